@@ -0,0 +1,102 @@
+package dit
+
+import (
+	"net/url"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// Page is one fetched page for ExtractPageTypesBatch.
+type Page struct {
+	URL        string `json:"url"`
+	HTML       string `json:"html"`
+	StatusCode int    `json:"status_code,omitempty"` // HTTP status; 0 if unknown
+}
+
+// PageBatchResult is the page type of one Page, with the metadata it came
+// with and what the page model read from its URL.
+type PageBatchResult struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Host       string `json:"host,omitempty"`
+	// URLFeatures is the normalized path and query that the page model's
+	// URL pipeline reads.
+	URLFeatures string `json:"url_features,omitempty"`
+	PageResult
+	Error string `json:"error,omitempty"` // set instead of the result when the page could not be classified
+}
+
+// ExtractPageTypesBatch classifies many pages at once, for crawl pipelines.
+// As with ExtractPageTypeURL, each page's URL feeds the page model's URL
+// features, as in training, and form actions are resolved against it.
+// Pages are classified concurrently; a page that fails gets Error and does
+// not fail the batch. Results are indexed like pages and not cached.
+func (c *Classifier) ExtractPageTypesBatch(pages []Page) ([]PageBatchResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if fc.PageModel == nil {
+		return nil, ErrNoPageModel
+	}
+
+	results := make([]PageBatchResult, len(pages))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(pages)) {
+		wg.Go(func() {
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(pages) {
+					return
+				}
+				results[i] = extractBatchPage(fc, pages[i])
+			}
+		})
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func extractBatchPage(fc *classifier.FormFieldClassifier, page Page) PageBatchResult {
+	result := PageBatchResult{
+		URL:         page.URL,
+		StatusCode:  page.StatusCode,
+		URLFeatures: classifier.PageURLExtractor{URL: page.URL}.ExtractString(nil, nil),
+	}
+	u, err := url.Parse(page.URL)
+	if err == nil {
+		result.Host = u.Hostname()
+	}
+	if err := checkHTML(page.HTML); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	doc, junk, err := loadPage(page.HTML)
+	if err != nil {
+		result.Error = "dit: " + err.Error()
+		return result
+	}
+	formResults, pageResult, _ := fc.ExtractPageDoc(doc, page.URL, false, 0, true)
+	result.Type = pageResult.Form
+	result.BotChallenge = junk == htmlutil.JunkBotChallenge
+	result.Parked = junk == htmlutil.JunkParked
+	result.Forms = newFormResults(formResults)
+	for i := range result.Forms {
+		resolveFormAction(&result.Forms[i].FormInfo, page.URL)
+	}
+	return result
+}
+
+// resolveFormAction makes info's action absolute against pageURL, if that
+// is an absolute URL. An unparsable action is left as written.
+func resolveFormAction(info *FormInfo, pageURL string) {
+	if u, err := url.Parse(pageURL); err == nil && u.IsAbs() {
+		_ = info.ResolveAction(pageURL)
+	}
+}
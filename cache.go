@@ -0,0 +1,224 @@
+package dit
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// Cache stores encoded classification results. Keys are hex digests of the
+// HTML content, the model version, and the requested operation, so a cache
+// may be shared between classifiers and survives model upgrades safely.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+}
+
+// Option configures a Classifier in New and Load.
+type Option func(*Classifier)
+
+// WithCache makes the classifier reuse results for HTML it has already
+// classified with the same model, e.g. when monitoring or retrying pages.
+func WithCache(cache Cache) Option {
+	return func(c *Classifier) {
+		c.cache = cache
+	}
+}
+
+// cached returns the cached result of op on html, or computes and stores it.
+// Without a cache it just calls compute. A result with a form that timed
+// out or panicked is returned but not stored, so a transient failure is
+// not served again, even from a DiskCache after a restart.
+func cached[T any](c *Classifier, fc *classifier.FormFieldClassifier, op, html string, compute func() (T, error)) (T, error) {
+	if c.cache == nil {
+		return compute()
+	}
+	version, err := c.modelVersion(fc)
+	if err != nil {
+		return compute()
+	}
+	if fc.Locators {
+		op += ":locators"
+	}
+	if t := fc.Templates; t != nil {
+		op += fmt.Sprintf(":templates=%g", t.Margin)
+	}
+
+	h := sha256.New()
+	for _, part := range []string{version, op, html} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+
+	var result T
+	if data, ok := c.cache.Get(key); ok {
+		if err := json.Unmarshal(data, &result); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err = compute()
+	if err != nil || hasFormErrors(result) {
+		return result, err
+	}
+	if data, err := json.Marshal(result); err == nil {
+		c.cache.Set(key, data)
+	}
+	return result, nil
+}
+
+// hasFormErrors reports whether result holds a form with an Error.
+func hasFormErrors(result any) bool {
+	failed := func(err string) bool { return err != "" }
+	switch r := result.(type) {
+	case []FormResult:
+		return slices.ContainsFunc(r, func(f FormResult) bool { return failed(f.Error) })
+	case []FormResultProba:
+		return slices.ContainsFunc(r, func(f FormResultProba) bool { return failed(f.Error) })
+	case []FormExplanation:
+		return slices.ContainsFunc(r, func(f FormExplanation) bool { return failed(f.Error) })
+	case *PageResult:
+		return r != nil && hasFormErrors(r.Forms)
+	case *PageResultProba:
+		return r != nil && hasFormErrors(r.Forms)
+	case *PageSummary:
+		return r != nil && r.FailedForms > 0
+	}
+	return false
+}
+
+// modelVersion returns the content hash of fc, the classifier's models as
+// snapshotted by the caller, computing it on first use after a change.
+func (c *Classifier) modelVersion(fc *classifier.FormFieldClassifier) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == "" || c.versionOf != fc {
+		version, err := fc.Version()
+		if err != nil {
+			return "", err
+		}
+		c.version, c.versionOf = version, fc
+	}
+	return c.version, nil
+}
+
+// resetModelVersion forgets the model version after the models change.
+func (c *Classifier) resetModelVersion() {
+	c.mu.Lock()
+	c.version = ""
+	c.mu.Unlock()
+}
+
+// MemoryCache is an in-memory Cache that evicts the least recently used
+// entry once it holds more than its size.
+type MemoryCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache returns an LRU cache holding up to size results.
+func NewMemoryCache(size int) *MemoryCache {
+	if size < 1 {
+		size = 1
+	}
+	return &MemoryCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached value for key and marks it as recently used.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.order.MoveToFront(elem)
+	return elem.Value.(*memoryCacheEntry).value, true
+}
+
+// Set stores value under key, evicting the least recently used entry if
+// the cache is full.
+func (m *MemoryCache) Set(key string, value []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.entries[key]; ok {
+		elem.Value.(*memoryCacheEntry).value = value
+		m.order.MoveToFront(elem)
+		return
+	}
+	m.entries[key] = m.order.PushFront(&memoryCacheEntry{key: key, value: value})
+	if m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// Len returns the number of cached results.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// DiskCache is a Cache storing one file per result in a directory, so
+// results persist across processes.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a cache backed by dir, creating it if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("dit: create cache directory: %w", err)
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get reads the cached value for key.
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(d.dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Set writes value for key. Write errors are ignored; the result is simply
+// recomputed next time.
+func (d *DiskCache) Set(key string, value []byte) {
+	tmp, err := os.CreateTemp(d.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(value)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(d.dir, key+".json")); err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
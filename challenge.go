@@ -0,0 +1,18 @@
+package dit
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// loadPage parses html for the page type methods and returns its
+// htmlutil.JunkPageKind. Bot-protection interstitials and parked domains
+// stand in for the page requested, and page models trained before such
+// pages were labeled tend to call them error or landing pages.
+func loadPage(html string) (*goquery.Document, string, error) {
+	doc, err := htmlutil.LoadHTMLString(html)
+	if err != nil {
+		return nil, "", err
+	}
+	return doc, htmlutil.JunkPageKind(doc), nil
+}
@@ -0,0 +1,238 @@
+package classifier
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Calibration methods for FitCalibration.
+const (
+	CalibrationPlatt    = "platt"    // sigmoid on the log-odds, for small data
+	CalibrationIsotonic = "isotonic" // monotone step fit, for plenty of data
+)
+
+// Calibration maps a model's raw class probabilities to calibrated ones,
+// so that e.g. 0.8 means right about 80% of the time. Each class is
+// calibrated one-vs-rest on held-out predictions; see FitCalibration.
+// Form and page type models apply theirs, if set, in ClassifyProba.
+type Calibration struct {
+	Method string `json:"method"`
+	// Platt holds the {A, B} of p' = 1 / (1 + exp(A*logit(p) + B)) by class.
+	Platt map[string][2]float64 `json:"platt,omitempty"`
+	// Isotonic holds the fitted curve by class.
+	Isotonic map[string]IsotonicCurve `json:"isotonic,omitempty"`
+}
+
+// IsotonicCurve is a non-decreasing piecewise linear function through the
+// points (X[i], Y[i]), constant beyond its ends.
+type IsotonicCurve struct {
+	X []float64 `json:"x"`
+	Y []float64 `json:"y"`
+}
+
+// FitCalibration fits a calibration of the given method from held-out
+// class probabilities and gold labels. Classes that are always or never
+// the label are left uncalibrated.
+func FitCalibration(method string, probas []map[string]float64, labels []string) (*Calibration, error) {
+	if method != CalibrationPlatt && method != CalibrationIsotonic {
+		return nil, fmt.Errorf("unknown calibration method %q (want %s or %s)", method, CalibrationPlatt, CalibrationIsotonic)
+	}
+	classSet := make(map[string]bool)
+	for _, proba := range probas {
+		for cls := range proba {
+			classSet[cls] = true
+		}
+	}
+
+	cal := &Calibration{Method: method}
+	for cls := range classSet {
+		p := make([]float64, len(labels))
+		y := make([]bool, len(labels))
+		positives := 0
+		for i, l := range labels {
+			p[i] = probas[i][cls]
+			y[i] = l == cls
+			if y[i] {
+				positives++
+			}
+		}
+		if positives == 0 || positives == len(labels) {
+			continue
+		}
+		switch method {
+		case CalibrationPlatt:
+			if cal.Platt == nil {
+				cal.Platt = make(map[string][2]float64)
+			}
+			cal.Platt[cls] = fitPlatt(p, y, positives)
+		case CalibrationIsotonic:
+			if cal.Isotonic == nil {
+				cal.Isotonic = make(map[string]IsotonicCurve)
+			}
+			cal.Isotonic[cls] = fitIsotonic(p, y)
+		}
+	}
+	return cal, nil
+}
+
+// Apply calibrates proba in place and returns it, renormalized to sum to
+// one. A nil Calibration returns proba unchanged.
+func (c *Calibration) Apply(proba map[string]float64) map[string]float64 {
+	if c == nil {
+		return proba
+	}
+	var sum float64
+	for cls, p := range proba {
+		if ab, ok := c.Platt[cls]; ok {
+			p = 1 / (1 + math.Exp(ab[0]*logit(p)+ab[1]))
+		} else if curve, ok := c.Isotonic[cls]; ok {
+			p = curve.at(p)
+		}
+		proba[cls] = p
+		sum += p
+	}
+	if sum > 0 {
+		for cls := range proba {
+			proba[cls] /= sum
+		}
+	}
+	return proba
+}
+
+// logit returns the log-odds of p, clipped away from 0 and 1.
+func logit(p float64) float64 {
+	const eps = 1e-7
+	p = min(max(p, eps), 1-eps)
+	return math.Log(p / (1 - p))
+}
+
+// fitPlatt fits A and B by Newton's method on the log loss, with Platt's
+// smoothed targets so that separable data does not drive them to infinity.
+func fitPlatt(p []float64, y []bool, positives int) [2]float64 {
+	negatives := len(y) - positives
+	hi := (float64(positives) + 1) / (float64(positives) + 2)
+	lo := 1 / (float64(negatives) + 2)
+	x := make([]float64, len(p))
+	t := make([]float64, len(p))
+	for i := range p {
+		x[i] = logit(p[i])
+		t[i] = lo
+		if y[i] {
+			t[i] = hi
+		}
+	}
+
+	loss := func(a, b float64) float64 {
+		var l float64
+		for i := range x {
+			z := a*x[i] + b
+			l += t[i]*softplus(z) + (1-t[i])*softplus(-z)
+		}
+		return l
+	}
+
+	a, b := 0.0, math.Log((float64(negatives)+1)/(float64(positives)+1))
+	current := loss(a, b)
+	for range 100 {
+		// Gradient and Hessian of the log loss in (a, b), where the
+		// predicted probability is q = 1 / (1 + exp(a*x + b)).
+		var ga, gb, haa, hab, hbb float64
+		for i := range x {
+			q := 1 / (1 + math.Exp(a*x[i]+b))
+			d := t[i] - q
+			w := q * (1 - q)
+			ga += d * x[i]
+			gb += d
+			haa += w * x[i] * x[i]
+			hab += w * x[i]
+			hbb += w
+		}
+		// Small ridge keeps the Hessian invertible.
+		haa += 1e-12
+		hbb += 1e-12
+		det := haa*hbb - hab*hab
+		if det <= 0 {
+			break
+		}
+		da := (hbb*ga - hab*gb) / det
+		db := (haa*gb - hab*ga) / det
+		// Halve the Newton step until it lowers the loss.
+		step := 1.0
+		for step > 1e-10 && loss(a-step*da, b-step*db) > current {
+			step /= 2
+		}
+		if step <= 1e-10 {
+			break
+		}
+		a, b = a-step*da, b-step*db
+		current = loss(a, b)
+		if math.Abs(step*da) < 1e-10 && math.Abs(step*db) < 1e-10 {
+			break
+		}
+	}
+	return [2]float64{a, b}
+}
+
+// softplus returns log(1 + exp(z)).
+func softplus(z float64) float64 {
+	if z > 0 {
+		return z + math.Log1p(math.Exp(-z))
+	}
+	return math.Log1p(math.Exp(z))
+}
+
+// fitIsotonic fits a non-decreasing curve of P(y) against p by pooling
+// adjacent violators, with one point per pooled block.
+func fitIsotonic(p []float64, y []bool) IsotonicCurve {
+	order := make([]int, len(p))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return p[order[i]] < p[order[j]] })
+
+	type block struct{ sumX, sumY, n float64 }
+	var blocks []block
+	for _, i := range order {
+		b := block{sumX: p[i], n: 1}
+		if y[i] {
+			b.sumY = 1
+		}
+		blocks = append(blocks, b)
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if prev.sumY/prev.n < last.sumY/last.n {
+				break
+			}
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1] = block{prev.sumX + last.sumX, prev.sumY + last.sumY, prev.n + last.n}
+		}
+	}
+
+	curve := IsotonicCurve{X: make([]float64, len(blocks)), Y: make([]float64, len(blocks))}
+	for i, b := range blocks {
+		curve.X[i] = b.sumX / b.n
+		curve.Y[i] = b.sumY / b.n
+	}
+	return curve
+}
+
+// at evaluates the curve at p.
+func (c IsotonicCurve) at(p float64) float64 {
+	if len(c.X) == 0 {
+		return p
+	}
+	i, _ := slices.BinarySearch(c.X, p)
+	switch {
+	case i == 0:
+		return c.Y[0]
+	case i == len(c.X):
+		return c.Y[len(c.Y)-1]
+	}
+	x0, x1 := c.X[i-1], c.X[i]
+	if x1 == x0 {
+		return c.Y[i]
+	}
+	return c.Y[i-1] + (c.Y[i]-c.Y[i-1])*(p-x0)/(x1-x0)
+}
@@ -1,10 +1,12 @@
 package classifier
 
 import (
+	"io"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
 // FormFieldClassifier detects HTML form, field, and page types.
@@ -12,18 +14,67 @@ type FormFieldClassifier struct {
 	FormModel  *FormTypeModel
 	FieldModel *FieldTypeModel
 	PageModel  *PageTypeModel
+	// FormTimeout bounds the classification of each form in ExtractForms
+	// and ExtractPage; 0 means no limit. Forms that time out or panic are
+	// reported with FormResult.Error instead of failing the whole page; a
+	// timed-out classification runs on in the background until it ends.
+	FormTimeout time.Duration
+	// Locators adds a Locator to every field result in ExtractForms and
+	// ExtractPage.
+	Locators bool
+	// Templates, if set, snaps field types in Classify to canonical
+	// sequences; see TemplateMatcher.
+	Templates *TemplateMatcher
+	// Languages holds models for specific page languages, keyed by the
+	// codes htmlutil.DetectLanguage returns. The Extract methods classify
+	// a page detected as one of them with its models, falling back to
+	// this classifier's own for any it lacks; the options above apply
+	// either way.
+	Languages map[string]*FormFieldClassifier
+}
+
+// ForLanguage returns the classifier for pages in lang: c with the form,
+// field, and page models of c.Languages[lang] where it has them, or c
+// itself if there is no model for lang.
+func (c *FormFieldClassifier) ForLanguage(lang string) *FormFieldClassifier {
+	m, ok := c.Languages[lang]
+	if !ok || lang == "" {
+		return c
+	}
+	routed := *c
+	routed.Languages = nil
+	if m.FormModel != nil {
+		routed.FormModel = m.FormModel
+	}
+	if m.FieldModel != nil {
+		routed.FieldModel = m.FieldModel
+	}
+	if m.PageModel != nil {
+		routed.PageModel = m.PageModel
+	}
+	return &routed
+}
+
+// route returns the classifier for the page sel belongs to.
+func (c *FormFieldClassifier) route(sel *goquery.Selection) *FormFieldClassifier {
+	if len(c.Languages) == 0 {
+		return c
+	}
+	return c.ForLanguage(htmlutil.DetectLanguage(sel))
 }
 
 // ClassifyResult holds the classification result for a form.
 type ClassifyResult struct {
-	Form   string            `json:"form"`
-	Fields map[string]string `json:"fields,omitempty"`
+	Form      string            `json:"form"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	FieldList []FieldResult     `json:"field_list,omitempty"` // fields in document order
 }
 
 // ClassifyProbaResult holds probability-based classification results.
 type ClassifyProbaResult struct {
-	Form   map[string]float64            `json:"form"`
-	Fields map[string]map[string]float64 `json:"fields,omitempty"`
+	Form      map[string]float64            `json:"form"`
+	Fields    map[string]map[string]float64 `json:"fields,omitempty"`
+	FieldList []FieldProbaResult            `json:"field_list,omitempty"` // fields in document order
 }
 
 // Classify returns the form type and field types.
@@ -31,7 +82,16 @@ func (c *FormFieldClassifier) Classify(form *goquery.Selection, fields bool) Cla
 	formType := c.FormModel.Classify(form)
 	result := ClassifyResult{Form: formType}
 	if fields && c.FieldModel != nil {
-		result.Fields = c.FieldModel.Classify(form, formType)
+		result.FieldList = c.FieldModel.ClassifyFields(form, formType)
+		if c.Templates != nil && len(result.FieldList) > 0 {
+			probas := c.FieldModel.ClassifyFieldsProba(form, formType)
+			marginals := make([]map[string]float64, len(probas))
+			for i, f := range probas {
+				marginals[i] = f.Proba
+			}
+			c.Templates.Apply(result.FieldList, marginals, htmlutil.GetFieldsToAnnotate(form))
+		}
+		result.Fields = fieldTypeMap(result.FieldList)
 	}
 	return result
 }
@@ -43,19 +103,15 @@ func (c *FormFieldClassifier) ClassifyProba(form *goquery.Selection, threshold f
 	result := ClassifyProbaResult{Form: filtered}
 
 	if fields && c.FieldModel != nil {
-		// Use most likely form type for field classification
-		bestFormType := ""
-		bestProb := -1.0
-		for cls, prob := range formProba {
-			if prob > bestProb {
-				bestProb = prob
-				bestFormType = cls
-			}
+		// Use the predicted form type for field classification
+		fieldProba := c.FieldModel.ClassifyFieldsProba(form, c.FormModel.Predict(formProba))
+		result.FieldList = make([]FieldProbaResult, len(fieldProba))
+		for i, f := range fieldProba {
+			result.FieldList[i] = FieldProbaResult{Name: f.Name, Proba: thresholdMap(f.Proba, threshold)}
 		}
-		fieldProba := c.FieldModel.ClassifyProba(form, bestFormType)
-		result.Fields = make(map[string]map[string]float64)
-		for name, probs := range fieldProba {
-			result.Fields[name] = thresholdMap(probs, threshold)
+		result.Fields = fieldProbaMap(result.FieldList)
+		if result.Fields == nil {
+			result.Fields = make(map[string]map[string]float64)
 		}
 	}
 
@@ -64,36 +120,50 @@ func (c *FormFieldClassifier) ClassifyProba(form *goquery.Selection, threshold f
 
 // ClassifyPage classifies the page type using form results as features.
 func (c *FormFieldClassifier) ClassifyPage(doc *goquery.Document) string {
-	formResults := c.classifyFormsOnDoc(doc)
+	formResults := c.ClassifyForms(doc)
 	return c.PageModel.Classify(doc, formResults)
 }
 
 // ClassifyPageProba returns page type probabilities.
 func (c *FormFieldClassifier) ClassifyPageProba(doc *goquery.Document, threshold float64) map[string]float64 {
-	formResults := c.classifyFormsOnDoc(doc)
+	formResults := c.ClassifyForms(doc)
 	proba := c.PageModel.ClassifyProba(doc, formResults)
 	return thresholdMap(proba, threshold)
 }
 
 // ExtractPage classifies both the page type and forms from HTML.
 func (c *FormFieldClassifier) ExtractPage(htmlStr string, proba bool, threshold float64, classifyFields bool) ([]FormResult, ClassifyResult, ClassifyProbaResult, error) {
+	return c.ExtractPageURL(htmlStr, "", proba, threshold, classifyFields)
+}
+
+// ExtractPageURL is ExtractPage for a page fetched from pageURL, which the
+// page model's URL pipeline reads; see PageTypeModel.ClassifyURL.
+func (c *FormFieldClassifier) ExtractPageURL(htmlStr, pageURL string, proba bool, threshold float64, classifyFields bool) ([]FormResult, ClassifyResult, ClassifyProbaResult, error) {
 	doc, err := htmlutil.LoadHTMLString(htmlStr)
 	if err != nil {
 		return nil, ClassifyResult{}, ClassifyProbaResult{}, err
 	}
+	formResults, pageResult, pageProba := c.ExtractPageDoc(doc, pageURL, proba, threshold, classifyFields)
+	return formResults, pageResult, pageProba, nil
+}
+
+// ExtractPageDoc is ExtractPageURL for an already parsed page, for callers
+// that also inspect the document themselves.
+func (c *FormFieldClassifier) ExtractPageDoc(doc *goquery.Document, pageURL string, proba bool, threshold float64, classifyFields bool) ([]FormResult, ClassifyResult, ClassifyProbaResult) {
+	c = c.route(doc.Selection)
 
 	forms := htmlutil.GetForms(doc)
 	formResults := make([]FormResult, len(forms))
 	var classifyResults []ClassifyResult
 
 	for i, form := range forms {
-		formResults[i].FormHTML, _ = form.Html()
-		if proba {
-			formResults[i].Proba = c.ClassifyProba(form, threshold, classifyFields)
-		} else {
-			formResults[i].Result = c.Classify(form, classifyFields)
+		var typeOnly ClassifyResult
+		formResults[i] = c.extractForm(i, form, proba, threshold, classifyFields, func() {
+			typeOnly = c.Classify(form, false)
+		})
+		if formResults[i].Error == "" {
+			classifyResults = append(classifyResults, typeOnly)
 		}
-		classifyResults = append(classifyResults, c.Classify(form, false))
 	}
 
 	var pageResult ClassifyResult
@@ -101,21 +171,21 @@ func (c *FormFieldClassifier) ExtractPage(htmlStr string, proba bool, threshold
 	if c.PageModel != nil {
 		if proba {
 			pageProba = ClassifyProbaResult{
-				Form: c.PageModel.ClassifyProba(doc, classifyResults),
+				Form: c.PageModel.ClassifyProbaURL(doc, classifyResults, pageURL),
 			}
 			pageProba.Form = thresholdMap(pageProba.Form, threshold)
 		} else {
 			pageResult = ClassifyResult{
-				Form: c.PageModel.Classify(doc, classifyResults),
+				Form: c.PageModel.ClassifyURL(doc, classifyResults, pageURL),
 			}
 		}
 	}
 
-	return formResults, pageResult, pageProba, nil
+	return formResults, pageResult, pageProba
 }
 
-// classifyFormsOnDoc runs form classification on all forms in a document.
-func (c *FormFieldClassifier) classifyFormsOnDoc(doc *goquery.Document) []ClassifyResult {
+// ClassifyForms runs form type classification (without fields) on all forms in a document.
+func (c *FormFieldClassifier) ClassifyForms(doc *goquery.Document) []ClassifyResult {
 	forms := htmlutil.GetForms(doc)
 	results := make([]ClassifyResult, len(forms))
 	for i, form := range forms {
@@ -126,53 +196,132 @@ func (c *FormFieldClassifier) classifyFormsOnDoc(doc *goquery.Document) []Classi
 
 // ExtractForms extracts and classifies all forms from HTML.
 func (c *FormFieldClassifier) ExtractForms(htmlStr string, proba bool, threshold float64, classifyFields bool) ([]FormResult, error) {
-	doc, err := htmlutil.LoadHTMLString(htmlStr)
+	return c.ExtractFormsFromReader(strings.NewReader(htmlStr), proba, threshold, classifyFields)
+}
+
+// ExtractFormsFromReader extracts and classifies forms from an io.Reader.
+func (c *FormFieldClassifier) ExtractFormsFromReader(r io.Reader, proba bool, threshold float64, classifyFields bool) ([]FormResult, error) {
+	doc, err := htmlutil.LoadHTML(r)
 	if err != nil {
 		return nil, err
 	}
+	return c.ExtractFormsDoc(doc, proba, threshold, classifyFields), nil
+}
 
+// ExtractFormsDoc classifies all forms in an already parsed document.
+func (c *FormFieldClassifier) ExtractFormsDoc(doc *goquery.Document, proba bool, threshold float64, classifyFields bool) []FormResult {
+	c = c.route(doc.Selection)
 	forms := htmlutil.GetForms(doc)
 	results := make([]FormResult, len(forms))
-
 	for i, form := range forms {
-		results[i].FormHTML, _ = form.Html()
+		results[i] = c.extractForm(i, form, proba, threshold, classifyFields, nil)
+	}
+	return results
+}
+
+// ExtractForm classifies a single form the caller selected; its Meta.Index
+// is its position among the document's forms (see htmlutil.FormIndex).
+func (c *FormFieldClassifier) ExtractForm(form *goquery.Selection, proba bool, threshold float64, classifyFields bool) FormResult {
+	return c.route(form).extractForm(htmlutil.FormIndex(form), form, proba, threshold, classifyFields, nil)
+}
+
+// extractForm classifies the index-th form in isolation (see FormTimeout),
+// running extra, if set, within the same isolation.
+func (c *FormFieldClassifier) extractForm(index int, form *goquery.Selection, proba bool, threshold float64, classifyFields bool, extra func()) FormResult {
+	formHTML, _ := form.Html()
+	var result ClassifyResult
+	var probaResult ClassifyProbaResult
+	meta := NewFormMeta(index, form)
+	tokens := htmlutil.GetCSRFTokens(form)
+	err := isolate(c.FormTimeout, func() {
 		if proba {
-			results[i].Proba = c.ClassifyProba(form, threshold, classifyFields)
+			probaResult = c.ClassifyProba(form, threshold, classifyFields)
 		} else {
-			results[i].Result = c.Classify(form, classifyFields)
+			result = c.Classify(form, classifyFields)
+		}
+		if extra != nil {
+			extra()
+		}
+		addElements(form, result.FieldList, probaResult.FieldList)
+		if c.Locators {
+			addLocators(index, form, result.FieldList, probaResult.FieldList)
 		}
+	})
+	if err != nil {
+		return FormResult{FormHTML: formHTML, Meta: meta, CSRFTokens: tokens, Error: err.Error()}
 	}
+	return FormResult{FormHTML: formHTML, Meta: meta, Result: result, Proba: probaResult, CSRFTokens: tokens}
+}
 
-	return results, nil
+// FormMeta describes where a form sits on its page and how it submits.
+type FormMeta struct {
+	Index  int    `json:"index"`            // position among the page's forms
+	Action string `json:"action,omitempty"` // see htmlutil.ResolveFormAction
+	Method string `json:"method"`           // see htmlutil.GetSubmitMethod
+	ID     string `json:"id,omitempty"`
+	Class  string `json:"class,omitempty"`
+	Hash   string `json:"hash"` // see htmlutil.FormHash
 }
 
-// ExtractFormsFromReader extracts and classifies forms from an io.Reader.
-func (c *FormFieldClassifier) ExtractFormsFromReader(r *strings.Reader, proba bool, threshold float64, classifyFields bool) ([]FormResult, error) {
-	doc, err := htmlutil.LoadHTML(r)
-	if err != nil {
-		return nil, err
+// NewFormMeta returns the metadata of the index-th form on a page.
+func NewFormMeta(index int, form *goquery.Selection) FormMeta {
+	return FormMeta{
+		Index:  index,
+		Action: htmlutil.ResolveFormAction(form),
+		Method: htmlutil.GetSubmitMethod(form),
+		ID:     form.AttrOr("id", ""),
+		Class:  strings.Join(strings.Fields(form.AttrOr("class", "")), " "),
+		Hash:   htmlutil.FormHash(form),
 	}
+}
 
-	forms := htmlutil.GetForms(doc)
-	results := make([]FormResult, len(forms))
-
-	for i, form := range forms {
-		results[i].FormHTML, _ = form.Html()
-		if proba {
-			results[i].Proba = c.ClassifyProba(form, threshold, classifyFields)
-		} else {
-			results[i].Result = c.Classify(form, classifyFields)
+// addElements sets the Element of each field result; the results are in
+// the order of GetFieldsToAnnotate, as the field model returns them.
+func addElements(form *goquery.Selection, fields []FieldResult, probaFields []FieldProbaResult) {
+	if len(fields) == 0 && len(probaFields) == 0 {
+		return
+	}
+	for i, elem := range htmlutil.GetFieldsToAnnotate(form) {
+		e := NewElement(elem)
+		if i < len(fields) {
+			fields[i].Element = e
+		}
+		if i < len(probaFields) {
+			probaFields[i].Element = e
 		}
 	}
+}
 
-	return results, nil
+// addLocators sets the Locator of each field result; the results are in
+// the order of GetFieldsToAnnotate, as the field model returns them.
+func addLocators(formIndex int, form *goquery.Selection, fields []FieldResult, probaFields []FieldProbaResult) {
+	for i, elem := range htmlutil.GetFieldsToAnnotate(form) {
+		loc := &Locator{
+			CSS:        htmlutil.CSSPath(elem),
+			XPath:      htmlutil.XPath(elem),
+			FormIndex:  formIndex,
+			FieldIndex: i,
+		}
+		if i < len(fields) {
+			fields[i].Locator = loc
+		}
+		if i < len(probaFields) {
+			probaFields[i].Locator = loc
+		}
+	}
 }
 
-// FormResult holds the result for a single form.
+// FormResult holds the result for a single form. Error is set, and the
+// results left empty, if the form could not be classified.
 type FormResult struct {
 	FormHTML string              `json:"form_html"`
+	Meta     FormMeta            `json:"meta"`
 	Result   ClassifyResult      `json:"result,omitempty"`
 	Proba    ClassifyProbaResult `json:"proba,omitempty"`
+	// CSRFTokens are the form's hidden anti-forgery tokens; see
+	// htmlutil.GetCSRFTokens.
+	CSRFTokens []htmlutil.CSRFToken `json:"csrf_tokens,omitempty"`
+	Error      string               `json:"error,omitempty"`
 }
 
 func thresholdMap(m map[string]float64, threshold float64) map[string]float64 {
@@ -1,10 +1,25 @@
 package classifier
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/crf"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/vectorizer"
 )
 
 func TestFormFeatureExtractors(t *testing.T) {
@@ -24,7 +39,7 @@ func TestFormFeatureExtractors(t *testing.T) {
 		t.Fatal(err)
 	}
 	forms := htmlutil.GetForms(doc)
-	form := forms[0]
+	form := htmlutil.NewFormView(forms[0])
 
 	// Test FormElements
 	fe := FormElements{}
@@ -109,6 +124,106 @@ func TestElemFeatures(t *testing.T) {
 	if !ok || len(optTexts) == 0 {
 		t.Error("expected option-text")
 	}
+	if _, ok := feat["implicit-label"]; ok {
+		t.Error("implicit-label set for a field with a <label>")
+	}
+
+	doc, _ = htmlutil.LoadHTMLString(`<form><div>Your email</div><div><input name="e"/></div></form>`)
+	form := htmlutil.GetForms(doc)[0]
+	implicit := ElemFeatures(htmlutil.GetFieldsToAnnotate(form)[0], form)
+	if got, _ := implicit["implicit-label"].([]string); strings.Join(got, " ") != "your email" {
+		t.Errorf("implicit-label = %v, want [your email]", implicit["implicit-label"])
+	}
+}
+
+func TestElemFeaturesOptionLimits(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<form><select name="country">`)
+	for i := range 200 {
+		fmt.Fprintf(&b, `<option value="c%d">Country %d with a rather long descriptive name</option>`, i, i)
+	}
+	b.WriteString(`</select></form>`)
+
+	doc, _ := htmlutil.LoadHTMLString(b.String())
+	form := htmlutil.GetForms(doc)[0]
+	elem := htmlutil.GetFieldsToAnnotate(form)[0]
+
+	feat := ElemFeatures(elem, form)
+	texts := feat["option-text"].([]string)
+	values := feat["option-value"].([]string)
+	if len(texts) != SelectOptionLimits.MaxOptions || len(values) != SelectOptionLimits.MaxOptions {
+		t.Fatalf("got %d texts, %d values, want %d", len(texts), len(values), SelectOptionLimits.MaxOptions)
+	}
+	if values[0] != "c0" || values[len(values)-1] != "c199" {
+		t.Errorf("sample should keep first and last options, got %q..%q", values[0], values[len(values)-1])
+	}
+	for _, text := range texts {
+		if n := len([]rune(text)); n > SelectOptionLimits.MaxTextLen {
+			t.Errorf("option text %q has %d runes, want <= %d", text, n, SelectOptionLimits.MaxTextLen)
+		}
+	}
+	again := ElemFeatures(elem, form)["option-value"].([]string)
+	if !slices.Equal(values, again) {
+		t.Error("option sampling is not deterministic")
+	}
+
+	defer func(l OptionLimits) { SelectOptionLimits = l }(SelectOptionLimits)
+	SelectOptionLimits = OptionLimits{}
+	if got := len(ElemFeatures(elem, form)["option-text"].([]string)); got != 200 {
+		t.Errorf("unlimited: got %d options, want 200", got)
+	}
+}
+
+func TestFieldModelOptionLimits(t *testing.T) {
+	defer func(l OptionLimits) { SelectOptionLimits = l }(SelectOptionLimits)
+	SelectOptionLimits = OptionLimits{MaxOptions: 3, MaxTextLen: 5}
+	fc := &FormFieldClassifier{FieldModel: TrainFieldType(nil, crf.DefaultTrainerConfig())}
+	if fc.FieldModel.OptionLimits != SelectOptionLimits {
+		t.Fatalf("trained limits = %+v, want %+v", fc.FieldModel.OptionLimits, SelectOptionLimits)
+	}
+
+	// Saved limits survive a change of SelectOptionLimits; a model saved
+	// without them uses none.
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := fc.SaveModel(path); err != nil {
+		t.Fatal(err)
+	}
+	SelectOptionLimits = OptionLimits{}
+	loaded, err := LoadClassifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.FieldModel.OptionLimits; got != (OptionLimits{MaxOptions: 3, MaxTextLen: 5}) {
+		t.Errorf("loaded limits = %+v, want the trained ones", got)
+	}
+	legacy, err := ParseClassifier([]byte(fmt.Sprintf(`{"format": 3, "feature_schema": %d, "field_model": {"num_labels": 1}}`, FeatureSchema)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := legacy.FieldModel.OptionLimits; got != (OptionLimits{}) {
+		t.Errorf("legacy limits = %+v, want none", got)
+	}
+}
+
+func TestSampleIndices(t *testing.T) {
+	tests := []struct {
+		n, k int
+		want []int
+	}{
+		{3, 0, []int{0, 1, 2}},
+		{3, 5, []int{0, 1, 2}},
+		{10, 1, []int{0}},
+		{10, 4, []int{0, 3, 6, 9}},
+		{0, 4, []int{}},
+	}
+	for _, tt := range tests {
+		if got := sampleIndices(tt.n, tt.k); !slices.Equal(got, tt.want) {
+			t.Errorf("sampleIndices(%d, %d) = %v, want %v", tt.n, tt.k, got, tt.want)
+		}
+	}
+	if got := truncateRunes("ülkeler", 3); got != "ülk" {
+		t.Errorf("truncateRunes = %q, want %q", got, "ülk")
+	}
 }
 
 func TestGetFormFeatures(t *testing.T) {
@@ -152,3 +267,1209 @@ func TestGetFormFeatures(t *testing.T) {
 		t.Errorf("bias = %v", feats[0]["bias"])
 	}
 }
+
+func TestFormPositionFeatures(t *testing.T) {
+	doc, _ := htmlutil.LoadHTMLString(`<html><body>
+<header><form id="search"><input type="search" name="q"/></form></header>
+<form id="plain"><input name="x"/></form>
+</body></html>`)
+
+	feats := FormPosition{}.ExtractDict(htmlutil.NewFormView(doc.Find("#search")))
+	if feats["landmark"] != "header" {
+		t.Errorf("landmark = %v, want header", feats["landmark"])
+	}
+	feats = FormPosition{}.ExtractDict(htmlutil.NewFormView(doc.Find("#plain")))
+	if feats["landmark"] != "none" {
+		t.Errorf("landmark = %v, want none", feats["landmark"])
+	}
+	if feats["hidden"] != false {
+		t.Errorf("hidden = %v, want false", feats["hidden"])
+	}
+}
+
+func TestFormTypeModelMissingPipeline(t *testing.T) {
+	loginDoc, _ := htmlutil.LoadHTMLString(`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`)
+	searchDoc, _ := htmlutil.LoadHTMLString(`<form><input type="search" name="q"/></form>`)
+	login := htmlutil.GetForms(loginDoc)[0]
+	search := htmlutil.GetForms(searchDoc)[0]
+
+	model := TrainFormType([]*goquery.Selection{login, search}, []string{"login", "search"}, DefaultFormTypeTrainConfig())
+
+	// Simulate a model saved before the last pipeline existed.
+	last := len(model.Pipelines) - 1
+	dropped := model.Pipelines[last].DictVec.VocabSize()
+	model.Pipelines = model.Pipelines[:last]
+	for c := range model.Coef {
+		model.Coef[c] = model.Coef[c][:len(model.Coef[c])-dropped]
+	}
+	model.InitRuntime()
+
+	if got := model.Classify(login); got != "login" {
+		t.Errorf("Classify(login) = %q, want login", got)
+	}
+}
+
+func TestFormElementsHTML5Types(t *testing.T) {
+	doc, _ := htmlutil.LoadHTMLString(`<form>
+  <input type="tel" name="phone" required/>
+  <input type="datetime-local" name="when"/>
+  <input type="file" name="cv"/>
+  <input type="search" name="q"/>
+</form>`)
+	feats := FormElements{}.ExtractDict(htmlutil.NewFormView(htmlutil.GetForms(doc)[0]))
+
+	for _, key := range []string{"has <input type=tel>", "has <input type=date>", "has <input type=file>", "has <input type=search>", "has required field"} {
+		if feats[key] != true {
+			t.Errorf("%s = %v, want true", key, feats[key])
+		}
+	}
+	for _, key := range []string{"has <input type=url>", "has <input type=number>", "has <input type=range>", "has <input type=color>"} {
+		if feats[key] != false {
+			t.Errorf("%s = %v, want false", key, feats[key])
+		}
+	}
+}
+
+func TestTrainFormTypeFrozenVocab(t *testing.T) {
+	load := func(htmls ...string) []*goquery.Selection {
+		var forms []*goquery.Selection
+		for _, html := range htmls {
+			doc, _ := htmlutil.LoadHTMLString(html)
+			forms = append(forms, htmlutil.GetForms(doc)[0])
+		}
+		return forms
+	}
+	config := DefaultFormTypeTrainConfig()
+	config.ScalePipelines = true
+	old := TrainFormType(load(
+		`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		`<form><input type="search" name="q"/></form>`,
+	), []string{"login", "search"}, config)
+
+	config.Vocab = old.Pipelines
+	retrained := TrainFormType(load(
+		`<form><input type="text" name="login"/><input type="password" name="password"/><input type="submit" value="Sign in"/></form>`,
+		`<form><input type="text" name="query"/><button>Find</button></form>`,
+		`<form><input type="email" name="email"/><input type="password" name="pwd"/></form>`,
+	), []string{"login", "search", "login"}, config)
+
+	if !reflect.DeepEqual(retrained.Pipelines, old.Pipelines) {
+		t.Error("frozen vocabulary changed the pipelines")
+	}
+	if got, want := len(retrained.Coef[0]), len(old.Coef[0]); got != want {
+		t.Errorf("retrained with %d features, want the old %d", got, want)
+	}
+	if reflect.DeepEqual(retrained.Coef, old.Coef) {
+		t.Error("weights were not retrained")
+	}
+}
+
+func TestTrainFormTypeScalePipelines(t *testing.T) {
+	var forms []*goquery.Selection
+	var labels []string
+	for _, html := range []string{
+		`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		`<form><input type="text" name="login"/><input type="password" name="password"/></form>`,
+		`<form><input type="search" name="q"/></form>`,
+		`<form><input type="text" name="query"/></form>`,
+	} {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+	}
+	labels = []string{"login", "login", "search", "search"}
+
+	config := DefaultFormTypeTrainConfig()
+	config.ScalePipelines = true
+	config.PipelineScales = map[string]float64{"submit text": 2}
+	model := TrainFormType(forms, labels, config)
+
+	for _, p := range model.Pipelines {
+		switch {
+		case p.Name == "submit text":
+			if p.Scale != 2 {
+				t.Errorf("submit text scale = %v, want override 2", p.Scale)
+			}
+		case p.Name == "form elements":
+			if p.Scale <= 0 || p.Scale >= 1 {
+				t.Errorf("form elements scale = %v, want in (0, 1)", p.Scale)
+			}
+		}
+	}
+
+	// At inference the form elements block (pipeline 0) must be rescaled
+	// the same way, giving unit mean norm over the training forms.
+	sum := 0.0
+	for _, form := range forms {
+		features := model.extractFeatures(form, new(formScratch))
+		sq := 0.0
+		for i, idx := range features.Indices {
+			if idx < model.vecDims[0] {
+				sq += features.Values[i] * features.Values[i]
+			}
+		}
+		sum += math.Sqrt(sq)
+	}
+	if mean := sum / float64(len(forms)); math.Abs(mean-1) > 1e-9 {
+		t.Errorf("mean form elements norm = %v, want 1", mean)
+	}
+	if got := model.Classify(forms[2]); got != "search" {
+		t.Errorf("Classify = %q, want search", got)
+	}
+}
+
+func TestTuneThresholds(t *testing.T) {
+	probas := []map[string]float64{
+		{"login": 0.9, "search": 0.1},
+		{"login": 0.6, "search": 0.4},
+		{"login": 0.45, "search": 0.55},
+		{"login": 0.2, "search": 0.8},
+	}
+	labels := []string{"login", "login", "search", "search"}
+
+	thresholds := TuneThresholds(probas, labels, 0)
+	if thresholds["login"] != 0.6 {
+		t.Errorf("login threshold = %v, want 0.6", thresholds["login"])
+	}
+	if thresholds["search"] != 0.55 {
+		t.Errorf("search threshold = %v, want 0.55", thresholds["search"])
+	}
+
+	// Row 1 mislabeled as search: 100% precision for login only at 0.9.
+	labels = []string{"login", "search", "search", "search"}
+	thresholds = TuneThresholds(probas, labels, 1)
+	if thresholds["login"] != 0.9 {
+		t.Errorf("login threshold at precision 1 = %v, want 0.9", thresholds["login"])
+	}
+}
+
+func TestFormTypeModelPredictThresholds(t *testing.T) {
+	proba := map[string]float64{"login": 0.55, "search": 0.45}
+
+	model := &FormTypeModel{}
+	if got := model.Predict(proba); got != "login" {
+		t.Errorf("Predict without thresholds = %q, want login", got)
+	}
+
+	model.Thresholds = map[string]float64{"login": 0.7, "search": 0.3}
+	if got := model.Predict(proba); got != "search" {
+		t.Errorf("Predict with thresholds = %q, want search", got)
+	}
+
+	model.Thresholds = map[string]float64{"login": 0.9, "search": 0.9}
+	if got := model.Predict(proba); got != "login" {
+		t.Errorf("Predict with no class passing = %q, want login", got)
+	}
+}
+
+func TestExtractRawFeaturesParallel(t *testing.T) {
+	var forms []*goquery.Selection
+	for _, html := range []string{
+		`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		`<form><input type="search" name="q"/><input type="submit" value="Go"/></form>`,
+		`<form><input type="email" name="email"/></form>`,
+	} {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+	}
+	pipelines := DefaultFeaturePipelines()
+
+	serialDicts, serialTexts := extractRawFeatures(pipelines, forms, 1)
+	dicts, texts := extractRawFeatures(pipelines, forms, 4)
+	if !reflect.DeepEqual(dicts, serialDicts) || !reflect.DeepEqual(texts, serialTexts) {
+		t.Error("parallel feature extraction differs from serial")
+	}
+}
+
+func TestIsolate(t *testing.T) {
+	if err := isolate(0, func() {}); err != nil {
+		t.Errorf("isolate = %v, want nil", err)
+	}
+	if err := isolate(0, func() { panic("boom") }); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("isolate of a panic = %v, want panic error", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	if err := isolate(10*time.Millisecond, func() { <-release }); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("isolate of a stalled form = %v, want timeout error", err)
+	}
+}
+
+var updateGolden = flag.Bool("update", false, "rewrite testdata golden files")
+
+// featureFixtures are forms whose field features are pinned by
+// testdata/field_features.golden.json.
+var featureFixtures = map[string]string{
+	"login": `<form method="post" action="/session">
+  <label for="u">Email or username</label><input type="text" name="login" id="u" placeholder="you@example.com" class="form-input"/>
+  <label>Password <input type="password" name="password"/></label>
+  <input type="checkbox" name="remember_me" value="1"/> Keep me signed in
+  <input type="submit" value="Sign in"/>
+</form>`,
+	"registration": `<form action="/signup">
+  <div>First name</div><div><input name="first_name"/></div>
+  <span id="em">E-mail</span><input type="email" name="email" aria-labelledby="em"/>
+  <select name="birth_year">` + yearOptions(1900, 2010) + `</select>
+  <textarea name="bio" title="About you"></textarea>
+  <input type="radio" name="gender" value="f"/><input type="radio" name="gender" value="m"/>
+  <button type="submit">Create account</button>
+</form>`,
+	"search": `<form role="search"><input type="search" name="q" placeholder="Search docs"/><button>Go</button></form>`,
+	"mfa": `<form method="post" action="/sessions/two-factor">
+  <label for="otp">Authentication code</label>
+  <input type="text" name="otp" id="otp" inputmode="numeric" autocomplete="one-time-code" maxlength="6" pattern="[0-9]*"/>
+  <button type="submit">Verify</button>
+</form>`,
+}
+
+func yearOptions(from, to int) string {
+	var b strings.Builder
+	for y := from; y <= to; y++ {
+		fmt.Fprintf(&b, `<option value="%d">%d</option>`, y, y)
+	}
+	return b.String()
+}
+
+type featureSnapshot struct {
+	FeatureSchema int                             `json:"feature_schema"`
+	Forms         map[string][]map[string]float64 `json:"forms"`
+}
+
+func TestFieldFeatureSnapshot(t *testing.T) {
+	got := featureSnapshot{FeatureSchema: FeatureSchema, Forms: make(map[string][]map[string]float64)}
+	for name, html := range featureFixtures {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		form := htmlutil.GetForms(doc)[0]
+		var attrs []map[string]float64
+		for _, feat := range GetFormFeatures(form, name, nil) {
+			attrs = append(attrs, crf.FeaturesToAttributes(feat))
+		}
+		got.Forms[name] = attrs
+	}
+
+	path := filepath.Join("testdata", "field_features.golden.json")
+	if *updateGolden {
+		data, err := json.MarshalIndent(got, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want featureSnapshot
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+	if want.FeatureSchema != FeatureSchema {
+		t.Fatalf("FeatureSchema is %d but %s records %d; regenerate it with go test ./classifier -run TestFieldFeatureSnapshot -update",
+			FeatureSchema, path, want.FeatureSchema)
+	}
+	for name := range featureFixtures {
+		if !reflect.DeepEqual(got.Forms[name], want.Forms[name]) {
+			t.Errorf("field features for %q changed; bump FeatureSchema and regenerate %s with -update, since models trained on the old features will mispredict",
+				name, path)
+			logAttrDiff(t, want.Forms[name], got.Forms[name])
+		}
+	}
+}
+
+func logAttrDiff(t *testing.T, want, got []map[string]float64) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Logf("  %d fields, want %d", len(got), len(want))
+		return
+	}
+	for i := range got {
+		for attr, v := range got[i] {
+			if w, ok := want[i][attr]; !ok || w != v {
+				t.Logf("  field %d: + %s=%g", i, attr, v)
+			}
+		}
+		for attr, v := range want[i] {
+			if _, ok := got[i][attr]; !ok {
+				t.Logf("  field %d: - %s=%g", i, attr, v)
+			}
+		}
+	}
+}
+
+func TestParseClassifierUnrecordedSchema(t *testing.T) {
+	// Field models saved before the schema was recorded were trained on
+	// schema 1 features.
+	_, err := ParseClassifier([]byte(`{"format": 3, "field_model": {"num_labels": 1}}`))
+	if !errors.Is(err, ErrFeatureSchema) {
+		t.Errorf("err = %v, want ErrFeatureSchema", err)
+	}
+	if _, err := ParseClassifier([]byte(`{"format": 3, "form_model": {"classes": ["login"]}}`)); err != nil {
+		t.Errorf("form model without a recorded schema: %v", err)
+	}
+}
+
+func TestMigrateModel(t *testing.T) {
+	legacy := []byte(`{"form_model": {"classes": ["login"]}, "field_model": null, "page_model": null}`)
+	out, from, err := MigrateModel(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 {
+		t.Errorf("from = %d, want 0", from)
+	}
+	var um UnifiedModel
+	if err := json.Unmarshal(out, &um); err != nil {
+		t.Fatal(err)
+	}
+	if um.Format != ModelFormat || um.FormModel == nil || um.FormModel.Classes[0] != "login" {
+		t.Errorf("migrated model = %+v", um)
+	}
+
+	current := []byte(fmt.Sprintf(`{"format": %d}`, ModelFormat))
+	if out, from, err := MigrateModel(current); err != nil || from != ModelFormat || string(out) != string(current) {
+		t.Errorf("current format: out=%s from=%d err=%v", out, from, err)
+	}
+
+	newer := []byte(fmt.Sprintf(`{"format": %d}`, ModelFormat+1))
+	if _, _, err := MigrateModel(newer); !errors.Is(err, ErrModelFormat) {
+		t.Errorf("newer format err = %v, want ErrModelFormat", err)
+	}
+	if _, _, err := MigrateModel([]byte(`{"format": -1}`)); !errors.Is(err, ErrModelFormat) {
+		t.Errorf("negative format err = %v, want ErrModelFormat", err)
+	}
+	if len(migrations) != ModelFormat {
+		t.Errorf("%d migrations for ModelFormat %d; add one per format bump", len(migrations), ModelFormat)
+	}
+}
+
+func TestDistillPageModel(t *testing.T) {
+	pages := map[string][]string{
+		"login": {"Sign in to your account", "Sign in", "Member sign in", "Sign in here"},
+		"blog":  {"Latest blog posts", "Our blog", "Blog archive", "Company blog"},
+	}
+	var docs []*goquery.Document
+	var labels, urls []string
+	for _, label := range []string{"login", "blog"} {
+		for i, title := range pages[label] {
+			doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf("<html><head><title>%s</title></head><body><h1>%s</h1></body></html>", title, title))
+			docs = append(docs, doc)
+			labels = append(labels, label)
+			urls = append(urls, fmt.Sprintf("https://example.com/%s/%d", label, i))
+		}
+	}
+	formResults := make([][]ClassifyResult, len(docs))
+	m := TrainPageType(docs, formResults, urls, labels, DefaultPageTypeTrainConfig())
+	m.InitRuntime()
+
+	rules := DistillPageModel(m, docs, formResults, DistillConfig{MinSupport: 2})
+	if len(rules.Rules) == 0 {
+		t.Fatal("no rules distilled")
+	}
+	for _, rule := range rules.Rules {
+		if rule.Support < 2 {
+			t.Errorf("rule %+v below the minimum support", rule)
+		}
+	}
+	if len(rules.Pipelines) > len(rules.Rules) {
+		t.Errorf("%d pipelines kept for %d rules", len(rules.Pipelines), len(rules.Rules))
+	}
+
+	// The rules must survive a JSON round-trip and agree with the model.
+	data, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded PageRules
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	for i, doc := range docs {
+		if got, want := loaded.Classify(doc, formResults[i]), m.Classify(doc, formResults[i]); got != want {
+			t.Errorf("page %d: rules say %q, model says %q", i, got, want)
+		}
+	}
+}
+
+func TestTemplateMatcher(t *testing.T) {
+	doc, _ := htmlutil.LoadHTMLString(`<form>
+		<input type="text" name="user"/>
+		<input type="hidden" name="csrf"/>
+		<input type="password" name="pass"/>
+		<input type="checkbox" name="keep"/>
+		<input type="submit" name="go" value="Go"/>
+	</form>`)
+	elems := htmlutil.GetFieldsToAnnotate(htmlutil.GetForms(doc)[0])
+
+	predict := func(keepType string, keepProba map[string]float64) ([]FieldResult, []map[string]float64) {
+		fields := []FieldResult{
+			{Name: "user", Type: "username"},
+			{Name: "pass", Type: "password"},
+			{Name: "keep", Type: keepType},
+			{Name: "go", Type: "submit button"},
+		}
+		probas := []map[string]float64{
+			{"username": 0.9},
+			{"password": 0.95},
+			keepProba,
+			{"submit button": 1},
+		}
+		return fields, probas
+	}
+
+	fields, probas := predict("TOS confirmation", map[string]float64{"TOS confirmation": 0.5, "remember me checkbox": 0.4})
+	(&TemplateMatcher{}).Apply(fields, probas, elems)
+	want := &Correction{Template: "login", Original: "TOS confirmation"}
+	if fields[2].Type != "remember me checkbox" || !reflect.DeepEqual(fields[2].Correction, want) {
+		t.Errorf("keep = %+v (correction %+v), want remember me checkbox corrected by %+v", fields[2], fields[2].Correction, want)
+	}
+	for i, f := range fields {
+		if i != 2 && f.Correction != nil {
+			t.Errorf("field %d corrected: %+v", i, f.Correction)
+		}
+	}
+
+	// A template type far less likely than the prediction is not forced.
+	fields, probas = predict("TOS confirmation", map[string]float64{"TOS confirmation": 0.9, "remember me checkbox": 0.05})
+	(&TemplateMatcher{}).Apply(fields, probas, elems)
+	if fields[2].Type != "TOS confirmation" || fields[2].Correction != nil {
+		t.Errorf("keep = %+v, want it left alone", fields[2])
+	}
+
+	// Exact matches are not flagged.
+	fields, probas = predict("remember me checkbox", map[string]float64{"remember me checkbox": 0.9})
+	(&TemplateMatcher{}).Apply(fields, probas, elems)
+	for i, f := range fields {
+		if f.Correction != nil {
+			t.Errorf("field %d corrected in an exact match: %+v", i, f.Correction)
+		}
+	}
+}
+
+func TestFitCalibration(t *testing.T) {
+	// An over-confident model: it always says 0.99 for its choice but is
+	// right only 7 times out of 10.
+	var probas []map[string]float64
+	var labels []string
+	for i := range 100 {
+		pred, other := "login", "search"
+		if i%2 == 1 {
+			pred, other = other, pred
+		}
+		probas = append(probas, map[string]float64{pred: 0.99, other: 0.01})
+		if (i/2)%10 < 7 {
+			labels = append(labels, pred)
+		} else {
+			labels = append(labels, other)
+		}
+	}
+
+	for _, method := range []string{CalibrationPlatt, CalibrationIsotonic} {
+		cal, err := FitCalibration(method, probas, labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := cal.Apply(map[string]float64{"login": 0.99, "search": 0.01})
+		if math.Abs(got["login"]-0.7) > 0.02 {
+			t.Errorf("%s: calibrated login = %v, want about 0.7", method, got["login"])
+		}
+		if sum := got["login"] + got["search"]; math.Abs(sum-1) > 1e-9 {
+			t.Errorf("%s: calibrated probabilities sum to %v", method, sum)
+		}
+	}
+
+	if _, err := FitCalibration("softmax", probas, labels); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+	var none *Calibration
+	if got := none.Apply(map[string]float64{"login": 0.99}); got["login"] != 0.99 {
+		t.Errorf("nil Calibration changed probabilities: %v", got)
+	}
+}
+
+// testIDs is a custom extractor reading the data-testid attributes of a
+// form's inputs.
+type testIDs struct{}
+
+func (testIDs) IsDict() bool                                  { return false }
+func (testIDs) ExtractDict(*htmlutil.FormView) map[string]any { return nil }
+func (testIDs) ExtractString(form *htmlutil.FormView) string {
+	var ids []string
+	form.Form.Find("[data-testid]").Each(func(_ int, s *goquery.Selection) {
+		ids = append(ids, s.AttrOr("data-testid", ""))
+	})
+	return strings.Join(ids, " ")
+}
+
+func init() {
+	RegisterExtractor("TestIDs", testIDs{})
+}
+
+func TestRegisterExtractorRoundTrip(t *testing.T) {
+	// The forms differ only in their data-testid attributes.
+	var forms []*goquery.Selection
+	var labels []string
+	for i := range 8 {
+		id, label := "login-user", "login"
+		if i%2 == 1 {
+			id, label = "search-box", "search"
+		}
+		doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf(`<form><input type="text" name="f%d" data-testid="%s"/></form>`, i, id))
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+		labels = append(labels, label)
+	}
+	config := DefaultFormTypeTrainConfig()
+	config.ExtraPipelines = []FeaturePipeline{
+		{Name: "test ids", Extractor: testIDs{}, VecType: "count", NgramRange: [2]int{1, 1}, MinDF: 1, Binary: true, Analyzer: "word"},
+	}
+	model := TrainFormType(forms, labels, config)
+	if got := model.Pipelines[len(model.Pipelines)-1].ExtractorType; got != "TestIDs" {
+		t.Fatalf("custom pipeline saved as extractor_type %q, want TestIDs", got)
+	}
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded FormTypeModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.InitRuntime()
+	for i, form := range forms {
+		if got := loaded.Classify(form); got != labels[i] {
+			t.Errorf("form %d classified as %q after round trip, want %q", i, got, labels[i])
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	RegisterExtractor("TestIDs", FormCSS{})
+}
+
+func TestFormTypeModelRetrainer(t *testing.T) {
+	var forms []*goquery.Selection
+	var labels []string
+	for i := range 8 {
+		id, label := "login-user", "login"
+		if i%2 == 1 {
+			id, label = "search-box", "search"
+		}
+		doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf(`<form><input type="text" name="f%d" data-testid="%s"/></form>`, i, id))
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+		labels = append(labels, label)
+	}
+	config := DefaultFormTypeTrainConfig()
+	config.ScalePipelines = true
+	config.Trees = &GBTConfig{Rounds: 5, MinChildWeight: 0.1}
+	config.Noise = NoiseConfig{LabelSmoothing: 0.1}
+	config.ExtraPipelines = []FeaturePipeline{
+		{Name: "test ids", Extractor: testIDs{}, VecType: "count", NgramRange: [2]int{1, 1}, MinDF: 1, Binary: true, Analyzer: "word", StopWords: map[string]bool{"box": true}},
+	}
+	model := TrainFormType(forms, labels, config)
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded FormTypeModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.InitRuntime()
+	got, err := loaded.TrainConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("TrainConfig() = %+v, want %+v", got, config)
+	}
+
+	// Retrained on the same forms, the model is the same.
+	loaded.Calibration = &Calibration{Method: CalibrationPlatt, Platt: map[string][2]float64{"login": {-2, 0}}}
+	train, err := loaded.Retrainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrained := train(forms, labels)
+	if retrained.Trees == nil || retrained.Calibration != loaded.Calibration {
+		t.Fatalf("retrained model = %+v, want a calibrated tree model", retrained)
+	}
+	for i, form := range forms {
+		if got, want := retrained.ClassifyProba(form), loaded.ClassifyProba(form); !reflect.DeepEqual(got, want) {
+			t.Errorf("form %d: retrained probabilities %v, want %v", i, got, want)
+		}
+	}
+
+	// A frozen vocabulary is recovered from the model's own pipelines.
+	frozen := DefaultFormTypeTrainConfig()
+	frozen.Vocab = model.Pipelines[:1]
+	got, err = TrainFormType(forms, labels, frozen).TrainConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Vocab) != 1 || got.Vocab[0].Name != model.Pipelines[0].Name {
+		t.Errorf("TrainConfig().Vocab = %+v, want pipeline %s", got.Vocab, model.Pipelines[0].Name)
+	}
+
+	ensemble, err := NewFormTypeEnsemble([]*FormTypeModel{model, &loaded}, []float64{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	train, err = ensemble.Retrainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members := train(forms, labels).Ensemble; len(members) != 2 || members[1].Weight != 3 || members[1].Model.Calibration != loaded.Calibration {
+		t.Errorf("retrained ensemble members = %+v, want two, weighted and calibrated as before", members)
+	}
+
+	loaded.Training = nil
+	if _, err := loaded.Retrainer(); err == nil {
+		t.Error("expected an error for a model without a recorded config")
+	}
+	if _, err := ensemble.Retrainer(); err == nil {
+		t.Error("expected an error for an ensemble member without a recorded config")
+	}
+}
+
+// pbField is a decoded protocol buffer field: v for varints, data for
+// length-delimited fields.
+type pbField struct {
+	num  int
+	v    uint64
+	data []byte
+}
+
+func decodePB(t *testing.T, b []byte) []pbField {
+	t.Helper()
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		f := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.v, n = binary.Uvarint(b)
+			b = b[n:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestFormTypeModelExplain(t *testing.T) {
+	var forms []*goquery.Selection
+	for _, html := range []string{
+		`<form><input type="text" name="user"/><input type="password" name="pass"/><input type="submit" value="Log in"/></form>`,
+		`<form><input type="search" name="q"/><button>Search</button></form>`,
+	} {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+	}
+	model := TrainFormType(forms, []string{"login", "search"}, DefaultFormTypeTrainConfig())
+
+	proba := model.ClassifyProba(forms[0])
+	explained := model.Explain(forms[0], 0)
+	if len(explained) != 2 || explained[0].Class != "login" {
+		t.Fatalf("Explain() classes = %+v, want login first", explained)
+	}
+	for _, e := range explained {
+		if math.Abs(e.Probability-proba[e.Class]) > 1e-12 {
+			t.Errorf("%s: probability %v, ClassifyProba gives %v", e.Class, e.Probability, proba[e.Class])
+		}
+		// Every contribution is listed, so they add up to the score.
+		sum := e.Intercept
+		for _, f := range append(e.Positive, e.Negative...) {
+			if f.Pipeline == "" || f.Feature == "" {
+				t.Errorf("%s: unnamed feature %+v", e.Class, f)
+			}
+			sum += f.Contribution
+		}
+		if math.Abs(sum-e.Score) > 1e-9 {
+			t.Errorf("%s: contributions add up to %v, score is %v", e.Class, sum, e.Score)
+		}
+		for i := 1; i < len(e.Positive); i++ {
+			if e.Positive[i].Contribution > e.Positive[i-1].Contribution {
+				t.Errorf("%s: positive contributions out of order", e.Class)
+			}
+		}
+	}
+
+	if top := model.Explain(forms[0], 1)[0]; len(top.Positive) != 1 || top.Positive[0] != explained[0].Positive[0] {
+		t.Errorf("Explain(topN=1) positive = %+v, want %+v", top.Positive, explained[0].Positive[:1])
+	}
+}
+
+func TestExportONNX(t *testing.T) {
+	var forms []*goquery.Selection
+	for _, html := range []string{
+		`<form><input type="text" name="user"/><input type="password" name="pass"/><input type="submit" value="Log in"/></form>`,
+		`<form><input type="search" name="q"/><button>Search</button></form>`,
+	} {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+	}
+	model := TrainFormType(forms, []string{"login", "search"}, DefaultFormTypeTrainConfig())
+	crfModel := crf.NewModel()
+	crfModel.Attributes.Add("type=password")
+	crfModel.Attributes.Add("type=search")
+	crfModel.Labels.Add("password")
+	crfModel.Labels.Add("search query")
+	crfModel.NumLabels = 2
+	crfModel.Weights = []float64{2, -1, -1, 3, 0.5, -0.5, 0.25, 1}
+	fc := &FormFieldClassifier{FormModel: model, FieldModel: &FieldTypeModel{CRF: crfModel}}
+
+	var buf strings.Builder
+	if err := fc.ExportONNX(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tensors := make(map[string][]float32)
+	var ops []string
+	meta := make(map[string]string)
+	for _, f := range decodePB(t, []byte(buf.String())) {
+		switch f.num {
+		case 7: // graph
+			for _, g := range decodePB(t, f.data) {
+				switch g.num {
+				case 1: // node
+					for _, n := range decodePB(t, g.data) {
+						if n.num == 4 {
+							ops = append(ops, string(n.data))
+						}
+					}
+				case 5: // initializer
+					var name string
+					var values []float32
+					for _, tf := range decodePB(t, g.data) {
+						switch tf.num {
+						case 8:
+							name = string(tf.data)
+						case 9:
+							for i := 0; i < len(tf.data); i += 4 {
+								values = append(values, math.Float32frombits(binary.LittleEndian.Uint32(tf.data[i:])))
+							}
+						}
+					}
+					tensors[name] = values
+				}
+			}
+		case 14: // metadata_props
+			entry := decodePB(t, f.data)
+			meta[string(entry[0].data)] = string(entry[1].data)
+		}
+	}
+
+	if want := []string{"Gemm", "Softmax", "MatMul", "Softmax", "Identity"}; !slices.Equal(ops, want) {
+		t.Errorf("ops = %v, want %v", ops, want)
+	}
+	if meta["dit.form_classes"] != `["login","search"]` || meta["dit.field_labels"] != `["password","search query"]` {
+		t.Errorf("metadata = %v", meta)
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(meta["dit.form_features"]), &names); err != nil {
+		t.Fatal(err)
+	}
+
+	// Gemm then Softmax over the exported weights reproduces ClassifyProba.
+	coef, intercept := tensors["form_coef"], tensors["form_intercept"]
+	for _, form := range forms {
+		features := model.Features(form).ToDense()
+		if len(features) != len(names) || len(coef) != 2*len(features) {
+			t.Fatalf("%d features, %d names, %d weights", len(features), len(names), len(coef))
+		}
+		logits := make([]float64, 2)
+		for c := range logits {
+			logits[c] = float64(intercept[c])
+			for i, x := range features {
+				logits[c] += float64(coef[c*len(features)+i]) * x
+			}
+		}
+		proba := model.ClassifyProba(form)
+		for c, p := range softmax(logits) {
+			if want := proba[model.Classes[c]]; math.Abs(p-want) > 1e-4 {
+				t.Errorf("ONNX P(%s) = %v, dit = %v", model.Classes[c], p, want)
+			}
+		}
+	}
+	if got := tensors["field_state_weights"]; !slices.Equal(got, []float32{2, -1, -1, 3}) {
+		t.Errorf("field_state_weights = %v", got)
+	}
+	if got := tensors["field_transition_weights"]; !slices.Equal(got, []float32{0.5, -0.5, 0.25, 1}) {
+		t.Errorf("field_transition_weights = %v", got)
+	}
+}
+
+// formasaurusDump exports m the way python/ditclient/formasaurus.py exports
+// a Formasaurus model, with classes under short names.
+func formasaurusDump(t *testing.T, m *FormTypeModel, short map[string]string) map[string]any {
+	t.Helper()
+	var classes []string
+	for _, cls := range m.Classes {
+		classes = append(classes, short[cls])
+	}
+	var pipelines []map[string]any
+	for _, p := range m.Pipelines {
+		fp := map[string]any{"extractor": p.ExtractorType}
+		var cv *vectorizer.CountVectorizer
+		switch p.VecType {
+		case "dict":
+			fp["vectorizer"] = "DictVectorizer"
+			fp["feature_names"] = p.DictVec.FeatureNames
+		case "count":
+			fp["vectorizer"] = "CountVectorizer"
+			cv = p.CountVec
+		case "tfidf":
+			fp["vectorizer"] = "TfidfVectorizer"
+			fp["idf"], fp["norm"] = p.TfidfVec.IDF, "l2"
+			cv = p.TfidfVec.CountVec
+		}
+		if cv != nil {
+			fp["vocabulary"], fp["ngram_range"], fp["binary"] = cv.Vocabulary, cv.NgramRange, cv.Binary
+			fp["analyzer"], fp["min_df"] = cv.Analyzer, cv.MinDF
+		}
+		pipelines = append(pipelines, fp)
+	}
+	return map[string]any{
+		"form_model": map[string]any{"classes": classes, "coef": m.Coef, "intercept": m.Intercept, "pipelines": pipelines},
+	}
+}
+
+func TestImportFormasaurus(t *testing.T) {
+	var forms []*goquery.Selection
+	for _, html := range []string{
+		`<form action="/login"><input type="text" name="user"/><input type="password" name="pass"/><input type="submit" value="Log in"/></form>`,
+		`<form action="/search"><input type="search" name="q"/><button>Search</button></form>`,
+		`<form action="/join"><input type="email" name="email"/><input type="password" name="pass"/><input type="password" name="pass2"/><input type="submit" value="Sign up"/></form>`,
+	} {
+		doc, _ := htmlutil.LoadHTMLString(html)
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+	}
+	model := TrainFormType(forms, []string{"login", "search", "registration"}, DefaultFormTypeTrainConfig())
+	short := map[string]string{"login": "l", "search": "s", "registration": "r"}
+	dump := formasaurusDump(t, model, short)
+	dump["form_types"] = map[string]string{"l": "login", "s": "search", "r": "registration"}
+	dump["field_types"] = map[string]string{"p": "password", "q": "search query"}
+	dump["field_model"] = map[string]any{
+		"labels": []string{"p", "q"},
+		"state_features": []map[string]any{
+			{"attr": "input-type=password", "label": "p", "weight": 2.5},
+			{"attr": "form-type=s", "label": "q", "weight": 1.5},
+		},
+		"transition_features": []map[string]any{{"from": "p", "to": "p", "weight": 0.75}},
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fc, err := ImportFormasaurus(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(fc.FormModel.Classes, model.Classes) {
+		t.Errorf("classes = %v, want %v", fc.FormModel.Classes, model.Classes)
+	}
+	for _, form := range forms {
+		got, want := fc.FormModel.ClassifyProba(form), model.ClassifyProba(form)
+		for cls, p := range want {
+			if math.Abs(got[cls]-p) > 1e-12 {
+				t.Errorf("P(%s) = %v, want %v", cls, got[cls], p)
+			}
+		}
+	}
+	crfModel := fc.FieldModel.CRF
+	if !slices.Equal(crfModel.Labels.ToStr, []string{"password", "search query"}) {
+		t.Errorf("field labels = %v", crfModel.Labels.ToStr)
+	}
+	attr := crfModel.Attributes.Get("form-type=search")
+	if attr < 0 || crfModel.Weights[crfModel.StateFeatureIndex(attr, 1)] != 1.5 {
+		t.Errorf("form-type=search weights missing, attributes %v", crfModel.Attributes.ToStr)
+	}
+	if got := crfModel.Weights[crfModel.TransOffset()]; got != 0.75 {
+		t.Errorf("password->password transition = %v, want 0.75", got)
+	}
+
+	// A binary LogisticRegression has a single coefficient row.
+	binary := formasaurusDump(t, model, short)
+	fm := binary["form_model"].(map[string]any)
+	fm["classes"], fm["coef"], fm["intercept"] = []string{"l", "s"}, model.Coef[:1], model.Intercept[:1]
+	data, _ = json.Marshal(binary)
+	if fc, err = ImportFormasaurus(data); err != nil {
+		t.Fatal(err)
+	}
+	if got := fc.FormModel.Coef; len(got) != 2 || slices.ContainsFunc(got[0], func(v float64) bool { return v != 0 }) {
+		t.Errorf("binary model coef not expanded to two rows")
+	}
+
+	fm["pipelines"] = append(fm["pipelines"].([]map[string]any), map[string]any{"extractor": "FormColor", "vectorizer": "DictVectorizer"})
+	data, _ = json.Marshal(binary)
+	if _, err := ImportFormasaurus(data); err == nil || !strings.Contains(err.Error(), "FormColor") {
+		t.Errorf("unknown extractor error = %v", err)
+	}
+}
+
+func TestHeuristicClassify(t *testing.T) {
+	tests := []struct {
+		name, html string
+		form       string
+		fields     map[string]string
+	}{
+		{"login", `<form><input name="login"><input type="password" name="pass"><input type="checkbox" name="remember_me"><input type="submit" name="go"></form>`,
+			"login", map[string]string{"login": "username", "pass": "password", "remember_me": "remember me checkbox", "go": "submit button"}},
+		{"registration", `<form><input type="email" name="email"><input type="password" name="pw"><input type="password" name="pw2"></form>`,
+			"registration", map[string]string{"email": "email", "pw": "password", "pw2": "password confirmation"}},
+		{"search", `<form><input type="search" name="q"></form>`, "search", map[string]string{"q": "search query"}},
+		{"recovery", `<form><p>Forgot your password?</p><input type="email" name="email"></form>`,
+			"password/login recovery", map[string]string{"email": "email"}},
+		{"contact", `<form><input name="full_name"><input name="email"><textarea name="msg"></textarea></form>`,
+			"contact/comment", map[string]string{"full_name": "full name", "email": "email", "msg": "comment text"}},
+		{"mfa", `<form><input name="code" autocomplete="one-time-code" inputmode="numeric" maxlength="6"></form>`,
+			"mfa", map[string]string{"code": "otp code"}},
+	}
+	for _, tt := range tests {
+		doc, _ := htmlutil.LoadHTMLString(tt.html)
+		got := HeuristicClassify(doc.Find("form"))
+		if got.Form != tt.form || !reflect.DeepEqual(got.Fields, tt.fields) {
+			t.Errorf("%s: HeuristicClassify = %s %v, want %s %v", tt.name, got.Form, got.Fields, tt.form, tt.fields)
+		}
+	}
+}
+
+func TestHeuristicPageType(t *testing.T) {
+	tests := []struct {
+		name, html, url string
+		want            string
+	}{
+		{"url", `<p>Welcome</p>`, "https://example.com/signup", "registration"},
+		{"search query", `<p>Results</p>`, "https://example.com/?q=shoes", "search"},
+		{"form", `<form><input name="user"><input type="password" name="pw"></form>`, "https://example.com/members", "login"},
+		{"mfa url", `<p>Enter your code</p>`, "https://example.com/login/2fa", "mfa"},
+		{"mfa form", `<form><input name="otp"></form>`, "https://example.com/members", "mfa"},
+		{"not found", `<title>Page not found</title>`, "https://example.com/x", "soft_404"},
+		{"directory", `<title>Index of /files</title>`, "", "directory_listing"},
+		{"landing", `<h1>Hello</h1>`, "https://example.com/", "landing"},
+		{"other", `<h1>Hello</h1>`, "", "other"},
+	}
+	for _, tt := range tests {
+		doc, _ := htmlutil.LoadHTMLString(tt.html)
+		var forms []ClassifyResult
+		doc.Find("form").Each(func(_ int, s *goquery.Selection) { forms = append(forms, HeuristicClassify(s)) })
+		if got := HeuristicPageType(doc, forms, tt.url); got != tt.want {
+			t.Errorf("%s: HeuristicPageType = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrainFormTypeGBT(t *testing.T) {
+	var forms []*goquery.Selection
+	var labels []string
+	for i := range 4 {
+		for _, tc := range []struct{ html, label string }{
+			{`<form action="/login"><input type="text" name="user%d"/><input type="password" name="pass"/><input type="submit" value="Log in"/></form>`, "login"},
+			{`<form action="/search"><input type="search" name="q%d"/><button>Search</button></form>`, "search"},
+			{`<form action="/join"><input type="email" name="email%d"/><input type="password" name="pass"/><input type="password" name="pass2"/><input type="submit" value="Sign up"/></form>`, "registration"},
+		} {
+			doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf(tc.html, i))
+			forms = append(forms, htmlutil.GetForms(doc)[0])
+			labels = append(labels, tc.label)
+		}
+	}
+	config := DefaultFormTypeTrainConfig()
+	config.Trees = &GBTConfig{Rounds: 20, MinChildWeight: 0.1}
+	model := TrainFormTypeGBT(forms, labels, config)
+	if model.Trees == nil || len(model.Trees.Trees) != 20 {
+		t.Fatalf("want 20 boosting rounds, got %+v", model.Trees)
+	}
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded FormTypeModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.InitRuntime()
+	for i, form := range forms {
+		if got := loaded.Classify(form); got != labels[i] {
+			t.Errorf("form %d classified as %q, want %q", i, got, labels[i])
+		}
+	}
+
+	// The contributions of a tree model add up to its scores, and the
+	// explanation agrees with ClassifyProba.
+	proba := loaded.ClassifyProba(forms[0])
+	for _, e := range loaded.Explain(forms[0], 0) {
+		sum := e.Intercept
+		for _, fc := range append(e.Positive, e.Negative...) {
+			sum += fc.Contribution
+		}
+		if math.Abs(sum-e.Score) > 1e-9 {
+			t.Errorf("%s: contributions sum to %v, score is %v", e.Class, sum, e.Score)
+		}
+		if math.Abs(e.Probability-proba[e.Class]) > 1e-9 {
+			t.Errorf("%s: explained probability %v, ClassifyProba %v", e.Class, e.Probability, proba[e.Class])
+		}
+	}
+
+	fc := &FormFieldClassifier{FormModel: &loaded}
+	if err := fc.ExportONNX(io.Discard); err == nil {
+		t.Error("ExportONNX of a tree model succeeded, want an error")
+	}
+	fc.Quantize(3)
+	if got := loaded.Classify(forms[0]); got != "login" {
+		t.Errorf("quantized model classified login form as %q", got)
+	}
+}
+
+func TestFormTypeEnsemble(t *testing.T) {
+	var forms []*goquery.Selection
+	var labels []string
+	for i := range 4 {
+		for _, tc := range []struct{ html, label string }{
+			{`<form action="/login"><input type="text" name="user%d"/><input type="password" name="pass"/><input type="submit" value="Log in"/></form>`, "login"},
+			{`<form action="/search"><input type="search" name="q%d"/><button>Search</button></form>`, "search"},
+			{`<form action="/join"><input type="email" name="email%d"/><input type="password" name="pass"/><input type="password" name="pass2"/><input type="submit" value="Sign up"/></form>`, "registration"},
+		} {
+			doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf(tc.html, i))
+			forms = append(forms, htmlutil.GetForms(doc)[0])
+			labels = append(labels, tc.label)
+		}
+	}
+	config := DefaultFormTypeTrainConfig()
+	all := TrainFormType(forms, labels, config)
+	// The second member knows no registration forms.
+	var twoForms []*goquery.Selection
+	var twoLabels []string
+	for i, label := range labels {
+		if label != "registration" {
+			twoForms, twoLabels = append(twoForms, forms[i]), append(twoLabels, label)
+		}
+	}
+	two := TrainFormType(twoForms, twoLabels, config)
+
+	for _, tc := range []struct {
+		name    string
+		models  []*FormTypeModel
+		weights []float64
+	}{
+		{"no members", nil, nil},
+		{"weight count", []*FormTypeModel{all, two}, []float64{1}},
+		{"negative weight", []*FormTypeModel{all, two}, []float64{1, -1}},
+		{"zero weights", []*FormTypeModel{all, two}, []float64{0, 0}},
+		{"nil member", []*FormTypeModel{all, nil}, nil},
+	} {
+		if _, err := NewFormTypeEnsemble(tc.models, tc.weights); err == nil {
+			t.Errorf("%s: NewFormTypeEnsemble succeeded, want an error", tc.name)
+		}
+	}
+
+	ensemble, err := NewFormTypeEnsemble([]*FormTypeModel{all, two}, []float64{3, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"login", "registration", "search"}; !slices.Equal(ensemble.Classes, want) {
+		t.Errorf("ensemble classes = %v, want %v", ensemble.Classes, want)
+	}
+
+	// The ensemble survives a save and load as part of the form model.
+	data, err := json.Marshal(ensemble)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded FormTypeModel
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded.InitRuntime()
+	for i, form := range forms {
+		pAll, pTwo := all.ClassifyProba(form), two.ClassifyProba(form)
+		proba := loaded.ClassifyProba(form)
+		for _, cls := range ensemble.Classes {
+			if want := 0.75*pAll[cls] + 0.25*pTwo[cls]; math.Abs(proba[cls]-want) > 1e-9 {
+				t.Errorf("form %d: P(%s) = %v, want %v", i, cls, proba[cls], want)
+			}
+		}
+		if got := loaded.Classify(form); got != labels[i] {
+			t.Errorf("form %d classified as %q, want %q", i, got, labels[i])
+		}
+	}
+
+	explained := loaded.Explain(forms[2], 5)
+	if len(explained) != 3 || explained[0].Class != "registration" || len(explained[0].Positive) != 0 {
+		t.Errorf("ensemble explanation = %+v, want classes with probabilities only", explained)
+	}
+
+	fc := &FormFieldClassifier{FormModel: &loaded}
+	if err := fc.ExportONNX(io.Discard); err == nil {
+		t.Error("ExportONNX of an ensemble succeeded, want an error")
+	}
+	fc.Quantize(3)
+	if got := loaded.Ensemble[0].Model.Coef[0][0]; got != math.Round(got*1000)/1000 {
+		t.Errorf("member weight %v not quantized", got)
+	}
+}
+
+func TestTrimWeights(t *testing.T) {
+	got := trimWeights([]float64{0.1, 2, 0.5, 2, 0.2}, []float64{1, 2, 3, 4, 5}, 0.4)
+	if want := []float64{1, 0, 3, 0, 5}; !slices.Equal(got, want) {
+		t.Errorf("trimWeights = %v, want %v", got, want)
+	}
+	if got := trimWeights([]float64{3, 1}, nil, 0.4); !slices.Equal(got, []float64{1, 1}) {
+		t.Errorf("trimWeights below one example = %v, want no trimming", got)
+	}
+}
+
+func TestNoiseRobustTraining(t *testing.T) {
+	// Twelve login and search forms, one login form labeled search.
+	var forms []*goquery.Selection
+	var labels []string
+	for i := range 12 {
+		html, label := `<form><input type="text" name="user%d"/><input type="password" name="pass"/></form>`, "login"
+		if i%2 == 1 {
+			html, label = `<form><input type="search" name="q%d"/></form>`, "search"
+		}
+		if i == 4 {
+			label = "search"
+		}
+		doc, _ := htmlutil.LoadHTMLString(fmt.Sprintf(html, i))
+		forms = append(forms, htmlutil.GetForms(doc)[0])
+		labels = append(labels, label)
+	}
+
+	for _, trees := range []*GBTConfig{nil, {Rounds: 30, MinChildWeight: 0.1}} {
+		config := DefaultFormTypeTrainConfig()
+		config.Trees = trees
+		plain := TrainFormType(forms, labels, config)
+		config.Noise = NoiseConfig{LabelSmoothing: 0.2}
+		smoothed := TrainFormType(forms, labels, config)
+		if p, q := smoothed.ClassifyProba(forms[0])["login"], plain.ClassifyProba(forms[0])["login"]; p >= q {
+			t.Errorf("trees %v: smoothed P(login) = %v, want below unsmoothed %v", trees != nil, p, q)
+		}
+
+		// Once trimmed, the mislabeled form no longer pulls its
+		// features towards search.
+		config.Noise = NoiseConfig{TrimFraction: 0.1}
+		trimmed := TrainFormType(forms, labels, config)
+		if p, q := trimmed.ClassifyProba(forms[4])["search"], plain.ClassifyProba(forms[4])["search"]; p >= q {
+			t.Errorf("trees %v: trimmed P(search) of the mislabeled form = %v, want below untrimmed %v", trees != nil, p, q)
+		}
+		for i, form := range forms {
+			if i != 4 && trimmed.Classify(form) != labels[i] {
+				t.Errorf("trees %v: form %d misclassified with trimming", trees != nil, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package classifier
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/vectorizer"
+)
+
+// PageRule predicts Class for pages on which Feature of Pipeline is present.
+type PageRule struct {
+	Pipeline string `json:"pipeline"`
+	Feature  string `json:"feature"`
+	Class    string `json:"class"`
+	// Support is the number of distillation pages the rule decided, and
+	// Precision the share of them on which it agrees with the page model.
+	Support   int     `json:"support"`
+	Precision float64 `json:"precision"`
+}
+
+// PageRules is a decision list distilled from a PageTypeModel: the first
+// rule whose feature is present on a page decides its type, and pages no
+// rule matches get Default. Pipelines hold vectorizers reduced to the
+// features the rules use, so the rule set ships without the full model.
+type PageRules struct {
+	Rules     []PageRule           `json:"rules"`
+	Default   string               `json:"default"`
+	Pipelines []SerializedPipeline `json:"pipelines"`
+}
+
+// DistillConfig controls DistillPageModel.
+type DistillConfig struct {
+	MaxRules   int // maximum number of rules; 0 means 20
+	MinSupport int // pages a feature must cover to become a rule; 0 means 3
+}
+
+// pageFeature names one column of a PageTypeModel's feature vector.
+type pageFeature struct {
+	pipeline int
+	name     string
+}
+
+// DistillPageModel learns a decision list that mimics the model's
+// predictions on the given pages. Rules are picked greedily (sequential
+// covering): each round takes the feature whose covered pages, among
+// those not yet decided, most consistently share one predicted type.
+func DistillPageModel(m *PageTypeModel, docs []*goquery.Document, formResults [][]ClassifyResult, config DistillConfig) *PageRules {
+	maxRules := cmp.Or(config.MaxRules, 20)
+	minSupport := cmp.Or(config.MinSupport, 3)
+	columns := m.featureColumns()
+
+	present := make([][]int, len(docs))
+	teacher := make([]int, len(docs))
+	classIndex := make(map[string]int, len(m.Classes))
+	for c, cls := range m.Classes {
+		classIndex[cls] = c
+	}
+	for i, doc := range docs {
+		features := m.extractFeatures(doc, formResults[i], "")
+		for k, idx := range features.Indices {
+			if features.Values[k] != 0 {
+				present[i] = append(present[i], idx)
+			}
+		}
+		teacher[i] = classIndex[m.Classify(doc, formResults[i])]
+	}
+
+	numClasses := len(m.Classes)
+	remaining := make([]bool, len(docs))
+	for i := range remaining {
+		remaining[i] = true
+	}
+	rules := &PageRules{}
+	var used []pageFeature
+	for len(rules.Rules) < maxRules {
+		counts := make(map[int][]int)
+		classTotals := make([]int, numClasses)
+		left := 0
+		for i, ok := range remaining {
+			if !ok {
+				continue
+			}
+			left++
+			classTotals[teacher[i]]++
+			for _, f := range present[i] {
+				if counts[f] == nil {
+					counts[f] = make([]int, numClasses)
+				}
+				counts[f][teacher[i]]++
+			}
+		}
+		if left == 0 {
+			break
+		}
+
+		// A rule must beat labelling every remaining page with the
+		// majority type; scores are Laplace-smoothed precisions.
+		bestScore := float64(slices.Max(classTotals)+1) / float64(left+numClasses)
+		bestFeature, bestClass, bestSupport, bestHits := -1, 0, 0, 0
+		for _, f := range slices.Sorted(maps.Keys(counts)) {
+			byClass := counts[f]
+			support := sum(byClass)
+			if support < minSupport {
+				continue
+			}
+			c := argmax(byClass)
+			score := float64(byClass[c]+1) / float64(support+numClasses)
+			if score > bestScore {
+				bestScore, bestFeature, bestClass, bestSupport, bestHits = score, f, c, support, byClass[c]
+			}
+		}
+		if bestFeature < 0 {
+			break
+		}
+
+		col := columns[bestFeature]
+		used = append(used, col)
+		rules.Rules = append(rules.Rules, PageRule{
+			Pipeline:  m.Pipelines[col.pipeline].Name,
+			Feature:   col.name,
+			Class:     m.Classes[bestClass],
+			Support:   bestSupport,
+			Precision: float64(bestHits) / float64(bestSupport),
+		})
+		for i, ok := range remaining {
+			if ok && slices.Contains(present[i], bestFeature) {
+				remaining[i] = false
+			}
+		}
+	}
+
+	defaultTotals := make([]int, numClasses)
+	for i, ok := range remaining {
+		if ok {
+			defaultTotals[teacher[i]]++
+		}
+	}
+	if sum(defaultTotals) == 0 {
+		for _, c := range teacher {
+			defaultTotals[c]++
+		}
+	}
+	if numClasses > 0 {
+		rules.Default = m.Classes[argmax(defaultTotals)]
+	}
+	rules.Pipelines = m.reducedPipelines(used)
+	return rules
+}
+
+// Classify returns the page type decided by the first matching rule.
+func (r *PageRules) Classify(doc *goquery.Document, formResults []ClassifyResult) string {
+	extractors := make(map[string]PageFeatureExtractor)
+	for _, pipe := range DefaultPageFeaturePipelines() {
+		extractors[pipe.Name] = pipe.Extractor
+	}
+
+	present := make(map[pageRuleKey]bool)
+	for _, sp := range r.Pipelines {
+		extractor, ok := extractors[sp.Name]
+		if !ok {
+			continue
+		}
+		var sv vectorizer.SparseVector
+		var names []string
+		switch sp.VecType {
+		case "dict":
+			sv = sp.DictVec.Transform(extractor.ExtractDict(doc, formResults))
+			names = sp.DictVec.FeatureNames
+		case "tfidf":
+			sv = sp.TfidfVec.Transform(extractor.ExtractString(doc, formResults))
+			names = sp.TfidfVec.CountVec.Terms()
+		}
+		for k, idx := range sv.Indices {
+			if sv.Values[k] != 0 {
+				present[pageRuleKey{sp.Name, names[idx]}] = true
+			}
+		}
+	}
+
+	for _, rule := range r.Rules {
+		if present[pageRuleKey{rule.Pipeline, rule.Feature}] {
+			return rule.Class
+		}
+	}
+	return r.Default
+}
+
+type pageRuleKey struct{ pipeline, feature string }
+
+// featureColumns names every column of the model's concatenated feature
+// vector, in the order extractFeatures produces them.
+func (m *PageTypeModel) featureColumns() []pageFeature {
+	var columns []pageFeature
+	for i, sp := range m.Pipelines {
+		var names []string
+		switch sp.VecType {
+		case "dict":
+			names = sp.DictVec.FeatureNames
+		case "tfidf":
+			names = sp.TfidfVec.CountVec.Terms()
+		}
+		for _, name := range names {
+			columns = append(columns, pageFeature{i, name})
+		}
+	}
+	return columns
+}
+
+// reducedPipelines returns the model's pipelines that features use, with
+// vectorizers restricted to those features.
+func (m *PageTypeModel) reducedPipelines(features []pageFeature) []SerializedPipeline {
+	byPipeline := make(map[int][]string)
+	for _, f := range features {
+		byPipeline[f.pipeline] = append(byPipeline[f.pipeline], f.name)
+	}
+	var out []SerializedPipeline
+	for i, sp := range m.Pipelines {
+		names, ok := byPipeline[i]
+		if !ok {
+			continue
+		}
+		reduced := SerializedPipeline{Name: sp.Name, ExtractorType: sp.ExtractorType, VecType: sp.VecType}
+		switch sp.VecType {
+		case "dict":
+			reduced.DictVec = sp.DictVec.Subset(names)
+		case "tfidf":
+			reduced.TfidfVec = sp.TfidfVec.Subset(names)
+		}
+		out = append(out, reduced)
+	}
+	return out
+}
+
+func sum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func argmax(xs []int) int {
+	best := 0
+	for i, x := range xs {
+		if x > xs[best] {
+			best = i
+		}
+	}
+	return best
+}
@@ -0,0 +1,95 @@
+package classifier
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// EnsembleMember is one form type model of an ensemble and the weight of
+// its vote.
+type EnsembleMember struct {
+	Weight float64        `json:"weight"`
+	Model  *FormTypeModel `json:"model"`
+}
+
+// NewFormTypeEnsemble returns a form type model whose probabilities are
+// the weighted average of those of models, each calibrated by its own
+// Calibration; a class a model does not know counts as 0 for it. Members
+// may differ in learner, pipelines, and classes, so runs trained on
+// different data or with different settings can be combined. weights
+// holds one non-negative weight per model; nil weighs them equally.
+//
+// The ensemble has no thresholds or calibration of its own; tune and fit
+// them on it like on any model. Members' thresholds are not used.
+func NewFormTypeEnsemble(models []*FormTypeModel, weights []float64) (*FormTypeModel, error) {
+	if len(models) == 0 {
+		return nil, errors.New("classifier: ensemble needs at least one form model")
+	}
+	if weights != nil && len(weights) != len(models) {
+		return nil, fmt.Errorf("classifier: %d weights for %d ensemble members", len(weights), len(models))
+	}
+	m := &FormTypeModel{}
+	total := 0.0
+	for i, member := range models {
+		if member == nil {
+			return nil, fmt.Errorf("classifier: ensemble member %d has no form model", i)
+		}
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("classifier: negative weight %g for ensemble member %d", w, i)
+		}
+		total += w
+		m.Ensemble = append(m.Ensemble, EnsembleMember{Weight: w, Model: member})
+		m.Classes = append(m.Classes, member.Classes...)
+	}
+	if total == 0 {
+		return nil, errors.New("classifier: ensemble weights sum to 0")
+	}
+	slices.Sort(m.Classes)
+	m.Classes = slices.Compact(m.Classes)
+	return m, nil
+}
+
+// ensembleProba averages the member probabilities of form by weight.
+func (m *FormTypeModel) ensembleProba(form *goquery.Selection) map[string]float64 {
+	total := 0.0
+	for _, member := range m.Ensemble {
+		total += member.Weight
+	}
+	result := make(map[string]float64, len(m.Classes))
+	for _, cls := range m.Classes {
+		result[cls] = 0
+	}
+	for _, member := range m.Ensemble {
+		if member.Weight == 0 {
+			continue
+		}
+		for cls, p := range member.Model.ClassifyProba(form) {
+			result[cls] += member.Weight / total * p
+		}
+	}
+	return result
+}
+
+// explainEnsemble lists the classes of an ensemble with their
+// probabilities only: averaged probabilities do not split into per-feature
+// shares of a score, so Score, Intercept, and the contributions are left
+// empty. Explain the members for their features.
+func (m *FormTypeModel) explainEnsemble(form *goquery.Selection) []ClassExplanation {
+	proba := m.ClassifyProba(form)
+	out := make([]ClassExplanation, 0, len(proba))
+	for cls, p := range proba {
+		out = append(out, ClassExplanation{Class: cls, Probability: p})
+	}
+	slices.SortFunc(out, func(a, b ClassExplanation) int {
+		return cmp.Or(cmp.Compare(b.Probability, a.Probability), cmp.Compare(a.Class, b.Class))
+	})
+	return out
+}
@@ -0,0 +1,301 @@
+package classifier
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/vectorizer"
+)
+
+// FeatureContribution is one feature's share of a class score: its value
+// in the form's or page's feature vector times the class weight.
+type FeatureContribution struct {
+	Pipeline     string  `json:"pipeline"` // e.g. "form css"
+	Feature      string  `json:"feature"`  // dict key or vocabulary term
+	Value        float64 `json:"value"`
+	Contribution float64 `json:"contribution"`
+	Reason       string  `json:"reason,omitempty"` // in words; set for page features
+}
+
+// ClassExplanation breaks down the score of one form or page type. The score is
+// Intercept plus the contributions of every feature present in the form;
+// Positive and Negative list the largest of them.
+type ClassExplanation struct {
+	Class       string                `json:"class"`
+	Probability float64               `json:"probability"`
+	Score       float64               `json:"score"` // logit before softmax
+	Intercept   float64               `json:"intercept"`
+	Positive    []FeatureContribution `json:"positive,omitempty"` // largest first
+	Negative    []FeatureContribution `json:"negative,omitempty"` // most negative first
+}
+
+// FormExplanation holds the explanation of one form of a page.
+type FormExplanation struct {
+	Meta    FormMeta           `json:"meta"`
+	Form    string             `json:"form"`              // predicted form type
+	Classes []ClassExplanation `json:"classes,omitempty"` // most probable first
+	Error   string             `json:"error,omitempty"`   // see FormResult.Error
+}
+
+// Explain returns, for every class, the topN features that raise and
+// lower its score for form, with classes ordered from most to least
+// probable as ClassifyProba gives them. A topN of 0 or less lists every
+// feature present in the form. An ensemble (see NewFormTypeEnsemble) lists
+// only the class probabilities.
+func (m *FormTypeModel) Explain(form *goquery.Selection, topN int) []ClassExplanation {
+	if len(m.Ensemble) > 0 {
+		return m.explainEnsemble(form)
+	}
+	features := m.Features(form)
+	if m.Trees != nil {
+		return m.explainTrees(features, topN)
+	}
+	names := pipelineFeatureNames(m.Pipelines, features.Indices)
+	return explainLinear(m.Classes, m.Coef, m.Intercept, m.Calibration, features, names, topN)
+}
+
+// explainTrees attributes the logits of a tree model to features the way
+// Saabas does: along the path a form takes through each tree, the change
+// from a node's value to its child's goes to the feature the node splits
+// on, and the root values add up to Intercept. Features whose absence
+// moved a score are listed with Value 0.
+func (m *FormTypeModel) explainTrees(features vectorizer.SparseVector, topN int) []ClassExplanation {
+	has := presence(features)
+	intercept := slices.Clone(m.Trees.Base)
+	contribs := make([]map[int]float64, len(m.Classes))
+	for c := range contribs {
+		contribs[c] = make(map[int]float64)
+	}
+	for _, round := range m.Trees.Trees {
+		for c, tree := range round {
+			path := tree.path(has)
+			intercept[c] += tree.Nodes[0].Value
+			for k, i := range path[:len(path)-1] {
+				node := tree.Nodes[i]
+				contribs[c][node.Feature] += tree.Nodes[path[k+1]].Value - node.Value
+			}
+		}
+	}
+
+	values := make(map[int]float64, len(features.Indices))
+	for k, idx := range features.Indices {
+		values[idx] = features.Values[k]
+	}
+	var indices []int
+	for _, contrib := range contribs {
+		for idx := range contrib {
+			indices = append(indices, idx)
+		}
+	}
+	slices.Sort(indices)
+	names := pipelineFeatureNames(m.Pipelines, slices.Compact(indices))
+	return explainContributions(m.Classes, intercept, contribs, values, m.Calibration, names, topN)
+}
+
+// Explain is FormTypeModel.Explain for the page type of doc, fetched from
+// pageURL, whose forms were classified as formResults. Every contribution
+// carries a Reason (see DescribeFeature).
+func (m *PageTypeModel) Explain(doc *goquery.Document, formResults []ClassifyResult, pageURL string, topN int) []ClassExplanation {
+	features := m.extractFeatures(doc, formResults, pageURL)
+	names := pipelineFeatureNames(m.Pipelines, features.Indices)
+	for k, idx := range features.Indices {
+		fc := names[idx]
+		fc.Reason = DescribeFeature(fc.Pipeline, fc.Feature, features.Values[k])
+		names[idx] = fc
+	}
+	return explainLinear(m.Classes, m.Coef, m.Intercept, m.Calibration, features, names, topN)
+}
+
+// explainLinear splits the logits of a linear model into per-feature
+// contributions, keeping the topN for and against each class.
+func explainLinear(classes []string, coef [][]float64, intercept []float64, calibration *Calibration, features vectorizer.SparseVector, names map[int]FeatureContribution, topN int) []ClassExplanation {
+	contribs := make([]map[int]float64, len(classes))
+	values := make(map[int]float64, len(features.Indices))
+	for k, idx := range features.Indices {
+		values[idx] = features.Values[k]
+	}
+	for c := range classes {
+		contribs[c] = make(map[int]float64, len(features.Indices))
+		for k, idx := range features.Indices {
+			contribs[c][idx] = features.Values[k] * coef[c][idx]
+		}
+	}
+	return explainContributions(classes, intercept, contribs, values, calibration, names, topN)
+}
+
+// explainContributions builds the explanation of every class from its
+// intercept and per-feature contributions, whose sum is the class logit;
+// values holds the feature values, and names the feature of every index
+// in contribs.
+func explainContributions(classes []string, intercept []float64, contribs []map[int]float64, values map[int]float64, calibration *Calibration, names map[int]FeatureContribution, topN int) []ClassExplanation {
+	logits := make([]float64, len(classes))
+	for c := range classes {
+		logits[c] = intercept[c]
+		for _, idx := range slices.Sorted(maps.Keys(contribs[c])) {
+			logits[c] += contribs[c][idx]
+		}
+	}
+	softmaxProba := softmax(logits)
+	proba := make(map[string]float64, len(classes))
+	for c, cls := range classes {
+		proba[cls] = softmaxProba[c]
+	}
+	proba = calibration.Apply(proba)
+
+	out := make([]ClassExplanation, len(classes))
+	for c, cls := range classes {
+		e := ClassExplanation{Class: cls, Probability: proba[cls], Score: logits[c], Intercept: intercept[c]}
+		for idx, contrib := range contribs[c] {
+			if contrib == 0 {
+				continue
+			}
+			fc := names[idx]
+			fc.Value, fc.Contribution = values[idx], contrib
+			if contrib > 0 {
+				e.Positive = append(e.Positive, fc)
+			} else {
+				e.Negative = append(e.Negative, fc)
+			}
+		}
+		byWeight := func(a, b FeatureContribution) int {
+			return cmp.Or(cmp.Compare(math.Abs(b.Contribution), math.Abs(a.Contribution)),
+				cmp.Compare(a.Pipeline, b.Pipeline), cmp.Compare(a.Feature, b.Feature))
+		}
+		slices.SortFunc(e.Positive, byWeight)
+		slices.SortFunc(e.Negative, byWeight)
+		if topN > 0 {
+			e.Positive = e.Positive[:min(topN, len(e.Positive))]
+			e.Negative = e.Negative[:min(topN, len(e.Negative))]
+		}
+		out[c] = e
+	}
+	slices.SortFunc(out, func(a, b ClassExplanation) int {
+		return cmp.Or(cmp.Compare(b.Probability, a.Probability), cmp.Compare(a.Class, b.Class))
+	})
+	return out
+}
+
+// pipelineFeatureNames names the given feature columns by pipeline and
+// feature, inverting only the vocabularies of pipelines that hold one of
+// them.
+func pipelineFeatureNames(pipelines []SerializedPipeline, indices []int) map[int]FeatureContribution {
+	names := make(map[int]FeatureContribution, len(indices))
+	offset := 0
+	for _, p := range pipelines {
+		dim := pipelineDim(p)
+		var wanted []int
+		for _, idx := range indices {
+			if idx >= offset && idx < offset+dim {
+				wanted = append(wanted, idx)
+			}
+		}
+		if len(wanted) > 0 {
+			column := make(map[int]string, len(wanted))
+			switch p.VecType {
+			case "dict":
+				for _, idx := range wanted {
+					column[idx-offset] = p.DictVec.FeatureNames[idx-offset]
+				}
+			case "count", "tfidf":
+				vocab := p.CountVec
+				if p.VecType == "tfidf" {
+					vocab = p.TfidfVec.CountVec
+				}
+				for _, idx := range wanted {
+					column[idx-offset] = ""
+				}
+				for term, i := range vocab.Vocabulary {
+					if _, ok := column[i]; ok {
+						column[i] = term
+					}
+				}
+			}
+			for _, idx := range wanted {
+				names[idx] = FeatureContribution{Pipeline: p.Name, Feature: column[idx-offset]}
+			}
+		}
+		offset += dim
+	}
+	return names
+}
+
+// ExplainForms explains the form type of every form in doc, listing the
+// topN features for and against each class (see FormTypeModel.Explain).
+func (c *FormFieldClassifier) ExplainForms(doc *goquery.Document, topN int) []FormExplanation {
+	c = c.route(doc.Selection)
+	forms := htmlutil.GetForms(doc)
+	results := make([]FormExplanation, len(forms))
+	for i, form := range forms {
+		results[i].Meta = NewFormMeta(i, form)
+		var classes []ClassExplanation
+		if err := isolate(c.FormTimeout, func() { classes = c.FormModel.Explain(form, topN) }); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Classes = classes
+		proba := make(map[string]float64, len(classes))
+		for _, e := range classes {
+			proba[e.Class] = e.Probability
+		}
+		results[i].Form = c.FormModel.Predict(proba)
+	}
+	return results
+}
+
+// tfidfSources says what each text page pipeline reads, for
+// DescribeFeature.
+var tfidfSources = map[string]string{
+	"page title":     "title contains",
+	"page meta desc": "meta description contains",
+	"page headings":  "headings contain",
+	"page h1":        "h1 contains",
+	"page css":       "body class or id contains",
+	"page nav text":  "navigation contains",
+}
+
+// DescribeFeature puts a page feature with the given value in words, such
+// as "title contains 'page not found'", "body text 500 B-2 KB", or
+// "URL path matched 'login'", so analysts can check a page type without
+// reading weights.
+func DescribeFeature(pipeline, feature string, value float64) string {
+	switch pipeline {
+	case "page structure":
+		return htmlutil.DescribePageFeature(feature, value)
+	case "form type summary":
+		if dominant, ok := strings.CutPrefix(feature, "dominant_type="); ok {
+			return "most forms are " + dominant
+		}
+		if feature == "form_count" {
+			return fmt.Sprintf("%g forms", value)
+		}
+		return htmlutil.DescribePageFeature(feature, value)
+	case "page url":
+		return fmt.Sprintf("URL path matched '%s'", strings.TrimSpace(feature))
+	}
+	if source, ok := tfidfSources[pipeline]; ok {
+		return fmt.Sprintf("%s '%s'", source, strings.TrimSpace(feature))
+	}
+	return fmt.Sprintf("%s contains '%s'", pipeline, strings.TrimSpace(feature))
+}
+
+// ExplainPage explains the page type of doc, fetched from pageURL, listing
+// the topN features for and against each class (see PageTypeModel.Explain).
+// Forms are classified for the page model's form type summary as in
+// ExtractPageDoc, skipping any that time out or panic.
+func (c *FormFieldClassifier) ExplainPage(doc *goquery.Document, pageURL string, topN int) []ClassExplanation {
+	c = c.route(doc.Selection)
+	var formResults []ClassifyResult
+	for _, form := range htmlutil.GetForms(doc) {
+		var r ClassifyResult
+		if err := isolate(c.FormTimeout, func() { r = c.Classify(form, false) }); err == nil {
+			formResults = append(formResults, r)
+		}
+	}
+	return c.PageModel.Explain(doc, formResults, pageURL, topN)
+}
@@ -1,67 +1,152 @@
 package classifier
 
 import (
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/PuerkitoBio/goquery"
 	"github.com/happyhackingspace/dit/crf"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
 // FieldTypeModel wraps a CRF model for field type classification.
 type FieldTypeModel struct {
 	CRF *crf.Model
+	// OptionLimits are the select option limits the CRF was trained with;
+	// see SelectOptionLimits.
+	OptionLimits OptionLimits
+}
+
+// FieldResult holds the predicted type of a single field.
+type FieldResult struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Element    *Element    `json:"element,omitempty"`    // set by FormFieldClassifier
+	Locator    *Locator    `json:"locator,omitempty"`    // set with FormFieldClassifier.Locators
+	Correction *Correction `json:"correction,omitempty"` // set when a TemplateMatcher changed Type
+}
+
+// FieldProbaResult holds type probabilities for a single field.
+type FieldProbaResult struct {
+	Name    string             `json:"name"`
+	Proba   map[string]float64 `json:"proba"`
+	Element *Element           `json:"element,omitempty"`
+	Locator *Locator           `json:"locator,omitempty"`
+}
+
+// Element holds the markup of a classified field that autofill and form
+// filling code needs besides its type.
+type Element struct {
+	Tag          string `json:"tag"`            // "input", "select", "textarea", or "button"
+	Type         string `json:"type,omitempty"` // type attribute, lowercased; "text" for an input without one
+	Placeholder  string `json:"placeholder,omitempty"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+	Required     bool   `json:"required,omitempty"`
+	MaxLength    int    `json:"maxlength,omitempty"` // 0 if unset or invalid
+}
+
+// NewElement describes the field element sel.
+func NewElement(sel *goquery.Selection) *Element {
+	e := &Element{
+		Tag:          goquery.NodeName(sel),
+		Type:         strings.ToLower(strings.TrimSpace(sel.AttrOr("type", ""))),
+		Placeholder:  strings.TrimSpace(sel.AttrOr("placeholder", "")),
+		Autocomplete: strings.TrimSpace(sel.AttrOr("autocomplete", "")),
+	}
+	if e.Tag == "input" && e.Type == "" {
+		e.Type = "text"
+	}
+	_, e.Required = sel.Attr("required")
+	if n, err := strconv.Atoi(strings.TrimSpace(sel.AttrOr("maxlength", ""))); err == nil && n > 0 {
+		e.MaxLength = n
+	}
+	return e
+}
+
+// Locator finds a classified field in the live DOM of its page.
+type Locator struct {
+	CSS        string `json:"css"`
+	XPath      string `json:"xpath"`
+	FormIndex  int    `json:"form_index"`  // position of the form among the page's forms
+	FieldIndex int    `json:"field_index"` // position of the field among the form's classified fields
 }
 
 // Classify returns field types for a form given the form type.
 func (m *FieldTypeModel) Classify(form *goquery.Selection, formType string) map[string]string {
+	return fieldTypeMap(m.ClassifyFields(form, formType))
+}
+
+// ClassifyFields returns field types in document order. Unlike Classify,
+// fields sharing a name (radio groups, repeated checkboxes) are all kept.
+func (m *FieldTypeModel) ClassifyFields(form *goquery.Selection, formType string) []FieldResult {
+	return m.classifyFields(form, formType, nil)
+}
+
+// ClassifyBatch is ClassifyFields for many forms, with formTypes[i] the
+// type of forms[i]. The CRF transition matrix is computed once and shared,
+// and forms are decoded concurrently on GOMAXPROCS goroutines. Results are
+// indexed like forms.
+func (m *FieldTypeModel) ClassifyBatch(forms []*goquery.Selection, formTypes []string) [][]FieldResult {
+	trans := m.CRF.ComputeTransScores()
+	results := make([][]FieldResult, len(forms))
+	parallelFor(len(forms), 0, func(i int) {
+		results[i] = m.classifyFields(forms[i], formTypes[i], trans)
+	})
+	return results
+}
+
+// classifyFields decodes with trans, or with a fresh transition matrix
+// when trans is nil.
+func (m *FieldTypeModel) classifyFields(form *goquery.Selection, formType string, trans [][]float64) []FieldResult {
 	fieldElems := htmlutil.GetFieldsToAnnotate(form)
 	if len(fieldElems) == 0 {
 		return nil
 	}
 
-	rawFeatures := GetFormFeatures(form, formType, fieldElems)
-
-	// Convert to CRF attributes
-	crfFeatures := make([]map[string]float64, len(rawFeatures))
-	for i, feat := range rawFeatures {
-		crfFeatures[i] = crf.FeaturesToAttributes(feat)
-	}
-
 	// Predict
-	labels := m.CRF.Predict(crfFeatures)
+	s := fieldScratchPool.Get().(*fieldScratch)
+	defer s.release()
+	if trans == nil {
+		trans = m.CRF.ComputeTransScores()
+	}
+	labels := m.CRF.PredictTrans(s.crfFormFeatures(form, formType, fieldElems, m.OptionLimits), trans)
 
 	// Map labels back to field names
-	result := make(map[string]string, len(fieldElems))
+	result := make([]FieldResult, 0, len(fieldElems))
 	for i, elem := range fieldElems {
-		name, _ := elem.Attr("name")
-		if i < len(labels) {
-			result[name] = labels[i]
+		if i >= len(labels) {
+			break
 		}
+		name, _ := elem.Attr("name")
+		result = append(result, FieldResult{Name: name, Type: labels[i]})
 	}
 	return result
 }
 
 // ClassifyProba returns field type probabilities for a form.
 func (m *FieldTypeModel) ClassifyProba(form *goquery.Selection, formType string) map[string]map[string]float64 {
+	return fieldProbaMap(m.ClassifyFieldsProba(form, formType))
+}
+
+// ClassifyFieldsProba returns field type probabilities in document order.
+func (m *FieldTypeModel) ClassifyFieldsProba(form *goquery.Selection, formType string) []FieldProbaResult {
 	fieldElems := htmlutil.GetFieldsToAnnotate(form)
 	if len(fieldElems) == 0 {
 		return nil
 	}
 
-	rawFeatures := GetFormFeatures(form, formType, fieldElems)
-
-	crfFeatures := make([]map[string]float64, len(rawFeatures))
-	for i, feat := range rawFeatures {
-		crfFeatures[i] = crf.FeaturesToAttributes(feat)
-	}
-
-	marginals := m.CRF.PredictMarginals(crfFeatures)
+	s := fieldScratchPool.Get().(*fieldScratch)
+	defer s.release()
+	marginals := m.CRF.PredictMarginals(s.crfFormFeatures(form, formType, fieldElems, m.OptionLimits))
 
-	result := make(map[string]map[string]float64, len(fieldElems))
+	result := make([]FieldProbaResult, 0, len(fieldElems))
 	for i, elem := range fieldElems {
-		name, _ := elem.Attr("name")
-		if i < len(marginals) {
-			result[name] = marginals[i]
+		if i >= len(marginals) {
+			break
 		}
+		name, _ := elem.Attr("name")
+		result = append(result, FieldProbaResult{Name: name, Proba: marginals[i]})
 	}
 	return result
 }
@@ -69,5 +154,55 @@ func (m *FieldTypeModel) ClassifyProba(form *goquery.Selection, formType string)
 // TrainFieldType trains a CRF model for field type classification.
 func TrainFieldType(sequences []crf.TrainingSequence, config crf.TrainerConfig) *FieldTypeModel {
 	crfModel := crf.Train(sequences, config)
-	return &FieldTypeModel{CRF: crfModel}
+	return &FieldTypeModel{CRF: crfModel, OptionLimits: SelectOptionLimits}
+}
+
+// fieldScratch holds the CRF input of a single FieldTypeModel prediction.
+// It comes from fieldScratchPool, so concurrent predictions on a shared
+// model never share buffers.
+type fieldScratch struct {
+	attrs []map[string]float64
+}
+
+var fieldScratchPool = sync.Pool{New: func() any { return new(fieldScratch) }}
+
+// release drops references to per-field attributes and returns s to the pool.
+func (s *fieldScratch) release() {
+	clear(s.attrs)
+	fieldScratchPool.Put(s)
+}
+
+// crfFormFeatures extracts field features and converts them to CRF
+// attributes, reusing the scratch buffer.
+func (s *fieldScratch) crfFormFeatures(form *goquery.Selection, formType string, fieldElems []*goquery.Selection, limits OptionLimits) []map[string]float64 {
+	s.attrs = s.attrs[:0]
+	for _, feat := range getFormFeatures(form, formType, fieldElems, limits) {
+		s.attrs = append(s.attrs, crf.FeaturesToAttributes(feat))
+	}
+	return s.attrs
+}
+
+// fieldTypeMap converts ordered field results to a name -> type map.
+// Later fields win when several share a name.
+func fieldTypeMap(fields []FieldResult) map[string]string {
+	if fields == nil {
+		return nil
+	}
+	result := make(map[string]string, len(fields))
+	for _, f := range fields {
+		result[f.Name] = f.Type
+	}
+	return result
+}
+
+// fieldProbaMap converts ordered field probabilities to a name -> proba map.
+func fieldProbaMap(fields []FieldProbaResult) map[string]map[string]float64 {
+	if fields == nil {
+		return nil
+	}
+	result := make(map[string]map[string]float64, len(fields))
+	for _, f := range fields {
+		result[f.Name] = f.Proba
+	}
+	return result
 }
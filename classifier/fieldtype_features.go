@@ -1,15 +1,21 @@
 package classifier
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 	"github.com/happyhackingspace/dit/internal/textutil"
 )
 
-// ElemFeatures extracts per-field features for CRF classification.
+// ElemFeatures extracts per-field features for CRF classification, with
+// SelectOptionLimits.
 func ElemFeatures(elem *goquery.Selection, form *goquery.Selection) map[string]any {
+	return elemFeatures(elem, form, SelectOptionLimits)
+}
+
+func elemFeatures(elem *goquery.Selection, form *goquery.Selection, limits OptionLimits) map[string]any {
 	name, _ := elem.Attr("name")
 	elemName := textutil.Normalize(name)
 	elemValue := normalizeAttr(elem, "value")
@@ -33,9 +39,14 @@ func ElemFeatures(elem *goquery.Selection, form *goquery.Selection) map[string]a
 	// Label features
 	label := htmlutil.FindLabel(form, elem)
 	if label != nil {
-		labelText := textutil.Normalize(label.Text())
+		// aria-labelledby may name several elements; keep their texts apart.
+		labelText := textutil.Normalize(strings.Join(label.Map(func(_ int, l *goquery.Selection) string {
+			return l.Text()
+		}), " "))
 		feat["label"] = textutil.Tokenize(labelText)
 		feat["label-ngrams-3-5"] = textutil.Ngrams(labelText, 3, 5)
+	} else if text := htmlutil.FindImplicitLabel(form, elem); text != "" {
+		feat["implicit-label"] = textutil.Tokenize(textutil.Normalize(text))
 	}
 
 	// Input type
@@ -48,14 +59,34 @@ func ElemFeatures(elem *goquery.Selection, form *goquery.Selection) map[string]a
 		feat["input-type"] = strings.ToLower(tp)
 	}
 
+	// Autofill and keypad hints, which single out one-time codes: an
+	// autocomplete="one-time-code", a numeric inputmode, a short maxlength
+	if autocomplete := normalizeAttr(elem, "autocomplete"); autocomplete != "" {
+		feat["autocomplete"] = strings.Fields(autocomplete)
+	}
+	if mode := normalizeAttr(elem, "inputmode"); mode != "" {
+		feat["inputmode"] = mode
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(elem.AttrOr("maxlength", ""))); err == nil && n > 0 {
+		feat["maxlength"] = maxLengthBucket(n)
+	}
+	if htmlutil.IsShortNumericInput(elem) {
+		feat["short-numeric"] = true
+	}
+	if htmlutil.IsOneTimeCodeInput(elem) {
+		feat["one-time-code"] = true
+	}
+
 	// Select options
 	if tag == "select" {
+		options := elem.Find("option")
 		var optTexts, optValues []string
-		elem.Find("option").Each(func(i int, opt *goquery.Selection) {
-			optTexts = append(optTexts, textutil.Normalize(opt.Text()))
+		for _, i := range sampleIndices(options.Length(), limits.MaxOptions) {
+			opt := options.Eq(i)
+			optTexts = append(optTexts, truncateRunes(textutil.Normalize(opt.Text()), limits.MaxTextLen))
 			val, _ := opt.Attr("value")
-			optValues = append(optValues, textutil.Normalize(val))
-		})
+			optValues = append(optValues, truncateRunes(textutil.Normalize(val), limits.MaxTextLen))
+		}
 		feat["option-text"] = optTexts
 		feat["option-value"] = optValues
 
@@ -77,8 +108,13 @@ func ElemFeatures(elem *goquery.Selection, form *goquery.Selection) map[string]a
 	return feat
 }
 
-// GetFormFeatures extracts CRF feature sequences for a form.
+// GetFormFeatures extracts CRF feature sequences for a form, with
+// SelectOptionLimits.
 func GetFormFeatures(form *goquery.Selection, formType string, fieldElems []*goquery.Selection) []map[string]any {
+	return getFormFeatures(form, formType, fieldElems, SelectOptionLimits)
+}
+
+func getFormFeatures(form *goquery.Selection, formType string, fieldElems []*goquery.Selection, limits OptionLimits) []map[string]any {
 	if fieldElems == nil {
 		fieldElems = htmlutil.GetFieldsToAnnotate(form)
 	}
@@ -87,7 +123,7 @@ func GetFormFeatures(form *goquery.Selection, formType string, fieldElems []*goq
 
 	res := make([]map[string]any, len(fieldElems))
 	for idx, elem := range fieldElems {
-		feat := ElemFeatures(elem, form)
+		feat := elemFeatures(elem, form, limits)
 
 		if idx == 0 {
 			feat["is-first"] = true
@@ -122,6 +158,20 @@ func GetFormFeatures(form *goquery.Selection, formType string, fieldElems []*goq
 	return res
 }
 
+// maxLengthBucket groups maxlength values: single characters (split code
+// inputs), codes and PINs, short texts, and the rest.
+func maxLengthBucket(n int) string {
+	switch {
+	case n == 1:
+		return "1"
+	case n <= 8:
+		return "2-8"
+	case n <= 32:
+		return "9-32"
+	}
+	return "33+"
+}
+
 func normalizeAttr(elem *goquery.Selection, attr string) string {
 	val, _ := elem.Attr(attr)
 	return textutil.Normalize(val)
@@ -0,0 +1,253 @@
+package classifier
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/happyhackingspace/dit/crf"
+	"github.com/happyhackingspace/dit/vectorizer"
+)
+
+// FormasaurusDump is a trained Formasaurus model exported to JSON, the input
+// of ImportFormasaurus. python/ditclient/formasaurus.py writes it from a
+// Formasaurus FormFieldClassifier.
+type FormasaurusDump struct {
+	// FormModel is the scikit-learn form type pipeline.
+	FormModel *FormasaurusLogReg `json:"form_model"`
+	// FieldModel is the python-crfsuite field type model, if any.
+	FieldModel *FormasaurusCRF `json:"field_model,omitempty"`
+	// FormTypes and FieldTypes map short type names to full ones, as in an
+	// annotation config.json. Models trained on short names are imported
+	// with full names, which dit uses throughout.
+	FormTypes  map[string]string `json:"form_types,omitempty"`
+	FieldTypes map[string]string `json:"field_types,omitempty"`
+}
+
+// FormasaurusLogReg is a LogisticRegression over a FeatureUnion of
+// extractor and vectorizer pipelines.
+type FormasaurusLogReg struct {
+	Classes   []string              `json:"classes"`
+	Coef      [][]float64           `json:"coef"` // one row for two classes
+	Intercept []float64             `json:"intercept"`
+	Pipelines []FormasaurusPipeline `json:"pipelines"`
+}
+
+// FormasaurusPipeline is one extractor and vectorizer of the FeatureUnion.
+type FormasaurusPipeline struct {
+	Extractor  string `json:"extractor"`  // class name, e.g. "SubmitText"
+	Vectorizer string `json:"vectorizer"` // DictVectorizer, CountVectorizer, or TfidfVectorizer
+	// FeatureNames is DictVectorizer.feature_names_.
+	FeatureNames []string `json:"feature_names,omitempty"`
+	// The fields below describe a CountVectorizer or TfidfVectorizer.
+	Vocabulary  map[string]int `json:"vocabulary,omitempty"`
+	NgramRange  [2]int         `json:"ngram_range"`
+	Binary      bool           `json:"binary"`
+	Analyzer    string         `json:"analyzer"`
+	MinDF       int            `json:"min_df"`
+	StopWords   []string       `json:"stop_words,omitempty"`
+	IDF         []float64      `json:"idf,omitempty"`
+	Norm        string         `json:"norm,omitempty"`
+	SublinearTF bool           `json:"sublinear_tf,omitempty"`
+}
+
+// FormasaurusCRF holds the weights of a python-crfsuite model, as listed by
+// its state_features_ and transition_features_.
+type FormasaurusCRF struct {
+	Labels        []string `json:"labels"`
+	StateFeatures []struct {
+		Attr   string  `json:"attr"`
+		Label  string  `json:"label"`
+		Weight float64 `json:"weight"`
+	} `json:"state_features"`
+	TransitionFeatures []struct {
+		From   string  `json:"from"`
+		To     string  `json:"to"`
+		Weight float64 `json:"weight"`
+	} `json:"transition_features"`
+}
+
+// ImportFormasaurus converts a FormasaurusDump into a classifier, so a
+// model trained with Formasaurus runs in dit without retraining. dit's
+// feature extractors are ports of Formasaurus's, and vocabularies, weights,
+// and CRF attributes carry over as they are. Form types are predicted as
+// in Formasaurus; probabilities come from a softmax over the same scores,
+// where scikit-learn's one-vs-rest LogisticRegression normalizes sigmoids.
+// The result has no page model.
+func ImportFormasaurus(data []byte) (*FormFieldClassifier, error) {
+	var dump FormasaurusDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, fmt.Errorf("unmarshal Formasaurus model: %w", err)
+	}
+	if dump.FormModel == nil {
+		return nil, errors.New("formasaurus model has no form model")
+	}
+	formModel, err := dump.FormModel.formTypeModel(dump.FormTypes)
+	if err != nil {
+		return nil, err
+	}
+	c := &FormFieldClassifier{FormModel: formModel}
+	if dump.FieldModel != nil {
+		crfModel, err := dump.FieldModel.model(dump.FieldTypes, dump.FormTypes)
+		if err != nil {
+			return nil, err
+		}
+		c.FieldModel = &FieldTypeModel{CRF: crfModel}
+	}
+	return c, nil
+}
+
+func (lr *FormasaurusLogReg) formTypeModel(types map[string]string) (*FormTypeModel, error) {
+	m := &FormTypeModel{
+		Classes:   make([]string, len(lr.Classes)),
+		Coef:      lr.Coef,
+		Intercept: lr.Intercept,
+	}
+	for i, cls := range lr.Classes {
+		m.Classes[i] = fullTypeName(types, cls)
+	}
+	// A binary LogisticRegression scores only the second class; a zero row
+	// for the first gives the same probabilities under softmax.
+	if len(m.Classes) == 2 && len(lr.Coef) == 1 && len(lr.Intercept) == 1 {
+		m.Coef = [][]float64{make([]float64, len(lr.Coef[0])), lr.Coef[0]}
+		m.Intercept = []float64{0, lr.Intercept[0]}
+	}
+	if len(m.Coef) != len(m.Classes) || len(m.Intercept) != len(m.Classes) {
+		return nil, fmt.Errorf("formasaurus form model has %d classes but %d coefficient rows and %d intercepts",
+			len(m.Classes), len(m.Coef), len(m.Intercept))
+	}
+
+	names := make(map[string]string)
+	for _, p := range DefaultFeaturePipelines() {
+		names[extractorTypeName(p.Extractor)] = p.Name
+	}
+	numFeatures := 0
+	for _, fp := range lr.Pipelines {
+		if _, ok := lookupExtractor(fp.Extractor); !ok {
+			return nil, fmt.Errorf("formasaurus feature extractor %q has no dit counterpart", fp.Extractor)
+		}
+		sp, err := fp.pipeline()
+		if err != nil {
+			return nil, fmt.Errorf("formasaurus pipeline %s: %w", fp.Extractor, err)
+		}
+		sp.Name = cmp.Or(names[fp.Extractor], fp.Extractor)
+		m.Pipelines = append(m.Pipelines, sp)
+		numFeatures += pipelineDim(sp)
+	}
+	for i, row := range m.Coef {
+		if len(row) != numFeatures {
+			return nil, fmt.Errorf("formasaurus form model: class %s has %d coefficients for %d features",
+				m.Classes[i], len(row), numFeatures)
+		}
+	}
+	m.InitRuntime()
+	return m, nil
+}
+
+// pipeline returns the serialized dit pipeline of fp, without its name.
+func (fp *FormasaurusPipeline) pipeline() (SerializedPipeline, error) {
+	sp := SerializedPipeline{ExtractorType: fp.Extractor}
+	if fp.Vectorizer == "DictVectorizer" {
+		sp.VecType = "dict"
+		sp.DictVec = &vectorizer.DictVectorizer{
+			FeatureNames: fp.FeatureNames,
+			FeatureIndex: make(map[string]int, len(fp.FeatureNames)),
+		}
+		for i, name := range fp.FeatureNames {
+			sp.DictVec.FeatureIndex[name] = i
+		}
+		return sp, nil
+	}
+
+	if fp.Analyzer != "word" && fp.Analyzer != "char_wb" {
+		return sp, fmt.Errorf("unsupported analyzer %q", fp.Analyzer)
+	}
+	for term, i := range fp.Vocabulary {
+		if i < 0 || i >= len(fp.Vocabulary) {
+			return sp, fmt.Errorf("vocabulary index %d of %q out of range", i, term)
+		}
+	}
+	cv := vectorizer.NewCountVectorizer(fp.NgramRange, fp.Binary, fp.Analyzer, fp.MinDF)
+	cv.Vocabulary = fp.Vocabulary
+	switch fp.Vectorizer {
+	case "CountVectorizer":
+		sp.VecType = "count"
+		sp.CountVec = cv
+	case "TfidfVectorizer":
+		if fp.Norm != "l2" || fp.SublinearTF {
+			return sp, fmt.Errorf("unsupported TfidfVectorizer settings norm=%q sublinear_tf=%v", fp.Norm, fp.SublinearTF)
+		}
+		if len(fp.IDF) != len(fp.Vocabulary) {
+			return sp, fmt.Errorf("%d idf weights for %d terms", len(fp.IDF), len(fp.Vocabulary))
+		}
+		sp.VecType = "tfidf"
+		sp.TfidfVec = &vectorizer.TfidfVectorizer{CountVec: cv, IDF: fp.IDF}
+		if len(fp.StopWords) > 0 {
+			sp.TfidfVec.StopWords = make(map[string]bool, len(fp.StopWords))
+			for _, w := range fp.StopWords {
+				sp.TfidfVec.StopWords[w] = true
+			}
+		}
+	default:
+		return sp, fmt.Errorf("unsupported vectorizer %q", fp.Vectorizer)
+	}
+	return sp, nil
+}
+
+// model returns the CRF of m with labels, and form types in form-type
+// attributes, renamed to their full names.
+func (m *FormasaurusCRF) model(fieldTypes, formTypes map[string]string) (*crf.Model, error) {
+	model := crf.NewModel()
+	for _, label := range m.Labels {
+		model.Labels.Add(fullTypeName(fieldTypes, label))
+	}
+	model.NumLabels = model.Labels.Size()
+	labelID := func(label string) (int, error) {
+		id := model.Labels.Get(fullTypeName(fieldTypes, label))
+		if id < 0 {
+			return 0, fmt.Errorf("formasaurus field model: unknown label %q", label)
+		}
+		return id, nil
+	}
+
+	attrs := make([]string, len(m.StateFeatures))
+	for i, f := range m.StateFeatures {
+		attrs[i] = f.Attr
+		if formType, ok := strings.CutPrefix(f.Attr, "form-type="); ok {
+			attrs[i] = "form-type=" + fullTypeName(formTypes, formType)
+		}
+		model.Attributes.Add(attrs[i])
+	}
+	model.Weights = make([]float64, model.NumWeights())
+	for i, f := range m.StateFeatures {
+		label, err := labelID(f.Label)
+		if err != nil {
+			return nil, err
+		}
+		model.Weights[model.StateFeatureIndex(model.Attributes.Get(attrs[i]), label)] = f.Weight
+	}
+	offset := model.TransOffset()
+	for _, f := range m.TransitionFeatures {
+		from, err := labelID(f.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := labelID(f.To)
+		if err != nil {
+			return nil, err
+		}
+		model.Weights[offset+from*model.NumLabels+to] = f.Weight
+	}
+	return model, nil
+}
+
+// fullTypeName returns the full name of a short type name in types, or
+// name itself if it is not one.
+func fullTypeName(types map[string]string, name string) string {
+	if full, ok := types[name]; ok {
+		return full
+	}
+	return name
+}
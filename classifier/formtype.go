@@ -2,9 +2,14 @@ package classifier
 
 import (
 	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/vectorizer"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/vectorizer"
 )
 
 // FormTypeModel holds a trained form type classifier.
@@ -13,13 +18,27 @@ type FormTypeModel struct {
 	Coef      [][]float64          `json:"coef"`      // [numClasses][numFeatures]
 	Intercept []float64            `json:"intercept"` // [numClasses]
 	Pipelines []SerializedPipeline `json:"pipelines"`
+	// Trees, if set, replaces Coef and Intercept: the model is a gradient
+	// boosted tree ensemble trained by TrainFormTypeGBT.
+	Trees *TreeEnsemble `json:"trees,omitempty"`
+	// Ensemble, if set, replaces the model's own weights and pipelines: its
+	// probabilities are the weighted average of the members'; see
+	// NewFormTypeEnsemble.
+	Ensemble []EnsembleMember `json:"ensemble,omitempty"`
+	// Thresholds holds tuned per-class decision thresholds; see Predict.
+	Thresholds  map[string]float64 `json:"thresholds,omitempty"`
+	Calibration *Calibration       `json:"calibration,omitempty"` // nil if uncalibrated
+	// Training records the config the model was trained with; see
+	// TrainConfig.
+	Training *FormTypeTraining `json:"training,omitempty"`
 
 	// Runtime state (not serialized directly)
-	dictVecs  []*vectorizer.DictVectorizer
-	countVecs []*vectorizer.CountVectorizer
-	tfidfVecs []*vectorizer.TfidfVectorizer
-	vecTypes  []string
-	vecDims   []int
+	dictVecs   []*vectorizer.DictVectorizer
+	countVecs  []*vectorizer.CountVectorizer
+	tfidfVecs  []*vectorizer.TfidfVectorizer
+	vecTypes   []string
+	vecDims    []int
+	extractors []FormFeatureExtractor // nil where none is known
 }
 
 // SerializedPipeline holds the serialized state of a feature pipeline.
@@ -30,11 +49,21 @@ type SerializedPipeline struct {
 	DictVec       *vectorizer.DictVectorizer  `json:"dict_vec,omitempty"`
 	CountVec      *vectorizer.CountVectorizer `json:"count_vec,omitempty"`
 	TfidfVec      *vectorizer.TfidfVectorizer `json:"tfidf_vec,omitempty"`
+	// Scale multiplies the pipeline's feature values; 0 means unscaled.
+	Scale float64 `json:"scale,omitempty"`
 }
 
 // Classify returns the predicted form type.
 func (m *FormTypeModel) Classify(form *goquery.Selection) string {
-	proba := m.ClassifyProba(form)
+	return m.Predict(m.ClassifyProba(form))
+}
+
+// Predict picks a form type from class probabilities. Without thresholds
+// this is the most probable class. With thresholds, the class whose
+// probability exceeds its threshold by the widest margin wins (classes
+// without a tuned threshold use defaultThreshold); if no class reaches its
+// threshold, the most probable class is returned.
+func (m *FormTypeModel) Predict(proba map[string]float64) string {
 	bestClass := ""
 	bestProb := -1.0
 	for cls, prob := range proba {
@@ -43,53 +72,134 @@ func (m *FormTypeModel) Classify(form *goquery.Selection) string {
 			bestClass = cls
 		}
 	}
-	return bestClass
+	if len(m.Thresholds) == 0 {
+		return bestClass
+	}
+
+	passClass := ""
+	bestMargin := -1.0
+	for cls, prob := range proba {
+		threshold, ok := m.Thresholds[cls]
+		if !ok {
+			threshold = defaultThreshold
+		}
+		if margin := prob - threshold; margin >= 0 && margin > bestMargin {
+			bestMargin = margin
+			passClass = cls
+		}
+	}
+	if passClass == "" {
+		return bestClass
+	}
+	return passClass
 }
 
-// ClassifyProba returns probabilities for each form type.
+// ClassifyProba returns probabilities for each form type, calibrated if
+// the model has a Calibration.
 func (m *FormTypeModel) ClassifyProba(form *goquery.Selection) map[string]float64 {
-	features := m.extractFeatures(form)
+	if len(m.Ensemble) > 0 {
+		return m.Calibration.Apply(m.ensembleProba(form))
+	}
+	s := formScratchPool.Get().(*formScratch)
+	defer s.release()
+	features := m.extractFeatures(form, s)
 
-	// Compute logits: logits[c] = dot(coef[c], features) + intercept[c]
+	// Compute logits: logits[c] = dot(coef[c], features) + intercept[c],
+	// or the sum of class c's trees
 	numClasses := len(m.Classes)
-	logits := make([]float64, numClasses)
-	for c := range numClasses {
-		logits[c] = features.Dot(m.Coef[c]) + m.Intercept[c]
+	s.logits = slices.Grow(s.logits[:0], numClasses)[:numClasses]
+	if m.Trees != nil {
+		m.Trees.logits(features, s.logits)
+	} else {
+		for c := range numClasses {
+			s.logits[c] = features.Dot(m.Coef[c]) + m.Intercept[c]
+		}
 	}
 
 	// Softmax
-	probs := softmax(logits)
+	probs := softmax(s.logits)
 	result := make(map[string]float64, numClasses)
 	for c, cls := range m.Classes {
 		result[cls] = probs[c]
 	}
-	return result
+	return m.Calibration.Apply(result)
 }
 
-// extractFeatures runs all pipelines and concatenates feature vectors.
-func (m *FormTypeModel) extractFeatures(form *goquery.Selection) vectorizer.SparseVector {
-	pipelines := DefaultFeaturePipelines()
-	vectors := make([]vectorizer.SparseVector, len(pipelines))
+// formScratch holds the buffers of a single FormTypeModel prediction.
+// Buffers come from formScratchPool, so concurrent predictions on a
+// shared model never touch the same memory and the model itself stays
+// read-only after InitRuntime.
+type formScratch struct {
+	vectors []vectorizer.SparseVector // per-pipeline features
+	logits  []float64
+}
 
-	for i, pipe := range pipelines {
+var formScratchPool = sync.Pool{New: func() any { return new(formScratch) }}
+
+// release drops references to per-form vectors and returns s to the pool.
+func (s *formScratch) release() {
+	clear(s.vectors)
+	formScratchPool.Put(s)
+}
+
+// extractFeatures runs the model's pipelines and concatenates feature vectors.
+// Pipelines without a known extractor contribute zeros; see
+// resolveExtractors.
+func (m *FormTypeModel) extractFeatures(form *goquery.Selection, s *formScratch) vectorizer.SparseVector {
+	view := htmlutil.NewFormView(form)
+	s.vectors = slices.Grow(s.vectors[:0], len(m.Pipelines))[:len(m.Pipelines)]
+	vectors := s.vectors
+
+	for i, sp := range m.Pipelines {
+		extractor := m.extractors[i]
+		if extractor == nil {
+			vectors[i] = vectorizer.NewSparseVector(m.vecDims[i])
+			continue
+		}
 		switch m.vecTypes[i] {
 		case "dict":
-			feats := pipe.Extractor.ExtractDict(form)
+			feats := extractor.ExtractDict(view)
 			vectors[i] = m.dictVecs[i].Transform(feats)
 		case "count":
-			text := pipe.Extractor.ExtractString(form)
+			text := extractor.ExtractString(view)
 			vectors[i] = m.countVecs[i].Transform(text)
 		case "tfidf":
-			text := pipe.Extractor.ExtractString(form)
+			text := extractor.ExtractString(view)
 			vectors[i] = m.tfidfVecs[i].Transform(text)
 		}
+		if sp.Scale != 0 {
+			scaleVector(vectors[i], sp.Scale)
+		}
 	}
 
 	return vectorizer.ConcatSparse(vectors)
 }
 
-// InitRuntime initializes runtime state from serialized pipelines.
+// resolveExtractors finds the extractor of each pipeline: the default
+// pipeline of the same name, so models trained before a pipeline was added
+// keep working, or else the extractor registered under its extractor_type.
+func (m *FormTypeModel) resolveExtractors() {
+	defaults := make(map[string]FormFeatureExtractor)
+	for _, pipe := range DefaultFeaturePipelines() {
+		defaults[pipe.Name] = pipe.Extractor
+	}
+	m.extractors = make([]FormFeatureExtractor, len(m.Pipelines))
+	for i, sp := range m.Pipelines {
+		if e, ok := defaults[sp.Name]; ok {
+			m.extractors[i] = e
+		} else if e, ok := lookupExtractor(sp.ExtractorType); ok {
+			m.extractors[i] = e
+		}
+	}
+}
+
+// InitRuntime initializes runtime state from serialized pipelines, and
+// that of the ensemble members.
 func (m *FormTypeModel) InitRuntime() {
+	for _, member := range m.Ensemble {
+		member.Model.InitRuntime()
+	}
+	m.resolveExtractors()
 	m.dictVecs = make([]*vectorizer.DictVectorizer, len(m.Pipelines))
 	m.countVecs = make([]*vectorizer.CountVectorizer, len(m.Pipelines))
 	m.tfidfVecs = make([]*vectorizer.TfidfVectorizer, len(m.Pipelines))
@@ -112,11 +222,35 @@ func (m *FormTypeModel) InitRuntime() {
 	}
 }
 
-// TrainFormType trains a form type classifier.
+// TrainFormType trains a form type classifier on the default pipelines and
+// config.ExtraPipelines: a logistic regression, or with config.Trees a
+// gradient boosted tree ensemble (see TrainFormTypeGBT).
 func TrainFormType(forms []*goquery.Selection, labels []string, config FormTypeTrainConfig) *FormTypeModel {
-	pipelines := DefaultFeaturePipelines()
+	if config.Trees != nil {
+		return TrainFormTypeGBT(forms, labels, config)
+	}
+	model, xData := fitFormPipelines(forms, config)
+	classes, y := formClasses(labels)
+	model.Classes = classes
+
+	reg := config.C
+	if reg <= 0 {
+		reg = 5.0
+	}
+
+	coef, intercept := trainLogReg(xData, y, len(classes), xData[0].Dim, reg, config.MaxIter, nil, config.Noise)
+	model.Coef = coef
+	model.Intercept = intercept
+	return model
+}
+
+// fitFormPipelines fits the vectorizers of the default pipelines and
+// config.ExtraPipelines on forms and returns the model holding them, ready
+// for weights, with the feature vector of every form.
+func fitFormPipelines(forms []*goquery.Selection, config FormTypeTrainConfig) (*FormTypeModel, []vectorizer.SparseVector) {
+	pipelines := append(DefaultFeaturePipelines(), config.ExtraPipelines...)
 
-	model := &FormTypeModel{}
+	model := &FormTypeModel{Training: newFormTypeTraining(config, pipelines)}
 	model.Pipelines = make([]SerializedPipeline, len(pipelines))
 	model.dictVecs = make([]*vectorizer.DictVectorizer, len(pipelines))
 	model.countVecs = make([]*vectorizer.CountVectorizer, len(pipelines))
@@ -124,7 +258,9 @@ func TrainFormType(forms []*goquery.Selection, labels []string, config FormTypeT
 	model.vecTypes = make([]string, len(pipelines))
 	model.vecDims = make([]int, len(pipelines))
 
-	// Extract raw features and fit vectorizers
+	// Extract raw features for every (pipeline, form) pair in parallel,
+	// then fit vectorizers
+	rawDicts, rawTexts := extractRawFeatures(pipelines, forms, config.Workers)
 	allVectors := make([][]vectorizer.SparseVector, len(pipelines))
 
 	for i, pipe := range pipelines {
@@ -135,50 +271,69 @@ func TrainFormType(forms []*goquery.Selection, labels []string, config FormTypeT
 			VecType:       pipe.VecType,
 		}
 
+		frozen := frozenPipeline(config.Vocab, pipe.Name, pipe.VecType)
 		switch pipe.VecType {
 		case "dict":
-			dv := vectorizer.NewDictVectorizer()
-			data := make([]map[string]any, len(forms))
-			for j, form := range forms {
-				data[j] = pipe.Extractor.ExtractDict(form)
+			var dv *vectorizer.DictVectorizer
+			if frozen != nil {
+				dv = frozen.DictVec
+				allVectors[i] = transformAll(rawDicts[i], dv.Transform)
+			} else {
+				dv = vectorizer.NewDictVectorizer()
+				allVectors[i] = dv.FitTransform(rawDicts[i])
 			}
-			vecs := dv.FitTransform(data)
-			allVectors[i] = vecs
 			model.dictVecs[i] = dv
 			model.vecDims[i] = dv.VocabSize()
 			sp.DictVec = dv
 
 		case "count":
-			stopWords := pipe.StopWords
-			_ = stopWords
-			cv := vectorizer.NewCountVectorizer(pipe.NgramRange, pipe.Binary, pipe.Analyzer, pipe.MinDF)
-			corpus := make([]string, len(forms))
-			for j, form := range forms {
-				corpus[j] = pipe.Extractor.ExtractString(form)
+			var cv *vectorizer.CountVectorizer
+			if frozen != nil {
+				cv = frozen.CountVec
+				allVectors[i] = transformAll(rawTexts[i], cv.Transform)
+			} else {
+				cv = vectorizer.NewCountVectorizer(pipe.NgramRange, pipe.Binary, pipe.Analyzer, pipe.MinDF)
+				allVectors[i] = cv.FitTransform(rawTexts[i])
 			}
-			vecs := cv.FitTransform(corpus)
-			allVectors[i] = vecs
 			model.countVecs[i] = cv
 			model.vecDims[i] = cv.VocabSize()
 			sp.CountVec = cv
 
 		case "tfidf":
-			stopWords := pipe.StopWords
-			if pipe.UseEnglishStop {
-				stopWords = vectorizer.EnglishStopWords()
-			}
-			tv := vectorizer.NewTfidfVectorizer(pipe.NgramRange, pipe.MinDF, pipe.Binary, pipe.Analyzer, stopWords)
-			corpus := make([]string, len(forms))
-			for j, form := range forms {
-				corpus[j] = pipe.Extractor.ExtractString(form)
+			var tv *vectorizer.TfidfVectorizer
+			if frozen != nil {
+				tv = frozen.TfidfVec
+				allVectors[i] = transformAll(rawTexts[i], tv.Transform)
+			} else {
+				stopWords := pipe.StopWords
+				if pipe.UseEnglishStop {
+					stopWords = vectorizer.EnglishStopWords()
+				}
+				tv = vectorizer.NewTfidfVectorizer(pipe.NgramRange, pipe.MinDF, pipe.Binary, pipe.Analyzer, stopWords)
+				allVectors[i] = tv.FitTransform(rawTexts[i])
 			}
-			vecs := tv.FitTransform(corpus)
-			allVectors[i] = vecs
 			model.tfidfVecs[i] = tv
 			model.vecDims[i] = tv.VocabSize()
 			sp.TfidfVec = tv
 		}
 
+		scale := 0.0
+		switch {
+		case frozen != nil:
+			scale = frozen.Scale
+		case config.ScalePipelines:
+			scale = pipelineScale(allVectors[i])
+		}
+		if override := config.PipelineScales[pipe.Name]; override > 0 {
+			scale = override
+		}
+		if scale != 0 {
+			for _, v := range allVectors[i] {
+				scaleVector(v, scale)
+			}
+			sp.Scale = scale
+		}
+
 		model.Pipelines[i] = sp
 	}
 
@@ -192,50 +347,111 @@ func TrainFormType(forms []*goquery.Selection, labels []string, config FormTypeT
 		xData[j] = vectorizer.ConcatSparse(vectors)
 	}
 
+	model.extractors = make([]FormFeatureExtractor, len(pipelines))
+	for i, pipe := range pipelines {
+		model.extractors[i] = pipe.Extractor
+	}
+	return model, xData
+}
+
+// formClasses lists the distinct labels in order of first appearance and
+// returns each label's index in that list.
+func formClasses(labels []string) (classes []string, y []int) {
 	classSet := make(map[string]int)
-	var classes []string
-	for _, l := range labels {
+	y = make([]int, len(labels))
+	for j, l := range labels {
 		if _, ok := classSet[l]; !ok {
 			classSet[l] = len(classes)
 			classes = append(classes, l)
 		}
+		y[j] = classSet[l]
 	}
-	model.Classes = classes
-
-	totalDim := xData[0].Dim
-	numClasses := len(classes)
-
-	y := make([]int, n)
-	for j := range n {
-		y[j] = classSet[labels[j]]
-	}
+	return classes, y
+}
 
-	reg := config.C
-	if reg <= 0 {
-		reg = 5.0
+// extractRawFeatures builds the view of each form and runs each pipeline's
+// extractor over the views on up to workers goroutines (0 uses
+// GOMAXPROCS). Dict pipelines fill dicts[i], text pipelines fill texts[i];
+// results are indexed like forms.
+func extractRawFeatures(pipelines []FeaturePipeline, forms []*goquery.Selection, workers int) (dicts [][]map[string]any, texts [][]string) {
+	views := make([]*htmlutil.FormView, len(forms))
+	parallelFor(len(forms), workers, func(j int) {
+		views[j] = htmlutil.NewFormView(forms[j])
+	})
+
+	dicts = make([][]map[string]any, len(pipelines))
+	texts = make([][]string, len(pipelines))
+	for i, pipe := range pipelines {
+		if pipe.VecType == "dict" {
+			dicts[i] = make([]map[string]any, len(forms))
+		} else {
+			texts[i] = make([]string, len(forms))
+		}
 	}
 
-	coef, intercept := trainLogReg(xData, y, numClasses, totalDim, reg, config.MaxIter, nil)
-	model.Coef = coef
-	model.Intercept = intercept
+	parallelFor(len(pipelines)*len(forms), workers, func(k int) {
+		i, j := k/len(forms), k%len(forms)
+		if dicts[i] != nil {
+			dicts[i][j] = pipelines[i].Extractor.ExtractDict(views[j])
+		} else {
+			texts[i][j] = pipelines[i].Extractor.ExtractString(views[j])
+		}
+	})
+	return dicts, texts
+}
 
-	return model
+// parallelFor calls fn(0..n-1) on up to workers goroutines (0 uses
+// GOMAXPROCS) and returns when all calls are done.
+func parallelFor(n, workers int, fn func(k int)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, n)
+
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				k := int(next.Add(1)) - 1
+				if k >= n {
+					return
+				}
+				fn(k)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // trainLogReg runs L-BFGS optimization for multinomial logistic regression.
-// sampleWeights can be nil for uniform weighting.
-func trainLogReg(xData []vectorizer.SparseVector, y []int, numClasses, totalDim int, reg float64, maxIter int, sampleWeights []float64) ([][]float64, []float64) {
+// sampleWeights can be nil for uniform weighting. With noise.TrimFraction
+// the trimmed examples are chosen again at every iteration, and the
+// L-BFGS history restarts whenever they change.
+func trainLogReg(xData []vectorizer.SparseVector, y []int, numClasses, totalDim int, reg float64, maxIter int, sampleWeights []float64, noise NoiseConfig) ([][]float64, []float64) {
 	numParams := numClasses * (totalDim + 1)
 	params := make([]float64, numParams)
+	targets := make([][]float64, len(y))
+	for j, yj := range y {
+		targets[j] = noise.targets(yj, numClasses)
+	}
 
 	lbfgs := newLogRegLBFGS(10)
+	weights := sampleWeights
 	for iter := range maxIter {
-		loss, gradients := logRegObjective(xData, y, params, numClasses, totalDim, reg, sampleWeights)
-		_ = iter
-		_ = loss
+		if noise.TrimFraction > 0 && iter >= trimWarmup {
+			trimmed := trimWeights(logRegLosses(xData, targets, params, numClasses, totalDim), sampleWeights, noise.TrimFraction)
+			if !slices.Equal(trimmed, weights) {
+				lbfgs = newLogRegLBFGS(10)
+			}
+			weights = trimmed
+		}
+		loss, gradients := logRegObjective(xData, targets, params, numClasses, totalDim, reg, weights)
 
 		dir := lbfgs.computeDirection(gradients, numParams)
-		step := logRegLineSearch(xData, y, params, dir, numClasses, totalDim, reg, loss, sampleWeights)
+		step := logRegLineSearch(xData, targets, params, dir, numClasses, totalDim, reg, loss, weights)
 
 		prevParams := make([]float64, numParams)
 		copy(prevParams, params)
@@ -243,7 +459,7 @@ func trainLogReg(xData []vectorizer.SparseVector, y []int, numClasses, totalDim
 			params[i] += step * dir[i]
 		}
 
-		_, newGrad := logRegObjective(xData, y, params, numClasses, totalDim, reg, sampleWeights)
+		_, newGrad := logRegObjective(xData, targets, params, numClasses, totalDim, reg, weights)
 		s := make([]float64, numParams)
 		yVec := make([]float64, numParams)
 		for i := range numParams {
@@ -280,6 +496,29 @@ type FormTypeTrainConfig struct {
 	C       float64
 	MaxIter int
 	Verbose bool
+	// ScalePipelines rescales each pipeline so its vectors have unit mean
+	// L2 norm on the training set, keeping high-dimensional text pipelines
+	// from drowning out the small structural dict pipelines.
+	ScalePipelines bool
+	// PipelineScales sets fixed positive scale factors by pipeline name,
+	// overriding learned ones.
+	PipelineScales map[string]float64
+	// Workers bounds concurrent feature extraction; 0 uses GOMAXPROCS.
+	Workers int
+	// Vocab freezes the vocabulary: pipelines found here (by name) keep
+	// their fitted vectorizers and scales, so only the weights are
+	// retrained. Typically the Pipelines of an earlier model.
+	Vocab []SerializedPipeline
+	// ExtraPipelines are trained alongside the default pipelines. Their
+	// extractors must be registered with RegisterExtractor for the model
+	// to use them after Save and Load.
+	ExtraPipelines []FeaturePipeline
+	// Trees, if set, makes TrainFormType train a gradient boosted tree
+	// ensemble instead of a logistic regression; C and MaxIter are then
+	// unused.
+	Trees *GBTConfig
+	// Noise makes either learner robust to mislabeled forms.
+	Noise NoiseConfig
 }
 
 // DefaultFormTypeTrainConfig returns default training config.
@@ -290,7 +529,54 @@ func DefaultFormTypeTrainConfig() FormTypeTrainConfig {
 	}
 }
 
-func logRegObjective(x []vectorizer.SparseVector, y []int, params []float64, numClasses, totalDim int, c float64, sampleWeights []float64) (float64, []float64) {
+// pipelineScale returns the factor that brings a pipeline's non-empty
+// vectors to unit mean L2 norm, or 0 if all vectors are empty.
+func pipelineScale(vecs []vectorizer.SparseVector) float64 {
+	sum := 0.0
+	n := 0
+	for _, v := range vecs {
+		if v.Nnz() == 0 {
+			continue
+		}
+		sum += v.L2Norm()
+		n++
+	}
+	if n == 0 || sum == 0 {
+		return 0
+	}
+	return float64(n) / sum
+}
+
+// scaleVector multiplies the vector's values in place.
+func scaleVector(v vectorizer.SparseVector, scale float64) {
+	for i := range v.Values {
+		v.Values[i] *= scale
+	}
+}
+
+// logRegProbs returns the class probabilities of x under params.
+func logRegProbs(x vectorizer.SparseVector, params []float64, numClasses, totalDim int) []float64 {
+	logits := make([]float64, numClasses)
+	for k := range numClasses {
+		offset := k * (totalDim + 1)
+		logits[k] = x.Dot(params[offset:offset+totalDim]) + params[offset+totalDim]
+	}
+	return softmax(logits)
+}
+
+// logRegLosses returns the unweighted, unregularized loss of every
+// example, for trimming.
+func logRegLosses(x []vectorizer.SparseVector, targets [][]float64, params []float64, numClasses, totalDim int) []float64 {
+	losses := make([]float64, len(x))
+	for j := range x {
+		losses[j] = crossEntropy(targets[j], logRegProbs(x[j], params, numClasses, totalDim))
+	}
+	return losses
+}
+
+// logRegObjective returns the regularized cross-entropy of params against
+// the target distributions and its gradient.
+func logRegObjective(x []vectorizer.SparseVector, targets [][]float64, params []float64, numClasses, totalDim int, c float64, sampleWeights []float64) (float64, []float64) {
 	N := len(x)
 	grad := make([]float64, len(params))
 	loss := 0.0
@@ -300,28 +586,16 @@ func logRegObjective(x []vectorizer.SparseVector, y []int, params []float64, num
 		if sampleWeights != nil {
 			w = sampleWeights[j]
 		}
-
-		logits := make([]float64, numClasses)
-		for k := range numClasses {
-			offset := k * (totalDim + 1)
-			logits[k] = x[j].Dot(params[offset:offset+totalDim]) + params[offset+totalDim]
+		if w == 0 {
+			continue
 		}
 
-		probs := softmax(logits)
-
-		if probs[y[j]] > 0 {
-			loss -= w * math.Log(probs[y[j]])
-		} else {
-			loss += w * 100
-		}
+		probs := logRegProbs(x[j], params, numClasses, totalDim)
+		loss += w * crossEntropy(targets[j], probs)
 
 		for k := range numClasses {
 			offset := k * (totalDim + 1)
-			indicator := 0.0
-			if k == y[j] {
-				indicator = 1.0
-			}
-			diff := w * (probs[k] - indicator)
+			diff := w * (probs[k] - targets[j][k])
 
 			for _, idx := range x[j].Indices {
 				for vi, vidx := range x[j].Indices {
@@ -347,7 +621,7 @@ func logRegObjective(x []vectorizer.SparseVector, y []int, params []float64, num
 	return loss, grad
 }
 
-func logRegLineSearch(x []vectorizer.SparseVector, y []int, params, dir []float64, numClasses, totalDim int, c, currentLoss float64, sampleWeights []float64) float64 {
+func logRegLineSearch(x []vectorizer.SparseVector, targets [][]float64, params, dir []float64, numClasses, totalDim int, c, currentLoss float64, sampleWeights []float64) float64 {
 	step := 1.0
 	n := len(params)
 	wNew := make([]float64, n)
@@ -356,7 +630,7 @@ func logRegLineSearch(x []vectorizer.SparseVector, y []int, params, dir []float6
 		for i := range n {
 			wNew[i] = params[i] + step*dir[i]
 		}
-		newLoss, _ := logRegObjective(x, y, wNew, numClasses, totalDim, c, sampleWeights)
+		newLoss, _ := logRegObjective(x, targets, wNew, numClasses, totalDim, c, sampleWeights)
 		if newLoss < currentLoss {
 			return step
 		}
@@ -486,28 +760,3 @@ func (l *logRegLBFGS) computeDirection(grad []float64, n int) []float64 {
 	}
 	return q
 }
-
-func extractorTypeName(e FormFeatureExtractor) string {
-	switch e.(type) {
-	case FormElements:
-		return "FormElements"
-	case SubmitText:
-		return "SubmitText"
-	case FormLinksText:
-		return "FormLinksText"
-	case FormLabelText:
-		return "FormLabelText"
-	case FormURL:
-		return "FormURL"
-	case FormCSS:
-		return "FormCSS"
-	case FormInputCSS:
-		return "FormInputCSS"
-	case FormInputNames:
-		return "FormInputNames"
-	case FormInputTitle:
-		return "FormInputTitle"
-	default:
-		return "unknown"
-	}
-}
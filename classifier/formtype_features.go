@@ -5,14 +5,15 @@ import (
 	"net/url"
 	"strings"
 
-	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
-// FormFeatureExtractor extracts features from a form element.
+// FormFeatureExtractor extracts features from a form element. Extractors
+// read the form's htmlutil.FormView, which is built once per form and
+// shared by all pipelines.
 type FormFeatureExtractor interface {
-	ExtractString(form *goquery.Selection) string
-	ExtractDict(form *goquery.Selection) map[string]any
+	ExtractString(form *htmlutil.FormView) string
+	ExtractDict(form *htmlutil.FormView) map[string]any
 	IsDict() bool
 }
 
@@ -20,12 +21,12 @@ type FormFeatureExtractor interface {
 type FormElements struct{}
 
 func (f FormElements) IsDict() bool { return true }
-func (f FormElements) ExtractString(_ *goquery.Selection) string {
+func (f FormElements) ExtractString(_ *htmlutil.FormView) string {
 	return ""
 }
-func (f FormElements) ExtractDict(form *goquery.Selection) map[string]any {
-	counts := htmlutil.GetTypeCounts(form)
-	inputCount := htmlutil.GetInputCount(form)
+func (f FormElements) ExtractDict(form *htmlutil.FormView) map[string]any {
+	counts := form.TypeCounts
+	inputCount := form.InputCount
 	return map[string]any{
 		"has <textarea>":                    counts["textarea"] > 0,
 		"has <input type=radio>":            counts["radio"] > 0,
@@ -40,7 +41,18 @@ func (f FormElements) ExtractDict(form *goquery.Selection) map[string]any {
 		"exactly one <input type=text>":     counts["text"] == 1,
 		"exactly two <input type=text>":     counts["text"] == 2,
 		"3 or more <input type=text>":       counts["text"] >= 3,
-		"<form method":                      htmlutil.GetFormMethod(form),
+		"<form method":                      form.Method,
+		"has <input type=tel>":              counts["tel"] > 0,
+		"has <input type=url>":              counts["url"] > 0,
+		"has <input type=date>":             counts["date"]+counts["datetime-local"]+counts["month"]+counts["week"]+counts["time"] > 0,
+		"has <input type=number>":           counts["number"] > 0,
+		"has <input type=file>":             counts["file"] > 0,
+		"has <input type=range>":            counts["range"] > 0,
+		"has <input type=color>":            counts["color"] > 0,
+		"has <input type=search>":           counts["search"] > 0,
+		"has required field":                form.RequiredCount > 0,
+		"has one-time code input":           form.OneTimeCodes > 0,
+		"has short numeric input":           form.ShortNumerics > 0,
 	}
 }
 
@@ -48,44 +60,44 @@ func (f FormElements) ExtractDict(form *goquery.Selection) map[string]any {
 type SubmitText struct{}
 
 func (f SubmitText) IsDict() bool { return false }
-func (f SubmitText) ExtractDict(_ *goquery.Selection) map[string]any {
+func (f SubmitText) ExtractDict(_ *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f SubmitText) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetSubmitTexts(form)
+func (f SubmitText) ExtractString(form *htmlutil.FormView) string {
+	return form.SubmitTexts
 }
 
 // FormLinksText extracts link text inside the form.
 type FormLinksText struct{}
 
 func (f FormLinksText) IsDict() bool { return false }
-func (f FormLinksText) ExtractDict(_ *goquery.Selection) map[string]any {
+func (f FormLinksText) ExtractDict(_ *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormLinksText) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetLinksText(form)
+func (f FormLinksText) ExtractString(form *htmlutil.FormView) string {
+	return form.LinksText
 }
 
 // FormLabelText extracts label text inside the form.
 type FormLabelText struct{}
 
 func (f FormLabelText) IsDict() bool { return false }
-func (f FormLabelText) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormLabelText) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormLabelText) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetLabelText(form)
+func (f FormLabelText) ExtractString(form *htmlutil.FormView) string {
+	return form.LabelText
 }
 
 // FormURL extracts the form action URL (normalized).
 type FormURL struct{}
 
 func (f FormURL) IsDict() bool { return false }
-func (f FormURL) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormURL) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormURL) ExtractString(form *goquery.Selection) string {
-	action := htmlutil.GetFormAction(form)
+func (f FormURL) ExtractString(form *htmlutil.FormView) string {
+	action := form.Action
 	if action == "" {
 		return ""
 	}
@@ -115,48 +127,97 @@ func normalizeURLPart(part string) string {
 type FormCSS struct{}
 
 func (f FormCSS) IsDict() bool { return false }
-func (f FormCSS) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormCSS) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormCSS) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetFormCSS(form)
+func (f FormCSS) ExtractString(form *htmlutil.FormView) string {
+	return form.CSS
 }
 
 // FormInputCSS extracts CSS of non-hidden inputs.
 type FormInputCSS struct{}
 
 func (f FormInputCSS) IsDict() bool { return false }
-func (f FormInputCSS) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormInputCSS) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormInputCSS) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetInputCSS(form)
+func (f FormInputCSS) ExtractString(form *htmlutil.FormView) string {
+	return form.InputCSS
 }
 
 // FormInputNames extracts names of non-hidden inputs.
 type FormInputNames struct{}
 
 func (f FormInputNames) IsDict() bool { return false }
-func (f FormInputNames) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormInputNames) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormInputNames) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetInputNames(form)
+func (f FormInputNames) ExtractString(form *htmlutil.FormView) string {
+	return form.InputNames
 }
 
 // FormInputTitle extracts title attributes of non-hidden inputs.
 type FormInputTitle struct{}
 
 func (f FormInputTitle) IsDict() bool { return false }
-func (f FormInputTitle) ExtractDict(form *goquery.Selection) map[string]any {
+func (f FormInputTitle) ExtractDict(form *htmlutil.FormView) map[string]any {
 	return nil
 }
-func (f FormInputTitle) ExtractString(form *goquery.Selection) string {
-	return htmlutil.GetInputTitles(form)
+func (f FormInputTitle) ExtractString(form *htmlutil.FormView) string {
+	return form.InputTitles
 }
 
-// DefaultFeaturePipelines returns the 9 feature extraction pipelines
-// matching Formasaurus's FEATURES list.
+// FormPosition extracts where the form sits in the page: enclosing
+// landmark, DOM depth, sibling count, and visibility. Header search bars and
+// footer newsletter forms are positionally distinctive.
+type FormPosition struct{}
+
+func (f FormPosition) IsDict() bool { return true }
+func (f FormPosition) ExtractString(_ *htmlutil.FormView) string {
+	return ""
+}
+func (f FormPosition) ExtractDict(form *htmlutil.FormView) map[string]any {
+	pos := form.Position
+	landmark := pos.Landmark
+	if landmark == "" {
+		landmark = "none"
+	}
+	return map[string]any{
+		"landmark":  landmark,
+		"dom depth": depthBucket(pos.Depth),
+		"siblings":  siblingsBucket(pos.Siblings),
+		"hidden":    pos.Hidden,
+	}
+}
+
+func depthBucket(depth int) string {
+	switch {
+	case depth <= 4:
+		return "0-4"
+	case depth <= 8:
+		return "5-8"
+	case depth <= 12:
+		return "9-12"
+	default:
+		return "13+"
+	}
+}
+
+func siblingsBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n <= 2:
+		return "1-2"
+	case n <= 5:
+		return "3-5"
+	default:
+		return "6+"
+	}
+}
+
+// DefaultFeaturePipelines returns the feature extraction pipelines: the 9
+// matching Formasaurus's FEATURES list, plus form position.
 func DefaultFeaturePipelines() []FeaturePipeline {
 	return []FeaturePipeline{
 		{Name: "form elements", Extractor: FormElements{}, VecType: "dict"},
@@ -168,6 +229,7 @@ func DefaultFeaturePipelines() []FeaturePipeline {
 		{Name: "input css", Extractor: FormInputCSS{}, VecType: "tfidf", NgramRange: [2]int{4, 5}, MinDF: 5, Binary: true, Analyzer: "char_wb"},
 		{Name: "input names", Extractor: FormInputNames{}, VecType: "tfidf", NgramRange: [2]int{5, 6}, MinDF: 3, Binary: true, Analyzer: "char_wb"},
 		{Name: "input title", Extractor: FormInputTitle{}, VecType: "tfidf", NgramRange: [2]int{5, 6}, MinDF: 3, Binary: true, Analyzer: "char_wb"},
+		{Name: "form position", Extractor: FormPosition{}, VecType: "dict"},
 	}
 }
 
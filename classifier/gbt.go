@@ -0,0 +1,293 @@
+package classifier
+
+import (
+	"math"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/vectorizer"
+)
+
+// GBTConfig holds the settings of TrainFormTypeGBT. Zero values take the
+// defaults, which suit datasets of a few thousand forms.
+type GBTConfig struct {
+	Rounds       int     `json:"rounds,omitempty"`        // boosting rounds, each adding one tree per class; 0 means 100
+	MaxDepth     int     `json:"max_depth,omitempty"`     // 0 means 4
+	LearningRate float64 `json:"learning_rate,omitempty"` // shrinks every tree's leaf values; 0 means 0.1
+	// Lambda is the L2 penalty on leaf values; 0 means 1.
+	Lambda float64 `json:"lambda,omitempty"`
+	// MinChildWeight is the least hessian sum, roughly the number of
+	// confidently mispredicted forms, a split leaves on either side; 0
+	// means 1.
+	MinChildWeight float64 `json:"min_child_weight,omitempty"`
+	// BalanceClasses weights forms inversely to their class's frequency,
+	// so that rare form types count as much as common ones.
+	BalanceClasses bool `json:"balance_classes,omitempty"`
+}
+
+func (c GBTConfig) withDefaults() GBTConfig {
+	if c.Rounds <= 0 {
+		c.Rounds = 100
+	}
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = 4
+	}
+	if c.LearningRate <= 0 {
+		c.LearningRate = 0.1
+	}
+	if c.Lambda <= 0 {
+		c.Lambda = 1
+	}
+	if c.MinChildWeight <= 0 {
+		c.MinChildWeight = 1
+	}
+	return c
+}
+
+// TreeEnsemble is a gradient boosted tree model of form type logits: the
+// logit of class c is Base[c] plus the leaf value each of Trees[r][c]
+// gives the form's features.
+type TreeEnsemble struct {
+	Base  []float64 `json:"base"`  // [numClasses], log class priors
+	Trees [][]Tree  `json:"trees"` // [rounds][numClasses]
+}
+
+// Tree is a binary regression tree over sparse features. Nodes[0] is the
+// root; every split tests whether a feature is present (non-zero), which
+// suits the sparse, mostly binary form features.
+type Tree struct {
+	Nodes []TreeNode `json:"nodes"`
+}
+
+// TreeNode is a split, or a leaf if Feature is -1. Value is the node's
+// contribution to the logit, leaves included; explanations attribute the
+// change from a node's value to its child's to the split feature.
+type TreeNode struct {
+	Feature int     `json:"f"`
+	Present int     `json:"p,omitempty"` // child index when the feature is present
+	Absent  int     `json:"a,omitempty"` // child index when it is not
+	Value   float64 `json:"v"`
+}
+
+// TrainFormTypeGBT trains a form type classifier whose scores come from a
+// gradient boosted tree ensemble (multiclass softmax boosting with Newton
+// leaf steps, as in XGBoost) over the same pipelines as TrainFormType.
+// Trees capture feature interactions a linear model cannot and often do
+// better on imbalanced data; config.Trees, if set, tunes them.
+func TrainFormTypeGBT(forms []*goquery.Selection, labels []string, config FormTypeTrainConfig) *FormTypeModel {
+	model, xData := fitFormPipelines(forms, config)
+	classes, y := formClasses(labels)
+	model.Classes = classes
+
+	var gbt GBTConfig
+	if config.Trees != nil {
+		gbt = *config.Trees
+	}
+	model.Trees = trainTrees(xData, y, len(classes), gbt.withDefaults(), config.Noise, config.Workers)
+	return model
+}
+
+// trainTrees boosts config.Rounds rounds of one tree per class on the
+// softmax loss; the trees of a round are grown on up to workers
+// goroutines. With noise.TrimFraction, every round after the first few
+// leaves out the forms with the highest loss.
+func trainTrees(xData []vectorizer.SparseVector, y []int, numClasses int, config GBTConfig, noise NoiseConfig, workers int) *TreeEnsemble {
+	n := len(xData)
+	weights := make([]float64, n)
+	counts := make([]int, numClasses)
+	for _, c := range y {
+		counts[c]++
+	}
+	for j, c := range y {
+		weights[j] = 1
+		if config.BalanceClasses {
+			weights[j] = float64(n) / float64(numClasses*counts[c])
+		}
+	}
+
+	ens := &TreeEnsemble{Base: make([]float64, numClasses)}
+	for c, count := range counts {
+		ens.Base[c] = math.Log(float64(count) / float64(n))
+	}
+
+	// present[j] holds the sorted non-zero features of form j.
+	present := make([][]int, n)
+	for j, x := range xData {
+		for k, idx := range x.Indices {
+			if x.Values[k] != 0 {
+				present[j] = append(present[j], idx)
+			}
+		}
+		slices.Sort(present[j])
+	}
+
+	targets := make([][]float64, n)
+	scores := make([][]float64, n)
+	for j := range scores {
+		targets[j] = noise.targets(y[j], numClasses)
+		scores[j] = slices.Clone(ens.Base)
+	}
+	dim := xData[0].Dim
+	for r := range config.Rounds {
+		probs := make([][]float64, n)
+		for j := range n {
+			probs[j] = softmax(scores[j])
+		}
+		roundWeights := weights
+		if noise.TrimFraction > 0 && r >= trimWarmup {
+			losses := make([]float64, n)
+			for j := range n {
+				losses[j] = crossEntropy(targets[j], probs[j])
+			}
+			roundWeights = trimWeights(losses, weights, noise.TrimFraction)
+		}
+		// Trimmed forms, with weight 0, are left out of the trees.
+		var rows []int
+		for j, w := range roundWeights {
+			if w > 0 {
+				rows = append(rows, j)
+			}
+		}
+
+		round := make([]Tree, numClasses)
+		parallelFor(numClasses, workers, func(c int) {
+			grad := make([]float64, n)
+			hess := make([]float64, n)
+			for _, j := range rows {
+				p := probs[j][c]
+				grad[j] = roundWeights[j] * (p - targets[j][c])
+				hess[j] = roundWeights[j] * max(p*(1-p), 1e-6)
+			}
+			g := treeGrower{config: config, present: present, grad: grad, hess: hess,
+				sumG: make([]float64, dim), sumH: make([]float64, dim)}
+			g.grow(rows, 0)
+			round[c] = Tree{Nodes: g.nodes}
+		})
+		for j := range n {
+			for c := range numClasses {
+				scores[j][c] += round[c].leafValue(func(f int) bool {
+					_, found := slices.BinarySearch(present[j], f)
+					return found
+				})
+			}
+		}
+		ens.Trees = append(ens.Trees, round)
+	}
+	return ens
+}
+
+// treeGrower grows one regression tree on the gradients and hessians of
+// the forms. sumG and sumH are scratch space indexed by feature.
+type treeGrower struct {
+	config     GBTConfig
+	present    [][]int
+	grad, hess []float64
+	sumG, sumH []float64
+	nodes      []TreeNode
+}
+
+// grow adds the node for the forms in rows at depth and returns its index.
+func (g *treeGrower) grow(rows []int, depth int) int {
+	var G, H float64
+	for _, j := range rows {
+		G += g.grad[j]
+		H += g.hess[j]
+	}
+	lambda := g.config.Lambda
+	idx := len(g.nodes)
+	g.nodes = append(g.nodes, TreeNode{Feature: -1, Value: -G / (H + lambda) * g.config.LearningRate})
+	if depth >= g.config.MaxDepth || H < 2*g.config.MinChildWeight {
+		return idx
+	}
+
+	var touched []int
+	for _, j := range rows {
+		for _, f := range g.present[j] {
+			if g.sumH[f] == 0 {
+				touched = append(touched, f)
+			}
+			g.sumG[f] += g.grad[j]
+			g.sumH[f] += g.hess[j]
+		}
+	}
+	best, bestGain := -1, 0.0
+	parent := G * G / (H + lambda)
+	for _, f := range touched {
+		gl, hl := g.sumG[f], g.sumH[f]
+		gr, hr := G-gl, H-hl
+		g.sumG[f], g.sumH[f] = 0, 0
+		if hl < g.config.MinChildWeight || hr < g.config.MinChildWeight {
+			continue
+		}
+		gain := gl*gl/(hl+lambda) + gr*gr/(hr+lambda) - parent
+		if gain > bestGain+1e-12 || gain > bestGain-1e-12 && best >= 0 && f < best {
+			best, bestGain = f, gain
+		}
+	}
+	if best < 0 {
+		return idx
+	}
+
+	var with, without []int
+	for _, j := range rows {
+		if _, found := slices.BinarySearch(g.present[j], best); found {
+			with = append(with, j)
+		} else {
+			without = append(without, j)
+		}
+	}
+	presentChild := g.grow(with, depth+1)
+	absentChild := g.grow(without, depth+1)
+	g.nodes[idx].Feature, g.nodes[idx].Present, g.nodes[idx].Absent = best, presentChild, absentChild
+	return idx
+}
+
+// leafValue walks the tree for a form whose features has reports present
+// and returns the value of the leaf it reaches.
+func (t *Tree) leafValue(has func(feature int) bool) float64 {
+	i := 0
+	for t.Nodes[i].Feature >= 0 {
+		if has(t.Nodes[i].Feature) {
+			i = t.Nodes[i].Present
+		} else {
+			i = t.Nodes[i].Absent
+		}
+	}
+	return t.Nodes[i].Value
+}
+
+// path returns the indices of the nodes from the root to the leaf a form
+// reaches.
+func (t *Tree) path(has func(feature int) bool) []int {
+	path := []int{0}
+	for n := t.Nodes[0]; n.Feature >= 0; n = t.Nodes[path[len(path)-1]] {
+		next := n.Absent
+		if has(n.Feature) {
+			next = n.Present
+		}
+		path = append(path, next)
+	}
+	return path
+}
+
+// logits writes the class logits of features to out.
+func (e *TreeEnsemble) logits(features vectorizer.SparseVector, out []float64) {
+	has := presence(features)
+	copy(out, e.Base)
+	for _, round := range e.Trees {
+		for c := range round {
+			out[c] += round[c].leafValue(has)
+		}
+	}
+}
+
+// presence returns a test for the non-zero features of v.
+func presence(v vectorizer.SparseVector) func(feature int) bool {
+	set := make(map[int]bool, len(v.Indices))
+	for k, idx := range v.Indices {
+		if v.Values[k] != 0 {
+			set[idx] = true
+		}
+	}
+	return func(f int) bool { return set[f] }
+}
@@ -0,0 +1,275 @@
+package classifier
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// The heuristic classifier stands in for a trained model when none can be
+// loaded: fields are typed by their type, name, id, placeholder and
+// autocomplete attributes, forms by the field types they hold, and pages
+// by URL patterns and markup. It is far less accurate than a model.
+
+// fieldRules map attribute text (name, id, placeholder, autocomplete,
+// aria-label, lowercased) to field types, first match wins.
+var fieldRules = []struct {
+	pattern *regexp.Regexp
+	typ     string
+}{
+	{regexp.MustCompile(`captcha`), "captcha"},
+	{regexp.MustCompile(`(user|login).*(mail)|(mail).*(user|login)`), "username or email"},
+	{regexp.MustCompile(`(confirm|repeat|again|verify|retype).*mail|mail.*(confirm|repeat|again|verify|retype|2)`), "email confirmation"},
+	{regexp.MustCompile(`e-?mail`), "email"},
+	{regexp.MustCompile(`user|login|nick|handle|account`), "username"},
+	{regexp.MustCompile(`first.?name|fname|given`), "first name"},
+	{regexp.MustCompile(`last.?name|lname|surname|family`), "last name"},
+	{regexp.MustCompile(`middle`), "middle name"},
+	{regexp.MustCompile(`full.?name|^name$|your.?name`), "full name"},
+	{regexp.MustCompile(`company|organi[sz]ation|business`), "organization name"},
+	{regexp.MustCompile(`phone|mobile|tel\b|cell`), "phone"},
+	{regexp.MustCompile(`fax`), "fax"},
+	{regexp.MustCompile(`zip|postal|postcode`), "postal code"},
+	{regexp.MustCompile(`country`), "country"},
+	{regexp.MustCompile(`city|town`), "city"},
+	{regexp.MustCompile(`state|province|region`), "state"},
+	{regexp.MustCompile(`address|street`), "address"},
+	{regexp.MustCompile(`website|homepage|url`), "url"},
+	{regexp.MustCompile(`^(q|s|query|search|keywords?|term)$|search`), "search query"},
+	{regexp.MustCompile(`qty|quantity`), "product quantity"},
+	{regexp.MustCompile(`sort|order.?by`), "sorting option"},
+	{regexp.MustCompile(`gender|sex`), "gender"},
+	{regexp.MustCompile(`birth|dob|date`), "full date"},
+	{regexp.MustCompile(`subject|title`), "comment title"},
+	{regexp.MustCompile(`comment|message|body|enquiry|inquiry`), "comment text"},
+}
+
+// HeuristicClassify types form and its fields by rules, as Classify does
+// with a model.
+func HeuristicClassify(form *goquery.Selection) ClassifyResult {
+	result := ClassifyResult{Fields: make(map[string]string)}
+	passwords := 0
+	for _, elem := range htmlutil.GetFieldsToAnnotate(form) {
+		typ := heuristicFieldType(elem, passwords)
+		if typ == "password" {
+			passwords++
+		}
+		name := elem.AttrOr("name", "")
+		if _, ok := result.Fields[name]; !ok {
+			result.Fields[name] = typ
+		}
+		result.FieldList = append(result.FieldList, FieldResult{Name: name, Type: typ})
+	}
+	result.Form = heuristicFormType(form, result.FieldList)
+	return result
+}
+
+// heuristicFieldType types one field; passwords is the number of password
+// inputs before it.
+func heuristicFieldType(elem *goquery.Selection, passwords int) string {
+	tag := goquery.NodeName(elem)
+	typ := strings.ToLower(strings.TrimSpace(elem.AttrOr("type", "")))
+	attrs := strings.ToLower(strings.Join([]string{
+		elem.AttrOr("name", ""), elem.AttrOr("id", ""), elem.AttrOr("placeholder", ""),
+		elem.AttrOr("autocomplete", ""), elem.AttrOr("aria-label", ""),
+	}, " "))
+
+	switch {
+	case typ == "reset":
+		return "reset button"
+	case tag == "button" || typ == "submit" || typ == "image" || typ == "button":
+		if strings.Contains(attrs+" "+strings.ToLower(elem.Text()+elem.AttrOr("value", "")), "cancel") {
+			return "cancel button"
+		}
+		return "submit button"
+	case htmlutil.IsOneTimeCodeInput(elem):
+		return "otp code"
+	case typ == "password":
+		if passwords > 0 {
+			return "password confirmation"
+		}
+		return "password"
+	case typ == "checkbox":
+		switch {
+		case strings.Contains(attrs, "remember"):
+			return "remember me checkbox"
+		case containsAnyString(attrs, "terms", "tos", "agree", "accept", "policy"):
+			return "TOS confirmation"
+		case containsAnyString(attrs, "newsletter", "subscribe", "news", "optin", "opt-in", "marketing"):
+			return "receive emails confirmation"
+		}
+		return "other"
+	case typ == "search":
+		return "search query"
+	case typ == "email" && !containsAnyString(attrs, "confirm", "repeat", "again"):
+		return "email"
+	case typ == "tel":
+		return "phone"
+	case typ == "url":
+		return "url"
+	}
+	name := strings.ToLower(elem.AttrOr("name", ""))
+	for _, r := range fieldRules {
+		if r.pattern.MatchString(name) || r.pattern.MatchString(attrs) {
+			return r.typ
+		}
+	}
+	if tag == "textarea" {
+		return "comment text"
+	}
+	return "other"
+}
+
+// heuristicFormType types a form from its field types and text.
+func heuristicFormType(form *goquery.Selection, fields []FieldResult) string {
+	counts := make(map[string]int)
+	for _, f := range fields {
+		counts[f.Type]++
+	}
+	inputs := len(fields) - counts["submit button"] - counts["cancel button"] - counts["reset button"]
+	text := strings.ToLower(htmlutil.GetAllFormText(form) + " " + form.AttrOr("action", "") + " " + form.AttrOr("class", "") + " " + form.AttrOr("id", ""))
+	userFields := counts["username"] + counts["email"] + counts["username or email"]
+
+	switch {
+	case counts["otp code"] > 0 && counts["password"]+counts["password confirmation"] == 0 && inputs <= 3:
+		return "mfa"
+	case counts["password confirmation"] > 0 || counts["password"] > 0 && (counts["first name"]+counts["last name"]+counts["full name"]+counts["email confirmation"] > 0):
+		return "registration"
+	case counts["password"] > 0:
+		if containsAnyString(text, "sign up", "signup", "register", "create account", "join") && !containsAnyString(text, "log in", "login", "sign in", "signin") {
+			return "registration"
+		}
+		return "login"
+	case counts["search query"] > 0 && inputs <= 3:
+		return "search"
+	case userFields > 0 && inputs <= 2 && containsAnyString(text, "forgot", "reset", "recover", "lost"):
+		return "password/login recovery"
+	case counts["email"] > 0 && inputs <= 3 && containsAnyString(text, "newsletter", "subscribe", "mailing"):
+		return "join mailing list"
+	case counts["comment text"] > 0:
+		return "contact/comment"
+	case counts["product quantity"] > 0 || counts["postal code"] > 0 && counts["address"] > 0 ||
+		form.Find(`input[autocomplete^="cc-"]`).Length() > 0:
+		return "order/add to cart"
+	}
+	return "other"
+}
+
+// urlPageTypes map URL path patterns to page types, first match wins.
+var urlPageTypes = []struct {
+	pattern *regexp.Regexp
+	typ     string
+}{
+	{regexp.MustCompile(`/(forgot|reset|recover|lost)[-_]?(password|pass|pw)?|/password[-_/](reset|new|forgot)|/account[-_]recovery`), "password_reset"},
+	{regexp.MustCompile(`/(2fa|mfa|otp|totp|two[-_]factor|multi[-_]factor)\b`), "mfa"},
+	{regexp.MustCompile(`/(log[-_]?in|sign[-_]?in|wp-login|auth/login|sso)\b`), "login"},
+	{regexp.MustCompile(`/(register|sign[-_]?up|join|create[-_]account|registration)\b`), "registration"},
+	{regexp.MustCompile(`/(wp-admin|admin|dashboard|administrator|cpanel)\b`), "admin"},
+	{regexp.MustCompile(`/(settings|preferences|my[-_]?account|account/edit|profile/edit)\b`), "settings"},
+	{regexp.MustCompile(`/(checkout|cart|basket|shopping[-_]bag)\b`), "checkout"},
+	{regexp.MustCompile(`/(pricing|plans)\b`), "pricing"},
+	{regexp.MustCompile(`/(contact|contact[-_]us|support/contact)\b`), "contact"},
+	{regexp.MustCompile(`/(search|find)\b`), "search"},
+	{regexp.MustCompile(`/(docs|documentation|api[-_]docs|api[-_]reference|swagger|redoc|developers?)\b`), "docs"},
+	{regexp.MustCompile(`/(products?|items?|shop/[^/]+|dp|p)/`), "product"},
+	{regexp.MustCompile(`/(blog|news|articles?|posts?|\d{4}/\d{2})/`), "blog"},
+}
+
+// formPageTypes map the type of a page's main form to a page type.
+var formPageTypes = map[string]string{
+	"mfa":                     "mfa",
+	"login":                   "login",
+	"registration":            "registration",
+	"password/login recovery": "password_reset",
+	"contact/comment":         "contact",
+	"order/add to cart":       "checkout",
+}
+
+// HeuristicPageType types a page by rules: stand-in and error pages by
+// their markup, then pageURL's path, then the types of its forms.
+func HeuristicPageType(doc *goquery.Document, formResults []ClassifyResult, pageURL string) string {
+	switch htmlutil.JunkPageKind(doc) {
+	case htmlutil.JunkBotChallenge:
+		return "captcha"
+	case htmlutil.JunkParked:
+		return "parked"
+	}
+	ind := htmlutil.GetErrorIndicators(doc)
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if ind["title_has_"+k] == 1.0 || ind["h1_has_"+k] == 1.0 {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("index_of", "directory_listing"):
+		return "directory_listing"
+	case has("welcome_nginx", "apache_default", "iis_default"):
+		return "default_page"
+	case htmlutil.IsMaintenancePage(doc):
+		return "maintenance"
+	case has("coming_soon", "launching_soon"):
+		return "coming_soon"
+	case has("404", "not_found", "page_not_found", "does_not_exist"):
+		return "soft_404"
+	case has("access_denied", "forbidden", "unauthorized", "server_error", "internal_error", "503", "service_unavailable"):
+		return "error"
+	}
+
+	path := ""
+	if u, err := url.Parse(pageURL); err == nil {
+		path = strings.ToLower(u.Path)
+		if u.Query().Get("q") != "" || u.Query().Get("s") != "" || u.Query().Get("query") != "" {
+			return "search"
+		}
+	}
+	for _, r := range urlPageTypes {
+		if r.pattern.MatchString(path + "/") {
+			return r.typ
+		}
+	}
+
+	for _, r := range formResults {
+		if typ, ok := formPageTypes[r.Form]; ok {
+			return typ
+		}
+	}
+	if len(htmlutil.GetDocsFrameworks(doc)) > 0 {
+		return "docs"
+	}
+	if pageURL != "" && strings.Trim(path, "/") == "" {
+		return "landing"
+	}
+	return "other"
+}
+
+// HeuristicExtractPage is ExtractPageDoc with HeuristicClassify and
+// HeuristicPageType in place of the models: it types every form and its
+// fields, then the page.
+func HeuristicExtractPage(doc *goquery.Document, pageURL string) ([]FormResult, ClassifyResult) {
+	forms := htmlutil.GetForms(doc)
+	formResults := make([]FormResult, len(forms))
+	classifyResults := make([]ClassifyResult, len(forms))
+	for i, form := range forms {
+		formHTML, _ := form.Html()
+		result := HeuristicClassify(form)
+		addElements(form, result.FieldList, nil)
+		formResults[i] = FormResult{FormHTML: formHTML, Meta: NewFormMeta(i, form), Result: result, CSRFTokens: htmlutil.GetCSRFTokens(form)}
+		classifyResults[i] = result
+	}
+	return formResults, ClassifyResult{Form: HeuristicPageType(doc, classifyResults, pageURL)}
+}
+
+func containsAnyString(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package classifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// isolate runs classify, turning a panic into an error and, with a positive
+// timeout, giving up once it expires so one pathological form (a select
+// with 100k options, say) cannot crash or stall a whole batch. A timed-out
+// classify keeps running in the background; its results must only be read
+// when isolate returns nil.
+func isolate(timeout time.Duration, classify func()) error {
+	run := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("classify form: panic: %v", r)
+			}
+		}()
+		classify()
+		return nil
+	}
+	if timeout <= 0 {
+		return run()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- run() }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("classify form: timed out after %v", timeout)
+	}
+}
@@ -0,0 +1,82 @@
+package classifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ModelFormat is the version of the model JSON layout written by SaveModel.
+// A layout change that can be derived from older files (a renamed field, a
+// new block with a sensible default) bumps it and appends a migration, so
+// existing model files keep loading without a retrain.
+const ModelFormat = 6
+
+// ErrModelFormat is returned for a model file written in a newer format
+// than this version understands, or with an invalid (negative) format.
+var ErrModelFormat = errors.New("unsupported model format")
+
+// migrations[i] upgrades a model document from format i to i+1. Each one
+// edits the top-level JSON object in place.
+var migrations = []func(doc map[string]json.RawMessage) error{
+	// 0 -> 1: files before versioning differ only by the missing format
+	// field, which MigrateModel sets.
+	func(map[string]json.RawMessage) error { return nil },
+	// 1 -> 2: form models may hold gradient boosted trees under "trees",
+	// which format 1 readers would ignore and classify with the empty
+	// linear weights instead; older files have none.
+	func(map[string]json.RawMessage) error { return nil },
+	// 2 -> 3: form models may be ensembles of members under "ensemble",
+	// which format 2 readers would take for an empty linear model; older
+	// files have none.
+	func(map[string]json.RawMessage) error { return nil },
+	// 3 -> 4: "option_limits" records the select option limits of the
+	// field model, which format 3 readers would replace with their own;
+	// older files have none and so use no limits.
+	func(map[string]json.RawMessage) error { return nil },
+	// 4 -> 5: "languages" holds per-language models, which format 4
+	// readers would drop, classifying every page with the default models;
+	// older files have none.
+	func(map[string]json.RawMessage) error { return nil },
+	// 5 -> 6: form and page models may hold a "calibration", which format
+	// 5 readers would ignore and return uncalibrated probabilities; older
+	// files have none.
+	func(map[string]json.RawMessage) error { return nil },
+}
+
+// MigrateModel upgrades serialized model JSON to ModelFormat. It returns the
+// upgraded JSON and the format the data was in; data already in the current
+// format is returned unchanged.
+func MigrateModel(data []byte) ([]byte, int, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("unmarshal model: %w", err)
+	}
+
+	format := 0
+	if raw, ok := doc["format"]; ok {
+		if err := json.Unmarshal(raw, &format); err != nil {
+			return nil, 0, fmt.Errorf("unmarshal model format: %w", err)
+		}
+	}
+	switch {
+	case format < 0:
+		return nil, format, fmt.Errorf("%w: model has invalid format %d", ErrModelFormat, format)
+	case format > ModelFormat:
+		return nil, format, fmt.Errorf("%w: model has format %d, newest supported is %d", ErrModelFormat, format, ModelFormat)
+	case format == ModelFormat:
+		return data, format, nil
+	}
+
+	for v := format; v < ModelFormat; v++ {
+		if err := migrations[v](doc); err != nil {
+			return nil, format, fmt.Errorf("migrate model from format %d: %w", v, err)
+		}
+	}
+	doc["format"] = json.RawMessage(fmt.Sprint(ModelFormat))
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, format, fmt.Errorf("marshal model: %w", err)
+	}
+	return out, format, nil
+}
@@ -1,56 +1,218 @@
 package classifier
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/happyhackingspace/dit/crf"
 )
 
+// FeatureSchema identifies the field features GetFormFeatures produces.
+// A field model only understands the features it was trained on, so bump
+// this whenever they change; testdata/field_features.golden.json pins the
+// current output and the snapshot test fails on drift without a bump.
+const FeatureSchema = 2
+
+// ErrFeatureSchema is returned by LoadClassifier for a model trained on a
+// different FeatureSchema.
+var ErrFeatureSchema = errors.New("model feature schema mismatch")
+
+// legacyFeatureSchema is the FeatureSchema in effect when it began to be
+// recorded; field models saved without one were trained on its features.
+const legacyFeatureSchema = 1
+
 // UnifiedModel holds form, field, and page models for serialization.
 type UnifiedModel struct {
-	FormModel  *FormTypeModel `json:"form_model"`
-	FieldModel *crf.Model     `json:"field_model"`
-	PageModel  *PageTypeModel `json:"page_model"`
+	// Format is the ModelFormat of the JSON layout; see MigrateModel.
+	Format int `json:"format"`
+	// FeatureSchema is the schema the field model was trained with; 0 for
+	// models saved before it was recorded, which are taken as schema 1.
+	FeatureSchema int            `json:"feature_schema,omitempty"`
+	FormModel     *FormTypeModel `json:"form_model"`
+	FieldModel    *crf.Model     `json:"field_model"`
+	// OptionLimits are the field model's FieldTypeModel.OptionLimits;
+	// missing means none.
+	OptionLimits *OptionLimits  `json:"option_limits,omitempty"`
+	PageModel    *PageTypeModel `json:"page_model"`
+	// Languages holds the models of FormFieldClassifier.Languages, saved
+	// in the same format and schema.
+	Languages map[string]*UnifiedModel `json:"languages,omitempty"`
 }
 
-// SaveModel saves the classifier to disk.
-func (c *FormFieldClassifier) SaveModel(path string) error {
+// unified returns the serializable form of the classifier.
+func (c *FormFieldClassifier) unified() UnifiedModel {
 	um := UnifiedModel{
-		FormModel: c.FormModel,
-		PageModel: c.PageModel,
+		Format:        ModelFormat,
+		FeatureSchema: FeatureSchema,
+		FormModel:     c.FormModel,
+		PageModel:     c.PageModel,
 	}
 	if c.FieldModel != nil {
 		um.FieldModel = c.FieldModel.CRF
+		if limits := c.FieldModel.OptionLimits; limits != (OptionLimits{}) {
+			um.OptionLimits = &limits
+		}
+	}
+	if len(c.Languages) > 0 {
+		um.Languages = make(map[string]*UnifiedModel, len(c.Languages))
+		for lang, m := range c.Languages {
+			lum := m.unified()
+			um.Languages[lang] = &lum
+		}
+	}
+	return um
+}
+
+// Version returns a content hash of the serialized models. It changes
+// whenever any model parameter (including tuned thresholds) changes.
+func (c *FormFieldClassifier) Version() (string, error) {
+	data, err := json.Marshal(c.unified())
+	if err != nil {
+		return "", fmt.Errorf("marshal model: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Clone returns a deep copy of c, for changing models that other
+// goroutines may be classifying with.
+func (c *FormFieldClassifier) Clone() (*FormFieldClassifier, error) {
+	data, err := json.Marshal(c.unified())
+	if err != nil {
+		return nil, fmt.Errorf("marshal model: %w", err)
+	}
+	clone, err := ParseClassifier(data)
+	if err != nil {
+		return nil, err
 	}
+	clone.FormTimeout, clone.Locators, clone.Templates = c.FormTimeout, c.Locators, c.Templates
+	return clone, nil
+}
 
-	data, err := json.MarshalIndent(um, "", "  ")
+// SaveModel saves the classifier to disk. A path ending in ".gz" is
+// written as compact, gzip-compressed JSON.
+func (c *FormFieldClassifier) SaveModel(path string) error {
+	var data []byte
+	var err error
+	if strings.HasSuffix(path, ".gz") {
+		data, err = json.Marshal(c.unified())
+	} else {
+		data, err = json.MarshalIndent(c.unified(), "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("marshal model: %w", err)
 	}
+	if strings.HasSuffix(path, ".gz") {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		_, _ = zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("compress model: %w", err)
+		}
+		data = buf.Bytes()
+	}
 
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create directory: %w", err)
 	}
 
-	return os.WriteFile(path, data, 0644)
+	// Write a temporary file and rename it over path, so a failed or
+	// interrupted save (or migration) never leaves a truncated model.
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create model file: %w", err)
+	}
+	_, werr := tmp.Write(data)
+	if werr == nil {
+		werr = tmp.Chmod(0644)
+	}
+	if cerr := tmp.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), path)
+	}
+	if werr != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write model file: %w", werr)
+	}
+	return nil
 }
 
-// LoadClassifier loads a FormFieldClassifier from disk.
+// LoadClassifier loads a FormFieldClassifier from disk, migrating models
+// saved in an older format. Gzip-compressed files are recognized by their
+// content.
 func LoadClassifier(path string) (*FormFieldClassifier, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read model: %w", err)
 	}
+	return ParseClassifier(data)
+}
+
+// ParseClassifier is LoadClassifier for a model already in memory, such as
+// one embedded in a binary.
+func ParseClassifier(data []byte) (*FormFieldClassifier, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("decompress model: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("decompress model: %w", err)
+		}
+	}
+	data, _, err := MigrateModel(data)
+	if err != nil {
+		return nil, err
+	}
 
 	var um UnifiedModel
 	if err := json.Unmarshal(data, &um); err != nil {
 		return nil, fmt.Errorf("unmarshal model: %w", err)
 	}
+	if schema := um.featureSchema(); schema != FeatureSchema && (um.FeatureSchema != 0 || um.hasFieldModel()) {
+		return nil, fmt.Errorf("%w: model has %d, dit expects %d", ErrFeatureSchema, schema, FeatureSchema)
+	}
+
+	return um.classifier(), nil
+}
 
+// featureSchema returns the recorded FeatureSchema, or legacyFeatureSchema
+// if none was recorded.
+func (um *UnifiedModel) featureSchema() int {
+	if um.FeatureSchema == 0 {
+		return legacyFeatureSchema
+	}
+	return um.FeatureSchema
+}
+
+// hasFieldModel reports whether um or any of its language models holds a
+// field model, the only model the feature schema applies to.
+func (um *UnifiedModel) hasFieldModel() bool {
+	if um.FieldModel != nil {
+		return true
+	}
+	for _, lum := range um.Languages {
+		if lum.hasFieldModel() {
+			return true
+		}
+	}
+	return false
+}
+
+// classifier returns the runtime classifier of a loaded model.
+func (um *UnifiedModel) classifier() *FormFieldClassifier {
 	c := &FormFieldClassifier{
 		FormModel: um.FormModel,
 		PageModel: um.PageModel,
@@ -62,11 +224,21 @@ func LoadClassifier(path string) (*FormFieldClassifier, error) {
 
 	if um.FieldModel != nil {
 		c.FieldModel = &FieldTypeModel{CRF: um.FieldModel}
+		if um.OptionLimits != nil {
+			c.FieldModel.OptionLimits = *um.OptionLimits
+		}
 	}
 
 	if um.PageModel != nil {
 		um.PageModel.InitRuntime()
 	}
 
-	return c, nil
+	if len(um.Languages) > 0 {
+		c.Languages = make(map[string]*FormFieldClassifier, len(um.Languages))
+		for lang, lum := range um.Languages {
+			c.Languages[lang] = lum.classifier()
+		}
+	}
+
+	return c
 }
@@ -0,0 +1,78 @@
+package classifier
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// trimWarmup is the number of iterations, or boosting rounds, trained on
+// every example before NoiseConfig.TrimFraction starts leaving some out:
+// until the model fits the bulk of the data, a high loss says little about
+// a label.
+const trimWarmup = 10
+
+// NoiseConfig makes the form and page type trainers robust to the
+// mislabeled examples that auto-labeled crawl data inevitably holds. The
+// zero value trains on the labels as they are.
+type NoiseConfig struct {
+	// LabelSmoothing moves this share (0 to 1; 0.1 is typical) of every
+	// example's target probability evenly onto all classes, so that a
+	// wrong label costs less than a confident mistake would.
+	LabelSmoothing float64 `json:"label_smoothing,omitempty"`
+	// TrimFraction leaves out this share (0 to 0.5) of the examples with
+	// the highest loss at every iteration after the first few, so labels
+	// the model cannot reconcile with the rest stop pulling on it.
+	TrimFraction float64 `json:"trim_fraction,omitempty"`
+}
+
+// targets returns the target distribution of an example of class y among
+// numClasses, smoothed by c.LabelSmoothing.
+func (c NoiseConfig) targets(y, numClasses int) []float64 {
+	t := make([]float64, numClasses)
+	for k := range t {
+		t[k] = c.LabelSmoothing / float64(numClasses)
+	}
+	t[y] += 1 - c.LabelSmoothing
+	return t
+}
+
+// crossEntropy is the loss of predicting probs for the target
+// distribution t.
+func crossEntropy(t, probs []float64) float64 {
+	loss := 0.0
+	for k, tk := range t {
+		if tk == 0 {
+			continue
+		}
+		if probs[k] > 0 {
+			loss -= tk * math.Log(probs[k])
+		} else {
+			loss += tk * 100
+		}
+	}
+	return loss
+}
+
+// trimWeights returns weights (nil meaning all 1) with those of the
+// fraction of examples with the highest losses set to 0. Ties go to the
+// earlier example so that trimming is deterministic.
+func trimWeights(losses, weights []float64, fraction float64) []float64 {
+	n := len(losses)
+	trimmed := make([]float64, n)
+	for j := range trimmed {
+		trimmed[j] = 1
+		if weights != nil {
+			trimmed[j] = weights[j]
+		}
+	}
+	order := make([]int, n)
+	for j := range order {
+		order[j] = j
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(losses[b], losses[a]) })
+	for _, j := range order[:int(fraction*float64(n))] {
+		trimmed[j] = 0
+	}
+	return trimmed
+}
@@ -0,0 +1,261 @@
+package classifier
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/vectorizer"
+)
+
+// ONNX constants used by ExportONNX. The model targets opset 13, which
+// every maintained ONNX runtime supports.
+const (
+	onnxIRVersion = 7
+	onnxOpset     = 13
+	onnxFloat     = 1 // TensorProto.FLOAT
+	onnxAttrInt   = 2 // AttributeProto.INT
+	onnxDimBatch  = "N"
+	onnxDimFields = "M"
+)
+
+// Features returns the feature vector the form model computes for form,
+// the form_features input of the graph written by ExportONNX.
+func (m *FormTypeModel) Features(form *goquery.Selection) vectorizer.SparseVector {
+	s := formScratchPool.Get().(*formScratch)
+	defer s.release()
+	return m.extractFeatures(form, s)
+}
+
+// ExportONNX writes the classifier's linear layers as an ONNX model so the
+// same weights can be served by other runtimes. Feature extraction stays
+// in dit: the graph starts from feature vectors.
+//
+//   - form_features [N, F] → form_proba [N, C]: the form type logistic
+//     regression, Gemm then Softmax, as FormTypeModel.ClassifyProba before
+//     calibration and thresholds. FormTypeModel.Features computes the input.
+//   - field_attributes [M, A] → field_emissions [M, L] and field_proba
+//     [M, L]: the CRF's per-field emission scores for the fields of one
+//     form, and their softmax, which approximates the CRF by ignoring
+//     label transitions. field_transitions [L, L] holds the transition
+//     weights for runtimes that decode with Viterbi themselves.
+//
+// Class, feature, label, and attribute names are stored in order as JSON
+// arrays in the metadata properties dit.form_classes, dit.form_features,
+// dit.field_labels, and dit.field_attributes. Weights are stored as
+// float32. Tree form models (see TrainFormTypeGBT) and ensembles (see
+// NewFormTypeEnsemble) cannot be exported.
+func (c *FormFieldClassifier) ExportONNX(w io.Writer) error {
+	m := c.FormModel
+	if m == nil {
+		return errors.New("classifier: no form model to export")
+	}
+	if m.Trees != nil {
+		return errors.New("classifier: tree form models cannot be exported to ONNX")
+	}
+	if len(m.Ensemble) > 0 {
+		return errors.New("classifier: form model ensembles cannot be exported to ONNX")
+	}
+	var graph, nodes, inits, inputs, outputs pbuf
+	numClasses := len(m.Classes)
+	numFeatures := 0
+	for _, p := range m.Pipelines {
+		numFeatures += pipelineDim(p)
+	}
+
+	coef := make([]float64, 0, numClasses*numFeatures)
+	for _, row := range m.Coef {
+		coef = append(coef, row...)
+	}
+	inits.msg(5, onnxTensor("form_coef", []int64{int64(numClasses), int64(numFeatures)}, coef))
+	inits.msg(5, onnxTensor("form_intercept", []int64{int64(numClasses)}, m.Intercept))
+	inputs.msg(11, onnxValueInfo("form_features", onnxDimBatch, int64(numFeatures)))
+	nodes.msg(1, onnxNode("Gemm", []string{"form_features", "form_coef", "form_intercept"}, "form_logits", onnxIntAttr("transB", 1)))
+	nodes.msg(1, onnxNode("Softmax", []string{"form_logits"}, "form_proba", onnxIntAttr("axis", 1)))
+	outputs.msg(12, onnxValueInfo("form_proba", onnxDimBatch, int64(numClasses)))
+
+	meta := map[string]any{
+		"dit.form_classes":  m.Classes,
+		"dit.form_features": formFeatureNames(m.Pipelines),
+	}
+
+	if c.FieldModel != nil && c.FieldModel.CRF != nil {
+		crfModel := c.FieldModel.CRF
+		numAttrs, numLabels := crfModel.Attributes.Size(), crfModel.NumLabels
+		offset := crfModel.TransOffset()
+		inits.msg(5, onnxTensor("field_state_weights", []int64{int64(numAttrs), int64(numLabels)}, crfModel.Weights[:offset]))
+		inits.msg(5, onnxTensor("field_transition_weights", []int64{int64(numLabels), int64(numLabels)}, crfModel.Weights[offset:offset+numLabels*numLabels]))
+		inputs.msg(11, onnxValueInfo("field_attributes", onnxDimFields, int64(numAttrs)))
+		nodes.msg(1, onnxNode("MatMul", []string{"field_attributes", "field_state_weights"}, "field_emissions"))
+		nodes.msg(1, onnxNode("Softmax", []string{"field_emissions"}, "field_proba", onnxIntAttr("axis", 1)))
+		nodes.msg(1, onnxNode("Identity", []string{"field_transition_weights"}, "field_transitions"))
+		outputs.msg(12, onnxValueInfo("field_emissions", onnxDimFields, int64(numLabels)))
+		outputs.msg(12, onnxValueInfo("field_proba", onnxDimFields, int64(numLabels)))
+		outputs.msg(12, onnxValueInfo("field_transitions", "", int64(numLabels), int64(numLabels)))
+		meta["dit.field_labels"] = crfModel.Labels.ToStr
+		meta["dit.field_attributes"] = crfModel.Attributes.ToStr
+	}
+
+	graph = append(graph, nodes...)
+	graph.str(2, "dit")
+	graph = append(graph, inits...)
+	graph = append(graph, inputs...)
+	graph = append(graph, outputs...)
+
+	var model pbuf
+	model.varint(1, onnxIRVersion)
+	model.str(2, "dit")
+	model.msg(7, graph)
+	var opset pbuf
+	opset.str(1, "")
+	opset.varint(2, onnxOpset)
+	model.msg(8, opset)
+	for _, key := range []string{"dit.form_classes", "dit.form_features", "dit.field_labels", "dit.field_attributes"} {
+		value, ok := meta[key]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var entry pbuf
+		entry.str(1, key)
+		entry.str(2, string(data))
+		model.msg(14, entry)
+	}
+	_, err := w.Write(model)
+	return err
+}
+
+// pipelineDim returns the number of features a serialized pipeline yields.
+func pipelineDim(p SerializedPipeline) int {
+	switch p.VecType {
+	case "dict":
+		return p.DictVec.VocabSize()
+	case "count":
+		return p.CountVec.VocabSize()
+	case "tfidf":
+		return p.TfidfVec.VocabSize()
+	}
+	return 0
+}
+
+// formFeatureNames names the form model's feature columns as
+// "<pipeline>: <feature>", in the order FormTypeModel.Features uses.
+func formFeatureNames(pipelines []SerializedPipeline) []string {
+	var names []string
+	for _, p := range pipelines {
+		column := make([]string, pipelineDim(p))
+		var vocab map[string]int
+		switch p.VecType {
+		case "dict":
+			copy(column, p.DictVec.FeatureNames)
+		case "count":
+			vocab = p.CountVec.Vocabulary
+		case "tfidf":
+			vocab = p.TfidfVec.CountVec.Vocabulary
+		}
+		for term, i := range vocab {
+			if i < len(column) {
+				column[i] = term
+			}
+		}
+		for _, name := range column {
+			names = append(names, p.Name+": "+name)
+		}
+	}
+	return names
+}
+
+func onnxTensor(name string, dims []int64, values []float64) pbuf {
+	var t pbuf
+	for _, d := range dims {
+		t.varint(1, uint64(d))
+	}
+	t.varint(2, onnxFloat)
+	t.str(8, name)
+	raw := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(float32(v)))
+	}
+	t.bytes(9, raw)
+	return t
+}
+
+// onnxValueInfo describes a float tensor input or output; a non-empty
+// batch names a leading dimension of any size.
+func onnxValueInfo(name, batch string, dims ...int64) pbuf {
+	var shape pbuf
+	if batch != "" {
+		var dim pbuf
+		dim.str(2, batch)
+		shape.msg(1, dim)
+	}
+	for _, d := range dims {
+		var dim pbuf
+		dim.varint(1, uint64(d))
+		shape.msg(1, dim)
+	}
+	var tensor pbuf
+	tensor.varint(1, onnxFloat)
+	tensor.msg(2, shape)
+	var typ pbuf
+	typ.msg(1, tensor)
+	var v pbuf
+	v.str(1, name)
+	v.msg(2, typ)
+	return v
+}
+
+func onnxNode(op string, inputs []string, output string, attrs ...pbuf) pbuf {
+	var n pbuf
+	for _, in := range inputs {
+		n.str(1, in)
+	}
+	n.str(2, output)
+	n.str(3, output)
+	n.str(4, op)
+	for _, a := range attrs {
+		n.msg(5, a)
+	}
+	return n
+}
+
+func onnxIntAttr(name string, v int64) pbuf {
+	var a pbuf
+	a.str(1, name)
+	a.varint(3, uint64(v))
+	a.varint(20, onnxAttrInt)
+	return a
+}
+
+// pbuf is an encoded protocol buffer message. ONNX files are protobuf;
+// the handful of fields ExportONNX writes do not justify a dependency.
+type pbuf []byte
+
+func (b *pbuf) key(field, wireType int) {
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|uint64(wireType))
+}
+
+func (b *pbuf) varint(field int, v uint64) {
+	b.key(field, 0)
+	*b = binary.AppendUvarint(*b, v)
+}
+
+func (b *pbuf) bytes(field int, v []byte) {
+	b.key(field, 2)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *pbuf) str(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *pbuf) msg(field int, m pbuf) {
+	b.bytes(field, m)
+}
@@ -0,0 +1,51 @@
+package classifier
+
+// OptionLimits bounds the <select> option features extracted per field.
+// Long option lists (countries, years) otherwise dominate the feature set.
+type OptionLimits struct {
+	// MaxOptions caps the options used per select; 0 means no limit.
+	// Larger selects are sampled deterministically: the first and last
+	// options are always kept and the rest are evenly spaced between them.
+	MaxOptions int `json:"max_options,omitempty"`
+	// MaxTextLen truncates each option text and value to this many runes;
+	// 0 means no limit.
+	MaxTextLen int `json:"max_text_len,omitempty"`
+}
+
+// SelectOptionLimits are the limits ElemFeatures and GetFormFeatures
+// apply, and so those field models are trained with. TrainFieldType
+// records them in the model, which classifies with its own limits
+// whatever SelectOptionLimits is later set to; models saved without them
+// predate the limits and use none.
+var SelectOptionLimits = OptionLimits{MaxOptions: 50, MaxTextLen: 40}
+
+// sampleIndices returns k evenly spaced indices in [0, n), including the
+// first and last, or all n indices if k is not below n.
+func sampleIndices(n, k int) []int {
+	if k <= 0 || n <= k {
+		k = n
+	}
+	idx := make([]int, k)
+	if k == 1 {
+		return idx
+	}
+	for i := range idx {
+		idx[i] = i * (n - 1) / (k - 1)
+	}
+	return idx
+}
+
+// truncateRunes shortens s to at most limit runes; limit <= 0 disables it.
+func truncateRunes(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	n := 0
+	for i := range s {
+		if n == limit {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
@@ -2,15 +2,16 @@ package classifier
 
 import (
 	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/vectorizer"
+	"github.com/happyhackingspace/dit/vectorizer"
 )
 
 // PageTypeModel holds a trained page type classifier.
 type PageTypeModel struct {
-	Classes   []string             `json:"classes"`
-	Coef      [][]float64          `json:"coef"`
-	Intercept []float64            `json:"intercept"`
-	Pipelines []SerializedPipeline `json:"pipelines"`
+	Classes     []string             `json:"classes"`
+	Coef        [][]float64          `json:"coef"`
+	Intercept   []float64            `json:"intercept"`
+	Pipelines   []SerializedPipeline `json:"pipelines"`
+	Calibration *Calibration         `json:"calibration,omitempty"`
 
 	// Runtime state (not serialized)
 	dictVecs  []*vectorizer.DictVectorizer
@@ -25,6 +26,10 @@ type PageTypeTrainConfig struct {
 	MaxIter      int
 	Verbose      bool
 	BalanceClass bool // use balanced class weights
+	// Vocab freezes the vocabulary as in FormTypeTrainConfig.Vocab.
+	Vocab []SerializedPipeline
+	// Noise makes training robust to mislabeled pages.
+	Noise NoiseConfig
 }
 
 // DefaultPageTypeTrainConfig returns default training config.
@@ -38,7 +43,13 @@ func DefaultPageTypeTrainConfig() PageTypeTrainConfig {
 
 // Classify returns the predicted page type.
 func (m *PageTypeModel) Classify(doc *goquery.Document, formResults []ClassifyResult) string {
-	proba := m.ClassifyProba(doc, formResults)
+	return m.ClassifyURL(doc, formResults, "")
+}
+
+// ClassifyURL is Classify for a page fetched from pageURL, whose path and
+// query feed the "page url" pipeline as they did in training.
+func (m *PageTypeModel) ClassifyURL(doc *goquery.Document, formResults []ClassifyResult, pageURL string) string {
+	proba := m.ClassifyProbaURL(doc, formResults, pageURL)
 	bestClass := ""
 	bestProb := -1.0
 	for cls, prob := range proba {
@@ -50,9 +61,15 @@ func (m *PageTypeModel) Classify(doc *goquery.Document, formResults []ClassifyRe
 	return bestClass
 }
 
-// ClassifyProba returns probabilities for each page type.
+// ClassifyProba returns probabilities for each page type, calibrated if
+// the model has a Calibration.
 func (m *PageTypeModel) ClassifyProba(doc *goquery.Document, formResults []ClassifyResult) map[string]float64 {
-	features := m.extractFeatures(doc, formResults)
+	return m.ClassifyProbaURL(doc, formResults, "")
+}
+
+// ClassifyProbaURL is ClassifyProba for a page fetched from pageURL.
+func (m *PageTypeModel) ClassifyProbaURL(doc *goquery.Document, formResults []ClassifyResult, pageURL string) map[string]float64 {
+	features := m.extractFeatures(doc, formResults, pageURL)
 
 	numClasses := len(m.Classes)
 	logits := make([]float64, numClasses)
@@ -65,15 +82,19 @@ func (m *PageTypeModel) ClassifyProba(doc *goquery.Document, formResults []Class
 	for c, cls := range m.Classes {
 		result[cls] = probs[c]
 	}
-	return result
+	return m.Calibration.Apply(result)
 }
 
 // extractFeatures runs all page pipelines and concatenates feature vectors.
-func (m *PageTypeModel) extractFeatures(doc *goquery.Document, formResults []ClassifyResult) vectorizer.SparseVector {
+// pageURL, if known, is given to the URL pipeline.
+func (m *PageTypeModel) extractFeatures(doc *goquery.Document, formResults []ClassifyResult, pageURL string) vectorizer.SparseVector {
 	pipelines := DefaultPageFeaturePipelines()
 	vectors := make([]vectorizer.SparseVector, len(pipelines))
 
 	for i, pipe := range pipelines {
+		if _, ok := pipe.Extractor.(PageURLExtractor); ok {
+			pipe.Extractor = PageURLExtractor{URL: pageURL}
+		}
 		switch m.vecTypes[i] {
 		case "dict":
 			feats := pipe.Extractor.ExtractDict(doc, formResults)
@@ -131,25 +152,26 @@ func TrainPageType(docs []*goquery.Document, formResults [][]ClassifyResult, url
 		// Inject URL into PageURLExtractor
 		extractor := pipe.Extractor
 
+		frozen := frozenPipeline(config.Vocab, pipe.Name, pipe.VecType)
 		switch pipe.VecType {
 		case "dict":
-			dv := vectorizer.NewDictVectorizer()
 			data := make([]map[string]any, len(docs))
 			for j, doc := range docs {
 				data[j] = extractor.ExtractDict(doc, formResults[j])
 			}
-			vecs := dv.FitTransform(data)
-			allVectors[i] = vecs
+			var dv *vectorizer.DictVectorizer
+			if frozen != nil {
+				dv = frozen.DictVec
+				allVectors[i] = transformAll(data, dv.Transform)
+			} else {
+				dv = vectorizer.NewDictVectorizer()
+				allVectors[i] = dv.FitTransform(data)
+			}
 			model.dictVecs[i] = dv
 			model.vecDims[i] = dv.VocabSize()
 			sp.DictVec = dv
 
 		case "tfidf":
-			stopWords := pipe.StopWords
-			if pipe.UseEnglishStop {
-				stopWords = vectorizer.EnglishStopWords()
-			}
-			tv := vectorizer.NewTfidfVectorizer(pipe.NgramRange, pipe.MinDF, pipe.Binary, pipe.Analyzer, stopWords)
 			corpus := make([]string, len(docs))
 			for j, doc := range docs {
 				// Handle URL extractor specially
@@ -159,8 +181,18 @@ func TrainPageType(docs []*goquery.Document, formResults [][]ClassifyResult, url
 					corpus[j] = extractor.ExtractString(doc, formResults[j])
 				}
 			}
-			vecs := tv.FitTransform(corpus)
-			allVectors[i] = vecs
+			var tv *vectorizer.TfidfVectorizer
+			if frozen != nil {
+				tv = frozen.TfidfVec
+				allVectors[i] = transformAll(corpus, tv.Transform)
+			} else {
+				stopWords := pipe.StopWords
+				if pipe.UseEnglishStop {
+					stopWords = vectorizer.EnglishStopWords()
+				}
+				tv = vectorizer.NewTfidfVectorizer(pipe.NgramRange, pipe.MinDF, pipe.Binary, pipe.Analyzer, stopWords)
+				allVectors[i] = tv.FitTransform(corpus)
+			}
 			model.tfidfVecs[i] = tv
 			model.vecDims[i] = tv.VocabSize()
 			sp.TfidfVec = tv
@@ -223,7 +255,7 @@ func TrainPageType(docs []*goquery.Document, formResults [][]ClassifyResult, url
 		}
 	}
 
-	coef, intercept := trainLogReg(xData, y, numClasses, totalDim, reg, config.MaxIter, sampleWeights)
+	coef, intercept := trainLogReg(xData, y, numClasses, totalDim, reg, config.MaxIter, sampleWeights, config.Noise)
 	model.Coef = coef
 	model.Intercept = intercept
 
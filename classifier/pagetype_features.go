@@ -5,7 +5,7 @@ import (
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
 // PageFeatureExtractor extracts features from a page document.
@@ -129,7 +129,7 @@ func (e FormTypeSummaryExtractor) ExtractDict(_ *goquery.Document, formResults [
 	// Per-type boolean features
 	knownTypes := []string{
 		"login", "registration", "search", "password/login recovery",
-		"contact/comment", "mailing list", "order/checkout", "other",
+		"contact/comment", "mailing list", "order/checkout", "mfa", "other",
 	}
 	for _, tp := range knownTypes {
 		key := "has_" + strings.ReplaceAll(strings.ReplaceAll(tp, "/", "_"), " ", "_") + "_form"
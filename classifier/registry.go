@@ -0,0 +1,80 @@
+package classifier
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// extractorRegistry maps the extractor_type names saved in models to form
+// feature extractors, so that a loaded model can run the same pipelines it
+// was trained with.
+var extractorRegistry = struct {
+	sync.RWMutex
+	byName map[string]FormFeatureExtractor
+	byType map[reflect.Type]string
+}{
+	byName: make(map[string]FormFeatureExtractor),
+	byType: make(map[reflect.Type]string),
+}
+
+func init() {
+	for name, e := range map[string]FormFeatureExtractor{
+		"FormElements":   FormElements{},
+		"SubmitText":     SubmitText{},
+		"FormLinksText":  FormLinksText{},
+		"FormLabelText":  FormLabelText{},
+		"FormURL":        FormURL{},
+		"FormCSS":        FormCSS{},
+		"FormInputCSS":   FormInputCSS{},
+		"FormInputNames": FormInputNames{},
+		"FormInputTitle": FormInputTitle{},
+		"FormPosition":   FormPosition{},
+	} {
+		RegisterExtractor(name, e)
+	}
+}
+
+// RegisterExtractor makes a custom form feature extractor available under
+// name, for pipelines added with FormTypeTrainConfig.ExtraPipelines. The
+// name is saved with the model as the pipeline's extractor_type, so
+// register the extractor, typically from an init function, before training
+// or loading models that use it; a loaded model whose extractor is not
+// registered gets zeros for that pipeline. Extractors are told apart by
+// their dynamic type, so each type is registered once. RegisterExtractor
+// panics on an empty name, a nil extractor, or a name or type already
+// registered.
+func RegisterExtractor(name string, e FormFeatureExtractor) {
+	if name == "" || e == nil {
+		panic("classifier: RegisterExtractor needs a name and an extractor")
+	}
+	t := reflect.TypeOf(e)
+	extractorRegistry.Lock()
+	defer extractorRegistry.Unlock()
+	if _, dup := extractorRegistry.byName[name]; dup {
+		panic(fmt.Sprintf("classifier: extractor %q registered twice", name))
+	}
+	if other, dup := extractorRegistry.byType[t]; dup {
+		panic(fmt.Sprintf("classifier: extractor type %v already registered as %q", t, other))
+	}
+	extractorRegistry.byName[name] = e
+	extractorRegistry.byType[t] = name
+}
+
+// lookupExtractor returns the extractor registered under name.
+func lookupExtractor(name string) (FormFeatureExtractor, bool) {
+	extractorRegistry.RLock()
+	defer extractorRegistry.RUnlock()
+	e, ok := extractorRegistry.byName[name]
+	return e, ok
+}
+
+// extractorTypeName returns the name e is registered under, or "unknown".
+func extractorTypeName(e FormFeatureExtractor) string {
+	extractorRegistry.RLock()
+	defer extractorRegistry.RUnlock()
+	if name, ok := extractorRegistry.byType[reflect.TypeOf(e)]; ok {
+		return name
+	}
+	return "unknown"
+}
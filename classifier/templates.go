@@ -0,0 +1,111 @@
+package classifier
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FieldTemplate is a canonical sequence of field types that forms tend to
+// follow, such as username, password, remember-me checkbox.
+type FieldTemplate struct {
+	Name  string   `json:"name"`
+	Types []string `json:"types"`
+}
+
+// DefaultFieldTemplates are the sequences a TemplateMatcher uses when
+// none are given.
+var DefaultFieldTemplates = []FieldTemplate{
+	{Name: "login", Types: []string{"username", "password", "remember me checkbox"}},
+	{Name: "email login", Types: []string{"email", "password", "remember me checkbox"}},
+	{Name: "signup", Types: []string{"email", "password", "password confirmation"}},
+	{Name: "username signup", Types: []string{"username", "email", "password", "password confirmation"}},
+}
+
+// TemplateMatcher snaps near-miss field predictions to a FieldTemplate.
+// A run of consecutive fields (buttons are skipped) that matches a
+// template in all but MaxChanges positions has those positions changed
+// to the template type, provided the field model found the template type
+// almost as likely as its own choice.
+type TemplateMatcher struct {
+	Templates []FieldTemplate // nil means DefaultFieldTemplates
+	// Margin is how far below the predicted type's probability the
+	// template type's may be; 0 means 0.2.
+	Margin float64
+	// MaxChanges is the most fields one match may change; 0 means 1.
+	MaxChanges int
+}
+
+// Correction records a field type changed by a TemplateMatcher.
+type Correction struct {
+	Template string `json:"template"` // FieldTemplate.Name
+	Original string `json:"original"` // type the field model predicted
+}
+
+// Apply corrects fields in place. fields, probas, and elems are parallel,
+// in the order of htmlutil.GetFieldsToAnnotate; probas are the field
+// model's marginals. Fields corrected by one template are left alone by
+// the following ones.
+func (t *TemplateMatcher) Apply(fields []FieldResult, probas []map[string]float64, elems []*goquery.Selection) {
+	templates := t.Templates
+	if templates == nil {
+		templates = DefaultFieldTemplates
+	}
+	margin := cmp.Or(t.Margin, 0.2)
+	maxChanges := cmp.Or(t.MaxChanges, 1)
+
+	var slots []int
+	for i, elem := range elems {
+		if i < len(fields) && i < len(probas) && templateSlot(elem) {
+			slots = append(slots, i)
+		}
+	}
+
+	for _, tmpl := range templates {
+		n := len(tmpl.Types)
+		for start := 0; start+n <= len(slots); start++ {
+			window := slots[start : start+n]
+			var changes []int
+			for k, i := range window {
+				if fields[i].Type != tmpl.Types[k] {
+					changes = append(changes, k)
+				}
+			}
+			// Only near misses: an exact match needs nothing, and a match
+			// must keep a majority of the model's predictions.
+			if len(changes) == 0 || len(changes) > maxChanges || 2*len(changes) >= n {
+				continue
+			}
+			ok := true
+			for _, k := range changes {
+				i := window[k]
+				if fields[i].Correction != nil || probas[i][tmpl.Types[k]] < probas[i][fields[i].Type]-margin {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, k := range changes {
+				f := &fields[window[k]]
+				f.Correction = &Correction{Template: tmpl.Name, Original: f.Type}
+				f.Type = tmpl.Types[k]
+			}
+		}
+	}
+}
+
+// templateSlot reports whether a field takes part in template matching:
+// submit and other buttons sit between the fields of a sequence.
+func templateSlot(elem *goquery.Selection) bool {
+	if goquery.NodeName(elem) == "button" {
+		return false
+	}
+	switch strings.ToLower(elem.AttrOr("type", "")) {
+	case "submit", "button", "reset", "image":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package classifier
+
+import "sort"
+
+// defaultThreshold is the decision threshold for classes without a tuned one.
+const defaultThreshold = 0.5
+
+// TuneThresholds finds a per-class decision threshold from held-out class
+// probabilities and gold labels. Each class is treated one-vs-rest: with
+// minPrecision == 0 the threshold maximizes F1; otherwise it is the lowest
+// threshold reaching minPrecision (maximizing recall at that precision).
+// Classes that never occur in labels, or cannot reach minPrecision, get no
+// threshold.
+func TuneThresholds(probas []map[string]float64, labels []string, minPrecision float64) map[string]float64 {
+	classSet := make(map[string]bool)
+	for _, l := range labels {
+		classSet[l] = true
+	}
+
+	thresholds := make(map[string]float64)
+	for cls := range classSet {
+		if t, ok := tuneClassThreshold(probas, labels, cls, minPrecision); ok {
+			thresholds[cls] = t
+		}
+	}
+	return thresholds
+}
+
+// tuneClassThreshold scans candidate thresholds (the observed probabilities
+// of cls) from highest to lowest, tracking precision, recall, and F1 of
+// predicting cls whenever its probability reaches the threshold.
+func tuneClassThreshold(probas []map[string]float64, labels []string, cls string, minPrecision float64) (float64, bool) {
+	type scored struct {
+		prob     float64
+		positive bool
+	}
+	items := make([]scored, len(labels))
+	totalPositive := 0
+	for i, l := range labels {
+		items[i] = scored{prob: probas[i][cls], positive: l == cls}
+		if items[i].positive {
+			totalPositive++
+		}
+	}
+	if totalPositive == 0 {
+		return 0, false
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].prob > items[j].prob })
+
+	bestThreshold := 0.0
+	bestF1 := -1.0
+	found := false
+	tp, fp := 0, 0
+	for i, it := range items {
+		if it.positive {
+			tp++
+		} else {
+			fp++
+		}
+		// Only evaluate at the last item of a run of equal probabilities.
+		if i+1 < len(items) && items[i+1].prob == it.prob {
+			continue
+		}
+		precision := float64(tp) / float64(tp+fp)
+		recall := float64(tp) / float64(totalPositive)
+		if minPrecision > 0 {
+			if precision >= minPrecision {
+				bestThreshold = it.prob
+				found = true
+			}
+			continue
+		}
+		f1 := 0.0
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+		if f1 > bestF1 {
+			bestF1 = f1
+			bestThreshold = it.prob
+			found = true
+		}
+	}
+	return bestThreshold, found
+}
@@ -0,0 +1,162 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FormTypeTraining records the FormTypeTrainConfig a form model was
+// trained with, so that models can be trained the same way on other data;
+// see FormTypeModel.TrainConfig. Workers and Verbose do not change the
+// model and are not recorded.
+type FormTypeTraining struct {
+	C              float64            `json:"c,omitempty"`
+	MaxIter        int                `json:"max_iter,omitempty"`
+	ScalePipelines bool               `json:"scale_pipelines,omitempty"`
+	PipelineScales map[string]float64 `json:"pipeline_scales,omitempty"`
+	// ExtraPipelines are the config's ExtraPipelines, each extractor saved
+	// under its registered name.
+	ExtraPipelines []pipelineSpec `json:"extra_pipelines,omitempty"`
+	// Vocab names the pipelines whose vocabulary config.Vocab froze; the
+	// model's own pipelines of those names hold it.
+	Vocab []string    `json:"vocab,omitempty"`
+	Trees *GBTConfig  `json:"trees,omitempty"`
+	Noise NoiseConfig `json:"noise"`
+}
+
+// pipelineSpec is a FeaturePipeline as FormTypeTraining saves it.
+type pipelineSpec struct {
+	Name           string   `json:"name"`
+	Extractor      string   `json:"extractor"`
+	VecType        string   `json:"vec_type"`
+	NgramRange     [2]int   `json:"ngram_range"`
+	MinDF          int      `json:"min_df,omitempty"`
+	Binary         bool     `json:"binary,omitempty"`
+	Analyzer       string   `json:"analyzer,omitempty"`
+	StopWords      []string `json:"stop_words,omitempty"`
+	UseEnglishStop bool     `json:"use_english_stop,omitempty"`
+}
+
+// newFormTypeTraining records config for a model trained on pipelines,
+// the default ones followed by config.ExtraPipelines.
+func newFormTypeTraining(config FormTypeTrainConfig, pipelines []FeaturePipeline) *FormTypeTraining {
+	t := &FormTypeTraining{
+		C:              config.C,
+		MaxIter:        config.MaxIter,
+		ScalePipelines: config.ScalePipelines,
+		PipelineScales: config.PipelineScales,
+		Trees:          config.Trees,
+		Noise:          config.Noise,
+	}
+	for _, p := range config.ExtraPipelines {
+		t.ExtraPipelines = append(t.ExtraPipelines, pipelineSpec{
+			Name:           p.Name,
+			Extractor:      extractorTypeName(p.Extractor),
+			VecType:        p.VecType,
+			NgramRange:     p.NgramRange,
+			MinDF:          p.MinDF,
+			Binary:         p.Binary,
+			Analyzer:       p.Analyzer,
+			StopWords:      slices.Sorted(maps.Keys(p.StopWords)),
+			UseEnglishStop: p.UseEnglishStop,
+		})
+	}
+	for _, p := range pipelines {
+		if frozenPipeline(config.Vocab, p.Name, p.VecType) != nil {
+			t.Vocab = append(t.Vocab, p.Name)
+		}
+	}
+	return t
+}
+
+// TrainConfig returns the config m was trained with, for training models
+// the same way on other data, such as cross-validation folds. It fails for
+// models without a recorded config (ensembles, whose members each have
+// their own, and models saved before configs were recorded) and for extra
+// pipelines whose extractor is not registered.
+func (m *FormTypeModel) TrainConfig() (FormTypeTrainConfig, error) {
+	t := m.Training
+	if t == nil {
+		return FormTypeTrainConfig{}, errors.New("classifier: form model has no recorded training config; retrain it")
+	}
+	config := FormTypeTrainConfig{
+		C:              t.C,
+		MaxIter:        t.MaxIter,
+		ScalePipelines: t.ScalePipelines,
+		PipelineScales: t.PipelineScales,
+		Trees:          t.Trees,
+		Noise:          t.Noise,
+	}
+	for _, spec := range t.ExtraPipelines {
+		e, ok := lookupExtractor(spec.Extractor)
+		if !ok {
+			return FormTypeTrainConfig{}, fmt.Errorf("classifier: extractor %q of pipeline %s is not registered", spec.Extractor, spec.Name)
+		}
+		p := FeaturePipeline{
+			Name:           spec.Name,
+			Extractor:      e,
+			VecType:        spec.VecType,
+			NgramRange:     spec.NgramRange,
+			MinDF:          spec.MinDF,
+			Binary:         spec.Binary,
+			Analyzer:       spec.Analyzer,
+			UseEnglishStop: spec.UseEnglishStop,
+		}
+		if len(spec.StopWords) > 0 {
+			p.StopWords = make(map[string]bool, len(spec.StopWords))
+			for _, w := range spec.StopWords {
+				p.StopWords[w] = true
+			}
+		}
+		config.ExtraPipelines = append(config.ExtraPipelines, p)
+	}
+	for _, sp := range m.Pipelines {
+		if slices.Contains(t.Vocab, sp.Name) {
+			config.Vocab = append(config.Vocab, sp)
+		}
+	}
+	return config, nil
+}
+
+// Retrainer returns a function that trains form models on other data the
+// way m was trained: with m's TrainConfig, or for an ensemble, each member
+// with its own, weighted as in m. The models it returns carry the
+// Calibration of m and of its members, so their probabilities are
+// calibrated as m's are. It fails if any config cannot be recovered.
+func (m *FormTypeModel) Retrainer() (func(forms []*goquery.Selection, labels []string) *FormTypeModel, error) {
+	if len(m.Ensemble) == 0 {
+		config, err := m.TrainConfig()
+		if err != nil {
+			return nil, err
+		}
+		return func(forms []*goquery.Selection, labels []string) *FormTypeModel {
+			model := TrainFormType(forms, labels, config)
+			model.Calibration = m.Calibration
+			return model
+		}, nil
+	}
+
+	members := make([]func([]*goquery.Selection, []string) *FormTypeModel, len(m.Ensemble))
+	weights := make([]float64, len(m.Ensemble))
+	for i, member := range m.Ensemble {
+		train, err := member.Model.Retrainer()
+		if err != nil {
+			return nil, fmt.Errorf("ensemble member %d: %w", i, err)
+		}
+		members[i], weights[i] = train, member.Weight
+	}
+	return func(forms []*goquery.Selection, labels []string) *FormTypeModel {
+		models := make([]*FormTypeModel, len(members))
+		for i, train := range members {
+			models[i] = train(forms, labels)
+		}
+		// The weights are those of a valid ensemble, so this cannot fail.
+		model, _ := NewFormTypeEnsemble(models, weights)
+		model.Calibration = m.Calibration
+		return model
+	}, nil
+}
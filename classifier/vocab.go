@@ -0,0 +1,24 @@
+package classifier
+
+import "github.com/happyhackingspace/dit/vectorizer"
+
+// frozenPipeline returns the pipeline of vocab with the given name and
+// vectorizer type, or nil if vocab has none. Training reuses its fitted
+// vectorizer instead of fitting a new one.
+func frozenPipeline(vocab []SerializedPipeline, name, vecType string) *SerializedPipeline {
+	for i, sp := range vocab {
+		if sp.Name == name && sp.VecType == vecType {
+			return &vocab[i]
+		}
+	}
+	return nil
+}
+
+// transformAll vectorizes items with an already fitted vectorizer.
+func transformAll[T any](items []T, transform func(T) vectorizer.SparseVector) []vectorizer.SparseVector {
+	vecs := make([]vectorizer.SparseVector, len(items))
+	for i, item := range items {
+		vecs[i] = transform(item)
+	}
+	return vecs
+}
@@ -1,15 +1,17 @@
+// Command dit-collect is an alias for "dit collect", kept for existing
+// scripts.
 package main
 
 import (
 	"os"
 
-	"github.com/happyhackingspace/dit/internal/collect"
+	"github.com/happyhackingspace/dit/internal/cli"
 )
 
 var version = "dev"
 
 func main() {
-	if err := collect.New(version).Run(); err != nil {
+	if err := cli.New(version).RunArgs(cli.CollectArgs(os.Args[1:])); err != nil {
 		os.Exit(1)
 	}
 }
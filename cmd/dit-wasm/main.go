@@ -0,0 +1,68 @@
+//go:build js && wasm
+
+// Command dit-wasm is the dit form classifier compiled to WebAssembly for
+// browsers and Node.js. It registers a global __dit object that
+// wasm/dit.js wraps; see wasm/README.md.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/happyhackingspace/dit"
+)
+
+var c *dit.Classifier
+
+func main() {
+	js.Global().Set("__dit", js.ValueOf(map[string]any{
+		"load":         js.FuncOf(load),
+		"extractForms": js.FuncOf(extractForms),
+	}))
+	// Keep the Go runtime alive for calls from JavaScript.
+	select {}
+}
+
+// load takes the model.json contents, plain or gzipped, as a Uint8Array,
+// or nothing to use the embedded compact model. It returns an error
+// message, or null on success.
+func load(_ js.Value, args []js.Value) any {
+	var err error
+	if len(args) == 0 || args[0].IsUndefined() || args[0].IsNull() {
+		c, err = dit.LoadEmbedded()
+	} else {
+		data := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(data, args[0])
+		c, err = dit.LoadBytes(data)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return nil
+}
+
+// extractForms classifies the forms of an HTML string and returns the
+// results as JSON, in the format of dit.Classifier.ExtractForms, or an
+// object with an error message.
+func extractForms(_ js.Value, args []js.Value) any {
+	if c == nil {
+		return failure(dit.ErrNotInitialized)
+	}
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return failure(errors.New("extractForms: html must be a string"))
+	}
+	forms, err := c.ExtractForms(args[0].String())
+	if err != nil {
+		return failure(err)
+	}
+	out, err := json.Marshal(forms)
+	if err != nil {
+		return failure(err)
+	}
+	return string(out)
+}
+
+func failure(err error) js.Value {
+	return js.ValueOf(map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,124 @@
+// Command libdit builds dit as a C shared library for calling it from
+// Python, Ruby, and other languages with a C FFI:
+//
+//	go build -buildmode=c-shared -o libdit.so ./cmd/libdit
+//
+// The build also writes libdit.h. Every function returning char* returns a
+// JSON object, {"result": ...} on success or {"error": "..."} on failure,
+// that the caller must release with dit_free. Results are those of the
+// dit.Classifier methods of the same name. See python/ditclient/lib.py for
+// a ctypes binding.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/happyhackingspace/dit"
+)
+
+// classifiers holds the loaded classifiers by the handles given out to C.
+var classifiers = struct {
+	sync.Mutex
+	byHandle map[uintptr]*dit.Classifier
+	next     uintptr
+}{byHandle: make(map[uintptr]*dit.Classifier)}
+
+func main() {}
+
+// dit_load loads the model at path, or finds one as dit.New does if path
+// is empty, and returns {"result": handle} for the other functions.
+//
+//export dit_load
+func dit_load(path *C.char) *C.char {
+	var c *dit.Classifier
+	var err error
+	if p := C.GoString(path); p != "" {
+		c, err = dit.Load(p)
+	} else {
+		c, err = dit.New()
+	}
+	if err != nil {
+		return respond(nil, err)
+	}
+	classifiers.Lock()
+	classifiers.next++
+	h := classifiers.next
+	classifiers.byHandle[h] = c
+	classifiers.Unlock()
+	return respond(h, nil)
+}
+
+// dit_extract_forms classifies the forms in html.
+//
+//export dit_extract_forms
+func dit_extract_forms(handle C.uintptr_t, html *C.char) *C.char {
+	c, err := lookup(handle)
+	if err != nil {
+		return respond(nil, err)
+	}
+	return respond(c.ExtractForms(C.GoString(html)))
+}
+
+// dit_extract_page_type classifies the page type and forms of html.
+//
+//export dit_extract_page_type
+func dit_extract_page_type(handle C.uintptr_t, html *C.char) *C.char {
+	c, err := lookup(handle)
+	if err != nil {
+		return respond(nil, err)
+	}
+	return respond(c.ExtractPageType(C.GoString(html)))
+}
+
+// dit_close releases the classifier behind handle.
+//
+//export dit_close
+func dit_close(handle C.uintptr_t) {
+	classifiers.Lock()
+	delete(classifiers.byHandle, uintptr(handle))
+	classifiers.Unlock()
+}
+
+// dit_free releases a string returned by the other functions.
+//
+//export dit_free
+func dit_free(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+func lookup(handle C.uintptr_t) (*dit.Classifier, error) {
+	classifiers.Lock()
+	defer classifiers.Unlock()
+	c, ok := classifiers.byHandle[uintptr(handle)]
+	if !ok {
+		return nil, fmt.Errorf("libdit: invalid handle %d", handle)
+	}
+	return c, nil
+}
+
+// respond encodes result or err as a C string owned by the caller.
+func respond(result any, err error) *C.char {
+	var resp struct {
+		Result any    `json:"result,omitempty"`
+		Error  string `json:"error,omitempty"`
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Result = result
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return C.CString(string(data))
+}
@@ -157,6 +157,34 @@ func TestTrainSimple(t *testing.T) {
 	}
 }
 
+func TestTrainFrozenAttributes(t *testing.T) {
+	attrs := NewAlphabet()
+	attrs.Add("bias")
+	attrs.Add("word=hello")
+	attrs.Add("word=unused")
+
+	config := DefaultTrainerConfig()
+	config.MaxIterations = 10
+	config.Attributes = attrs
+	model := Train([]TrainingSequence{{
+		Features: []map[string]float64{
+			{"word=hello": 1.0, "bias": 1.0},
+			{"word=new": 1.0, "bias": 1.0},
+		},
+		Labels: []string{"A", "B"},
+	}}, config)
+
+	if model.Attributes != attrs {
+		t.Error("frozen attribute alphabet was replaced")
+	}
+	if model.Attributes.Get("word=new") >= 0 {
+		t.Error("attribute outside the frozen alphabet was added")
+	}
+	if got, want := len(model.Weights), model.NumWeights(); got != want || model.Attributes.Size() != 3 {
+		t.Errorf("weights = %d (want %d), attributes = %d (want 3)", got, want, model.Attributes.Size())
+	}
+}
+
 func TestModelSaveLoad(t *testing.T) {
 	model := NewModel()
 	model.Labels.Add("A")
@@ -13,6 +13,12 @@ type TrainerConfig struct {
 	AllPossibleTransitions bool
 	Epsilon                float64 // convergence threshold
 	Verbose                bool
+	// Logger receives training progress; nil discards it.
+	Logger *slog.Logger
+	// Attributes, if set, freezes the attribute alphabet (typically that
+	// of an earlier model): attributes outside it are ignored instead of
+	// added, so retraining only updates weights.
+	Attributes *Alphabet
 }
 
 // DefaultTrainerConfig returns default training config matching Formasaurus.
@@ -28,11 +34,18 @@ func DefaultTrainerConfig() TrainerConfig {
 
 // Train trains a CRF model on the given sequences using OWL-QN.
 func Train(sequences []TrainingSequence, config TrainerConfig) *Model {
+	log := config.Logger
+	if log == nil {
+		log = slog.New(slog.DiscardHandler)
+	}
 	model := NewModel()
 
 	// Build alphabets
 	model.Labels = BuildLabelAlphabet(sequences)
-	model.Attributes = BuildAttributeAlphabet(sequences)
+	model.Attributes = config.Attributes
+	if model.Attributes == nil {
+		model.Attributes = BuildAttributeAlphabet(sequences)
+	}
 	model.NumLabels = model.Labels.Size()
 
 	numWeights := model.NumWeights()
@@ -170,7 +183,7 @@ func Train(sequences []TrainingSequence, config TrainerConfig) *Model {
 			}
 		}
 
-		slog.Debug("CRF training iteration", "iteration", iter+1, "nll", nll)
+		log.Debug("CRF training iteration", "iteration", iter+1, "nll", nll)
 
 		// OWL-QN step
 		// Compute pseudo-gradient for L1
@@ -254,7 +267,7 @@ func Train(sequences []TrainingSequence, config TrainerConfig) *Model {
 		}, numWeights, config.C1)
 
 		if step == 0 {
-			slog.Warn("CRF line search failed, stopping")
+			log.Warn("CRF line search failed, stopping")
 			break
 		}
 
@@ -366,7 +379,7 @@ func Train(sequences []TrainingSequence, config TrainerConfig) *Model {
 			}
 		}
 		if maxGrad < config.Epsilon {
-			slog.Debug("CRF converged", "iteration", iter+1, "max_gradient", maxGrad)
+			log.Debug("CRF converged", "iteration", iter+1, "max_gradient", maxGrad)
 			break
 		}
 	}
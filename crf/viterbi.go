@@ -64,8 +64,14 @@ func Viterbi(stateScores, transScores [][]float64) ([]int, float64) {
 
 // Predict returns the best label sequence as strings.
 func (m *Model) Predict(features []map[string]float64) []string {
+	return m.PredictTrans(features, m.ComputeTransScores())
+}
+
+// PredictTrans is Predict with transScores from ComputeTransScores, so that
+// callers decoding many sequences compute the matrix once. transScores is
+// only read and may be shared between goroutines.
+func (m *Model) PredictTrans(features []map[string]float64, transScores [][]float64) []string {
 	stateScores := m.ComputeStateScores(features)
-	transScores := m.ComputeTransScores()
 	path, _ := Viterbi(stateScores, transScores)
 
 	labels := make([]string, len(path))
@@ -0,0 +1,82 @@
+package dit
+
+import (
+	"fmt"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// Disagreement is a page, form, or field that the model and the heuristic
+// rules (see Heuristic) type differently. A confident model overruling a
+// rule often points at a mislabeled training page, an unsure one at a
+// blind spot of the model.
+type Disagreement struct {
+	Kind                 string  `json:"kind"`            // "page", "form", or "field"
+	Form                 int     `json:"form"`            // index of the form on the page; -1 for the page
+	Field                string  `json:"field,omitempty"` // field name, for fields
+	Model                string  `json:"model"`           // type the model predicts
+	Probability          float64 `json:"probability"`     // model probability of Model
+	Heuristic            string  `json:"heuristic"`       // type the rules give
+	HeuristicProbability float64 `json:"heuristic_probability"`
+}
+
+// Disagreements classifies html, fetched from pageURL (or "" if unknown),
+// both with the model and with the heuristic rules, and returns where they
+// differ: the page type first if the model has a page classifier, then
+// each form's type followed by its fields' types, in document order.
+// Where the rules find no type ("other") they are not counted as
+// disagreeing, and forms whose classification failed are skipped. The
+// model types are read off one probability classification: form types
+// as the form model picks them, page and field types as the most
+// probable ones.
+func (c *Classifier) Disagreements(html, pageURL string) ([]Disagreement, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	return cached(c, fc, "disagreements:"+pageURL, html, func() ([]Disagreement, error) {
+		doc, _, err := loadPage(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		formProba, _, pageProba := fc.ExtractPageDoc(doc, pageURL, true, 0, true)
+		// ExtractPageDoc classifies with the model for the page's
+		// language, whose thresholds pick the form types.
+		formModel := fc.ForLanguage(htmlutil.DetectLanguage(doc.Selection)).FormModel
+		heuristicForms, heuristicPage := classifier.HeuristicExtractPage(doc, pageURL)
+
+		var out []Disagreement
+		add := func(kind string, form int, field, model, heuristic string, proba map[string]float64) {
+			if heuristic == "other" || heuristic == model {
+				return
+			}
+			out = append(out, Disagreement{
+				Kind: kind, Form: form, Field: field,
+				Model: model, Probability: proba[model],
+				Heuristic: heuristic, HeuristicProbability: proba[heuristic],
+			})
+		}
+		if fc.PageModel != nil {
+			pageType, _ := mostProbable(pageProba.Form)
+			add("page", -1, "", pageType, heuristicPage.Form, pageProba.Form)
+		}
+		for i, fr := range formProba {
+			if fr.Error != "" {
+				continue
+			}
+			rules := heuristicForms[i].Result
+			add("form", i, "", formModel.Predict(fr.Proba.Form), rules.Form, fr.Proba.Form)
+			fields := fr.Proba.FieldList
+			for j := range min(len(fields), len(rules.FieldList)) {
+				fieldType, _ := mostProbable(fields[j].Proba)
+				add("field", i, fields[j].Name, fieldType, rules.FieldList[j].Type, fields[j].Proba)
+			}
+		}
+		return out, nil
+	})
+}
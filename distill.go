@@ -0,0 +1,120 @@
+package dit
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/internal/storage"
+)
+
+// DistillConfig holds configuration for page model distillation.
+type DistillConfig struct {
+	MaxRules   int // maximum number of rules; 0 means 20
+	MinSupport int // pages a rule must cover; 0 means 3
+	// Folds is the number of cross-validation folds used to measure the
+	// rules on pages they were not distilled from; 0 means 5.
+	Folds int
+	// Logger receives warnings; nil keeps distillation silent.
+	Logger *slog.Logger
+}
+
+// DistillResult holds a distilled page rule set and how it compares with
+// the page model on the annotated pages.
+type DistillResult struct {
+	// Rules is distilled from all pages; save it with encoding/json and
+	// apply it with classifier.PageRules.Classify.
+	Rules *classifier.PageRules
+	Pages int
+	// ModelAccuracy, RulesAccuracy, and Fidelity (agreement of the rules
+	// with the page model) are measured on the same held-out folds: for
+	// each, a page model is trained with the default settings on the
+	// remaining pages and rules are distilled from it, so neither is
+	// scored on pages it learned from.
+	ModelAccuracy float64
+	RulesAccuracy float64
+	Fidelity      float64
+}
+
+// AccuracyDrop returns how much accuracy is lost by using the rules
+// instead of the page model.
+func (r *DistillResult) AccuracyDrop() float64 {
+	return r.ModelAccuracy - r.RulesAccuracy
+}
+
+// DistillPageRules distills the page type model into a small decision list
+// over its most telling features, for environments that cannot ship the
+// full model, and measures the accuracy it gives up on the annotated pages
+// in dataDir.
+func (c *Classifier) DistillPageRules(dataDir string, config *DistillConfig) (*DistillResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if fc.PageModel == nil {
+		return nil, ErrNoPageModel
+	}
+	if config == nil {
+		config = &DistillConfig{}
+	}
+	nFolds := 5
+	if config.Folds > 0 {
+		nFolds = config.Folds
+	}
+
+	pagesDir := filepath.Join(dataDir, "pages")
+	if _, err := os.Stat(filepath.Join(pagesDir, "index.json")); err != nil {
+		return nil, fmt.Errorf("%w in %s", ErrNoAnnotations, pagesDir)
+	}
+	opts := storage.DefaultIterOptions()
+	opts.Logger = config.Logger
+	annotations, err := storage.NewPageStorage(pagesDir).IterPageAnnotations(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	docs, formResults, urls, labels := extractPageTrainingData(annotations, fc.FormModel)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoAnnotations, pagesDir)
+	}
+
+	distillConfig := classifier.DistillConfig{MaxRules: config.MaxRules, MinSupport: config.MinSupport}
+	result := &DistillResult{
+		Rules: classifier.DistillPageModel(fc.PageModel, docs, formResults, distillConfig),
+		Pages: len(docs),
+	}
+
+	var modelCorrect, rulesCorrect, agree, evaluated int
+	for _, testIdx := range groupKFold(pageDomainGroups(annotations), nFolds) {
+		test := makeTestSet(len(docs), testIdx)
+		trainDocs, trainForms, trainURLs, trainLabels := filterPageByIndex(docs, formResults, urls, labels, test, false)
+		if len(trainDocs) == 0 {
+			continue
+		}
+		model := classifier.TrainPageType(trainDocs, trainForms, trainURLs, trainLabels, classifier.DefaultPageTypeTrainConfig())
+		rules := classifier.DistillPageModel(model, trainDocs, trainForms, distillConfig)
+		for _, i := range testIdx {
+			evaluated++
+			predicted := model.ClassifyURL(docs[i], formResults[i], urls[i])
+			ruled := rules.Classify(docs[i], formResults[i])
+			if predicted == labels[i] {
+				modelCorrect++
+			}
+			if ruled == labels[i] {
+				rulesCorrect++
+			}
+			if ruled == predicted {
+				agree++
+			}
+		}
+	}
+	if evaluated == 0 {
+		return result, nil
+	}
+	n := float64(evaluated)
+	result.ModelAccuracy = float64(modelCorrect) / n
+	result.RulesAccuracy = float64(rulesCorrect) / n
+	result.Fidelity = float64(agree) / n
+	return result, nil
+}
@@ -12,53 +12,206 @@
 //
 //	page, _ := c.ExtractPageType(htmlString)
 //	fmt.Println(page.Type) // "login"
+//
+// The package never writes to stdout or stderr and never touches the global
+// slog default; training and evaluation report progress only to the Logger
+// set in their configs.
 package dit
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
 // Classifier wraps the form and field type classification models.
+//
+// A Classifier is safe for concurrent use by multiple goroutines once
+// loaded; options must be applied before it is shared. Reload may run
+// alongside classification.
 type Classifier struct {
-	fc *classifier.FormFieldClassifier
+	model sync.RWMutex // guards fc, which Reload swaps
+	fc    *classifier.FormFieldClassifier
+	opts  []Option // applied again by Reload
+	cache Cache
+
+	mu        sync.Mutex
+	version   string                          // model content hash, computed lazily for cache keys
+	versionOf *classifier.FormFieldClassifier // models version was computed for
+}
+
+// FormInfo describes where a form sits on its page and where it submits,
+// for callers such as scanners that build follow-up requests.
+type FormInfo struct {
+	Index int `json:"index"` // position among the page's forms
+	// Action is the action attribute resolved against the page's <base
+	// href>; empty when the form submits to the page itself. Use
+	// ResolveAction to make it absolute.
+	Action string `json:"action,omitempty"`
+	Method string `json:"method"` // "GET", "POST", or "DIALOG"
+	ID     string `json:"id,omitempty"`
+	Class  string `json:"class,omitempty"`
+	// Hash is the form's content hash, the hex SHA-1 of its inner HTML,
+	// which also identifies it among the training annotations and
+	// across crawls; see htmlutil.FormHash.
+	Hash string `json:"hash"`
+}
+
+// ResolveAction makes Action absolute given the URL the page was fetched
+// from. A form without an action submits to pageURL itself.
+func (f *FormInfo) ResolveAction(pageURL string) error {
+	page, err := url.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("dit: resolve action: %w", err)
+	}
+	action, err := url.Parse(f.Action)
+	if err != nil {
+		return fmt.Errorf("dit: resolve action: %w", err)
+	}
+	f.Action = page.ResolveReference(action).String()
+	return nil
+}
+
+func newFormInfo(m classifier.FormMeta) FormInfo {
+	return FormInfo{Index: m.Index, Action: m.Action, Method: m.Method, ID: m.ID, Class: m.Class, Hash: m.Hash}
 }
 
 // FormResult holds the classification result for a single form.
 type FormResult struct {
+	FormInfo
 	Type   string            `json:"type"`
 	Fields map[string]string `json:"fields,omitempty"`
+	// FieldList holds the same predictions in document order, keeping
+	// every field even when several share a name.
+	FieldList []Field `json:"field_list,omitempty"`
+	// CSRFTokens are the hidden anti-forgery tokens the form submits,
+	// found by input name and the randomness of the value.
+	CSRFTokens []CSRFToken `json:"csrf_tokens,omitempty"`
+	// Error is set, and the type left empty, for a form whose
+	// classification panicked or exceeded the WithFormTimeout limit.
+	Error string `json:"error,omitempty"`
 }
 
 // FormResultProba holds probability-based classification results for a single form.
 type FormResultProba struct {
-	Type   map[string]float64            `json:"type"`
-	Fields map[string]map[string]float64 `json:"fields,omitempty"`
+	FormInfo
+	Type       map[string]float64            `json:"type"`
+	Fields     map[string]map[string]float64 `json:"fields,omitempty"`
+	FieldList  []FieldProba                  `json:"field_list,omitempty"`
+	CSRFTokens []CSRFToken                   `json:"csrf_tokens,omitempty"` // see FormResult.CSRFTokens
+	Error      string                        `json:"error,omitempty"`       // see FormResult.Error
+}
+
+// Field holds the predicted type of a single form field.
+type Field struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Element    *Element    `json:"element,omitempty"`    // markup of the field
+	Locator    *Locator    `json:"locator,omitempty"`    // set with WithLocators
+	Correction *Correction `json:"correction,omitempty"` // set when WithFieldTemplates changed Type
+}
+
+// FieldProba holds type probabilities for a single form field.
+type FieldProba struct {
+	Name    string             `json:"name"`
+	Type    map[string]float64 `json:"type"`
+	Element *Element           `json:"element,omitempty"`
+	Locator *Locator           `json:"locator,omitempty"`
+}
+
+// Element holds the markup of a classified field, so autofill code can
+// use it next to the predicted type without parsing the HTML again.
+type Element struct {
+	Tag          string `json:"tag"`            // "input", "select", "textarea", or "button"
+	Type         string `json:"type,omitempty"` // type attribute, lowercased; "text" for an input without one
+	Placeholder  string `json:"placeholder,omitempty"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+	Required     bool   `json:"required,omitempty"`
+	MaxLength    int    `json:"maxlength,omitempty"` // 0 if unset or invalid
+}
+
+// CSRFToken is a hidden anti-forgery token of a form, such as Django's
+// csrfmiddlewaretoken or Rails' authenticity_token, which a submission
+// must echo back.
+type CSRFToken struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Locator finds a classified field in the live DOM, for automation such as
+// Playwright or chromedp scripts that need more than the field name.
+type Locator struct {
+	CSS        string `json:"css"`         // selector matching only this field
+	XPath      string `json:"xpath"`       // absolute XPath
+	FormIndex  int    `json:"form_index"`  // position of the form among the page's forms
+	FieldIndex int    `json:"field_index"` // position within the form's classified fields
+}
+
+// Correction flags a field type snapped to a canonical field sequence by
+// WithFieldTemplates.
+type Correction struct {
+	Template string `json:"template"` // e.g. "login" or "signup"
+	Original string `json:"original"` // type the field model predicted
 }
 
 // PageResult holds the page type classification result.
 type PageResult struct {
 	Type  string       `json:"type"`
 	Forms []FormResult `json:"forms,omitempty"`
+	// BotChallenge is set for a bot-protection or WAF interstitial, such
+	// as a Cloudflare or Akamai challenge, served in place of the page
+	// requested. Type is then the model's reading of the interstitial.
+	BotChallenge bool `json:"bot_challenge,omitempty"`
+	// Parked is set for a parked or for-sale domain, a placeholder page
+	// with ads or a sale offer that scanners can skip.
+	Parked bool `json:"parked,omitempty"`
+	// Heuristic is set for a result of Heuristic, typed by built-in rules
+	// rather than a trained model and so much less reliable.
+	Heuristic bool `json:"heuristic,omitempty"`
 }
 
 // PageResultProba holds probability-based page type classification results.
 type PageResultProba struct {
-	Type  map[string]float64 `json:"type"`
-	Forms []FormResultProba  `json:"forms,omitempty"`
+	Type         map[string]float64 `json:"type"`
+	Forms        []FormResultProba  `json:"forms,omitempty"`
+	BotChallenge bool               `json:"bot_challenge,omitempty"` // see PageResult
+	Parked       bool               `json:"parked,omitempty"`        // see PageResult
 }
 
 // New loads the classifier from "model.json", searching the current directory
-// and parent directories up to the module root, then ~/.dit/model.json.
-func New() (*Classifier, error) {
+// and parent directories up to the module root, then ~/.dit/model.json. If
+// none is found it falls back to the compact model embedded in the library,
+// if the build has one (see LoadEmbedded); an embedded model that fails to
+// load is reported rather than ErrModelNotFound.
+func New(opts ...Option) (*Classifier, error) {
+	return newClassifier(embeddedFiles, opts)
+}
+
+func newClassifier(embedded fs.FS, opts []Option) (*Classifier, error) {
 	path, err := FindModel("model.json")
+	if errors.Is(err, ErrModelNotFound) {
+		c, embeddedErr := loadEmbedded(embedded, opts...)
+		if !errors.Is(embeddedErr, ErrModelNotFound) {
+			return c, embeddedErr
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("dit: %w", err)
+		return nil, err
 	}
-	return Load(path)
+	return Load(path, opts...)
 }
 
 // ModelDir returns the default model storage directory (~/.dit).
@@ -72,6 +225,7 @@ func ModelDir() string {
 
 // FindModel searches for a model file by name.
 // Search order: current dir walk-up to module root, then ~/.dit/.
+// It returns ErrModelNotFound if no such file exists.
 func FindModel(name string) (string, error) {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -100,125 +254,405 @@ func FindModel(name string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("model.json not found")
+	return "", fmt.Errorf("%w: %s", ErrModelNotFound, name)
 }
 
-// Load loads a trained classifier from a model file.
-func Load(path string) (*Classifier, error) {
+// Load loads a trained classifier from a model file. It returns
+// ErrModelNotFound if the file does not exist, ErrModelVersion if it holds a
+// model from a newer or incompatible dit version, and ErrIncompatibleModel if
+// it does not hold a dit model.
+func Load(path string, opts ...Option) (*Classifier, error) {
 	fc, err := classifier.LoadClassifier(path)
-	if err != nil {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%w: %w", ErrModelNotFound, err)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, classifier.ErrFeatureSchema),
+		errors.Is(err, classifier.ErrModelFormat):
+		return nil, incompatibleModel(path, err)
+	case err != nil:
 		return nil, fmt.Errorf("dit: %w", err)
+	case fc.FormModel == nil:
+		return nil, fmt.Errorf("%w: %s has no form model", ErrIncompatibleModel, path)
+	}
+	c := &Classifier{fc: fc, opts: opts}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// LoadBytes loads a trained classifier from model data already in memory,
+// plain or gzipped, such as a model.json fetched over the network or
+// bundled with a WebAssembly build. It returns ErrIncompatibleModel, or
+// ErrModelVersion, if data does not hold a model this version can use.
+func LoadBytes(data []byte, opts ...Option) (*Classifier, error) {
+	return parseModel(data, "model data", opts)
+}
+
+// parseModel is LoadBytes with name identifying data in errors.
+func parseModel(data []byte, name string, opts []Option) (*Classifier, error) {
+	fc, err := classifier.ParseClassifier(data)
+	switch {
+	case err != nil:
+		return nil, incompatibleModel(name, err)
+	case fc.FormModel == nil:
+		return nil, fmt.Errorf("%w: %s has no form model", ErrIncompatibleModel, name)
+	}
+	c := &Classifier{fc: fc, opts: opts}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// ModelFormat is the model file format written by Save.
+const ModelFormat = classifier.ModelFormat
+
+// MigrateModel upgrades the model file at path to the current format in
+// place and returns the format it was in. Load migrates older models in
+// memory on every call; migrating the file once avoids that and lets older
+// dit versions fail clearly instead of misreading it. A model already in
+// the current format is left untouched.
+func MigrateModel(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("%w: %w", ErrModelNotFound, err)
+		}
+		return 0, fmt.Errorf("dit: %w", err)
+	}
+	_, from, err := classifier.MigrateModel(data)
+	if err != nil {
+		return from, incompatibleModel(path, err)
+	}
+	if from == ModelFormat {
+		return from, nil
+	}
+	c, err := Load(path)
+	if err != nil {
+		return from, err
+	}
+	return from, c.Save(path)
+}
+
+// WithLocators adds a Locator (CSS selector, XPath, form and field index)
+// to every field in FieldList, so callers can find the classified inputs in
+// the live page rather than by name alone.
+func WithLocators() Option {
+	return func(c *Classifier) {
+		c.fc.Locators = true
+	}
+}
+
+// WithFieldTemplates snaps field types to canonical sequences (username,
+// password, remember me checkbox; email, password, password confirmation;
+// and a few more) when the field model's prediction misses one position
+// and its probability for the template type is within margin of its
+// choice. Changed fields carry a Correction. margin 0 means 0.2. Only
+// ExtractForms and ExtractPageType results are corrected; probabilities are
+// reported as the model computed them.
+func WithFieldTemplates(margin float64) Option {
+	return func(c *Classifier) {
+		c.fc.Templates = &classifier.TemplateMatcher{Margin: margin}
+	}
+}
+
+// WithFormTimeout bounds the classification of each form. A form that takes
+// longer, or whose classification panics, is reported with FormResult.Error
+// instead of stalling or crashing the whole call. 0 means no limit; panics
+// are isolated either way. Go cannot interrupt the timed-out classification:
+// it runs to completion in the background, holding its CPU and memory, so
+// pick a timeout that pathological forms, not busy moments, exceed.
+func WithFormTimeout(d time.Duration) Option {
+	return func(c *Classifier) {
+		c.fc.FormTimeout = d
 	}
-	return &Classifier{fc: fc}, nil
 }
 
 // Save writes the classifier to a model file.
 func (c *Classifier) Save(path string) error {
-	if c.fc == nil {
-		return fmt.Errorf("dit: classifier not initialized")
+	fc := c.models()
+	if fc == nil {
+		return ErrNotInitialized
 	}
-	if err := c.fc.SaveModel(path); err != nil {
+	if err := fc.SaveModel(path); err != nil {
 		return fmt.Errorf("dit: %w", err)
 	}
 	return nil
 }
 
 // ExtractForms extracts and classifies all forms in the given HTML string.
-// Returns an empty slice (not nil) if no forms are found.
+// Returns an empty slice (not nil) if no forms are found, and an *InputError
+// (matching ErrNotHTML) for empty input or input such as JSON or a PDF.
 func (c *Classifier) ExtractForms(html string) ([]FormResult, error) {
-	if c.fc == nil || c.fc.FormModel == nil {
-		return nil, fmt.Errorf("dit: classifier not initialized")
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	return cached(c, fc, "forms", html, func() ([]FormResult, error) {
+		results, err := fc.ExtractForms(html, false, 0, true)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		return newFormResults(results), nil
+	})
+}
+
+// ExtractFormsReader is ExtractForms for HTML read from r, which is parsed
+// as it streams instead of being copied into a string first. Only the
+// first 512 bytes after any leading whitespace are sniffed for non-HTML
+// input. Results are not cached.
+func (c *Classifier) ExtractFormsReader(r io.Reader) ([]FormResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	br := bufio.NewReader(r)
+	// Leading whitespace does not change how a page parses, and a page
+	// indented by more than the sniffed bytes would look empty.
+	if err := skipSpace(br); err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	head, err := br.Peek(512)
+	switch {
+	case err == io.EOF:
+		// All of the input is in head.
+		err = checkHTML(string(head))
+	case err == nil, errors.Is(err, bufio.ErrBufferFull):
+		err = checkHTMLPrefix(string(head))
+	default:
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	results, err := c.fc.ExtractForms(html, false, 0, true)
+	results, err := fc.ExtractFormsFromReader(br, false, 0, true)
 	if err != nil {
 		return nil, fmt.Errorf("dit: %w", err)
 	}
+	return newFormResults(results), nil
+}
+
+// ExtractFormsDoc is ExtractForms for a page the caller has already
+// parsed, such as a crawler or proxy holding a goquery.Document, and
+// saves parsing it again. The document is only read. Results are not
+// cached.
+func (c *Classifier) ExtractFormsDoc(doc *goquery.Document) ([]FormResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if doc == nil {
+		return nil, &InputError{}
+	}
+	return newFormResults(fc.ExtractFormsDoc(doc, false, 0, true)), nil
+}
 
+// ClassifyForm classifies one form the caller picked out, for tools that
+// iterate forms themselves (only those inside a given container, say)
+// rather than extracting every form of the page. Only the first node of
+// form is used, and FormInfo.Index is its position among the document's
+// forms. Results are not cached.
+func (c *Classifier) ClassifyForm(form *goquery.Selection) (FormResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return FormResult{}, ErrNotInitialized
+	}
+	if form == nil || form.Length() == 0 {
+		return FormResult{}, &InputError{}
+	}
+	r := fc.ExtractForm(form.First(), false, 0, true)
+	return newFormResults([]classifier.FormResult{r})[0], nil
+}
+
+func newFormResults(results []classifier.FormResult) []FormResult {
 	out := make([]FormResult, len(results))
 	for i, r := range results {
-		out[i] = FormResult{
-			Type:   r.Result.Form,
-			Fields: r.Result.Fields,
-		}
+		out[i] = newFormResult(r.Result)
+		out[i].FormInfo = newFormInfo(r.Meta)
+		out[i].CSRFTokens = newCSRFTokens(r.CSRFTokens)
+		out[i].Error = r.Error
 	}
-	return out, nil
+	return out
 }
 
 // ExtractFormsProba extracts forms and returns classification probabilities.
 // Probabilities below threshold are omitted.
 func (c *Classifier) ExtractFormsProba(html string, threshold float64) ([]FormResultProba, error) {
-	if c.fc == nil || c.fc.FormModel == nil {
-		return nil, fmt.Errorf("dit: classifier not initialized")
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
 	}
-
-	results, err := c.fc.ExtractForms(html, true, threshold, true)
-	if err != nil {
-		return nil, fmt.Errorf("dit: %w", err)
+	if err := checkHTML(html); err != nil {
+		return nil, err
 	}
 
-	out := make([]FormResultProba, len(results))
-	for i, r := range results {
-		out[i] = FormResultProba{
-			Type:   r.Proba.Form,
-			Fields: r.Proba.Fields,
+	op := "forms-proba:" + strconv.FormatFloat(threshold, 'g', -1, 64)
+	return cached(c, fc, op, html, func() ([]FormResultProba, error) {
+		results, err := fc.ExtractForms(html, true, threshold, true)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
 		}
-	}
-	return out, nil
+
+		out := make([]FormResultProba, len(results))
+		for i, r := range results {
+			out[i] = newFormResultProba(r.Proba)
+			out[i].FormInfo = newFormInfo(r.Meta)
+			out[i].CSRFTokens = newCSRFTokens(r.CSRFTokens)
+			out[i].Error = r.Error
+		}
+		return out, nil
+	})
 }
 
 // ExtractPageType classifies the page type and all forms in the HTML.
 func (c *Classifier) ExtractPageType(html string) (*PageResult, error) {
-	if c.fc == nil || c.fc.FormModel == nil {
-		return nil, fmt.Errorf("dit: classifier not initialized")
+	return c.ExtractPageTypeURL(html, "")
+}
+
+// ExtractPageTypeURL is ExtractPageType for a page fetched from pageURL:
+// as in ExtractPageTypesBatch, the URL feeds the page model's URL
+// features, and form actions are resolved against it.
+func (c *Classifier) ExtractPageTypeURL(html, pageURL string) (*PageResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
 	}
-	if c.fc.PageModel == nil {
-		return nil, fmt.Errorf("dit: page model not available")
+	if fc.PageModel == nil {
+		return nil, ErrNoPageModel
 	}
-
-	formResults, pageResult, _, err := c.fc.ExtractPage(html, false, 0, true)
-	if err != nil {
-		return nil, fmt.Errorf("dit: %w", err)
+	if err := checkHTML(html); err != nil {
+		return nil, err
 	}
 
-	forms := make([]FormResult, len(formResults))
-	for i, r := range formResults {
-		forms[i] = FormResult{
-			Type:   r.Result.Form,
-			Fields: r.Result.Fields,
+	return cached(c, fc, "page:"+pageURL, html, func() (*PageResult, error) {
+		doc, junk, err := loadPage(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		formResults, pageResult, _ := fc.ExtractPageDoc(doc, pageURL, false, 0, true)
+		forms := newFormResults(formResults)
+		for i := range forms {
+			resolveFormAction(&forms[i].FormInfo, pageURL)
 		}
-	}
 
-	return &PageResult{
-		Type:  pageResult.Form,
-		Forms: forms,
-	}, nil
+		return &PageResult{
+			Type:         pageResult.Form,
+			Forms:        forms,
+			BotChallenge: junk == htmlutil.JunkBotChallenge,
+			Parked:       junk == htmlutil.JunkParked,
+		}, nil
+	})
 }
 
 // ExtractPageTypeProba classifies the page type with probabilities.
 func (c *Classifier) ExtractPageTypeProba(html string, threshold float64) (*PageResultProba, error) {
-	if c.fc == nil || c.fc.FormModel == nil {
-		return nil, fmt.Errorf("dit: classifier not initialized")
+	return c.ExtractPageTypeProbaURL(html, "", threshold)
+}
+
+// ExtractPageTypeProbaURL is ExtractPageTypeProba for a page fetched from
+// pageURL; see ExtractPageTypeURL.
+func (c *Classifier) ExtractPageTypeProbaURL(html, pageURL string, threshold float64) (*PageResultProba, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
 	}
-	if c.fc.PageModel == nil {
-		return nil, fmt.Errorf("dit: page model not available")
+	if fc.PageModel == nil {
+		return nil, ErrNoPageModel
 	}
-
-	formResults, _, pageProba, err := c.fc.ExtractPage(html, true, threshold, true)
-	if err != nil {
-		return nil, fmt.Errorf("dit: %w", err)
+	if err := checkHTML(html); err != nil {
+		return nil, err
 	}
 
-	forms := make([]FormResultProba, len(formResults))
-	for i, r := range formResults {
-		forms[i] = FormResultProba{
-			Type:   r.Proba.Form,
-			Fields: r.Proba.Fields,
+	op := "page-proba:" + strconv.FormatFloat(threshold, 'g', -1, 64) + ":" + pageURL
+	return cached(c, fc, op, html, func() (*PageResultProba, error) {
+		doc, junk, err := loadPage(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		formResults, _, pageProba := fc.ExtractPageDoc(doc, pageURL, true, threshold, true)
+
+		forms := make([]FormResultProba, len(formResults))
+		for i, r := range formResults {
+			forms[i] = newFormResultProba(r.Proba)
+			forms[i].FormInfo = newFormInfo(r.Meta)
+			forms[i].CSRFTokens = newCSRFTokens(r.CSRFTokens)
+			forms[i].Error = r.Error
+			resolveFormAction(&forms[i].FormInfo, pageURL)
+		}
+
+		return &PageResultProba{
+			Type:         pageProba.Form,
+			Forms:        forms,
+			BotChallenge: junk == htmlutil.JunkBotChallenge,
+			Parked:       junk == htmlutil.JunkParked,
+		}, nil
+	})
+}
+
+func newFormResult(r classifier.ClassifyResult) FormResult {
+	out := FormResult{
+		Type:   r.Form,
+		Fields: r.Fields,
+	}
+	if r.FieldList != nil {
+		out.FieldList = make([]Field, len(r.FieldList))
+		for i, f := range r.FieldList {
+			out.FieldList[i] = Field{Name: f.Name, Type: f.Type, Element: newElement(f.Element), Locator: newLocator(f.Locator), Correction: newCorrection(f.Correction)}
 		}
 	}
+	return out
+}
 
-	return &PageResultProba{
-		Type:  pageProba.Form,
-		Forms: forms,
-	}, nil
+func newCSRFTokens(tokens []htmlutil.CSRFToken) []CSRFToken {
+	if tokens == nil {
+		return nil
+	}
+	out := make([]CSRFToken, len(tokens))
+	for i, t := range tokens {
+		out[i] = CSRFToken(t)
+	}
+	return out
+}
+
+func newElement(e *classifier.Element) *Element {
+	if e == nil {
+		return nil
+	}
+	return (*Element)(e)
+}
+
+func newLocator(l *classifier.Locator) *Locator {
+	if l == nil {
+		return nil
+	}
+	return &Locator{CSS: l.CSS, XPath: l.XPath, FormIndex: l.FormIndex, FieldIndex: l.FieldIndex}
+}
+
+func newCorrection(c *classifier.Correction) *Correction {
+	if c == nil {
+		return nil
+	}
+	return &Correction{Template: c.Template, Original: c.Original}
+}
+
+func newFormResultProba(r classifier.ClassifyProbaResult) FormResultProba {
+	out := FormResultProba{
+		Type:   r.Form,
+		Fields: r.Fields,
+	}
+	if r.FieldList != nil {
+		out.FieldList = make([]FieldProba, len(r.FieldList))
+		for i, f := range r.FieldList {
+			out.FieldList[i] = FieldProba{Name: f.Name, Type: f.Proba, Element: newElement(f.Element), Locator: newLocator(f.Locator)}
+		}
+	}
+	return out
 }
@@ -1,13 +1,35 @@
 package dit
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/crf"
+	"github.com/happyhackingspace/dit/internal/storage"
 )
 
 const loginFormHTML = `<html><body>
@@ -20,6 +42,56 @@ const loginFormHTML = `<html><body>
 </form>
 </body></html>`
 
+// testAnnotations returns a few synthetic login and search form annotations.
+func testAnnotations() []storage.FormAnnotation {
+	return []storage.FormAnnotation{
+		{
+			FormHTML: `<input type="text" name="username"/><input type="password" name="password"/><input type="checkbox" name="remember"/><input type="submit" value="Log In"/>`,
+			TypeFull: "login",
+			FieldTypes: map[string]string{
+				"username": "username", "password": "password", "remember": "remember me checkbox",
+			},
+		},
+		{
+			FormHTML: `<input type="text" name="login"/><input type="password" name="pass"/><input type="submit" value="Sign in"/>`,
+			TypeFull: "login",
+			FieldTypes: map[string]string{
+				"login": "username", "pass": "password",
+			},
+		},
+		{
+			FormHTML: `<input type="search" name="q"/><input type="submit" value="Search"/>`,
+			TypeFull: "search",
+			FieldTypes: map[string]string{
+				"q": "search query",
+			},
+		},
+		{
+			FormHTML: `<input type="text" name="query"/><button type="submit">Find</button>`,
+			TypeFull: "search",
+			FieldTypes: map[string]string{
+				"query": "search query",
+			},
+		},
+	}
+}
+
+// newTestClassifier trains a small form and field model on synthetic
+// annotations so API tests do not depend on a downloaded model.json.
+func newTestClassifier(t *testing.T) *Classifier {
+	t.Helper()
+	annotations := testAnnotations()
+	forms, labels := extractFormTrainingData(annotations)
+	formModel := classifier.TrainFormType(forms, labels, classifier.DefaultFormTypeTrainConfig())
+	sequences, _ := buildCRFSequences(annotations)
+	fieldModel := classifier.TrainFieldType(sequences, crf.DefaultTrainerConfig())
+
+	return &Classifier{fc: &classifier.FormFieldClassifier{
+		FormModel:  formModel,
+		FieldModel: fieldModel,
+	}}
+}
+
 func buildBinary(t *testing.T) string {
 	t.Helper()
 	tmpDir := t.TempDir()
@@ -165,17 +237,3202 @@ func TestExtractFormsNoForms(t *testing.T) {
 	}
 }
 
+func TestExtractFormsReaderAndDoc(t *testing.T) {
+	c := newTestClassifier(t)
+	want, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.ExtractFormsReader(strings.NewReader(loginFormHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFormsReader = %+v, want %+v", got, want)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(loginFormHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.ExtractFormsDoc(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFormsDoc = %+v, want %+v", got, want)
+	}
+
+	got, err = c.ExtractFormsReader(strings.NewReader(strings.Repeat(" \n", 600) + loginFormHTML))
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractFormsReader(indented) = %+v, %v, want %+v", got, err, want)
+	}
+	longJSON := `{"forms": [` + strings.Repeat(`{"name": "login", "fields": ["username", "password"]}, `, 20) + `{}]}`
+	for _, input := range []string{`{"forms": []}`, longJSON, "  \n\t"} {
+		if _, err := c.ExtractFormsReader(strings.NewReader(input)); !errors.Is(err, ErrNotHTML) {
+			t.Errorf("ExtractFormsReader(%.20q) err = %v, want ErrNotHTML", input, err)
+		}
+	}
+	if _, err := c.ExtractFormsDoc(nil); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("ExtractFormsDoc(nil) err = %v, want ErrNotHTML", err)
+	}
+}
+
+func TestClassifyForm(t *testing.T) {
+	c := newTestClassifier(t)
+	page := `<html><body>
+		<form><input type="search" name="q"/></form>
+		<div id="account">` + loginFormHTML + `</div>
+	</body></html>`
+	all, err := c.ExtractForms(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.ClassifyForm(doc.Find("#account form"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, all[1]) {
+		t.Errorf("ClassifyForm = %+v, want %+v", got, all[1])
+	}
+	if got.Index != 1 {
+		t.Errorf("Index = %d, want 1", got.Index)
+	}
+	if _, err := c.ClassifyForm(doc.Find("#missing")); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("ClassifyForm(empty selection) err = %v, want ErrNotHTML", err)
+	}
+}
+
+func TestFieldClassifyBatch(t *testing.T) {
+	c := newTestClassifier(t)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(loginFormHTML + `
+		<form><input type="search" name="q"/><input type="submit" value="Search"/></form>
+		<form><p>no fields</p></form>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forms []*goquery.Selection
+	var formTypes []string
+	doc.Find("form").Each(func(_ int, form *goquery.Selection) {
+		forms = append(forms, form)
+		formTypes = append(formTypes, c.fc.FormModel.Classify(form))
+	})
+
+	batch := c.fc.FieldModel.ClassifyBatch(forms, formTypes)
+	if len(batch) != len(forms) {
+		t.Fatalf("ClassifyBatch returned %d results for %d forms", len(batch), len(forms))
+	}
+	for i, form := range forms {
+		if want := c.fc.FieldModel.ClassifyFields(form, formTypes[i]); !reflect.DeepEqual(batch[i], want) {
+			t.Errorf("form %d: ClassifyBatch = %+v, ClassifyFields = %+v", i, batch[i], want)
+		}
+	}
+}
+
+func TestExtractPageTypesBatch(t *testing.T) {
+	c := newTestClassifier(t)
+	if _, err := c.ExtractPageTypesBatch(nil); !errors.Is(err, ErrNoPageModel) {
+		t.Errorf("without a page model err = %v, want ErrNoPageModel", err)
+	}
+
+	var docs []*goquery.Document
+	var urls, labels []string
+	for i, label := range []string{"login", "blog", "login", "blog"} {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(fmt.Sprintf("<title>%s page</title>", label)))
+		docs = append(docs, doc)
+		urls = append(urls, fmt.Sprintf("https://example.com/%s/%d", label, i))
+		labels = append(labels, label)
+	}
+	c.fc.PageModel = classifier.TrainPageType(docs, make([][]classifier.ClassifyResult, len(docs)), urls, labels, classifier.DefaultPageTypeTrainConfig())
+	c.fc.PageModel.InitRuntime()
+
+	results, err := c.ExtractPageTypesBatch([]Page{
+		{URL: "https://example.com/account/login?next=/", HTML: loginFormHTML, StatusCode: 200},
+		{URL: "https://example.com/blog/1", HTML: `{"not": "html"}`, StatusCode: 404},
+		{URL: "https://example.com/blog/2", HTML: challengeHTML, StatusCode: 200},
+		{URL: "https://example.net/", HTML: parkedHTML, StatusCode: 200},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+
+	login := results[0]
+	if login.Error != "" || login.Type == "" || len(login.Forms) != 1 {
+		t.Fatalf("results[0] = %+v, want a classified page with one form", login)
+	}
+	if login.StatusCode != 200 || login.Host != "example.com" || login.URLFeatures == "" {
+		t.Errorf("results[0] metadata = %+v", login)
+	}
+	if got := login.Forms[0].Action; got != "https://example.com/login" {
+		t.Errorf("form action = %q, want it resolved against the page URL", got)
+	}
+	if results[1].Error == "" || results[1].StatusCode != 404 || results[1].URL != "https://example.com/blog/1" {
+		t.Errorf("results[1] = %+v, want an error with the page metadata", results[1])
+	}
+	if login.BotChallenge || !results[2].BotChallenge {
+		t.Errorf("BotChallenge = %v, %v; want only the challenge page flagged", login.BotChallenge, results[2].BotChallenge)
+	}
+	if login.Parked || results[2].Parked || !results[3].Parked || results[3].BotChallenge {
+		t.Errorf("Parked = %v, %v, %v; want only the parked page flagged", login.Parked, results[2].Parked, results[3].Parked)
+	}
+
+	// A page whose markup says nothing is typed by its URL, as in a batch.
+	neutral := "<html><head><title>page</title></head><body><p>Welcome</p></body></html>"
+	for _, pageURL := range []string{"https://example.com/login/9", "https://example.com/blog/9"} {
+		batch, err := c.ExtractPageTypesBatch([]Page{{URL: pageURL, HTML: neutral}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := c.ExtractPageTypeURL(neutral, pageURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proba, err := c.ExtractPageTypeProbaURL(neutral, pageURL, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Split(pageURL, "/")[3]
+		if batch[0].Type != want || page.Type != want || proba.Type[want] < 0.5 {
+			t.Errorf("%s: batch type %q, ExtractPageTypeURL %q, ExtractPageTypeProbaURL %v; want %s", pageURL, batch[0].Type, page.Type, proba.Type, want)
+		}
+	}
+	if page, err := c.ExtractPageTypeURL(loginFormHTML, "https://example.com/account/login?next=/"); err != nil || page.Forms[0].Action != "https://example.com/login" {
+		t.Errorf("ExtractPageTypeURL form action = %+v, %v; want it resolved against the page URL", page, err)
+	}
+
+	if page, err := c.ExtractPageType(challengeHTML); err != nil || !page.BotChallenge {
+		t.Errorf("ExtractPageType(challenge) = %+v, %v; want BotChallenge", page, err)
+	}
+	if page, err := c.ExtractPageTypeProba(challengeHTML, 0); err != nil || !page.BotChallenge {
+		t.Errorf("ExtractPageTypeProba(challenge) = %+v, %v; want BotChallenge", page, err)
+	}
+	if page, err := c.ExtractPageType(loginFormHTML); err != nil || page.BotChallenge || page.Parked {
+		t.Errorf("ExtractPageType(login) = %+v, %v; want no BotChallenge or Parked", page, err)
+	}
+	if page, err := c.ExtractPageTypeProba(parkedHTML, 0); err != nil || !page.Parked {
+		t.Errorf("ExtractPageTypeProba(parked) = %+v, %v; want Parked", page, err)
+	}
+}
+
+// parkedHTML is a for-sale page from a domain parking service.
+const parkedHTML = `<!DOCTYPE html><html><head><title>example.net</title></head>
+<body><h1>example.net</h1><p>This domain may be for sale! Make an offer.</p>
+<script src="https://www.sedoparking.com/frmpark/example.net/js/park.js"></script></body></html>`
+
+// challengeHTML is a Cloudflare "Just a moment..." interstitial.
+const challengeHTML = `<!DOCTYPE html><html><head><title>Just a moment...</title></head>
+<body><div class="main-wrapper"><h1>example.com</h1>
+<p>Checking if the site connection is secure</p></div>
+<script>window._cf_chl_opt={cType: 'managed'};</script></body></html>`
+
+func TestClassifyStream(t *testing.T) {
+	c := newTestClassifier(t)
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.ClassifyStream(inR, outW)
+		_ = outW.Close()
+	}()
+	results := bufio.NewScanner(outR)
+	send := func(line string) PageBatchResult {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+		// Each result arrives before the next record is written.
+		if !results.Scan() {
+			t.Fatalf("no result for %s: %v", line, results.Err())
+		}
+		var result PageBatchResult
+		if err := json.Unmarshal(results.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	record, _ := json.Marshal(Page{URL: "https://example.com/account/", HTML: loginFormHTML})
+	login := send(string(record))
+	if login.Error != "" || len(login.Forms) != 1 || login.Forms[0].Type != "login" {
+		t.Errorf("login record = %+v, want one login form", login)
+	} else if got := login.Forms[0].Action; got != "https://example.com/login" {
+		t.Errorf("form action = %q, want it resolved against the record URL", got)
+	}
+	if _, err := io.WriteString(inW, "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if bad := send("not json"); !strings.Contains(bad.Error, "invalid record") {
+		t.Errorf("invalid record error = %q", bad.Error)
+	}
+	if pdf := send(`{"url": "https://example.com/a.pdf", "html": "%PDF-1.7"}`); pdf.URL != "https://example.com/a.pdf" || pdf.Error == "" {
+		t.Errorf("PDF record = %+v, want an error", pdf)
+	}
+
+	_ = inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if results.Scan() {
+		t.Errorf("unexpected extra result %s", results.Text())
+	}
+}
+
+// TestConcurrentExtractForms shares one Classifier across goroutines; run
+// it with -race (as CI does) to catch state leaking between predictions.
+func TestConcurrentExtractForms(t *testing.T) {
+	c := newTestClassifier(t)
+	pages := []string{
+		loginFormHTML,
+		`<form><input type="search" name="q"/><input type="submit" value="Search"/></form>`,
+		`<form><input type="text" name="query"/><button type="submit">Find</button></form>`,
+	}
+	want := make([][]FormResult, len(pages))
+	for i, page := range pages {
+		results, err := c.ExtractForms(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i] = results
+	}
+
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Go(func() {
+			for i := range 50 {
+				page := (g + i) % len(pages)
+				got, err := c.ExtractForms(pages[page])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !reflect.DeepEqual(got, want[page]) {
+					t.Errorf("goroutine %d: page %d = %+v, want %+v", g, page, got, want[page])
+					return
+				}
+				if _, err := c.ExtractFormsProba(pages[page], 0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
 func TestLoadNonExistent(t *testing.T) {
 	_, err := Load("nonexistent.json")
-	if err == nil {
-		t.Error("expected error for nonexistent model")
+	if !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("err = %v, want ErrModelNotFound", err)
+	}
+}
+
+func TestLoadBytes(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestClassifier(t)
+	for _, name := range []string{"model.json", "model.json.gz"} {
+		path := filepath.Join(dir, name)
+		if err := c.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadBytes(data)
+		if err != nil {
+			t.Fatalf("LoadBytes(%s): %v", name, err)
+		}
+		results, err := loaded.ExtractForms(loginFormHTML)
+		if err != nil || len(results) != 1 || results[0].Type != "login" {
+			t.Errorf("LoadBytes(%s) classifier: %+v, %v", name, results, err)
+		}
+	}
+	if _, err := LoadBytes([]byte("not json")); !errors.Is(err, ErrIncompatibleModel) {
+		t.Errorf("LoadBytes(garbage) err = %v, want ErrIncompatibleModel", err)
+	}
+}
+
+func TestLoadIncompatible(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, content string
+		version       bool
+	}{
+		{"garbage.json", "not json", false},
+		{"other.json", `{"something": "else"}`, false},
+		{"schema.json", `{"feature_schema": 999, "form_model": {}}`, true},
+		// A field model saved before the schema was recorded has schema 1.
+		{"unrecorded-schema.json", `{"form_model": {}, "field_model": {}}`, true},
+		{"format.json", `{"format": 99}`, true},
+	} {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if !errors.Is(err, ErrIncompatibleModel) {
+			t.Errorf("Load(%s) err = %v, want ErrIncompatibleModel", tt.name, err)
+		}
+		if errors.Is(err, ErrModelVersion) != tt.version {
+			t.Errorf("Load(%s) err = %v, want ErrModelVersion %v", tt.name, err, tt.version)
+		}
+	}
+}
+
+func TestMigrateModelInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := newTestClassifier(t).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if from, err := MigrateModel(path); err != nil || from != ModelFormat {
+		t.Fatalf("current model: from=%d err=%v", from, err)
+	}
+
+	// Strip the format field to get a model as saved before versioning.
+	data, _ := os.ReadFile(path)
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	delete(doc, "format")
+	data, _ = json.Marshal(doc)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := MigrateModel(path)
+	if err != nil || from != 0 {
+		t.Fatalf("legacy model: from=%d err=%v", from, err)
+	}
+	data, _ = os.ReadFile(path)
+	if !bytes.Contains(data, []byte(fmt.Sprintf(`"format": %d`, ModelFormat))) {
+		t.Error("migrated file does not record the current format")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("model directory has %d entries after migration, want only the model", len(entries))
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExtractForms(loginFormHTML); err != nil {
+		t.Error(err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"format": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); !errors.Is(err, ErrModelVersion) {
+		t.Errorf("newer format err = %v, want ErrModelVersion", err)
+	}
+	if _, err := MigrateModel(path); !errors.Is(err, ErrModelVersion) {
+		t.Errorf("MigrateModel newer format err = %v, want ErrModelVersion", err)
+	}
+}
+
+func TestFetchError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("download model: %w", &FetchError{URL: "https://example.com/", Err: cause})
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) || fetchErr.StatusCode != 0 || !errors.Is(err, cause) {
+		t.Errorf("err = %v, want a FetchError wrapping the cause", err)
+	}
+	err = &FetchError{URL: "https://example.com/", StatusCode: 404}
+	if err.Error() != "fetch https://example.com/: HTTP 404" {
+		t.Errorf("Error() = %q", err.Error())
 	}
 }
 
 func TestClassifierNotInitialized(t *testing.T) {
 	c := &Classifier{}
 	_, err := c.ExtractForms(loginFormHTML)
-	if err == nil {
-		t.Error("expected error for uninitialized classifier")
+	if !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("err = %v, want ErrNotInitialized", err)
+	}
+	if _, err := newTestClassifier(t).ExtractPageType(loginFormHTML); !errors.Is(err, ErrNoPageModel) {
+		t.Errorf("ExtractPageType err = %v, want ErrNoPageModel", err)
+	}
+}
+
+func TestExtractFormsIsolatesPanics(t *testing.T) {
+	c := newTestClassifier(t)
+	c.fc.FormModel.Coef = nil // classification of any form now panics
+	cache := NewMemoryCache(10)
+	WithCache(cache)(c)
+
+	results, err := c.ExtractForms(loginFormHTML + "<form><input name=q></form>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, r := range results {
+		if !strings.Contains(r.Error, "panic") || r.Type != "" {
+			t.Errorf("result %d = %+v, want a panic error", i, r)
+		}
+	}
+	// Failed forms may succeed on a retry, so their results are not cached.
+	if cache.Len() != 0 {
+		t.Errorf("cache holds %d results with failed forms, want none", cache.Len())
+	}
+
+	summary, err := c.Summarize(loginFormHTML + "<form><input name=q></form>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.FormCount != 2 || summary.FailedForms != 2 || len(summary.FormTypes) != 0 {
+		t.Errorf("summary = %+v, want two failed forms", summary)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("cache holds a summary with failed forms")
+	}
+}
+
+func TestExtractFormsRejectsNonHTML(t *testing.T) {
+	c := newTestClassifier(t)
+	tests := []struct {
+		name, input, detected string
+	}{
+		{"empty", "  \n", ""},
+		{"json", `{"forms": []}`, "application/json"},
+		{"pdf", "%PDF-1.7\n1 0 obj\n", "application/pdf"},
+		{"binary", "\x00\x01\x02\x03", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := c.ExtractForms(tt.input)
+			var inputErr *InputError
+			if !errors.As(err, &inputErr) || !errors.Is(err, ErrNotHTML) {
+				t.Fatalf("err = %v, want an InputError", err)
+			}
+			if inputErr.Detected != tt.detected {
+				t.Errorf("Detected = %q, want %q", inputErr.Detected, tt.detected)
+			}
+		})
+	}
+
+	for _, input := range []string{"<form><input name=q></form>", "just some text"} {
+		if _, err := c.ExtractForms(input); err != nil {
+			t.Errorf("ExtractForms(%q) = %v, want no error", input, err)
+		}
+	}
+}
+
+func TestExtractFormsFieldOrder(t *testing.T) {
+	c := newTestClassifier(t)
+
+	html := `<form>
+  <input type="password" name="password"/>
+  <input type="text" name="username"/>
+  <input type="radio" name="plan" value="a"/>
+  <input type="radio" name="plan" value="b"/>
+</form>`
+	results, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 form, got %d", len(results))
+	}
+
+	var names []string
+	for _, f := range results[0].FieldList {
+		names = append(names, f.Name)
+		if results[0].Fields[f.Name] == "" {
+			t.Errorf("field %q missing from Fields map", f.Name)
+		}
+	}
+	want := []string{"password", "username", "plan", "plan"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("field order = %v, want %v", names, want)
+	}
+
+	probaResults, err := c.ExtractFormsProba(html, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probaResults[0].FieldList) != len(want) {
+		t.Fatalf("expected %d proba fields, got %d", len(want), len(probaResults[0].FieldList))
+	}
+	for i, f := range probaResults[0].FieldList {
+		if f.Name != want[i] {
+			t.Errorf("proba field %d = %q, want %q", i, f.Name, want[i])
+		}
+	}
+}
+
+func TestExtractFormsLocators(t *testing.T) {
+	c := newTestClassifier(t)
+	html := `<html><body><form><input name="q"/></form>` + loginFormHTML[len("<html><body>"):]
+
+	results, _ := c.ExtractForms(html)
+	if results[1].FieldList[0].Locator != nil {
+		t.Error("locators set without WithLocators")
+	}
+
+	WithLocators()(c)
+	results, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := results[1].FieldList[1]
+	want := Locator{CSS: "#pass", XPath: "/html/body/form[2]/input[2]", FormIndex: 1, FieldIndex: 1}
+	if got.Name != "password" || got.Locator == nil || *got.Locator != want {
+		t.Errorf("password field = %+v, locator %+v, want %+v", got, got.Locator, want)
+	}
+
+	probas, _ := c.ExtractFormsProba(html, 0)
+	if loc := probas[0].FieldList[0].Locator; loc == nil || loc.CSS != "html > body > form:nth-of-type(1) > input" {
+		t.Errorf("proba locator = %+v", loc)
+	}
+	if loc := Precision(2).FormsProba(probas)[0].FieldList[0].Locator; loc == nil {
+		t.Error("rounding dropped the locator")
+	}
+}
+
+func TestExtractFormsElements(t *testing.T) {
+	c := newTestClassifier(t)
+	html := `<html><body><form action="/login" method="POST">
+<input name="username" placeholder=" Email or username " autocomplete="username" required maxlength="64"/>
+<input type="PASSWORD" name="password" autocomplete="current-password" maxlength="x"/>
+<select name="lang"><option>en</option></select>
+</form></body></html>`
+
+	results, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Element{
+		{Tag: "input", Type: "text", Placeholder: "Email or username", Autocomplete: "username", Required: true, MaxLength: 64},
+		{Tag: "input", Type: "password", Autocomplete: "current-password"},
+		{Tag: "select"},
+	}
+	fields := results[0].FieldList
+	if len(fields) != len(want) {
+		t.Fatalf("got %d fields, want %d", len(fields), len(want))
+	}
+	for i, f := range fields {
+		if f.Element == nil || *f.Element != want[i] {
+			t.Errorf("field %s element = %+v, want %+v", f.Name, f.Element, want[i])
+		}
+	}
+
+	probas, _ := c.ExtractFormsProba(html, 0)
+	if e := probas[0].FieldList[1].Element; e == nil || *e != want[1] {
+		t.Errorf("proba element = %+v, want %+v", e, want[1])
+	}
+	if e := Precision(2).FormsProba(probas)[0].FieldList[1].Element; e == nil {
+		t.Error("rounding dropped the element")
+	}
+}
+
+// formAnnotationHashes stores page as an annotated page and returns the
+// hashes of its form annotations, for checking that results join with the
+// training data.
+func formAnnotationHashes(t *testing.T, page string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	forms := strings.TrimSuffix(strings.Repeat(`"o", `, strings.Count(page, "<form")), ", ")
+	files := map[string]string{
+		"config.json": `{"form_types": {"types": [{"full": "other", "short": "o"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [], "NA_value": "XX", "skip_value": "--"}}`,
+		"index.json": `{"page.html": {"url": "http://example.com/", "forms": [` + forms + `]}}`,
+		"page.html":  page,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := storage.DefaultIterOptions()
+	opts.DropDuplicates = false
+	annotations, err := storage.NewStorage(dir).IterAnnotations(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashes []string
+	for _, a := range annotations {
+		hashes = append(hashes, a.Hash)
+	}
+	return hashes
+}
+
+func TestExtractFormsInfo(t *testing.T) {
+	c := newTestClassifier(t)
+	html := `<html><body><form id="q" class="search  big"><input name="q"/></form>` + loginFormHTML[len("<html><body>"):]
+
+	results, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FormInfo{
+		// The hash is the SHA-1 of the inner HTML, <input name="q"/>.
+		{Index: 0, Method: "GET", ID: "q", Class: "search big", Hash: "54c1e8fd9d447dd9479ad9fda5773ffee7ff6ec3"},
+		{Index: 1, Action: "/login", Method: "POST", Hash: formAnnotationHashes(t, html)[1]},
+	}
+	if hashes := formAnnotationHashes(t, html); len(hashes) != 2 || hashes[0] != want[0].Hash {
+		t.Errorf("annotation hashes = %v, want %s first", hashes, want[0].Hash)
+	}
+	for i, r := range results {
+		if r.FormInfo != want[i] {
+			t.Errorf("form %d info = %+v, want %+v", i, r.FormInfo, want[i])
+		}
+	}
+
+	probas, _ := c.ExtractFormsProba(html, 0)
+	if probas[1].FormInfo != want[1] {
+		t.Errorf("proba form info = %+v, want %+v", probas[1].FormInfo, want[1])
+	}
+
+	for i, wantAction := range []string{"https://example.com/account/settings", "https://example.com/login"} {
+		info := results[i].FormInfo
+		if err := info.ResolveAction("https://example.com/account/settings"); err != nil {
+			t.Fatal(err)
+		}
+		if info.Action != wantAction {
+			t.Errorf("form %d resolved action = %q, want %q", i, info.Action, wantAction)
+		}
+	}
+}
+
+func TestNewSubmitRequest(t *testing.T) {
+	html := `<html><body>
+<form id="q"><input type="search" name="q" value="old"/><input type="hidden" name="lang" value="en"/></form>
+<form action="/login" method="post">
+  <input type="hidden" name="csrf" value="tok"/>
+  <input type="text" name="user"/>
+  <input type="password" name="pass"/>
+  <input type="checkbox" name="remember" checked/>
+  <input type="checkbox" name="news" value="yes"/>
+  <input type="text" name="off" value="x" disabled/>
+  <select name="lang"><option value="en">English</option><option value="de" selected>Deutsch</option></select>
+  <input type="submit" name="go" value="Log In"/>
+  <button name="other">Other</button>
+</form>
+<form action="/upload" method="post" enctype="multipart/form-data"><input type="text" name="title"/></form>
+<form id="edit" action="/edit" method="get">
+  <input type="text" name="a" value="1"/>
+  <fieldset disabled>
+    <legend><input type="text" name="b" value="2"/></legend>
+    <input type="text" name="c" value="3"/>
+  </fieldset>
+  <input type="text" name="d" value="4" form="q"/>
+  <button type="submit" name="save" value="1" formaction="/save" formmethod="post">Save</button>
+  <button type="submit" name="drop" value="1" formaction="/drop">Delete</button>
+</form>
+<input type="text" name="e" value="5" form="edit"/>
+</body></html>`
+	ctx := context.Background()
+	page := "https://example.com/account/"
+
+	req, err := NewSubmitRequest(ctx, html, page, FormInfo{Index: 0, Method: "GET"}, map[string]string{"q": "shoes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodGet || req.URL.String() != "https://example.com/account/?q=shoes&lang=en&d=4" {
+		t.Errorf("GET request = %s %s", req.Method, req.URL)
+	}
+
+	req, err = NewSubmitRequest(ctx, html, page, FormInfo{Index: 1, Action: "/login", Method: "POST"}, map[string]string{"user": "alice", "pass": "s3cret!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || req.URL.String() != "https://example.com/login" {
+		t.Errorf("POST request = %s %s", req.Method, req.URL)
+	}
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"csrf": {"tok"}, "user": {"alice"}, "pass": {"s3cret!"}, "remember": {"on"}, "lang": {"de"}, "go": {"Log In"}}
+	if !reflect.DeepEqual(req.PostForm, want) {
+		t.Errorf("POST body = %v, want %v", req.PostForm, want)
+	}
+
+	req, err = NewSubmitRequest(ctx, html, page, FormInfo{Index: 2, Action: "/upload", Method: "POST"}, map[string]string{"title": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.MultipartForm.Value["title"]; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("multipart title = %v, want [hello]", got)
+	}
+
+	req, err = NewSubmitRequest(ctx, html, page, FormInfo{Index: 3, Action: "/edit", Method: "GET"}, map[string]string{"e": "x", "z": "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost || req.URL.String() != "https://example.com/save" {
+		t.Errorf("submitter request = %s %s, want POST https://example.com/save", req.Method, req.URL)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "a=1&b=2&save=1&e=x&z=y"; got != want {
+		t.Errorf("submitter body = %q, want %q", got, want)
+	}
+
+	if _, err := NewSubmitRequest(ctx, html, page, FormInfo{Index: 4, Method: "GET"}, nil); !errors.Is(err, ErrNoForms) {
+		t.Errorf("missing form error = %v, want ErrNoForms", err)
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	html := `<html><body><form action="/session" method="POST">
+<input type="hidden" name="authenticity_token" value="Zm9vYmFyYmF6cXV4">
+<input type="text" name="login"/><input type="password" name="password"/><input type="submit" name="commit" value="Sign in"/>
+</form></body></html>`
+	page, err := Heuristic(html, "https://example.com/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Heuristic || page.Type != "login" || len(page.Forms) != 1 {
+		t.Fatalf("Heuristic() = %+v, want a heuristic login page with one form", page)
+	}
+	form := page.Forms[0]
+	if form.Type != "login" || form.Fields["login"] != "username" || form.Fields["password"] != "password" {
+		t.Errorf("form = %+v", form)
+	}
+	if form.Action != "/session" || form.Method != "POST" || len(form.CSRFTokens) != 1 || form.FieldList[0].Element == nil {
+		t.Errorf("form info = %+v, tokens = %v", form.FormInfo, form.CSRFTokens)
+	}
+
+	var inputErr *InputError
+	if _, err := Heuristic(`{"not": "html"}`, ""); !errors.As(err, &inputErr) {
+		t.Errorf("Heuristic(JSON) err = %v, want InputError", err)
+	}
+}
+
+func TestFunctional_RunNoModel(t *testing.T) {
+	binary := buildBinary(t)
+	page := filepath.Join(t.TempDir(), "login.html")
+	if err := os.WriteFile(page, []byte(loginFormHTML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(binary, "run", "-s", "--no-model", page).Output()
+	if err != nil {
+		t.Fatalf("run --no-model failed: %v", err)
+	}
+	var result PageResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if !result.Heuristic || len(result.Forms) != 1 || result.Forms[0].Type != "login" {
+		t.Errorf("result = %+v, want a heuristic result with one login form", result)
+	}
+
+	if out, err := exec.Command(binary, "run", "-s", "--no-model", "--proba", page).CombinedOutput(); err == nil {
+		t.Errorf("--no-model --proba succeeded:\n%s", out)
+	}
+
+	// Without --no-model, only a missing model falls back to the
+	// heuristics; a model that is there but does not load is an error.
+	home := t.TempDir()
+	run := exec.Command(binary, "run", "-s", page)
+	run.Dir, run.Env = home, append(os.Environ(), "HOME="+home)
+	if err := os.MkdirAll(filepath.Join(home, ".dit"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".dit", "model.json"), []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run.CombinedOutput(); err == nil {
+		t.Errorf("run with a broken model succeeded:\n%s", out)
+	}
+}
+
+func TestExtractFormsCSRFTokens(t *testing.T) {
+	c := newTestClassifier(t)
+	html := `<html><body><form action="/login" method="POST">
+<input type="hidden" name="authenticity_token" value="Zm9vYmFyYmF6cXV4">
+<input type="text" name="username"/><input type="password" name="password"/>
+</form></body></html>`
+	want := []CSRFToken{{Name: "authenticity_token", Value: "Zm9vYmFyYmF6cXV4"}}
+
+	results, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results[0].CSRFTokens, want) {
+		t.Errorf("ExtractForms tokens = %+v, want %+v", results[0].CSRFTokens, want)
+	}
+	probas, _ := c.ExtractFormsProba(html, 0)
+	if !reflect.DeepEqual(probas[0].CSRFTokens, want) {
+		t.Errorf("ExtractFormsProba tokens = %+v, want %+v", probas[0].CSRFTokens, want)
+	}
+	if labeled := (Labels{}).Forms(results); !reflect.DeepEqual(labeled[0].CSRFTokens, want) {
+		t.Errorf("localized tokens = %+v, want %+v", labeled[0].CSRFTokens, want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	c := newTestClassifier(t)
+
+	html := `<html><body>
+<form action="/login" method="POST">
+  <input type="text" name="username"/>
+  <input type="password" name="password"/>
+  <input type="submit" value="Log In"/>
+  <div class="g-recaptcha"></div>
+</form>
+<a href="https://accounts.google.com/o/oauth2/auth">Sign in with Google</a>
+</body></html>`
+	summary, err := c.Summarize(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.FormCount != 1 {
+		t.Errorf("FormCount = %d, want 1", summary.FormCount)
+	}
+	if !summary.HasLogin || summary.FormTypes["login"] != 1 {
+		t.Errorf("expected one login form, got %v", summary.FormTypes)
+	}
+	if summary.HasSearch {
+		t.Error("unexpected search form")
+	}
+	if len(summary.SSOProviders) != 1 || summary.SSOProviders[0] != "google" {
+		t.Errorf("SSOProviders = %v", summary.SSOProviders)
+	}
+	if len(summary.Captchas) != 1 || summary.Captchas[0] != "recaptcha" {
+		t.Errorf("Captchas = %v", summary.Captchas)
+	}
+	if summary.DocsFrameworks != nil {
+		t.Errorf("DocsFrameworks = %v, want none", summary.DocsFrameworks)
+	}
+	if summary.Type != "" {
+		t.Errorf("Type = %q, want empty without page model", summary.Type)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	c := newTestClassifier(t)
+
+	html := `<html><body><form action="/login" method="POST">
+<input type="text" name="username"/><input type="password" name="password"/><input type="submit" value="Log In"/>
+</form></body></html>`
+	explained, err := c.Explain(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms, err := c.ExtractForms(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(explained) != 1 || explained[0].Type != forms[0].Type || explained[0].Action != "/login" {
+		t.Fatalf("Explain() = %+v, want the %s form at /login", explained, forms[0].Type)
+	}
+	top := explained[0].Classes[0]
+	if top.Type != forms[0].Type || len(top.Positive) == 0 || len(top.Positive) > ExplainTopFeatures {
+		t.Errorf("top class = %+v", top)
+	}
+	if f := top.Positive[0]; f.Pipeline == "" || f.Contribution <= 0 {
+		t.Errorf("top feature = %+v", f)
+	}
+
+	if _, err := new(Classifier).Explain(html); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Explain() without a model: err = %v, want ErrNotInitialized", err)
+	}
+}
+
+// newTestPageClassifier is newTestClassifier with a page model telling
+// "page not found" error pages from blog posts.
+func newTestPageClassifier(t *testing.T) *Classifier {
+	t.Helper()
+	c := newTestClassifier(t)
+	var docs []*goquery.Document
+	var urls, labels []string
+	for i, label := range []string{"error", "blog", "error", "blog"} {
+		html := "<title>Page not found</title><h1>404</h1><p>Sorry.</p>"
+		if label == "blog" {
+			html = "<title>Blog post</title><h1>Our news</h1><article>" + strings.Repeat("Some long story. ", 40) + "</article>"
+		}
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+		docs = append(docs, doc)
+		urls = append(urls, fmt.Sprintf("https://example.com/%s/%d", label, i))
+		labels = append(labels, label)
+	}
+	c.fc.PageModel = classifier.TrainPageType(docs, make([][]classifier.ClassifyResult, len(docs)), urls, labels, classifier.DefaultPageTypeTrainConfig())
+	c.fc.PageModel.InitRuntime()
+	return c
+}
+
+func TestDistillPageRules(t *testing.T) {
+	dir := t.TempDir()
+	pages := filepath.Join(dir, "pages")
+	if err := os.MkdirAll(pages, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.json": `{"page_types": {"types": [{"full": "error", "short": "er"}, {"full": "blog", "short": "bl"}], "NA_value": "X", "skip_value": "-"}}`,
+	}
+	index := make(map[string]map[string]string)
+	for i := range 8 {
+		name, label := fmt.Sprintf("p%d.html", i), "er"
+		files[name] = "<title>Page not found</title><h1>404</h1><p>Sorry.</p>"
+		if i%2 == 1 {
+			label = "bl"
+			files[name] = "<title>Blog post</title><h1>Our news</h1><article>" + strings.Repeat("Some long story. ", 40) + "</article>"
+		}
+		index[name] = map[string]string{"url": fmt.Sprintf("https://site%d.example/", i), "page_type": label}
+	}
+	data, _ := json.Marshal(index)
+	files["index.json"] = string(data)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(pages, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A page model with the labels swapped is wrong on every page, so an
+	// accuracy measured with it rather than on held-out folds would be 0.
+	c := newTestClassifier(t)
+	var docs []*goquery.Document
+	for i := range 8 {
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(files[fmt.Sprintf("p%d.html", i)]))
+		docs = append(docs, doc)
+	}
+	swapped := []string{"blog", "error", "blog", "error", "blog", "error", "blog", "error"}
+	c.fc.PageModel = classifier.TrainPageType(docs, make([][]classifier.ClassifyResult, len(docs)), make([]string, len(docs)), swapped, classifier.DefaultPageTypeTrainConfig())
+	c.fc.PageModel.InitRuntime()
+
+	result, err := c.DistillPageRules(dir, &DistillConfig{Folds: 4, MinSupport: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pages != 8 || result.Rules == nil {
+		t.Fatalf("result = %+v, want rules for 8 pages", result)
+	}
+	if result.ModelAccuracy < 0.75 || result.RulesAccuracy < 0.75 {
+		t.Errorf("model accuracy %v, rules accuracy %v, want both measured on held-out folds", result.ModelAccuracy, result.RulesAccuracy)
+	}
+}
+
+func TestExplainPage(t *testing.T) {
+	if _, err := newTestClassifier(t).ExplainPage("<title>x</title>", ""); !errors.Is(err, ErrNoPageModel) {
+		t.Errorf("without a page model err = %v, want ErrNoPageModel", err)
+	}
+
+	c := newTestPageClassifier(t)
+	html := "<title>Page not found</title><h1>404</h1><p>Sorry, that page is gone.</p>"
+	exp, err := c.ExplainPage(html, "https://example.com/error/9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Type != "error" || len(exp.Classes) != 2 || exp.Classes[0].Type != exp.Type {
+		t.Fatalf("ExplainPage() = %+v, want error first", exp)
+	}
+	reasons := make(map[string]bool)
+	for _, f := range exp.Classes[0].Positive {
+		if f.Reason == "" {
+			t.Errorf("feature %+v has no reason", f)
+		}
+		reasons[f.Reason] = true
+	}
+	for _, want := range []string{"title contains 'page not found'", "URL path matched 'error'"} {
+		if !reasons[want] {
+			t.Errorf("reasons = %v, want %q", slices.Sorted(maps.Keys(reasons)), want)
+		}
+	}
+}
+
+func TestFunctional_RunExplainPage(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.json")
+	if err := newTestPageClassifier(t).Save(modelPath); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "missing.html")
+	if err := os.WriteFile(page, []byte("<title>Page not found</title><h1>404</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(binary, "run", "-s", "--model", modelPath, "--explain-page", page).CombinedOutput()
+	if err != nil {
+		t.Fatalf("run --explain-page failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"Page type: error", "For:", "title contains 'page not found'", "Runners-up:"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	if out, err := exec.Command(binary, "run", "-s", "--model", modelPath, "--explain-page", "--format", "csv", page).CombinedOutput(); err == nil {
+		t.Errorf("--explain-page --format csv succeeded:\n%s", out)
+	}
+}
+
+func TestDisagreements(t *testing.T) {
+	if _, err := (&Classifier{}).Disagreements(loginFormHTML, ""); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("without a model err = %v, want ErrNotInitialized", err)
+	}
+
+	// The page model only knows error and blog pages, while the rules
+	// type a page with a login form as a login page.
+	c := newTestPageClassifier(t)
+	disagreements, err := c.Disagreements(loginFormHTML, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disagreements) == 0 {
+		t.Fatal("expected a page type disagreement")
+	}
+	page := disagreements[0]
+	if page.Kind != "page" || page.Form != -1 || page.Heuristic != "login" || (page.Model != "error" && page.Model != "blog") {
+		t.Errorf("disagreements[0] = %+v, want the page typed login by the rules", page)
+	}
+	if page.Probability <= 0 || page.HeuristicProbability != 0 {
+		t.Errorf("page probabilities = %v, %v, want the model's and 0 for a type it does not know", page.Probability, page.HeuristicProbability)
+	}
+	for _, d := range disagreements {
+		if d.Model == d.Heuristic || d.Heuristic == "other" {
+			t.Errorf("%+v is not a disagreement", d)
+		}
+	}
+}
+
+func TestFunctional_RunDisagreements(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.json")
+	if err := newTestPageClassifier(t).Save(modelPath); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "login.html")
+	if err := os.WriteFile(page, []byte(loginFormHTML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	review := filepath.Join(dir, "review.jsonl")
+
+	for range 2 {
+		if out, err := exec.Command(binary, "run", "-s", "--model", modelPath, "--disagreements", review, page).CombinedOutput(); err != nil {
+			t.Fatalf("run --disagreements failed: %v\n%s", err, out)
+		}
+	}
+	data, err := os.ReadFile(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) < 2 || len(lines)%2 != 0 {
+		t.Fatalf("review file has %d lines, want the same disagreements appended twice:\n%s", len(lines), data)
+	}
+	var first struct {
+		URL string `json:"url"`
+		Disagreement
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.URL != page || first.Kind != "page" || first.Heuristic != "login" {
+		t.Errorf("first review line = %s", lines[0])
+	}
+
+	if out, err := exec.Command(binary, "run", "-s", "--no-model", "--disagreements", review, page).CombinedOutput(); err == nil {
+		t.Errorf("--no-model --disagreements succeeded:\n%s", out)
+	}
+}
+
+func TestPrimaryForm(t *testing.T) {
+	c := newTestClassifier(t)
+
+	html := `<html><body>
+<header><form action="/login"><input type="text" name="username"/><input type="password" name="password"/></form></header>
+<main><form action="/login"><input type="text" name="username"/><input type="password" name="password"/><input type="checkbox" name="remember"/><input type="submit" value="Log In"/></form></main>
+<div style="display: none"><form action="/login"><input type="text" name="login"/><input type="password" name="pass"/><input type="submit" value="Sign in"/></form></div>
+</body></html>`
+	primary, err := c.PrimaryForm(html, "login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil {
+		t.Fatal("expected a primary login form")
+	}
+	if primary.Index != 1 {
+		t.Errorf("Index = %d, want 1 (main content form)", primary.Index)
+	}
+	if primary.Type != "login" {
+		t.Errorf("Type = %q, want login", primary.Type)
+	}
+	if primary.Score <= 0 || primary.Probability <= 0 {
+		t.Errorf("expected positive score and probability, got %v / %v", primary.Score, primary.Probability)
+	}
+
+	primary, err = c.PrimaryForm(html, "search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary != nil {
+		t.Errorf("expected no search form, got index %d", primary.Index)
+	}
+
+	if _, err := c.PrimaryForm("<p>No forms here</p>", "login"); !errors.Is(err, ErrNoForms) {
+		t.Errorf("err = %v, want ErrNoForms", err)
+	}
+}
+
+func TestPrimaryFormMatchesExtractForms(t *testing.T) {
+	c := newTestClassifier(t)
+	WithLocators()(c)
+
+	forms, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, err := c.PrimaryForm(loginFormHTML, forms[0].Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil {
+		t.Fatalf("no primary %s form", forms[0].Type)
+	}
+	if !reflect.DeepEqual(primary.FormResult, forms[0]) {
+		t.Errorf("PrimaryForm result = %+v, want the ExtractForms result %+v", primary.FormResult, forms[0])
+	}
+	if len(primary.FieldList) == 0 || primary.FieldList[0].Element == nil || primary.FieldList[0].Locator == nil {
+		t.Errorf("fields = %+v, want elements and locators", primary.FieldList)
+	}
+
+	// A panic classifying the chosen form's fields is reported, not raised.
+	c.fc.FieldModel.CRF = nil
+	primary, err = c.PrimaryForm(loginFormHTML, forms[0].Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || !strings.Contains(primary.Error, "panic") {
+		t.Errorf("PrimaryForm with a broken field model = %+v, want a panic error", primary)
+	}
+}
+
+func TestFieldGroups(t *testing.T) {
+	wpLogin := `<input type="text" name="log"/><input type="password" name="pwd"/><input type="checkbox" name="rememberme"/><input type="hidden" name="redirect_to"/>`
+	annotations := []storage.FormAnnotation{
+		{URL: "https://a.example.com/login", FormHTML: wpLogin},
+		{URL: "https://blog.other.org/wp-login.php", FormHTML: wpLogin},
+		{URL: "https://search.third.net/", FormHTML: `<input type="search" name="q"/>`},
+		{URL: "https://fourth.io/", FormHTML: `<input type="search" name="q"/>`},
+		{URL: "https://fourth.io/", FormHTML: `<input type="email" name="email"/>`},
+	}
+
+	groups := fieldGroups(annotations)
+	if groups[0] != groups[1] {
+		t.Error("forms sharing a template on different domains should share a group")
+	}
+	if groups[2] == groups[3] {
+		t.Error("small forms on different domains should not be grouped by template")
+	}
+	if groups[3] != groups[4] {
+		t.Error("forms on the same page should share a group")
+	}
+	if groups[0] == groups[2] {
+		t.Error("unrelated forms should be in different groups")
+	}
+
+	// Forms without a URL share no domain or page.
+	loginForm := `<input type="email" name="email"/><input type="password" name="password"/><input type="checkbox" name="remember"/>`
+	noURL := []storage.FormAnnotation{{FormHTML: `<input name="q"/>`}, {FormHTML: `<input name="email"/>`}}
+	if groups := fieldGroups(noURL); groups[0] == groups[1] {
+		t.Error("forms without a URL should not share a group")
+	}
+
+	// A template on more than maxTemplateDomains domains does not group.
+	var widespread []storage.FormAnnotation
+	for i := range maxTemplateDomains + 1 {
+		widespread = append(widespread, storage.FormAnnotation{URL: fmt.Sprintf("https://site%d.example/", i), FormHTML: loginForm})
+	}
+	if groups := fieldGroups(widespread); groups[0] == groups[1] {
+		t.Error("a template used on many sites should not join them into one group")
+	}
+}
+
+func TestGroupKFold(t *testing.T) {
+	// One group of 6 items and 6 groups of 1: the big group gets a fold
+	// of its own and the small ones are spread over the rest.
+	groups := []int{0, 0, 0, 0, 0, 0, 1, 2, 3, 4, 5, 6}
+	folds := groupKFold(groups, 3)
+	if len(folds) != 3 {
+		t.Fatalf("got %d folds, want 3", len(folds))
+	}
+	var sizes []int
+	for _, fold := range folds {
+		sizes = append(sizes, len(fold))
+	}
+	if !slices.Equal(sizes, []int{6, 3, 3}) {
+		t.Errorf("fold sizes = %v, want [6 3 3]", sizes)
+	}
+	if got := groupKFold([]int{0, 0, 1}, 5); len(got) != 2 {
+		t.Errorf("got %d folds for 2 groups, want 2", len(got))
+	}
+}
+
+func TestEvaluateEndToEnd(t *testing.T) {
+	annotations := testAnnotations()
+	for i := range annotations {
+		annotations[i].URL = fmt.Sprintf("https://site%d.example/", i)
+		annotations[i].FormAnnotated = true
+		annotations[i].FieldsAnnotated = true
+	}
+	// Not field-annotated: excluded from the joint evaluation.
+	annotations = append(annotations, storage.FormAnnotation{
+		URL:           "https://other.example/",
+		FormHTML:      `<input type="text" name="q"/>`,
+		TypeFull:      "search",
+		FormAnnotated: true,
+	})
+
+	var pages []storage.PageAnnotation
+	for i, typ := range []string{"login", "blog", "login", "blog"} {
+		pages = append(pages, storage.PageAnnotation{
+			URL:      fmt.Sprintf("https://page%d.example/%s", i, typ),
+			HTML:     fmt.Sprintf("<html><head><title>%s</title></head><body><h1>%s</h1></body></html>", typ, typ),
+			TypeFull: typ,
+		})
+	}
+
+	result := evaluateEndToEnd(annotations, pages, 2, []Option{WithFieldTemplates(0)})
+	if result == nil {
+		t.Fatal("expected end-to-end result")
+	}
+	if result.FormTotal != 4 || result.SequenceTotal != 4 {
+		t.Errorf("FormTotal = %d, SequenceTotal = %d, want 4", result.FormTotal, result.SequenceTotal)
+	}
+	if result.PageTotal != 4 {
+		t.Errorf("PageTotal = %d, want every held-out page", result.PageTotal)
+	}
+	if result.FieldTotal != 7 {
+		t.Errorf("FieldTotal = %d, want 7", result.FieldTotal)
+	}
+	if result.FieldAccuracy < 0 || result.FieldAccuracy > 1 {
+		t.Errorf("FieldAccuracy = %v out of range", result.FieldAccuracy)
+	}
+
+	if evaluateEndToEnd(annotations[4:], pages, 2, nil) != nil {
+		t.Error("expected nil result without field annotations")
+	}
+}
+
+func TestWithCache(t *testing.T) {
+	c := newTestClassifier(t)
+	cache := NewMemoryCache(2)
+	WithCache(cache)(c)
+
+	first, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("cache holds %d results, want 1", cache.Len())
+	}
+	second, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 1 || len(second) != 1 || second[0].Type != first[0].Type {
+		t.Errorf("cached result = %+v, want %+v", second, first)
+	}
+
+	// A different model version must not reuse the cached result.
+	c.fc.FormModel.Thresholds = map[string]float64{"login": 0.9}
+	c.resetModelVersion()
+	if _, err := c.ExtractForms(loginFormHTML); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExtractFormsProba(loginFormHTML, 0.05); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("cache holds %d results, want 2 after eviction", cache.Len())
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	cache, err := NewDiskCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Get("missing"); ok {
+		t.Error("Get on empty cache reported a hit")
+	}
+	cache.Set("key", []byte(`{"type":"login"}`))
+	data, ok := cache.Get("key")
+	if !ok || string(data) != `{"type":"login"}` {
+		t.Errorf("Get = %q, %v; want stored value", data, ok)
+	}
+}
+
+func TestLoadTaxonomy(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "forms"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"form_types": {"types": [{"full": "search", "short": "s"}, {"full": "login", "short": "l"}], "NA_value": "X", "skip_value": "-", "simplify_map": {"b": "l"}},
+		"field_types": {"types": [{"full": "password", "short": "pw"}], "NA_value": "XX", "skip_value": "--"}
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "forms", "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	taxonomy, err := LoadTaxonomy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := taxonomy.FormTypes
+	if len(forms.Types) != 2 || forms.Types[0] != (TypeLabel{Name: "login", Short: "l"}) {
+		t.Errorf("form types = %+v, want login first", forms.Types)
+	}
+	if forms.NAValue != "X" || forms.SimplifyMap["b"] != "l" {
+		t.Errorf("form type set = %+v", forms)
+	}
+	if taxonomy.PageTypes != nil {
+		t.Error("expected no page types without a page config")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pageConfig := `{"page_types": {"types": [{"full": "status page", "short": "sp", "url_patterns": ["/status"]}], "NA_value": "X", "skip_value": "-"}}`
+	if err := os.WriteFile(filepath.Join(dir, "pages", "config.json"), []byte(pageConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if taxonomy, err = LoadTaxonomy(dir); err != nil {
+		t.Fatal(err)
+	}
+	pages := taxonomy.PageTypes
+	if pages == nil || len(pages.Types) != 1 || pages.Types[0] != (TypeLabel{Name: "status page", Short: "sp"}) {
+		t.Fatalf("page types = %+v, want status page", pages)
+	}
+	if !reflect.DeepEqual(pages.URLPatterns, map[string][]string{"status page": {"/status"}}) {
+		t.Errorf("page URL patterns = %v, want /status for status page", pages.URLPatterns)
+	}
+
+	c := newTestClassifier(t)
+	modelTaxonomy, err := c.Taxonomy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modelTaxonomy.FormTypes.Types) != 2 || modelTaxonomy.FormTypes.Types[0].Name != "login" {
+		t.Errorf("model form types = %+v", modelTaxonomy.FormTypes.Types)
+	}
+	if modelTaxonomy.FieldTypes == nil || len(modelTaxonomy.FieldTypes.Types) == 0 {
+		t.Error("expected field types from the CRF model")
+	}
+}
+
+func TestLoadLabels(t *testing.T) {
+	labels, err := LoadLabels("fr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := []FormResult{{
+		Type:      "login",
+		Fields:    map[string]string{"user": "username", "x": "unknown type"},
+		FieldList: []Field{{Name: "user", Type: "username"}},
+	}}
+	got := labels.Forms(results)
+	if got[0].Type != "connexion" || got[0].Fields["user"] != "nom d'utilisateur" || got[0].FieldList[0].Type != "nom d'utilisateur" {
+		t.Errorf("localized result = %+v", got[0])
+	}
+	if got[0].Fields["x"] != "unknown type" {
+		t.Errorf("unknown label = %q, want unchanged", got[0].Fields["x"])
+	}
+	if results[0].Type != "login" {
+		t.Error("Forms modified its input")
+	}
+
+	page := labels.PageProba(&PageResultProba{Type: map[string]float64{"login": 0.9}})
+	if page.Type["connexion"] != 0.9 {
+		t.Errorf("localized page proba = %v", page.Type)
+	}
+
+	path := filepath.Join(t.TempDir(), "custom.json")
+	if err := os.WriteFile(path, []byte(`{"login": "giriş"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	custom, err := LoadLabels(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom.Label("login") != "giriş" {
+		t.Errorf("custom label = %q", custom.Label("login"))
+	}
+
+	if _, err := LoadLabels("xx"); err == nil {
+		t.Error("expected error for unknown locale")
+	}
+}
+
+func TestFunctional_RunStdinJSONL(t *testing.T) {
+	binary := buildBinary(t)
+	modelPath := filepath.Join(t.TempDir(), "model.json")
+	if err := newTestClassifier(t).Save(modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	input := fmt.Sprintf("{\"id\": 1, \"html\": %q}\n\nnot json\n{\"id\": \"b\"}\n", loginFormHTML)
+	cmd := exec.Command(binary, "run", "-s", "--stdin-jsonl", "--model", modelPath)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("JSONL mode failed: %v\nStderr: %s", err, stderr.String())
+	}
+
+	type response struct {
+		Index  int             `json:"index"`
+		ID     any             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  string          `json:"error"`
+	}
+	var responses []response
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var resp response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("invalid response line: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3 (blank line skipped)", len(responses))
+	}
+
+	var forms []FormResult
+	if err := json.Unmarshal(responses[0].Result, &forms); err != nil || len(forms) != 1 || forms[0].Type != "login" {
+		t.Errorf("response 1 = %s (%v), want one login form", responses[0].Result, err)
+	}
+	if responses[1].Error == "" {
+		t.Error("expected an error for the malformed line")
+	}
+	if responses[2].ID != "b" || responses[2].Error == "" {
+		t.Errorf("response 3 = %+v, want error echoing id b", responses[2])
+	}
+	for i, resp := range responses {
+		if resp.Index != i {
+			t.Errorf("response %d has index %d", i, resp.Index)
+		}
+	}
+}
+
+func TestFunctional_RunStdinJSONLJobs(t *testing.T) {
+	binary := buildBinary(t)
+	modelPath := filepath.Join(t.TempDir(), "model.json")
+	if err := newTestClassifier(t).Save(modelPath); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 40
+	var input strings.Builder
+	for i := range n {
+		fmt.Fprintf(&input, "{\"id\": %d, \"html\": %q}\n", i, loginFormHTML)
+	}
+	cmd := exec.Command(binary, "run", "-s", "--stdin-jsonl", "--jobs", "4", "--model", modelPath)
+	cmd.Stdin = strings.NewReader(input.String())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("JSONL mode failed: %v\nStderr: %s", err, stderr.String())
+	}
+
+	seen := make(map[int]bool)
+	dec := json.NewDecoder(&stdout)
+	for dec.More() {
+		var resp struct {
+			Index int    `json:"index"`
+			ID    int    `json:"id"`
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("invalid response line: %v", err)
+		}
+		if resp.Error != "" || resp.ID != resp.Index || seen[resp.Index] {
+			t.Errorf("unexpected response %+v", resp)
+		}
+		seen[resp.Index] = true
+	}
+	if len(seen) != n {
+		t.Errorf("got %d distinct responses, want %d", len(seen), n)
+	}
+}
+
+func TestFunctional_RunCSV(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.json")
+	if err := newTestClassifier(t).Save(modelPath); err != nil {
+		t.Fatal(err)
+	}
+	htmlPath := filepath.Join(dir, "login.html")
+	if err := os.WriteFile(htmlPath, []byte(loginFormHTML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(binary, "run", "-s", "--model", modelPath, "--format", "csv", htmlPath).Output()
+	if err != nil {
+		t.Fatalf("run --format csv failed: %v", err)
+	}
+	want := "url,page_type,form_index,form_type,field_name,field_type,probability\n" +
+		htmlPath + ",,0,login,username,username,\n" +
+		htmlPath + ",,0,login,password,password,\n"
+	if string(out) != want {
+		t.Errorf("csv output =\n%s\nwant\n%s", out, want)
+	}
+
+	out, err = exec.Command(binary, "run", "-s", "--model", modelPath, "--format", "csv", "--proba", htmlPath).Output()
+	if err != nil {
+		t.Fatalf("run --format csv --proba failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[1], htmlPath+",,0,login,,,") {
+		t.Errorf("first proba row = %q, want the most probable form type", lines[1])
+	}
+}
+
+func TestPrecisionStableOutput(t *testing.T) {
+	if got := Precision(3).Round(0.123456); got != 0.123 {
+		t.Errorf("Round = %v, want 0.123", got)
+	}
+	if got := Precision(-1).Round(0.123456); got != 0.123456 {
+		t.Errorf("negative precision Round = %v, want unchanged", got)
+	}
+
+	c := newTestClassifier(t)
+	encode := func() string {
+		forms, err := c.ExtractFormsProba(loginFormHTML, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(Precision(2).FormsProba(forms))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+	first := encode()
+	for range 5 {
+		if again := encode(); again != first {
+			t.Fatalf("output differs between runs:\n%s\n%s", first, again)
+		}
+	}
+
+	var forms []FormResultProba
+	if err := json.Unmarshal([]byte(first), &forms); err != nil {
+		t.Fatal(err)
+	}
+	for tp, p := range forms[0].Type {
+		if p != Precision(2).Round(p) {
+			t.Errorf("form type %s probability %v not rounded", tp, p)
+		}
+	}
+	if i, j := strings.Index(first, `"login"`), strings.Index(first, `"search"`); i < 0 || j < 0 || i > j {
+		t.Errorf("form type keys not in sorted order: %s", first)
+	}
+}
+
+// TestLibraryQuiet builds a program that only imports dit and checks that
+// training, evaluation, and classification write nothing to stderr, even
+// when a page annotation index is broken and would be warned about.
+func TestLibraryQuiet(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"forms/config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [{"full": "username", "short": "un"}, {"full": "password", "short": "pw"}, {"full": "search query", "short": "q"}], "NA_value": "XX", "skip_value": "--"}
+		}`,
+		"forms/index.json": `{
+			"a.html": {"url": "http://alpha.org/", "forms": ["l"], "visible_html_fields": [{"username": "un", "password": "pw"}]},
+			"b.html": {"url": "http://beta.net/", "forms": ["s"], "visible_html_fields": [{"q": "q"}]},
+			"c.html": {"url": "http://gamma.com/", "forms": ["l"], "visible_html_fields": [{"login": "un", "pass": "pw"}]},
+			"d.html": {"url": "http://delta.io/", "forms": ["s"], "visible_html_fields": [{"query": "q"}]}
+		}`,
+		"forms/a.html":     `<form><input type="text" name="username"/><input type="password" name="password"/><input type="submit" value="Log In"/></form>`,
+		"forms/b.html":     `<form><input type="search" name="q"/><input type="submit" value="Search"/></form>`,
+		"forms/c.html":     `<form><input type="text" name="login"/><input type="password" name="pass"/><input type="submit" value="Sign in"/></form>`,
+		"forms/d.html":     `<form><input type="text" name="query"/><button type="submit">Find</button></form>`,
+		"pages/index.json": `not json`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", "./testdata/quietlib", dir)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("quietlib failed: %v\nStderr: %s", err, stderr.String())
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("library wrote to stderr:\n%s", stderr.String())
+	}
+	var forms []FormResult
+	if err := json.Unmarshal(stdout.Bytes(), &forms); err != nil || len(forms) != 1 {
+		t.Errorf("stdout = %q (%v), want one form", stdout.String(), err)
+	}
+}
+
+func TestFunctional_CollectGenSeeds(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	domains := filepath.Join(dir, "domains.txt")
+	if err := os.WriteFile(domains, []byte("example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	seeds := filepath.Join(dir, "seeds.jsonl")
+
+	cmd := exec.Command(binary, "collect", "gen-seeds", "-s", "--domains", domains, "--output", seeds, "--types", "login")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect gen-seeds failed: %v\n%s", err, output)
+	}
+	data, err := os.ReadFile(seeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"url":"https://example.com/login","expected_type":"login"`) {
+		t.Errorf("seeds = %s, want a login seed for example.com", data)
+	}
+
+	// A page type defined only in the pages config.json.
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"page_types": {"types": [{"full": "status page", "short": "sp", "url_patterns": ["/status"]}], "NA_value": "X", "skip_value": "-"}}`
+	if err := os.WriteFile(filepath.Join(dir, "pages", "config.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(binary, "collect", "gen-seeds", "-s", "--domains", domains, "--output", seeds, "--data-folder", dir, "--types", "status page")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect gen-seeds failed: %v\n%s", err, output)
+	}
+	data, _ = os.ReadFile(seeds)
+	if strings.TrimSpace(string(data)) != `{"url":"https://example.com/status","expected_type":"status page"}` {
+		t.Errorf("seeds = %s, want one status page seed from config.json", data)
+	}
+}
+
+func TestFunctional_CollectFetchSkipsBotChallenge(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			fmt.Fprint(w, challengeHTML)
+			return
+		}
+		fmt.Fprintf(w, "<html><body><h1>Contact us</h1>%s</body></html>", padding)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	seeds := filepath.Join(dir, "seeds.jsonl")
+	lines := fmt.Sprintf("{\"url\": %q, \"expected_type\": \"lg\"}\n{\"url\": %q, \"expected_type\": \"ct\"}\n", srv.URL+"/login", srv.URL+"/contact")
+	if err := os.WriteFile(seeds, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("collect fetch failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "bot challenge page") {
+		t.Errorf("output does not report the challenge:\n%s", output)
+	}
+
+	var index map[string]struct {
+		URL string `json:"url"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 {
+		t.Fatalf("index = %+v, want only the contact page", index)
+	}
+	for _, entry := range index {
+		if entry.URL != srv.URL+"/contact" {
+			t.Errorf("collected %s, want only the contact page", entry.URL)
+		}
+	}
+}
+
+func TestFunctional_CollectFetchFilenames(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body><h1>Contact us</h1>%s</body></html>", padding)
+	}))
+	defer srv.Close()
+	hashName := func(rawURL string) string {
+		sum := sha256.Sum256([]byte(rawURL))
+		return "html/" + hex.EncodeToString(sum[:])[:20]
+	}
+
+	// Another URL already holds the name the contact page hashes to, and
+	// the about page is indexed under an older, MD5-based name.
+	dir := t.TempDir()
+	contact, about := srv.URL+"/contact", srv.URL+"/about"
+	taken, legacy := hashName(contact)+".html", "html/0123456789ab.html"
+	index := fmt.Sprintf(`{%q: {"url": "https://other.example/"}, %q: {"url": %q}}`, taken, legacy, about)
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pages", "index.json"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	seeds := filepath.Join(dir, "seeds.jsonl")
+	lines := fmt.Sprintf("{\"url\": %q, \"expected_type\": \"ct\"}\n{\"url\": %q, \"expected_type\": \"ab\"}\n", contact, about)
+	if err := os.WriteFile(seeds, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0", "--hash-digits", "8").CombinedOutput(); err == nil {
+		t.Errorf("--hash-digits 8 succeeded:\n%s", output)
+	}
+	if output, err := exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0").CombinedOutput(); err != nil {
+		t.Fatalf("collect fetch failed: %v\n%s", err, output)
+	}
+
+	var got map[string]struct {
+		URL string `json:"url"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		taken:                         "https://other.example/",
+		hashName(contact) + "-2.html": contact,
+		legacy:                        about,
+	}
+	if len(got) != len(want) {
+		t.Errorf("index = %+v, want %v", got, want)
+	}
+	for filename, url := range want {
+		if got[filename].URL != url {
+			t.Errorf("index[%s] = %q, want %q", filename, got[filename].URL, url)
+		}
+		if filename == taken {
+			continue
+		}
+		page, err := os.ReadFile(filepath.Join(dir, "pages", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header := "<!-- dit-url: " + url + " -->\n"; !strings.HasPrefix(string(page), header) {
+			t.Errorf("%s does not start with %q", filename, header)
+		}
+	}
+}
+
+func TestFunctional_CollectFetchMaintenance(t *testing.T) {
+	binary := buildBinary(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "<html><head><title>Down for maintenance</title></head><body><h1>We'll be back soon!</h1><p>Sorry for the inconvenience.</p></body></html>")
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "<html><body><h1>503 Service Temporarily Down</h1><p>The upstream server did not answer in time.</p></body></html>")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	seeds := filepath.Join(dir, "seeds.jsonl")
+	lines := fmt.Sprintf("{\"url\": %q, \"expected_type\": \"lg\"}\n{\"url\": %q, \"expected_type\": \"ct\"}\n", srv.URL+"/login", srv.URL+"/contact")
+	if err := os.WriteFile(seeds, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect fetch failed: %v\n%s", err, output)
+	}
+
+	var index map[string]struct {
+		URL      string `json:"url"`
+		PageType string `json:"page_type"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 {
+		t.Fatalf("index = %+v, want only the maintenance page", index)
+	}
+	for _, entry := range index {
+		if entry.URL != srv.URL+"/login" || entry.PageType != "mt" {
+			t.Errorf("collected %+v, want the login URL saved as mt", entry)
+		}
+	}
+}
+
+func TestFunctional_CollectFetchAuthenticated(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	var gotHeader, leaked string
+	// Another site, which a redirect leads to, must not see the credentials.
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-Test") + r.Header.Get("Cookie")
+		fmt.Fprintf(w, "<html><body><h1>Elsewhere</h1>%s</body></html>", padding)
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/out" {
+			http.Redirect(w, r, otherURL+"/", http.StatusFound)
+			return
+		}
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc123" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		gotHeader = r.Header.Get("X-Test")
+		fmt.Fprintf(w, "<html><head><title>Account settings</title></head><body><h1>Account settings</h1>%s</body></html>", padding)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	seeds := filepath.Join(dir, "seeds.jsonl")
+	lines := fmt.Sprintf("{\"url\": %q, \"expected_type\": \"settings\"}\n{\"url\": %q, \"expected_type\": \"ln\"}\n{\"url\": %q, \"expected_type\": \"ln\"}\n",
+		srv.URL+"/settings", srv.URL+"/out", strings.Replace(srv.URL, "https:", "http:", 1)+"/plain")
+	if err := os.WriteFile(seeds, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0", "--cookie", "session=abc123")
+	if output, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(output), "--auth-domain") {
+		t.Fatalf("collect fetch with --cookie but no --auth-domain: err = %v\n%s", err, output)
+	}
+
+	cmd = exec.Command(binary, "collect", "fetch", "--seed", seeds, "--data-folder", dir, "--delay", "0",
+		"--auth-domain", "127.0.0.1", "--cookie", "session=abc123", "--header", "X-Test: yes")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("collect fetch failed: %v\n%s", err, output)
+	}
+	if gotHeader != "yes" {
+		t.Errorf("X-Test header = %q, want yes", gotHeader)
+	}
+	if leaked != "" {
+		t.Errorf("credentials %q sent to another site after a redirect", leaked)
+	}
+	if !strings.Contains(string(output), "plain http") {
+		t.Errorf("output does not report the refused plain http request:\n%s", output)
+	}
+	var index map[string]struct {
+		URL string `json:"url"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	for path, entry := range index {
+		html, _ := os.ReadFile(filepath.Join(dir, "pages", path))
+		if entry.URL == srv.URL+"/settings" && !strings.Contains(string(html), "Account settings") {
+			t.Errorf("collected %s from %s, want the settings page", path, entry.URL)
+		}
+	}
+	if len(index) != 2 {
+		t.Errorf("index has %d pages, want the settings and redirected pages", len(index))
+	}
+}
+
+func TestFunctional_CollectReview(t *testing.T) {
+	binary := buildBinary(t)
+	dataDir := t.TempDir()
+	pages := filepath.Join(dataDir, "pages")
+	if err := os.MkdirAll(filepath.Join(pages, "html"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index := map[string]map[string]any{
+		"html/a.html": {"url": "https://a.example/login", "page_type": "lg", "pending": true},
+		"html/b.html": {"url": "https://b.example/", "page_type": "ln", "pending": true},
+		"html/c.html": {"url": "https://c.example/x", "page_type": "s4", "pending": true},
+		"html/d.html": {"url": "https://d.example/", "page_type": "ln"},
+	}
+	for name := range index {
+		if err := os.WriteFile(filepath.Join(pages, name), []byte("<title>"+name+"</title><p>hello</p>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(pages, "index.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Confirm a, relabel b, discard c.
+	cmd := exec.Command(binary, "collect", "review", "-s", "--data-folder", dataDir)
+	cmd.Stdin = strings.NewReader("\nrg\nd\n")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("collect review failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "title:   html/a.html") || !strings.Contains(string(output), "Confirmed 1, relabeled 1, discarded 1, 0 still pending") {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+
+	var got map[string]struct {
+		PageType string `json:"page_type"`
+		Pending  bool   `json:"pending"`
+	}
+	data, _ = os.ReadFile(filepath.Join(pages, "index.json"))
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got["html/a.html"].PageType != "lg" || got["html/b.html"].PageType != "rg" || got["html/a.html"].Pending || got["html/b.html"].Pending {
+		t.Errorf("index after review = %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(pages, "html/c.html")); !os.IsNotExist(err) {
+		t.Errorf("discarded page still on disk: %v", err)
+	}
+}
+
+func TestFunctional_DataSimilar(t *testing.T) {
+	binary := buildBinary(t)
+	dataDir := t.TempDir()
+	login := `<form action="/login"><label>Email</label><input type="email" name="email"/><input type="password" name="password"/><button>Sign in</button></form>`
+	files := map[string]string{
+		"forms/config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [], "NA_value": "XX", "skip_value": "--"}
+		}`,
+		"forms/index.json": `{
+			"login.html": {"url": "http://a.example/", "forms": ["l"]},
+			"search.html": {"url": "http://b.example/", "forms": ["s", "X"]}
+		}`,
+		"forms/login.html":  login,
+		"forms/search.html": `<form><input type="search" name="q"/><button>Search</button></form><form><input name="newsletter"/></form>`,
+		"query.html":        `<html><body>` + login + `<form><input type="search" name="q"/><button>Go</button></form></body></html>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := filepath.Join(dataDir, "query.html")
+
+	output, err := exec.Command(binary, "data", "similar", query, "-s", "--data-folder", dataDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("data similar: %v\n%s", err, output)
+	}
+	for _, want := range []string{"Form 0:", "1.000  login", "forms/login.html form 0  http://a.example/  [near-duplicate]", "Form 1:", "search "} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	output, err = exec.Command(binary, "data", "similar", query, "-s", "--data-folder", dataDir, "--form", "1", "--top", "1", "--format", "jsonl").Output()
+	if err != nil {
+		t.Fatalf("data similar --format jsonl: %v", err)
+	}
+	var got struct {
+		QueryForm  int `json:"query_form"`
+		Form       int
+		Type, Path string
+	}
+	if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &got) != nil {
+		t.Fatalf("jsonl output:\n%s", output)
+	}
+	if got.QueryForm != 1 || got.Type != "search" || got.Path != filepath.Join("forms", "search.html") || got.Form != 0 {
+		t.Errorf("nearest form = %+v, want the annotated search form", got)
+	}
+
+	if err := exec.Command(binary, "data", "similar", query, "-s", "--data-folder", dataDir, "--form", "5").Run(); err == nil {
+		t.Error("expected an error for a form index past the page's forms")
+	}
+}
+
+func TestFunctional_DataLint(t *testing.T) {
+	binary := buildBinary(t)
+	dataDir := t.TempDir()
+	long := "<p>" + strings.Repeat("Our products are great. ", 100) + "</p>"
+	files := map[string]string{
+		"forms/config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [{"full": "username", "short": "un"}, {"full": "password", "short": "pw"}, {"full": "search query", "short": "q"}], "NA_value": "XX", "skip_value": "--"}
+		}`,
+		"forms/index.json": `{
+			"ok.html": {"url": "http://a.example/", "forms": ["l"], "visible_html_fields": [{"user": "un", "pass": "pw"}]},
+			"nopass.html": {"url": "http://b.example/", "forms": ["l"], "visible_html_fields": [{"user": "un", "pass": "pw"}]},
+			"search.html": {"url": "http://c.example/", "forms": ["s"], "visible_html_fields": [{"q": "q", "pw": "q"}]}
+		}`,
+		"forms/ok.html":     `<form><input name="user"/><input type="password" name="pass"/></form>`,
+		"forms/nopass.html": `<form><input name="user"/><input type="text" name="pass"/></form>`,
+		"forms/search.html": `<form><input name="q"/><input type="password" name="pw"/></form>`,
+		"pages/config.json": `{"page_types": {"types": [{"full": "error", "short": "er"}], "NA_value": "X", "skip_value": "-"}}`,
+		"pages/index.json": `{
+			"html/long.html": {"url": "https://d.example/x", "page_type": "er", "status": 200},
+			"html/404.html": {"url": "https://d.example/y", "page_type": "er", "status": 404},
+			"html/old.html": {"url": "https://d.example/z", "page_type": "er"}
+		}`,
+		"pages/html/long.html": long,
+		"pages/html/404.html":  long,
+		"pages/html/old.html":  long,
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output, err := exec.Command(binary, "data", "lint", "-s", "--data-folder", dataDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("data lint: %v\n%s", err, output)
+	}
+	for _, want := range []string{
+		"forms/nopass.html form 0: [login-without-password]",
+		`forms/nopass.html form 0 field "pass": [password-on-text-input]`,
+		"forms/search.html form 0: [search-with-password]",
+		"pages/html/long.html: [error-page-http-200]",
+		"fix: ",
+		"4 issues",
+	} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(string(output), "ok.html") || strings.Contains(string(output), "404.html") || strings.Contains(string(output), "old.html") {
+		t.Errorf("clean annotations flagged:\n%s", output)
+	}
+
+	output, err = exec.Command(binary, "data", "lint", "-s", "--data-folder", dataDir, "--format", "jsonl").Output()
+	if err != nil {
+		t.Fatalf("data lint --format jsonl: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	var issue struct {
+		Rule, Path, Fix string
+		Form            int
+	}
+	if len(lines) != 4 || json.Unmarshal([]byte(lines[0]), &issue) != nil || issue.Rule == "" || issue.Fix == "" {
+		t.Errorf("jsonl output:\n%s", output)
+	}
+}
+
+func TestFunctional_DataPrunePages(t *testing.T) {
+	binary := buildBinary(t)
+	dataDir := t.TempDir()
+	pages := filepath.Join(dataDir, "pages")
+	if err := os.MkdirAll(filepath.Join(pages, "html"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	challenge := `<title>Just a moment...</title><body><script>window._cf_chl_opt={}</script></body>`
+	files := map[string]string{
+		"config.json": `{"page_types": {"types": [{"full": "login", "short": "lg"}, {"full": "captcha", "short": "ca"}, {"full": "landing", "short": "ln"}], "NA_value": "X", "skip_value": "-"}}`,
+		"index.json": `{
+			"html/challenge.html": {"url": "https://a.example/login", "page_type": "lg", "raw": "raw/challenge.html"},
+			"html/captcha.html": {"url": "https://b.example/", "page_type": "ca"},
+			"html/parked.html": {"url": "https://c.example/", "page_type": "ln", "pending": true},
+			"html/shell.html": {"url": "https://d.example/", "page_type": "ln"},
+			"html/login.html": {"url": "https://e.example/login", "page_type": "lg"}
+		}`,
+		"html/challenge.html": challenge,
+		"raw/challenge.html":  challenge,
+		"html/captcha.html":   challenge,
+		"html/parked.html":    `<body><h1>This domain is for sale!</h1></body>`,
+		"html/shell.html":     `<body><div id="root"></div><script src="/main.js"></script></body>`,
+		"html/login.html":     loginFormHTML,
+	}
+	for name, content := range files {
+		path := filepath.Join(pages, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output, err := exec.Command(binary, "data", "prune-pages", "-s", "--data-folder", dataDir, "--dry-run").CombinedOutput()
+	if err != nil || !strings.Contains(string(output), "Would prune 3 pages (bot_challenge 1, parked 1, app_shell 1)") {
+		t.Fatalf("dry run: %v\n%s", err, output)
+	}
+	if data, _ := os.ReadFile(filepath.Join(pages, "index.json")); !strings.Contains(string(data), "shell.html") {
+		t.Fatal("dry run changed index.json")
+	}
+
+	output, err = exec.Command(binary, "data", "prune-pages", "-s", "--data-folder", dataDir).CombinedOutput()
+	if err != nil || !strings.Contains(string(output), "Pruned 3 pages") {
+		t.Fatalf("prune-pages: %v\n%s", err, output)
+	}
+	var index map[string]json.RawMessage
+	data, _ := os.ReadFile(filepath.Join(pages, "index.json"))
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(index)); !slices.Equal(got, []string{"html/captcha.html", "html/login.html"}) {
+		t.Errorf("index after pruning = %v, want the captcha and login pages", got)
+	}
+	for _, name := range []string{"html/challenge.html", "raw/challenge.html", "html/shell.html"} {
+		if _, err := os.Stat(filepath.Join(pages, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still on disk: %v", name, err)
+		}
+	}
+}
+
+func TestExtractFormsTopK(t *testing.T) {
+	c := newTestClassifier(t)
+
+	results, err := c.ExtractFormsTopK(loginFormHTML, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 form, got %d", len(results))
+	}
+	r := results[0]
+	if len(r.Types) != 2 {
+		t.Fatalf("Types = %v, want 2 predictions", r.Types)
+	}
+	if r.Types[0].Score < r.Types[1].Score {
+		t.Errorf("Types not ordered by score: %v", r.Types)
+	}
+	forms, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Types[0].Type != forms[0].Type {
+		t.Errorf("top type = %q, ExtractForms type = %q", r.Types[0].Type, forms[0].Type)
+	}
+	if len(r.FieldList) == 0 {
+		t.Fatal("expected field predictions")
+	}
+	for _, f := range r.FieldList {
+		if len(f.Types) == 0 || len(f.Types) > 2 {
+			t.Errorf("field %s: got %d predictions, want 1 or 2", f.Name, len(f.Types))
+		}
+	}
+
+	all, err := c.ExtractFormsTopK(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proba, err := c.ExtractFormsProba(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all[0].Types) != len(proba[0].Type) {
+		t.Errorf("k=0 returned %d types, want %d", len(all[0].Types), len(proba[0].Type))
+	}
+}
+
+// withFrenchFormModel adds to c a "French" form model that predicts the
+// same forms under other names, prefixed with "fr-", and returns
+// loginFormHTML marked as French.
+func withFrenchFormModel(t *testing.T, c *Classifier) string {
+	t.Helper()
+	forms, labels := extractFormTrainingData(testAnnotations())
+	for i := range labels {
+		labels[i] = "fr-" + labels[i]
+	}
+	fr := &Classifier{fc: &classifier.FormFieldClassifier{
+		FormModel: classifier.TrainFormType(forms, labels, classifier.DefaultFormTypeTrainConfig()),
+	}}
+	WithLanguageModel("FR", fr)(c)
+	return strings.Replace(loginFormHTML, "<html>", `<html lang="fr-FR">`, 1)
+}
+
+func TestWithLanguageModel(t *testing.T) {
+	c := newTestClassifier(t)
+	frenchHTML := withFrenchFormModel(t, c)
+	if got := c.Languages(); !slices.Equal(got, []string{"fr"}) {
+		t.Fatalf("Languages() = %v, want [fr]", got)
+	}
+	if got := DetectLanguage(frenchHTML); got != "fr" {
+		t.Fatalf("DetectLanguage = %q, want fr", got)
+	}
+
+	check := func(t *testing.T, c *Classifier) {
+		t.Helper()
+		results, err := c.ExtractForms(frenchHTML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || !strings.HasPrefix(results[0].Type, "fr-") {
+			t.Fatalf("French page classified as %+v, want the French model's type", results)
+		}
+		// The French model has no field model, so the default one is used.
+		if len(results[0].Fields) == 0 {
+			t.Error("expected field types from the default field model")
+		}
+		results, err = c.ExtractForms(loginFormHTML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || strings.HasPrefix(results[0].Type, "fr-") {
+			t.Errorf("unlabeled page classified as %+v, want the default model's type", results)
+		}
+	}
+	check(t, c)
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Languages(); !slices.Equal(got, []string{"fr"}) {
+		t.Fatalf("loaded Languages() = %v, want [fr]", got)
+	}
+	check(t, loaded)
+}
+
+func TestSummarizeLanguageModel(t *testing.T) {
+	c := newTestClassifier(t)
+	frenchHTML := withFrenchFormModel(t, c)
+
+	summary, err := c.Summarize(frenchHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for formType := range summary.FormTypes {
+		if !strings.HasPrefix(formType, "fr-") {
+			t.Errorf("French page form types = %v, want the French model's types", summary.FormTypes)
+		}
+	}
+	if summary.FormCount != 1 {
+		t.Errorf("FormCount = %d, want 1", summary.FormCount)
+	}
+}
+
+func TestPrimaryFormLanguageModel(t *testing.T) {
+	c := newTestClassifier(t)
+	frenchHTML := withFrenchFormModel(t, c)
+
+	forms, err := c.ExtractForms(frenchHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 1 || !strings.HasPrefix(forms[0].Type, "fr-") {
+		t.Fatalf("French page classified as %+v, want the French model's type", forms)
+	}
+	primary, err := c.PrimaryForm(frenchHTML, forms[0].Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if primary == nil || primary.Type != forms[0].Type {
+		t.Fatalf("PrimaryForm(%q) = %+v, want the French model's form", forms[0].Type, primary)
+	}
+}
+
+func TestFunctional_CollectCrawlPerTypeMax(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", r.URL.Path, padding)
+			return
+		}
+		fmt.Fprint(w, "<html><body>")
+		for i := range 5 {
+			fmt.Fprintf(w, `<a href="/blog/%d">post</a><a href="/product/%d">item</a>`, i, i)
+		}
+		fmt.Fprintf(w, "%s</body></html>", padding)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0", "--per-type-max", "bl=2,pd=1")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	var index map[string]struct {
+		PageType string `json:"page_type"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, entry := range index {
+		counts[entry.PageType]++
+	}
+	if want := map[string]int{"ln": 1, "bl": 2, "pd": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("collected page types = %v, want %v", counts, want)
+	}
+}
+
+func TestFunctional_CollectCrawlPathSegments(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", r.URL.Path, padding)
+			return
+		}
+		fmt.Fprint(w, "<html><body>")
+		for _, path := range []string{"/pricing", "/shop/cart", "/cartography", "/docs/intro", "/docsify", "/account/settings"} {
+			fmt.Fprintf(w, `<a href="%s">link</a>`, path)
+		}
+		fmt.Fprintf(w, "%s</body></html>", padding)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir, "--delay", "0", "--prob404", "0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	var index map[string]struct {
+		URL      string `json:"url"`
+		PageType string `json:"page_type"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, entry := range index {
+		got[strings.TrimPrefix(entry.URL, srv.URL)] = entry.PageType
+	}
+	for path, want := range map[string]string{"/pricing": "pc", "/shop/cart": "ck", "/docs/intro": "dc", "/account/settings": "st"} {
+		if got[path] != want {
+			t.Errorf("%s collected as %q, want %q", path, got[path], want)
+		}
+	}
+	for _, path := range []string{"/cartography", "/docsify"} {
+		if tp, ok := got[path]; ok && tp != "" {
+			t.Errorf("%s collected as %q, want no type from a partial path segment", path, tp)
+		}
+	}
+}
+
+func TestFunctional_CollectCrawlTargetShare(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", r.URL.Path, padding)
+			return
+		}
+		fmt.Fprint(w, "<html><body>")
+		for i := range 10 {
+			fmt.Fprintf(w, `<a href="/blog/%d">post</a>`, i)
+		}
+		fmt.Fprintf(w, `<a href="/forgot-password">Forgot password?</a>%s</body></html>`, padding)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Room for the homepage and one link: it must be the short type.
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0", "--max-per-site", "2", "--target-share", "pr=20")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	var index map[string]struct {
+		PageType string `json:"page_type"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, entry := range index {
+		counts[entry.PageType]++
+	}
+	if want := map[string]int{"ln": 1, "pr": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("collected page types = %v, want %v", counts, want)
+	}
+}
+
+// writeLoginSearchData writes a data folder of two login and two search
+// forms with their field annotations and returns its path.
+func writeLoginSearchData(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	forms := filepath.Join(dir, "forms")
+	if err := os.MkdirAll(forms, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [{"full": "username", "short": "username"}, {"full": "password", "short": "password"}, {"full": "search query", "short": "search query"}], "NA_value": "XX", "skip_value": "--"}
+		}`,
+		"index.json": `{
+			"a.html": {"url": "http://a-site.org/", "forms": ["l"], "visible_html_fields": [{"user": "username", "pass": "password"}]},
+			"b.html": {"url": "http://b-site.org/", "forms": ["l"], "visible_html_fields": [{"login": "username", "pwd": "password"}]},
+			"c.html": {"url": "http://c-site.org/", "forms": ["s"], "visible_html_fields": [{"q": "search query"}]},
+			"d.html": {"url": "http://d-site.org/", "forms": ["s"], "visible_html_fields": [{"query": "search query"}]}
+		}`,
+		"a.html": `<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		"b.html": `<form><input type="text" name="login"/><input type="password" name="pwd"/></form>`,
+		"c.html": `<form><input type="search" name="q"/></form>`,
+		"d.html": `<form><input type="text" name="query"/></form>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(forms, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTrainCalibration(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	if _, err := Train(dir, &TrainConfig{Calibration: "softmax"}); err == nil {
+		t.Fatal("expected an error for an unknown calibration method")
+	}
+
+	c, err := Train(dir, &TrainConfig{Calibration: CalibrationPlatt, CalibrationFolds: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal := c.fc.FormModel.Calibration; cal == nil || cal.Method != CalibrationPlatt || len(cal.Platt) == 0 {
+		t.Fatalf("form model calibration = %+v", cal)
+	}
+	results, err := c.ExtractFormsProba(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := 0.0
+	for _, p := range results[0].Type {
+		sum += p
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("calibrated form probabilities sum to %v", sum)
+	}
+
+	// The calibration is saved with the model.
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.fc.FormModel.Calibration, c.fc.FormModel.Calibration) {
+		t.Error("calibration not restored by Load")
+	}
+}
+
+func TestTrainNoiseRobust(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	for _, config := range []TrainConfig{{LabelSmoothing: 1}, {LabelSmoothing: -0.1}, {TrimFraction: 0.5}} {
+		if _, err := Train(dir, &config); err == nil {
+			t.Errorf("Train(%+v) succeeded, want a range error", config)
+		}
+	}
+
+	c, err := Train(dir, &TrainConfig{LabelSmoothing: 0.1, TrimFraction: 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Type != "login" {
+		t.Errorf("ExtractForms = %+v, want one login form", result)
+	}
+}
+
+func TestSelfTrain(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	unlabeled := t.TempDir()
+	pages := map[string]string{
+		"p1.html":     `<form><input type="text" name="username"/><input type="password" name="password"/></form>`,
+		"p2.html":     `<form><input type="search" name="q"/></form>`,
+		"sub/p3.html": `<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		"notes.txt":   "not a page",
+	}
+	for name, content := range pages {
+		path := filepath.Join(unlabeled, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, _, err := SelfTrain(dir, unlabeled, &SelfTrainConfig{Threshold: 1.5}); err == nil {
+		t.Error("expected an error for a threshold above 1")
+	}
+	if _, _, err := SelfTrain(dir, t.TempDir(), nil); err == nil {
+		t.Error("expected an error for a folder without pages")
+	}
+
+	c, rounds, err := SelfTrain(dir, unlabeled, &SelfTrainConfig{Rounds: 2, Threshold: 0.5, HoldOut: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 3 {
+		t.Fatalf("got %d rounds, want 3 (annotations only, then 2 self-training rounds)", len(rounds))
+	}
+	if rounds[0].PseudoForms != 0 || rounds[0].HeldOutForms == 0 {
+		t.Errorf("round 0 = %+v, want no pseudo-labels and held-out forms", rounds[0])
+	}
+	if rounds[1].PseudoForms == 0 || rounds[1].PseudoForms > 3 {
+		t.Errorf("round 1 pseudo-labeled %d forms, want 1 to 3", rounds[1].PseudoForms)
+	}
+	result, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Type != "login" {
+		t.Errorf("ExtractForms = %+v, want one login form", result)
+	}
+}
+
+func TestExcludeSites(t *testing.T) {
+	unlabeled := []unlabeledPage{
+		{path: "a.html", url: "https://www.a-site.org/login"},
+		{path: "b.html", url: "https://b-site.org/"},
+		{path: "c.html"},
+	}
+	testForms := []storage.FormAnnotation{{URL: "http://a-site.org/"}, {}}
+	testPages := []storage.PageAnnotation{{URL: "https://c-site.org/about"}}
+	got := excludeSites(unlabeled, testForms, testPages)
+	if len(got) != 2 || got[0].path != "b.html" || got[1].path != "c.html" {
+		t.Errorf("excludeSites = %+v, want b.html and c.html, without the held-out a-site.org", got)
+	}
+}
+
+func TestTuneThresholds(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	c, err := Train(dir, &TrainConfig{FormModel: FormModelGBT, GBT: classifier.GBTConfig{Rounds: 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Classification goes on while the thresholds are tuned.
+	before := c.models()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := c.ExtractForms(loginFormHTML); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	})
+	thresholds, err := c.TuneThresholds(dir, &TuneConfig{Folds: 2})
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) == 0 || !maps.Equal(c.models().FormModel.Thresholds, thresholds) {
+		t.Errorf("tuned thresholds %v, model has %v", thresholds, c.models().FormModel.Thresholds)
+	}
+	if before.FormModel.Thresholds != nil {
+		t.Error("TuneThresholds changed the models in use instead of swapping in a copy")
+	}
+
+	// A model that does not record how it was trained cannot be tuned.
+	c.fc.FormModel.Training = nil
+	if _, err := c.TuneThresholds(dir, nil); err == nil {
+		t.Error("expected an error for a form model without a training config")
+	}
+}
+
+func TestTrainFormModelGBT(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	if _, err := Train(dir, &TrainConfig{FormModel: "forest"}); err == nil {
+		t.Fatal("expected an error for an unknown form model")
+	}
+	if _, err := Train(dir, &TrainConfig{FormModel: FormModelGBT, GBT: classifier.GBTConfig{Rounds: -1}}); err == nil {
+		t.Fatal("expected an error for negative boosting rounds")
+	}
+
+	c, err := Train(dir, &TrainConfig{FormModel: FormModelGBT, GBT: classifier.GBTConfig{Rounds: 7, BalanceClasses: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.fc.FormModel.Trees == nil || c.fc.FormModel.Coef != nil {
+		t.Fatal("form model is not a tree ensemble")
+	}
+	if n := len(c.fc.FormModel.Trees.Trees); n != 7 {
+		t.Errorf("tree ensemble has %d rounds, want the configured 7", n)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := c.ExtractFormsProba(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.ExtractFormsProba(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got[0].Type, want[0].Type) {
+		t.Errorf("form probabilities after Load = %v, want %v", got[0].Type, want[0].Type)
+	}
+}
+
+func TestEnsemble(t *testing.T) {
+	dir := writeLoginSearchData(t)
+	logreg, err := Train(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbt, err := Train(dir, &TrainConfig{FormModel: FormModelGBT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Ensemble([]*Classifier{logreg, {}}, nil); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("Ensemble with an uninitialized member: err = %v, want ErrNotInitialized", err)
+	}
+	if _, err := Ensemble([]*Classifier{logreg, gbt}, []float64{1}); err == nil {
+		t.Error("Ensemble with one weight for two members succeeded, want an error")
+	}
+
+	c, err := Ensemble([]*Classifier{logreg, gbt}, []float64{1, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.ExtractFormsProba(loginFormHTML, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pLogreg, _ := logreg.ExtractFormsProba(loginFormHTML, 0)
+	pGBT, _ := gbt.ExtractFormsProba(loginFormHTML, 0)
+	for cls, p := range got[0].Type {
+		if want := 0.25*pLogreg[0].Type[cls] + 0.75*pGBT[0].Type[cls]; math.Abs(p-want) > 1e-9 {
+			t.Errorf("P(%s) = %v, want %v", cls, p, want)
+		}
+	}
+	if _, err := Train(dir, &TrainConfig{VocabFrom: c}); err == nil {
+		t.Error("Train with an ensemble as VocabFrom succeeded, want an error")
+	}
+
+	// A member's language model joins the ensemble for that language; the
+	// others take part with their default model.
+	gbt.fc.Languages = map[string]*classifier.FormFieldClassifier{"de": {FormModel: logreg.fc.FormModel}}
+	c, err = Ensemble([]*Classifier{logreg, gbt}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	de := c.fc.Languages["de"]
+	if de == nil || len(de.FormModel.Ensemble) != 2 || de.FormModel.Ensemble[1].Model != logreg.fc.FormModel {
+		t.Fatalf("de model = %+v, want an ensemble with gbt's de model", de)
+	}
+	if de.FormModel.Ensemble[0].Model != logreg.fc.FormModel {
+		t.Error("de ensemble does not fall back to logreg's default model")
+	}
+}
+
+func TestFunctional_ModelEnsemble(t *testing.T) {
+	binary := buildBinary(t)
+	dir := writeLoginSearchData(t)
+	tmp := t.TempDir()
+	a, b, out := filepath.Join(tmp, "a.json"), filepath.Join(tmp, "b.json"), filepath.Join(tmp, "model.json")
+	for _, args := range [][]string{
+		{"train", a, "--data-folder", dir, "-s"},
+		{"train", b, "--data-folder", dir, "--form-model", "gbt", "-s"},
+		{"model", "ensemble", a, b, "--weights", "2,1", "-o", out, "-s"},
+	} {
+		if output, err := exec.Command(binary, args...).CombinedOutput(); err != nil {
+			t.Fatalf("dit %s: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+	if output, err := exec.Command(binary, "model", "ensemble", a, b, "--weights", "1", "-o", out, "-s").CombinedOutput(); err == nil {
+		t.Errorf("mismatched --weights succeeded:\n%s", output)
+	}
+
+	page := filepath.Join(tmp, "login.html")
+	if err := os.WriteFile(page, []byte(loginFormHTML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(binary, "run", "-s", "--model", out, page).CombinedOutput()
+	if err != nil {
+		t.Fatalf("dit run with the ensemble: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "login") {
+		t.Errorf("ensemble did not classify the login form:\n%s", output)
+	}
+}
+
+func TestFunctional_CollectCrawlURLFilters(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	var mu sync.Mutex
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path != "/" {
+			fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", r.URL.Path, padding)
+			return
+		}
+		fmt.Fprintf(w, `<html><body>
+<a href="/logout">Log out</a>
+<a href="/cart/add?id=1">Add to cart</a>
+<a href="/session/end">Sign out</a>
+<a href="/posts/3" data-method="delete">Trash</a>
+<a href="/blog/1?lang=fr">Français</a>
+<a href="/blog/1">post</a>
+<a href="/blog/2">post</a>
+<a href="/products/1">item</a>
+%s</body></html>`, padding)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0", "--exclude-pattern", "/blog/2$")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.Sort(requested)
+	if want := []string{"/", "/blog/1", "/products/1"}; !slices.Equal(requested, want) {
+		t.Errorf("requested %v, want %v", requested, want)
+	}
+}
+
+func TestFunctional_CollectCrawlMaxDepth(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	// The homepage links /blog/1 and /blog/2; /blog/1 leads on to /blog/3
+	// and that to /blog/4.
+	children := map[string]string{
+		"/":       `<a href="/blog/1">post</a><a href="/blog/2">post</a>`,
+		"/blog/1": `<a href="/blog/3">post</a>`,
+		"/blog/3": `<a href="/blog/4">post</a>`,
+	}
+	var mu sync.Mutex
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		fmt.Fprintf(w, "<html><body>%s%s</body></html>", children[r.URL.Path], padding)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0", "--max-depth", "2")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// Breadth-first: both depth 1 posts before the depth 2 one, and
+	// nothing past --max-depth.
+	if len(requested) != 4 || requested[0] != "/" || requested[3] != "/blog/3" ||
+		!slices.Equal(slices.Sorted(slices.Values(requested[1:3])), []string{"/blog/1", "/blog/2"}) {
+		t.Errorf("requested %v, want /, /blog/1 and /blog/2 in either order, then /blog/3", requested)
+	}
+}
+
+func TestFunctional_CollectCrawlNormalize(t *testing.T) {
+	binary := buildBinary(t)
+	page := `<html><head><script>var tracking = "` + strings.Repeat("x", 200) + `";</script></head>
+<body>
+    <form action="/login"><input name="user"><input type="password" name="pass"></form>
+</body></html>`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0", "--normalize")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "pages", "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index map[string]struct {
+		Raw string `json:"raw"`
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 {
+		t.Fatalf("index has %d entries, want the homepage", len(index))
+	}
+	for filename, entry := range index {
+		normalized, err := os.ReadFile(filepath.Join(dir, "pages", filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, "pages", entry.Raw))
+		if err != nil {
+			t.Fatalf("raw copy %q: %v", entry.Raw, err)
+		}
+		if string(raw) != page {
+			t.Errorf("raw copy differs from the response:\n%s", raw)
+		}
+		if bytes.Contains(normalized, []byte("tracking")) || !bytes.Contains(normalized, []byte(`<input type="password" name="pass"/>`)) {
+			t.Errorf("normalized page should drop the script and keep the form:\n%s", normalized)
+		}
+	}
+}
+
+func TestFunctional_CollectCrawlBacksOffOn429(t *testing.T) {
+	binary := buildBinary(t)
+	padding := strings.Repeat("<p>filler text for a realistic page</p>", 5)
+	var mu sync.Mutex
+	var throttled time.Time
+	var after []time.Duration
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprintf(w, `<html><body><a href="/blog/1">post</a><a href="/blog/2">post</a><a href="/blog/3">post</a>%s</body></html>`, padding)
+		case throttled.IsZero():
+			throttled = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			after = append(after, time.Since(throttled))
+			fmt.Fprintf(w, "<html><body><h1>%s</h1>%s</body></html>", r.URL.Path, padding)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites.txt")
+	if err := os.WriteFile(sites, []byte(srv.URL+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "collect", "crawl", "-s", "--sites", sites, "--data-folder", dir,
+		"--delay", "0", "--prob404", "0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("collect crawl failed: %v\n%s", err, output)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(after) != 2 {
+		t.Fatalf("%d requests after the 429, want 2", len(after))
+	}
+	// Retry-After: 1 holds back the next request, and the delay then
+	// relaxes gradually instead of dropping back to --delay.
+	if after[0] < 900*time.Millisecond {
+		t.Errorf("first request came %v after the 429, want at least 1s", after[0])
+	}
+	if gap := after[1] - after[0]; gap < 400*time.Millisecond {
+		t.Errorf("second request came %v after the first, want about 500ms", gap)
+	}
+}
+
+func TestQuantizeAndLoadEmbedded(t *testing.T) {
+	c := newTestClassifier(t)
+	want, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	full := filepath.Join(dir, "model.json")
+	if err := c.Save(full); err != nil {
+		t.Fatal(err)
+	}
+	before := c.models()
+	intercept := slices.Clone(before.FormModel.Intercept)
+	if err := c.Quantize(3); err != nil {
+		t.Fatal(err)
+	}
+	if c.models() == before || !slices.Equal(before.FormModel.Intercept, intercept) {
+		t.Error("Quantize changed the models in use instead of swapping in a copy")
+	}
+	compact := filepath.Join(dir, "model.json.gz")
+	if err := c.Save(compact); err != nil {
+		t.Fatal(err)
+	}
+	fullInfo, _ := os.Stat(full)
+	compactInfo, _ := os.Stat(compact)
+	if compactInfo.Size()*4 > fullInfo.Size() {
+		t.Errorf("compact model is %d bytes, full model %d", compactInfo.Size(), fullInfo.Size())
+	}
+
+	// This tree ships without an embedded model.
+	if _, err := LoadEmbedded(); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("LoadEmbedded err = %v, want ErrModelNotFound", err)
+	}
+
+	// Without a model on disk, New reports a broken embedded model instead
+	// of ErrModelNotFound.
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if _, err := newClassifier(fstest.MapFS{}, nil); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("New without any model: err = %v, want ErrModelNotFound", err)
+	}
+	broken := fstest.MapFS{embeddedModel: {Data: []byte("not gzip")}}
+	if _, err := newClassifier(broken, nil); err == nil || errors.Is(err, ErrModelNotFound) {
+		t.Errorf("New with a broken embedded model: err = %v, want its load error", err)
+	}
+
+	data, err := os.ReadFile(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, load := range map[string]func() (*Classifier, error){
+		"Load":         func() (*Classifier, error) { return Load(compact) },
+		"loadEmbedded": func() (*Classifier, error) { return loadEmbedded(fstest.MapFS{embeddedModel: {Data: data}}) },
+	} {
+		loaded, err := load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := loaded.ExtractForms(loginFormHTML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: compact model predicts %+v, full model %+v", name, got, want)
+		}
+	}
+}
+
+func TestReloadAndWatchModel(t *testing.T) {
+	c := newTestClassifier(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.json")
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path, WithLocators())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retrain: the new model routes French pages to a model of its own.
+	fr := &Classifier{fc: &classifier.FormFieldClassifier{FormModel: c.fc.FormModel}}
+	WithLanguageModel("fr", fr)(c)
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Reload(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Languages(); !slices.Equal(got, []string{"fr"}) {
+		t.Errorf("Languages() after Reload = %v, want [fr]", got)
+	}
+	results, err := loaded.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].FieldList) == 0 || results[0].FieldList[0].Locator == nil {
+		t.Errorf("WithLocators not applied to the reloaded model: %+v", results)
+	}
+
+	if err := loaded.Reload(filepath.Join(dir, "missing.json")); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Reload(missing) error = %v, want ErrModelNotFound", err)
+	}
+	if got := loaded.Languages(); !slices.Equal(got, []string{"fr"}) {
+		t.Errorf("failed Reload replaced the model: Languages() = %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reloaded := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		loaded.WatchModel(ctx, path, 10*time.Millisecond, func(err error) {
+			select {
+			case reloaded <- err:
+			default:
+			}
+		})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Replace the file atomically, as WatchModel asks, so the watcher never
+	// reads it half written.
+	time.Sleep(50 * time.Millisecond)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, plain, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("watched reload failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchModel did not reload the changed file")
+	}
+	if got := loaded.Languages(); len(got) != 0 {
+		t.Errorf("Languages() after watched reload = %v, want none", got)
+	}
+}
+
+func TestExportONNX(t *testing.T) {
+	var empty Classifier
+	if err := empty.ExportONNX(filepath.Join(t.TempDir(), "model.onnx")); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("uninitialized ExportONNX error = %v, want ErrNotInitialized", err)
+	}
+
+	c := newTestClassifier(t)
+	path := filepath.Join(t.TempDir(), "model.onnx")
+	if err := c.ExportONNX(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"form_proba", "field_emissions", "dit.form_classes", "login"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("exported model does not contain %q", want)
+		}
+	}
+}
+
+func TestFunctional_ModelImportFormasaurus(t *testing.T) {
+	binary := buildBinary(t)
+	dir := t.TempDir()
+	dump := filepath.Join(dir, "dump.json")
+	if err := os.WriteFile(dump, []byte(`{
+		"form_model": {
+			"classes": ["l", "s"],
+			"coef": [[2.0, -1.0]],
+			"intercept": [0.5],
+			"pipelines": [{"extractor": "FormElements", "vectorizer": "DictVectorizer", "feature_names": ["has <input type=password>", "has <input type=search>"]}]
+		},
+		"form_types": {"l": "login", "s": "search"}
+	}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "model.json")
+
+	cmd := exec.Command(binary, "model", "import", "--from", "formasaurus", dump, "-o", output, "-s")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("model import failed: %v\n%s", err, out)
+	}
+	c, err := Load(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := c.ExtractForms(loginFormHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || (results[0].Type != "login" && results[0].Type != "search") {
+		t.Errorf("results = %+v, want one login or search form", results)
+	}
+
+	cmd = exec.Command(binary, "model", "import", "--from", "sklearn", dump, "-o", output, "-s")
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "unsupported --from") {
+		t.Errorf("--from sklearn: err = %v, output %s", err, out)
+	}
+	if _, err := ImportFormasaurus(output); !errors.Is(err, ErrIncompatibleModel) {
+		t.Errorf("ImportFormasaurus of a dit model: err = %v, want ErrIncompatibleModel", err)
 	}
 }
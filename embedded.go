@@ -0,0 +1,52 @@
+package dit
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// embeddedFiles holds the compact model compiled into the library, if the
+// build has one; see embedded/README.md.
+//
+//go:embed embedded
+var embeddedFiles embed.FS
+
+// embeddedModel is the path of the compact model within embeddedFiles.
+const embeddedModel = "embedded/model.json.gz"
+
+// LoadEmbedded loads the compact model compiled into the library, a
+// quantized version of the full model that trades a little accuracy for
+// working offline. It returns ErrModelNotFound if this build has none.
+func LoadEmbedded(opts ...Option) (*Classifier, error) {
+	return loadEmbedded(embeddedFiles, opts...)
+}
+
+func loadEmbedded(fsys fs.FS, opts ...Option) (*Classifier, error) {
+	data, err := fs.ReadFile(fsys, embeddedModel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: no embedded model", ErrModelNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	return parseModel(data, "embedded model", opts)
+}
+
+// Quantize rounds the classifier's weights to decimals places so that a
+// model saved to a ".gz" path is small enough to embed; see LoadEmbedded.
+// Three decimals usually keep predictions unchanged. The rounded models
+// are a copy swapped in as by Reload, so calls already running finish on
+// the unrounded ones.
+func (c *Classifier) Quantize(decimals int) error {
+	fc := c.models()
+	if fc == nil {
+		return ErrNotInitialized
+	}
+	return c.updateModels(fc, func(next *classifier.FormFieldClassifier) {
+		next.Quantize(decimals)
+	})
+}
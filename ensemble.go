@@ -0,0 +1,84 @@
+package dit
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// Ensemble combines the form type models of members into one classifier
+// whose form probabilities are their weighted average, to get more out of
+// runs trained on different data or with different learners. weights
+// holds one non-negative weight per member; nil weighs them equally. The
+// field and page models are those of the first member. Pages in a
+// language any member has a model for get an ensemble of the members'
+// models for that language, falling back to a member's default model
+// where it has none. Save writes the members into the model file, so Load
+// restores the ensemble; see classifier.NewFormTypeEnsemble.
+func Ensemble(members []*Classifier, weights []float64, opts ...Option) (*Classifier, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("dit: ensemble needs at least one classifier")
+	}
+	fcs := make([]*classifier.FormFieldClassifier, len(members))
+	var langs []string
+	for i, m := range members {
+		var fc *classifier.FormFieldClassifier
+		if m != nil {
+			fc = m.models()
+		}
+		if fc == nil || fc.FormModel == nil {
+			return nil, fmt.Errorf("%w: ensemble member %d", ErrNotInitialized, i)
+		}
+		fcs[i] = fc
+		for lang := range fc.Languages {
+			langs = append(langs, lang)
+		}
+	}
+	slices.Sort(langs)
+	langs = slices.Compact(langs)
+
+	first := fcs[0]
+	formModel, err := formEnsemble(fcs, weights, "")
+	if err != nil {
+		return nil, err
+	}
+	fc := &classifier.FormFieldClassifier{
+		FormModel:  formModel,
+		FieldModel: first.FieldModel,
+		PageModel:  first.PageModel,
+	}
+	for _, lang := range langs {
+		langModel, err := formEnsemble(fcs, weights, lang)
+		if err != nil {
+			return nil, err
+		}
+		if fc.Languages == nil {
+			fc.Languages = make(map[string]*classifier.FormFieldClassifier, len(langs))
+		}
+		m := &classifier.FormFieldClassifier{FormModel: langModel}
+		if own, ok := first.Languages[lang]; ok {
+			m.FieldModel, m.PageModel = own.FieldModel, own.PageModel
+		}
+		fc.Languages[lang] = m
+	}
+	c := &Classifier{fc: fc, opts: opts}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// formEnsemble combines the form type models the members use for pages in
+// lang, or their default models when lang is empty.
+func formEnsemble(members []*classifier.FormFieldClassifier, weights []float64, lang string) (*classifier.FormTypeModel, error) {
+	models := make([]*classifier.FormTypeModel, len(members))
+	for i, m := range members {
+		models[i] = m.ForLanguage(lang).FormModel
+	}
+	formModel, err := classifier.NewFormTypeEnsemble(models, weights)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	return formModel, nil
+}
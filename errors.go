@@ -0,0 +1,70 @@
+package dit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// Errors returned by the package, for use with errors.Is.
+var (
+	// ErrModelNotFound is returned by New, FindModel, and Load when no model
+	// file exists at the searched locations or the given path.
+	ErrModelNotFound = errors.New("dit: model not found")
+	// ErrIncompatibleModel is returned by Load for a file that is not a dit
+	// model, such as invalid JSON or a model without a form classifier, or
+	// for a model trained on a different feature schema.
+	ErrIncompatibleModel = errors.New("dit: incompatible model")
+	// ErrModelVersion is returned by Load, LoadEmbedded, and MigrateModel
+	// for a model written in a newer file format or trained on a different
+	// feature schema; retraining or upgrading dit fixes it, unlike a corrupt
+	// file. It wraps ErrIncompatibleModel, so checks for that still match.
+	ErrModelVersion = fmt.Errorf("%w: version mismatch", ErrIncompatibleModel)
+	// ErrNotInitialized is returned by Classifier methods called on a zero
+	// Classifier rather than one from New, Load, or Train.
+	ErrNotInitialized = errors.New("dit: classifier not initialized")
+	// ErrNoPageModel is returned by the page type methods for models trained
+	// without page annotations; callers usually fall back to ExtractForms.
+	ErrNoPageModel = errors.New("dit: page model not available")
+	// ErrNoForms is returned by PrimaryForm for a page without any forms,
+	// and by NewSubmitRequest when the page lacks the form to submit.
+	ErrNoForms = errors.New("dit: no forms found")
+	// ErrNoAnnotations is returned by Train, Evaluate, and TuneThresholds
+	// when the data directory holds no usable annotations.
+	ErrNoAnnotations = errors.New("dit: no annotations found")
+)
+
+// FetchError reports a page or file that could not be downloaded. It is
+// returned by the dit commands that fetch URLs; StatusCode is 0 if no
+// response was received.
+type FetchError struct {
+	URL        string
+	StatusCode int
+	Err        error // underlying error, if any
+}
+
+func (e *FetchError) Error() string {
+	switch {
+	case e.Err != nil && e.StatusCode != 0:
+		return fmt.Sprintf("fetch %s: HTTP %d: %v", e.URL, e.StatusCode, e.Err)
+	case e.Err != nil:
+		return fmt.Sprintf("fetch %s: %v", e.URL, e.Err)
+	default:
+		return fmt.Sprintf("fetch %s: HTTP %d", e.URL, e.StatusCode)
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// incompatibleModel wraps err, from parsing the model named name, in
+// ErrModelVersion for version mismatches and ErrIncompatibleModel otherwise.
+func incompatibleModel(name string, err error) error {
+	if errors.Is(err, classifier.ErrFeatureSchema) || errors.Is(err, classifier.ErrModelFormat) {
+		return fmt.Errorf("%w: %s: %w", ErrModelVersion, name, err)
+	}
+	return fmt.Errorf("%w: %s: %w", ErrIncompatibleModel, name, err)
+}
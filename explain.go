@@ -0,0 +1,135 @@
+package dit
+
+import (
+	"fmt"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// ExplainTopFeatures is the number of features Explain lists for and
+// against each form type.
+const ExplainTopFeatures = 10
+
+// FormExplanation tells why a form got its type: for every form type, the
+// features of the form that raised and lowered its score.
+type FormExplanation struct {
+	FormInfo
+	Type    string             `json:"type"`
+	Classes []ClassExplanation `json:"classes,omitempty"` // most probable first
+	Error   string             `json:"error,omitempty"`   // see FormResult.Error
+}
+
+// ClassExplanation breaks down the score of one form or page type. Score
+// is the logit the probability is computed from: Intercept plus the
+// contribution of every feature in the form or page, of which Positive and
+// Negative list the largest.
+type ClassExplanation struct {
+	Type        string                `json:"type"`
+	Probability float64               `json:"probability"`
+	Score       float64               `json:"score"`
+	Intercept   float64               `json:"intercept"`
+	Positive    []FeatureContribution `json:"positive,omitempty"` // largest first
+	Negative    []FeatureContribution `json:"negative,omitempty"` // most negative first
+}
+
+// FeatureContribution is one feature's share of a form or page type score:
+// its value times the model's weight for the type.
+type FeatureContribution struct {
+	Pipeline     string  `json:"pipeline"` // feature pipeline, e.g. "form css"
+	Feature      string  `json:"feature"`  // dict key or vocabulary term
+	Value        float64 `json:"value"`
+	Contribution float64 `json:"contribution"`
+	// Reason puts a page feature in words, e.g. "title contains 'page not
+	// found'" or "URL path matched 'login'"; empty for form features.
+	Reason string `json:"reason,omitempty"`
+}
+
+// PageExplanation tells why a page got its type: for every page type, the
+// features of the page that raised and lowered its score.
+type PageExplanation struct {
+	Type    string             `json:"type"`
+	Classes []ClassExplanation `json:"classes,omitempty"` // most probable first
+}
+
+// Explain classifies every form in the HTML and returns, per form type,
+// the ExplainTopFeatures features that pushed the form towards and away
+// from it, for debugging why a registration form was labeled login, say.
+// Field types are not explained.
+func (c *Classifier) Explain(html string) ([]FormExplanation, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	return cached(c, fc, "explain", html, func() ([]FormExplanation, error) {
+		doc, err := htmlutil.LoadHTMLString(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		results := fc.ExplainForms(doc, ExplainTopFeatures)
+		out := make([]FormExplanation, len(results))
+		for i, r := range results {
+			out[i] = FormExplanation{FormInfo: newFormInfo(r.Meta), Type: r.Form, Classes: newClassExplanations(r.Classes), Error: r.Error}
+		}
+		return out, nil
+	})
+}
+
+// ExplainPage classifies the page type of the HTML, fetched from pageURL
+// (or "" if unknown), and returns per page type the ExplainTopFeatures
+// features that pushed the page towards and away from it, each with a
+// Reason in words for analysts validating a finding.
+func (c *Classifier) ExplainPage(html, pageURL string) (*PageExplanation, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if fc.PageModel == nil {
+		return nil, ErrNoPageModel
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	return cached(c, fc, "explain-page:"+pageURL, html, func() (*PageExplanation, error) {
+		doc, err := htmlutil.LoadHTMLString(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		out := &PageExplanation{Classes: newClassExplanations(fc.ExplainPage(doc, pageURL, ExplainTopFeatures))}
+		if len(out.Classes) > 0 {
+			out.Type = out.Classes[0].Type
+		}
+		return out, nil
+	})
+}
+
+func newClassExplanations(classes []classifier.ClassExplanation) []ClassExplanation {
+	var out []ClassExplanation
+	for _, e := range classes {
+		out = append(out, ClassExplanation{
+			Type:        e.Class,
+			Probability: e.Probability,
+			Score:       e.Score,
+			Intercept:   e.Intercept,
+			Positive:    newFeatureContributions(e.Positive),
+			Negative:    newFeatureContributions(e.Negative),
+		})
+	}
+	return out
+}
+
+func newFeatureContributions(fcs []classifier.FeatureContribution) []FeatureContribution {
+	if fcs == nil {
+		return nil
+	}
+	out := make([]FeatureContribution, len(fcs))
+	for i, f := range fcs {
+		out[i] = FeatureContribution(f)
+	}
+	return out
+}
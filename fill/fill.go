@@ -0,0 +1,286 @@
+// Package fill generates plausible test values for classified form fields,
+// for form fuzzing and QA bots built on dit.
+package fill
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/happyhackingspace/dit"
+)
+
+// Domain is the default domain of generated emails and URLs; it is
+// reserved for documentation (RFC 2606), so no mail reaches a real inbox.
+const Domain = "example.com"
+
+// PasswordLength is the default length of generated passwords.
+const PasswordLength = 16
+
+var (
+	firstNames = []string{"Alice", "Bruno", "Chloe", "Daniel", "Elena", "Farid", "Grace", "Hugo", "Ines", "Jonas", "Keiko", "Liam"}
+	lastNames  = []string{"Anders", "Baker", "Castillo", "Dumont", "Eriksen", "Fischer", "Garcia", "Hayes", "Ivanova", "Jensen", "Kowalski", "Lopez"}
+	addresses  = []struct{ street, city, state, postal string }{
+		{"742 Evergreen Terrace", "Springfield", "Illinois", "62704"},
+		{"1600 Pine Street", "Seattle", "Washington", "98101"},
+		{"221 Baker Avenue", "Austin", "Texas", "73301"},
+		{"12 Harbor Road", "Portland", "Maine", "04101"},
+		{"350 Fifth Avenue", "New York", "New York", "10118"},
+	}
+	words     = []string{"garden", "river", "laptop", "coffee", "winter", "guitar", "bicycle", "camera", "mountain", "notebook"}
+	companies = []string{"Acme Corporation", "Globex Inc.", "Initech LLC", "Umbrella Labs", "Stark Industries"}
+	questions = []string{"What was the name of your first pet?", "In what city were you born?", "What was your first car?"}
+)
+
+// unfilled are the field types that must stay empty whatever their
+// markup: a honeypot or captcha typed in would give a bot away, and a
+// one-time code cannot be guessed.
+var unfilled = map[string]bool{
+	"honeypot": true, "captcha": true, "otp code": true,
+	"submit button": true, "cancel button": true, "reset button": true,
+}
+
+const (
+	upper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	lower   = "abcdefghijkmnopqrstuvwxyz"
+	digits  = "23456789"
+	symbols = "!#$%&*+-=?@_"
+)
+
+// Generator produces field values for one persona at a time, so a form's
+// email matches its email confirmation and the username is derived from
+// the names. Generators seeded alike produce the same values.
+type Generator struct {
+	// Domain of generated emails and URLs; defaults to Domain.
+	Domain string
+	// PasswordLength is the length of generated passwords, at least 4;
+	// defaults to PasswordLength.
+	PasswordLength int
+
+	rng     *rand.Rand
+	persona *persona
+}
+
+// persona is the identity the values of one form are drawn from.
+type persona struct {
+	first, middle, last  string
+	username, password   string
+	phone, fax           string
+	gender               string
+	street, city, state  string
+	postal, organization string
+	year, month, day     int
+	answer               string
+}
+
+// New returns a Generator whose values are determined by seed.
+func New(seed uint64) *Generator {
+	return &Generator{rng: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// Reset starts a new persona, so later values belong to a different
+// identity.
+func (g *Generator) Reset() {
+	g.persona = nil
+}
+
+// Value returns a value for a field of the given type (e.g. "email" or
+// "password confirmation"), drawn from the current persona. It returns ""
+// for fields that must stay empty, such as honeypots, captchas, one-time
+// codes and buttons, and for selects whose options only the page knows.
+func (g *Generator) Value(fieldType string) string {
+	p := g.current()
+	switch fieldType {
+	case "username":
+		return p.username
+	case "email", "email confirmation", "username or email":
+		return p.username + "@" + g.domain()
+	case "password", "password confirmation":
+		return p.password
+	case "first name":
+		return p.first
+	case "middle name":
+		return p.middle
+	case "last name":
+		return p.last
+	case "full name":
+		return p.first + " " + p.last
+	case "gender":
+		return p.gender
+	case "organization name":
+		return p.organization
+	case "address":
+		return p.street
+	case "city":
+		return p.city
+	case "state":
+		return p.state
+	case "postal code":
+		return p.postal
+	case "country":
+		return "United States"
+	case "phone":
+		return p.phone
+	case "fax":
+		return p.fax
+	case "url":
+		return "https://www." + g.domain() + "/"
+	case "full date":
+		return fmt.Sprintf("%04d-%02d-%02d", p.year, p.month, p.day)
+	case "day":
+		return strconv.Itoa(p.day)
+	case "month":
+		return strconv.Itoa(p.month)
+	case "year":
+		return strconv.Itoa(p.year)
+	case "timezone":
+		return "UTC"
+	case "search query":
+		return g.pick(words)
+	case "comment title":
+		return "About the " + g.pick(words)
+	case "comment text", "about me text":
+		return fmt.Sprintf("I enjoy my %s and my %s.", g.pick(words), g.pick(words))
+	case "security question":
+		return g.pick(questions)
+	case "security answer":
+		return p.answer
+	case "product quantity":
+		return "1"
+	case "other number":
+		return strconv.Itoa(1 + g.rng.IntN(100))
+	case "TOS confirmation", "remember me checkbox":
+		return "on"
+	case "other":
+		return "test"
+	}
+	return ""
+}
+
+// Field returns a value for f that also suits its markup: an
+// <input type="email"> gets an email whatever its predicted type, and the
+// value is cut to the element's maxlength. Checkboxes and radios only get
+// "on" when they should be ticked, and selects, hidden inputs and buttons
+// get "", as do honeypots and captchas whatever their element type.
+func (g *Generator) Field(f dit.Field) string {
+	if unfilled[f.Type] {
+		return ""
+	}
+	v := g.Value(f.Type)
+	e := f.Element
+	if e == nil {
+		return v
+	}
+	if e.Tag != "input" && e.Tag != "textarea" {
+		return ""
+	}
+	switch e.Type {
+	case "hidden", "submit", "button", "reset", "image", "file":
+		return ""
+	case "checkbox", "radio":
+		if v != "on" {
+			return ""
+		}
+	case "email":
+		if !strings.Contains(v, "@") {
+			v = g.Value("email")
+		}
+	case "tel":
+		v = g.Value("phone")
+	case "url":
+		v = g.Value("url")
+	case "date":
+		v = g.Value("full date")
+	case "number", "range":
+		if _, err := strconv.Atoi(v); err != nil {
+			v = "1"
+		}
+	}
+	if e.MaxLength > 0 {
+		if r := []rune(v); len(r) > e.MaxLength {
+			v = string(r[:e.MaxLength])
+		}
+	}
+	return v
+}
+
+// Form starts a new persona and returns a value for every field of form
+// that should be filled, keyed by field name; the first field of a name
+// decides its value.
+func (g *Generator) Form(form dit.FormResult) map[string]string {
+	g.Reset()
+	values := make(map[string]string)
+	for _, f := range form.FieldList {
+		if _, ok := values[f.Name]; ok || f.Name == "" {
+			continue
+		}
+		if v := g.Field(f); v != "" {
+			values[f.Name] = v
+		}
+	}
+	return values
+}
+
+// Password returns a new random password of length n that meets common
+// policies: its first four characters are one uppercase letter, lowercase
+// letter, digit and symbol in random order, so a maxlength cut of at
+// least 4 keeps it valid. An n below 4 is raised to 4.
+func (g *Generator) Password(n int) string {
+	n = max(n, 4)
+	classes := []string{upper, lower, digits, symbols}
+	g.rng.Shuffle(len(classes), func(i, j int) { classes[i], classes[j] = classes[j], classes[i] })
+	all := upper + lower + digits + symbols
+	b := make([]byte, n)
+	for i := range b {
+		set := all
+		if i < len(classes) {
+			set = classes[i]
+		}
+		b[i] = set[g.rng.IntN(len(set))]
+	}
+	return string(b)
+}
+
+func (g *Generator) current() *persona {
+	if g.persona != nil {
+		return g.persona
+	}
+	first, last := g.pick(firstNames), g.pick(lastNames)
+	addr := addresses[g.rng.IntN(len(addresses))]
+	length := g.PasswordLength
+	if length == 0 {
+		length = PasswordLength
+	}
+	g.persona = &persona{
+		first:        first,
+		middle:       g.pick(firstNames),
+		last:         last,
+		username:     fmt.Sprintf("%s.%s%02d", strings.ToLower(first), strings.ToLower(last), g.rng.IntN(100)),
+		password:     g.Password(length),
+		phone:        fmt.Sprintf("+1 202 555 01%02d", g.rng.IntN(100)),
+		fax:          fmt.Sprintf("+1 202 555 01%02d", g.rng.IntN(100)),
+		gender:       g.pick([]string{"female", "male"}),
+		street:       addr.street,
+		city:         addr.city,
+		state:        addr.state,
+		postal:       addr.postal,
+		organization: g.pick(companies),
+		year:         1960 + g.rng.IntN(45),
+		month:        1 + g.rng.IntN(12),
+		day:          1 + g.rng.IntN(28),
+		answer:       g.pick(words),
+	}
+	return g.persona
+}
+
+func (g *Generator) domain() string {
+	if g.Domain == "" {
+		return Domain
+	}
+	return g.Domain
+}
+
+func (g *Generator) pick(list []string) string {
+	return list[g.rng.IntN(len(list))]
+}
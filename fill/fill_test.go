@@ -0,0 +1,104 @@
+package fill
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/happyhackingspace/dit"
+)
+
+func TestValueConsistent(t *testing.T) {
+	g := New(1)
+	if g.Value("email") != g.Value("email confirmation") {
+		t.Error("email confirmation differs from email")
+	}
+	if g.Value("password") != g.Value("password confirmation") {
+		t.Error("password confirmation differs from password")
+	}
+	if email := g.Value("email"); !strings.HasPrefix(email, g.Value("username")+"@") || !strings.HasSuffix(email, "@"+Domain) {
+		t.Errorf("email = %q, want username@%s", email, Domain)
+	}
+	for _, typ := range []string{"honeypot", "captcha", "submit button", "sorting option", "no such type"} {
+		if v := g.Value(typ); v != "" {
+			t.Errorf("Value(%q) = %q, want empty", typ, v)
+		}
+	}
+
+	before := g.Value("password")
+	g.Reset()
+	if g.Value("password") == before {
+		t.Error("Reset kept the persona's password")
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := New(42), New(42)
+	for _, typ := range []string{"username", "email", "password", "phone", "full date", "comment text"} {
+		if va, vb := a.Value(typ), b.Value(typ); va != vb {
+			t.Errorf("Value(%q) = %q and %q with the same seed", typ, va, vb)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	g := New(7)
+	for n := range 20 {
+		pw := g.Password(n)
+		if len(pw) != max(n, 4) {
+			t.Errorf("Password(%d) has length %d", n, len(pw))
+		}
+		// The first four characters cover every class.
+		var hasUpper, hasLower, hasDigit, hasSymbol bool
+		for _, r := range pw[:4] {
+			switch {
+			case unicode.IsUpper(r):
+				hasUpper = true
+			case unicode.IsLower(r):
+				hasLower = true
+			case unicode.IsDigit(r):
+				hasDigit = true
+			default:
+				hasSymbol = true
+			}
+		}
+		if !hasUpper || !hasLower || !hasDigit || !hasSymbol {
+			t.Errorf("Password(%d) = %q misses a character class", n, pw)
+		}
+	}
+}
+
+func TestForm(t *testing.T) {
+	g := New(3)
+	form := dit.FormResult{Type: "registration", FieldList: []dit.Field{
+		{Name: "login", Type: "username", Element: &dit.Element{Tag: "input", Type: "email"}},
+		{Name: "pass", Type: "password", Element: &dit.Element{Tag: "input", Type: "password", MaxLength: 8}},
+		{Name: "pass2", Type: "password confirmation", Element: &dit.Element{Tag: "input", Type: "password", MaxLength: 8}},
+		{Name: "website", Type: "honeypot", Element: &dit.Element{Tag: "input", Type: "text"}},
+		{Name: "contact", Type: "honeypot", Element: &dit.Element{Tag: "input", Type: "email"}},
+		{Name: "verify", Type: "captcha", Element: &dit.Element{Tag: "input", Type: "number"}},
+		{Name: "tos", Type: "TOS confirmation", Element: &dit.Element{Tag: "input", Type: "checkbox"}},
+		{Name: "news", Type: "receive emails confirmation", Element: &dit.Element{Tag: "input", Type: "checkbox"}},
+		{Name: "country", Type: "country", Element: &dit.Element{Tag: "select"}},
+		{Name: "qty", Type: "other", Element: &dit.Element{Tag: "input", Type: "number"}},
+	}}
+	values := g.Form(form)
+
+	if !strings.Contains(values["login"], "@") {
+		t.Errorf("login = %q, want an email for type=email", values["login"])
+	}
+	if len(values["pass"]) != 8 || values["pass"] != values["pass2"] {
+		t.Errorf("pass = %q, pass2 = %q, want equal 8-character passwords", values["pass"], values["pass2"])
+	}
+	if values["tos"] != "on" {
+		t.Errorf("tos = %q, want on", values["tos"])
+	}
+	if values["qty"] != "1" {
+		t.Errorf("qty = %q, want 1", values["qty"])
+	}
+	for _, name := range []string{"website", "contact", "verify", "news", "country"} {
+		if v, ok := values[name]; ok {
+			t.Errorf("%s = %q, want it left unset", name, v)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package dit
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// ImportFormasaurus loads a model trained with Formasaurus from the JSON
+// dump at path, written by python/ditclient/formasaurus.py, so it can be
+// used, and saved as a dit model with Save, without retraining. The result
+// has no page model. It returns ErrIncompatibleModel for a dump dit cannot
+// convert, such as one with a feature extractor dit does not port; see
+// classifier.ImportFormasaurus.
+func ImportFormasaurus(path string, opts ...Option) (*Classifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %w", ErrModelNotFound, err)
+		}
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	fc, err := classifier.ImportFormasaurus(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrIncompatibleModel, path, err)
+	}
+	c := &Classifier{fc: fc, opts: opts}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
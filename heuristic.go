@@ -0,0 +1,31 @@
+package dit
+
+import (
+	"fmt"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// Heuristic classifies the page type and forms of html, fetched from
+// pageURL (or "" if unknown), with built-in URL patterns and markup rules
+// instead of a model, so tools degrade gracefully when no model can be
+// loaded, such as offline. Form, field, and page types use the model's
+// labels, but are far less accurate; the result has Heuristic set.
+func Heuristic(html, pageURL string) (*PageResult, error) {
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+	doc, junk, err := loadPage(html)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	formResults, pageResult := classifier.HeuristicExtractPage(doc, pageURL)
+	return &PageResult{
+		Type:         pageResult.Form,
+		Forms:        newFormResults(formResults),
+		BotChallenge: junk == htmlutil.JunkBotChallenge,
+		Parked:       junk == htmlutil.JunkParked,
+		Heuristic:    true,
+	}, nil
+}
@@ -0,0 +1,132 @@
+package htmlutil
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// CSRFToken is a hidden anti-forgery token a form submits, which a
+// scanner must echo back for the submission to be accepted.
+type CSRFToken struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// csrfNames matches the hidden input names web frameworks give their
+// anti-forgery tokens: Django's csrfmiddlewaretoken, Rails'
+// authenticity_token, ASP.NET's __RequestVerificationToken, Laravel and
+// Symfony's _token, WordPress's _wpnonce, Magento's form_key, and so on.
+var csrfNames = regexp.MustCompile(`(?i)(csrf|xsrf|anti-?forgery|requestverificationtoken|authenticity_token)|^(_token|form_key|form_token|_wpnonce|nonce)$|\[_token\]$`)
+
+// tokenHints are words of hidden input names that hold a token when their
+// value looks random, e.g. an OAuth state or a custom "sec_token". They
+// must be whole words of the name (see nameWords), so that "keyword" or
+// "statement" do not count; only "token" and "nonce", which are not part
+// of other words, also count run into one, as in "authtoken".
+var tokenHints = []string{"token", "nonce", "state", "key", "sig", "signature", "hash"}
+
+// stateBlobs are hidden inputs that carry serialized page state rather
+// than a token, though their values look random.
+var stateBlobs = []string{"__viewstate", "__viewstategenerator", "__eventvalidation", "__eventtarget", "__eventargument"}
+
+// joomlaToken matches the input name Joomla hides its token in, submitted
+// with the value "1".
+var joomlaToken = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// GetCSRFTokens returns the hidden anti-CSRF tokens of form, in document
+// order: inputs named as frameworks name their tokens, and inputs whose
+// name hints at a token (token, nonce, state, ...) and whose value looks
+// random, at least 16 characters without spaces and of high entropy.
+func GetCSRFTokens(form *goquery.Selection) []CSRFToken {
+	var tokens []CSRFToken
+	form.FindMatcher(compiled(`input[type="hidden" i][name]`)).Each(func(_ int, s *goquery.Selection) {
+		name, value := s.AttrOr("name", ""), s.AttrOr("value", "")
+		if isCSRFToken(name, value) {
+			tokens = append(tokens, CSRFToken{Name: name, Value: value})
+		}
+	})
+	return tokens
+}
+
+func isCSRFToken(name, value string) bool {
+	lower := strings.ToLower(name)
+	switch {
+	case value == "" || containsAny(lower, stateBlobs...):
+		return false
+	case csrfNames.MatchString(name):
+		return true
+	case value == "1" && joomlaToken.MatchString(lower):
+		return true
+	}
+	hinted := slices.ContainsFunc(nameWords(name), func(w string) bool {
+		return slices.Contains(tokenHints, w) || strings.HasSuffix(w, "token") || strings.HasSuffix(w, "nonce")
+	})
+	return hinted && looksRandom(value)
+}
+
+// nameWords splits an input name into lowercase words at punctuation and
+// camelCase humps: "oauth_state" and "oauthState" both give oauth,
+// state, and "APIKey" gives api, key.
+func nameWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+// looksRandom reports whether value could be a random token: long, without
+// whitespace, and above 3 bits of entropy per character, which rules out
+// words, counters, and repeated characters.
+func looksRandom(value string) bool {
+	if len(value) < 16 || strings.ContainsFunc(value, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }) {
+		return false
+	}
+	return Entropy(value) > 3
+}
+
+// Entropy returns the Shannon entropy of s in bits per byte.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
@@ -0,0 +1,133 @@
+package htmlutil
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// FormView holds what the form feature extractors read from a form,
+// gathered in a single walk of its elements instead of one Find per
+// feature. Each field equals the result of the matching Get function.
+type FormView struct {
+	Form *goquery.Selection
+
+	TypeCounts    map[string]int // GetTypeCounts
+	InputCount    int            // GetInputCount
+	RequiredCount int            // GetRequiredCount
+	OneTimeCodes  int            // GetOneTimeCodeCount
+	ShortNumerics int            // GetShortNumericCount
+	Method        string         // GetFormMethod
+	Action        string         // GetFormAction
+	CSS           string         // GetFormCSS
+	SubmitTexts   string         // GetSubmitTexts
+	LinksText     string         // GetLinksText
+	LabelText     string         // GetLabelText
+	InputNames    string         // GetInputNames
+	InputCSS      string         // GetInputCSS
+	InputTitles   string         // GetInputTitles
+	Position      FormPosition   // GetFormPosition
+}
+
+// NewFormView extracts the view of a single form element.
+func NewFormView(form *goquery.Selection) *FormView {
+	v := &FormView{
+		Form:     form,
+		Method:   GetFormMethod(form),
+		Action:   GetFormAction(form),
+		CSS:      GetFormCSS(form),
+		Position: GetFormPosition(form),
+	}
+	n := singleNode(form)
+	if n == nil {
+		v.TypeCounts = GetTypeCounts(form)
+		v.InputCount = GetInputCount(form)
+		v.RequiredCount = GetRequiredCount(form)
+		v.OneTimeCodes = GetOneTimeCodeCount(form)
+		v.ShortNumerics = GetShortNumericCount(form)
+		v.SubmitTexts = GetSubmitTexts(form)
+		v.LinksText = GetLinksText(form)
+		v.LabelText = GetLabelText(form)
+		v.InputNames = GetInputNames(form)
+		v.InputCSS = GetInputCSS(form)
+		v.InputTitles = GetInputTitles(form)
+		return v
+	}
+
+	v.TypeCounts = make(map[string]int)
+	names := make(map[string]bool)
+	var submits, links, labels, inputNames, inputCSS, titles []string
+	eachDescendant(n, func(e *html.Node) {
+		switch e.Data {
+		case "a":
+			links = append(links, nodeText(e))
+			return
+		case "label":
+			labels = append(labels, nodeText(e))
+			return
+		case "textarea", "select":
+			v.TypeCounts[e.Data]++
+		case "input":
+			tp, exists := attr(e, "type")
+			if tp == "submit" {
+				if val, ok := attr(e, "value"); ok {
+					submits = append(submits, val)
+				}
+			}
+			if !exists {
+				tp = "text"
+			}
+			v.TypeCounts[strings.ToLower(tp)]++
+			if isOneTimeCode(nodeAttr(e)) {
+				v.OneTimeCodes++
+			}
+			if isShortNumeric(nodeAttr(e)) {
+				v.ShortNumerics++
+			}
+			if !strings.EqualFold(tp, "hidden") {
+				if name, ok := attr(e, "name"); ok {
+					inputNames = append(inputNames, cleanInputName(name))
+				}
+				class, _ := attr(e, "class")
+				id, _ := attr(e, "id")
+				inputCSS = append(inputCSS, class+" "+id)
+				if title, ok := attr(e, "title"); ok {
+					titles = append(titles, title)
+				}
+			}
+		default:
+			return
+		}
+		if name, _ := attr(e, "name"); name != "" {
+			names[name] = true
+		}
+		if _, ok := attr(e, "required"); ok {
+			v.RequiredCount++
+		}
+	})
+	v.InputCount = len(names)
+	v.SubmitTexts = strings.Join(submits, " ")
+	v.LinksText = strings.Join(links, " ")
+	v.LabelText = strings.Join(labels, " ")
+	v.InputNames = strings.Join(inputNames, " ")
+	v.InputCSS = strings.Join(inputCSS, " ")
+	v.InputTitles = strings.Join(titles, " ")
+	return v
+}
+
+// nodeText returns the text of n and its descendants, like Selection.Text.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
@@ -0,0 +1,26 @@
+package htmlutil
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FormHash returns the content hash of form: the hex SHA-1 of its inner
+// HTML as goquery serializes it. For a given dit build the same markup
+// hashes the same on any page or crawl, so the hash joins classification
+// results across crawls and with the training annotations, which carry it
+// too; whitespace or attribute order changes give a different hash, and so
+// may a change to the HTML parser or serializer in another build.
+func FormHash(form *goquery.Selection) string {
+	formHTML, _ := form.Html()
+	return HashFormHTML(formHTML)
+}
+
+// HashFormHTML is FormHash for inner form HTML that is already serialized,
+// such as storage.FormAnnotation.FormHTML.
+func HashFormHTML(formHTML string) string {
+	sum := sha1.Sum([]byte(formHTML))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,497 @@
+// Package htmlutil provides HTML form and field extraction utilities.
+//
+// The helpers are independent of classification and can be used on their
+// own, e.g. to pull forms, their visible fields, and field labels out of a
+// scraped page:
+//
+//	doc, _ := htmlutil.LoadHTMLString(page)
+//	for _, form := range htmlutil.GetForms(doc) {
+//	    fields := htmlutil.GetVisibleFields(form)
+//	    around := htmlutil.GetTextAroundElems(form, fields)
+//	    for _, field := range fields {
+//	        if label := htmlutil.FindLabel(form, field); label != nil {
+//	            fmt.Println(field.AttrOr("name", ""), label.Text())
+//	        }
+//	        fmt.Println(around.Before[field]) // text preceding the field
+//	    }
+//	}
+package htmlutil
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// LoadHTML parses HTML bytes into a goquery Document.
+func LoadHTML(r io.Reader) (*goquery.Document, error) {
+	return goquery.NewDocumentFromReader(r)
+}
+
+// LoadHTMLString parses HTML string into a goquery Document.
+func LoadHTMLString(htmlStr string) (*goquery.Document, error) {
+	return goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+}
+
+// GetForms returns all <form> elements in the document.
+func GetForms(doc *goquery.Document) []*goquery.Selection {
+	var forms []*goquery.Selection
+	doc.FindMatcher(compiled("form")).Each(func(_ int, s *goquery.Selection) {
+		forms = append(forms, s)
+	})
+	return forms
+}
+
+// GetVisibleFields returns visible form fields (textarea, select, button, non-hidden inputs).
+func GetVisibleFields(form *goquery.Selection) []*goquery.Selection {
+	var fields []*goquery.Selection
+	form.FindMatcher(compiled("textarea, select, button, input")).Each(func(_ int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "input" {
+			tp, exists := s.Attr("type")
+			if exists && strings.EqualFold(tp, "hidden") {
+				return
+			}
+		}
+		fields = append(fields, s)
+	})
+	return fields
+}
+
+// GetFieldsToAnnotate returns visible fields with non-empty name attribute.
+func GetFieldsToAnnotate(form *goquery.Selection) []*goquery.Selection {
+	visible := GetVisibleFields(form)
+	var result []*goquery.Selection
+	for _, f := range visible {
+		if name, _ := f.Attr("name"); name != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// GetTypeCounts returns counts of different input types in a form.
+func GetTypeCounts(form *goquery.Selection) map[string]int {
+	if n := singleNode(form); n != nil {
+		return typeCountsNode(n)
+	}
+	return typeCountsSelection(form)
+}
+
+func typeCountsSelection(form *goquery.Selection) map[string]int {
+	counts := make(map[string]int)
+	form.FindMatcher(compiled("input, textarea, select")).Each(func(_ int, s *goquery.Selection) {
+		tag := goquery.NodeName(s)
+		switch tag {
+		case "textarea":
+			counts["textarea"]++
+		case "select":
+			counts["select"]++
+		case "input":
+			tp, exists := s.Attr("type")
+			if !exists {
+				tp = "text"
+			}
+			counts[strings.ToLower(tp)]++
+		}
+	})
+	return counts
+}
+
+// GetInputCount returns the number of named input elements (matching lxml form.inputs.keys()).
+func GetInputCount(form *goquery.Selection) int {
+	seen := make(map[string]bool)
+	form.FindMatcher(compiled("input, textarea, select")).Each(func(_ int, s *goquery.Selection) {
+		if name, _ := s.Attr("name"); name != "" {
+			seen[name] = true
+		}
+	})
+	return len(seen)
+}
+
+// GetRequiredCount returns the number of input, select, and textarea
+// elements carrying the required attribute.
+func GetRequiredCount(form *goquery.Selection) int {
+	return form.FindMatcher(compiled("input[required], textarea[required], select[required]")).Length()
+}
+
+// FindLabel finds the element labelling a form field, in order of
+// precedence: the elements named by its aria-labelledby attribute (anywhere
+// in the document, in reference order), a <label for> matching its id, or
+// an ancestor <label> wrapping it. Ids are compared as plain strings, so
+// quotes and CSS metacharacters in them need no escaping.
+func FindLabel(form *goquery.Selection, elem *goquery.Selection) *goquery.Selection {
+	if refs, _ := elem.Attr("aria-labelledby"); refs != "" && elem.Length() > 0 {
+		root := elem.Get(0)
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		var labels []*html.Node
+		for _, id := range strings.Fields(refs) {
+			if n := findByID(root, id); n != nil {
+				labels = append(labels, n)
+			}
+		}
+		if len(labels) > 0 {
+			return goquery.NewDocumentFromNode(root).FindNodes(labels...)
+		}
+	}
+
+	// Try matching by for=id
+	if id, exists := elem.Attr("id"); exists && id != "" {
+		for _, n := range form.Nodes {
+			if label := findLabelFor(n, id); label != nil {
+				return form.FindNodes(label)
+			}
+		}
+	}
+
+	// Try ancestor <label>
+	parent := elem.ClosestMatcher(compiled("label"))
+	if parent.Length() > 0 {
+		return parent
+	}
+
+	return nil
+}
+
+// Limits on FindImplicitLabel: how many nodes before the field it looks
+// at, and how long a text may be before it reads as prose, not a label.
+const (
+	maxImplicitLabelNodes = 6
+	maxImplicitLabelLen   = 80
+)
+
+// FindImplicitLabel returns the text acting as a field's label when no
+// <label> is associated with it, as in <div>Email</div><div><input></div>:
+// the nearest non-blank text before the field within the form, at most
+// maxImplicitLabelNodes nodes away. It returns "" if another field or a
+// <label> comes first, or if the text is too long to be a label.
+func FindImplicitLabel(form *goquery.Selection, elem *goquery.Selection) string {
+	if form.Length() == 0 || elem.Length() == 0 {
+		return ""
+	}
+	root, field := form.Get(0), elem.Get(0)
+	ancestors := make(map[*html.Node]bool)
+	for n := field.Parent; n != nil && n != root; n = n.Parent {
+		ancestors[n] = true
+	}
+
+	visited := 0
+	for n := previousNode(field, root); n != nil && visited < maxImplicitLabelNodes; n = previousNode(n, root) {
+		if ancestors[n] {
+			continue
+		}
+		visited++
+		switch n.Type {
+		case html.ElementNode:
+			switch n.Data {
+			case "input", "select", "textarea", "button", "label":
+				return ""
+			}
+		case html.TextNode:
+			if p := n.Parent; p != nil && (p.Data == "script" || p.Data == "style" || p.Data == "option") {
+				continue
+			}
+			if inLabel(n, root) {
+				return "" // another field's label
+			}
+			text := strings.Join(strings.Fields(n.Data), " ")
+			if text == "" {
+				visited--
+				continue
+			}
+			if len([]rune(text)) > maxImplicitLabelLen {
+				return ""
+			}
+			return text
+		}
+	}
+	return ""
+}
+
+// GetFormMethod returns the form's method attribute, lowercased.
+func GetFormMethod(form *goquery.Selection) string {
+	method, _ := form.Attr("method")
+	method = strings.ToLower(strings.TrimSpace(method))
+	if method == "" {
+		return "MISSING"
+	}
+	return method
+}
+
+// GetFormAction returns the form's action attribute.
+func GetFormAction(form *goquery.Selection) string {
+	action, _ := form.Attr("action")
+	return action
+}
+
+// ResolveFormAction returns the form's action resolved against the
+// document's <base href>, if any. A form without an action submits to the
+// page itself and returns "".
+func ResolveFormAction(form *goquery.Selection) string {
+	return ResolveURL(form, GetFormAction(form))
+}
+
+// ResolveURL returns ref, such as a submit button's formaction, resolved
+// against the <base href> of sel's document, if any. An empty ref is
+// returned as "".
+func ResolveURL(sel *goquery.Selection, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || len(sel.Nodes) == 0 {
+		return ref
+	}
+	baseHref, ok := goquery.NewDocumentFromNode(documentRoot(sel.Nodes[0])).FindMatcher(compiled("base[href]")).First().Attr("href")
+	if !ok {
+		return ref
+	}
+	base, err := url.Parse(strings.TrimSpace(baseHref))
+	if err != nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+// GetSubmitMethod returns the HTTP method the form submits with: "GET",
+// "POST", or "DIALOG". Missing and invalid methods default to "GET", as in
+// browsers.
+func GetSubmitMethod(form *goquery.Selection) string {
+	switch method := strings.ToUpper(strings.TrimSpace(form.AttrOr("method", ""))); method {
+	case "POST", "DIALOG":
+		return method
+	default:
+		return "GET"
+	}
+}
+
+// GetSubmitTexts returns the values of all <input type="submit"> elements.
+func GetSubmitTexts(form *goquery.Selection) string {
+	var texts []string
+	form.FindMatcher(compiled("input[type=\"submit\"]")).Each(func(i int, s *goquery.Selection) {
+		if val, exists := s.Attr("value"); exists {
+			texts = append(texts, val)
+		}
+	})
+	return strings.Join(texts, " ")
+}
+
+// GetLinksText returns text of all links inside the form.
+func GetLinksText(form *goquery.Selection) string {
+	var texts []string
+	form.FindMatcher(compiled("a")).Each(func(i int, s *goquery.Selection) {
+		texts = append(texts, s.Text())
+	})
+	return strings.Join(texts, " ")
+}
+
+// GetLabelText returns text of all <label> elements in the form.
+func GetLabelText(form *goquery.Selection) string {
+	var texts []string
+	form.FindMatcher(compiled("label")).Each(func(i int, s *goquery.Selection) {
+		texts = append(texts, s.Text())
+	})
+	return strings.Join(texts, " ")
+}
+
+// GetInputNames returns names of all non-hidden <input> elements, cleaned up.
+func GetInputNames(form *goquery.Selection) string {
+	if n := singleNode(form); n != nil {
+		return inputNamesNode(n)
+	}
+	return inputNamesSelection(form)
+}
+
+func inputNamesSelection(form *goquery.Selection) string {
+	var names []string
+	form.FindMatcher(compiled("input")).Each(func(i int, s *goquery.Selection) {
+		tp, _ := s.Attr("type")
+		if strings.EqualFold(tp, "hidden") {
+			return
+		}
+		if name, exists := s.Attr("name"); exists {
+			names = append(names, cleanInputName(name))
+		}
+	})
+	return strings.Join(names, " ")
+}
+
+// GetFormCSS returns the form's class and id attributes.
+func GetFormCSS(form *goquery.Selection) string {
+	class, _ := form.Attr("class")
+	id, _ := form.Attr("id")
+	return class + " " + id
+}
+
+// GetInputCSS returns CSS classes and IDs of non-hidden input elements.
+func GetInputCSS(form *goquery.Selection) string {
+	if n := singleNode(form); n != nil {
+		return inputCSSNode(n)
+	}
+	return inputCSSSelection(form)
+}
+
+func inputCSSSelection(form *goquery.Selection) string {
+	var parts []string
+	form.FindMatcher(compiled("input")).Each(func(i int, s *goquery.Selection) {
+		tp, _ := s.Attr("type")
+		if strings.EqualFold(tp, "hidden") {
+			return
+		}
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		parts = append(parts, class+" "+id)
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetInputTitles returns title attributes of non-hidden input elements.
+func GetInputTitles(form *goquery.Selection) string {
+	var titles []string
+	form.FindMatcher(compiled("input")).Each(func(i int, s *goquery.Selection) {
+		tp, _ := s.Attr("type")
+		if strings.EqualFold(tp, "hidden") {
+			return
+		}
+		if title, exists := s.Attr("title"); exists {
+			titles = append(titles, title)
+		}
+	})
+	return strings.Join(titles, " ")
+}
+
+// GetAllFormText returns all text content inside the form.
+func GetAllFormText(form *goquery.Selection) string {
+	return form.Text()
+}
+
+// IsHidden reports whether the element or one of its ancestors is hidden via
+// the hidden attribute, aria-hidden="true", an inline display:none or
+// visibility:hidden style, or a common utility class (hidden, d-none).
+func IsHidden(sel *goquery.Selection) bool {
+	for n := sel; n.Length() > 0 && goquery.NodeName(n) != "html"; n = n.Parent() {
+		if _, ok := n.Attr("hidden"); ok {
+			return true
+		}
+		if aria, _ := n.Attr("aria-hidden"); strings.EqualFold(aria, "true") {
+			return true
+		}
+		style, _ := n.Attr("style")
+		style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+		if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+			return true
+		}
+		class, _ := n.Attr("class")
+		for _, c := range strings.Fields(class) {
+			if c == "hidden" || c == "d-none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// landmarkRoles maps ARIA roles to the equivalent HTML5 landmark element.
+var landmarkRoles = map[string]string{
+	"banner":        "header",
+	"contentinfo":   "footer",
+	"navigation":    "nav",
+	"complementary": "aside",
+	"main":          "main",
+	"search":        "search",
+}
+
+// GetLandmark returns the closest enclosing page landmark of the element:
+// "header", "footer", "nav", "aside", "main", "article", "search", or ""
+// if it sits outside any landmark. ARIA roles map to their element names.
+func GetLandmark(sel *goquery.Selection) string {
+	for n := sel.Parent(); n.Length() > 0; n = n.Parent() {
+		if role, _ := n.Attr("role"); role != "" {
+			if landmark, ok := landmarkRoles[strings.ToLower(role)]; ok {
+				return landmark
+			}
+		}
+		switch tag := goquery.NodeName(n); tag {
+		case "header", "footer", "nav", "aside", "main", "article", "search":
+			return tag
+		}
+	}
+	return ""
+}
+
+// FormPosition describes where a form sits in its page.
+type FormPosition struct {
+	Landmark string `json:"landmark,omitempty"` // see GetLandmark
+	Depth    int    `json:"depth,omitempty"`    // number of element ancestors, including <html>
+	Siblings int    `json:"siblings,omitempty"` // number of sibling elements
+	Hidden   bool   `json:"hidden,omitempty"`   // see IsHidden
+}
+
+// maxPositionDepth caps the ancestor chain rebuilt by FormPosition.Wrap.
+const maxPositionDepth = 32
+
+// GetFormPosition returns the landmark, depth, sibling count, and visibility
+// of a form within its document.
+func GetFormPosition(form *goquery.Selection) FormPosition {
+	depth := 0
+	for n := form.Parent(); n.Length() > 0; n = n.Parent() {
+		depth++
+	}
+	return FormPosition{
+		Landmark: GetLandmark(form),
+		Depth:    depth,
+		Siblings: form.Siblings().Length(),
+		Hidden:   IsHidden(form),
+	}
+}
+
+// Wrap embeds formHTML (a complete <form> element) in a minimal document
+// whose structure reproduces the position, so that GetFormPosition on the
+// parsed form returns p. It lets training code restore position features
+// for forms stored without their page. A zero FormPosition returns formHTML
+// unchanged.
+func (p FormPosition) Wrap(formHTML string) string {
+	if p == (FormPosition{}) {
+		return formHTML
+	}
+
+	// <html> and <body> are added by the parser.
+	var open, closing []string
+	depth := 2
+	if p.Landmark != "" {
+		open = append(open, "<"+p.Landmark+">")
+		closing = append(closing, "</"+p.Landmark+">")
+		depth++
+	}
+	for ; depth < min(p.Depth, maxPositionDepth); depth++ {
+		open = append(open, "<div>")
+		closing = append(closing, "</div>")
+	}
+	if p.Hidden {
+		// Hide the innermost wrapper rather than adding a level.
+		if len(open) == 0 {
+			open = append(open, "<div>")
+			closing = append(closing, "</div>")
+		}
+		last := open[len(open)-1]
+		open[len(open)-1] = last[:len(last)-1] + " hidden>"
+	}
+
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	for _, tag := range open {
+		b.WriteString(tag)
+	}
+	b.WriteString(formHTML)
+	b.WriteString(strings.Repeat("<span></span>", p.Siblings))
+	for i := len(closing) - 1; i >= 0; i-- {
+		b.WriteString(closing[i])
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
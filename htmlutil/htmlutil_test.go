@@ -0,0 +1,578 @@
+package htmlutil
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const testHTML = `
+<html><body>
+<form id="login" method="POST" action="/login" class="auth-form">
+  <label for="user">Username</label>
+  <input type="text" name="username" id="user" title="Enter username"/>
+  <label for="pass">Password</label>
+  <input type="password" name="password" id="pass"/>
+  <input type="hidden" name="csrf" value="abc123"/>
+  <input type="submit" value="Log In"/>
+  <a href="/register">Register here</a>
+</form>
+</body></html>
+`
+
+func TestGetForms(t *testing.T) {
+	doc, err := LoadHTMLString(testHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := GetForms(doc)
+	if len(forms) != 1 {
+		t.Errorf("expected 1 form, got %d", len(forms))
+	}
+}
+
+func TestGetVisibleFields(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	fields := GetVisibleFields(forms[0])
+
+	// Should get: text input, password input, submit input (hidden is excluded)
+	if len(fields) != 3 {
+		t.Errorf("expected 3 visible fields, got %d", len(fields))
+		for _, f := range fields {
+			name, _ := f.Attr("name")
+			tp, _ := f.Attr("type")
+			t.Logf("  field: name=%s type=%s", name, tp)
+		}
+	}
+}
+
+func TestGetFieldsToAnnotate(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	fields := GetFieldsToAnnotate(forms[0])
+
+	// Submit buttons typically don't have name attr in our test, so:
+	// username + password = 2
+	if len(fields) != 2 {
+		t.Errorf("expected 2 annotatable fields, got %d", len(fields))
+		for _, f := range fields {
+			name, _ := f.Attr("name")
+			t.Logf("  field: %s", name)
+		}
+	}
+}
+
+func TestGetTypeCounts(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	counts := GetTypeCounts(forms[0])
+
+	if counts["text"] != 1 {
+		t.Errorf("text count = %d, want 1", counts["text"])
+	}
+	if counts["password"] != 1 {
+		t.Errorf("password count = %d, want 1", counts["password"])
+	}
+	if counts["hidden"] != 1 {
+		t.Errorf("hidden count = %d, want 1", counts["hidden"])
+	}
+	if counts["submit"] != 1 {
+		t.Errorf("submit count = %d, want 1", counts["submit"])
+	}
+}
+
+func TestGetInputCount(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	count := GetInputCount(forms[0])
+
+	// username, password, csrf = 3 unique named inputs
+	if count != 3 {
+		t.Errorf("input count = %d, want 3", count)
+	}
+}
+
+func TestFindLabel(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	fields := GetFieldsToAnnotate(forms[0])
+
+	label := FindLabel(forms[0], fields[0]) // username field
+	if label == nil {
+		t.Error("expected to find label for username field")
+		return
+	}
+	text := strings.TrimSpace(label.Text())
+	if text != "Username" {
+		t.Errorf("label text = %q, want %q", text, "Username")
+	}
+}
+
+func TestFindLabelPatterns(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><body>
+<h2 id="billing">Billing</h2><span id="name-hint">Full name</span>
+<form>
+  <label for='a"b]'>Quoted</label><input id='a"b]' name="quoted"/>
+  <label for="x\:y">Escaped</label><input id="x:y" name="colon"/>
+  <label>Wrapped <input name="wrapped"/></label>
+  <label for="both">Ignored</label><input id="both" name="both" aria-labelledby="billing name-hint missing"/>
+  <input name="none"/>
+</form></body></html>`)
+	form := GetForms(doc)[0]
+	tests := map[string]string{
+		"quoted":  "Quoted",
+		"colon":   "",
+		"wrapped": "Wrapped",
+		"both":    "Billing Full name",
+		"none":    "",
+	}
+	for name, want := range tests {
+		field := form.Find(`input[name="` + name + `"]`)
+		label := FindLabel(form, field)
+		got := ""
+		if label != nil {
+			got = strings.TrimSpace(strings.Join(label.Map(func(_ int, s *goquery.Selection) string {
+				return s.Text()
+			}), " "))
+		}
+		if got != want {
+			t.Errorf("label of %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFindImplicitLabel(t *testing.T) {
+	doc, _ := LoadHTMLString(`<form>
+  <div class="row"><div><span>E-mail</span></div><div><input name="email"/></div></div>
+  Phone <input name="phone"/>
+  <input name="after-field"/>
+  <label>Explicit</label> <input name="after-label"/>
+  <p>` + strings.Repeat("Long prose that is clearly not a label. ", 3) + `</p><input name="prose"/>
+  <div>Far</div><div><div><div><span></span><span></span><span></span><span></span><span></span><span></span><input name="far"/></div></div></div>
+</form>`)
+	form := GetForms(doc)[0]
+	tests := map[string]string{
+		"email":       "E-mail",
+		"phone":       "Phone",
+		"after-field": "",
+		"after-label": "",
+		"prose":       "",
+		"far":         "",
+	}
+	for name, want := range tests {
+		if got := FindImplicitLabel(form, form.Find(`input[name="`+name+`"]`)); got != want {
+			t.Errorf("implicit label of %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCompiledSelectors(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	form := GetForms(doc)[0]
+	if n := form.FindMatcher(compiled("input, textarea, select")).Length(); n != 4 {
+		t.Errorf("compiled selector matched %d inputs, want 4", n)
+	}
+	if _, ok := compiledSelectors.Load("input, textarea, select"); !ok {
+		t.Error("selector not cached after use")
+	}
+}
+
+func BenchmarkFindLabel(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString("<form>")
+	for i := range 100 {
+		fmt.Fprintf(&sb, `<label for="f%d">Field %d</label><input id="f%d" name="f%d"/>`, i, i, i, i)
+	}
+	sb.WriteString("</form>")
+	doc, _ := LoadHTMLString(sb.String())
+	form := GetForms(doc)[0]
+	fields := GetVisibleFields(form)
+	for b.Loop() {
+		for _, field := range fields {
+			FindLabel(form, field)
+		}
+	}
+}
+
+func TestGetFormMethod(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	method := GetFormMethod(forms[0])
+	if method != "post" {
+		t.Errorf("method = %q, want %q", method, "post")
+	}
+}
+
+func TestGetSubmitTexts(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	text := GetSubmitTexts(forms[0])
+	if text != "Log In" {
+		t.Errorf("submit text = %q, want %q", text, "Log In")
+	}
+}
+
+func TestGetLinksText(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	text := GetLinksText(forms[0])
+	if !strings.Contains(text, "Register here") {
+		t.Errorf("links text = %q, want to contain 'Register here'", text)
+	}
+}
+
+func TestGetFormCSS(t *testing.T) {
+	doc, _ := LoadHTMLString(testHTML)
+	forms := GetForms(doc)
+	css := GetFormCSS(forms[0])
+	if !strings.Contains(css, "auth-form") {
+		t.Errorf("css = %q, want to contain 'auth-form'", css)
+	}
+	if !strings.Contains(css, "login") {
+		t.Errorf("css = %q, want to contain 'login'", css)
+	}
+}
+
+func TestGetTextAroundElems(t *testing.T) {
+	html := `
+<form>
+  Some text before
+  <input type="text" name="field1"/>
+  Middle text
+  <input type="text" name="field2"/>
+  After text
+</form>`
+	doc, _ := LoadHTMLString(html)
+	forms := GetForms(doc)
+	fields := GetFieldsToAnnotate(forms[0])
+
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(fields))
+	}
+
+	ta := GetTextAroundElems(forms[0], fields)
+
+	before1 := ta.Before[fields[0]]
+	if !strings.Contains(before1, "Some text before") {
+		t.Errorf("before field1 = %q, want to contain 'Some text before'", before1)
+	}
+
+	after1 := ta.After[fields[0]]
+	if !strings.Contains(after1, "Middle text") {
+		t.Errorf("after field1 = %q, want to contain 'Middle text'", after1)
+	}
+
+	after2 := ta.After[fields[1]]
+	if !strings.Contains(after2, "After text") {
+		t.Errorf("after field2 = %q, want to contain 'After text'", after2)
+	}
+}
+
+func TestGetFormMethodMissing(t *testing.T) {
+	html := `<form><input type="text" name="q"/></form>`
+	doc, _ := LoadHTMLString(html)
+	forms := GetForms(doc)
+	method := GetFormMethod(forms[0])
+	if method != "MISSING" {
+		t.Errorf("method = %q, want %q", method, "MISSING")
+	}
+}
+
+func TestIsHidden(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><body>
+<form id="a"><input name="x"/></form>
+<div style="display: none"><form id="b"></form></div>
+<form id="c" hidden></form>
+<div class="modal d-none"><form id="d"></form></div>
+</body></html>`)
+	want := map[string]bool{"a": false, "b": true, "c": true, "d": true}
+	for id, hidden := range want {
+		if got := IsHidden(doc.Find("#" + id)); got != hidden {
+			t.Errorf("IsHidden(#%s) = %v, want %v", id, got, hidden)
+		}
+	}
+}
+
+func TestGetLandmark(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><body>
+<header><div><form id="a"></form></div></header>
+<main><section><form id="b"></form></section></main>
+<div role="contentinfo"><form id="c"></form></div>
+<form id="d"></form>
+</body></html>`)
+	want := map[string]string{"a": "header", "b": "main", "c": "footer", "d": ""}
+	for id, landmark := range want {
+		if got := GetLandmark(doc.Find("#" + id)); got != landmark {
+			t.Errorf("GetLandmark(#%s) = %q, want %q", id, got, landmark)
+		}
+	}
+}
+
+func TestFormPositionWrap(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><body>
+<footer><div class="row"><div class="col" style="display:none">
+  <p>Newsletter</p><form id="f"><input name="email"/></form><span>Privacy</span>
+</div></div></footer>
+</body></html>`)
+	pos := GetFormPosition(doc.Find("#f"))
+	want := FormPosition{Landmark: "footer", Depth: 5, Siblings: 2, Hidden: true}
+	if pos != want {
+		t.Fatalf("GetFormPosition() = %+v, want %+v", pos, want)
+	}
+
+	wrapped, _ := LoadHTMLString(pos.Wrap(`<form><input name="email"/></form>`))
+	if got := GetFormPosition(wrapped.Find("form")); got != pos {
+		t.Errorf("GetFormPosition(Wrap()) = %+v, want %+v", got, pos)
+	}
+
+	if got := (FormPosition{}).Wrap("<form></form>"); got != "<form></form>" {
+		t.Errorf("zero Wrap() = %q", got)
+	}
+}
+
+func TestLocators(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><body>
+<form id="login"><input name="user"/><input name="pass" type="password"/></form>
+<div><form><input name="q"/></form><form><input id="dup" name="a"/><input id="dup" name="b"/></form></div>
+<form><input id="1bad" name="c"/></form>
+</body></html>`)
+
+	tests := []struct {
+		name, css, xpath string
+	}{
+		{"user", "#login > input:nth-of-type(1)", "/html/body/form[1]/input[1]"},
+		{"pass", "#login > input:nth-of-type(2)", "/html/body/form[1]/input[2]"},
+		{"q", "html > body > div > form:nth-of-type(1) > input", "/html/body/div/form[1]/input"},
+		{"b", "html > body > div > form:nth-of-type(2) > input:nth-of-type(2)", "/html/body/div/form[2]/input[2]"},
+		{"c", "html > body > form:nth-of-type(2) > input", "/html/body/form[2]/input"},
+	}
+	for _, tt := range tests {
+		elem := doc.Find(fmt.Sprintf(`input[name=%q]`, tt.name))
+		if got := CSSPath(elem); got != tt.css {
+			t.Errorf("CSSPath(%s) = %q, want %q", tt.name, got, tt.css)
+		}
+		if got := XPath(elem); got != tt.xpath {
+			t.Errorf("XPath(%s) = %q, want %q", tt.name, got, tt.xpath)
+		}
+		if found := doc.Find(tt.css); found.Length() != 1 || !found.IsSelection(elem) {
+			t.Errorf("selector %q matches %d elements, want only %s", tt.css, found.Length(), tt.name)
+		}
+	}
+	if CSSPath(doc.Find("nothing")) != "" || XPath(doc.Find("nothing")) != "" {
+		t.Error("empty selection should have no locator")
+	}
+}
+
+func TestFormActionAndMethod(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><head><base href="https://example.com/app/"></head><body>
+<form action="login" method="post"></form>
+<form action="/search" method="Dialog"></form>
+<form method="put"></form>
+</body></html>`)
+	forms := GetForms(doc)
+	tests := []struct{ action, method string }{
+		{"https://example.com/app/login", "POST"},
+		{"https://example.com/search", "DIALOG"},
+		{"", "GET"},
+	}
+	for i, tt := range tests {
+		if got := ResolveFormAction(forms[i]); got != tt.action {
+			t.Errorf("form %d action = %q, want %q", i, got, tt.action)
+		}
+		if got := GetSubmitMethod(forms[i]); got != tt.method {
+			t.Errorf("form %d method = %q, want %q", i, got, tt.method)
+		}
+	}
+
+	doc, _ = LoadHTMLString(`<form action="next?x=1"></form>`)
+	if got := ResolveFormAction(GetForms(doc)[0]); got != "next?x=1" {
+		t.Errorf("action without base = %q", got)
+	}
+}
+
+func TestIsDestructiveLink(t *testing.T) {
+	tests := []struct {
+		html string
+		want bool
+	}{
+		{`<a href="/logout">Bye</a>`, true},
+		{`<a href="/account/delete/42">Go</a>`, true},
+		{`<a href="/session">Log out</a>`, true},
+		{`<a href="/s">  Sign-Out </a>`, true},
+		{`<a href="/p/1?x=2">Add to cart</a>`, true},
+		{`<a href="/n" aria-label="Unsubscribe from emails">x</a>`, true},
+		{`<a href="/posts/1" data-method="delete">Trash</a>`, true},
+		{`<a href="/posts/1" data-method="get">Open</a>`, false},
+		{`<a href="/help/account">How to delete your account</a>`, false},
+		{`<a href="/login">Log in</a>`, false},
+		{`<a href="/cart">Cart</a>`, false},
+	}
+	for _, tt := range tests {
+		doc, _ := LoadHTMLString(tt.html)
+		if got := IsDestructiveLink(doc.Find("a")); got != tt.want {
+			t.Errorf("IsDestructiveLink(%s) = %v, want %v", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeHTML(t *testing.T) {
+	page := `<html><head><script>var x = 1;</script>
+<script src="https://www.google.com/recaptcha/api.js">ignored</script></head>
+<body>
+  <form action="/login">
+    <label>User   name</label>
+    <input name="user">
+    <textarea name="bio">line 1
+  line 2</textarea>
+  </form>
+  <pre>a
+    b</pre>
+</body></html>`
+	got, err := NormalizeHTML(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<script src="https://www.google.com/recaptcha/api.js"></script>`,
+		"<label>User name</label>",
+		"line 1\n  line 2",
+		"<pre>a\n    b</pre>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("normalized page lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "var x") || strings.Contains(got, "\n\n") {
+		t.Errorf("inline script or whitespace runs left:\n%s", got)
+	}
+
+	orig, _ := LoadHTMLString(page)
+	norm, _ := LoadHTMLString(got)
+	words := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	if a, b := words(GetAllFormText(orig.Find("form"))), words(GetAllFormText(norm.Find("form"))); a != b {
+		t.Errorf("form text changed: %q -> %q", a, b)
+	}
+	if a, b := GetCaptchaProviders(orig), GetCaptchaProviders(norm); fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("CAPTCHA providers changed: %v -> %v", a, b)
+	}
+}
+
+func TestJunkPageKind(t *testing.T) {
+	article := "<p>" + strings.Repeat("A long article about gardening and the weather. ", 60) + "</p>"
+	tests := []struct {
+		name, html, want string
+	}{
+		{"cloudflare", `<title>Just a moment...</title><body><script>window._cf_chl_opt={}</script><noscript>Enable JavaScript and cookies to continue</noscript></body>`, JunkBotChallenge},
+		{"challenge text", `<body><h1>example.com</h1><p>Checking if the site connection is secure</p></body>`, JunkBotChallenge},
+		{"akamai", `<title>Access Denied</title><body><h1>Access Denied</h1>You don't have permission to access "http://a.example/login" on this server.<p>Reference #18.6f2d3e17.1700000000.1a2b3c4d</p></body>`, JunkBotChallenge},
+		{"aws waf", `<body><script src="https://abc.token.awswaf.com/abc/challenge.js"></script><div id="challenge-container"></div></body>`, JunkBotChallenge},
+		{"waf script on a real page", `<body>` + article + `<script src="/_Incapsula_Resource?x=1"></script></body>`, ""},
+		{"parked", `<title>example.com</title><body><h1>This domain is for sale!</h1><a href="https://www.hugedomains.com/">Buy</a></body>`, JunkParked},
+		{"parking script", `<body><script src="https://www.sedoparking.com/js/p.js"></script><a href="/x">Related searches</a></body>`, JunkParked},
+		{"app shell", `<title>App</title><body><noscript>You need to enable JavaScript to run this app.</noscript><div id="root"></div><script src="/main.js"></script></body>`, JunkAppShell},
+		{"login shell", `<body><form><input name="user"></form><script src="/main.js"></script></body>`, ""},
+		{"article", `<title>Gardening</title><body>` + article + `<script src="/a.js"></script></body>`, ""},
+	}
+	for _, tt := range tests {
+		doc, _ := LoadHTMLString(tt.html)
+		if got := JunkPageKind(doc); got != tt.want {
+			t.Errorf("%s: JunkPageKind = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsMaintenancePage(t *testing.T) {
+	article := "<p>" + strings.Repeat("A long article about gardening and the weather. ", 60) + "</p>"
+	tests := []struct {
+		name, html string
+		want       bool
+	}{
+		{"back soon", `<title>Maintenance</title><body><h1>We'll be back soon!</h1><p>Sorry for the inconvenience.</p></body>`, true},
+		{"503 maintenance", `<title>503 Service Unavailable</title><body><h1>Down for maintenance</h1></body>`, true},
+		{"503", `<title>503 Service Unavailable</title><body><h1>Service Unavailable</h1><p>The server is temporarily unavailable.</p></body>`, false},
+		{"under construction", `<body><h1>Site under construction</h1></body>`, true},
+		{"404", `<title>404 Not Found</title><body><h1>Not Found</h1></body>`, false},
+		{"announcement", `<body><p>Scheduled maintenance on Sunday.</p>` + article + `</body>`, false},
+	}
+	for _, tt := range tests {
+		doc, _ := LoadHTMLString(tt.html)
+		if got := IsMaintenancePage(doc); got != tt.want {
+			t.Errorf("%s: IsMaintenancePage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetCSRFTokens(t *testing.T) {
+	doc, _ := LoadHTMLString(`<form method="post">
+<input type="hidden" name="csrfmiddlewaretoken" value="abc">
+<input type="HIDDEN" name="_token" value="xyz">
+<input type="hidden" name="c4ca4238a0b923820dcc509a6f75849b" value="1">
+<input type="hidden" name="sec_token" value="q8Zr1xLk3Vb9TmW2pYc7">
+<input type="hidden" name="page_token" value="aaaaaaaaaaaaaaaaaaaa">
+<input type="hidden" name="__VIEWSTATE" value="dDwtMTI3OTMzNDM4NDs7PiNNE0Jj8yKxTmnf">
+<input type="hidden" name="redirect" value="/home">
+<input type="hidden" name="keyword_id" value="x7Fq2LmZ9pRt4VcB8nKw">
+<input type="hidden" name="statement" value="H3kd9QmX2vLp7RtZ5bYn">
+<input type="hidden" name="oauthState" value="Tz4Lq8Wm1Xc6Vb3Nk9Rp">
+<input type="hidden" name="authenticity_token" value="">
+<input type="text" name="csrf_note" value="not hidden">
+</form>`)
+	got := GetCSRFTokens(doc.Find("form"))
+	want := []CSRFToken{
+		{Name: "csrfmiddlewaretoken", Value: "abc"},
+		{Name: "_token", Value: "xyz"},
+		{Name: "c4ca4238a0b923820dcc509a6f75849b", Value: "1"},
+		{Name: "sec_token", Value: "q8Zr1xLk3Vb9TmW2pYc7"},
+		{Name: "oauthState", Value: "Tz4Lq8Wm1Xc6Vb3Nk9Rp"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCSRFTokens = %+v, want %+v", got, want)
+	}
+
+	for name, want := range map[string]string{"oauth_state": "oauth state", "OAuthState": "o auth state", "apiKey2": "api key2", "X-CSRF-Token": "x csrf token", "HTMLSig": "html sig", "APIKey": "api key"} {
+		if got := strings.Join(nameWords(name), " "); got != want {
+			t.Errorf("nameWords(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if e := Entropy("aaaa"); e != 0 {
+		t.Errorf("Entropy(aaaa) = %v, want 0", e)
+	}
+	if e := Entropy("abcd"); e != 2 {
+		t.Errorf("Entropy(abcd) = %v, want 2", e)
+	}
+}
+
+func TestOneTimeCodeInputs(t *testing.T) {
+	tests := []struct {
+		html         string
+		otp, numeric bool
+	}{
+		{`<input name="code" autocomplete="one-time-code"/>`, true, false},
+		{`<input name="otp" inputmode="numeric" maxlength="6"/>`, true, true},
+		{`<input type="tel" name="two_factor_code" maxlength="6"/>`, true, true},
+		{`<input name="verification_code" pattern="[0-9]*" maxlength="6"/>`, true, true},
+		{`<input name="user[mfa]"/>`, true, false},
+		{`<input name="digit1" inputmode="numeric" maxlength="1"/>`, false, true},
+		{`<input name="zip" pattern="\d{5}" maxlength="5"/>`, false, true},
+		{`<input name="postal_code"/>`, false, false},
+		{`<input name="security_code" maxlength="4"/>`, false, false},
+		{`<input type="hidden" name="otp"/>`, false, false},
+		{`<input type="tel" name="phone" maxlength="15"/>`, false, false},
+		{`<textarea name="otp"></textarea>`, false, false},
+	}
+	for _, tt := range tests {
+		doc, _ := LoadHTMLString("<form>" + tt.html + "</form>")
+		elem := doc.Find("form").Children().First()
+		if got := IsOneTimeCodeInput(elem); got != tt.otp {
+			t.Errorf("IsOneTimeCodeInput(%s) = %v, want %v", tt.html, got, tt.otp)
+		}
+		if got := IsShortNumericInput(elem); got != tt.numeric {
+			t.Errorf("IsShortNumericInput(%s) = %v, want %v", tt.html, got, tt.numeric)
+		}
+	}
+}
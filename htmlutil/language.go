@@ -0,0 +1,221 @@
+package htmlutil
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// maxLanguageText bounds how much page text DetectLanguage reads.
+const maxLanguageText = 8 << 10
+
+// minLanguageLetters is the fewest letters DetectLanguage guesses from.
+const minLanguageLetters = 20
+
+// scriptLanguages maps scripts written by essentially one language, as far
+// as the classifier's training data goes, to that language. Latin and Han
+// are told apart by the text itself.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// stopwords are frequent short words that mark Latin-script languages.
+// A word listed for several languages counts for each.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "your", "you", "with", "this", "are", "for", "not"},
+	"fr": {"le", "la", "les", "et", "des", "du", "vous", "votre", "pour", "est", "une", "pas", "avec"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "sie", "mit", "für", "ein", "eine", "oder", "ihre"},
+	"es": {"el", "los", "las", "y", "que", "con", "para", "su", "una", "por", "del", "tu"},
+	"pt": {"o", "os", "as", "e", "que", "com", "para", "uma", "não", "seu", "sua", "você", "do", "da"},
+	"it": {"il", "gli", "e", "che", "con", "per", "una", "non", "del", "della", "sono", "tuo"},
+	"nl": {"de", "het", "een", "en", "van", "niet", "met", "voor", "uw", "je", "zijn"},
+	"tr": {"ve", "bir", "bu", "için", "ile", "veya", "olarak", "değil", "şifre"},
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			index[w] = append(index[w], lang)
+		}
+	}
+	return index
+}()
+
+// DetectLanguage returns the language of the page sel belongs to as a
+// lowercase ISO 639-1 code such as "en" or "fr", or "" if it cannot tell.
+// Pass doc.Selection for a whole document. A lang or xml:lang attribute on
+// <html>, or a Content-Language meta tag, wins; otherwise the language is
+// guessed from the page's visible text: the script most of its letters are
+// written in and, for Latin script, which language's common words it uses
+// most.
+func DetectLanguage(sel *goquery.Selection) string {
+	if len(sel.Nodes) == 0 {
+		return ""
+	}
+	root := sel.Nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	var htmlElem, body *html.Node
+	var meta string
+	eachDescendant(root, func(n *html.Node) {
+		switch n.Data {
+		case "html":
+			if htmlElem == nil {
+				htmlElem = n
+			}
+		case "body":
+			if body == nil {
+				body = n
+			}
+		case "meta":
+			if v, _ := attr(n, "http-equiv"); meta == "" && strings.EqualFold(v, "content-language") {
+				meta, _ = attr(n, "content")
+			}
+		}
+	})
+	if htmlElem != nil {
+		for _, key := range []string{"lang", "xml:lang"} {
+			if v, _ := attr(htmlElem, key); primaryLanguage(v) != "" {
+				return primaryLanguage(v)
+			}
+		}
+	}
+	// Content-Language may list several languages; the first is the main one.
+	if lang := primaryLanguage(strings.Split(meta, ",")[0]); lang != "" {
+		return lang
+	}
+	if body == nil {
+		body = root
+	}
+	return guessLanguage(visibleText(body, maxLanguageText))
+}
+
+// primaryLanguage returns the primary subtag of a language tag such as
+// "en-US" or "pt_BR".
+func primaryLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	return tag
+}
+
+// visibleText returns the text below n, leaving out scripts and styles,
+// up to about limit bytes.
+func visibleText(n *html.Node, limit int) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil && b.Len() < limit; c = c.NextSibling {
+			switch {
+			case c.Type == html.TextNode:
+				b.WriteString(c.Data)
+				b.WriteByte(' ')
+			case c.Type == html.ElementNode && (c.Data == "script" || c.Data == "style" || c.Data == "noscript" || c.Data == "template"):
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// guessLanguage guesses the language of text from character statistics.
+func guessLanguage(text string) string {
+	counts := make(map[string]int)
+	latin, letters := 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, r) {
+			latin++
+			continue
+		}
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if letters < minLanguageLetters {
+		return ""
+	}
+
+	// Japanese mixes kana with Han characters.
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	best, bestCount := "", latin
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	if best != "" {
+		return refineScriptLanguage(best, text)
+	}
+	return guessLatinLanguage(text)
+}
+
+// refineScriptLanguage tells apart the languages sharing a script by the
+// letters only some of them use.
+func refineScriptLanguage(lang, text string) string {
+	switch {
+	case lang == "ru" && strings.ContainsAny(text, "іїєґІЇЄҐ"):
+		return "uk"
+	case lang == "ar" && strings.ContainsAny(text, "پچژگ"):
+		return "fa"
+	}
+	return lang
+}
+
+// guessLatinLanguage returns the language whose stopwords occur most often
+// in text, or "" for fewer than two hits or a tie.
+func guessLatinLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range stopwordLanguages[word] {
+			counts[lang]++
+		}
+	}
+	best, bestCount, tie := "", 1, false
+	for lang, n := range counts {
+		switch {
+		case n > bestCount:
+			best, bestCount, tie = lang, n, false
+		case n == bestCount:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,35 @@
+package htmlutil
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name, html, want string
+	}{
+		{"lang attribute", `<html lang="pt-BR"><body>Sign in to your account</body></html>`, "pt"},
+		{"xml:lang", `<html xml:lang="de"><body></body></html>`, "de"},
+		{"content-language", `<html><head><meta http-equiv="Content-Language" content="fr, en"></head><body></body></html>`, "fr"},
+		{"english text", `<html><body><p>Sign in to your account with the password you chose.</p></body></html>`, "en"},
+		{"french text", `<html><body><p>Connectez-vous avec votre identifiant et le mot de passe pour accéder à votre compte.</p></body></html>`, "fr"},
+		{"german text", `<html><body><p>Bitte melden Sie sich mit Ihrem Benutzernamen und dem Passwort für das Konto an.</p></body></html>`, "de"},
+		{"russian text", `<html><body><p>Войдите в свою учетную запись, чтобы продолжить работу.</p></body></html>`, "ru"},
+		{"japanese text", `<html><body><p>ログインしてください。パスワードを忘れた場合はこちら。登録</p></body></html>`, "ja"},
+		{"script ignored", `<html><body><script>var the = "and the of the to the is";</script><p>Войдите в свою учетную запись, чтобы продолжить.</p></body></html>`, "ru"},
+		{"too little text", `<html><body>Login</body></html>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := LoadHTMLString(tt.html)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DetectLanguage(doc.Selection); got != tt.want {
+				t.Errorf("DetectLanguage() = %q, want %q", got, tt.want)
+			}
+			// A form or other element reports its page's language.
+			if got := DetectLanguage(doc.Find("body")); got != tt.want {
+				t.Errorf("DetectLanguage(body) = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package htmlutil
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// destructiveHref matches link URLs that change state when fetched.
+var destructiveHref = regexp.MustCompile(`(?i)(/(log-?out|sign-?out|log-?off|delete|destroy|remove|unsubscribe|add[-_]to[-_](cart|basket|bag))\b|[?&](action|do)=(logout|signout|delete|remove)\b|[?&](add-to-cart|remove_item)=)`)
+
+// destructiveText matches link texts that start with a state-changing
+// action. Only the start is checked so that e.g. "How to delete your
+// account" still counts as an ordinary link.
+var destructiveText = regexp.MustCompile(`^(log ?out|sign ?out|log ?off|delete|remove|unsubscribe|deactivate|close (my |your )?account|cancel (my |your )?subscription|empty (the )?(cart|basket)|add to (cart|basket|bag))\b`)
+
+// IsDestructiveLink reports whether following the <a> element a would
+// likely change state on the server, such as logging out, deleting
+// something, unsubscribing, or adding to a cart. It looks at the href,
+// the link text, title and aria-label, and a data-method attribute asking
+// for a non-GET request.
+func IsDestructiveLink(a *goquery.Selection) bool {
+	if method, ok := a.Attr("data-method"); ok && !strings.EqualFold(strings.TrimSpace(method), "get") {
+		return true
+	}
+	if destructiveHref.MatchString(a.AttrOr("href", "")) {
+		return true
+	}
+	for _, text := range []string{a.Text(), a.AttrOr("title", ""), a.AttrOr("aria-label", "")} {
+		text = strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(text, "-", " ")), " "))
+		if destructiveText.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package htmlutil
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// plainID matches ids usable in a CSS selector without escaping.
+var plainID = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// CSSPath returns a CSS selector for the first node of sel that matches it
+// alone in its document. The path starts at the closest ancestor-or-self
+// with a unique plain id, or at <html>, and uses :nth-of-type where
+// siblings share a tag.
+func CSSPath(sel *goquery.Selection) string {
+	if sel.Length() == 0 {
+		return ""
+	}
+	n := sel.Nodes[0]
+	root := documentRoot(n)
+
+	var parts []string
+	for ; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		if id, ok := attr(n, "id"); ok && plainID.MatchString(id) && countIDs(root, id) == 1 {
+			parts = append(parts, "#"+id)
+			break
+		}
+		part := n.Data
+		if idx, total := typeIndex(n); total > 1 {
+			part += fmt.Sprintf(":nth-of-type(%d)", idx)
+		}
+		parts = append(parts, part)
+	}
+	slices.Reverse(parts)
+	return strings.Join(parts, " > ")
+}
+
+// XPath returns an absolute XPath for the first node of sel, with a
+// position predicate where siblings share a tag.
+func XPath(sel *goquery.Selection) string {
+	if sel.Length() == 0 {
+		return ""
+	}
+	var parts []string
+	for n := sel.Nodes[0]; n != nil && n.Type == html.ElementNode; n = n.Parent {
+		part := n.Data
+		if idx, total := typeIndex(n); total > 1 {
+			part += fmt.Sprintf("[%d]", idx)
+		}
+		parts = append(parts, part)
+	}
+	slices.Reverse(parts)
+	return "/" + strings.Join(parts, "/")
+}
+
+// FormIndex returns the position of the first node of sel among its
+// document's forms, as in GetForms: the number of <form> elements that
+// precede it in document order.
+func FormIndex(sel *goquery.Selection) int {
+	if sel.Length() == 0 {
+		return 0
+	}
+	target := sel.Nodes[0]
+	index := 0
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n == target {
+			return true
+		}
+		if n.Type == html.ElementNode && n.Data == "form" {
+			index++
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(documentRoot(target))
+	return index
+}
+
+// typeIndex returns the 1-based position of n among its sibling elements
+// with the same tag, and how many such siblings there are.
+func typeIndex(n *html.Node) (idx, total int) {
+	if n.Parent == nil {
+		return 1, 1
+	}
+	for c := n.Parent.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == n.Data {
+			total++
+			if c == n {
+				idx = total
+			}
+		}
+	}
+	return idx, total
+}
+
+func documentRoot(n *html.Node) *html.Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
+
+// countIDs returns how many elements below root have the given id.
+func countIDs(root *html.Node, id string) int {
+	count := 0
+	eachDescendant(root, func(n *html.Node) {
+		if v, _ := attr(n, "id"); v == id {
+			count++
+		}
+	})
+	return count
+}
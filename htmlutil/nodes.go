@@ -0,0 +1,160 @@
+package htmlutil
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// The feature extractors below run for every form at training and
+// classification time. They walk the *html.Node tree of a single-node
+// selection directly instead of going through goquery's Find and Each,
+// which allocate a Selection per match. Multi-node selections, rare in
+// practice, take the goquery path so results match Find's deduplication.
+
+// singleNode returns the only node of sel, or nil if sel holds zero or
+// several nodes.
+func singleNode(sel *goquery.Selection) *html.Node {
+	if len(sel.Nodes) != 1 {
+		return nil
+	}
+	return sel.Nodes[0]
+}
+
+// eachDescendant calls fn for every element below n in document order,
+// as goquery's Find does.
+func eachDescendant(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		eachDescendant(c, fn)
+	}
+}
+
+// findLabelFor returns the first <label> below n whose for attribute is id,
+// comparing the value directly rather than building a selector per id.
+func findLabelFor(n *html.Node, id string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "label" {
+			if f, _ := attr(c, "for"); f == id {
+				return c
+			}
+		}
+		if label := findLabelFor(c, id); label != nil {
+			return label
+		}
+	}
+	return nil
+}
+
+// findByID returns the first element below n whose id is id.
+func findByID(n *html.Node, id string) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			if v, _ := attr(c, "id"); v == id {
+				return c
+			}
+		}
+		if found := findByID(c, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// previousNode returns the node before n in document order, or nil at
+// root: the deepest last descendant of the previous sibling, else the
+// parent.
+func previousNode(n, root *html.Node) *html.Node {
+	if n.PrevSibling == nil {
+		if n.Parent == root {
+			return nil
+		}
+		return n.Parent
+	}
+	n = n.PrevSibling
+	for n.LastChild != nil {
+		n = n.LastChild
+	}
+	return n
+}
+
+// inLabel reports whether n sits inside a <label> below root.
+func inLabel(n, root *html.Node) bool {
+	for p := n.Parent; p != nil && p != root; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == "label" {
+			return true
+		}
+	}
+	return false
+}
+
+// attr returns the first attribute of n named key, like Selection.Attr.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// isHiddenInput reports whether n is an input of type hidden.
+func isHiddenInput(n *html.Node) bool {
+	tp, _ := attr(n, "type")
+	return strings.EqualFold(tp, "hidden")
+}
+
+func typeCountsNode(form *html.Node) map[string]int {
+	counts := make(map[string]int)
+	eachDescendant(form, func(n *html.Node) {
+		switch n.Data {
+		case "textarea":
+			counts["textarea"]++
+		case "select":
+			counts["select"]++
+		case "input":
+			tp, exists := attr(n, "type")
+			if !exists {
+				tp = "text"
+			}
+			counts[strings.ToLower(tp)]++
+		}
+	})
+	return counts
+}
+
+func inputNamesNode(form *html.Node) string {
+	var names []string
+	eachDescendant(form, func(n *html.Node) {
+		if n.Data != "input" || isHiddenInput(n) {
+			return
+		}
+		if name, exists := attr(n, "name"); exists {
+			names = append(names, cleanInputName(name))
+		}
+	})
+	return strings.Join(names, " ")
+}
+
+func inputCSSNode(form *html.Node) string {
+	var parts []string
+	eachDescendant(form, func(n *html.Node) {
+		if n.Data != "input" || isHiddenInput(n) {
+			return
+		}
+		class, _ := attr(n, "class")
+		id, _ := attr(n, "id")
+		parts = append(parts, class+" "+id)
+	})
+	return strings.Join(parts, " ")
+}
+
+// cleanInputName drops the underscores and brackets of a field name.
+func cleanInputName(name string) string {
+	name = strings.ReplaceAll(name, "_", "")
+	name = strings.ReplaceAll(name, "[", "")
+	return strings.ReplaceAll(name, "]", "")
+}
@@ -0,0 +1,161 @@
+package htmlutil
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// equivalenceHTML exercises the cases the node walkers must agree with
+// goquery on: missing and mixed-case types, hidden inputs, duplicate
+// attributes, nested containers, and elements outside any form.
+const equivalenceHTML = `<html><body>
+<input name="outside" type="text"/>
+<form id="a" class="f">
+  <div><p><input name="user_name[first]" class="c1 c2" id="u"/></p></div>
+  <input type="PASSWORD" name="pass" class="pw" title="Password"/>
+  <input type="Hidden" name="csrf"/>
+  <input type="checkbox" name="remember" name="dup" class="x" class="y"/>
+  <input/>
+  <textarea name="msg"></textarea>
+  <select name="country"><option>TR</option></select>
+  <fieldset><legend>More</legend><input type="email" id="mail" required/></fieldset>
+  <label>Remember <input type="checkbox" name="remember" title="Stay"/> me <a href="/why">why?</a></label>
+  <a href="/reset">Forgot <b>password</b>?</a>
+  <select name="pick" required></select>
+  <input type="submit" value="Go"/><input type="SUBMIT" value="Ignored"/><input type="submit"/>
+  <button type="submit">Go</button>
+</form>
+<form id="empty"></form>
+<form id="b"><table><tr><td><input type="search" name="q"></td></tr></table></form>
+<form id="otp"><input name="otp" inputmode="numeric" maxlength="6" autocomplete="one-time-code"/><input type="tel" name="zip" maxlength="5"/></form>
+</body></html>`
+
+func TestNodeExtractorsMatchGoquery(t *testing.T) {
+	doc, err := LoadHTMLString(equivalenceHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forms := GetForms(doc)
+	if len(forms) != 4 {
+		t.Fatalf("got %d forms, want 4", len(forms))
+	}
+	for _, form := range append(forms, doc.Find("form"), doc.Selection) {
+		id := form.AttrOr("id", "document")
+		if got, want := GetTypeCounts(form), typeCountsSelection(form); !maps.Equal(got, want) {
+			t.Errorf("%s: GetTypeCounts = %v, goquery = %v", id, got, want)
+		}
+		if got, want := GetInputNames(form), inputNamesSelection(form); got != want {
+			t.Errorf("%s: GetInputNames = %q, goquery = %q", id, got, want)
+		}
+		if got, want := GetInputCSS(form), inputCSSSelection(form); got != want {
+			t.Errorf("%s: GetInputCSS = %q, goquery = %q", id, got, want)
+		}
+	}
+
+	counts := GetTypeCounts(forms[0])
+	if counts["text"] != 2 || counts["password"] != 1 || counts["hidden"] != 1 || counts["textarea"] != 1 {
+		t.Errorf("type counts = %v", counts)
+	}
+	if got := GetInputNames(forms[0]); got != "usernamefirst pass remember remember" {
+		t.Errorf("input names = %q", got)
+	}
+}
+
+func TestFormViewMatchesGetters(t *testing.T) {
+	doc, err := LoadHTMLString(equivalenceHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, form := range append(GetForms(doc), doc.Find("form")) {
+		want := &FormView{
+			Form:          form,
+			TypeCounts:    typeCountsSelection(form),
+			InputCount:    GetInputCount(form),
+			RequiredCount: GetRequiredCount(form),
+			OneTimeCodes:  GetOneTimeCodeCount(form),
+			ShortNumerics: GetShortNumericCount(form),
+			Method:        GetFormMethod(form),
+			Action:        GetFormAction(form),
+			CSS:           GetFormCSS(form),
+			SubmitTexts:   GetSubmitTexts(form),
+			LinksText:     GetLinksText(form),
+			LabelText:     GetLabelText(form),
+			InputNames:    inputNamesSelection(form),
+			InputCSS:      inputCSSSelection(form),
+			InputTitles:   GetInputTitles(form),
+			Position:      GetFormPosition(form),
+		}
+		if got := NewFormView(form); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: NewFormView =\n%+v\nwant\n%+v", form.AttrOr("id", "all"), got, want)
+		}
+	}
+}
+
+func benchmarkForm(b *testing.B) *goquery.Selection {
+	b.Helper()
+	var sb strings.Builder
+	sb.WriteString("<form>")
+	for i := range 50 {
+		sb.WriteString(`<div class="row"><label>Field</label><input type="text" name="field_` +
+			string(rune('a'+i%26)) + `" class="form-control" id="f"/></div>`)
+	}
+	sb.WriteString(`<input type="hidden" name="csrf"/><select name="s"></select><textarea name="t"></textarea></form>`)
+	doc, err := LoadHTMLString(sb.String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return GetForms(doc)[0]
+}
+
+func BenchmarkGetTypeCounts(b *testing.B) {
+	form := benchmarkForm(b)
+	b.Run("node", func(b *testing.B) {
+		for b.Loop() {
+			GetTypeCounts(form)
+		}
+	})
+	b.Run("goquery", func(b *testing.B) {
+		for b.Loop() {
+			typeCountsSelection(form)
+		}
+	})
+}
+
+func BenchmarkGetInputNames(b *testing.B) {
+	form := benchmarkForm(b)
+	b.Run("node", func(b *testing.B) {
+		for b.Loop() {
+			GetInputNames(form)
+		}
+	})
+	b.Run("goquery", func(b *testing.B) {
+		for b.Loop() {
+			inputNamesSelection(form)
+		}
+	})
+}
+
+func BenchmarkNewFormView(b *testing.B) {
+	form := benchmarkForm(b)
+	for b.Loop() {
+		NewFormView(form)
+	}
+}
+
+func BenchmarkGetInputCSS(b *testing.B) {
+	form := benchmarkForm(b)
+	b.Run("node", func(b *testing.B) {
+		for b.Loop() {
+			GetInputCSS(form)
+		}
+	})
+	b.Run("goquery", func(b *testing.B) {
+		for b.Loop() {
+			inputCSSSelection(form)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package htmlutil
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// NormalizeHTML returns a smaller copy of a page for training datasets:
+// inline scripts are removed, external <script src> tags are kept empty
+// (CAPTCHA and SSO detection look at their URLs), and runs of whitespace
+// in text outside <pre> and <textarea> collapse to a single space. Forms,
+// attributes, and visible text are unchanged, so features extracted from
+// the result match those of the original.
+func NormalizeHTML(htmlStr string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlStr))
+	if err != nil {
+		return "", err
+	}
+	normalizeNode(doc)
+	var b strings.Builder
+	if err := html.Render(&b, doc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func normalizeNode(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch {
+		case c.Type == html.ElementNode && c.Data == "script":
+			if _, ok := attr(c, "src"); ok {
+				for c.FirstChild != nil {
+					c.RemoveChild(c.FirstChild)
+				}
+			} else {
+				n.RemoveChild(c)
+			}
+		case c.Type == html.ElementNode && (c.Data == "pre" || c.Data == "textarea"):
+		case c.Type == html.TextNode:
+			c.Data = collapseSpace(c.Data)
+		default:
+			normalizeNode(c)
+		}
+		c = next
+	}
+}
+
+// collapseSpace replaces each run of HTML whitespace in s with one space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', '\n', '\r', '\f':
+			space = true
+		default:
+			if space {
+				b.WriteByte(' ')
+				space = false
+			}
+			b.WriteByte(s[i])
+		}
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package htmlutil
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// otpNames matches the names and ids sites give one-time code inputs of
+// two-factor (MFA) challenges: otp, totp, 2fa, mfa, verification_code,
+// two_factor_code, and so on.
+var otpNames = regexp.MustCompile(`(?i)(^|[^a-z])(otp|totp|hotp|mfa|2fa|tfa)([^a-z]|$)|one.?time|two.?factor|(verif\w*|auth\w*|sms|login|2fa|mfa).?code|passcode`)
+
+// digitPattern matches pattern attributes that only accept digits, e.g.
+// "[0-9]*" or "\d{6}".
+var digitPattern = regexp.MustCompile(`^(\[0-9\]|\\d)([*+]|\{\d+(,\d*)?\})?$`)
+
+// attrFunc looks up an attribute of an element, like Selection.Attr.
+type attrFunc func(key string) (string, bool)
+
+// IsOneTimeCodeInput reports whether elem asks for the one-time code of a
+// two-factor challenge: an input with autocomplete="one-time-code" or
+// named like otp, 2fa, or verification_code.
+func IsOneTimeCodeInput(elem *goquery.Selection) bool {
+	return goquery.NodeName(elem) == "input" && isOneTimeCode(elem.Attr)
+}
+
+// IsShortNumericInput reports whether elem takes a few digits: an input
+// with inputmode="numeric", type number or tel, or a digits-only pattern,
+// and a maxlength of at most 8. One-time codes, PINs, and postal codes
+// look like this.
+func IsShortNumericInput(elem *goquery.Selection) bool {
+	return goquery.NodeName(elem) == "input" && isShortNumeric(elem.Attr)
+}
+
+// GetOneTimeCodeCount counts the one-time code inputs of form; see
+// IsOneTimeCodeInput.
+func GetOneTimeCodeCount(form *goquery.Selection) int {
+	return form.FindMatcher(compiled("input")).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return isOneTimeCode(s.Attr)
+	}).Length()
+}
+
+// GetShortNumericCount counts the short numeric inputs of form; see
+// IsShortNumericInput.
+func GetShortNumericCount(form *goquery.Selection) int {
+	return form.FindMatcher(compiled("input")).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return isShortNumeric(s.Attr)
+	}).Length()
+}
+
+func isOneTimeCode(attr attrFunc) bool {
+	if ac, _ := attr("autocomplete"); strings.Contains(strings.ToLower(ac), "one-time-code") {
+		return true
+	}
+	switch tp, _ := attr("type"); strings.ToLower(strings.TrimSpace(tp)) {
+	case "", "text", "tel", "number", "password":
+	default:
+		return false
+	}
+	name, _ := attr("name")
+	id, _ := attr("id")
+	return otpNames.MatchString(name) || otpNames.MatchString(id)
+}
+
+func isShortNumeric(attr attrFunc) bool {
+	maxLength, _ := attr("maxlength")
+	n, err := strconv.Atoi(strings.TrimSpace(maxLength))
+	if err != nil || n < 1 || n > 8 {
+		return false
+	}
+	tp, _ := attr("type")
+	mode, _ := attr("inputmode")
+	pattern, _ := attr("pattern")
+	switch {
+	case strings.EqualFold(strings.TrimSpace(mode), "numeric"):
+		return true
+	case strings.EqualFold(tp, "number") || strings.EqualFold(tp, "tel"):
+		return true
+	}
+	return digitPattern.MatchString(strings.TrimSpace(pattern))
+}
+
+// nodeAttr adapts attr to an attrFunc for n.
+func nodeAttr(n *html.Node) attrFunc {
+	return func(key string) (string, bool) { return attr(n, key) }
+}
@@ -0,0 +1,643 @@
+package htmlutil
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// GetPageTitle returns the <title> text content.
+func GetPageTitle(doc *goquery.Document) string {
+	return strings.TrimSpace(doc.FindMatcher(compiled("title")).First().Text())
+}
+
+// GetMetaDescription returns the content of <meta name="description">.
+func GetMetaDescription(doc *goquery.Document) string {
+	content, _ := doc.FindMatcher(compiled(`meta[name="description"]`)).Attr("content")
+	if content == "" {
+		content, _ = doc.FindMatcher(compiled(`meta[name="Description"]`)).Attr("content")
+	}
+	return strings.TrimSpace(content)
+}
+
+// GetMetaKeywords returns the content of <meta name="keywords">.
+func GetMetaKeywords(doc *goquery.Document) string {
+	content, _ := doc.FindMatcher(compiled(`meta[name="keywords"]`)).Attr("content")
+	if content == "" {
+		content, _ = doc.FindMatcher(compiled(`meta[name="Keywords"]`)).Attr("content")
+	}
+	return strings.TrimSpace(content)
+}
+
+// GetMetaRobots returns the content of <meta name="robots">.
+func GetMetaRobots(doc *goquery.Document) string {
+	content, _ := doc.FindMatcher(compiled(`meta[name="robots"]`)).Attr("content")
+	return strings.TrimSpace(content)
+}
+
+// GetHeadings returns concatenated text of all h1-h6 elements.
+func GetHeadings(doc *goquery.Document) string {
+	var parts []string
+	doc.FindMatcher(compiled("h1, h2, h3, h4, h5, h6")).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" {
+			parts = append(parts, text)
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetH1Text returns concatenated text of all <h1> elements.
+func GetH1Text(doc *goquery.Document) string {
+	var parts []string
+	doc.FindMatcher(compiled("h1")).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" {
+			parts = append(parts, text)
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetNavText returns concatenated text of all <nav> elements.
+func GetNavText(doc *goquery.Document) string {
+	var parts []string
+	doc.FindMatcher(compiled("nav")).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" {
+			parts = append(parts, text)
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetPageLinkTexts returns concatenated text of all <a> elements.
+func GetPageLinkTexts(doc *goquery.Document) string {
+	var parts []string
+	doc.FindMatcher(compiled("a")).Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if text != "" {
+			parts = append(parts, text)
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetBodyText returns visible text from the body, truncated for feature extraction.
+func GetBodyText(doc *goquery.Document, maxLen int) string {
+	text := strings.TrimSpace(doc.FindMatcher(compiled("body")).Text())
+	// Collapse whitespace
+	fields := strings.Fields(text)
+	text = strings.Join(fields, " ")
+	if maxLen > 0 && len(text) > maxLen {
+		text = text[:maxLen]
+	}
+	return text
+}
+
+// GetPageCSS returns class and id attributes from <body> and <main> elements.
+func GetPageCSS(doc *goquery.Document) string {
+	var parts []string
+	doc.FindMatcher(compiled("body, main")).Each(func(_ int, s *goquery.Selection) {
+		if class, exists := s.Attr("class"); exists && class != "" {
+			parts = append(parts, class)
+		}
+		if id, exists := s.Attr("id"); exists && id != "" {
+			parts = append(parts, id)
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// GetPageStructure returns structural boolean features and counts about the page.
+func GetPageStructure(doc *goquery.Document) map[string]any {
+	features := make(map[string]any)
+
+	formCount := doc.FindMatcher(compiled("form")).Length()
+	features["has_form"] = boolToFloat(formCount > 0)
+	features["form_count"] = float64(formCount)
+	features["has_nav"] = boolToFloat(doc.FindMatcher(compiled("nav")).Length() > 0)
+	features["has_header"] = boolToFloat(doc.FindMatcher(compiled("header")).Length() > 0)
+	features["has_footer"] = boolToFloat(doc.FindMatcher(compiled("footer")).Length() > 0)
+	features["has_article"] = boolToFloat(doc.FindMatcher(compiled("article")).Length() > 0)
+	features["has_aside"] = boolToFloat(doc.FindMatcher(compiled("aside")).Length() > 0)
+	features["has_main"] = boolToFloat(doc.FindMatcher(compiled("main")).Length() > 0)
+	features["has_table"] = boolToFloat(doc.FindMatcher(compiled("table")).Length() > 0)
+	features["has_video"] = boolToFloat(doc.FindMatcher(compiled("video")).Length() > 0)
+	features["has_iframe"] = boolToFloat(doc.FindMatcher(compiled("iframe")).Length() > 0)
+
+	// Password field indicates login/registration
+	features["has_password"] = boolToFloat(doc.FindMatcher(compiled(`input[type="password"]`)).Length() > 0)
+
+	// Link count
+	linkCount := doc.FindMatcher(compiled("a")).Length()
+	features["link_count_bucket"] = linkCountBucket(linkCount)
+
+	// Image count
+	imgCount := doc.FindMatcher(compiled("img")).Length()
+	features["img_count_bucket"] = imgCountBucket(imgCount)
+
+	// Content length bucket
+	bodyText := doc.FindMatcher(compiled("body")).Text()
+	features["content_length_bucket"] = contentLengthBucket(len(bodyText))
+
+	// Heading count
+	features["heading_count"] = float64(doc.FindMatcher(compiled("h1, h2, h3, h4, h5, h6")).Length())
+
+	// Error indicators (merged in)
+	maps.Copy(features, GetErrorIndicators(doc))
+	maps.Copy(features, GetCommerceIndicators(doc))
+	maps.Copy(features, GetDocsIndicators(doc))
+	maps.Copy(features, GetAccountIndicators(doc))
+
+	return features
+}
+
+// pageKeywords are the phrases GetErrorIndicators looks for in the title,
+// h1, and body text, by feature name suffix (title_has_<name>, ...).
+var pageKeywords = []struct {
+	name    string
+	keyword string
+}{
+	{"404", "404"},
+	{"not_found", "not found"},
+	{"page_not_found", "page not found"},
+	{"does_not_exist", "does not exist"},
+	{"no_longer_available", "no longer available"},
+	{"access_denied", "access denied"},
+	{"forbidden", "forbidden"},
+	{"unauthorized", "unauthorized"},
+	{"server_error", "server error"},
+	{"internal_error", "internal server error"},
+	{"captcha", "captcha"},
+	{"cloudflare", "cloudflare"},
+	{"challenge", "challenge"},
+	{"verify_human", "verify you are human"},
+	{"domain_parking", "domain parking"},
+	{"parked_domain", "parked domain"},
+	{"domain_for_sale", "domain is for sale"},
+	{"may_be_for_sale", "may be for sale"},
+	{"buy_this_domain", "buy this domain"},
+	{"make_offer", "make an offer"},
+	{"domain_owner", "domain owner"},
+	{"coming_soon", "coming soon"},
+	{"under_construction", "under construction"},
+	{"maintenance", "maintenance"},
+	{"be_back_soon", "be back soon"},
+	{"temporarily_unavailable", "temporarily unavailable"},
+	{"service_unavailable", "service unavailable"},
+	{"503", "503"},
+	{"launching_soon", "launching soon"},
+	{"welcome_nginx", "welcome to nginx"},
+	{"apache_default", "apache2 default page"},
+	{"iis_default", "iis windows server"},
+	{"index_of", "index of /"},
+	{"directory_listing", "directory listing"},
+	{"waf_block", "blocked"},
+	{"bot_detection", "bot"},
+	{"admin_panel", "admin"},
+	{"dashboard", "dashboard"},
+	{"login", "log in"},
+	{"sign_in", "sign in"},
+	{"pricing", "pricing"},
+	{"plans", "plans"},
+	{"free_trial", "free trial"},
+	{"checkout", "checkout"},
+	{"shopping_cart", "shopping cart"},
+	{"your_cart", "your cart"},
+	{"order_summary", "order summary"},
+	{"subtotal", "subtotal"},
+	{"documentation", "documentation"},
+	{"api_reference", "api reference"},
+	{"getting_started", "getting started"},
+	{"developer", "developer"},
+	{"endpoint", "endpoint"},
+	{"swagger", "swagger"},
+	{"account_settings", "account settings"},
+	{"change_password", "change password"},
+	{"email_preferences", "email preferences"},
+	{"notification_settings", "notification"},
+	{"edit_profile", "edit profile"},
+	{"two_factor", "two-factor"},
+	{"delete_account", "delete account"},
+	{"save_changes", "save changes"},
+}
+
+// GetErrorIndicators returns features for detecting error/soft-404/special pages.
+func GetErrorIndicators(doc *goquery.Document) map[string]any {
+	features := make(map[string]any)
+
+	title := strings.ToLower(GetPageTitle(doc))
+	h1 := strings.ToLower(GetH1Text(doc))
+	bodyText := strings.ToLower(doc.FindMatcher(compiled("body")).Text())
+
+	// Limit body text scan to first 5000 chars for performance
+	if len(bodyText) > 5000 {
+		bodyText = bodyText[:5000]
+	}
+
+	for _, p := range pageKeywords {
+		features["title_has_"+p.name] = boolToFloat(strings.Contains(title, p.keyword))
+		features["h1_has_"+p.name] = boolToFloat(strings.Contains(h1, p.keyword))
+		features["body_has_"+p.name] = boolToFloat(strings.Contains(bodyText, p.keyword))
+	}
+
+	// Parked domains load their ads and sale offers from parking services.
+	parking := false
+	doc.FindMatcher(compiled("a[href], script[src], iframe[src]")).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		src, ok := s.Attr("src")
+		if !ok {
+			src, _ = s.Attr("href")
+		}
+		parking = containsAny(strings.ToLower(src), parkedMarkup...)
+		return !parking
+	})
+	features["has_parking_service"] = boolToFloat(parking)
+	features["maintenance_notice"] = boolToFloat(IsMaintenancePage(doc))
+
+	return features
+}
+
+// pricePattern matches amounts such as "$29", "€9.99", "19 €", or "10 USD"
+// in lowercased text.
+var pricePattern = regexp.MustCompile(`[$€£¥₹]\s?\d|\d\s?[€£]|\d\s?(usd|eur|gbp)\b`)
+
+// billingPeriods are phrases that quote a price per period, as plan tiers do.
+var billingPeriods = []string{"/mo", "/month", "/yr", "/year", "per month", "per year", "a month", "billed monthly", "billed annually", "billed yearly", "per user"}
+
+// GetCommerceIndicators returns features for telling pricing pages (plan
+// tiers quoted per period) from checkout and cart pages (line items,
+// totals, payment fields), which otherwise look like product or landing
+// pages.
+func GetCommerceIndicators(doc *goquery.Document) map[string]any {
+	features := make(map[string]any)
+
+	bodyText := strings.ToLower(strings.Join(strings.Fields(doc.FindMatcher(compiled("body")).Text()), " "))
+	if len(bodyText) > 10000 {
+		bodyText = bodyText[:10000]
+	}
+	features["price_count_bucket"] = priceCountBucket(len(pricePattern.FindAllStringIndex(bodyText, -1)))
+	features["has_billing_period"] = boolToFloat(containsAny(bodyText, billingPeriods...))
+
+	features["has_pricing_markup"] = boolToFloat(doc.FindMatcher(compiled(
+		`[class*="pricing"], [id*="pricing"], [class*="price-table"], [class*="plan-card"], [class*="tier"]`)).Length() > 0)
+	features["has_cart_markup"] = boolToFloat(doc.FindMatcher(compiled(
+		`[class*="cart"], [id*="cart"], [class*="basket"], [id*="basket"], [class*="checkout"], [id*="checkout"]`)).Length() > 0)
+	features["has_quantity_input"] = boolToFloat(doc.FindMatcher(compiled(
+		`input[name*="qty"], input[name*="quantity"]`)).Length() > 0)
+
+	// Card fields, or the iframes payment processors render them in.
+	features["has_payment_fields"] = boolToFloat(doc.FindMatcher(compiled(
+		`input[autocomplete^="cc-"], input[name*="cardnumber"], input[name*="card_number"], input[name*="cvc"], input[name*="cvv"], `+
+			`iframe[src*="js.stripe.com"], iframe[src*="braintree"], iframe[src*="adyen"], iframe[src*="paypal.com"]`)).Length() > 0)
+
+	return features
+}
+
+// GetAccountIndicators returns features for account and profile settings
+// pages, which only logged-in users see: change-password forms, email and
+// notification preferences, and account deletion.
+func GetAccountIndicators(doc *goquery.Document) map[string]any {
+	features := make(map[string]any)
+
+	changePassword, preferences := false, false
+	doc.FindMatcher(compiled("form")).Each(func(_ int, form *goquery.Selection) {
+		passwords := form.FindMatcher(compiled(`input[type="password"]`))
+		// A change-password form asks for the current password next to the
+		// new one; a registration form only for the new one, twice.
+		if passwords.Length() >= 2 && passwords.FilterFunction(func(_ int, s *goquery.Selection) bool {
+			attrs := strings.ToLower(s.AttrOr("autocomplete", "") + " " + s.AttrOr("name", "") + " " + s.AttrOr("id", ""))
+			return containsAny(attrs, "current", "old", "existing")
+		}).Length() > 0 {
+			changePassword = true
+		}
+		if form.FindMatcher(compiled(`input[type="checkbox"]`)).Length() >= 3 &&
+			containsAny(strings.ToLower(form.Text()), "email", "notif", "newsletter") {
+			preferences = true
+		}
+	})
+	features["has_change_password_form"] = boolToFloat(changePassword)
+	features["has_email_preferences_form"] = boolToFloat(preferences)
+
+	danger := false
+	doc.FindMatcher(compiled(`button, a, input[type="submit"]`)).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		text := strings.ToLower(strings.Join(strings.Fields(s.Text()+" "+s.AttrOr("value", "")), " "))
+		danger = containsAny(text, "delete account", "delete my account", "deactivate account", "close account", "close my account")
+		return !danger
+	})
+	features["has_delete_account"] = boolToFloat(danger)
+
+	return features
+}
+
+// httpMethodPattern matches an HTTP method followed by a path, as API
+// references list endpoints ("GET /v1/users").
+var httpMethodPattern = regexp.MustCompile(`\b(GET|POST|PUT|PATCH|DELETE)\s+/`)
+
+// GetDocsIndicators returns features for documentation pages: API
+// references generated by a docs framework, code samples, and endpoint
+// listings.
+func GetDocsIndicators(doc *goquery.Document) map[string]any {
+	features := make(map[string]any)
+
+	features["has_docs_framework"] = boolToFloat(len(GetDocsFrameworks(doc)) > 0)
+	features["code_block_bucket"] = codeBlockBucket(doc.FindMatcher(compiled("pre, code")).Length())
+	features["has_sidebar_toc"] = boolToFloat(doc.FindMatcher(compiled(
+		`[class*="sidebar"] a, [class*="toc"] a, [class*="menu"] li a`)).Length() >= 10)
+
+	bodyText := strings.Join(strings.Fields(doc.FindMatcher(compiled("body")).Text()), " ")
+	if len(bodyText) > 10000 {
+		bodyText = bodyText[:10000]
+	}
+	features["has_http_methods"] = boolToFloat(len(httpMethodPattern.FindAllStringIndex(bodyText, 3)) >= 2)
+
+	return features
+}
+
+// docsFrameworks maps documentation generators and API explorers to markup
+// fragments (asset URLs, root elements, generator names) that identify them.
+var docsFrameworks = []struct {
+	name     string
+	patterns []string
+}{
+	{"swagger-ui", []string{"swagger-ui", "swaggerui"}},
+	{"redoc", []string{"redoc"}},
+	{"stoplight", []string{"stoplight", "elements-api"}},
+	{"rapidoc", []string{"rapidoc", "rapi-doc"}},
+	{"scalar", []string{"@scalar/"}},
+	{"graphiql", []string{"graphiql"}},
+	{"readme", []string{"readme.io", "readme.com", "rm-sidebar"}},
+	{"docusaurus", []string{"docusaurus"}},
+	{"gitbook", []string{"gitbook"}},
+	{"mkdocs", []string{"mkdocs"}},
+	{"sphinx", []string{"sphinx", "_static/doctools.js"}},
+	{"mintlify", []string{"mintlify"}},
+}
+
+// GetDocsFrameworks returns the documentation frameworks and API explorers
+// (Swagger UI, Redoc, ReadMe, Docusaurus, ...) that rendered the page, which
+// point at API endpoints worth mapping. Names are sorted.
+func GetDocsFrameworks(doc *goquery.Document) []string {
+	found := make(map[string]bool)
+	match := func(markup string) {
+		markup = strings.ToLower(markup)
+		for _, f := range docsFrameworks {
+			if containsAny(markup, f.patterns...) {
+				found[f.name] = true
+			}
+		}
+	}
+	doc.FindMatcher(compiled(`script[src], link[href], meta[name="generator"], div[id], div[class]`)).Each(func(_ int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		href, _ := s.Attr("href")
+		content, _ := s.Attr("content")
+		id, _ := s.Attr("id")
+		class, _ := s.Attr("class")
+		match(src + " " + href + " " + content + " " + id + " " + class)
+	})
+	// Web component explorers mount on their own elements.
+	doc.FindMatcher(compiled("redoc, rapi-doc, elements-api")).Each(func(_ int, s *goquery.Selection) {
+		match(goquery.NodeName(s))
+	})
+	return sortedKeys(found)
+}
+
+func codeBlockBucket(n int) float64 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 3:
+		return 1
+	case n <= 15:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func priceCountBucket(n int) float64 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 2:
+		return 1
+	case n <= 9:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+func linkCountBucket(n int) float64 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 5:
+		return 1
+	case n <= 20:
+		return 2
+	case n <= 50:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func imgCountBucket(n int) float64 {
+	switch {
+	case n == 0:
+		return 0
+	case n <= 3:
+		return 1
+	case n <= 10:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func contentLengthBucket(n int) float64 {
+	switch {
+	case n < 100:
+		return 0
+	case n < 500:
+		return 1
+	case n < 2000:
+		return 2
+	case n < 10000:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// bucketDescriptions describe the values of the count bucket features.
+var bucketDescriptions = map[string][]string{
+	"link_count_bucket":     {"no links", "1-5 links", "6-20 links", "21-50 links", "more than 50 links"},
+	"img_count_bucket":      {"no images", "1-3 images", "4-10 images", "more than 10 images"},
+	"content_length_bucket": {"body text < 100 B", "body text 100-500 B", "body text 500 B-2 KB", "body text 2-10 KB", "body text > 10 KB"},
+	"price_count_bucket":    {"no prices", "1-2 prices", "3-9 prices", "10 or more prices"},
+	"code_block_bucket":     {"no code blocks", "1-3 code blocks", "4-15 code blocks", "more than 15 code blocks"},
+}
+
+// DescribePageFeature puts a GetPageStructure feature with the given value
+// in words for analysts, e.g. "title contains 'page not found'" for
+// title_has_page_not_found or "body text 500 B-2 KB" for
+// content_length_bucket. Unknown features are named with spaces for
+// underscores.
+func DescribePageFeature(name string, value float64) string {
+	for _, where := range []string{"title", "h1", "body"} {
+		suffix, ok := strings.CutPrefix(name, where+"_has_")
+		if !ok {
+			continue
+		}
+		for _, p := range pageKeywords {
+			if p.name == suffix {
+				return fmt.Sprintf("%s contains '%s'", where, p.keyword)
+			}
+		}
+	}
+	if buckets, ok := bucketDescriptions[name]; ok {
+		if i := int(value); i >= 0 && i < len(buckets) {
+			return buckets[i]
+		}
+	}
+	words := strings.ReplaceAll(name, "_", " ")
+	switch {
+	case strings.HasPrefix(name, "has_") && value == 1:
+		return "page " + words
+	case value == 1:
+		return words
+	default:
+		return fmt.Sprintf("%s = %g", words, value)
+	}
+}
+
+// ssoProviders lists single sign-on providers with their OAuth endpoints.
+var ssoProviders = []struct {
+	name  string
+	hosts []string
+}{
+	{"google", []string{"accounts.google.com"}},
+	{"facebook", []string{"facebook.com/dialog/oauth", "/dialog/oauth"}},
+	{"github", []string{"github.com/login/oauth"}},
+	{"apple", []string{"appleid.apple.com"}},
+	{"microsoft", []string{"login.microsoftonline.com", "login.live.com"}},
+	{"twitter", []string{"api.twitter.com/oauth", "twitter.com/i/oauth2"}},
+	{"linkedin", []string{"linkedin.com/oauth"}},
+	{"gitlab", []string{"gitlab.com/oauth"}},
+	{"okta", []string{".okta.com/oauth2"}},
+	{"auth0", []string{".auth0.com/authorize"}},
+}
+
+var ssoActionPrefixes = []string{"sign in with ", "log in with ", "login with ", "continue with ", "sign up with ", "connect with "}
+
+// GetSSOProviders returns the single sign-on providers offered on the page,
+// detected from OAuth endpoint URLs, /auth/<provider> style paths, and
+// "Sign in with <provider>" button text. Names are sorted.
+func GetSSOProviders(doc *goquery.Document) []string {
+	found := make(map[string]bool)
+	doc.FindMatcher(compiled("a, button, form, input[type=\"submit\"]")).Each(func(_ int, s *goquery.Selection) {
+		target, _ := s.Attr("href")
+		if goquery.NodeName(s) == "form" {
+			target, _ = s.Attr("action")
+		}
+		target = strings.ToLower(target)
+		text := strings.ToLower(strings.Join(strings.Fields(s.Text()), " "))
+		if value, ok := s.Attr("value"); ok {
+			text += " " + strings.ToLower(value)
+		}
+
+		for _, p := range ssoProviders {
+			if found[p.name] {
+				continue
+			}
+			if target != "" && (containsAny(target, p.hosts...) ||
+				containsAny(target, "/auth/"+p.name, "/oauth/"+p.name, "/login/"+p.name, "/social/"+p.name, "/connect/"+p.name)) {
+				found[p.name] = true
+				continue
+			}
+			for _, prefix := range ssoActionPrefixes {
+				if strings.Contains(text, prefix+p.name) {
+					found[p.name] = true
+					break
+				}
+			}
+		}
+	})
+	return sortedKeys(found)
+}
+
+// captchaProviders maps CAPTCHA vendors to markup fragments (script URLs,
+// widget classes) that identify them.
+var captchaProviders = []struct {
+	name     string
+	patterns []string
+}{
+	{"recaptcha", []string{"google.com/recaptcha", "recaptcha.net", "g-recaptcha"}},
+	{"hcaptcha", []string{"hcaptcha.com", "h-captcha"}},
+	{"turnstile", []string{"challenges.cloudflare.com/turnstile", "cf-turnstile"}},
+	{"funcaptcha", []string{"arkoselabs.com", "funcaptcha"}},
+	{"geetest", []string{"geetest"}},
+}
+
+// GetCaptchaProviders returns the CAPTCHA widgets embedded in the page.
+// Unrecognised CAPTCHAs (images or inputs named "captcha") are reported as
+// "generic". Names are sorted.
+func GetCaptchaProviders(doc *goquery.Document) []string {
+	found := make(map[string]bool)
+	doc.FindMatcher(compiled("script[src], iframe[src], div[class], div[id]")).Each(func(_ int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		markup := strings.ToLower(src + " " + class + " " + id)
+		for _, p := range captchaProviders {
+			if containsAny(markup, p.patterns...) {
+				found[p.name] = true
+			}
+		}
+	})
+	if len(found) == 0 {
+		doc.FindMatcher(compiled("img, input")).Each(func(_ int, s *goquery.Selection) {
+			src, _ := s.Attr("src")
+			name, _ := s.Attr("name")
+			id, _ := s.Attr("id")
+			if strings.Contains(strings.ToLower(src+" "+name+" "+id), "captcha") {
+				found["generic"] = true
+			}
+		})
+	}
+	return sortedKeys(found)
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -208,6 +208,53 @@ func TestGetErrorIndicatorsDirectory(t *testing.T) {
 	}
 }
 
+func TestGetErrorIndicatorsParked(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><head><title>example.net is for sale</title></head><body>
+<h1>Buy this domain</h1><p>The domain owner is accepting offers. Make an offer today.</p>
+<a href="https://www.afternic.com/forsale/example.net">Inquire</a></body></html>`)
+	features := GetErrorIndicators(doc)
+
+	for _, name := range []string{"h1_has_buy_this_domain", "body_has_make_offer", "body_has_domain_owner", "has_parking_service"} {
+		if features[name] != 1.0 {
+			t.Errorf("expected %s = 1.0", name)
+		}
+	}
+
+	doc, _ = LoadHTMLString(test404HTML)
+	if GetErrorIndicators(doc)["has_parking_service"] != 0.0 {
+		t.Error("expected has_parking_service = 0.0 on a 404 page")
+	}
+}
+
+func TestGetCommerceIndicators(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><head><title>Pricing</title></head><body>
+<div class="pricing-table"><div class="tier"><h2>Starter</h2><p>$9/mo</p></div>
+<div class="tier"><h2>Pro</h2><p>$29/mo, billed annually</p></div>
+<div class="tier"><h2>Team</h2><p>$49 per user</p></div></div></body></html>`)
+	features := GetPageStructure(doc)
+	for name, want := range map[string]float64{
+		"title_has_pricing": 1, "price_count_bucket": 2, "has_billing_period": 1,
+		"has_pricing_markup": 1, "has_cart_markup": 0, "has_payment_fields": 0,
+	} {
+		if features[name] != want {
+			t.Errorf("pricing page: %s = %v, want %v", name, features[name], want)
+		}
+	}
+
+	doc, _ = LoadHTMLString(`<html><head><title>Checkout</title></head><body><div id="cart">
+<input name="quantity" value="1"><p>Order summary</p><p>Subtotal: 19,90 €</p>
+<input autocomplete="cc-number" name="ccnum"></div></body></html>`)
+	features = GetCommerceIndicators(doc)
+	for name, want := range map[string]float64{
+		"price_count_bucket": 1, "has_billing_period": 0, "has_pricing_markup": 0,
+		"has_cart_markup": 1, "has_quantity_input": 1, "has_payment_fields": 1,
+	} {
+		if features[name] != want {
+			t.Errorf("checkout page: %s = %v, want %v", name, features[name], want)
+		}
+	}
+}
+
 func TestGetPageStructureNoForm(t *testing.T) {
 	doc, _ := LoadHTMLString("<html><body><p>Hello</p></body></html>")
 	features := GetPageStructure(doc)
@@ -240,3 +287,109 @@ func TestContentLengthBucket(t *testing.T) {
 		}
 	}
 }
+
+func TestGetSSOProviders(t *testing.T) {
+	html := `<html><body>
+<a href="https://accounts.google.com/o/oauth2/auth?client_id=1">Google</a>
+<a href="/auth/github">GitHub</a>
+<button>Continue with Apple</button>
+<a href="https://facebook.com/somepage">Our Facebook page</a>
+</body></html>`
+	doc, _ := LoadHTMLString(html)
+	got := strings.Join(GetSSOProviders(doc), ",")
+	if got != "apple,github,google" {
+		t.Errorf("GetSSOProviders() = %q, want %q", got, "apple,github,google")
+	}
+
+	doc, _ = LoadHTMLString(testPageHTML)
+	if providers := GetSSOProviders(doc); len(providers) != 0 {
+		t.Errorf("expected no SSO providers, got %v", providers)
+	}
+}
+
+func TestGetCaptchaProviders(t *testing.T) {
+	html := `<html><head><script src="https://www.google.com/recaptcha/api.js"></script></head>
+<body><form><div class="cf-turnstile" data-sitekey="x"></div></form></body></html>`
+	doc, _ := LoadHTMLString(html)
+	got := strings.Join(GetCaptchaProviders(doc), ",")
+	if got != "recaptcha,turnstile" {
+		t.Errorf("GetCaptchaProviders() = %q, want %q", got, "recaptcha,turnstile")
+	}
+
+	doc, _ = LoadHTMLString(`<form><img src="/captcha.php"/><input name="captcha_code"/></form>`)
+	got = strings.Join(GetCaptchaProviders(doc), ",")
+	if got != "generic" {
+		t.Errorf("GetCaptchaProviders() = %q, want %q", got, "generic")
+	}
+}
+
+func TestGetDocsFrameworks(t *testing.T) {
+	html := `<html><head><link rel="stylesheet" href="/swagger-ui/swagger-ui.css"></head>
+<body><div id="swagger-ui"></div><redoc spec-url="/openapi.json"></redoc></body></html>`
+	doc, _ := LoadHTMLString(html)
+	got := strings.Join(GetDocsFrameworks(doc), ",")
+	if got != "redoc,swagger-ui" {
+		t.Errorf("GetDocsFrameworks() = %q, want %q", got, "redoc,swagger-ui")
+	}
+
+	doc, _ = LoadHTMLString(test404HTML)
+	if got := GetDocsFrameworks(doc); got != nil {
+		t.Errorf("GetDocsFrameworks() = %v on a 404 page, want none", got)
+	}
+}
+
+func TestGetDocsIndicators(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><head><title>API Reference</title></head><body>
+<h1>API reference</h1><pre><code>curl https://api.example.com/v1/users</code></pre>
+<p>GET /v1/users lists users.</p><p>POST /v1/users creates one.</p></body></html>`)
+	features := GetPageStructure(doc)
+	for name, want := range map[string]float64{
+		"title_has_api_reference": 1, "code_block_bucket": 1, "has_http_methods": 1, "has_docs_framework": 0,
+	} {
+		if features[name] != want {
+			t.Errorf("%s = %v, want %v", name, features[name], want)
+		}
+	}
+}
+
+func TestGetAccountIndicators(t *testing.T) {
+	doc, _ := LoadHTMLString(`<html><head><title>Account settings</title></head><body>
+<form><input type="password" name="current_password" autocomplete="current-password">
+<input type="password" name="new_password" autocomplete="new-password"><button>Change password</button></form>
+<form><p>Email preferences</p><input type="checkbox" name="news"><input type="checkbox" name="digest">
+<input type="checkbox" name="mentions"><button>Save changes</button></form>
+<button class="danger">Delete account</button></body></html>`)
+	features := GetPageStructure(doc)
+	for _, name := range []string{"title_has_account_settings", "body_has_change_password", "body_has_email_preferences",
+		"has_change_password_form", "has_email_preferences_form", "has_delete_account"} {
+		if features[name] != 1.0 {
+			t.Errorf("expected %s = 1.0", name)
+		}
+	}
+
+	doc, _ = LoadHTMLString(`<form><input type="email" name="email"><input type="password" name="password">
+<input type="password" name="password_confirm"><button>Sign up</button></form>`)
+	if GetAccountIndicators(doc)["has_change_password_form"] != 0.0 {
+		t.Error("expected has_change_password_form = 0.0 on a registration form")
+	}
+}
+
+func TestDescribePageFeature(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		value float64
+		want  string
+	}{
+		{"title_has_page_not_found", 1, "title contains 'page not found'"},
+		{"body_has_login", 1, "body contains 'log in'"},
+		{"content_length_bucket", 2, "body text 500 B-2 KB"},
+		{"link_count_bucket", 4, "more than 50 links"},
+		{"has_password", 1, "page has password"},
+		{"maintenance_notice", 1, "maintenance notice"},
+		{"heading_count", 3, "heading count = 3"},
+	} {
+		if got := DescribePageFeature(tt.name, tt.value); got != tt.want {
+			t.Errorf("DescribePageFeature(%q, %v) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
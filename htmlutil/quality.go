@@ -0,0 +1,89 @@
+package htmlutil
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Kinds of page reported by JunkPageKind.
+const (
+	JunkBotChallenge = "bot_challenge"
+	JunkParked       = "parked"
+	JunkAppShell     = "app_shell"
+)
+
+// shortPageText is the amount of visible text below which markers that
+// also appear on ordinary pages, such as WAF scripts, count.
+const shortPageText = 1500
+
+// challengeTitles and challengeText are shown by bot-protection
+// interstitials instead of the requested page.
+var (
+	challengeTitles = []string{"just a moment...", "attention required! | cloudflare", "ddos-guard", "checking your browser", "one more step"}
+	challengeText   = []string{"checking your browser before accessing", "checking if the site connection is secure", "verify you are human by completing", "enable javascript and cookies to continue", "needs to review the security of your connection"}
+	// challengeMarkup is challenge scripts and forms; several vendors also
+	// inject them into ordinary pages, so they only count on short ones.
+	challengeMarkup = []string{"cf_chl_opt", "cf-browser-verification", "/challenge-platform/h/", "_incapsula_resource", "px-captcha", "captcha-delivery.com", "/_sec/cp_challenge", "sucuri_cloudproxy", "ddos-guard", "awswaf.com", "aws-waf-token"}
+)
+
+// parkedText and parkedMarkup mark domain parking and for-sale pages.
+var (
+	parkedText   = []string{"this domain is for sale", "this domain may be for sale", "buy this domain", "domain is parked", "parked free, courtesy of", "this domain name is for sale", "the domain owner may be willing to sell", "make an offer on this domain", "inquire about this domain", "generated by the domain owner using"}
+	parkedMarkup = []string{"sedoparking.com", "parkingcrew.net", "bodis.com", "above.com/marketplace", "dan.com/buy-domain", "afternic.com", "hugedomains.com", "domainmarket.com", "parklogic"}
+)
+
+// maintenanceText is shown by sites that are down for maintenance or not
+// yet launched; announcements of future maintenance on ordinary pages use
+// the same words, so it only counts on short pages. Generic outage
+// wording such as "service unavailable" is left out: it is also the text
+// of every stock 503 error page.
+var maintenanceText = []string{"down for maintenance", "under maintenance", "undergoing maintenance", "scheduled maintenance",
+	"maintenance mode", "we'll be back soon", "we will be back soon", "we’ll be back soon", "be right back", "back online shortly",
+	"under construction", "site is being updated"}
+
+// IsMaintenancePage reports whether doc is a maintenance, "we'll be back
+// soon", or "under construction" page served while a site is down, often
+// with HTTP 503. Such pages are easily mistaken for error pages.
+func IsMaintenancePage(doc *goquery.Document) bool {
+	text := strings.ToLower(GetPageTitle(doc))
+	if body := singleNode(doc.FindMatcher(compiled("body"))); body != nil {
+		text += " " + strings.ToLower(strings.Join(strings.Fields(visibleText(body, 4*shortPageText)), " "))
+	}
+	return len(text) < shortPageText && containsAny(text, maintenanceText...)
+}
+
+// JunkPageKind reports whether doc is a page that stands in for the one
+// requested: a bot-protection challenge or WAF block page from Cloudflare,
+// Akamai, Imperva, AWS WAF and the like (JunkBotChallenge), a parked or
+// for-sale domain (JunkParked), or a client-rendered app shell with no
+// content before JavaScript runs (JunkAppShell). It returns "" for other
+// pages. The checks are keyword heuristics; markup is only searched on
+// pages with little text, so long pages cost one pass over their text.
+func JunkPageKind(doc *goquery.Document) string {
+	title := strings.ToLower(GetPageTitle(doc))
+	text := ""
+	if body := singleNode(doc.FindMatcher(compiled("body"))); body != nil {
+		text = strings.ToLower(strings.Join(strings.Fields(visibleText(body, 1<<16)), " "))
+	}
+	short := len(text) < shortPageText
+	var markup string
+	if short {
+		markup, _ = goquery.OuterHtml(doc.Selection)
+		markup = strings.ToLower(markup)
+	}
+
+	// Akamai's block page is a bare "Access Denied" with a reference number.
+	akamaiBlock := strings.Contains(text, "you don't have permission to access") && strings.Contains(text, "reference #")
+	switch {
+	case containsAny(text, challengeText...), short && akamaiBlock,
+		short && (containsAny(title, challengeTitles...) || containsAny(markup, challengeMarkup...)):
+		return JunkBotChallenge
+	case containsAny(text, parkedText...), short && containsAny(markup, parkedMarkup...):
+		return JunkParked
+	case len(strings.Fields(text)) < 5 && doc.FindMatcher(compiled("form, input, textarea, select")).Length() == 0 &&
+		doc.FindMatcher(compiled("script")).Length() > 0:
+		return JunkAppShell
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package htmlutil
+
+import (
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// compiledSelectors caches compiled selectors by their source. goquery's
+// Find compiles its selector string on every call, which shows up in
+// profiles of per-field helpers such as FindLabel on large forms. Only
+// constant selector strings are compiled, so the cache stays small.
+var compiledSelectors sync.Map // string -> goquery.Matcher
+
+// compiled returns the compiled form of selector, which must be valid.
+func compiled(selector string) goquery.Matcher {
+	if m, ok := compiledSelectors.Load(selector); ok {
+		return m.(goquery.Matcher)
+	}
+	m, _ := compiledSelectors.LoadOrStore(selector, cascadia.MustCompile(selector))
+	return m.(goquery.Matcher)
+}
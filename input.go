@@ -0,0 +1,109 @@
+package dit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrNotHTML matches, with errors.Is, the *InputError returned for input
+// that is obviously not HTML.
+var ErrNotHTML = errors.New("dit: input is not HTML")
+
+// InputError reports input rejected before classification because it is
+// empty or obviously not HTML, which would otherwise yield zero forms and
+// hide the mistake (passing a JSON API response or a PDF, say).
+type InputError struct {
+	// Detected is the sniffed content type, as returned by
+	// DetectContentType; empty for blank input.
+	Detected string
+}
+
+func (e *InputError) Error() string {
+	if e.Detected == "" {
+		return "dit: input is empty"
+	}
+	return "dit: input is not HTML (detected " + e.Detected + ")"
+}
+
+// Is reports whether target is ErrNotHTML.
+func (e *InputError) Is(target error) bool {
+	return target == ErrNotHTML
+}
+
+// DetectContentType guesses the type of a document: "text/html" for HTML,
+// including fragments such as a bare <form>, "text/plain" for other text,
+// "application/json" for JSON, or the http.DetectContentType media type for
+// binary formats such as PDF and images. It returns "" for blank input.
+func DetectContentType(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return ""
+	}
+	if trimmed[0] == '<' {
+		return "text/html"
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "application/json"
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType([]byte(content)))
+	switch detected {
+	case "text/html", "text/xml":
+		return "text/html"
+	case "text/plain":
+		return "text/plain"
+	}
+	return detected
+}
+
+// checkHTML returns an *InputError unless html may be HTML. Plain text is
+// let through: it parses as a document without forms.
+func checkHTML(html string) error {
+	switch detected := DetectContentType(html); detected {
+	case "text/html", "text/plain":
+		return nil
+	default:
+		return &InputError{Detected: detected}
+	}
+}
+
+// checkHTMLPrefix is checkHTML for the first bytes of a longer input,
+// where JSON cannot be recognized by parsing it whole: a prefix that is
+// valid JSON as far as it goes counts as JSON.
+func checkHTMLPrefix(head string) error {
+	if trimmed := strings.TrimSpace(head); trimmed != "" && (trimmed[0] == '{' || trimmed[0] == '[') && isJSONPrefix(trimmed) {
+		return &InputError{Detected: "application/json"}
+	}
+	return checkHTML(head)
+}
+
+// isJSONPrefix reports whether s is the start of a JSON document.
+func isJSONPrefix(s string) bool {
+	dec := json.NewDecoder(strings.NewReader(s))
+	for {
+		if _, err := dec.Token(); err != nil {
+			return err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+// skipSpace consumes the ASCII whitespace at the start of br.
+func skipSpace(br *bufio.Reader) error {
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !strings.ContainsRune(" \t\n\r\f\v", rune(c)) {
+			return br.UnreadByte()
+		}
+	}
+}
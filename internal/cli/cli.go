@@ -51,8 +51,17 @@ func (c *CLI) setupCommands() {
 	c.rootCmd.AddCommand(c.newTrainCommand())
 	c.rootCmd.AddCommand(c.newRunCommand())
 	c.rootCmd.AddCommand(c.newEvaluateCommand())
+	c.rootCmd.AddCommand(c.newTuneThresholdsCommand())
+	c.rootCmd.AddCommand(c.newMigrateModelCommand())
+	c.rootCmd.AddCommand(c.newCompactModelCommand())
+	c.rootCmd.AddCommand(c.newModelCommand())
+	c.rootCmd.AddCommand(c.newDistillPageCommand())
+	c.rootCmd.AddCommand(c.newTaxonomyCommand())
+	c.rootCmd.AddCommand(c.newServeCommand())
+	c.rootCmd.AddCommand(c.newReportCommand())
 	c.rootCmd.AddCommand(c.newUpCommand())
 	c.rootCmd.AddCommand(c.newDataCommand())
+	c.rootCmd.AddCommand(c.newCollectCommand())
 }
 
 // Run executes the CLI and returns any error.
@@ -60,6 +69,12 @@ func (c *CLI) Run() error {
 	return c.rootCmd.Execute()
 }
 
+// RunArgs executes the CLI with args instead of the process arguments.
+func (c *CLI) RunArgs(args []string) error {
+	c.rootCmd.SetArgs(args)
+	return c.rootCmd.Execute()
+}
+
 // initApp initializes logging and prints the banner.
 func (c *CLI) initApp() {
 	if c.initialized {
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// collectUserAgent identifies dit when collecting training pages.
+const collectUserAgent = "Mozilla/5.0 (compatible; dit-collect/1.0)"
+
+const normalizeUsage = "Store pages with inline scripts removed and whitespace collapsed, keeping the originals under raw/"
+
+// newCollectCommand groups the commands that gather page annotations into
+// <data-folder>/pages, the layout train and evaluate read.
+func (c *CLI) newCollectCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "collect",
+		Short: "Collect HTML pages for page type classifier training",
+		Long: `Collect HTML pages for page type classifier training. Pages are saved
+under <data-folder>/pages with an index.json mapping each file to its URL
+and page type, as train and evaluate expect.`,
+	}
+	cmd.PersistentFlags().String("data-folder", "data", "Path to annotation data folder; pages go in its pages/ subfolder")
+	cmd.PersistentFlags().String("output", "", "Pages folder, overriding <data-folder>/pages")
+	_ = cmd.PersistentFlags().MarkDeprecated("output", "use --data-folder")
+	cmd.PersistentFlags().Int("hash-digits", defaultHashDigits, "Hex digits of the URL's SHA-256 in stored page filenames (12 to 64)")
+	cmd.AddCommand(c.newCollectFetchCommand())
+	cmd.AddCommand(c.newCrawlCommand())
+	cmd.AddCommand(c.newGenSeedCommand())
+	cmd.AddCommand(c.newCollectReviewCommand())
+	return cmd
+}
+
+// pagesDir returns the folder collect commands write pages to.
+func pagesDir(cmd *cobra.Command) string {
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		return output
+	}
+	dataFolder, _ := cmd.Flags().GetString("data-folder")
+	return filepath.Join(dataFolder, "pages")
+}
+
+// pageFiles returns how collect commands store pages, checking
+// --hash-digits.
+func pageFiles(cmd *cobra.Command, normalize bool) (pageFileOpts, error) {
+	digits, _ := cmd.Flags().GetInt("hash-digits")
+	if digits < minHashDigits || digits > 2*sha256.Size {
+		return pageFileOpts{}, fmt.Errorf("--hash-digits %d out of range [%d, %d]", digits, minHashDigits, 2*sha256.Size)
+	}
+	return pageFileOpts{normalize: normalize, hashDigits: digits}, nil
+}
+
+// CollectArgs maps the arguments of the dit-collect alias binary to the
+// equivalent dit arguments: "dit-collect crawl ..." is "dit collect crawl
+// ...", and dit-collect's own collect command is now "dit collect fetch".
+func CollectArgs(args []string) []string {
+	if len(args) > 0 && args[0] == "collect" {
+		args = append([]string{"fetch"}, args[1:]...)
+	}
+	return append([]string{"collect"}, args...)
+}
+
+func (c *CLI) newCollectFetchCommand() *cobra.Command {
+	var (
+		seedFile   string
+		timeout    int
+		delay      int
+		maxDelay   int
+		userAgent  string
+		maxPages   int
+		mangleOnly bool
+		normalize  bool
+		auth       authFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch pages from seed URLs and save them to <data-folder>/pages",
+		Example: `  dit collect fetch --seed seeds.jsonl
+  dit collect fetch --seed seeds.jsonl --data-folder data --mangle-only
+  dit collect fetch --seed settings.jsonl --auth-domain example.com --cookie 'session=abc123'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outputDir := pagesDir(cmd)
+			files, err := pageFiles(cmd, normalize)
+			if err != nil {
+				return err
+			}
+			seeds, err := loadSeeds(seedFile)
+			if err != nil {
+				return fmt.Errorf("load seeds: %w", err)
+			}
+			slog.Info("Loaded seeds", "count", len(seeds))
+
+			index, err := loadIndex(outputDir)
+			if err != nil {
+				return fmt.Errorf("load index: %w", err)
+			}
+
+			authed, err := auth.client(newHTTPClient(time.Duration(timeout)*time.Second, true))
+			if err != nil {
+				return err
+			}
+			client := newPacedClient(authed, time.Duration(delay)*time.Millisecond, time.Duration(maxDelay)*time.Millisecond)
+			if err := os.MkdirAll(filepath.Join(outputDir, "html"), 0755); err != nil {
+				return fmt.Errorf("create html dir: %w", err)
+			}
+
+			collected := 0
+			for _, seed := range seeds {
+				if maxPages > 0 && collected >= maxPages {
+					break
+				}
+
+				if !mangleOnly {
+					if pageType, err := fetchAndSave(client, seed.URL, seed.ExpectedType, userAgent, outputDir, files, index); err != nil {
+						slog.Warn("Failed to fetch", "url", seed.URL, "error", err)
+					} else {
+						collected++
+						slog.Info("Collected", "url", seed.URL, "type", pageType, "total", collected)
+					}
+				}
+
+				if seed.Mangle {
+					mangledURL := manglePath(seed.URL)
+					if mangledURL != "" {
+						if maxPages > 0 && collected >= maxPages {
+							break
+						}
+						status, err := fetchAndSaveMangled(client, mangledURL, userAgent, outputDir, files, index)
+						if err != nil {
+							slog.Warn("Failed to fetch mangled", "url", mangledURL, "error", err)
+						} else {
+							collected++
+							pageType := "s4"
+							if status == 404 {
+								pageType = "er"
+							}
+							slog.Info("Collected mangled", "url", mangledURL, "status", status, "type", pageType, "total", collected)
+						}
+					}
+				}
+			}
+
+			if err := saveIndex(outputDir, index); err != nil {
+				return fmt.Errorf("save index: %w", err)
+			}
+			slog.Info("Collection complete", "total", collected, "index_entries", len(index))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&seedFile, "seed", "", "Path to seed file (JSONL)")
+	cmd.Flags().IntVar(&timeout, "timeout", 30, "HTTP timeout in seconds")
+	cmd.Flags().IntVar(&delay, "delay", 1000, "Minimum delay between requests to a host in ms; it grows for slow or throttling hosts")
+	cmd.Flags().IntVar(&maxDelay, "max-delay", 60000, "Maximum delay between requests to a host in ms")
+	cmd.Flags().StringVar(&userAgent, "user-agent", collectUserAgent, "User-Agent header")
+	cmd.Flags().IntVar(&maxPages, "max", 0, "Max pages to collect (0=unlimited)")
+	cmd.Flags().BoolVar(&mangleOnly, "mangle-only", false, "Only collect mangled URLs")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, normalizeUsage)
+	auth.register(cmd)
+	_ = cmd.MarkFlagRequired("seed")
+	return cmd
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// authFlags are the credentials of a logged-in browser session, which let
+// collect capture pages behind a login such as account settings.
+type authFlags struct {
+	domain  string
+	cookie  string
+	headers []string
+}
+
+func (f *authFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.domain, "auth-domain", "", "Send --cookie and --header only to this domain and its subdomains")
+	cmd.Flags().StringVar(&f.cookie, "cookie", "", `Cookie header of a logged-in session (e.g. "session=abc123"), for pages behind a login`)
+	cmd.Flags().StringArrayVar(&f.headers, "header", nil, `Extra request header as "Name: value" (e.g. "Authorization: Bearer ..."); repeatable`)
+}
+
+// client wraps client so requests to the auth domain carry the
+// credentials; without credentials it returns client unchanged. client's
+// redirect policy is extended to strip the credentials from redirects that
+// leave the auth domain or https: net/http only drops Authorization and
+// Cookie there, not other --header values.
+func (f *authFlags) client(client *http.Client) (httpClient, error) {
+	if f.cookie == "" && len(f.headers) == 0 {
+		return client, nil
+	}
+	if f.domain == "" {
+		return nil, errors.New("--cookie and --header need --auth-domain, the site to send them to")
+	}
+	header := make(http.Header)
+	for _, h := range f.headers {
+		name, value, ok := strings.Cut(h, ":")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("--header %q: want \"Name: value\"", h)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	if f.cookie != "" {
+		header.Set("Cookie", f.cookie)
+	}
+	ac := &authClient{domain: strings.ToLower(strings.Trim(f.domain, ".")), header: header}
+
+	redirected := *client
+	checkRedirect := client.CheckRedirect
+	redirected.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !ac.authorized(req.URL) {
+			for name := range header {
+				req.Header.Del(name)
+			}
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		return nil
+	}
+	ac.client = &redirected
+	return ac, nil
+}
+
+// errPlainHTTPAuth is returned for a request to the auth domain over plain
+// http, which would expose the session credentials.
+var errPlainHTTPAuth = errors.New("refusing to send --cookie and --header over plain http")
+
+// authClient adds session credentials to https requests for one domain
+// and its subdomains; requests to other hosts, such as external links or
+// cross-site redirects, go out without them, and plain http requests to
+// the domain fail with errPlainHTTPAuth.
+type authClient struct {
+	client httpClient
+	domain string
+	header http.Header
+}
+
+// inDomain reports whether u is on the auth domain or a subdomain.
+func (c *authClient) inDomain(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == c.domain || strings.HasSuffix(host, "."+c.domain)
+}
+
+// authorized reports whether a request to u may carry the credentials.
+func (c *authClient) authorized(u *url.URL) bool {
+	return u.Scheme == "https" && c.inDomain(u)
+}
+
+func (c *authClient) Do(req *http.Request) (*http.Response, error) {
+	if c.inDomain(req.URL) {
+		if !c.authorized(req.URL) {
+			return nil, errPlainHTTPAuth
+		}
+		req = req.Clone(req.Context())
+		for name, values := range c.header {
+			req.Header[name] = values
+		}
+	}
+	return c.client.Do(req)
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// classBalance tracks the page type counts of the collected dataset
+// against target shares, so the crawler can go after the types that are
+// short first.
+type classBalance struct {
+	targets map[string]float64 // share of the dataset, 0 to 1
+	counts  map[string]int
+	total   int
+}
+
+// newClassBalance counts the pages already in index against shares, given
+// in percent by page type. It returns nil, which prioritizes nothing, when
+// there are no shares.
+func newClassBalance(index map[string]pageIndexEntry, shares map[string]int) (*classBalance, error) {
+	if len(shares) == 0 {
+		return nil, nil
+	}
+	b := &classBalance{targets: make(map[string]float64, len(shares)), counts: make(map[string]int)}
+	sum := 0
+	for pageType, percent := range shares {
+		if percent < 0 {
+			return nil, fmt.Errorf("target share of %s is negative", pageType)
+		}
+		sum += percent
+		b.targets[pageType] = float64(percent) / 100
+	}
+	if sum > 100 {
+		return nil, fmt.Errorf("target shares add up to %d%%, more than 100%%", sum)
+	}
+	for _, entry := range index {
+		b.add(entry.PageType)
+	}
+	return b, nil
+}
+
+// add counts a newly collected page.
+func (b *classBalance) add(pageType string) {
+	if b == nil {
+		return
+	}
+	b.counts[pageType]++
+	b.total++
+}
+
+// deficit is how many pages of pageType the dataset lacks for its target
+// share, counting the next page; positive means the type is short.
+func (b *classBalance) deficit(pageType string) float64 {
+	if b == nil {
+		return 0
+	}
+	target, ok := b.targets[pageType]
+	if !ok {
+		return 0
+	}
+	return target*float64(b.total+1) - float64(b.counts[pageType])
+}
+
+// short returns the page types below their target share, sorted.
+func (b *classBalance) short() []string {
+	if b == nil {
+		return nil
+	}
+	var types []string
+	for _, pageType := range slices.Sorted(maps.Keys(b.targets)) {
+		if b.deficit(pageType) > 0 {
+			types = append(types, pageType)
+		}
+	}
+	return types
+}
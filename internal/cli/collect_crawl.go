@@ -0,0 +1,557 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newCrawlCommand() *cobra.Command {
+	var (
+		sitesFile  string
+		timeout    int
+		delay      int
+		maxDelay   int
+		userAgent  string
+		maxTotal   int
+		maxPerSite int
+		maxDepth   int
+		perTypeMax map[string]int
+		shares     map[string]int
+		prob404    float64
+		include    []string
+		exclude    []string
+		noBlock    bool
+		normalize  bool
+		auth       authFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "crawl",
+		Short: "Crawl websites, follow links, mangle URLs for error/soft_404",
+		Example: `  dit collect crawl --sites sites.txt
+  dit collect crawl --sites sites.txt --data-folder data --max-total 1000 --prob404 0.3
+  dit collect crawl --sites sites.txt --per-type-max bl=3,pd=2 --max-depth 2
+  dit collect crawl --sites sites.txt --target-share pr=10,s4=10,er=10
+  dit collect crawl --sites sites.txt --exclude-pattern '/tag/' --include-pattern '^https://[^/]+/en/'
+  dit collect crawl --sites example.txt --auth-domain example.com --cookie 'session=abc123'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			outputDir := pagesDir(cmd)
+			files, err := pageFiles(cmd, normalize)
+			if err != nil {
+				return err
+			}
+			sites, err := loadLines(sitesFile)
+			if err != nil {
+				return fmt.Errorf("load sites: %w", err)
+			}
+			slog.Info("Loaded sites", "count", len(sites))
+
+			index, err := loadIndex(outputDir)
+			if err != nil {
+				return fmt.Errorf("load index: %w", err)
+			}
+			filter, err := newURLFilter(include, exclude, !noBlock)
+			if err != nil {
+				return err
+			}
+			// Without a config.json only the built-in URL patterns apply.
+			schema, _ := storage.NewPageStorage(outputDir).GetPageSchema()
+			balance, err := newClassBalance(index, shares)
+			if err != nil {
+				return fmt.Errorf("--target-share: %w", err)
+			}
+			if short := balance.short(); len(short) > 0 {
+				slog.Info("Prioritizing page types below their target share", "types", short)
+			}
+
+			authed, err := auth.client(newHTTPClient(time.Duration(timeout)*time.Second, true))
+			if err != nil {
+				return err
+			}
+			client := newPacedClient(authed, time.Duration(delay)*time.Millisecond, time.Duration(maxDelay)*time.Millisecond)
+			if err := os.MkdirAll(filepath.Join(outputDir, "html"), 0755); err != nil {
+				return fmt.Errorf("create html dir: %w", err)
+			}
+
+			totalCollected := 0
+
+			for _, site := range sites {
+				if maxTotal > 0 && totalCollected >= maxTotal {
+					break
+				}
+
+				site = strings.TrimSpace(site)
+				if site == "" {
+					continue
+				}
+				if !strings.HasPrefix(site, "http") {
+					site = "https://" + site
+				}
+
+				n, err := crawlSite(client, site, userAgent, outputDir, index, crawlOpts{
+					maxPerSite: maxPerSite,
+					perTypeMax: perTypeMax,
+					balance:    balance,
+					filter:     filter,
+					maxDepth:   maxDepth,
+					maxTotal:   maxTotal,
+					total:      &totalCollected,
+					prob404:    prob404,
+					files:      files,
+					schema:     schema,
+				})
+				if err != nil {
+					slog.Warn("Failed to crawl site", "site", site, "error", err)
+					continue
+				}
+
+				slog.Info("Finished site", "site", site, "collected", n, "total", totalCollected)
+
+				// Save index periodically
+				if totalCollected%50 == 0 {
+					if err := saveIndex(outputDir, index); err != nil {
+						slog.Warn("Failed to save index", "error", err)
+					}
+				}
+			}
+
+			if err := saveIndex(outputDir, index); err != nil {
+				return fmt.Errorf("save index: %w", err)
+			}
+			slog.Info("Crawl complete", "total", totalCollected, "index_entries", len(index))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&sitesFile, "sites", "", "File with domain list (one per line)")
+	cmd.Flags().IntVar(&timeout, "timeout", 30, "HTTP timeout in seconds")
+	cmd.Flags().IntVar(&delay, "delay", 800, "Minimum delay between requests to a host in ms; it grows for slow or throttling hosts")
+	cmd.Flags().IntVar(&maxDelay, "max-delay", 60000, "Maximum delay between requests to a host in ms")
+	cmd.Flags().StringVar(&userAgent, "user-agent", collectUserAgent, "User-Agent header")
+	cmd.Flags().IntVar(&maxTotal, "max-total", 0, "Max total pages (0=unlimited)")
+	cmd.Flags().IntVar(&maxPerSite, "max-per-site", 20, "Max pages per site")
+	cmd.Flags().IntVar(&maxDepth, "max-depth", 0, "Max link depth from the homepage (0=unlimited); links are followed breadth-first")
+	cmd.Flags().StringToIntVar(&perTypeMax, "per-type-max", nil, "Max pages of a page type per site, as type=n (e.g. bl=3,pd=2); other types are limited only by --max-per-site")
+	cmd.Flags().StringToIntVar(&shares, "target-share", nil, "Target share of the dataset in percent by page type (e.g. pr=10,s4=10); links of types below it are followed first, counting pages already in index.json")
+	cmd.Flags().StringArrayVar(&include, "include-pattern", nil, "Only follow links matching this regexp (repeatable)")
+	cmd.Flags().StringArrayVar(&exclude, "exclude-pattern", nil, "Never follow links matching this regexp (repeatable)")
+	cmd.Flags().BoolVar(&noBlock, "no-default-blocklist", false, "Also follow logout, cart, delete, and ?lang= links, which are skipped by default by URL or link text")
+	cmd.Flags().Float64Var(&prob404, "prob404", 0.3, "Probability of mangling a discovered link")
+	cmd.Flags().BoolVar(&normalize, "normalize", false, normalizeUsage)
+	auth.register(cmd)
+	_ = cmd.MarkFlagRequired("sites")
+	return cmd
+}
+
+type crawlOpts struct {
+	maxPerSite int
+	perTypeMax map[string]int // per-site quota by page type
+	balance    *classBalance  // nil follows links in discovery order
+	filter     urlFilter
+	maxDepth   int // link depth limit; 0 means none
+	maxTotal   int
+	total      *int
+	prob404    float64
+	files      pageFileOpts
+	// schema is the pages config.json, whose url_patterns are tried before
+	// the built-in ones; nil without a config.
+	schema *storage.AnnotationSchema
+}
+
+func crawlSite(client httpClient, siteURL, userAgent, outputDir string, index map[string]pageIndexEntry, opts crawlOpts) (int, error) {
+	siteU, err := url.Parse(siteURL)
+	if err != nil {
+		return 0, err
+	}
+	siteHost := siteU.Hostname()
+
+	visited := make(map[string]bool)
+	collected := 0
+	perType := make(map[string]int)
+	quotaReached := func(pageType string) bool {
+		n, ok := opts.perTypeMax[pageType]
+		return ok && perType[pageType] >= n
+	}
+	record := func(html, pageURL, pageType string, status int) {
+		filename, entry := savePage(html, pageURL, pageType, status, outputDir, opts.files, index)
+		index[filename] = entry
+		collected++
+		perType[pageType]++
+		*opts.total++
+		opts.balance.add(pageType)
+	}
+	linkTypes := make(map[string]string)
+	typeOf := func(link string) string {
+		pageType, ok := linkTypes[link]
+		if !ok {
+			if u, err := url.Parse(link); err == nil {
+				pageType = detectPageType(u, opts.schema)
+			}
+			linkTypes[link] = pageType
+		}
+		return pageType
+	}
+
+	// 1. Fetch homepage as landing page
+	html, status, err := fetchPage(client, siteURL, userAgent)
+	if err != nil {
+		return 0, fmt.Errorf("homepage: %w", err)
+	}
+	if isBotChallenge(html) {
+		return 0, fmt.Errorf("homepage: %w", errBotChallenge)
+	}
+	// A site down for maintenance serves the same page for every link.
+	if len(html) >= 100 && isMaintenance(html, status) {
+		if !quotaReached(maintenanceType) {
+			record(html, siteURL, maintenanceType, status)
+		}
+		slog.Info("Site under maintenance, leaving site", "url", siteURL)
+		return collected, nil
+	}
+	if status >= 400 || len(html) < 100 {
+		return 0, fmt.Errorf("homepage HTTP %d (%d bytes)", status, len(html))
+	}
+
+	visited[siteURL] = true
+	if !quotaReached("ln") {
+		record(html, siteURL, "ln", status)
+		slog.Debug("Collected homepage", "url", siteURL, "type", "ln")
+	}
+
+	// 2. Queue links from homepage
+	frontier := newCrawlFrontier(opts.balance, typeOf)
+	links := extractLinks(html, siteU, opts.filter)
+	rand.Shuffle(len(links), func(i, j int) { links[i], links[j] = links[j], links[i] })
+	for _, link := range links {
+		frontier.push(link, 1)
+	}
+
+	// 3. Follow links on same domain breadth-first, those of wanted page
+	// types first within each depth
+	for frontier.len() > 0 {
+		next := frontier.pop()
+		link := next.url
+		if collected >= opts.maxPerSite {
+			break
+		}
+		if opts.maxTotal > 0 && *opts.total >= opts.maxTotal {
+			break
+		}
+
+		linkU, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+
+		if linkU.Hostname() != siteHost {
+			continue
+		}
+
+		normalized := normalizeURL(link)
+		if visited[normalized] {
+			continue
+		}
+		visited[normalized] = true
+
+		if skipURL(linkU) {
+			continue
+		}
+		if !opts.filter.allow(link) {
+			slog.Debug("Skipping filtered link", "url", link)
+			continue
+		}
+
+		pageType := typeOf(link)
+		if pageType != "" && quotaReached(pageType) {
+			slog.Debug("Page type quota reached", "url", link, "type", pageType)
+			continue
+		}
+
+		linkHTML, linkStatus, err := fetchPage(client, link, userAgent)
+		if err != nil {
+			slog.Debug("Failed to fetch link", "url", link, "error", err)
+			continue
+		}
+
+		// Once the site challenges the crawler, the pages that follow are
+		// challenges too.
+		if isBotChallenge(linkHTML) {
+			slog.Warn("Bot challenge, leaving site", "url", link)
+			break
+		}
+		if len(linkHTML) >= 100 && isMaintenance(linkHTML, linkStatus) {
+			if !quotaReached(maintenanceType) {
+				record(linkHTML, link, maintenanceType, linkStatus)
+			}
+			slog.Info("Site under maintenance, leaving site", "url", link)
+			break
+		}
+
+		if linkStatus == 200 && len(linkHTML) >= 100 && pageType != "" {
+			record(linkHTML, link, pageType, linkStatus)
+			slog.Debug("Collected link", "url", link, "type", pageType)
+
+			if opts.maxDepth == 0 || next.depth < opts.maxDepth {
+				for _, sub := range extractLinks(linkHTML, siteU, opts.filter) {
+					frontier.push(sub, next.depth+1)
+				}
+			}
+		}
+
+		// Mangle with probability prob404, or always while error pages
+		// are short of their target share
+		prob404 := opts.prob404
+		if opts.balance.deficit("s4") > 0 || opts.balance.deficit("er") > 0 {
+			prob404 = 1
+		}
+		if rand.Float64() < prob404 && len(linkU.Path) > 1 && !(quotaReached("s4") && quotaReached("er")) {
+			if opts.maxTotal > 0 && *opts.total >= opts.maxTotal {
+				break
+			}
+			if collected >= opts.maxPerSite {
+				break
+			}
+
+			mangledURL := manglePath(link)
+			if mangledURL != "" && !visited[mangledURL] {
+				visited[mangledURL] = true
+				mangledHTML, mangledStatus, err := fetchPage(client, mangledURL, userAgent)
+				if err != nil {
+					slog.Debug("Failed mangled", "url", mangledURL, "error", err)
+					continue
+				}
+
+				if isBotChallenge(mangledHTML) {
+					slog.Warn("Bot challenge, leaving site", "url", mangledURL)
+					break
+				}
+				if len(mangledHTML) >= 100 && (mangledStatus == 200 || mangledStatus == 404) {
+					mangledType := "s4"
+					if mangledStatus == 404 {
+						mangledType = "er"
+					}
+					if quotaReached(mangledType) {
+						slog.Debug("Page type quota reached", "url", mangledURL, "type", mangledType)
+						continue
+					}
+					record(mangledHTML, mangledURL, mangledType, mangledStatus)
+					slog.Debug("Collected mangled", "url", mangledURL, "status", mangledStatus, "type", mangledType)
+				}
+			}
+		}
+	}
+
+	return collected, nil
+}
+
+// defaultBlocklist matches links a crawler should not follow: ones with
+// side effects (logging out, changing a cart, deleting or unsubscribing)
+// and locale switches that return a duplicate of a page already seen.
+var defaultBlocklist = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)/(log-?out|sign-?out|logoff)\b`),
+	regexp.MustCompile(`(?i)(add[-_]to[-_]cart|/cart/(add|remove|update)|[?&](add-to-cart|remove_item)=)`),
+	regexp.MustCompile(`(?i)/(delete|remove|unsubscribe)\b`),
+	regexp.MustCompile(`(?i)[?&](lang|locale|hl|language)=`),
+}
+
+// urlFilter decides which discovered links the crawler follows.
+type urlFilter struct {
+	include   []*regexp.Regexp // if set, a link must match one
+	exclude   []*regexp.Regexp
+	blocklist bool // also skip links whose anchor looks destructive
+}
+
+// newURLFilter compiles the --include-pattern and --exclude-pattern
+// regexps, adding defaultBlocklist to the excludes if blocklist is set.
+func newURLFilter(include, exclude []string, blocklist bool) (urlFilter, error) {
+	f := urlFilter{blocklist: blocklist}
+	for _, p := range include {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return f, fmt.Errorf("--include-pattern: %w", err)
+		}
+		f.include = append(f.include, re)
+	}
+	for _, p := range exclude {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return f, fmt.Errorf("--exclude-pattern: %w", err)
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	if blocklist {
+		f.exclude = append(f.exclude, defaultBlocklist...)
+	}
+	return f, nil
+}
+
+// allow reports whether the crawler may follow link.
+func (f urlFilter) allow(link string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(link) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractLinks returns the distinct links in htmlStr resolved against base.
+// With the filter's blocklist on, links whose URL, text, or data-method
+// mark them as logging out, deleting, or the like are left out.
+func extractLinks(htmlStr string, base *url.URL, filter urlFilter) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlStr))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		if !exists || href == "" {
+			return
+		}
+
+		if strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "mailto:") {
+			return
+		}
+		if filter.blocklist && htmlutil.IsDestructiveLink(s) {
+			slog.Debug("Skipping destructive link", "href", href, "text", strings.TrimSpace(s.Text()))
+			return
+		}
+
+		u, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		resolved := base.ResolveReference(u).String()
+
+		if !seen[resolved] {
+			seen[resolved] = true
+			links = append(links, resolved)
+		}
+	})
+
+	return links
+}
+
+// detectPageType guesses the page type code of a link from its URL: by the
+// url_patterns of the pages config.json first, then by common paths.
+func detectPageType(u *url.URL, schema *storage.AnnotationSchema) string {
+	if tp := schema.TypeForURLPath(u.Path); tp != "" {
+		return tp
+	}
+	path := strings.ToLower(u.Path)
+	host := strings.ToLower(u.Hostname())
+
+	if matchAny(path, "/login", "/signin", "/sign-in", "/sign_in", "/wp-login", "/sso/start", "/auth/login", "/user/login", "/account/login", "/accounts/login") {
+		return "lg"
+	}
+
+	if matchAny(path, "/register", "/signup", "/sign-up", "/sign_up", "/join", "/create-account", "/user/register", "/accounts/emailsignup") {
+		return "rg"
+	}
+
+	if matchAny(path, "/forgot", "/reset-password", "/password/reset", "/password/new", "/account-recovery", "/account/recover", "/password_reset", "/forgot_password", "/forgot-password") {
+		return "pr"
+	}
+
+	if matchAny(path, "/contact", "/contact-us", "/contact_us") {
+		return "ct"
+	}
+
+	if matchAny(path, "/search") || u.Query().Get("q") != "" || u.Query().Get("s") != "" || u.Query().Get("query") != "" {
+		return "sr"
+	}
+
+	if matchSegments(path, "settings", "preferences", "profile/edit", "account/edit", "account/profile", "my-account", "myaccount") ||
+		strings.TrimSuffix(path, "/") == "/account" || strings.TrimSuffix(path, "/") == "/profile" {
+		return "st"
+	}
+
+	if matchSegments(path, "pricing", "plans-and-pricing", "plans") {
+		return "pc"
+	}
+
+	if matchSegments(path, "checkout", "cart", "basket", "shopping-bag") {
+		return "ck"
+	}
+
+	if matchSegments(path, "docs", "api-docs", "apidocs", "swagger", "redoc", "api-reference", "developer", "developers") ||
+		strings.HasPrefix(host, "docs.") || strings.HasPrefix(host, "developer.") || strings.HasPrefix(host, "developers.") {
+		return "dc"
+	}
+
+	if matchAny(path, "/blog", "/post/", "/posts/", "/article/", "/articles/", "/news/") ||
+		strings.HasPrefix(host, "blog.") || strings.HasPrefix(host, "engineering.") {
+		return "bl"
+	}
+
+	if matchAny(path, "/product/", "/products/", "/dp/", "/item/", "/itm/", "/p/", "/listing/") {
+		return "pd"
+	}
+
+	return ""
+}
+
+func matchAny(path string, patterns ...string) bool {
+	for _, p := range patterns {
+		if strings.Contains(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments reports whether path contains one of patterns as whole
+// path segments, so that "cart" matches /shop/cart/ but not /cartography.
+// A pattern may span segments, as in "account/edit".
+func matchSegments(path string, patterns ...string) bool {
+	segments := "/" + strings.Trim(path, "/") + "/"
+	for _, p := range patterns {
+		if strings.Contains(segments, "/"+p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func skipURL(u *url.URL) bool {
+	path := strings.ToLower(u.Path)
+	for _, ext := range []string{".js", ".css", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".pdf", ".zip", ".xml", ".json", ".woff", ".woff2", ".ttf", ".mp4", ".mp3", ".webp", ".avif"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.Fragment = ""
+	s := u.String()
+	return strings.TrimRight(s, "/")
+}
@@ -0,0 +1,68 @@
+package cli
+
+// frontierLink is a link waiting to be crawled.
+type frontierLink struct {
+	url   string
+	depth int // clicks from the homepage
+	seq   int // discovery order
+}
+
+// crawlFrontier holds the links a site crawl has yet to visit, handed out
+// breadth-first: every link of one depth goes before any of the next.
+// Within a depth, links of the page type furthest below its target share
+// come first, then links whose page type can be told from the URL (the
+// only ones the crawl collects), then the rest in discovery order.
+type crawlFrontier struct {
+	links   []frontierLink
+	queued  map[string]bool
+	seq     int
+	balance *classBalance
+	typeOf  func(string) string
+}
+
+func newCrawlFrontier(balance *classBalance, typeOf func(string) string) *crawlFrontier {
+	return &crawlFrontier{queued: make(map[string]bool), balance: balance, typeOf: typeOf}
+}
+
+// push queues link at depth unless it was queued before.
+func (f *crawlFrontier) push(link string, depth int) {
+	key := normalizeURL(link)
+	if f.queued[key] {
+		return
+	}
+	f.queued[key] = true
+	f.links = append(f.links, frontierLink{url: link, depth: depth, seq: f.seq})
+	f.seq++
+}
+
+func (f *crawlFrontier) len() int {
+	return len(f.links)
+}
+
+// pop removes and returns the link to visit next.
+func (f *crawlFrontier) pop() frontierLink {
+	best := 0
+	for i := 1; i < len(f.links); i++ {
+		if f.before(f.links[i], f.links[best]) {
+			best = i
+		}
+	}
+	link := f.links[best]
+	f.links = append(f.links[:best], f.links[best+1:]...)
+	return link
+}
+
+// before reports whether a should be visited before b.
+func (f *crawlFrontier) before(a, b frontierLink) bool {
+	if a.depth != b.depth {
+		return a.depth < b.depth
+	}
+	typeA, typeB := f.typeOf(a.url), f.typeOf(b.url)
+	if da, db := max(f.balance.deficit(typeA), 0), max(f.balance.deficit(typeB), 0); da != db {
+		return da > db
+	}
+	if (typeA != "") != (typeB != "") {
+		return typeA != ""
+	}
+	return a.seq < b.seq
+}
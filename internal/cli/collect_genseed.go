@@ -1,11 +1,13 @@
-package collect
+package cli
 
 import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/happyhackingspace/dit/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -13,8 +15,10 @@ func (c *CLI) newGenSeedCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "gen-seeds",
 		Short: "Generate seed file from common URL patterns",
-		Example: `  dit-collect gen-seeds --domains domains.txt --output seeds.jsonl
-  dit-collect gen-seeds --domains domains.txt --output seeds.jsonl --types login,registration`,
+		Example: `  dit collect gen-seeds --domains domains.txt --output seeds.jsonl
+  dit collect gen-seeds --domains domains.txt --output seeds.jsonl --types login,registration
+  dit collect gen-seeds --domains saas.txt --output seeds.jsonl --types pricing,checkout
+  dit collect gen-seeds --domains domains.txt --data-folder data --types 'status page'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			domainsFile, _ := cmd.Flags().GetString("domains")
 			output, _ := cmd.Flags().GetString("output")
@@ -27,6 +31,16 @@ func (c *CLI) newGenSeedCommand() *cobra.Command {
 
 			typeList := strings.Split(types, ",")
 			typePatterns := getTypePatterns()
+			// Page types with url_patterns in the pages config.json add to,
+			// or replace, the built-in patterns. gen-seeds' own --output is
+			// the seed file, so the pages folder is always under
+			// --data-folder.
+			dataFolder, _ := cmd.Flags().GetString("data-folder")
+			if schema, err := storage.NewPageStorage(filepath.Join(dataFolder, "pages")).GetPageSchema(); err == nil {
+				for _, t := range schema.URLPatterns {
+					typePatterns[schema.TypesInv[t.Short]] = t.Patterns
+				}
+			}
 
 			f, err := os.Create(output)
 			if err != nil {
@@ -79,7 +93,7 @@ func (c *CLI) newGenSeedCommand() *cobra.Command {
 	}
 	cmd.Flags().String("domains", "", "File with domain list (one per line)")
 	cmd.Flags().String("output", "seeds.jsonl", "Output seed file")
-	cmd.Flags().String("types", "login,registration,search,contact,password_reset,error,soft_404,admin,landing", "Page types to generate seeds for")
+	cmd.Flags().String("types", "login,registration,search,contact,password_reset,error,soft_404,admin,pricing,checkout,docs,landing", "Page types to generate seeds for, including those with url_patterns in <data-folder>/pages/config.json")
 	_ = cmd.MarkFlagRequired("domains")
 	return cmd
 }
@@ -92,6 +106,10 @@ func getTypePatterns() map[string][]string {
 		"contact":        {"/contact", "/contact-us", "/about/contact"},
 		"password_reset": {"/forgot-password", "/reset-password", "/account/recover", "/password/reset"},
 		"admin":          {"/admin", "/wp-admin", "/dashboard", "/admin/login"},
+		"settings":       {"/settings", "/account", "/account/settings", "/profile/edit", "/preferences"},
+		"pricing":        {"/pricing", "/plans"},
+		"checkout":       {"/cart", "/checkout", "/basket"},
+		"docs":           {"/docs", "/api-docs", "/swagger-ui/index.html", "/redoc", "/developers"},
 		"error":          {"/this-page-does-not-exist-404-test", "/nonexistent-page-xyz"},
 		"soft_404":       {"/this-page-does-not-exist-404-test"},
 	}
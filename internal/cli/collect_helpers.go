@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// seedEntry represents a single entry in the seed file (JSONL).
+type seedEntry struct {
+	URL          string `json:"url"`
+	ExpectedType string `json:"expected_type"`
+	Mangle       bool   `json:"mangle,omitempty"`
+}
+
+// pageIndexEntry matches the data/pages/index.json format. Collected
+// pages are Pending, auto-labeled, until confirmed by dit collect review.
+type pageIndexEntry struct {
+	URL      string `json:"url"`
+	PageType string `json:"page_type"`
+	Pending  bool   `json:"pending,omitempty"`
+	Raw      string `json:"raw,omitempty"`    // original response, if the page was normalized
+	Status   int    `json:"status,omitempty"` // HTTP status the page was served with; 0 if not recorded
+}
+
+// httpClient is the interface used for HTTP requests (allows testing).
+type httpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+func loadSeeds(path string) ([]seedEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var seeds []seedEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var s seedEntry
+		if err := json.Unmarshal([]byte(line), &s); err != nil {
+			slog.Warn("Skipping invalid seed line", "line", line, "error", err)
+			continue
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, scanner.Err()
+}
+
+func loadLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func loadIndex(dir string) (map[string]pageIndexEntry, error) {
+	path := filepath.Join(dir, "index.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]pageIndexEntry), nil
+		}
+		return nil, err
+	}
+	var index map[string]pageIndexEntry
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func saveIndex(dir string, index map[string]pageIndexEntry) error {
+	data, err := json.MarshalIndent(index, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index.json"), data, 0644)
+}
+
+func fetchPage(client httpClient, rawURL, userAgent string) (string, int, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body := make([]byte, 0, 1024*1024)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			body = append(body, buf[:n]...)
+			if len(body) > 5*1024*1024 {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return string(body), resp.StatusCode, nil
+}
+
+// fetchAndSave saves the page at rawURL under pageType, or as a
+// maintenance page if the site is down, and returns the type saved.
+func fetchAndSave(client httpClient, rawURL, pageType, userAgent, outputDir string, files pageFileOpts, index map[string]pageIndexEntry) (string, error) {
+	html, status, err := fetchPage(client, rawURL, userAgent)
+	if err != nil {
+		return "", err
+	}
+	if len(html) < 100 {
+		return "", fmt.Errorf("response too short (%d bytes)", len(html))
+	}
+	if isBotChallenge(html) {
+		return "", errBotChallenge
+	}
+	if isMaintenance(html, status) {
+		pageType = maintenanceType
+	} else if status >= 400 {
+		return "", fmt.Errorf("HTTP %d", status)
+	}
+
+	filename, entry := savePage(html, rawURL, pageType, status, outputDir, files, index)
+	index[filename] = entry
+	return pageType, nil
+}
+
+func fetchAndSaveMangled(client httpClient, mangledURL, userAgent, outputDir string, files pageFileOpts, index map[string]pageIndexEntry) (int, error) {
+	html, status, err := fetchPage(client, mangledURL, userAgent)
+	if err != nil {
+		return 0, err
+	}
+	if len(html) < 100 {
+		return status, fmt.Errorf("response too short (%d bytes)", len(html))
+	}
+
+	if status != 200 && status != 404 {
+		return status, fmt.Errorf("unexpected status %d for mangled URL", status)
+	}
+	if isBotChallenge(html) {
+		return status, errBotChallenge
+	}
+
+	pageType := "s4"
+	if status == 404 {
+		pageType = "er"
+	}
+
+	filename, entry := savePage(html, mangledURL, pageType, status, outputDir, files, index)
+	index[filename] = entry
+	return status, nil
+}
+
+// errBotChallenge is returned for a bot-protection interstitial served in
+// place of the page requested, which would otherwise be saved under the
+// label of that page.
+var errBotChallenge = errors.New("bot challenge page")
+
+// isBotChallenge reports whether html is a bot-protection interstitial.
+func isBotChallenge(html string) bool {
+	doc, err := htmlutil.LoadHTMLString(html)
+	return err == nil && htmlutil.JunkPageKind(doc) == htmlutil.JunkBotChallenge
+}
+
+// maintenanceType is the page type code of maintenance pages, which a
+// site that is down serves in place of every page.
+const maintenanceType = "mt"
+
+// isMaintenance reports whether a response is a maintenance page, served
+// with HTTP 503 or, by some sites, 200.
+func isMaintenance(html string, status int) bool {
+	if status != http.StatusOK && status != http.StatusServiceUnavailable {
+		return false
+	}
+	doc, err := htmlutil.LoadHTMLString(html)
+	return err == nil && htmlutil.IsMaintenancePage(doc)
+}
+
+// pageFileOpts controls how collected pages are stored.
+type pageFileOpts struct {
+	normalize  bool // store pages normalized, originals under raw/
+	hashDigits int  // hex digits of the URL hash in filenames
+}
+
+// Default and bounds of the --hash-digits flag. 20 hex digits (80 bits)
+// keep collisions unlikely well past millions of pages.
+const (
+	defaultHashDigits = 20
+	minHashDigits     = 12
+)
+
+// savePage writes a collected page to outputDir and returns its index
+// path and pending entry. With files.normalize, the indexed file, which
+// training reads, holds htmlutil.NormalizeHTML's smaller version and the
+// response as received is kept under raw/, by the same name, for
+// re-processing.
+func savePage(html, rawURL, pageType string, status int, outputDir string, files pageFileOpts, index map[string]pageIndexEntry) (string, pageIndexEntry) {
+	entry := pageIndexEntry{URL: rawURL, PageType: pageType, Status: status, Pending: true}
+	filename := pageFilename(rawURL, outputDir, files.hashDigits, index)
+	if files.normalize {
+		normalized, err := htmlutil.NormalizeHTML(html)
+		if err != nil {
+			slog.Warn("Cannot normalize page, storing it as received", "url", rawURL, "error", err)
+		} else {
+			entry.Raw = "raw/" + strings.TrimPrefix(filename, "html/")
+			writePageFile(outputDir, entry.Raw, html)
+			html = normalized
+		}
+	}
+	writePageFile(outputDir, filename, pageURLComment(rawURL)+html)
+	return filename, entry
+}
+
+// pageFilename returns the path under outputDir to store the page of
+// rawURL at: that of its index entry if it has one, so that re-collecting
+// a URL replaces its page even in folders named by an older scheme, else
+// html/ and the first digits hex digits of the SHA-256 of rawURL. If that
+// name is taken by another URL's page, a -2, -3, ... suffix is added
+// until it is not.
+func pageFilename(rawURL, outputDir string, digits int, index map[string]pageIndexEntry) string {
+	var existing []string
+	for filename, entry := range index {
+		if entry.URL == rawURL {
+			existing = append(existing, filename)
+		}
+	}
+	if len(existing) > 0 {
+		return slices.Min(existing)
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(sum[:])[:digits]
+	filename := "html/" + name + ".html"
+	for n := 2; !pageFileFree(outputDir, filename, rawURL, index); n++ {
+		slog.Warn("Page filename taken by another URL, adding a suffix", "path", filename, "url", rawURL)
+		filename = fmt.Sprintf("html/%s-%d.html", name, n)
+	}
+	return filename
+}
+
+// writePageFile writes content to filename under outputDir.
+func writePageFile(outputDir, filename, content string) {
+	path := filepath.Join(outputDir, filename)
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	_ = os.WriteFile(path, []byte(content), 0644)
+}
+
+// pageFileFree reports whether the page of rawURL may be written to
+// filename: no page is stored there, or the one stored there is of rawURL
+// by its index entry or its URL comment.
+func pageFileFree(outputDir, filename, rawURL string, index map[string]pageIndexEntry) bool {
+	if entry, ok := index[filename]; ok {
+		return entry.URL == rawURL
+	}
+	data, err := os.ReadFile(filepath.Join(outputDir, filename))
+	if err != nil {
+		return true
+	}
+	storedURL, ok := storedPageURL(data)
+	return ok && storedURL == commentURL(rawURL)
+}
+
+// pageURLPrefix starts the comment that records a stored page's URL.
+const pageURLPrefix = "<!-- dit-url: "
+
+// pageURLComment returns the first line of a stored page, recording the
+// URL it was fetched from so the file can be traced to it without
+// index.json. Raw copies are kept as received, without it.
+func pageURLComment(rawURL string) string {
+	return pageURLPrefix + commentURL(rawURL) + " -->\n"
+}
+
+// commentURL returns rawURL with ">" percent-encoded, which cannot end
+// the HTML comment it is written in and addresses the same resource.
+func commentURL(rawURL string) string {
+	return strings.ReplaceAll(rawURL, ">", "%3E")
+}
+
+// storedPageURL returns the URL recorded in the comment of a stored page,
+// as commentURL wrote it, and whether there is one.
+func storedPageURL(data []byte) (string, bool) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	rest, ok := bytes.CutPrefix(line, []byte(pageURLPrefix))
+	if !ok {
+		return "", false
+	}
+	storedURL, ok := bytes.CutSuffix(rest, []byte(" -->"))
+	return string(storedURL), ok
+}
+
+func manglePath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	path := u.Path
+	if path == "" || path == "/" {
+		path = "/index"
+	}
+
+	lastSlash := strings.LastIndex(path, "/")
+	if lastSlash < 0 {
+		lastSlash = 0
+	}
+	segment := path[lastSlash+1:]
+	if segment == "" {
+		segment = "page"
+		path += segment
+		lastSlash = strings.LastIndex(path, "/")
+		segment = path[lastSlash+1:]
+	}
+
+	pos := rand.IntN(len(segment) + 1)
+	ch := byte('a' + rand.IntN(26))
+	mangled := segment[:pos] + string(ch) + segment[pos:]
+
+	u.Path = path[:lastSlash+1] + mangled
+	return u.String()
+}
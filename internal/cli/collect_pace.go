@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backoffStep is the least a 429 or 503 response raises a host's delay to.
+const backoffStep = time.Second
+
+// pacedClient spaces out requests to each host by a delay that adapts to
+// how the host responds, in the spirit of Scrapy's AutoThrottle: the delay
+// drifts toward the host's response time, never below min, and doubles
+// (or follows Retry-After) on 429 Too Many Requests and 503 Service
+// Unavailable, up to max. Hosts are paced independently, so a slow or
+// rate-limiting site does not hold back the others.
+type pacedClient struct {
+	client   httpClient
+	min, max time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostPace
+}
+
+// hostPace is the politeness state of one host.
+type hostPace struct {
+	delay time.Duration // pause between requests
+	next  time.Time     // earliest time of the next request
+}
+
+func newPacedClient(client httpClient, minDelay, maxDelay time.Duration) *pacedClient {
+	return &pacedClient{client: client, min: minDelay, max: max(maxDelay, minDelay), hosts: make(map[string]*hostPace)}
+}
+
+// Do waits out the host's delay, sends req, and adapts the delay to the
+// response.
+func (c *pacedClient) Do(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	c.mu.Lock()
+	pace, ok := c.hosts[host]
+	if !ok {
+		pace = &hostPace{delay: c.min}
+		c.hosts[host] = pace
+	}
+	wait := time.Until(pace.next)
+	c.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+	elapsed := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil:
+		// Timeouts and refused connections: keep the delay as it is.
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		pace.delay = min(max(2*pace.delay, backoffStep, retryAfter(resp.Header)), c.max)
+		slog.Info("Host is throttling, backing off", "host", host, "status", resp.StatusCode, "delay", pace.delay)
+	case resp.StatusCode >= 500:
+		// Errors are often fast; do not let them speed the crawl up.
+		pace.delay = min(max(pace.delay, (pace.delay+elapsed)/2), c.max)
+	default:
+		pace.delay = min(max((pace.delay+elapsed)/2, c.min), c.max)
+	}
+	pace.next = time.Now().Add(pace.delay)
+	return resp, err
+}
+
+// retryAfter returns the wait a Retry-After header asks for, given in
+// seconds or as an HTTP date, or 0 if there is none.
+func retryAfter(h http.Header) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
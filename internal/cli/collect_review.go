@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newCollectReviewCommand() *cobra.Command {
+	var acceptAll bool
+
+	cmd := &cobra.Command{
+		Use:   "review",
+		Short: "Confirm, correct, or discard collected pages before training uses them",
+		Long: `Walk through the pages collect labeled automatically and that train and
+evaluate therefore skip. For each page the URL, title, a text snippet, and
+the current label are shown; answer with:
+
+  Enter       confirm the label
+  <type>      relabel the page (short code or full name from config.json)
+  d           discard the page and delete its HTML file
+  s           leave it pending for later
+  q           stop; decisions so far are saved`,
+		Example: `  dit collect review
+  dit collect review --data-folder data
+  dit collect review --accept-all`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stats, err := reviewPages(pagesDir(cmd), os.Stdin, os.Stdout, acceptAll)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Confirmed %d, relabeled %d, discarded %d, %d still pending\n",
+				stats.confirmed, stats.relabeled, stats.discarded, stats.pending)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&acceptAll, "accept-all", false, "Confirm every pending label without prompting")
+	return cmd
+}
+
+type reviewStats struct {
+	confirmed, relabeled, discarded, pending int
+}
+
+// reviewPages prompts on out for each pending page in dir and reads the
+// decisions from in, saving the index after each one so an interrupted
+// review loses nothing.
+func reviewPages(dir string, in io.Reader, out io.Writer, acceptAll bool) (reviewStats, error) {
+	var stats reviewStats
+	index, err := loadIndex(dir)
+	if err != nil {
+		return stats, fmt.Errorf("load index: %w", err)
+	}
+	var queue []string
+	for filename, entry := range index {
+		if entry.Pending {
+			queue = append(queue, filename)
+		}
+	}
+	slices.Sort(queue)
+	stats.pending = len(queue)
+	if len(queue) == 0 {
+		return stats, nil
+	}
+
+	// Without a config.json any label is accepted.
+	schema, _ := storage.NewPageStorage(dir).GetPageSchema()
+
+	if acceptAll {
+		for _, filename := range queue {
+			entry := index[filename]
+			entry.Pending = false
+			index[filename] = entry
+		}
+		stats.confirmed, stats.pending = len(queue), 0
+		return stats, saveIndex(dir, index)
+	}
+
+	input := bufio.NewScanner(in)
+	for i, filename := range queue {
+		entry := index[filename]
+		fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(queue), entry.URL)
+		if data, err := os.ReadFile(filepath.Join(dir, filename)); err != nil {
+			fmt.Fprintf(out, "  (cannot read %s: %v)\n", filename, err)
+		} else if doc, err := htmlutil.LoadHTMLString(string(data)); err == nil {
+			fmt.Fprintf(out, "  title:   %s\n", htmlutil.GetPageTitle(doc))
+			fmt.Fprintf(out, "  snippet: %s\n", htmlutil.GetBodyText(doc, 160))
+		}
+		fmt.Fprintf(out, "  label:   %s\n", describeLabel(schema, entry.PageType))
+
+		answer, err := promptReview(input, out, schema)
+		if err != nil || answer == "q" {
+			return stats, err
+		}
+		switch answer {
+		case "s":
+			continue
+		case "":
+			stats.confirmed++
+		case "d":
+			delete(index, filename)
+			_ = os.Remove(filepath.Join(dir, filename))
+			if entry.Raw != "" {
+				_ = os.Remove(filepath.Join(dir, entry.Raw))
+			}
+			stats.discarded++
+		default:
+			entry.PageType = answer
+			stats.relabeled++
+		}
+		if answer != "d" {
+			entry.Pending = false
+			index[filename] = entry
+		}
+		stats.pending--
+		if err := saveIndex(dir, index); err != nil {
+			return stats, fmt.Errorf("save index: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// promptReview asks until it gets a valid answer: "" to confirm, "d",
+// "s", "q", or a page type, returned as its short code. Running out of
+// input counts as "q".
+func promptReview(input *bufio.Scanner, out io.Writer, schema *storage.AnnotationSchema) (string, error) {
+	for {
+		fmt.Fprint(out, "Enter=confirm, <type>=relabel, d=discard, s=skip, q=quit: ")
+		if !input.Scan() {
+			return "q", input.Err()
+		}
+		switch answer := strings.TrimSpace(input.Text()); answer {
+		case "", "d", "s", "q":
+			return answer, nil
+		default:
+			if label, ok := resolveLabel(schema, answer); ok {
+				return label, nil
+			}
+			fmt.Fprintf(out, "  unknown page type %q\n", answer)
+		}
+	}
+}
+
+// resolveLabel maps a short code or full page type name to the short code
+// stored in index.json.
+func resolveLabel(schema *storage.AnnotationSchema, label string) (string, bool) {
+	if schema == nil {
+		return label, true
+	}
+	if _, ok := schema.TypesInv[label]; ok {
+		return label, true
+	}
+	short, ok := schema.Types[label]
+	return short, ok
+}
+
+func describeLabel(schema *storage.AnnotationSchema, label string) string {
+	if schema != nil {
+		if full, ok := schema.TypesInv[label]; ok && full != label {
+			return label + " (" + full + ")"
+		}
+	}
+	return label
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newCompactModelCommand() *cobra.Command {
+	var decimals int
+
+	cmd := &cobra.Command{
+		Use:   "compact-model <modelfile> <output.json.gz>",
+		Short: "Write a quantized, compressed copy of a model for embedding",
+		Long: `Round the weights of a model and save it gzip-compressed, for the
+compact model compiled into the library (embedded/model.json.gz) that
+dit.New falls back to offline. Any dit command also loads .gz models.`,
+		Args: cobra.ExactArgs(2),
+		Example: `  dit compact-model model.json embedded/model.json.gz
+  dit compact-model model.json small.json.gz --decimals 2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dest := args[0], args[1]
+			if !strings.HasSuffix(dest, ".gz") {
+				return fmt.Errorf("output %s must end in .gz", dest)
+			}
+			cl, err := dit.Load(src)
+			if err != nil {
+				return err
+			}
+			if err := cl.Quantize(decimals); err != nil {
+				return err
+			}
+			if err := cl.Save(dest); err != nil {
+				return err
+			}
+			before, _ := os.Stat(src)
+			after, err := os.Stat(dest)
+			if err != nil {
+				return err
+			}
+			slog.Info("Compact model saved", "path", dest,
+				"size", fmt.Sprintf("%.1fMB", float64(after.Size())/1024/1024),
+				"original", fmt.Sprintf("%.1fMB", float64(before.Size())/1024/1024))
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&decimals, "decimals", 3, "Decimal places to keep in model weights")
+	return cmd
+}
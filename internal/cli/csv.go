@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/happyhackingspace/dit"
+)
+
+// csvHeader is the flattened schema of --format csv: one row per field, or
+// per form without fields, or per page without forms. Probability results
+// instead get one row per candidate type, with the probability filled in
+// and only the column the type belongs to (page_type, form_type, or
+// field_type) set.
+var csvHeader = []string{"url", "page_type", "form_index", "form_type", "field_name", "field_type", "probability"}
+
+// csvWriter writes classification results as CSV rows.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// newCSVWriter writes the header and returns a writer for result rows.
+// Call Flush when done.
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	if err := cw.w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Flush writes buffered rows and reports any write error.
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// WriteResult writes the rows of a `dit run` result for url.
+func (cw *csvWriter) WriteResult(url string, result any) error {
+	switch r := result.(type) {
+	case *dit.PageResult:
+		return cw.writeForms(url, r.Type, r.Forms)
+	case []dit.FormResult:
+		return cw.writeForms(url, "", r)
+	case *dit.PageResultProba:
+		for _, tp := range byProba(r.Type) {
+			if err := cw.row(url, tp, -1, "", "", "", r.Type[tp]); err != nil {
+				return err
+			}
+		}
+		return cw.writeFormsProba(url, r.Forms)
+	case []dit.FormResultProba:
+		return cw.writeFormsProba(url, r)
+	}
+	return nil
+}
+
+func (cw *csvWriter) writeForms(url, pageType string, forms []dit.FormResult) error {
+	if len(forms) == 0 {
+		return cw.row(url, pageType, -1, "", "", "", -1)
+	}
+	for i, form := range forms {
+		fields := form.FieldList
+		if fields == nil {
+			for _, name := range slices.Sorted(maps.Keys(form.Fields)) {
+				fields = append(fields, dit.Field{Name: name, Type: form.Fields[name]})
+			}
+		}
+		if len(fields) == 0 {
+			if err := cw.row(url, pageType, i, form.Type, "", "", -1); err != nil {
+				return err
+			}
+		}
+		for _, field := range fields {
+			if err := cw.row(url, pageType, i, form.Type, field.Name, field.Type, -1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) writeFormsProba(url string, forms []dit.FormResultProba) error {
+	for i, form := range forms {
+		for _, tp := range byProba(form.Type) {
+			if err := cw.row(url, "", i, tp, "", "", form.Type[tp]); err != nil {
+				return err
+			}
+		}
+		fields := form.FieldList
+		if fields == nil {
+			for _, name := range slices.Sorted(maps.Keys(form.Fields)) {
+				fields = append(fields, dit.FieldProba{Name: name, Type: form.Fields[name]})
+			}
+		}
+		for _, field := range fields {
+			for _, tp := range byProba(field.Type) {
+				if err := cw.row(url, "", i, "", field.Name, tp, field.Type[tp]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// row writes one record; negative formIndex and proba leave their columns
+// empty.
+func (cw *csvWriter) row(url, pageType string, formIndex int, formType, fieldName, fieldType string, proba float64) error {
+	index, probability := "", ""
+	if formIndex >= 0 {
+		index = strconv.Itoa(formIndex)
+	}
+	if proba >= 0 {
+		probability = strconv.FormatFloat(proba, 'f', -1, 64)
+	}
+	return cw.w.Write([]string{url, pageType, index, formType, fieldName, fieldType, probability})
+}
+
+// byProba returns the types of probs, most probable first.
+func byProba(probs map[string]float64) []string {
+	types := slices.Collect(maps.Keys(probs))
+	slices.SortFunc(types, func(a, b string) int {
+		return cmp.Or(cmp.Compare(probs[b], probs[a]), cmp.Compare(a, b))
+	})
+	return types
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/happyhackingspace/dit"
 	"github.com/spf13/cobra"
 )
 
@@ -50,7 +51,7 @@ func (c *CLI) newDataCommand() *cobra.Command {
 	}
 	uploadCmd.Flags().StringVar(&uploadDataFolder, "data-folder", "data", "Source folder for training data")
 
-	dataCmd.AddCommand(downloadCmd, uploadCmd)
+	dataCmd.AddCommand(downloadCmd, uploadCmd, c.newDataPrunePagesCommand(), c.newDataLintCommand(), c.newDataSimilarCommand())
 	return dataCmd
 }
 
@@ -58,11 +59,11 @@ func dataDownload(dataFolder string) error {
 	slog.Info("Downloading training data", "url", hfDataURL)
 	resp, err := http.Get(hfDataURL)
 	if err != nil {
-		return fmt.Errorf("download data: %w", err)
+		return fmt.Errorf("download data: %w", &dit.FetchError{URL: hfDataURL, Err: err})
 	}
 	defer func() { _ = resp.Body.Close() }()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download data: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("download data: %w", &dit.FetchError{URL: hfDataURL, StatusCode: resp.StatusCode})
 	}
 
 	if err := os.RemoveAll(dataFolder); err != nil {
@@ -118,11 +119,11 @@ func dataDownload(dataFolder string) error {
 	slog.Info("Downloading model", "url", modelURL)
 	modelResp, err := http.Get(modelURL)
 	if err != nil {
-		return fmt.Errorf("download model: %w", err)
+		return fmt.Errorf("download model: %w", &dit.FetchError{URL: modelURL, Err: err})
 	}
 	defer func() { _ = modelResp.Body.Close() }()
 	if modelResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download model: HTTP %d", modelResp.StatusCode)
+		return fmt.Errorf("download model: %w", &dit.FetchError{URL: modelURL, StatusCode: modelResp.StatusCode})
 	}
 
 	mf, err := os.Create("model.json")
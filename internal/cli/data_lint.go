@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// longErrorPage is the body text length, in bytes, above which a page
+// served with HTTP 200 is too long to be an error page.
+const longErrorPage = 2000
+
+// lintIssue is a suspicious annotation found by dit data lint.
+type lintIssue struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"` // relative to the data folder
+	URL     string `json:"url,omitempty"`
+	Form    int    `json:"form"` // form index; -1 for page issues
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Fix     string `json:"fix"`
+}
+
+func (c *CLI) newDataLintCommand() *cobra.Command {
+	var (
+		dataFolder string
+		format     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Flag suspicious annotations in the training data",
+		Long: `Check form and page annotations for labels their HTML contradicts, each
+reported with a rule ID and a suggested fix:
+
+  login-without-password  a form labeled login has no password input
+  search-with-password    a form labeled search has a password input
+  password-on-text-input  a field labeled password is a plain text input
+  error-page-http-200     a page labeled error was served with HTTP 200
+                          and has long content
+
+Pages collected before dit recorded HTTP status are not checked for
+error-page-http-200. Nothing is changed; fix the labels in index.json
+or with dit collect review.`,
+		Example: `  dit data lint
+  dit data lint --data-folder data --format jsonl > issues.jsonl`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "jsonl" {
+				return fmt.Errorf("unknown format %q (want text or jsonl)", format)
+			}
+			formIssues, err := lintForms(filepath.Join(dataFolder, "forms"))
+			if err != nil {
+				return err
+			}
+			pageIssues, err := lintPages(filepath.Join(dataFolder, "pages"))
+			if err != nil {
+				return err
+			}
+			return writeLintIssues(os.Stdout, format, append(formIssues, pageIssues...))
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder with forms/ and pages/ subfolders")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or jsonl")
+	return cmd
+}
+
+// lintForms checks the form annotations in dir, if it has an index.
+func lintForms(dir string) ([]lintIssue, error) {
+	store := storage.NewStorage(dir)
+	index, err := store.GetIndex()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load form index: %w", err)
+	}
+	formSchema, _ := store.GetFormSchema()
+	fieldSchema, _ := store.GetFieldSchema()
+
+	var issues []lintIssue
+	for _, filename := range slices.Sorted(maps.Keys(index)) {
+		entry := index[filename]
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			slog.Warn("Cannot read page", "path", filename, "error", err)
+			continue
+		}
+		doc, err := htmlutil.LoadHTMLString(string(data))
+		if err != nil {
+			continue
+		}
+		forms := htmlutil.GetForms(doc)
+		for i, code := range entry.Forms {
+			if i >= len(forms) {
+				break
+			}
+			issue := lintIssue{Path: filepath.Join("forms", filename), URL: entry.URL, Form: i}
+			hasPassword := forms[i].Find(`input[type="password" i]`).Length() > 0
+			switch fullType(formSchema, code) {
+			case "login":
+				if !hasPassword {
+					issue.Rule, issue.Message = "login-without-password", "form labeled login has no password input"
+					issue.Fix = "relabel the form (e.g. password/login recovery, mfa, or other), unless the password is asked on a later step"
+					issues = append(issues, issue)
+				}
+			case "search":
+				if hasPassword {
+					issue.Rule, issue.Message = "search-with-password", "form labeled search has a password input"
+					issue.Fix = "relabel the form as login, or check that the label belongs to this form"
+					issues = append(issues, issue)
+				}
+			}
+			if i < len(entry.VisibleHTMLFields) {
+				issues = append(issues, lintFields(issue, forms[i], entry.VisibleHTMLFields[i], fieldSchema)...)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// lintFields checks the field annotations of one form; at is the issue
+// with the form's location filled in.
+func lintFields(at lintIssue, form *goquery.Selection, fields map[string]string, schema *storage.AnnotationSchema) []lintIssue {
+	elems := make(map[string]*goquery.Selection)
+	for _, elem := range htmlutil.GetFieldsToAnnotate(form) {
+		if name := elem.AttrOr("name", ""); elems[name] == nil {
+			elems[name] = elem
+		}
+	}
+
+	var issues []lintIssue
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		label := fullType(schema, fields[name])
+		elem := elems[name]
+		if label != "password" && label != "password confirmation" || elem == nil || goquery.NodeName(elem) != "input" {
+			continue
+		}
+		tp := strings.ToLower(strings.TrimSpace(elem.AttrOr("type", "text")))
+		if tp != "text" && tp != "" {
+			continue
+		}
+		issue := at
+		issue.Rule, issue.Field = "password-on-text-input", name
+		issue.Message = fmt.Sprintf("field labeled %s is an <input type=text>", label)
+		issue.Fix = "relabel the field (often the username or a one-time code next to the password), unless the site shows passwords in plain text"
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// lintPages checks the page annotations in dir.
+func lintPages(dir string) ([]lintIssue, error) {
+	index, err := loadIndex(dir)
+	if err != nil {
+		return nil, fmt.Errorf("load page index: %w", err)
+	}
+	schema, _ := storage.NewPageStorage(dir).GetPageSchema()
+
+	var issues []lintIssue
+	for _, filename := range slices.Sorted(maps.Keys(index)) {
+		entry := index[filename]
+		if entry.Status != 200 || fullType(schema, entry.PageType) != "error" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			slog.Warn("Cannot read page", "path", filename, "error", err)
+			continue
+		}
+		doc, err := htmlutil.LoadHTMLString(string(data))
+		if err != nil {
+			continue
+		}
+		if n := len(htmlutil.GetBodyText(doc, 0)); n >= longErrorPage {
+			issues = append(issues, lintIssue{
+				Rule: "error-page-http-200", Path: filepath.Join("pages", filename), URL: entry.URL, Form: -1,
+				Message: fmt.Sprintf("page labeled error was served with HTTP 200 and has %d bytes of text", n),
+				Fix:     "relabel the page as soft_404 if it says the page is missing, or as the type of page it shows",
+			})
+		}
+	}
+	return issues, nil
+}
+
+// writeLintIssues prints issues one per line, as text followed by a
+// summary or, for format "jsonl", as JSON objects.
+func writeLintIssues(w io.Writer, format string, issues []lintIssue) error {
+	if format == "jsonl" {
+		enc := json.NewEncoder(w)
+		for _, issue := range issues {
+			if err := enc.Encode(issue); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(issues) == 0 {
+		fmt.Fprintln(w, "No issues found.")
+		return nil
+	}
+	rules := make(map[string]int)
+	for _, issue := range issues {
+		where := issue.Path
+		if issue.Form >= 0 {
+			where += fmt.Sprintf(" form %d", issue.Form)
+		}
+		if issue.Field != "" {
+			where += fmt.Sprintf(" field %q", issue.Field)
+		}
+		fmt.Fprintf(w, "%s: [%s] %s\n    fix: %s\n", where, issue.Rule, issue.Message, issue.Fix)
+		rules[issue.Rule]++
+	}
+	var counts []string
+	for _, rule := range slices.Sorted(maps.Keys(rules)) {
+		counts = append(counts, fmt.Sprintf("%s %d", rule, rules[rule]))
+	}
+	fmt.Fprintf(w, "%d issues (%s)\n", len(issues), strings.Join(counts, ", "))
+	return nil
+}
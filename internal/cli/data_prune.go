@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// junkLabels are the page types a page of each junk kind is rightly
+// labeled with; such pages are training data for those types and kept.
+var junkLabels = map[string][]string{
+	htmlutil.JunkBotChallenge: {"captcha", "waf_block"},
+	htmlutil.JunkParked:       {"parked"},
+}
+
+func (c *CLI) newDataPrunePagesCommand() *cobra.Command {
+	var (
+		dataFolder string
+		dryRun     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune-pages",
+		Short: "Remove bot-challenge, parked-domain, and empty app-shell pages from the page dataset",
+		Long: `Remove collected pages that stand in for the page that was requested, so
+they do not teach the page classifier wrong labels:
+
+  bot_challenge  Cloudflare and other bot-protection interstitials
+  parked         parked or for-sale domains
+  app_shell      client-rendered apps with no content before JavaScript runs
+
+Challenge pages labeled captcha or waf_block and parked pages labeled parked
+are kept, as examples of those types. Pruned pages are dropped from
+index.json and their HTML files, including raw/ originals, are deleted.`,
+		Example: `  dit data prune-pages --dry-run
+  dit data prune-pages --data-folder data`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pruned, err := prunePages(filepath.Join(dataFolder, "pages"), dryRun)
+			if err != nil {
+				return err
+			}
+			verb := "Pruned"
+			if dryRun {
+				verb = "Would prune"
+			}
+			fmt.Printf("%s %d pages (bot_challenge %d, parked %d, app_shell %d)\n", verb,
+				pruned[htmlutil.JunkBotChallenge]+pruned[htmlutil.JunkParked]+pruned[htmlutil.JunkAppShell],
+				pruned[htmlutil.JunkBotChallenge], pruned[htmlutil.JunkParked], pruned[htmlutil.JunkAppShell])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder; pages are read from its pages/ subfolder")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report the pages that would be pruned")
+	return cmd
+}
+
+// prunePages removes junk pages from the page dataset in dir and returns
+// how many it removed, or would remove with dryRun, by junk kind.
+func prunePages(dir string, dryRun bool) (map[string]int, error) {
+	index, err := loadIndex(dir)
+	if err != nil {
+		return nil, fmt.Errorf("load index: %w", err)
+	}
+	schema, _ := storage.NewPageStorage(dir).GetPageSchema()
+
+	pruned := make(map[string]int)
+	for _, filename := range slices.Sorted(maps.Keys(index)) {
+		entry := index[filename]
+		// A normalized page has lost its inline scripts, which challenge
+		// and parking markup is often in, so the raw original is checked.
+		path := filename
+		if entry.Raw != "" {
+			path = entry.Raw
+		}
+		data, err := os.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			slog.Warn("Cannot read page", "path", path, "error", err)
+			continue
+		}
+		doc, err := htmlutil.LoadHTMLString(string(data))
+		if err != nil {
+			continue
+		}
+		kind := htmlutil.JunkPageKind(doc)
+		if kind == "" || slices.Contains(junkLabels[kind], fullType(schema, entry.PageType)) {
+			continue
+		}
+		pruned[kind]++
+		slog.Info("Pruning page", "path", filename, "url", entry.URL, "label", entry.PageType, "kind", kind)
+		if dryRun {
+			continue
+		}
+		delete(index, filename)
+		_ = os.Remove(filepath.Join(dir, filename))
+		if entry.Raw != "" {
+			_ = os.Remove(filepath.Join(dir, entry.Raw))
+		}
+	}
+	if dryRun || len(pruned) == 0 {
+		return pruned, nil
+	}
+	return pruned, saveIndex(dir, index)
+}
+
+// fullType returns the full name of a type label, or the label itself if
+// schema does not name it; schema is nil for a dataset without a
+// config.json, whose labels are then compared as they are.
+func fullType(schema *storage.AnnotationSchema, label string) string {
+	if schema != nil {
+		if full, ok := schema.TypesInv[label]; ok {
+			return full
+		}
+	}
+	return label
+}
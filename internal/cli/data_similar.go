@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+	"github.com/happyhackingspace/dit/vectorizer"
+	"github.com/spf13/cobra"
+)
+
+// nearDuplicate is the cosine similarity from which dit data similar calls
+// a training form a near-duplicate of the query.
+const nearDuplicate = 0.95
+
+// similarForm is an annotated form close to a query form.
+type similarForm struct {
+	Query     int     `json:"query_form"` // form index in the queried file
+	Score     float64 `json:"score"`      // cosine similarity, 0 to 1
+	Path      string  `json:"path"`       // relative to the data folder
+	URL       string  `json:"url,omitempty"`
+	Form      int     `json:"form"`
+	Type      string  `json:"type,omitempty"` // annotated form type; empty if not annotated
+	Duplicate bool    `json:"near_duplicate,omitempty"`
+}
+
+// formIndex is a TF-IDF index of annotated forms for similarity search.
+type formIndex struct {
+	tfidf   *vectorizer.TfidfVectorizer
+	vectors []vectorizer.SparseVector // L2-normalized
+	forms   []similarForm             // location and label of each vector
+}
+
+func (c *CLI) newDataSimilarCommand() *cobra.Command {
+	var (
+		dataFolder string
+		top        int
+		formIdx    int
+		format     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "similar <file.html>",
+		Short: "Find the annotated forms most similar to the forms of a page",
+		Long: `Index the annotated forms by TF-IDF over their input names, types, ids,
+classes, labels, and text, and list the training forms nearest to each
+form of the given page, with their form types. Use it to find precedents
+before labeling a form, and to spot near-duplicates (similarity of at
+least 0.95) that add little to the training data.`,
+		Example: `  dit data similar page.html
+  dit data similar page.html --form 0 --top 10
+  dit data similar page.html --format jsonl`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "jsonl" {
+				return fmt.Errorf("unknown format %q (want text or jsonl)", format)
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			doc, err := htmlutil.LoadHTMLString(string(data))
+			if err != nil {
+				return err
+			}
+			forms := htmlutil.GetForms(doc)
+			if formIdx >= len(forms) {
+				return fmt.Errorf("%s has %d forms, no form %d", args[0], len(forms), formIdx)
+			}
+			index, err := buildFormIndex(filepath.Join(dataFolder, "forms"))
+			if err != nil {
+				return err
+			}
+
+			var results []similarForm
+			for i, form := range forms {
+				if formIdx < 0 || i == formIdx {
+					results = append(results, index.nearest(i, form, top)...)
+				}
+			}
+			return writeSimilarForms(os.Stdout, format, results)
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder with a forms/ subfolder")
+	cmd.Flags().IntVar(&top, "top", 5, "Number of similar forms to list per form")
+	cmd.Flags().IntVar(&formIdx, "form", -1, "Only search for this form of the page (-1 searches all)")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or jsonl")
+	return cmd
+}
+
+// buildFormIndex indexes every form of the pages annotated in dir.
+func buildFormIndex(dir string) (*formIndex, error) {
+	store := storage.NewStorage(dir)
+	index, err := store.GetIndex()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no form annotations in %s", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load form index: %w", err)
+	}
+	// Without a config.json, labels are shown as they are.
+	schema, _ := store.GetFormSchema()
+
+	var docs []string
+	ix := &formIndex{}
+	for _, filename := range slices.Sorted(maps.Keys(index)) {
+		entry := index[filename]
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			slog.Warn("Cannot read page", "path", filename, "error", err)
+			continue
+		}
+		doc, err := htmlutil.LoadHTMLString(string(data))
+		if err != nil {
+			continue
+		}
+		for i, form := range htmlutil.GetForms(doc) {
+			sf := similarForm{Path: filepath.Join("forms", filename), URL: entry.URL, Form: i}
+			if i < len(entry.Forms) && (schema == nil || entry.Forms[i] != schema.NAValue) {
+				sf.Type = fullType(schema, entry.Forms[i])
+			}
+			ix.forms = append(ix.forms, sf)
+			docs = append(docs, formDocument(form))
+		}
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no annotated forms in %s", dir)
+	}
+	ix.tfidf = vectorizer.NewTfidfVectorizer([2]int{1, 2}, 1, false, "word", nil)
+	ix.vectors = ix.tfidf.FitTransform(docs)
+	return ix, nil
+}
+
+// formDocument is the text a form is indexed by: its input types, names,
+// ids and classes, labels, titles, submit texts, and visible text.
+func formDocument(form *goquery.Selection) string {
+	var types []string
+	for tp, n := range htmlutil.GetTypeCounts(form) {
+		for range n {
+			types = append(types, "type_"+tp)
+		}
+	}
+	slices.Sort(types)
+	return strings.Join([]string{
+		strings.Join(types, " "),
+		htmlutil.GetInputNames(form),
+		htmlutil.GetInputCSS(form),
+		htmlutil.GetFormCSS(form),
+		htmlutil.GetLabelText(form),
+		htmlutil.GetInputTitles(form),
+		htmlutil.GetSubmitTexts(form),
+		htmlutil.GetAllFormText(form),
+	}, " ")
+}
+
+// nearest returns the top indexed forms most similar to form, the query's
+// form number query, best first; forms sharing no term are left out.
+func (ix *formIndex) nearest(query int, form *goquery.Selection, top int) []similarForm {
+	q := ix.tfidf.Transform(formDocument(form))
+	weights := make(map[int]float64, len(q.Indices))
+	for k, idx := range q.Indices {
+		weights[idx] = q.Values[k]
+	}
+
+	var out []similarForm
+	for i, v := range ix.vectors {
+		score := 0.0
+		for k, idx := range v.Indices {
+			score += weights[idx] * v.Values[k]
+		}
+		if score <= 0 {
+			continue
+		}
+		sf := ix.forms[i]
+		sf.Query, sf.Score, sf.Duplicate = query, score, score >= nearDuplicate
+		out = append(out, sf)
+	}
+	slices.SortStableFunc(out, func(a, b similarForm) int { return cmp.Compare(b.Score, a.Score) })
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
+
+// writeSimilarForms prints results grouped by query form as text or, for
+// format "jsonl", as one JSON object per line.
+func writeSimilarForms(w io.Writer, format string, results []similarForm) error {
+	if format == "jsonl" {
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No similar annotated forms found.")
+		return nil
+	}
+	query := -1
+	for _, r := range results {
+		if r.Query != query {
+			query = r.Query
+			fmt.Fprintf(w, "Form %d:\n", query)
+		}
+		label := cmp.Or(r.Type, "(not annotated)")
+		fmt.Fprintf(w, "  %.3f  %-20s %s form %d", r.Score, label, r.Path, r.Form)
+		if r.URL != "" {
+			fmt.Fprintf(w, "  %s", r.URL)
+		}
+		if r.Duplicate {
+			fmt.Fprint(w, "  [near-duplicate]")
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
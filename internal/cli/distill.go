@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newDistillPageCommand() *cobra.Command {
+	var dataFolder string
+	var output string
+	var maxRules int
+	var minSupport int
+	var cvFolds int
+
+	cmd := &cobra.Command{
+		Use:   "distill-page <modelfile>",
+		Short: "Distill the page type model into a small rule list",
+		Long: `Distill the page type model into an interpretable decision list over
+its most telling features, for environments that cannot ship the full
+model. The accuracy given up is measured on held-out annotated pages.`,
+		Args: cobra.ExactArgs(1),
+		Example: `  dit distill-page model.json --data-folder data -o page-rules.json
+  dit distill-page model.json --max-rules 10`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cl, err := dit.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			slog.Info("Distilling page model", "folds", cvFolds, "data-folder", dataFolder)
+			result, err := cl.DistillPageRules(dataFolder, &dit.DistillConfig{
+				MaxRules:   maxRules,
+				MinSupport: minSupport,
+				Folds:      cvFolds,
+				Logger:     slog.Default(),
+			})
+			if err != nil {
+				return err
+			}
+
+			for i, rule := range result.Rules.Rules {
+				fmt.Printf("%2d. if %s has %q then %-20s (support %d, precision %.3f)\n",
+					i+1, rule.Pipeline, rule.Feature, rule.Class, rule.Support, rule.Precision)
+			}
+			fmt.Printf("    else %s\n\n", result.Rules.Default)
+			fmt.Printf("Pages:          %d\n", result.Pages)
+			fmt.Printf("Model accuracy: %.3f\n", result.ModelAccuracy)
+			fmt.Printf("Rules accuracy: %.3f (drop %.3f)\n", result.RulesAccuracy, result.AccuracyDrop())
+			fmt.Printf("Fidelity:       %.3f\n", result.Fidelity)
+
+			if output == "" {
+				return nil
+			}
+			data, err := json.MarshalIndent(result.Rules, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(output, data, 0644); err != nil {
+				return err
+			}
+			slog.Info("Rules saved", "path", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write the rules as JSON to this file")
+	cmd.Flags().IntVar(&maxRules, "max-rules", 20, "Maximum number of rules")
+	cmd.Flags().IntVar(&minSupport, "min-support", 3, "Minimum pages a rule must cover")
+	cmd.Flags().IntVar(&cvFolds, "cv", 5, "Number of cross-validation folds for the accuracy estimate")
+	return cmd
+}
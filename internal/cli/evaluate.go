@@ -1,8 +1,10 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"sort"
 	"time"
 
@@ -13,22 +15,42 @@ import (
 func (c *CLI) newEvaluateCommand() *cobra.Command {
 	var dataFolder string
 	var cvFolds int
+	var endToEnd bool
+	var fieldTemplates bool
+	var outputPath string
 
 	cmd := &cobra.Command{
-		Use:     "evaluate",
-		Short:   "Evaluate model accuracy via cross-validation",
-		Example: `  dit evaluate --data-folder data --cv 10`,
+		Use:   "evaluate",
+		Short: "Evaluate model accuracy via cross-validation",
+		Example: `  dit evaluate --data-folder data --cv 10
+  dit evaluate --end-to-end
+  dit evaluate --end-to-end --field-templates
+  dit evaluate --output eval.json   # for dit report --format html --evaluation`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			slog.Info("Evaluating", "folds", cvFolds, "data-folder", dataFolder)
 			start := time.Now()
+			var opts []dit.Option
+			if fieldTemplates {
+				opts = append(opts, dit.WithFieldTemplates(0))
+			}
 			result, err := dit.Evaluate(dataFolder, &dit.EvalConfig{
-				Folds:   cvFolds,
-				Verbose: c.verbose,
+				Folds:    cvFolds,
+				Verbose:  c.verbose,
+				EndToEnd: endToEnd,
+				Options:  opts,
+				Logger:   slog.Default(),
 			})
 			if err != nil {
 				return err
 			}
 			slog.Debug("Evaluation completed", "duration", time.Since(start))
+			if outputPath != "" {
+				data, _ := json.MarshalIndent(result, "", "  ")
+				if err := os.WriteFile(outputPath, data, 0644); err != nil {
+					return fmt.Errorf("write evaluation: %w", err)
+				}
+				slog.Info("Evaluation saved", "path", outputPath)
+			}
 
 			if result.FormTotal > 0 {
 				fmt.Printf("Form type accuracy: %.1f%% (%d/%d)\n",
@@ -40,6 +62,18 @@ func (c *CLI) newEvaluateCommand() *cobra.Command {
 				fmt.Printf("Sequence accuracy: %.1f%% (%d/%d forms)\n",
 					result.SequenceAccuracy*100, result.SequenceCorrect, result.SequenceTotal)
 			}
+			if e2e := result.EndToEnd; e2e != nil {
+				fmt.Printf("End-to-end form type accuracy: %.1f%% (%d/%d)\n",
+					e2e.FormAccuracy*100, e2e.FormCorrect, e2e.FormTotal)
+				fmt.Printf("End-to-end field type accuracy: %.1f%% (%d/%d fields)\n",
+					e2e.FieldAccuracy*100, e2e.FieldCorrect, e2e.FieldTotal)
+				fmt.Printf("End-to-end sequence accuracy: %.1f%% (%d/%d forms)\n",
+					e2e.SequenceAccuracy*100, e2e.SequenceCorrect, e2e.SequenceTotal)
+				if e2e.PageTotal > 0 {
+					fmt.Printf("End-to-end page type accuracy: %.1f%% (%d/%d)\n",
+						e2e.PageAccuracy*100, e2e.PageCorrect, e2e.PageTotal)
+				}
+			}
 			if result.PageTotal > 0 {
 				fmt.Printf("Page type accuracy: %.1f%% (%d/%d)\n",
 					result.PageAccuracy*100, result.PageCorrect, result.PageTotal)
@@ -54,6 +88,9 @@ func (c *CLI) newEvaluateCommand() *cobra.Command {
 
 	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder")
 	cmd.Flags().IntVar(&cvFolds, "cv", 10, "Number of cross-validation folds")
+	cmd.Flags().BoolVar(&endToEnd, "end-to-end", false, "Also evaluate the full pipeline: fields under predicted form types, pages under predicted forms")
+	cmd.Flags().BoolVar(&fieldTemplates, "field-templates", false, "Snap near-miss field sequences to canonical templates in the end-to-end evaluation, as dit run --field-templates does")
+	cmd.Flags().StringVar(&outputPath, "output", "", "Also save the results as JSON to this file")
 	return cmd
 }
 
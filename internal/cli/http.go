@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/happyhackingspace/dit/internal/fetch"
+)
+
+// newHTTPClient returns the client dit fetches pages with: HTTP/2 and
+// compressed responses as in fetch.NewTransport, at most 5 redirects, and
+// timeout (0 means none) bounding each request. insecure skips TLS
+// verification, which collect uses so that training crawls keep pages from
+// sites with broken certificates.
+func newHTTPClient(timeout time.Duration, insecure bool) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: fetch.NewTransport(insecure),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/happyhackingspace/dit"
+)
+
+// jsonlRequest is one line of `dit run --stdin-jsonl` input. Exactly one of
+// HTML and URL is expected; unset options fall back to the command flags.
+type jsonlRequest struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	HTML      string          `json:"html,omitempty"`
+	URL       string          `json:"url,omitempty"`
+	Proba     *bool           `json:"proba,omitempty"`
+	Threshold *float64        `json:"threshold,omitempty"`
+	Precision *int            `json:"precision,omitempty"`
+}
+
+// jsonlResponse is one line of output. Index is the position of the
+// request among the non-blank input lines, so that callers running with
+// several jobs can restore request order. Result holds what `dit run`
+// would print for the page; Error is set instead on failure.
+type jsonlResponse struct {
+	Index  int             `json:"index"`
+	ID     json.RawMessage `json:"id,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// jsonlOptions holds the command-line defaults for JSONL requests.
+type jsonlOptions struct {
+	proba     bool
+	threshold float64
+	precision dit.Precision
+	labels    dit.Labels
+	fetch     fetchOptions
+	jobs      int // requests answered concurrently; responses then come in completion order
+}
+
+// runStdinJSONL loads the model once and answers JSONL requests on stdin.
+func runStdinJSONL(modelPath, labelsLocale string, opts jsonlOptions, modelOpts ...dit.Option) error {
+	if labelsLocale != "" {
+		labels, err := dit.LoadLabels(labelsLocale)
+		if err != nil {
+			return err
+		}
+		opts.labels = labels
+	}
+	cl, err := loadOrDownloadModel(modelPath, modelOpts...)
+	if err != nil {
+		return err
+	}
+	slog.Debug("Serving JSONL requests on stdin")
+	return runJSONL(cl, os.Stdin, os.Stdout, opts)
+}
+
+// runJSONL serves line-delimited JSON requests from r until EOF, writing one
+// response line to w per non-empty request line as soon as it is answered.
+// Up to opts.jobs requests run at once, so memory stays bounded however
+// long the input is. A bad request produces an error response and does
+// not stop the loop.
+func runJSONL(cl *dit.Classifier, r io.Reader, w io.Writer, opts jsonlOptions) error {
+	reader := bufio.NewReader(r)
+	out := &jsonlWriter{enc: json.NewEncoder(w)}
+	out.enc.SetEscapeHTML(false)
+
+	// A request holds its slot until its response is written, so with one
+	// job responses keep request order.
+	slots := make(chan struct{}, max(opts.jobs, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	next := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			slots <- struct{}{}
+			if err := out.error(); err != nil {
+				return err
+			}
+			index := next
+			next++
+			wg.Go(func() {
+				defer func() { <-slots }()
+				resp := handleJSONLRequest(cl, line, opts)
+				resp.Index = index
+				out.write(resp)
+			})
+		}
+		if errors.Is(err, io.EOF) {
+			wg.Wait()
+			return out.error()
+		}
+		if err != nil {
+			return fmt.Errorf("read request: %w", err)
+		}
+	}
+}
+
+// jsonlWriter serializes response lines from concurrent requests and
+// keeps the first write error.
+type jsonlWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func (w *jsonlWriter) write(resp jsonlResponse) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		if err := w.enc.Encode(resp); err != nil {
+			w.err = fmt.Errorf("write response: %w", err)
+		}
+	}
+}
+
+func (w *jsonlWriter) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// handleJSONLRequest answers one non-blank request line.
+func handleJSONLRequest(cl *dit.Classifier, line []byte, opts jsonlOptions) jsonlResponse {
+	var req jsonlRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return jsonlResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+	resp := jsonlResponse{ID: req.ID}
+
+	html := req.HTML
+	switch {
+	case html != "" && req.URL != "":
+		resp.Error = "request has both html and url"
+		return resp
+	case req.URL != "":
+		if !isURL(req.URL) {
+			resp.Error = fmt.Sprintf("url must start with http:// or https://: %q", req.URL)
+			return resp
+		}
+		fetched, err := fetchHTML(req.URL, opts.fetch)
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		html = fetched
+	case html == "":
+		resp.Error = "request has neither html nor url"
+		return resp
+	}
+
+	proba := opts.proba
+	if req.Proba != nil {
+		proba = *req.Proba
+	}
+	threshold := opts.threshold
+	if req.Threshold != nil {
+		threshold = *req.Threshold
+	}
+
+	precision := opts.precision
+	if req.Precision != nil {
+		precision = dit.Precision(*req.Precision)
+	}
+
+	result, _, err := classifyHTML(cl, html, req.URL, proba, threshold, opts.labels, precision)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	if req.URL != "" {
+		resolveActions(result, req.URL)
+	}
+	slog.Debug("JSONL request classified", "id", string(req.ID))
+	resp.Result = result
+	return resp
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"log/slog"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newMigrateModelCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate-model <modelfile>",
+		Short: "Upgrade a model file to the current format in place",
+		Long: `Upgrade a model saved by an older dit version to the current model
+format, rewriting the file in place. No retraining is needed; models
+already in the current format are left untouched.`,
+		Args:    cobra.ExactArgs(1),
+		Example: `  dit migrate-model model.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modelPath := args[0]
+			from, err := dit.MigrateModel(modelPath)
+			if err != nil {
+				return err
+			}
+			if from == dit.ModelFormat {
+				slog.Info("Model already in current format", "path", modelPath, "format", from)
+				return nil
+			}
+			slog.Info("Model migrated", "path", modelPath, "from", from, "to", dit.ModelFormat)
+			return nil
+		},
+	}
+}
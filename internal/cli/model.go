@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newModelCommand() *cobra.Command {
+	modelCmd := &cobra.Command{
+		Use:   "model",
+		Short: "Convert models from other tools and combine models",
+		Run: func(cmd *cobra.Command, args []string) {
+			_ = cmd.Help()
+		},
+	}
+	modelCmd.AddCommand(c.newModelImportCommand())
+	modelCmd.AddCommand(c.newModelEnsembleCommand())
+	return modelCmd
+}
+
+func (c *CLI) newModelImportCommand() *cobra.Command {
+	var from, output string
+
+	cmd := &cobra.Command{
+		Use:   "import <dump>",
+		Short: "Convert a model trained by another tool into a dit model",
+		Long: `Convert a trained model exported from another tool into dit's model
+format, so it can be used without retraining.
+
+  formasaurus  a Formasaurus FormFieldClassifier, exported to JSON with
+               python -m ditclient.formasaurus (scikit-learn form model and
+               python-crfsuite field model)
+
+The imported model has no page classifier.`,
+		Args: cobra.ExactArgs(1),
+		Example: `  python -m ditclient.formasaurus dump.json
+  dit model import --from formasaurus dump.json -o model.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from != "formasaurus" {
+				return fmt.Errorf("unsupported --from %q; supported: formasaurus", from)
+			}
+			cl, err := dit.ImportFormasaurus(args[0])
+			if err != nil {
+				return err
+			}
+			if err := cl.Save(output); err != nil {
+				return err
+			}
+			slog.Info("Model imported", "from", from, "path", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "formasaurus", "Tool the model was trained with (formasaurus)")
+	cmd.Flags().StringVarP(&output, "output", "o", "model.json", "Path to write the dit model to")
+	return cmd
+}
+
+func (c *CLI) newModelEnsembleCommand() *cobra.Command {
+	var (
+		weights []float64
+		output  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "ensemble <modelfile> <modelfile>...",
+		Short: "Combine the form models of several models by weighted voting",
+		Long: `Write a model whose form type probabilities are the weighted average of
+those of the given models, such as runs trained on different data or with
+--form-model gbt and logreg. The members are stored in the output model
+and may differ in classes and features. Field and page models come from
+the first model. Tune thresholds on the result like on any model.`,
+		Args: cobra.MinimumNArgs(2),
+		Example: `  dit model ensemble logreg.json gbt.json -o model.json
+  dit model ensemble a.json b.json c.json --weights 2,1,1 -o model.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if weights != nil && len(weights) != len(args) {
+				return fmt.Errorf("%d weights for %d models", len(weights), len(args))
+			}
+			members := make([]*dit.Classifier, len(args))
+			for i, path := range args {
+				m, err := dit.Load(path)
+				if err != nil {
+					return err
+				}
+				members[i] = m
+			}
+			cl, err := dit.Ensemble(members, weights)
+			if err != nil {
+				return err
+			}
+			if err := cl.Save(output); err != nil {
+				return err
+			}
+			slog.Info("Ensemble saved", "path", output, "members", len(members))
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64SliceVar(&weights, "weights", nil, "Weight of each model's vote, in argument order (default: equal)")
+	cmd.Flags().StringVarP(&output, "output", "o", "model.json", "Path to write the combined model to")
+	return cmd
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/report"
+	"github.com/happyhackingspace/dit/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newReportCommand() *cobra.Command {
+	var dbPath string
+	var format string
+	var evalPath string
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Query classification results recorded with --db",
+		Long: `Query the SQLite results database written by "dit run --db" and
+"dit serve --db". Summaries and page lists use the latest classification of
+each URL; history and changes span every recorded scan.
+
+"dit report --format html" renders everything into one static HTML page:
+type summaries, per-site findings, classification changes, and, with
+--evaluation, metrics and the confusion matrix saved by "dit evaluate
+--output".`,
+		Example: `  dit report summary --db results.db
+  dit report pages --type login --db results.db
+  dit report changes --db results.db --format json
+  dit report pages --db results.db --format csv > pages.csv
+  dit report history https://example.com/login --db results.db
+  dit report --format html --db results.db --evaluation eval.json -o report.html`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "html" {
+				return cmd.Help()
+			}
+			db, err := openResults(dbPath)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = db.Close() }()
+
+			var eval *dit.EvalResult
+			if evalPath != "" {
+				data, err := os.ReadFile(evalPath)
+				if err != nil {
+					return fmt.Errorf("read evaluation: %w", err)
+				}
+				if err := json.Unmarshal(data, &eval); err != nil {
+					return fmt.Errorf("parse evaluation %s: %w", evalPath, err)
+				}
+			}
+
+			data, err := report.Build(db, dbPath, eval)
+			if err != nil {
+				return err
+			}
+			if outputPath == "" {
+				return data.WriteHTML(os.Stdout)
+			}
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := data.WriteHTML(f); err != nil {
+				_ = f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			slog.Info("Report written", "path", outputPath)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVar(&dbPath, "db", "results.db", "Path to the results database")
+	cmd.PersistentFlags().StringVar(&format, "format", "text", "Output format: text, json, csv (pages and history), or html for the full report")
+	cmd.Flags().StringVar(&evalPath, "evaluation", "", "Include evaluation results saved by dit evaluate --output (html format)")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "Write the HTML report to this file instead of stdout")
+
+	// withStore opens the database for a subcommand and prints its result.
+	withStore := func(query func(*store.Store, []string) (any, func(*tabwriter.Writer), error)) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			if format == "html" {
+				return fmt.Errorf("html renders the full report: dit report --format html")
+			}
+			if format != "json" && format != "text" && format != "csv" {
+				return fmt.Errorf("unknown format %q (want json, text, or csv)", format)
+			}
+			db, err := openResults(dbPath)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = db.Close() }()
+
+			result, printText, err := query(db, args)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "json":
+				output, _ := json.MarshalIndent(result, "", "  ")
+				fmt.Println(string(output))
+				return nil
+			case "csv":
+				records, ok := result.([]store.Record)
+				if !ok {
+					return fmt.Errorf("csv format is available for pages and history")
+				}
+				cw, err := newCSVWriter(os.Stdout)
+				if err != nil {
+					return err
+				}
+				for _, r := range records {
+					if err := cw.WriteResult(r.URL, &dit.PageResult{Type: r.PageType, Forms: r.Forms}); err != nil {
+						return err
+					}
+				}
+				return cw.Flush()
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			printText(w)
+			return w.Flush()
+		}
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "summary",
+		Short: "Count page and form types",
+		Args:  cobra.NoArgs,
+		RunE: withStore(func(db *store.Store, _ []string) (any, func(*tabwriter.Writer), error) {
+			pages, forms, err := db.Summary()
+			result := map[string][]store.TypeCount{"page_types": pages, "form_types": forms}
+			return result, func(w *tabwriter.Writer) {
+				printCounts(w, "PAGE TYPE", pages)
+				_, _ = fmt.Fprintln(w)
+				printCounts(w, "FORM TYPE", forms)
+			}, err
+		}),
+	})
+
+	var pageType string
+	pagesCmd := &cobra.Command{
+		Use:   "pages",
+		Short: "List URLs, optionally those with a page or form type",
+		Args:  cobra.NoArgs,
+		RunE: withStore(func(db *store.Store, _ []string) (any, func(*tabwriter.Writer), error) {
+			records, err := db.Pages(pageType)
+			return records, func(w *tabwriter.Writer) { printRecords(w, records) }, err
+		}),
+	}
+	pagesCmd.Flags().StringVar(&pageType, "type", "", "Only URLs with this page type or form type")
+	cmd.AddCommand(pagesCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "changes",
+		Short: "List page type changes between scans",
+		Args:  cobra.NoArgs,
+		RunE: withStore(func(db *store.Store, _ []string) (any, func(*tabwriter.Writer), error) {
+			changes, err := db.Changes()
+			return changes, func(w *tabwriter.Writer) {
+				_, _ = fmt.Fprintln(w, "CHANGED AT\tURL\tFROM\tTO")
+				for _, ch := range changes {
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ch.ChangedAt.Format("2006-01-02 15:04"), ch.URL, ch.From, ch.To)
+				}
+			}, err
+		}),
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "history <url>",
+		Short: "Show every recorded classification of a URL",
+		Args:  cobra.ExactArgs(1),
+		RunE: withStore(func(db *store.Store, args []string) (any, func(*tabwriter.Writer), error) {
+			records, err := db.History(args[0])
+			return records, func(w *tabwriter.Writer) { printRecords(w, records) }, err
+		}),
+	})
+	return cmd
+}
+
+// openResults opens an existing results database.
+func openResults(path string) (*store.Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("results database: %w", err)
+	}
+	return store.Open(path)
+}
+
+func printCounts(w *tabwriter.Writer, title string, counts []store.TypeCount) {
+	_, _ = fmt.Fprintf(w, "%s\tURLS\n", title)
+	for _, c := range counts {
+		typ := c.Type
+		if typ == "" {
+			typ = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", typ, c.Count)
+	}
+}
+
+func printRecords(w *tabwriter.Writer, records []store.Record) {
+	_, _ = fmt.Fprintln(w, "SCANNED AT\tURL\tPAGE TYPE\tFORMS")
+	for _, r := range records {
+		forms := ""
+		for i, f := range r.Forms {
+			if i > 0 {
+				forms += ", "
+			}
+			forms += f.Type
+		}
+		if r.Error != "" {
+			forms = "error: " + r.Error
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ScannedAt.Format("2006-01-02 15:04"), r.URL, r.PageType, forms)
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -14,6 +15,8 @@ import (
 
 	"github.com/chromedp/chromedp"
 	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/scan"
+	"github.com/happyhackingspace/dit/internal/store"
 	"github.com/spf13/cobra"
 )
 
@@ -25,6 +28,19 @@ func (c *CLI) newRunCommand() *cobra.Command {
 	var proba bool
 	var render bool
 	var renderTimeout int
+	var labelsLocale string
+	var stdinJSONL bool
+	var jobs int
+	var formTimeout time.Duration
+	var locators bool
+	var fieldTemplates bool
+	var languageModels map[string]string
+	var dbPath string
+	var format string
+	var precision int
+	var explainPage bool
+	var noModel bool
+	var disagreementsPath string
 
 	cmd := &cobra.Command{
 		Use:   "run [url-or-file]",
@@ -51,9 +67,39 @@ func (c *CLI) newRunCommand() *cobra.Command {
   # Use custom probability threshold
   dit run https://github.com/login --proba --threshold 0.1
 
+  # Explain the page type in words instead of printing JSON
+  dit run https://example.com/missing --explain-page
+
+  # Round probabilities to 3 decimal places for diffable output
+  dit run https://github.com/login --proba --precision 3
+
+  # Localize output labels (built-in fr/de, or a JSON table file)
+  dit run https://github.com/login --labels-locale fr
+
+  # Serve line-delimited JSON requests on stdin (see README)
+  dit run --stdin-jsonl < requests.jsonl
+
+  # Classify a large target list 8 at a time, streaming results as they finish
+  dit run --stdin-jsonl --jobs 8 < targets.jsonl > results.jsonl
+
+  # Flatten results to CSV rows for spreadsheets
+  dit run https://github.com/login --format csv > login.csv
+
+  # Record the result in a SQLite database for "dit report"
+  dit run https://github.com/login --db results.db
+
   # Use custom model file
   dit run login.html --model custom.json
 
+  # Classify French pages with a model trained on French annotations
+  dit run https://example.fr/connexion --language-model fr=model-fr.json
+
+  # Classify offline without a model, by URL patterns and markup rules
+  dit run https://example.com/login --no-model
+
+  # Append where the model and the heuristic rules disagree to a review file
+  dit run https://example.com/login --disagreements review.jsonl
+
   # Render JavaScript-heavy pages
   dit run https://github.com/login --render
 
@@ -63,14 +109,52 @@ func (c *CLI) newRunCommand() *cobra.Command {
   # Verbose mode with debug output
   dit run https://github.com/login -v`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "csv" {
+				return fmt.Errorf("unknown format %q (want json or csv)", format)
+			}
+			if noModel && (stdinJSONL || proba || explainPage || modelPath != "") {
+				return fmt.Errorf("--no-model cannot be combined with --stdin-jsonl, --proba, --explain-page, or --model")
+			}
+			if disagreementsPath != "" && (noModel || stdinJSONL || explainPage) {
+				return fmt.Errorf("--disagreements needs a model and cannot be combined with --no-model, --stdin-jsonl, or --explain-page")
+			}
+			if explainPage && (stdinJSONL || format != "json" || dbPath != "") {
+				return fmt.Errorf("--explain-page prints text and cannot be combined with --stdin-jsonl, --format csv, or --db")
+			}
+
 			var htmlContent string
 			var target string
 			var err error
+			modelOpts := []dit.Option{dit.WithFormTimeout(formTimeout)}
+			if locators {
+				modelOpts = append(modelOpts, dit.WithLocators())
+			}
+			if fieldTemplates {
+				modelOpts = append(modelOpts, dit.WithFieldTemplates(0))
+			}
+			langOpts, err := languageModelOptions(languageModels)
+			if err != nil {
+				return err
+			}
+			modelOpts = append(modelOpts, langOpts...)
 			fetchOpts := fetchOptions{
 				render:  render,
 				timeout: time.Duration(renderTimeout) * time.Second,
 			}
 
+			if stdinJSONL {
+				if len(args) > 0 {
+					return fmt.Errorf("--stdin-jsonl reads requests from stdin and takes no arguments")
+				}
+				return runStdinJSONL(modelPath, labelsLocale, jsonlOptions{
+					proba:     proba,
+					threshold: threshold,
+					precision: dit.Precision(precision),
+					fetch:     fetchOpts,
+					jobs:      jobs,
+				}, modelOpts...)
+			}
+
 			if len(args) == 0 {
 				if isStdinTerminal() {
 					return cmd.Help()
@@ -92,65 +176,298 @@ func (c *CLI) newRunCommand() *cobra.Command {
 			}
 			slog.Debug("HTML fetched", "target", target, "bytes", len(htmlContent))
 
+			var labels dit.Labels
+			if labelsLocale != "" {
+				labels, err = dit.LoadLabels(labelsLocale)
+				if err != nil {
+					return err
+				}
+			}
+
+			pageURL := ""
+			if isURL(target) {
+				pageURL = target
+			}
+			if noModel {
+				return runHeuristic(target, htmlContent, pageURL, format, dbPath, labels)
+			}
+
 			start := time.Now()
-			cl, err := loadOrDownloadModel(modelPath)
+			cl, err := loadOrDownloadModel(modelPath, modelOpts...)
 			if err != nil {
-				return err
+				// Only a missing model degrades to the heuristics; an
+				// explicit --model, output only a model can give, and a
+				// model that exists but fails to load are errors.
+				if modelPath != "" || proba || explainPage || !errors.Is(err, dit.ErrModelNotFound) {
+					return err
+				}
+				slog.Warn("No model found, falling back to heuristic classification", "reason", err)
+				if disagreementsPath != "" {
+					slog.Warn("Skipping --disagreements, which needs a model")
+				}
+				return runHeuristic(target, htmlContent, pageURL, format, dbPath, labels)
 			}
 			slog.Debug("Model loaded", "duration", time.Since(start))
 
+			if explainPage {
+				exp, err := cl.ExplainPage(htmlContent, pageURL)
+				if err != nil {
+					return err
+				}
+				writePageExplanation(os.Stdout, exp)
+				return nil
+			}
+
 			start = time.Now()
-			if proba {
-				pageResult, pageErr := cl.ExtractPageTypeProba(htmlContent, threshold)
-				if pageErr == nil {
-					slog.Debug("Page+form classification completed", "duration", time.Since(start))
-					output, _ := json.MarshalIndent(pageResult, "", "  ")
-					fmt.Println(string(output))
-				} else {
-					results, err := cl.ExtractFormsProba(htmlContent, threshold)
-					if err != nil {
-						return err
-					}
-					slog.Debug("Form classification completed", "forms", len(results), "duration", time.Since(start))
-					if len(results) == 0 {
-						fmt.Println("No forms found.")
-						return nil
-					}
-					output, _ := json.MarshalIndent(results, "", "  ")
-					fmt.Println(string(output))
+			result, noForms, err := classifyHTML(cl, htmlContent, pageURL, proba, threshold, labels, dit.Precision(precision))
+			if err != nil {
+				return err
+			}
+			slog.Debug("Classification completed", "duration", time.Since(start))
+			if disagreementsPath != "" {
+				disagreements, err := cl.Disagreements(htmlContent, pageURL)
+				if err != nil {
+					return err
 				}
-			} else {
-				pageResult, pageErr := cl.ExtractPageType(htmlContent)
-				if pageErr == nil {
-					slog.Debug("Page+form classification completed", "duration", time.Since(start))
-					output, _ := json.MarshalIndent(pageResult, "", "  ")
-					fmt.Println(string(output))
-				} else {
-					results, err := cl.ExtractForms(htmlContent)
-					if err != nil {
-						return err
-					}
-					slog.Debug("Form classification completed", "forms", len(results), "duration", time.Since(start))
-					if len(results) == 0 {
-						fmt.Println("No forms found.")
-						return nil
-					}
-					output, _ := json.MarshalIndent(results, "", "  ")
-					fmt.Println(string(output))
+				if err := appendDisagreements(disagreementsPath, target, disagreements); err != nil {
+					return err
 				}
 			}
-			return nil
+			if pageURL != "" {
+				resolveActions(result, pageURL)
+			}
+			if dbPath != "" {
+				if err := recordResult(dbPath, target, pageURL, cl, htmlContent, result, proba || labels != nil); err != nil {
+					return err
+				}
+			}
+			if noForms && format != "csv" {
+				fmt.Println("No forms found.")
+				return nil
+			}
+			return writeRunResult(target, format, result)
 		},
 	}
 
 	cmd.Flags().StringVar(&modelPath, "model", "", "Path to model file (default: auto-detect or download)")
 	cmd.Flags().Float64Var(&threshold, "threshold", 0.05, "Minimum probability threshold")
 	cmd.Flags().BoolVar(&proba, "proba", false, "Show probabilities")
+	cmd.Flags().IntVar(&precision, "precision", -1, "Round probabilities to this many decimal places (-1 keeps full precision)")
 	cmd.Flags().BoolVar(&render, "render", false, "Render JavaScript-driven pages in a headless browser")
 	cmd.Flags().IntVar(&renderTimeout, "timeout", 30, "Render browser timeout in seconds")
+	cmd.Flags().BoolVar(&stdinJSONL, "stdin-jsonl", false, "Answer line-delimited JSON requests from stdin until EOF")
+	cmd.Flags().IntVar(&jobs, "jobs", 1, "With --stdin-jsonl, answer this many requests at once; responses then stream in completion order")
+	cmd.Flags().DurationVar(&formTimeout, "form-timeout", 0, "Give up on a form after this long and report it with an error (0 means no limit)")
+	cmd.Flags().BoolVar(&locators, "locators", false, "Add a CSS selector, XPath, and form/field index to each field")
+	cmd.Flags().BoolVar(&fieldTemplates, "field-templates", false, "Snap near-miss field sequences (e.g. username, password, remember me) to canonical templates")
+	cmd.Flags().StringToStringVar(&languageModels, "language-model", nil, "Classify pages in a language with their own model, as lang=path (e.g. fr=model-fr.json; repeatable)")
+	cmd.Flags().StringVar(&format, "format", "json", "Output format: json or csv")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Record the result in this SQLite database (see dit report)")
+	cmd.Flags().StringVar(&labelsLocale, "labels-locale", "", "Localize output labels: built-in locale (fr, de) or path to a JSON label table")
+	cmd.Flags().BoolVar(&noModel, "no-model", false, "Classify with built-in URL and markup rules instead of a model (less accurate; also used when no model can be found)")
+	cmd.Flags().StringVar(&disagreementsPath, "disagreements", "", "Append where the model and the heuristic rules type the page, forms, or fields differently to this JSONL review file")
+	cmd.Flags().BoolVar(&explainPage, "explain-page", false, "Print the features behind the page type in words instead of the JSON result")
 	return cmd
 }
 
+// runHeuristic is dit run for --no-model and when no model can be
+// found: it classifies html with dit.Heuristic, records the result in the
+// database at dbPath, if any, and prints it localized.
+func runHeuristic(target, html, pageURL, format, dbPath string, labels dit.Labels) error {
+	result, err := dit.Heuristic(html, pageURL)
+	if err != nil {
+		return err
+	}
+	if pageURL != "" {
+		resolveActions(result, pageURL)
+	}
+	if dbPath != "" {
+		if err := recordResult(dbPath, target, pageURL, nil, html, result, false); err != nil {
+			return err
+		}
+	}
+	if labels != nil {
+		result = labels.Page(result)
+	}
+	return writeRunResult(target, format, result)
+}
+
+// appendDisagreements appends the disagreements between the model and the
+// heuristic rules on target to the review file at path, one JSON object
+// per line with the target's URL or file name.
+func appendDisagreements(path, target string, disagreements []dit.Disagreement) error {
+	if len(disagreements) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, d := range disagreements {
+		if err := enc.Encode(struct {
+			URL string `json:"url"`
+			dit.Disagreement
+		}{target, d}); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// writeRunResult prints a dit run result as indented JSON or, for format
+// "csv", as CSV rows.
+func writeRunResult(target, format string, result any) error {
+	if format == "csv" {
+		cw, err := newCSVWriter(os.Stdout)
+		if err != nil {
+			return err
+		}
+		if err := cw.WriteResult(target, result); err != nil {
+			return err
+		}
+		return cw.Flush()
+	}
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	return nil
+}
+
+// writePageExplanation prints the page type with the features that argued
+// for and against it, in words, followed by the runner-up types.
+func writePageExplanation(w io.Writer, exp *dit.PageExplanation) {
+	if len(exp.Classes) == 0 {
+		return
+	}
+	top := exp.Classes[0]
+	fmt.Fprintf(w, "Page type: %s (%.1f%%)\n", top.Type, top.Probability*100)
+	for _, section := range []struct {
+		title    string
+		features []dit.FeatureContribution
+	}{{"For", top.Positive}, {"Against", top.Negative}} {
+		if len(section.features) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", section.title)
+		for _, f := range section.features {
+			fmt.Fprintf(w, "  %+.2f  %s\n", f.Contribution, f.Reason)
+		}
+	}
+	if len(exp.Classes) > 1 {
+		fmt.Fprintln(w, "\nRunners-up:")
+		for _, e := range exp.Classes[1:min(4, len(exp.Classes))] {
+			fmt.Fprintf(w, "  %s (%.1f%%)\n", e.Type, e.Probability*100)
+		}
+	}
+}
+
+// classifyHTML classifies a page fetched from pageURL ("" if unknown) as
+// `dit run` reports it: the page type with its forms, or just the forms if
+// the model has no page classifier. noForms is set for a forms-only result
+// without forms. Probabilities are rounded to precision.
+func classifyHTML(cl *dit.Classifier, html, pageURL string, proba bool, threshold float64, labels dit.Labels, precision dit.Precision) (result any, noForms bool, err error) {
+	if proba {
+		pageResult, err := cl.ExtractPageTypeProbaURL(html, pageURL, threshold)
+		if err == nil {
+			pageResult = precision.PageProba(pageResult)
+			if labels != nil {
+				pageResult = labels.PageProba(pageResult)
+			}
+			return pageResult, false, nil
+		}
+		if !errors.Is(err, dit.ErrNoPageModel) {
+			return nil, false, err
+		}
+		results, err := cl.ExtractFormsProba(html, threshold)
+		if err != nil {
+			return nil, false, err
+		}
+		results = precision.FormsProba(results)
+		if labels != nil {
+			results = labels.FormsProba(results)
+		}
+		return results, len(results) == 0, nil
+	}
+
+	pageResult, err := cl.ExtractPageTypeURL(html, pageURL)
+	if err == nil {
+		if labels != nil {
+			pageResult = labels.Page(pageResult)
+		}
+		return pageResult, false, nil
+	}
+	if !errors.Is(err, dit.ErrNoPageModel) {
+		return nil, false, err
+	}
+	results, err := cl.ExtractForms(html)
+	if err != nil {
+		return nil, false, err
+	}
+	if labels != nil {
+		results = labels.Forms(results)
+	}
+	return results, len(results) == 0, nil
+}
+
+// resolveActions makes the form actions in a classifyHTML result absolute
+// against the URL the page was fetched from.
+func resolveActions(result any, pageURL string) {
+	var infos []*dit.FormInfo
+	switch r := result.(type) {
+	case *dit.PageResult:
+		for i := range r.Forms {
+			infos = append(infos, &r.Forms[i].FormInfo)
+		}
+	case *dit.PageResultProba:
+		for i := range r.Forms {
+			infos = append(infos, &r.Forms[i].FormInfo)
+		}
+	case []dit.FormResult:
+		for i := range r {
+			infos = append(infos, &r[i].FormInfo)
+		}
+	case []dit.FormResultProba:
+		for i := range r {
+			infos = append(infos, &r[i].FormInfo)
+		}
+	}
+	for _, info := range infos {
+		if err := info.ResolveAction(pageURL); err != nil {
+			slog.Debug("Cannot resolve form action", "action", info.Action, "error", err)
+		}
+	}
+}
+
+// recordResult stores the classification of target, fetched from pageURL
+// ("" if unknown), in the results database. Probability and localized
+// results are reclassified, since the database holds plain labels.
+func recordResult(dbPath, target, pageURL string, cl *dit.Classifier, html string, result any, reclassify bool) error {
+	rec := store.Record{URL: target}
+	switch r := result.(type) {
+	case *dit.PageResult:
+		rec.PageType, rec.Forms = r.Type, r.Forms
+	case []dit.FormResult:
+		rec.Forms = r
+	}
+	if reclassify {
+		var err error
+		if rec.PageType, rec.Forms, err = scan.Classify(cl, html, pageURL); err != nil {
+			return err
+		}
+	}
+
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	if err := db.Add(rec); err != nil {
+		_ = db.Close()
+		return err
+	}
+	return db.Close()
+}
+
 func isStdinTerminal() bool {
 	fi, err := os.Stdin.Stat()
 	if err != nil {
@@ -159,49 +476,84 @@ func isStdinTerminal() bool {
 	return fi.Mode()&os.ModeCharDevice != 0
 }
 
-func loadOrDownloadModel(modelPath string) (*dit.Classifier, error) {
+// languageModelOptions loads the --language-model files, given as lang=path
+// pairs, for dit.WithLanguageModel.
+func languageModelOptions(models map[string]string) ([]dit.Option, error) {
+	var opts []dit.Option
+	for lang, path := range models {
+		m, err := dit.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("load %s language model: %w", lang, err)
+		}
+		opts = append(opts, dit.WithLanguageModel(lang, m))
+	}
+	return opts, nil
+}
+
+func loadOrDownloadModel(modelPath string, opts ...dit.Option) (*dit.Classifier, error) {
 	if modelPath != "" {
 		slog.Debug("Loading custom model", "path", modelPath)
-		return dit.Load(modelPath)
+		return dit.Load(modelPath, opts...)
 	}
 
-	cl, err := dit.New()
+	// Prefer the full model, on disk or downloaded, to the embedded
+	// compact one, which only stands in when offline.
+	path, err := dit.FindModel("model.json")
 	if err == nil {
-		return cl, nil
+		return dit.Load(path, opts...)
+	}
+	if !errors.Is(err, dit.ErrModelNotFound) {
+		return nil, err
 	}
 
 	dest := filepath.Join(dit.ModelDir(), "model.json")
+	if err := downloadModel(dest); err != nil {
+		cl, embeddedErr := dit.LoadEmbedded(opts...)
+		if errors.Is(embeddedErr, dit.ErrModelNotFound) {
+			return nil, fmt.Errorf("%w: %w", dit.ErrModelNotFound, err)
+		}
+		if embeddedErr != nil {
+			return nil, embeddedErr
+		}
+		slog.Warn("Model download failed, using the embedded compact model", "error", err)
+		return cl, nil
+	}
+	return dit.Load(dest, opts...)
+}
+
+// downloadModel downloads the full model to dest.
+func downloadModel(dest string) error {
 	slog.Info("Model not found, downloading", "url", modelURL, "dest", dest)
 
 	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
-		return nil, fmt.Errorf("create model dir: %w", err)
+		return fmt.Errorf("create model dir: %w", err)
 	}
 
 	resp, err := http.Get(modelURL)
 	if err != nil {
-		return nil, fmt.Errorf("download model: %w", err)
+		return fmt.Errorf("download model: %w", &dit.FetchError{URL: modelURL, Err: err})
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("download model: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("download model: %w", &dit.FetchError{URL: modelURL, StatusCode: resp.StatusCode})
 	}
 
 	f, err := os.Create(dest)
 	if err != nil {
-		return nil, fmt.Errorf("create model file: %w", err)
+		return fmt.Errorf("create model file: %w", err)
 	}
 
 	written, err := io.Copy(f, resp.Body)
 	if err != nil {
 		_ = f.Close()
 		_ = os.Remove(dest)
-		return nil, fmt.Errorf("download model: %w", err)
+		return fmt.Errorf("download model: %w", err)
 	}
 	_ = f.Close()
 
 	slog.Info("Model downloaded", "size", fmt.Sprintf("%.1fMB", float64(written)/1024/1024))
-	return dit.Load(dest)
+	return nil
 }
 
 type fetchOptions struct {
@@ -227,14 +579,14 @@ func fetchHTML(target string, opts fetchOptions) (string, error) {
 }
 
 func fetchHTMLPlain(target string) (string, error) {
-	resp, err := http.Get(target)
+	resp, err := newHTTPClient(0, false).Get(target)
 	if err != nil {
-		return "", fmt.Errorf("fetch URL: %w", err)
+		return "", &dit.FetchError{URL: target, Err: err}
 	}
 	defer func() { _ = resp.Body.Close() }()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+		return "", &dit.FetchError{URL: target, StatusCode: resp.StatusCode, Err: err}
 	}
 	return string(body), nil
 }
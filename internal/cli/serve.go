@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/server"
+	"github.com/happyhackingspace/dit/internal/store"
+	"github.com/happyhackingspace/dit/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newServeCommand() *cobra.Command {
+	var modelPath string
+	var watchModel time.Duration
+	var addr string
+	var maxConcurrent int
+	var maxBatch int
+	var shutdownTimeout time.Duration
+	var apiKeys []string
+	var apiKeysFile string
+	var rateLimit float64
+	var rateBurst int
+	var maxBodyBytes int64
+	var truncateBody bool
+	var allowPrivateURLs bool
+	var maxJobs int
+	var scanMaxPages int
+	var scanDelay time.Duration
+	var formTimeout time.Duration
+	var locators bool
+	var fieldTemplates bool
+	var languageModels map[string]string
+	var webhookURL string
+	var webhookSecret string
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve classification over HTTP with health and readiness probes",
+		Long: `Serve classification over HTTP. POST /classify takes an HTML body, or a
+JSON body with either {"html": "..."} or {"url": "..."} to fetch the page
+first; POST /classify/batch takes a JSON array of those (at most
+--max-batch) and answers with one result per page, in order.
+GET /healthz and GET /readyz (model loaded) serve as liveness and readiness
+probes. SIGTERM or SIGINT drains in-flight requests before exiting.
+
+With --api-key or --api-keys-file, /classify requires one of the keys as
+"Authorization: Bearer <key>" or "X-API-Key: <key>". --rate-limit caps
+requests per second per key (per client IP without keys).
+
+Bodies over --max-body-bytes are refused with 413 (or cut to the limit with
+--truncate-body), and non-HTML payloads such as PDF or JSON with 415.
+
+POST /scan with {"url": "..."} crawls and classifies a site in the
+background and returns a job ID; GET /jobs/{id} reports progress and the
+pages scanned so far. With --webhook-url, scans POST events (login page
+found, soft 404 detected, classification changed) to that URL, signed with
+--webhook-secret. With --db, every scanned page is recorded in a SQLite
+database for "dit report", and classification changes are detected
+against it across restarts rather than only for recent scans in memory.
+
+With --watch-model, the --model file is reloaded whenever it changes, so a
+retrained model takes over without a restart.`,
+		Example: `  dit serve --addr :8080
+  dit serve --model model.json --max-concurrent 4
+  dit serve --model model.json --watch-model 10s
+  dit serve --api-keys-file keys.txt --rate-limit 5 --rate-burst 20
+  curl -s -H 'Content-Type: text/html' --data-binary @login.html localhost:8080/classify
+  curl -s -H 'Content-Type: application/json' -d '{"url": "https://example.com/login"}' localhost:8080/classify
+  curl -s -d '[{"id": 1, "url": "https://example.com/login"}, {"id": 2, "url": "https://example.com/signup"}]' localhost:8080/classify/batch
+  curl -s -d '{"url": "https://example.com"}' localhost:8080/scan`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if watchModel > 0 && modelPath == "" {
+				return fmt.Errorf("--watch-model needs --model")
+			}
+			keys, err := loadAPIKeys(apiKeys, apiKeysFile)
+			if err != nil {
+				return err
+			}
+
+			var db *store.Store
+			if dbPath != "" {
+				if db, err = store.Open(dbPath); err != nil {
+					return err
+				}
+				defer func() { _ = db.Close() }()
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			srv := server.New(server.Config{
+				Addr:             addr,
+				MaxConcurrent:    maxConcurrent,
+				MaxBatch:         maxBatch,
+				AllowPrivateURLs: allowPrivateURLs,
+				ShutdownTimeout:  shutdownTimeout,
+				APIKeys:          keys,
+				RateLimit:        rateLimit,
+				RateBurst:        rateBurst,
+				MaxBodyBytes:     maxBodyBytes,
+				TruncateBody:     truncateBody,
+				MaxJobs:          maxJobs,
+				ScanMaxPages:     scanMaxPages,
+				ScanDelay:        scanDelay,
+				Webhook:          webhook.Config{URL: webhookURL, Secret: webhookSecret},
+				Store:            db,
+			})
+
+			// Load the model while already answering probes, so /healthz
+			// passes and /readyz fails until the model is in memory.
+			ctx, cancel := context.WithCancelCause(ctx)
+			defer cancel(nil)
+			go func() {
+				start := time.Now()
+				opts := []dit.Option{dit.WithFormTimeout(formTimeout)}
+				if locators {
+					opts = append(opts, dit.WithLocators())
+				}
+				if fieldTemplates {
+					opts = append(opts, dit.WithFieldTemplates(0))
+				}
+				langOpts, err := languageModelOptions(languageModels)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				cl, err := loadOrDownloadModel(modelPath, append(opts, langOpts...)...)
+				if err != nil {
+					cancel(err)
+					return
+				}
+				slog.Info("Model loaded", "duration", time.Since(start))
+				srv.SetClassifier(cl)
+				if watchModel > 0 {
+					cl.WatchModel(ctx, modelPath, watchModel, func(err error) {
+						if err != nil {
+							slog.Warn("Model reload failed, keeping the previous model", "path", modelPath, "error", err)
+							return
+						}
+						slog.Info("Model reloaded", "path", modelPath)
+					})
+				}
+			}()
+
+			if err := srv.ListenAndServe(ctx); err != nil {
+				return err
+			}
+			if err := context.Cause(ctx); err != nil && err != context.Canceled {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&modelPath, "model", "", "Path to model file (default: auto-detect or download)")
+	cmd.Flags().DurationVar(&watchModel, "watch-model", 0, "Reload --model when it changes, checking at this interval (0 disables)")
+	cmd.Flags().StringVar(&addr, "addr", ":8080", "Address to listen on")
+	cmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 0, "Maximum simultaneous classifications (0 uses all CPUs)")
+	cmd.Flags().IntVar(&maxBatch, "max-batch", 100, "Maximum pages per /classify/batch request")
+	cmd.Flags().BoolVar(&allowPrivateURLs, "allow-private-urls", false, "Let clients have loopback, private, and link-local URLs fetched (trusted networks only)")
+	cmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Time allowed for in-flight requests on shutdown")
+	cmd.Flags().DurationVar(&formTimeout, "form-timeout", 0, "Give up on a form after this long and report it with an error (0 means no limit)")
+	cmd.Flags().BoolVar(&locators, "locators", false, "Add a CSS selector, XPath, and form/field index to each field")
+	cmd.Flags().BoolVar(&fieldTemplates, "field-templates", false, "Snap near-miss field sequences (e.g. username, password, remember me) to canonical templates")
+	cmd.Flags().StringToStringVar(&languageModels, "language-model", nil, "Classify pages in a language with their own model, as lang=path (e.g. fr=model-fr.json; repeatable)")
+	cmd.Flags().StringArrayVar(&apiKeys, "api-key", nil, "API key required on /classify (repeatable)")
+	cmd.Flags().StringVar(&apiKeysFile, "api-keys-file", "", "File with one API key per line")
+	cmd.Flags().Float64Var(&rateLimit, "rate-limit", 0, "Requests per second per API key or client IP (0 disables)")
+	cmd.Flags().IntVar(&rateBurst, "rate-burst", 0, "Burst size for --rate-limit (default: one second's worth)")
+	cmd.Flags().Int64Var(&maxBodyBytes, "max-body-bytes", 10<<20, "Maximum request body size in bytes")
+	cmd.Flags().BoolVar(&truncateBody, "truncate-body", false, "Classify the first --max-body-bytes of larger bodies instead of refusing them")
+	cmd.Flags().IntVar(&maxJobs, "max-jobs", 4, "Maximum concurrently running /scan jobs")
+	cmd.Flags().IntVar(&scanMaxPages, "scan-max-pages", 50, "Maximum pages fetched per /scan job")
+	cmd.Flags().DurationVar(&scanDelay, "scan-delay", 500*time.Millisecond, "Delay between page fetches within a /scan job")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", "", "URL receiving scan events as JSON POSTs")
+	cmd.Flags().StringVar(&webhookSecret, "webhook-secret", "", "Secret for the HMAC-SHA256 X-Dit-Signature header on webhook events")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Record scanned pages in this SQLite database (see dit report)")
+	return cmd
+}
+
+// loadAPIKeys merges keys from flags and a keys file, skipping blank lines
+// and # comments in the file.
+func loadAPIKeys(keys []string, path string) ([]string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read API keys: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				keys = append(keys, line)
+			}
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no API keys in %s", path)
+		}
+	}
+	return keys, nil
+}
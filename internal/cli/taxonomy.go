@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newTaxonomyCommand() *cobra.Command {
+	var dataFolder string
+	var modelPath string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "taxonomy",
+		Short: "Print form, field, and page types with short codes and simplify maps",
+		Long: `Print the form, field, and page type taxonomy of the data config, or
+with --model the labels a trained model can output.`,
+		Example: `  dit taxonomy --format json
+  dit taxonomy --data-folder data
+  dit taxonomy --model model.json --format json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "json" && format != "text" {
+				return fmt.Errorf("unknown format %q (want json or text)", format)
+			}
+
+			var taxonomy *dit.Taxonomy
+			var err error
+			if modelPath != "" {
+				var cl *dit.Classifier
+				cl, err = dit.Load(modelPath)
+				if err != nil {
+					return err
+				}
+				taxonomy, err = cl.Taxonomy()
+			} else {
+				taxonomy, err = dit.LoadTaxonomy(dataFolder)
+			}
+			if err != nil {
+				return err
+			}
+
+			if format == "json" {
+				output, _ := json.MarshalIndent(taxonomy, "", "  ")
+				fmt.Println(string(output))
+				return nil
+			}
+			printTypeSet("Form types", taxonomy.FormTypes)
+			printTypeSet("Field types", taxonomy.FieldTypes)
+			printTypeSet("Page types", taxonomy.PageTypes)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder")
+	cmd.Flags().StringVar(&modelPath, "model", "", "Read labels from a model file instead of the data config")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	return cmd
+}
+
+func printTypeSet(title string, ts *dit.TypeSet) {
+	if ts == nil {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, t := range ts.Types {
+		if t.Short != "" {
+			fmt.Printf("  %-6s %s\n", t.Short, t.Name)
+		} else {
+			fmt.Printf("  %s\n", t.Name)
+		}
+	}
+	if len(ts.SimplifyMap) > 0 {
+		fmt.Printf("  simplified:\n")
+		codes := make([]string, 0, len(ts.SimplifyMap))
+		for code := range ts.SimplifyMap {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Printf("    %s -> %s\n", code, ts.SimplifyMap[code])
+		}
+	}
+	fmt.Println()
+}
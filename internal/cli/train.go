@@ -1,27 +1,82 @@
 package cli
 
 import (
+	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/classifier"
 	"github.com/spf13/cobra"
 )
 
 func (c *CLI) newTrainCommand() *cobra.Command {
 	var dataFolder string
+	var scalePipelines bool
+	var workers int
+	var vocabFrom string
+	var calibration string
+	var calibrationFolds int
+	var formModel string
+	var labelSmoothing, trimFraction float64
+	var gbt classifier.GBTConfig
+	var selfTrain string
+	var selfTrainRounds int
+	var selfTrainThreshold float64
 
 	cmd := &cobra.Command{
 		Use:   "train <modelfile>",
 		Short: "Train a model on annotated HTML forms",
 		Args:  cobra.ExactArgs(1),
 		Example: `  dit train model.json --data-folder data
+  dit train model.json --scale-pipelines
+  dit train new-model.json --vocab-from model.json
+  dit train model.json --calibration platt
+  dit train model.json --form-model gbt
+  dit train model.json --form-model gbt --gbt-rounds 200 --gbt-balance-classes
+  dit train model.json --label-smoothing 0.1 --trim 0.05
+  dit train model.json --self-train unlabeled-pages/
   dit train model.json -v`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			modelPath := args[0]
 			slog.Info("Training classifier", "data-folder", dataFolder, "output", modelPath)
+			var vocab *dit.Classifier
+			if vocabFrom != "" {
+				var err error
+				if vocab, err = dit.Load(vocabFrom); err != nil {
+					return err
+				}
+				slog.Info("Freezing vocabulary", "from", vocabFrom)
+			}
 			start := time.Now()
-			cl, err := dit.Train(dataFolder, &dit.TrainConfig{Verbose: c.verbose})
+			config := dit.TrainConfig{
+				Verbose:          c.verbose,
+				Logger:           slog.Default(),
+				ScalePipelines:   scalePipelines,
+				Workers:          workers,
+				VocabFrom:        vocab,
+				Calibration:      calibration,
+				CalibrationFolds: calibrationFolds,
+				FormModel:        formModel,
+				GBT:              gbt,
+				LabelSmoothing:   labelSmoothing,
+				TrimFraction:     trimFraction,
+			}
+			var cl *dit.Classifier
+			var err error
+			if selfTrain != "" {
+				var rounds []dit.SelfTrainRound
+				cl, rounds, err = dit.SelfTrain(dataFolder, selfTrain, &dit.SelfTrainConfig{
+					TrainConfig: config,
+					Rounds:      selfTrainRounds,
+					Threshold:   selfTrainThreshold,
+				})
+				if err == nil {
+					printSelfTrainRounds(rounds)
+				}
+			} else {
+				cl, err = dit.Train(dataFolder, &config)
+			}
 			if err != nil {
 				return err
 			}
@@ -35,5 +90,35 @@ func (c *CLI) newTrainCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder")
+	cmd.Flags().BoolVar(&scalePipelines, "scale-pipelines", false, "Learn per-pipeline scale factors for the form type model")
+	cmd.Flags().IntVar(&workers, "workers", 0, "Parallel workers for parsing and feature extraction (0 uses all CPUs)")
+	cmd.Flags().StringVar(&vocabFrom, "vocab-from", "", "Reuse the vocabulary of this model and retrain only the weights")
+	cmd.Flags().StringVar(&calibration, "calibration", "", "Calibrate form and page type probabilities on held-out predictions: platt or isotonic")
+	cmd.Flags().IntVar(&calibrationFolds, "calibration-folds", 5, "Cross-validation folds for --calibration")
+	cmd.Flags().StringVar(&formModel, "form-model", dit.FormModelLogReg, "Form type learner: logreg or gbt (gradient boosted trees)")
+	cmd.Flags().IntVar(&gbt.Rounds, "gbt-rounds", 0, "Boosting rounds for --form-model gbt (0 means 100)")
+	cmd.Flags().IntVar(&gbt.MaxDepth, "gbt-max-depth", 0, "Tree depth for --form-model gbt (0 means 4)")
+	cmd.Flags().Float64Var(&gbt.LearningRate, "gbt-learning-rate", 0, "Learning rate for --form-model gbt (0 means 0.1)")
+	cmd.Flags().Float64Var(&gbt.Lambda, "gbt-lambda", 0, "L2 penalty on leaf values for --form-model gbt (0 means 1)")
+	cmd.Flags().Float64Var(&gbt.MinChildWeight, "gbt-min-child-weight", 0, "Least hessian sum on either side of a split for --form-model gbt (0 means 1)")
+	cmd.Flags().BoolVar(&gbt.BalanceClasses, "gbt-balance-classes", false, "Weight forms inversely to their type's frequency for --form-model gbt")
+	cmd.Flags().Float64Var(&labelSmoothing, "label-smoothing", 0, "Spread this share of each label's probability over all classes, for noisy (e.g. auto-labeled) data")
+	cmd.Flags().Float64Var(&trimFraction, "trim", 0, "Leave out this share of highest-loss examples at each training iteration, for noisy data")
+	cmd.Flags().StringVar(&selfTrain, "self-train", "", "Folder of unlabeled HTML pages to pseudo-label confidently typed forms and pages from, retraining each round")
+	cmd.Flags().IntVar(&selfTrainRounds, "self-train-rounds", 3, "Pseudo-labeling rounds for --self-train")
+	cmd.Flags().Float64Var(&selfTrainThreshold, "self-train-threshold", 0.95, "Least probability a form or page type needs to become a pseudo-label")
 	return cmd
 }
+
+// printSelfTrainRounds prints the held-out accuracy of every self-training
+// round.
+func printSelfTrainRounds(rounds []dit.SelfTrainRound) {
+	fmt.Printf("%-6s %12s %12s %14s %14s\n", "Round", "Pseudo forms", "Pseudo pages", "Form accuracy", "Page accuracy")
+	for _, r := range rounds {
+		page := "-"
+		if r.HeldOutPages > 0 {
+			page = fmt.Sprintf("%.1f%%", r.PageAccuracy*100)
+		}
+		fmt.Printf("%-6d %12d %12d %13.1f%% %14s\n", r.Round, r.PseudoForms, r.PseudoPages, r.FormAccuracy*100, page)
+	}
+}
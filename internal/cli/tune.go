@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/spf13/cobra"
+)
+
+func (c *CLI) newTuneThresholdsCommand() *cobra.Command {
+	var dataFolder string
+	var cvFolds int
+	var minPrecision float64
+
+	cmd := &cobra.Command{
+		Use:   "tune-thresholds <modelfile>",
+		Short: "Tune per-class form type decision thresholds",
+		Long: `Tune per-class form type decision thresholds on cross-validated
+predictions and store them in the model. By default each threshold
+maximizes F1; with --precision it maximizes recall at that precision.`,
+		Args: cobra.ExactArgs(1),
+		Example: `  dit tune-thresholds model.json --data-folder data
+  dit tune-thresholds model.json --precision 0.95`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			modelPath := args[0]
+			cl, err := dit.Load(modelPath)
+			if err != nil {
+				return err
+			}
+
+			slog.Info("Tuning thresholds", "folds", cvFolds, "data-folder", dataFolder)
+			thresholds, err := cl.TuneThresholds(dataFolder, &dit.TuneConfig{
+				Folds:        cvFolds,
+				MinPrecision: minPrecision,
+				Verbose:      c.verbose,
+				Logger:       slog.Default(),
+			})
+			if err != nil {
+				return err
+			}
+
+			classes := make([]string, 0, len(thresholds))
+			for cls := range thresholds {
+				classes = append(classes, cls)
+			}
+			sort.Strings(classes)
+			for _, cls := range classes {
+				fmt.Printf("%-30s %.3f\n", cls, thresholds[cls])
+			}
+
+			if err := cl.Save(modelPath); err != nil {
+				return err
+			}
+			slog.Info("Model saved", "path", modelPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dataFolder, "data-folder", "data", "Path to annotation data folder")
+	cmd.Flags().IntVar(&cvFolds, "cv", 10, "Number of cross-validation folds")
+	cmd.Flags().Float64Var(&minPrecision, "precision", 0, "Target precision per class (0 maximizes F1)")
+	return cmd
+}
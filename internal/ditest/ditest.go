@@ -0,0 +1,47 @@
+// Package ditest provides a small trained classifier for the tests of
+// packages built on dit.
+package ditest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/happyhackingspace/dit"
+)
+
+// Classifier trains a tiny model on synthetic login and search forms.
+func Classifier(t testing.TB) *dit.Classifier {
+	t.Helper()
+	dir := t.TempDir()
+	forms := filepath.Join(dir, "forms")
+	if err := os.MkdirAll(forms, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [{"full": "username", "short": "username"}, {"full": "password", "short": "password"}, {"full": "search query", "short": "search query"}], "NA_value": "XX", "skip_value": "--"}
+		}`,
+		"index.json": `{
+			"a.html": {"url": "http://a.example.org/", "forms": ["l"], "visible_html_fields": [{"user": "username", "pass": "password"}]},
+			"b.html": {"url": "http://b.example.org/", "forms": ["l"], "visible_html_fields": [{"login": "username", "pwd": "password"}]},
+			"c.html": {"url": "http://c.example.org/", "forms": ["s"], "visible_html_fields": [{"q": "search query"}]},
+			"d.html": {"url": "http://d.example.org/", "forms": ["s"], "visible_html_fields": [{"query": "search query"}]}
+		}`,
+		"a.html": `<form><input type="text" name="user"/><input type="password" name="pass"/></form>`,
+		"b.html": `<form><input type="text" name="login"/><input type="password" name="pwd"/></form>`,
+		"c.html": `<form><input type="search" name="q"/></form>`,
+		"d.html": `<form><input type="text" name="query"/></form>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(forms, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cl, err := dit.Train(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cl
+}
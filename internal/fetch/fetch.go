@@ -0,0 +1,202 @@
+// Package fetch provides the HTTP transport dit fetches pages with: HTTP/2
+// where the server offers it, and compressed responses decoded whatever
+// the server sends.
+package fetch
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// AcceptEncoding lists the content codings the transport can decode.
+// Zstd is left out: neither the standard library nor dit decodes it.
+const AcceptEncoding = "gzip, deflate, br"
+
+// MaxBodyBytes bounds a decoded response body, so that a small compressed
+// response cannot expand without limit.
+const MaxBodyBytes = 64 << 20
+
+// NewTransport returns a transport that negotiates HTTP/2 over TLS, asks
+// for AcceptEncoding unless the request sets Accept-Encoding itself, and
+// decodes gzip, deflate, and Brotli bodies, also when sent unasked, up to
+// MaxBodyBytes. Responses in an encoding it cannot decode, such as zstd,
+// fail instead of yielding compressed bytes as the page. insecure skips
+// TLS certificate verification.
+func NewTransport(insecure bool) http.RoundTripper {
+	return newTransport(insecure, http.DefaultTransport.(*http.Transport).Clone())
+}
+
+// ErrNonPublicAddress is returned by the transport of NewPublicTransport
+// for a connection to an address that is not on the public internet.
+var ErrNonPublicAddress = errors.New("fetch: refusing to connect to a non-public address")
+
+// NewPublicTransport is NewTransport for fetching URLs chosen by untrusted
+// clients: it only connects to public IP addresses, checked after DNS
+// resolution and for every redirect, so that a server fetching URLs on
+// request cannot be pointed at loopback, private, link-local (cloud
+// metadata), or other internal addresses. Proxies from the environment
+// are not used, since the check would apply to the proxy instead.
+func NewPublicTransport() http.RoundTripper {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: publicOnly}
+	base.DialContext = dialer.DialContext
+	return newTransport(false, base)
+}
+
+// nonPublicPrefixes are the ranges, besides those netip.Addr reports as
+// private, loopback, link-local, or not global unicast, that
+// publicOnly refuses.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 of any IPv4 address
+}
+
+// publicOnly is a net.Dialer Control function refusing connections to
+// non-public addresses.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, ip)
+	}
+	return nil
+}
+
+// isPublicAddr reports whether ip is a public internet address.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newTransport wraps base as NewTransport describes.
+func newTransport(insecure bool, base *http.Transport) http.RoundTripper {
+	// A custom TLS config turns HTTP/2 off unless it is forced.
+	base.ForceAttemptHTTP2 = true
+	if insecure {
+		base.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	// Decoding here rather than in net/http also covers deflate and
+	// encodings the server sends without being asked.
+	base.DisableCompression = true
+	return &decodingTransport{base: base, maxBytes: MaxBodyBytes}
+}
+
+type decodingTransport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+func (t *decodingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Accept-Encoding", AcceptEncoding)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return resp, nil
+	case "gzip", "x-gzip", "deflate", "br":
+	default:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("fetch: unsupported Content-Encoding %q", encoding)
+	}
+	resp.Body = &decodedBody{raw: resp.Body, encoding: encoding, limit: t.maxBytes, remaining: t.maxBytes}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decodedBody decodes raw on first Read, so that bodiless responses such
+// as HEAD requests and 304s never need a valid stream.
+type decodedBody struct {
+	raw       io.ReadCloser
+	encoding  string
+	limit     int64
+	remaining int64
+	r         io.Reader
+	err       error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = newDecoder(b.raw, b.encoding)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.remaining <= 0 {
+		// A body of exactly the limit is fine; only more is not.
+		if n, err := b.r.Read(make([]byte, 1)); n == 0 {
+			return 0, err
+		}
+		return 0, fmt.Errorf("fetch: decoded body exceeds %d bytes", b.limit)
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	return b.raw.Close()
+}
+
+// newDecoder returns a reader decoding r. Deflate should be zlib-wrapped,
+// but many servers send raw deflate, so the zlib header is checked for.
+func newDecoder(r io.Reader, encoding string) (io.Reader, error) {
+	switch encoding {
+	case "br":
+		return brotli.NewReader(r), nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	}
+	br := bufio.NewReader(r)
+	head, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,171 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+const page = "<html><body><form><input name=q></form></body></html>"
+
+// brotliPage is page compressed by the reference Brotli encoder.
+var brotliPage = []byte{
+	0x1b, 0x34, 0x00, 0xf0, 0x8c, 0x94, 0xa9, 0xeb, 0x33, 0x42, 0xa5, 0x27, 0x0f, 0x5a, 0x5c, 0xfb,
+	0xb0, 0xb5, 0xd1, 0x69, 0x45, 0x2a, 0xa1, 0x45, 0x18, 0xe1, 0xd2, 0x45, 0x4d, 0x54, 0x71, 0xa2,
+	0x93, 0x16, 0x9c, 0x3f, 0x38, 0x82, 0x61, 0x01,
+}
+
+func compress(t *testing.T, encoding string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+	if _, err := io.WriteString(w, page); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTransportDecodes(t *testing.T) {
+	bodies := map[string]struct {
+		encoding string
+		body     []byte
+	}{
+		"/plain":       {"", []byte(page)},
+		"/gzip":        {"gzip", compress(t, "gzip")},
+		"/deflate":     {"deflate", compress(t, "zlib")},
+		"/raw-deflate": {"deflate", compress(t, "flate")},
+		"/br":          {"br", brotliPage},
+		"/zstd":        {"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept-Encoding"); got != AcceptEncoding {
+			t.Errorf("Accept-Encoding = %q, want %q", got, AcceptEncoding)
+		}
+		b := bodies[r.URL.Path]
+		if b.encoding != "" {
+			w.Header().Set("Content-Encoding", b.encoding)
+		}
+		_, _ = w.Write(b.body)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(false)}
+	for _, path := range []string{"/plain", "/gzip", "/deflate", "/raw-deflate", "/br"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if string(body) != page {
+			t.Errorf("%s body = %q, want the page", path, body)
+		}
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%s still has Content-Encoding %q", path, resp.Header.Get("Content-Encoding"))
+		}
+	}
+
+	if _, err := client.Get(srv.URL + "/zstd"); err == nil || !strings.Contains(err.Error(), `unsupported Content-Encoding "zstd"`) {
+		t.Errorf("zstd response error = %v, want unsupported Content-Encoding", err)
+	}
+}
+
+func TestTransportBodyLimit(t *testing.T) {
+	gzipped := compress(t, "gzip")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipped)
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		limit   int64
+		wantErr bool
+	}{
+		{int64(len(page)), false},
+		{int64(len(page)) - 1, true},
+	} {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+		base.DisableCompression = true
+		client := &http.Client{Transport: &decodingTransport{base: base, maxBytes: tt.limit}}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("limit %d: read error = %v, want error %v", tt.limit, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransportHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, page)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	resp, err := (&http.Client{Transport: NewTransport(true)}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("negotiated %s, want HTTP/2", resp.Proto)
+	}
+}
+
+func TestPublicTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, page)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewPublicTransport()}
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("GET of a loopback server: err = %v, want ErrNonPublicAddress", err)
+	}
+
+	for addr, want := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false, // cloud metadata
+		"100.64.0.1":      false,
+		"::1":             false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+		"0.0.0.0":         false,
+	} {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
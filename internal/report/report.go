@@ -0,0 +1,154 @@
+// Package report renders recorded scan results and evaluation metrics as a
+// self-contained HTML page for sharing.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/store"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+}).Parse(reportTemplate))
+
+// Data is the content of a report.
+type Data struct {
+	Generated time.Time
+	Source    string // results database path
+	PageTypes []store.TypeCount
+	FormTypes []store.TypeCount
+	Sites     []Site
+	Changes   []store.Change
+	// Evaluation and Confusion are set when evaluation results are given.
+	Evaluation *dit.EvalResult
+	Confusion  *Matrix
+}
+
+// Site groups the latest records of one host.
+type Site struct {
+	Host     string
+	Pages    []store.Record
+	Logins   int // pages with a login page type or login form
+	Soft404s int
+	Errors   int
+}
+
+// Matrix is a confusion matrix laid out for display, classes ordered by
+// support.
+type Matrix struct {
+	Classes []string
+	Rows    []MatrixRow
+}
+
+// MatrixRow holds the predictions for one true class.
+type MatrixRow struct {
+	Class    string
+	Cells    []MatrixCell
+	Total    int
+	Accuracy float64
+}
+
+// MatrixCell is one count, with its share of the row for shading.
+type MatrixCell struct {
+	Count    int
+	Share    float64
+	Diagonal bool
+}
+
+// Build collects report data from the results database and, if eval is
+// not nil, evaluation metrics.
+func Build(db *store.Store, source string, eval *dit.EvalResult) (*Data, error) {
+	d := &Data{Generated: time.Now(), Source: source, Evaluation: eval}
+	var err error
+	if d.PageTypes, d.FormTypes, err = db.Summary(); err != nil {
+		return nil, err
+	}
+	if d.Changes, err = db.Changes(); err != nil {
+		return nil, err
+	}
+	records, err := db.Pages("")
+	if err != nil {
+		return nil, err
+	}
+	d.Sites = groupSites(records)
+	if eval != nil && len(eval.PageConfusion) > 0 {
+		d.Confusion = newMatrix(eval.PageConfusion, eval.PageClasses)
+	}
+	return d, nil
+}
+
+// WriteHTML renders the report.
+func (d *Data) WriteHTML(w io.Writer) error {
+	return tmpl.Execute(w, d)
+}
+
+// groupSites groups records by host, in order of first appearance (records
+// are sorted by URL, so hosts come out sorted by scheme and name).
+func groupSites(records []store.Record) []Site {
+	var sites []Site
+	index := make(map[string]int)
+	for _, r := range records {
+		host := r.URL
+		if u, err := url.Parse(r.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		i, ok := index[host]
+		if !ok {
+			i = len(sites)
+			index[host] = i
+			sites = append(sites, Site{Host: host})
+		}
+		site := &sites[i]
+		site.Pages = append(site.Pages, r)
+		switch {
+		case r.Error != "":
+			site.Errors++
+		case r.PageType == "soft_404":
+			site.Soft404s++
+		}
+		if r.PageType == "login" || slices.ContainsFunc(r.Forms, func(f dit.FormResult) bool { return f.Type == "login" }) {
+			site.Logins++
+		}
+	}
+	return sites
+}
+
+func newMatrix(confusion map[string]map[string]int, classes []string) *Matrix {
+	support := func(cls string) int {
+		n := 0
+		for _, v := range confusion[cls] {
+			n += v
+		}
+		return n
+	}
+	classes = slices.Clone(classes)
+	slices.SortStableFunc(classes, func(a, b string) int { return support(b) - support(a) })
+
+	m := &Matrix{Classes: classes}
+	for _, trueClass := range classes {
+		row := MatrixRow{Class: trueClass, Total: support(trueClass)}
+		for _, predClass := range classes {
+			cell := MatrixCell{Count: confusion[trueClass][predClass], Diagonal: trueClass == predClass}
+			if row.Total > 0 {
+				cell.Share = float64(cell.Count) / float64(row.Total)
+			}
+			if cell.Diagonal {
+				row.Accuracy = cell.Share
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
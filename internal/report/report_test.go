@@ -0,0 +1,71 @@
+package report
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/store"
+)
+
+func TestWriteHTML(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, r := range []store.Record{
+		{URL: "https://a.example/", ScannedAt: t0, PageType: "landing"},
+		{URL: "https://a.example/", ScannedAt: t0.Add(time.Hour), PageType: "soft_404"},
+		{URL: "https://a.example/login", ScannedAt: t0, PageType: "login", Forms: []dit.FormResult{
+			{Type: "login", FieldList: []dit.Field{{Name: "user", Type: "username"}}},
+		}},
+		{URL: "https://b.example/<script>", ScannedAt: t0, Error: "timeout"},
+	} {
+		if err := db.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eval := &dit.EvalResult{
+		PageAccuracy: 0.75, PageCorrect: 3, PageTotal: 4,
+		PageClasses:   []string{"login", "soft_404"},
+		PageConfusion: map[string]map[string]int{"login": {"login": 2}, "soft_404": {"soft_404": 1, "login": 1}},
+	}
+	data, err := Build(db, "results.db", eval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Sites) != 2 || data.Sites[0].Host != "a.example" || data.Sites[0].Logins != 1 ||
+		data.Sites[0].Soft404s != 1 || data.Sites[1].Errors != 1 {
+		t.Errorf("sites = %+v", data.Sites)
+	}
+	if row := data.Confusion.Rows[1]; row.Class != "soft_404" || row.Accuracy != 0.5 {
+		t.Errorf("confusion row = %+v", row)
+	}
+
+	var b strings.Builder
+	if err := data.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, want := range []string{
+		"<summary>a.example (2 pages)",
+		"user: username",
+		"<td>landing</td><td>soft_404</td>",
+		"75.0%",
+		"rgba(52, 168, 83, 0.50)",
+		"https://b.example/&lt;script&gt;",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+	if strings.Contains(html, "ZgotmplZ") {
+		t.Error("report contains sanitized template output")
+	}
+}
@@ -0,0 +1,233 @@
+// Package scan crawls a site and classifies the pages it visits.
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/fetch"
+)
+
+// Config controls a site scan.
+type Config struct {
+	// MaxPages bounds the number of pages fetched; 0 means 50.
+	MaxPages int
+	// Delay is the pause between requests.
+	Delay time.Duration
+	// UserAgent is sent with every request; empty uses a dit default.
+	UserAgent string
+	// Client fetches pages; nil uses a client with a 30 second timeout and
+	// a fetch.NewTransport.
+	Client *http.Client
+	// Slots, if set, bounds concurrent classifications: Site holds a slot
+	// in it while classifying each page, so scans share a server's limit.
+	Slots chan struct{}
+}
+
+// maxPageBytes caps how much of a page is read for classification.
+const maxPageBytes = 10 << 20
+
+// Page is the outcome of scanning one URL.
+type Page struct {
+	URL    string           `json:"url"`
+	Status int              `json:"status,omitempty"`
+	Type   string           `json:"type,omitempty"` // empty without a page model
+	Forms  []dit.FormResult `json:"forms,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// Site crawls same-host links breadth-first from start, classifying each
+// HTML page, and calls visit with every fetched page in crawl order. Fetch
+// and classification failures are reported on the page, not returned; Site
+// only fails for an invalid start URL or when ctx is cancelled.
+func Site(ctx context.Context, cl *dit.Classifier, start string, cfg Config, visit func(Page)) error {
+	startURL, err := parseURL(start)
+	if err != nil {
+		return fmt.Errorf("invalid start URL %q", start)
+	}
+	cfg = cfg.withDefaults()
+
+	normalize(startURL)
+	queue := []string{startURL.String()}
+	seen := map[string]bool{startURL.String(): true}
+	for fetched := 0; len(queue) > 0 && fetched < cfg.MaxPages; fetched++ {
+		if fetched > 0 && cfg.Delay > 0 {
+			select {
+			case <-time.After(cfg.Delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		target := queue[0]
+		queue = queue[1:]
+
+		page, html := fetchPage(ctx, cfg, target)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if html != "" {
+			if cfg.Slots != nil {
+				select {
+				case cfg.Slots <- struct{}{}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			page.Type, page.Forms, err = Classify(cl, html, target)
+			if cfg.Slots != nil {
+				<-cfg.Slots
+			}
+			if err != nil {
+				page.Error = err.Error()
+			}
+			for _, link := range links(html, startURL, target) {
+				if !seen[link] {
+					seen[link] = true
+					queue = append(queue, link)
+				}
+			}
+		}
+		visit(page)
+	}
+	return nil
+}
+
+// CheckURL reports whether rawURL is an absolute http or https URL, as
+// Site and Fetch require.
+func CheckURL(rawURL string) error {
+	_, err := parseURL(rawURL)
+	return err
+}
+
+// Fetch downloads the single page at target, as Site fetches each page,
+// and returns its page record, without a classification, and HTML body.
+// The body is empty if the fetch failed, which Page.Error then describes,
+// or the response is not HTML.
+func Fetch(ctx context.Context, cfg Config, target string) (Page, string) {
+	if _, err := parseURL(target); err != nil {
+		return Page{URL: target, Error: fmt.Sprintf("invalid URL %q", target)}, ""
+	}
+	return fetchPage(ctx, cfg.withDefaults(), target)
+}
+
+// withDefaults returns cfg with unset fields given their defaults.
+func (cfg Config) withDefaults() Config {
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 50
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "Mozilla/5.0 (compatible; dit-scan/1.0)"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 30 * time.Second, Transport: fetch.NewTransport(false)}
+	}
+	return cfg
+}
+
+// parseURL parses an absolute http or https URL.
+func parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("not an absolute http or https URL")
+	}
+	return u, nil
+}
+
+// fetchPage downloads target and returns its page record and HTML body; the
+// body is empty for failed requests and non-HTML responses.
+func fetchPage(ctx context.Context, cfg Config, target string) (Page, string) {
+	page := Page{URL: target}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		page.Error = err.Error()
+		return page, ""
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := cfg.Client.Do(req)
+	if err != nil {
+		page.Error = err.Error()
+		return page, ""
+	}
+	defer func() { _ = resp.Body.Close() }()
+	page.Status = resp.StatusCode
+
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ := mime.ParseMediaType(ct)
+		if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+			page.Error = "not HTML: " + mediaType
+			return page, ""
+		}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		page.Error = err.Error()
+		return page, ""
+	}
+	return page, string(body)
+}
+
+// Classify returns the page type and forms of html, fetched from pageURL,
+// as a scan records them. The page type is empty for models without a page
+// classifier.
+func Classify(cl *dit.Classifier, html, pageURL string) (pageType string, forms []dit.FormResult, err error) {
+	result, err := cl.ExtractPageTypeURL(html, pageURL)
+	if err == nil {
+		return result.Type, result.Forms, nil
+	}
+	if !errors.Is(err, dit.ErrNoPageModel) {
+		return "", nil, err
+	}
+	forms, err = cl.ExtractForms(html)
+	return "", forms, err
+}
+
+// links returns the absolute http(s) links in html that stay on the start
+// URL's host, without fragments, in document order. Links that would log
+// out, delete, or otherwise change state are left out.
+func links(html string, start *url.URL, pageURL string) []string {
+	doc, err := htmlutil.LoadHTMLString(html)
+	if err != nil {
+		return nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, href := range doc.Find("a[href]").EachIter() {
+		if htmlutil.IsDestructiveLink(href) {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(href.AttrOr("href", "")))
+		if err != nil {
+			continue
+		}
+		u := base.ResolveReference(ref)
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host != start.Host {
+			continue
+		}
+		normalize(u)
+		out = append(out, u.String())
+	}
+	return out
+}
+
+// normalize drops the fragment and spells an empty path as "/", so each
+// page is queued once.
+func normalize(u *url.URL) {
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+}
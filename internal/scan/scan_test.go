@@ -0,0 +1,109 @@
+package scan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/happyhackingspace/dit/internal/ditest"
+)
+
+// testSite serves a home page linking to a login page, a PDF, itself, a
+// logout link, and an external site.
+func testSite() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<a href="/login#top">Log in</a> <a href="/doc.pdf">Doc</a>
+			<a href="/">Home</a> <a href="/session/end">Sign out</a> <a href="http://other.example.org/">Other</a>`))
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`))
+	})
+	mux.HandleFunc("/doc.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("%PDF-1.7"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestSite(t *testing.T) {
+	cl := ditest.Classifier(t)
+	site := testSite()
+	defer site.Close()
+
+	var pages []Page
+	err := Site(context.Background(), cl, site.URL, Config{}, func(p Page) {
+		pages = append(pages, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{site.URL + "/", site.URL + "/login", site.URL + "/doc.pdf"}
+	if len(pages) != len(want) {
+		t.Fatalf("scanned %d pages, want %d: %+v", len(pages), len(want), pages)
+	}
+	for i, p := range pages {
+		if p.URL != want[i] {
+			t.Errorf("page %d URL = %q, want %q", i, p.URL, want[i])
+		}
+	}
+	if forms := pages[1].Forms; len(forms) != 1 || forms[0].Type != "login" {
+		t.Errorf("login page forms = %+v, want one login form", forms)
+	}
+	if pages[2].Error == "" {
+		t.Error("PDF page has no error")
+	}
+}
+
+func TestSiteMaxPagesAndCancel(t *testing.T) {
+	cl := ditest.Classifier(t)
+	site := testSite()
+	defer site.Close()
+
+	n := 0
+	if err := Site(context.Background(), cl, site.URL, Config{MaxPages: 1}, func(Page) { n++ }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("scanned %d pages with MaxPages 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Site(ctx, cl, site.URL, Config{}, func(Page) {}); err != context.Canceled {
+		t.Errorf("cancelled scan error = %v, want context.Canceled", err)
+	}
+
+	if err := Site(context.Background(), cl, "ftp://example.org", Config{}, func(Page) {}); err == nil {
+		t.Error("ftp start URL accepted")
+	}
+}
+
+func TestSiteSlots(t *testing.T) {
+	cl := ditest.Classifier(t)
+	site := testSite()
+	defer site.Close()
+
+	// With every slot taken, the scan waits to classify until cancelled.
+	slots := make(chan struct{}, 1)
+	slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n := 0
+	if err := Site(ctx, cl, site.URL, Config{Slots: slots}, func(Page) { n++ }); err != context.DeadlineExceeded || n != 0 {
+		t.Errorf("scan with no free slot: err = %v after %d pages, want context.DeadlineExceeded before any", err, n)
+	}
+
+	<-slots
+	if err := Site(context.Background(), cl, site.URL, Config{Slots: slots}, func(Page) { n++ }); err != nil || n != 3 {
+		t.Errorf("scan with a free slot: err = %v after %d pages, want 3", err, n)
+	}
+	if len(slots) != 0 {
+		t.Error("scan kept a slot")
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requireKey rejects requests without a configured API key, passed as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>". With no keys
+// configured every request is allowed.
+func (s *Server) requireKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.keys) > 0 && !s.validKey(requestKey(r)) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dit"`)
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid API key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimit applies the per-client token bucket. Clients are identified by
+// API key when keys are configured, by remote IP otherwise.
+func (s *Server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter != nil {
+			client := requestKey(r)
+			if len(s.keys) == 0 {
+				client = remoteIP(r)
+			}
+			if wait := s.limiter.reserve(client); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+// validKey compares key against the configured keys in constant time.
+func (s *Server) validKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(key))
+	valid := 0
+	for _, k := range s.keys {
+		valid |= subtle.ConstantTimeCompare(sum[:], k[:])
+	}
+	return valid == 1
+}
+
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limiter keeps one token bucket per client: rate tokens per second, up to
+// burst. Idle full buckets are dropped to bound memory.
+type limiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(rate)))
+	}
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// reserve takes a token for client and returns 0, or returns how long
+// until a token is available without taking one.
+func (l *limiter) reserve(client string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/scan"
+)
+
+// batchResult is one element of the POST /classify/batch response, at the
+// position of its request. Result holds what POST /classify would answer
+// for the page; Error is set instead on failure.
+type batchResult struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	URL    string          `json:"url,omitempty"`
+	Status int             `json:"status,omitempty"` // HTTP status of a fetched URL
+	Result any             `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// handleClassifyBatch classifies a JSON array of classifyRequest pages and
+// answers with an array of batchResult in the same order. Pages are
+// fetched and classified concurrently, sharing the classification slots of
+// POST /classify; one page failing does not fail the others.
+func (s *Server) handleClassifyBatch(w http.ResponseWriter, r *http.Request) {
+	cl := s.cl.Load()
+	if cl == nil {
+		writeError(w, http.StatusServiceUnavailable, "not_ready", "model not loaded")
+		return
+	}
+	opts, err := parseClassifyOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	var reqs []classifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)).Decode(&reqs); err != nil {
+		writeBodyError(w, err, "invalid JSON body: ")
+		return
+	}
+	if len(reqs) > s.cfg.MaxBatch {
+		writeError(w, http.StatusRequestEntityTooLarge, "too_many_pages",
+			fmt.Sprintf("batch of %d pages exceeds %d", len(reqs), s.cfg.MaxBatch))
+		return
+	}
+
+	results := make([]batchResult, len(reqs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(len(reqs), s.cfg.MaxConcurrent) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = s.classifyPage(r, cl, reqs[i], opts)
+			}
+		}()
+	}
+	for i := range reqs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if r.Context().Err() != nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// classifyPage fetches and classifies one page of a batch.
+func (s *Server) classifyPage(r *http.Request, cl *dit.Classifier, req classifyRequest, opts classifyOptions) batchResult {
+	res := batchResult{ID: req.ID, URL: req.URL}
+	html := req.HTML
+	switch {
+	case (req.HTML == "") == (req.URL == ""):
+		res.Error = "exactly one of html and url is required"
+		return res
+	case req.URL != "":
+		var page scan.Page
+		page, html = scan.Fetch(r.Context(), s.fetch, req.URL)
+		res.Status = page.Status
+		if page.Error != "" {
+			res.Error = "fetch: " + page.Error
+			return res
+		}
+	}
+	if detected := dit.DetectContentType(html); detected != "text/html" && detected != "text/plain" {
+		res.Error = "page does not look like HTML"
+		if detected != "" {
+			res.Error += " (" + detected + ")"
+		}
+		return res
+	}
+
+	if !s.acquire(r.Context()) {
+		res.Error = r.Context().Err().Error()
+		return res
+	}
+	defer s.release()
+	result, err := classify(cl, html, req.URL, opts)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Result = result
+	return res
+}
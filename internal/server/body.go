@@ -0,0 +1,51 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+)
+
+// readBody reads the request body up to MaxBodyBytes. With TruncateBody the
+// rest is discarded and truncated is set; otherwise an oversized body
+// returns an *http.MaxBytesError.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) (body []byte, truncated bool, err error) {
+	limit := s.cfg.MaxBodyBytes
+	if !s.cfg.TruncateBody {
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		return body, false, err
+	}
+	body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], true, nil
+	}
+	return body, false, nil
+}
+
+// acceptedContentType reports whether a request Content-Type may carry
+// HTML. A missing type is accepted and left to sniffing, as is curl's
+// --data-binary default of application/x-www-form-urlencoded.
+func acceptedContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "text/plain",
+		"application/octet-stream", "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
+
+// isJSON reports whether a request Content-Type is JSON.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/scan"
+	"github.com/happyhackingspace/dit/internal/store"
+	"github.com/happyhackingspace/dit/internal/webhook"
+)
+
+// maxTrackedURLs bounds the URLs whose last page type is kept in memory
+// when there is no results database.
+const maxTrackedURLs = 10000
+
+// pageTypes remembers the last page type seen for each scanned URL, so
+// repeated scans can report classification changes. With a results
+// database the last type is read from it, so changes are seen across
+// restarts; without one only the maxTrackedURLs most recently scanned URLs
+// of this process are remembered.
+type pageTypes struct {
+	mu     sync.Mutex
+	db     *store.Store     // nil without Config.Store
+	recent *dit.MemoryCache // used without db
+}
+
+func newPageTypes(db *store.Store) *pageTypes {
+	p := &pageTypes{db: db}
+	if db == nil {
+		p.recent = dit.NewMemoryCache(maxTrackedURLs)
+	}
+	return p
+}
+
+// swap records typ for url and returns the previous type. With a database
+// the page itself is recorded by Server.record, after the events are sent.
+func (p *pageTypes) swap(url, typ string) (string, bool) {
+	if p.db != nil {
+		prev, ok, err := p.db.LastPageType(url)
+		if err != nil {
+			slog.Warn("Cannot read previous page type", "url", url, "error", err)
+		}
+		return prev, ok
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev, ok := p.recent.Get(url)
+	p.recent.Set(url, []byte(typ))
+	return string(prev), ok
+}
+
+// pageEvents sends the webhook events a scanned page gives rise to: a login
+// page or login form, a soft 404, and a page type differing from an earlier
+// scan of the same URL (see pageTypes).
+func (s *Server) pageEvents(jobID string, page scan.Page) {
+	if s.webhook == nil || page.Error != "" {
+		return
+	}
+	event := func(typ string, data any) {
+		s.webhook.Send(webhook.Event{Type: typ, URL: page.URL, Job: jobID, Data: data})
+	}
+
+	if page.Type == "login" || hasLoginForm(page) {
+		event(webhook.LoginPageFound, map[string]any{"page_type": page.Type, "forms": page.Forms})
+	}
+	if page.Type == "soft_404" {
+		event(webhook.Soft404Detected, map[string]any{"status": page.Status})
+	}
+	if page.Type == "" {
+		return
+	}
+	if prev, ok := s.pageTypes.swap(page.URL, page.Type); ok && prev != page.Type {
+		event(webhook.ClassificationChanged, map[string]string{"previous": prev, "current": page.Type})
+	}
+}
+
+func hasLoginForm(page scan.Page) bool {
+	for _, form := range page.Forms {
+		if form.Type == "login" {
+			return true
+		}
+	}
+	return false
+}
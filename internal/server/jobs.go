@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/happyhackingspace/dit/internal/scan"
+	"github.com/happyhackingspace/dit/internal/store"
+)
+
+// Job states reported by GET /jobs/{id}.
+const (
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// jobRetention is how long finished jobs stay queryable.
+const jobRetention = time.Hour
+
+// job is a background site scan started by POST /scan.
+type job struct {
+	mu       sync.Mutex
+	id       string
+	url      string
+	maxPages int
+	status   string
+	err      string
+	started  time.Time
+	finished time.Time
+	pages    []scan.Page
+}
+
+// jobStatus is the JSON form of a job.
+type jobStatus struct {
+	ID       string      `json:"id"`
+	URL      string      `json:"url"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Progress jobProgress `json:"progress"`
+	Pages    []scan.Page `json:"pages"`
+}
+
+type jobProgress struct {
+	Scanned  int `json:"scanned"`
+	MaxPages int `json:"max_pages"`
+}
+
+func (j *job) snapshot() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	st := jobStatus{
+		ID:       j.id,
+		URL:      j.url,
+		Status:   j.status,
+		Error:    j.err,
+		Started:  j.started,
+		Progress: jobProgress{Scanned: len(j.pages), MaxPages: j.maxPages},
+		Pages:    append([]scan.Page{}, j.pages...),
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		st.Finished = &finished
+	}
+	return st
+}
+
+// jobs tracks scan jobs. Running jobs are bounded by Config.MaxJobs and are
+// cancelled when the server shuts down.
+type jobs struct {
+	mu     sync.Mutex
+	byID   map[string]*job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // running scan goroutines
+}
+
+func newJobs() *jobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &jobs{byID: make(map[string]*job), ctx: ctx, cancel: cancel}
+}
+
+// running returns the number of unfinished jobs and forgets jobs finished
+// longer than jobRetention ago.
+func (js *jobs) running(now time.Time) int {
+	n := 0
+	for id, j := range js.byID {
+		j.mu.Lock()
+		switch {
+		case j.status == jobRunning:
+			n++
+		case now.Sub(j.finished) > jobRetention:
+			delete(js.byID, id)
+		}
+		j.mu.Unlock()
+	}
+	return n
+}
+
+// scanRequest is the body of POST /scan.
+type scanRequest struct {
+	URL      string `json:"url"`
+	MaxPages int    `json:"max_pages,omitempty"`
+}
+
+// handleScan starts a background scan of the requested site and answers 202
+// with the job, to be polled at GET /jobs/{id}.
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	cl := s.cl.Load()
+	if cl == nil {
+		writeError(w, http.StatusServiceUnavailable, "not_ready", "model not loaded")
+		return
+	}
+
+	var req scanRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body: "+err.Error())
+		return
+	}
+	if req.URL == "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "url is required")
+		return
+	}
+	if err := scan.CheckURL(req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid url "+strconv.Quote(req.URL)+": "+err.Error())
+		return
+	}
+	if req.MaxPages <= 0 || req.MaxPages > s.cfg.ScanMaxPages {
+		req.MaxPages = s.cfg.ScanMaxPages
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	j := &job{
+		id:       id,
+		url:      req.URL,
+		maxPages: req.MaxPages,
+		status:   jobRunning,
+		started:  time.Now(),
+	}
+
+	s.jobs.mu.Lock()
+	if s.jobs.running(j.started) >= s.cfg.MaxJobs {
+		s.jobs.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, "too_many_jobs", "too many scans running")
+		return
+	}
+	s.jobs.byID[id] = j
+	s.jobs.mu.Unlock()
+
+	// Scans fetch with the server's client, so they are held to the same
+	// public-address rule as POST /classify, and classify in its slots.
+	cfg := s.fetch
+	cfg.MaxPages, cfg.Delay, cfg.Slots = req.MaxPages, s.cfg.ScanDelay, s.slots
+	s.jobs.wg.Add(1)
+	go func() {
+		defer s.jobs.wg.Done()
+		err := scan.Site(s.jobs.ctx, cl, req.URL, cfg, func(page scan.Page) {
+			j.mu.Lock()
+			j.pages = append(j.pages, page)
+			j.mu.Unlock()
+			s.pageEvents(j.id, page)
+			s.record(j.id, page)
+		})
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.finished = time.Now()
+		switch {
+		case err == nil:
+			j.status = jobDone
+		case errors.Is(err, context.Canceled):
+			j.status = jobCancelled
+		default:
+			j.status = jobFailed
+			j.err = err.Error()
+		}
+		slog.Debug("Scan finished", "job", j.id, "url", j.url, "status", j.status, "pages", len(j.pages))
+	}()
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// handleJob reports a scan job's progress and the pages scanned so far.
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	s.jobs.mu.Lock()
+	j, ok := s.jobs.byID[r.PathValue("id")]
+	s.jobs.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, j.snapshot())
+}
+
+// record adds a scanned page to the results store, if configured.
+func (s *Server) record(jobID string, page scan.Page) {
+	if s.cfg.Store == nil {
+		return
+	}
+	err := s.cfg.Store.Add(store.Record{
+		URL:      page.URL,
+		Job:      jobID,
+		Status:   page.Status,
+		PageType: page.Type,
+		Forms:    page.Forms,
+		Error:    page.Error,
+	})
+	if err != nil {
+		slog.Warn("Cannot record scanned page", "url", page.URL, "error", err)
+	}
+}
+
+func newJobID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
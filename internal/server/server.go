@@ -0,0 +1,400 @@
+// Package server implements dit's HTTP serve mode.
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/fetch"
+	"github.com/happyhackingspace/dit/internal/scan"
+	"github.com/happyhackingspace/dit/internal/store"
+	"github.com/happyhackingspace/dit/internal/webhook"
+)
+
+// Config holds serve mode settings.
+type Config struct {
+	Addr string
+	// MaxConcurrent bounds simultaneous classifications; further requests
+	// wait for a slot. 0 uses GOMAXPROCS.
+	MaxConcurrent int
+	// ShutdownTimeout bounds how long in-flight requests may run after
+	// shutdown starts. 0 means 30 seconds.
+	ShutdownTimeout time.Duration
+	// APIKeys, if set, are required on classification requests. Probes
+	// stay open.
+	APIKeys []string
+	// RateLimit is the sustained number of classification requests per
+	// second allowed per API key (per client IP without keys), with bursts
+	// up to RateBurst. 0 disables rate limiting.
+	RateLimit float64
+	RateBurst int
+	// MaxBodyBytes caps the request body; 0 means 10 MiB. Larger bodies are
+	// rejected with 413, or cut to the limit if TruncateBody is set.
+	MaxBodyBytes int64
+	TruncateBody bool
+	// MaxBatch caps the pages of one POST /classify/batch request; 0 means
+	// 100.
+	MaxBatch int
+	// AllowPrivateURLs lets the classify and scan endpoints fetch URLs that
+	// resolve to loopback, private, link-local, and other non-public
+	// addresses.
+	// Off by default, so the server cannot be used to reach internal
+	// services; turn it on only where every client is trusted.
+	AllowPrivateURLs bool
+	// MaxJobs bounds concurrently running scan jobs; 0 means 4.
+	MaxJobs int
+	// ScanMaxPages caps the pages fetched per scan job; 0 means 50.
+	ScanMaxPages int
+	// ScanDelay is the pause between page fetches within a scan.
+	ScanDelay time.Duration
+	// Webhook, if its URL is set, receives scan events: login pages found,
+	// soft 404s detected, and page types changed since an earlier scan of
+	// the URL, which is looked up in Store if set and otherwise remembered
+	// only in memory for recently scanned URLs.
+	Webhook webhook.Config
+	// Store, if set, records every page scanned by a scan job.
+	Store *store.Store
+}
+
+// Server answers classification requests over HTTP.
+//
+// GET /healthz reports that the process is up. GET /readyz reports whether
+// a model is loaded and the server is not shutting down, for readiness
+// probes. POST /classify classifies the HTML request body, or the HTML or
+// URL of a JSON body; oversized, mistyped, and non-HTML bodies (PDF, JSON,
+// images) are refused with a JSON error carrying a machine-readable code.
+// POST /classify/batch classifies a JSON array of such pages. POST /scan starts a
+// background crawl-and-classify job of a site, polled at GET /jobs/{id}.
+type Server struct {
+	cfg       Config
+	cl        atomic.Pointer[dit.Classifier]
+	draining  atomic.Bool
+	slots     chan struct{}
+	keys      [][sha256.Size]byte // hashed APIKeys
+	limiter   *limiter            // nil without RateLimit
+	jobs      *jobs
+	webhook   *webhook.Sender // nil without Webhook.URL
+	pageTypes *pageTypes
+	fetch     scan.Config // how pages are fetched from client-given URLs
+}
+
+// New creates a Server. It is not ready until SetClassifier is called.
+func New(cfg Config) *Server {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 10 << 20
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 100
+	}
+	if cfg.MaxJobs <= 0 {
+		cfg.MaxJobs = 4
+	}
+	if cfg.ScanMaxPages <= 0 {
+		cfg.ScanMaxPages = 50
+	}
+	s := &Server{
+		cfg:   cfg,
+		slots: make(chan struct{}, cfg.MaxConcurrent),
+		jobs:  newJobs(),
+	}
+	s.pageTypes = newPageTypes(cfg.Store)
+	for _, key := range cfg.APIKeys {
+		s.keys = append(s.keys, sha256.Sum256([]byte(key)))
+	}
+	if cfg.RateLimit > 0 {
+		s.limiter = newLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+	if cfg.Webhook.URL != "" {
+		s.webhook = webhook.New(cfg.Webhook)
+	}
+	if !cfg.AllowPrivateURLs {
+		s.fetch.Client = &http.Client{Timeout: 30 * time.Second, Transport: fetch.NewPublicTransport()}
+	}
+	return s
+}
+
+// SetClassifier installs the classifier and marks the server ready.
+func (s *Server) SetClassifier(cl *dit.Classifier) {
+	s.cl.Store(cl)
+}
+
+// Handler returns the HTTP handler for all endpoints.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+	mux.HandleFunc("POST /classify", s.requireKey(s.rateLimit(s.handleClassify)))
+	mux.HandleFunc("POST /classify/batch", s.requireKey(s.rateLimit(s.handleClassifyBatch)))
+	mux.HandleFunc("POST /scan", s.requireKey(s.rateLimit(s.handleScan)))
+	mux.HandleFunc("GET /jobs/{id}", s.requireKey(s.handleJob))
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled, then stops accepting
+// requests, fails readiness, cancels running scan jobs, and waits up to
+// ShutdownTimeout for in-flight requests to finish.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.cfg.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	slog.Info("Serving", "addr", s.cfg.Addr, "max-concurrent", s.cfg.MaxConcurrent,
+		"auth", len(s.keys) > 0, "rate-limit", s.cfg.RateLimit)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.draining.Store(true)
+	s.jobs.cancel()
+	slog.Info("Shutting down", "timeout", s.cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	s.jobs.wg.Wait() // cancelled scans stop sending events
+	if s.webhook != nil {
+		if err := s.webhook.Close(shutdownCtx); err != nil {
+			slog.Warn("Undelivered webhook events", "error", err)
+		}
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case s.draining.Load():
+		writeError(w, http.StatusServiceUnavailable, "shutting_down", "shutting down")
+	case s.cl.Load() == nil:
+		writeError(w, http.StatusServiceUnavailable, "not_ready", "model not loaded")
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
+
+// classifyOptions are the query parameters of the classify endpoints,
+// which mirror the `dit run` flags.
+type classifyOptions struct {
+	proba     bool
+	threshold float64
+	precision dit.Precision
+}
+
+func parseClassifyOptions(query url.Values) (classifyOptions, error) {
+	opts := classifyOptions{
+		proba:     query.Get("proba") == "true" || query.Get("proba") == "1",
+		threshold: 0.05,
+		precision: dit.Precision(-1),
+	}
+	if v := query.Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, errors.New("invalid threshold")
+		}
+		opts.threshold = t
+	}
+	if v := query.Get("precision"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, errors.New("invalid precision")
+		}
+		opts.precision = dit.Precision(d)
+	}
+	return opts, nil
+}
+
+// classifyRequest is a JSON body of POST /classify and an element of the
+// POST /classify/batch array: a page's HTML, or a URL for the server to
+// fetch it from.
+type classifyRequest struct {
+	ID   json.RawMessage `json:"id,omitempty"` // echoed in batch results
+	HTML string          `json:"html,omitempty"`
+	URL  string          `json:"url,omitempty"`
+}
+
+// handleClassify classifies the HTML request body, or with a JSON body the
+// HTML or URL it holds. Query parameters proba, threshold, and precision
+// mirror the `dit run` flags. The response is the page result with its
+// forms, or just the forms if the model has no page classifier.
+func (s *Server) handleClassify(w http.ResponseWriter, r *http.Request) {
+	cl := s.cl.Load()
+	if cl == nil {
+		writeError(w, http.StatusServiceUnavailable, "not_ready", "model not loaded")
+		return
+	}
+	opts, err := parseClassifyOptions(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	var html, pageURL string
+	if isJSON(r.Header.Get("Content-Type")) {
+		var req classifyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes)).Decode(&req); err != nil {
+			writeBodyError(w, err, "invalid JSON body: ")
+			return
+		}
+		switch {
+		case (req.HTML == "") == (req.URL == ""):
+			writeError(w, http.StatusBadRequest, "bad_request", "exactly one of html and url is required")
+			return
+		case req.URL != "":
+			page, body := scan.Fetch(r.Context(), s.fetch, req.URL)
+			if page.Error != "" {
+				writeError(w, http.StatusBadGateway, "fetch_failed", "fetch "+req.URL+": "+page.Error)
+				return
+			}
+			w.Header().Set("X-Dit-Status", strconv.Itoa(page.Status))
+			html, pageURL = body, req.URL
+		default:
+			html = req.HTML
+		}
+	} else {
+		if !acceptedContentType(r.Header.Get("Content-Type")) {
+			writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type",
+				"Content-Type must be text/html, application/xhtml+xml, text/plain, or application/json")
+			return
+		}
+		body, truncated, err := s.readBody(w, r)
+		if err != nil {
+			writeBodyError(w, err, "read body: ")
+			return
+		}
+		if truncated {
+			w.Header().Set("X-Dit-Truncated", "true")
+		}
+		html = string(body)
+	}
+
+	switch detected := dit.DetectContentType(html); detected {
+	case "text/html", "text/plain":
+	case "":
+		writeError(w, http.StatusUnsupportedMediaType, "not_html", "request body is empty")
+		return
+	default:
+		writeJSON(w, http.StatusUnsupportedMediaType, errorResponse{
+			Code:     "not_html",
+			Error:    "request body does not look like HTML",
+			Detected: detected,
+		})
+		return
+	}
+
+	if !s.acquire(r.Context()) {
+		return
+	}
+	defer s.release()
+
+	result, err := classify(cl, html, pageURL, opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// acquire waits for a classification slot and reports whether it got one
+// before ctx was done.
+func (s *Server) acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Server) release() {
+	<-s.slots
+}
+
+// writeBodyError answers a request whose body could not be read.
+func writeBodyError(w http.ResponseWriter, err error, prefix string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "too_large",
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, "bad_request", prefix+err.Error())
+}
+
+// classify returns the page result, or the forms if the model has no page
+// classifier, as `dit run` prints them. pageURL is the URL html was
+// fetched from, or "" for a posted page.
+func classify(cl *dit.Classifier, html, pageURL string, opts classifyOptions) (any, error) {
+	if opts.proba {
+		page, err := cl.ExtractPageTypeProbaURL(html, pageURL, opts.threshold)
+		if err == nil {
+			return opts.precision.PageProba(page), nil
+		}
+		if !errors.Is(err, dit.ErrNoPageModel) {
+			return nil, err
+		}
+		forms, err := cl.ExtractFormsProba(html, opts.threshold)
+		if err != nil {
+			return nil, err
+		}
+		return opts.precision.FormsProba(forms), nil
+	}
+	page, err := cl.ExtractPageTypeURL(html, pageURL)
+	if err == nil {
+		return page, nil
+	}
+	if !errors.Is(err, dit.ErrNoPageModel) {
+		return nil, err
+	}
+	return cl.ExtractForms(html)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// errorResponse is the body of every error reply.
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"` // stable machine-readable reason
+	// Detected is the sniffed content type of a rejected non-HTML body.
+	Detected string `json:"detected,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg, Code: code})
+}
@@ -0,0 +1,462 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+	"github.com/happyhackingspace/dit/internal/ditest"
+	"github.com/happyhackingspace/dit/internal/scan"
+	"github.com/happyhackingspace/dit/internal/store"
+	"github.com/happyhackingspace/dit/internal/webhook"
+)
+
+func TestProbes(t *testing.T) {
+	s := New(Config{})
+	h := s.Handler()
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d, want 200", code)
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before model load = %d, want 503", code)
+	}
+
+	s.SetClassifier(ditest.Classifier(t))
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz after model load = %d, want 200", code)
+	}
+
+	s.draining.Store(true)
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while draining = %d, want 503", code)
+	}
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz while draining = %d, want 200", code)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	s := New(Config{MaxConcurrent: 1})
+	h := s.Handler()
+	html := `<form><input type="text" name="user"/><input type="password" name="pass"/></form>`
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader(html)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("classify before model load = %d, want 503", rec.Code)
+	}
+
+	s.SetClassifier(ditest.Classifier(t))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader(html)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("classify = %d: %s", rec.Code, rec.Body.String())
+	}
+	var forms []dit.FormResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &forms); err != nil {
+		t.Fatal(err)
+	}
+	if len(forms) != 1 || forms[0].Type != "login" {
+		t.Errorf("forms = %+v, want one login form", forms)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/classify?threshold=abc", strings.NewReader(html)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad threshold = %d, want 400", rec.Code)
+	}
+}
+
+func TestClassifyWaitsForSlot(t *testing.T) {
+	s := New(Config{MaxConcurrent: 1})
+	s.SetClassifier(ditest.Classifier(t))
+	s.slots <- struct{}{} // occupy the only slot
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader("<form></form>")).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Body.Len() != 0 {
+		t.Errorf("request abandoned while waiting got a response: %s", rec.Body.String())
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	s := New(Config{APIKeys: []string{"secret"}})
+	s.SetClassifier(ditest.Classifier(t))
+	h := s.Handler()
+
+	classify := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader("<form></form>"))
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := classify("", ""); code != http.StatusUnauthorized {
+		t.Errorf("no key = %d, want 401", code)
+	}
+	if code := classify("X-API-Key", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong key = %d, want 401", code)
+	}
+	if code := classify("Authorization", "Bearer secret"); code != http.StatusOK {
+		t.Errorf("bearer key = %d, want 200", code)
+	}
+	if code := classify("X-API-Key", "secret"); code != http.StatusOK {
+		t.Errorf("X-API-Key = %d, want 200", code)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/readyz without key = %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := New(Config{APIKeys: []string{"a", "b"}, RateLimit: 1, RateBurst: 2})
+	s.SetClassifier(ditest.Classifier(t))
+	now := time.Unix(1000, 0)
+	s.limiter.now = func() time.Time { return now }
+	h := s.Handler()
+
+	classify := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader("<form></form>"))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := classify("a"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within burst = %d, want 200", i, rec.Code)
+		}
+	}
+	rec := classify("a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("over burst = %d (Retry-After %q), want 429 with Retry-After 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := classify("b"); rec.Code != http.StatusOK {
+		t.Errorf("other key = %d, want its own bucket", rec.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rec := classify("a"); rec.Code != http.StatusOK {
+		t.Errorf("after refill = %d, want 200", rec.Code)
+	}
+}
+
+func TestClassifyRejectsBadBodies(t *testing.T) {
+	s := New(Config{MaxBodyBytes: 64})
+	s.SetClassifier(ditest.Classifier(t))
+	h := s.Handler()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		detected    string
+	}{
+		{"too large", "text/html", "<form>" + strings.Repeat("x", 64) + "</form>", http.StatusRequestEntityTooLarge, "too_large", ""},
+		{"xml content type", "application/xml", `<feed></feed>`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"json without page", "application/json", `{"id": 1}`, http.StatusBadRequest, "bad_request", ""},
+		{"pdf body", "application/octet-stream", "%PDF-1.7\n1 0 obj\n", http.StatusUnsupportedMediaType, "not_html", "application/pdf"},
+		{"json body", "text/plain", `{"html": "<form></form>"}`, http.StatusUnsupportedMediaType, "not_html", "application/json"},
+		{"empty body", "text/html", " \n", http.StatusUnsupportedMediaType, "not_html", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			var resp errorResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tt.code || resp.Detected != tt.detected {
+				t.Errorf("error = %+v, want code %q detected %q", resp, tt.code, tt.detected)
+			}
+		})
+	}
+}
+
+func TestClassifyJSON(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprint(w, `<form><input type="search" name="q"/></form>`)
+	}))
+	defer site.Close()
+	s := New(Config{MaxConcurrent: 2, MaxBatch: 3, AllowPrivateURLs: true})
+	s.SetClassifier(ditest.Classifier(t))
+	h := s.Handler()
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/classify", `{"url": "`+site.URL+`/"}`)
+	var forms []dit.FormResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &forms); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("classify URL = %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(forms) != 1 || forms[0].Type != "search" || rec.Header().Get("X-Dit-Status") != "200" {
+		t.Errorf("classify URL = %+v, status header %q; want one search form fetched with 200", forms, rec.Header().Get("X-Dit-Status"))
+	}
+	if rec := post("/classify", `{"url": "ftp://example.com/"}`); rec.Code != http.StatusBadGateway {
+		t.Errorf("classify ftp URL = %d, want 502", rec.Code)
+	}
+
+	// By default the server refuses to fetch internal addresses.
+	public := New(Config{})
+	public.SetClassifier(s.cl.Load())
+	for _, path := range []string{"/classify", "/classify/batch"} {
+		body := `{"url": "` + site.URL + `/"}`
+		if path == "/classify/batch" {
+			body = "[" + body + "]"
+		}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		public.Handler().ServeHTTP(rec, req)
+		if !strings.Contains(rec.Body.String(), "non-public address") {
+			t.Errorf("%s of a loopback URL = %d %s, want it refused", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = post("/classify/batch", `[
+		{"id": "a", "html": "<form><input type=\"text\" name=\"user\"/><input type=\"password\" name=\"pass\"/></form>"},
+		{"id": "b", "url": "`+site.URL+`/missing"},
+		{"id": "c"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch = %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []struct {
+		ID     string           `json:"id"`
+		Status int              `json:"status"`
+		Result []dit.FormResult `json:"result"`
+		Error  string           `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].ID != "a" || results[1].ID != "b" || results[2].ID != "c" {
+		t.Fatalf("batch results = %+v, want three in request order", results)
+	}
+	if len(results[0].Result) != 1 || results[0].Result[0].Type != "login" {
+		t.Errorf("results[0] = %+v, want a login form", results[0])
+	}
+	if results[1].Status != http.StatusNotFound || len(results[1].Result) != 1 || results[1].Result[0].Type != "search" {
+		t.Errorf("results[1] = %+v, want the fetched 404 page classified", results[1])
+	}
+	if results[2].Error == "" {
+		t.Errorf("results[2] = %+v, want an error for a page without html or url", results[2])
+	}
+
+	if rec := post("/classify/batch", `[{"html": "a"}, {"html": "b"}, {"html": "c"}, {"html": "d"}]`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch = %d, want 413", rec.Code)
+	}
+}
+
+func TestClassifyTruncatesBody(t *testing.T) {
+	html := `<form><input type="text" name="user"/><input type="password" name="pass"/></form>`
+	s := New(Config{MaxBodyBytes: int64(len(html)), TruncateBody: true})
+	s.SetClassifier(ditest.Classifier(t))
+
+	req := httptest.NewRequest(http.MethodPost, "/classify", strings.NewReader(html+strings.Repeat(" ", 100)))
+	req.Header.Set("Content-Type", "text/html; charset=utf-8")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("classify = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Dit-Truncated") != "true" {
+		t.Error("truncated response lacks X-Dit-Truncated header")
+	}
+}
+
+func TestScanJob(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<form><input type="text" name="user"/><input type="password" name="pass"/></form>`))
+	}))
+	defer site.Close()
+
+	s := New(Config{MaxJobs: 1, AllowPrivateURLs: true})
+	s.SetClassifier(ditest.Classifier(t))
+	h := s.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(`{"url": "example.com/login"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("scan of a relative URL = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(`{"url": "`+site.URL+`"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("scan = %d: %s", rec.Code, rec.Body.String())
+	}
+	var started jobStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatal(err)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/jobs/"+started.ID {
+		t.Errorf("Location = %q, want /jobs/%s", loc, started.ID)
+	}
+
+	var st jobStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/"+started.ID, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("job = %d: %s", rec.Code, rec.Body.String())
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+			t.Fatal(err)
+		}
+		if st.Status != jobRunning || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st.Status != jobDone || st.Progress.Scanned != 1 || st.Finished == nil {
+		t.Fatalf("job = %+v, want one page done", st)
+	}
+	if forms := st.Pages[0].Forms; len(forms) != 1 || forms[0].Type != "login" {
+		t.Errorf("scanned forms = %+v, want one login form", forms)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/jobs/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown job = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("scan without url = %d, want 400", rec.Code)
+	}
+
+	// By default scans, like /classify, do not fetch loopback addresses.
+	s = New(Config{})
+	s.SetClassifier(ditest.Classifier(t))
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/scan", strings.NewReader(`{"url": "`+site.URL+`"}`)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("scan = %d: %s", rec.Code, rec.Body.String())
+	}
+	s.jobs.wg.Wait()
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatal(err)
+	}
+	s.jobs.mu.Lock()
+	st = s.jobs.byID[started.ID].snapshot()
+	s.jobs.mu.Unlock()
+	if len(st.Pages) != 1 || !strings.Contains(st.Pages[0].Error, "non-public") || st.Pages[0].Forms != nil {
+		t.Errorf("scan of a loopback site = %+v, want its page refused", st)
+	}
+}
+
+func TestPageEvents(t *testing.T) {
+	var mu sync.Mutex
+	var got []webhook.Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	s := New(Config{Webhook: webhook.Config{URL: receiver.URL}})
+	s.pageEvents("j1", scan.Page{URL: "http://a.example.org/", Type: "other"})
+	s.pageEvents("j2", scan.Page{URL: "http://a.example.org/", Type: "soft_404"})
+	s.pageEvents("j2", scan.Page{URL: "http://a.example.org/account", Type: "other",
+		Forms: []dit.FormResult{{Type: "login"}}})
+	s.pageEvents("j2", scan.Page{URL: "http://a.example.org/down", Error: "timeout"})
+	if err := s.webhook.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for _, e := range got {
+		types = append(types, e.Type+" "+e.URL)
+	}
+	want := []string{
+		webhook.Soft404Detected + " http://a.example.org/",
+		webhook.ClassificationChanged + " http://a.example.org/",
+		webhook.LoginPageFound + " http://a.example.org/account",
+	}
+	if strings.Join(types, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events =\n%s\nwant\n%s", strings.Join(types, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPageTypesPersistAcrossRestarts(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	page := scan.Page{URL: "http://a.example.org/", Type: "other"}
+	first := New(Config{Store: db})
+	if _, ok := first.pageTypes.swap(page.URL, page.Type); ok {
+		t.Error("first scan has a previous page type")
+	}
+	first.record("j1", page)
+
+	// A new server on the same database sees the earlier scan.
+	second := New(Config{Store: db})
+	if prev, ok := second.pageTypes.swap(page.URL, "soft_404"); !ok || prev != "other" {
+		t.Errorf("previous page type = %q, %v, want other", prev, ok)
+	}
+
+	// Without a database only recently scanned URLs are remembered.
+	memory := newPageTypes(nil)
+	memory.swap(page.URL, "other")
+	for i := range maxTrackedURLs {
+		memory.swap(fmt.Sprintf("http://a.example.org/%d", i), "other")
+	}
+	if _, ok := memory.swap(page.URL, "soft_404"); ok {
+		t.Error("least recently scanned URL still remembered past maxTrackedURLs")
+	}
+}
@@ -1,6 +1,12 @@
 // Package storage provides access to annotation data for form classification training.
 package storage
 
+import (
+	"strings"
+
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
 // AnnotationSchema holds the types and their mappings for form or field annotations.
 type AnnotationSchema struct {
 	Types       map[string]string // full_name -> short_name
@@ -8,17 +14,45 @@ type AnnotationSchema struct {
 	NAValue     string
 	SkipValue   string
 	SimplifyMap map[string]string
+	// URLPatterns holds the URL patterns of the types that have them, in
+	// config order.
+	URLPatterns []TypeURLPatterns
+}
+
+// TypeURLPatterns lists the URL path fragments that mark pages of a type.
+type TypeURLPatterns struct {
+	Short    string
+	Patterns []string
+}
+
+// TypeForURLPath returns the short name of the first type with a pattern
+// contained in the lowercased path, or "" if none matches or s is nil.
+func (s *AnnotationSchema) TypeForURLPath(path string) string {
+	if s == nil {
+		return ""
+	}
+	path = strings.ToLower(path)
+	for _, t := range s.URLPatterns {
+		for _, p := range t.Patterns {
+			if p != "" && strings.Contains(path, strings.ToLower(p)) {
+				return t.Short
+			}
+		}
+	}
+	return ""
 }
 
 // FormAnnotation represents a single annotated form.
 type FormAnnotation struct {
 	FormHTML       string
+	Hash           string // content hash of FormHTML; see htmlutil.FormHash
 	URL            string
-	Type           string            // short form type
-	TypeFull       string            // full form type
-	FormIndex      int               // index of form on the page
-	FieldTypes     map[string]string // field_name -> short_type
-	FieldTypesFull map[string]string // field_name -> full_type
+	Type           string                // short form type
+	TypeFull       string                // full form type
+	FormIndex      int                   // index of form on the page
+	Position       htmlutil.FormPosition // where the form sits on the page
+	FieldTypes     map[string]string     // field_name -> short_type
+	FieldTypesFull map[string]string     // field_name -> full_type
 	FormSchema     *AnnotationSchema
 	FieldSchema    *AnnotationSchema
 
@@ -3,7 +3,6 @@ package storage
 import (
 	"encoding/json"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -25,9 +24,16 @@ type pageConfigJSON struct {
 }
 
 // pageIndexEntry represents a single entry in the page index.json.
+// Pending entries were labeled automatically by dit collect and are left
+// out of annotations until reviewed. Raw is the path of the page as
+// received when the indexed file holds a normalized copy, and Status the
+// HTTP status it was served with, if recorded.
 type pageIndexEntry struct {
 	URL      string `json:"url"`
 	PageType string `json:"page_type"`
+	Pending  bool   `json:"pending,omitempty"`
+	Raw      string `json:"raw,omitempty"`
+	Status   int    `json:"status,omitempty"`
 }
 
 // PageAnnotation represents a single annotated page.
@@ -51,6 +57,17 @@ func (s *PageStorage) GetPageSchema() (*AnnotationSchema, error) {
 	return buildSchema(config.PageTypes), nil
 }
 
+// collectPageTypes are the page types dit collect assigns on its own;
+// annotations use their full names when config.json does not define
+// their codes.
+var collectPageTypes = []typeEntry{
+	{Full: "maintenance", Short: "mt"},
+	{Full: "pricing", Short: "pc"},
+	{Full: "checkout", Short: "ck"},
+	{Full: "docs", Short: "dc"},
+	{Full: "settings", Short: "st"},
+}
+
 // GetPageIndex reads the page index file.
 func (s *PageStorage) GetPageIndex() (map[string]pageIndexEntry, error) {
 	data, err := os.ReadFile(filepath.Join(s.Folder, "index.json"))
@@ -70,6 +87,11 @@ func (s *PageStorage) IterPageAnnotations(opts IterOptions) ([]PageAnnotation, e
 	if err != nil {
 		return nil, fmt.Errorf("get page schema: %w", err)
 	}
+	for _, t := range collectPageTypes {
+		if _, ok := schema.TypesInv[t.Short]; !ok {
+			schema.TypesInv[t.Short] = t.Full
+		}
+	}
 	index, err := s.GetPageIndex()
 	if err != nil {
 		return nil, fmt.Errorf("get page index: %w", err)
@@ -94,8 +116,18 @@ func (s *PageStorage) IterPageAnnotations(opts IterOptions) ([]PageAnnotation, e
 	})
 
 	var annotations []PageAnnotation
+	pending := 0
 	for _, pi := range sorted {
+		if pi.info.Pending {
+			pending++
+			continue
+		}
 		tp := pi.info.PageType
+		if opts.SimplifyPageTypes {
+			if simplified, ok := schema.SimplifyMap[tp]; ok {
+				tp = simplified
+			}
+		}
 
 		if opts.DropNA && tp == schema.NAValue {
 			continue
@@ -107,7 +139,7 @@ func (s *PageStorage) IterPageAnnotations(opts IterOptions) ([]PageAnnotation, e
 		htmlPath := filepath.Join(s.Folder, pi.path)
 		htmlData, err := os.ReadFile(htmlPath)
 		if err != nil {
-			slog.Warn("Cannot read page annotation file", "path", pi.path, "error", err)
+			opts.logger().Warn("Cannot read page annotation file", "path", pi.path, "error", err)
 			continue
 		}
 
@@ -124,6 +156,9 @@ func (s *PageStorage) IterPageAnnotations(opts IterOptions) ([]PageAnnotation, e
 		}
 		annotations = append(annotations, ann)
 	}
+	if pending > 0 {
+		opts.logger().Info("Skipping pages pending review (see dit collect review)", "count", pending)
+	}
 
 	return annotations, nil
 }
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// parallelOrdered applies fn to items on up to workers goroutines and yields
+// the results in item order. Work runs at most 2*workers items ahead of the
+// consumer; breaking out of the loop stops the remaining work.
+func parallelOrdered[T, R any](items []T, workers int, fn func(T) R) iter.Seq[R] {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return func(yield func(R) bool) {
+		if workers == 1 {
+			for _, item := range items {
+				if !yield(fn(item)) {
+					return
+				}
+			}
+			return
+		}
+
+		type job struct {
+			item   T
+			result chan R
+		}
+		done := make(chan struct{})
+		jobs := make(chan job)
+		pending := make(chan chan R, 2*workers)
+
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		defer close(done)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(jobs)
+			defer close(pending)
+			for _, item := range items {
+				result := make(chan R, 1)
+				select {
+				case pending <- result:
+				case <-done:
+					return
+				}
+				select {
+				case jobs <- job{item, result}:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					j.result <- fn(j.item)
+				}
+			}()
+		}
+
+		for result := range pending {
+			if !yield(<-result) {
+				return
+			}
+		}
+	}
+}
@@ -1,18 +1,19 @@
 package storage
 
 import (
-	"crypto/md5"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
 	"golang.org/x/net/publicsuffix"
 
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 )
 
 // Storage wraps the annotation data folder.
@@ -41,6 +42,9 @@ type typeConfig struct {
 type typeEntry struct {
 	Full  string `json:"full"`
 	Short string `json:"short"`
+	// URLPatterns are URL path fragments of pages of the type, e.g.
+	// "/pricing"; only page types have them.
+	URLPatterns []string `json:"url_patterns,omitempty"`
 }
 
 // indexEntry represents a single entry in index.json.
@@ -84,9 +88,13 @@ func (s *Storage) GetFieldSchema() (*AnnotationSchema, error) {
 func buildSchema(tc typeConfig) *AnnotationSchema {
 	types := make(map[string]string, len(tc.Types))
 	typesInv := make(map[string]string, len(tc.Types))
+	var urlPatterns []TypeURLPatterns
 	for _, t := range tc.Types {
 		types[t.Full] = t.Short
 		typesInv[t.Short] = t.Full
+		if len(t.URLPatterns) > 0 {
+			urlPatterns = append(urlPatterns, TypeURLPatterns{Short: t.Short, Patterns: t.URLPatterns})
+		}
 	}
 	return &AnnotationSchema{
 		Types:       types,
@@ -94,6 +102,7 @@ func buildSchema(tc typeConfig) *AnnotationSchema {
 		NAValue:     tc.NAValue,
 		SkipValue:   tc.SkipValue,
 		SimplifyMap: tc.SimplifyMap,
+		URLPatterns: urlPatterns,
 	}
 }
 
@@ -110,8 +119,22 @@ func (s *Storage) GetIndex() (map[string]indexEntry, error) {
 	return index, nil
 }
 
-// IterAnnotations yields FormAnnotation objects from the storage.
+// IterAnnotations returns all FormAnnotation objects from the storage.
 func (s *Storage) IterAnnotations(opts IterOptions) ([]FormAnnotation, error) {
+	annotations, err := s.Annotations(opts)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Collect(annotations), nil
+}
+
+// Annotations returns an iterator over FormAnnotation objects. The schemas
+// and index are read up front (so their errors are reported immediately).
+// Pages are read and parsed by a pool of opts.Workers goroutines only as
+// the caller ranges over the sequence, a bounded number ahead of it, so
+// stopping early skips the remaining pages. Pages whose forms would all be
+// dropped are never parsed.
+func (s *Storage) Annotations(opts IterOptions) (iter.Seq[FormAnnotation], error) {
 	formSchema, err := s.GetFormSchema()
 	if err != nil {
 		return nil, fmt.Errorf("get form schema: %w", err)
@@ -126,13 +149,9 @@ func (s *Storage) IterAnnotations(opts IterOptions) ([]FormAnnotation, error) {
 	}
 
 	// Sort by domain + path for deterministic ordering
-	type pathInfo struct {
-		path string
-		info indexEntry
-	}
-	sorted := make([]pathInfo, 0, len(index))
+	sorted := make([]indexPage, 0, len(index))
 	for path, info := range index {
-		sorted = append(sorted, pathInfo{path, info})
+		sorted = append(sorted, indexPage{path, info})
 	}
 	sort.Slice(sorted, func(i, j int) bool {
 		di := GetDomain(sorted[i].info.URL)
@@ -143,106 +162,138 @@ func (s *Storage) IterAnnotations(opts IterOptions) ([]FormAnnotation, error) {
 		return sorted[i].path < sorted[j].path
 	})
 
-	seen := make(map[string]bool)
-	var annotations []FormAnnotation
-
-	for _, pi := range sorted {
-		htmlPath := filepath.Join(s.Folder, pi.path)
-		htmlData, err := os.ReadFile(htmlPath)
-		if err != nil {
-			slog.Warn("Cannot read annotation file", "path", pi.path, "error", err)
-			continue
+	// Only pages with at least one kept form are read and parsed.
+	pages := sorted[:0]
+	for _, page := range sorted {
+		for _, tp := range page.info.Forms {
+			if _, ok := keepFormType(tp, formSchema, opts); ok {
+				pages = append(pages, page)
+				break
+			}
 		}
+	}
 
-		doc, err := htmlutil.LoadHTMLString(string(htmlData))
-		if err != nil {
-			continue
+	load := func(page indexPage) []FormAnnotation {
+		return s.pageAnnotations(page, formSchema, fieldSchema, opts)
+	}
+	return func(yield func(FormAnnotation) bool) {
+		seen := make(map[string]bool)
+		for anns := range parallelOrdered(pages, opts.Workers, load) {
+			for _, ann := range anns {
+				// Deduplication by form content hash
+				if opts.DropDuplicates {
+					if seen[ann.Hash] {
+						continue
+					}
+					seen[ann.Hash] = true
+				}
+				if !yield(ann) {
+					return
+				}
+			}
 		}
+	}, nil
+}
 
-		forms := htmlutil.GetForms(doc)
+// indexPage is an index.json entry with its page path.
+type indexPage struct {
+	path string
+	info indexEntry
+}
 
-		for idx, form := range forms {
-			if idx >= len(pi.info.Forms) {
-				break
-			}
+// keepFormType applies type simplification and reports whether a form
+// annotated as tp survives the drop options.
+func keepFormType(tp string, formSchema *AnnotationSchema, opts IterOptions) (string, bool) {
+	if opts.SimplifyFormTypes {
+		if simplified, ok := formSchema.SimplifyMap[tp]; ok {
+			tp = simplified
+		}
+	}
+	if opts.DropNA && tp == formSchema.NAValue {
+		return tp, false
+	}
+	if opts.DropSkipped && tp == formSchema.SkipValue {
+		return tp, false
+	}
+	return tp, true
+}
 
-			tp := pi.info.Forms[idx]
+// pageAnnotations reads and parses one annotated page and returns its kept
+// forms in page order, before deduplication.
+func (s *Storage) pageAnnotations(page indexPage, formSchema, fieldSchema *AnnotationSchema, opts IterOptions) []FormAnnotation {
+	htmlData, err := os.ReadFile(filepath.Join(s.Folder, page.path))
+	if err != nil {
+		opts.logger().Warn("Cannot read annotation file", "path", page.path, "error", err)
+		return nil
+	}
 
-			if opts.SimplifyFormTypes {
-				if simplified, ok := formSchema.SimplifyMap[tp]; ok {
-					tp = simplified
-				}
-			}
+	doc, err := htmlutil.LoadHTMLString(string(htmlData))
+	if err != nil {
+		return nil
+	}
 
-			if opts.DropNA && tp == formSchema.NAValue {
-				continue
-			}
-			if opts.DropSkipped && tp == formSchema.SkipValue {
-				continue
-			}
+	var annotations []FormAnnotation
+	for idx, form := range htmlutil.GetForms(doc) {
+		if idx >= len(page.info.Forms) {
+			break
+		}
 
-			// Deduplication by form content hash
-			if opts.DropDuplicates {
-				formHTML, _ := form.Html()
-				hash := fmt.Sprintf("%x", md5.Sum([]byte(formHTML)))
-				if seen[hash] {
-					continue
-				}
-				seen[hash] = true
-			}
+		tp, ok := keepFormType(page.info.Forms[idx], formSchema, opts)
+		if !ok {
+			continue
+		}
 
-			// Build field types
-			var fieldTypes, fieldTypesFull map[string]string
-			fieldsAnnotated := false
-			if idx < len(pi.info.VisibleHTMLFields) && pi.info.VisibleHTMLFields[idx] != nil {
-				rawFields := pi.info.VisibleHTMLFields[idx]
-				fieldTypes = make(map[string]string, len(rawFields))
-				fieldTypesFull = make(map[string]string, len(rawFields))
-				allAnnotated := true
-				for name, ftp := range rawFields {
-					if opts.SimplifyFieldTypes {
-						if simplified, ok := fieldSchema.SimplifyMap[ftp]; ok {
-							ftp = simplified
-						}
-					}
-					if ftp == fieldSchema.NAValue {
-						allAnnotated = false
-					}
-					fieldTypes[name] = ftp
-					if full, ok := fieldSchema.TypesInv[ftp]; ok {
-						fieldTypesFull[name] = full
-					} else {
-						fieldTypesFull[name] = ftp
+		// Build field types
+		var fieldTypes, fieldTypesFull map[string]string
+		fieldsAnnotated := false
+		if idx < len(page.info.VisibleHTMLFields) && page.info.VisibleHTMLFields[idx] != nil {
+			rawFields := page.info.VisibleHTMLFields[idx]
+			fieldTypes = make(map[string]string, len(rawFields))
+			fieldTypesFull = make(map[string]string, len(rawFields))
+			allAnnotated := true
+			for name, ftp := range rawFields {
+				if opts.SimplifyFieldTypes {
+					if simplified, ok := fieldSchema.SimplifyMap[ftp]; ok {
+						ftp = simplified
 					}
 				}
-				fieldsAnnotated = allAnnotated && len(rawFields) > 0
-			}
-
-			// Get full form type name
-			typeFull := tp
-			if full, ok := formSchema.TypesInv[tp]; ok {
-				typeFull = full
+				if ftp == fieldSchema.NAValue {
+					allAnnotated = false
+				}
+				fieldTypes[name] = ftp
+				if full, ok := fieldSchema.TypesInv[ftp]; ok {
+					fieldTypesFull[name] = full
+				} else {
+					fieldTypesFull[name] = ftp
+				}
 			}
+			fieldsAnnotated = allAnnotated && len(rawFields) > 0
+		}
 
-			formHTML, _ := form.Html()
-			ann := FormAnnotation{
-				FormHTML:        formHTML,
-				URL:             pi.info.URL,
-				Type:            tp,
-				TypeFull:        typeFull,
-				FormIndex:       idx,
-				FieldTypes:      fieldTypes,
-				FieldTypesFull:  fieldTypesFull,
-				FormSchema:      formSchema,
-				FieldSchema:     fieldSchema,
-				FormAnnotated:   tp != formSchema.NAValue,
-				FieldsAnnotated: fieldsAnnotated,
-			}
-			annotations = append(annotations, ann)
+		// Get full form type name
+		typeFull := tp
+		if full, ok := formSchema.TypesInv[tp]; ok {
+			typeFull = full
 		}
-	}
 
-	return annotations, nil
+		formHTML, _ := form.Html()
+		annotations = append(annotations, FormAnnotation{
+			FormHTML:        formHTML,
+			Hash:            htmlutil.HashFormHTML(formHTML),
+			URL:             page.info.URL,
+			Type:            tp,
+			TypeFull:        typeFull,
+			FormIndex:       idx,
+			Position:        htmlutil.GetFormPosition(form),
+			FieldTypes:      fieldTypes,
+			FieldTypesFull:  fieldTypesFull,
+			FormSchema:      formSchema,
+			FieldSchema:     fieldSchema,
+			FormAnnotated:   tp != formSchema.NAValue,
+			FieldsAnnotated: fieldsAnnotated,
+		})
+	}
+	return annotations
 }
 
 // IterOptions controls annotation iteration behavior.
@@ -252,7 +303,20 @@ type IterOptions struct {
 	DropSkipped        bool
 	SimplifyFormTypes  bool
 	SimplifyFieldTypes bool
+	SimplifyPageTypes  bool
 	Verbose            bool
+	// Workers bounds how many pages are read and parsed concurrently;
+	// 0 uses GOMAXPROCS. Annotations are still yielded in index order.
+	Workers int
+	// Logger receives warnings about unreadable files; nil discards them.
+	Logger *slog.Logger
+}
+
+func (opts IterOptions) logger() *slog.Logger {
+	if opts.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return opts.Logger
 }
 
 // DefaultIterOptions returns the default options for iterating annotations.
@@ -263,6 +327,7 @@ func DefaultIterOptions() IterOptions {
 		DropSkipped:        true,
 		SimplifyFormTypes:  true,
 		SimplifyFieldTypes: true,
+		SimplifyPageTypes:  true,
 	}
 }
 
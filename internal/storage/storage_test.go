@@ -1,6 +1,11 @@
 package storage
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestGetDomain(t *testing.T) {
 	tests := []struct {
@@ -20,3 +25,138 @@ func TestGetDomain(t *testing.T) {
 		}
 	}
 }
+
+func writeTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{
+			"form_types": {"types": [{"full": "login", "short": "l"}, {"full": "search", "short": "s"}], "NA_value": "X", "skip_value": "-"},
+			"field_types": {"types": [{"full": "username", "short": "username"}], "NA_value": "X", "skip_value": "-"}
+		}`,
+		"index.json": `{
+			"a.html": {"url": "http://a.example.org/", "forms": ["l"], "visible_html_fields": [{"user": "username"}]},
+			"b.html": {"url": "http://b.example.net/", "forms": ["s", "X"]}
+		}`,
+		"a.html": `<html><body><form><input name="user"/></form></body></html>`,
+		"b.html": `<html><body><form><input name="q"/></form><form><input name="other"/></form></body></html>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewStorage(dir)
+}
+
+func TestAnnotations(t *testing.T) {
+	s := writeTestStorage(t)
+
+	seq, err := s.Annotations(DefaultIterOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for ann := range seq {
+		types = append(types, ann.TypeFull)
+	}
+	if strings.Join(types, ",") != "login,search" {
+		t.Errorf("annotation types = %v, want [login search]", types)
+	}
+
+	// Stopping early must not visit later pages.
+	count := 0
+	for range seq {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("early break yielded %d annotations, want 1", count)
+	}
+
+	all, err := s.IterAnnotations(DefaultIterOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("IterAnnotations returned %d annotations, want 2", len(all))
+	}
+
+	if _, err := NewStorage(t.TempDir()).Annotations(DefaultIterOptions()); err == nil {
+		t.Error("expected error for missing config")
+	}
+}
+
+func TestPageSchemaCustomTypes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"page_types": {"types": [
+			{"full": "login", "short": "lg"},
+			{"full": "status page", "short": "sp", "url_patterns": ["/status", "/uptime"]},
+			{"full": "pricing", "short": "pc", "url_patterns": ["/pricing"]}
+		], "NA_value": "X", "skip_value": "-", "simplify_map": {"plans": "pc"}}}`,
+		"index.json": `{
+			"a.html": {"url": "https://a.example/status", "page_type": "sp"},
+			"b.html": {"url": "https://b.example/plans", "page_type": "plans"},
+			"c.html": {"url": "https://c.example/settings", "page_type": "st"},
+			"d.html": {"url": "https://d.example/cart", "page_type": "ck"}
+		}`,
+		"a.html": `<html><body>All systems operational</body></html>`,
+		"b.html": `<html><body>Plans</body></html>`,
+		"c.html": `<html><body>Account settings</body></html>`,
+		"d.html": `<html><body>Your cart</body></html>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewPageStorage(dir)
+
+	schema, err := s.GetPageSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"/Status/api": "sp", "/uptime": "sp", "/pricing/teams": "pc", "/login": ""} {
+		if got := schema.TypeForURLPath(path); got != want {
+			t.Errorf("TypeForURLPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if got := (*AnnotationSchema)(nil).TypeForURLPath("/status"); got != "" {
+		t.Errorf("nil schema TypeForURLPath = %q, want empty", got)
+	}
+
+	anns, err := s.IterPageAnnotations(DefaultIterOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, ann := range anns {
+		types = append(types, ann.TypeFull)
+	}
+	if strings.Join(types, ",") != "status page,pricing,settings,checkout" {
+		t.Errorf("page types = %v, want [status page pricing settings checkout] with plans simplified and st and ck built in", types)
+	}
+}
+
+func TestParallelOrdered(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	for _, workers := range []int{1, 4} {
+		i := 0
+		for got := range parallelOrdered(items, workers, func(n int) int { return n * n }) {
+			if got != i*i {
+				t.Fatalf("workers=%d: result %d = %d, want %d", workers, i, got, i*i)
+			}
+			i++
+			if i == 50 {
+				break
+			}
+		}
+		if i != 50 {
+			t.Errorf("workers=%d: got %d results before break, want 50", workers, i)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package store records classification results in a SQLite database so
+// repeated scans can be queried over time.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS pages (
+	id         INTEGER PRIMARY KEY,
+	url        TEXT NOT NULL,
+	scanned_at TEXT NOT NULL,
+	job        TEXT NOT NULL DEFAULT '',
+	status     INTEGER NOT NULL DEFAULT 0,
+	page_type  TEXT NOT NULL DEFAULT '',
+	error      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS pages_url ON pages (url, scanned_at);
+CREATE TABLE IF NOT EXISTS forms (
+	page_id    INTEGER NOT NULL REFERENCES pages (id) ON DELETE CASCADE,
+	form_index INTEGER NOT NULL,
+	form_type  TEXT NOT NULL,
+	PRIMARY KEY (page_id, form_index)
+);
+CREATE TABLE IF NOT EXISTS fields (
+	page_id     INTEGER NOT NULL,
+	form_index  INTEGER NOT NULL,
+	field_index INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	field_type  TEXT NOT NULL,
+	PRIMARY KEY (page_id, form_index, field_index),
+	FOREIGN KEY (page_id, form_index) REFERENCES forms (page_id, form_index) ON DELETE CASCADE
+);
+`
+
+// Store is a SQLite database of classified pages. It is safe for
+// concurrent use.
+type Store struct {
+	db *sql.DB
+}
+
+// Record is one classification of a URL.
+type Record struct {
+	URL       string           `json:"url"`
+	ScannedAt time.Time        `json:"scanned_at"`
+	Job       string           `json:"job,omitempty"` // scan job, if any
+	Status    int              `json:"status,omitempty"`
+	PageType  string           `json:"page_type,omitempty"`
+	Forms     []dit.FormResult `json:"forms,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("open results database: %w", err)
+	}
+	// SQLite allows one writer; a single connection avoids lock errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("create results schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records r with its forms and fields. A zero ScannedAt is set to now.
+func (s *Store) Add(r Record) (err error) {
+	if r.ScannedAt.IsZero() {
+		r.ScannedAt = time.Now()
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(`INSERT INTO pages (url, scanned_at, job, status, page_type, error) VALUES (?, ?, ?, ?, ?, ?)`,
+		r.URL, formatTime(r.ScannedAt), r.Job, r.Status, r.PageType, r.Error)
+	if err != nil {
+		return fmt.Errorf("record page: %w", err)
+	}
+	pageID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for i, form := range r.Forms {
+		if _, err := tx.Exec(`INSERT INTO forms (page_id, form_index, form_type) VALUES (?, ?, ?)`,
+			pageID, i, form.Type); err != nil {
+			return fmt.Errorf("record form: %w", err)
+		}
+		for j, field := range formFields(form) {
+			if _, err := tx.Exec(`INSERT INTO fields (page_id, form_index, field_index, name, field_type) VALUES (?, ?, ?, ?, ?)`,
+				pageID, i, j, field.Name, field.Type); err != nil {
+				return fmt.Errorf("record field: %w", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// formFields returns the form's fields in document order, falling back to
+// the name map for results without a field list.
+func formFields(form dit.FormResult) []dit.Field {
+	if form.FieldList != nil {
+		return form.FieldList
+	}
+	fields := make([]dit.Field, 0, len(form.Fields))
+	for name, typ := range form.Fields {
+		fields = append(fields, dit.Field{Name: name, Type: typ})
+	}
+	return fields
+}
+
+// TypeCount is the number of URLs whose latest classification has a type.
+type TypeCount struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// Summary counts page and form types over the latest record of each URL.
+func (s *Store) Summary() (pages, forms []TypeCount, err error) {
+	pages, err = s.typeCounts(`SELECT page_type, COUNT(*) FROM latest GROUP BY page_type ORDER BY COUNT(*) DESC, page_type`)
+	if err != nil {
+		return nil, nil, err
+	}
+	forms, err = s.typeCounts(`SELECT f.form_type, COUNT(*) FROM latest JOIN forms f ON f.page_id = latest.id
+		GROUP BY f.form_type ORDER BY COUNT(*) DESC, f.form_type`)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pages, forms, nil
+}
+
+// latest selects the most recent record of each URL.
+const latest = `WITH latest AS (
+	SELECT * FROM pages p WHERE p.id = (
+		SELECT id FROM pages q WHERE q.url = p.url ORDER BY scanned_at DESC, id DESC LIMIT 1))
+`
+
+func (s *Store) typeCounts(query string) ([]TypeCount, error) {
+	rows, err := s.db.Query(latest + query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var counts []TypeCount
+	for rows.Next() {
+		var c TypeCount
+		if err := rows.Scan(&c.Type, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// Pages returns the latest record of each URL whose page type or one of
+// whose form types is typ (all URLs if typ is empty), ordered by URL.
+func (s *Store) Pages(typ string) ([]Record, error) {
+	return s.records(latest+`SELECT id, url, scanned_at, job, status, page_type, error FROM latest
+		WHERE ?1 = '' OR page_type = ?1 OR EXISTS (SELECT 1 FROM forms f WHERE f.page_id = latest.id AND f.form_type = ?1)
+		ORDER BY url`, typ)
+}
+
+// History returns every record of url, oldest first.
+func (s *Store) History(url string) ([]Record, error) {
+	return s.records(`SELECT id, url, scanned_at, job, status, page_type, error FROM pages
+		WHERE url = ? ORDER BY scanned_at, id`, url)
+}
+
+// LastPageType returns the page type of the latest successful record of
+// url that has one, and whether there is such a record.
+func (s *Store) LastPageType(url string) (string, bool, error) {
+	var typ string
+	err := s.db.QueryRow(`SELECT page_type FROM pages WHERE url = ? AND error = '' AND page_type != ''
+		ORDER BY scanned_at DESC, id DESC LIMIT 1`, url).Scan(&typ)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return typ, true, nil
+}
+
+// Change is a page type that differs between consecutive records of a URL.
+type Change struct {
+	URL       string    `json:"url"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Changes returns page type changes between consecutive successful
+// records of each URL that have a page type, ordered by URL and time.
+func (s *Store) Changes() ([]Change, error) {
+	rows, err := s.db.Query(`SELECT url, prev_type, page_type, scanned_at FROM (
+			SELECT url, page_type, scanned_at, id,
+				LAG(page_type) OVER (PARTITION BY url ORDER BY scanned_at, id) AS prev_type
+			FROM pages WHERE error = '' AND page_type != '')
+		WHERE prev_type IS NOT NULL AND prev_type != page_type
+		ORDER BY url, scanned_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var at string
+		if err := rows.Scan(&c.URL, &c.From, &c.To, &at); err != nil {
+			return nil, err
+		}
+		if c.ChangedAt, err = parseTime(at); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// records runs a pages query selecting id, url, scanned_at, job, status,
+// page_type, and error, and loads each page's forms and fields.
+func (s *Store) records(query string, args ...any) ([]Record, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var records []Record
+	for rows.Next() {
+		var id int64
+		var r Record
+		var at string
+		if err := rows.Scan(&id, &r.URL, &at, &r.Job, &r.Status, &r.PageType, &r.Error); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if r.ScannedAt, err = parseTime(at); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		records = append(records, r)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		forms, err := s.forms(id)
+		if err != nil {
+			return nil, err
+		}
+		records[i].Forms = forms
+	}
+	return records, nil
+}
+
+// forms loads the forms and fields of one page record.
+func (s *Store) forms(pageID int64) ([]dit.FormResult, error) {
+	rows, err := s.db.Query(`SELECT f.form_index, f.form_type, d.name, d.field_type
+		FROM forms f LEFT JOIN fields d ON d.page_id = f.page_id AND d.form_index = f.form_index
+		WHERE f.page_id = ? ORDER BY f.form_index, d.field_index`, pageID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	var forms []dit.FormResult
+	for rows.Next() {
+		var index int
+		var formType string
+		var name, fieldType sql.NullString
+		if err := rows.Scan(&index, &formType, &name, &fieldType); err != nil {
+			return nil, err
+		}
+		if index >= len(forms) {
+			forms = append(forms, dit.FormResult{Type: formType})
+		}
+		if name.Valid {
+			form := &forms[len(forms)-1]
+			if form.Fields == nil {
+				form.Fields = make(map[string]string)
+			}
+			form.Fields[name.String] = fieldType.String
+			form.FieldList = append(form.FieldList, dit.Field{Name: name.String, Type: fieldType.String})
+		}
+	}
+	return forms, rows.Err()
+}
+
+// Times are stored as fixed-width UTC RFC 3339 text so they sort correctly.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(timeLayout, s)
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/happyhackingspace/dit"
+)
+
+func TestStore(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	login := dit.FormResult{
+		Type:      "login",
+		Fields:    map[string]string{"user": "username", "pass": "password"},
+		FieldList: []dit.Field{{Name: "user", Type: "username"}, {Name: "pass", Type: "password"}},
+	}
+	records := []Record{
+		{URL: "https://a.example/", ScannedAt: t0, PageType: "landing"},
+		{URL: "https://a.example/login", ScannedAt: t0, PageType: "login", Forms: []dit.FormResult{login}},
+		{URL: "https://a.example/", ScannedAt: t0.Add(time.Hour), Job: "j2", PageType: "soft_404"},
+		{URL: "https://a.example/down", ScannedAt: t0.Add(time.Hour), Error: "timeout"},
+	}
+	for _, r := range records {
+		if err := db.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pages, forms, err := db.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || len(forms) != 1 || forms[0] != (TypeCount{"login", 1}) {
+		t.Errorf("summary = %v, %v", pages, forms)
+	}
+
+	logins, err := db.Pages("login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 1 || logins[0].URL != "https://a.example/login" || !logins[0].ScannedAt.Equal(t0) {
+		t.Fatalf("login pages = %+v", logins)
+	}
+	if got := logins[0].Forms; len(got) != 1 || got[0].Type != "login" ||
+		len(got[0].FieldList) != 2 || got[0].FieldList[1] != login.FieldList[1] {
+		t.Errorf("login forms = %+v", got)
+	}
+
+	changes, err := db.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Change{URL: "https://a.example/", From: "landing", To: "soft_404", ChangedAt: t0.Add(time.Hour)}
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+
+	history, err := db.History("https://a.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].PageType != "landing" || history[1].Job != "j2" {
+		t.Errorf("history = %+v", history)
+	}
+
+	// A record without a page type, as from a forms-only model, is not a
+	// change.
+	for _, r := range []Record{
+		{URL: "https://a.example/login", ScannedAt: t0.Add(time.Hour), Forms: []dit.FormResult{login}},
+		{URL: "https://a.example/login", ScannedAt: t0.Add(2 * time.Hour), PageType: "login"},
+	} {
+		if err := db.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changes, err := db.Changes(); err != nil || len(changes) != 1 {
+		t.Errorf("changes after an untyped record = %+v, %v, want only the earlier one", changes, err)
+	}
+
+	for url, want := range map[string]string{
+		"https://a.example/":     "soft_404",
+		"https://a.example/down": "",
+		"https://b.example/":     "",
+	} {
+		got, ok, err := db.LastPageType(url)
+		if err != nil || got != want || ok != (want != "") {
+			t.Errorf("LastPageType(%s) = %q, %v, %v, want %q", url, got, ok, err, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package webhook delivers signed event notifications over HTTP.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types sent by scans.
+const (
+	LoginPageFound        = "login_page_found"
+	ClassificationChanged = "classification_changed"
+	Soft404Detected       = "soft_404_detected"
+)
+
+// Event is the JSON body of a webhook delivery.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	URL  string    `json:"url"`           // page the event is about
+	Job  string    `json:"job,omitempty"` // scan job that observed it
+	Data any       `json:"data,omitempty"`
+}
+
+// Config holds webhook delivery settings.
+type Config struct {
+	URL string
+	// Secret, if set, signs each body with HMAC-SHA256, sent as
+	// "X-Dit-Signature: sha256=<hex>".
+	Secret string
+	// MaxRetries bounds redeliveries after a network error, 429, or 5xx;
+	// 0 means 3. Retries back off exponentially from Backoff (0 means 1s).
+	MaxRetries int
+	Backoff    time.Duration
+	// Client sends deliveries; nil uses a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// queueSize bounds events waiting for delivery; further events are dropped.
+const queueSize = 256
+
+// Sender delivers events in order from a background goroutine, so slow or
+// failing receivers never hold up the caller.
+type Sender struct {
+	cfg    Config
+	queue  chan Event
+	done   chan struct{}
+	once   sync.Once
+	ctx    context.Context // cancelled when Close gives up waiting
+	cancel context.CancelFunc
+}
+
+// New starts a Sender for cfg.
+func New(cfg Config) *Sender {
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	s := &Sender{
+		cfg:   cfg,
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	go s.run()
+	return s
+}
+
+// Send queues e for delivery, stamping its time if unset. It never blocks;
+// events are dropped with a warning when the queue is full. Send must not
+// be called after Close.
+func (s *Sender) Send(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	select {
+	case s.queue <- e:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "type", e.Type, "url", e.URL)
+	}
+}
+
+// Close stops accepting events and waits until queued events are delivered
+// or ctx is done. In the latter case the delivery in progress is aborted,
+// events still queued are dropped, and no further delivery is attempted
+// once Close returns.
+func (s *Sender) Close(ctx context.Context) error {
+	s.once.Do(func() { close(s.queue) })
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-s.done
+		return ctx.Err()
+	}
+}
+
+func (s *Sender) run() {
+	defer close(s.done)
+	defer s.cancel()
+	dropped := 0
+	for e := range s.queue {
+		if s.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		if err := s.deliver(e); err != nil {
+			slog.Warn("Webhook delivery failed", "type", e.Type, "url", e.URL, "error", err)
+		}
+	}
+	if dropped > 0 {
+		slog.Warn("Dropped undelivered webhook events on shutdown", "count", dropped)
+	}
+}
+
+// deliver posts e, retrying transient failures.
+func (s *Sender) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	backoff := s.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(e.Type, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.cfg.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-s.ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (s *Sender) post(eventType string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Dit-Event", eventType)
+	if s.cfg.Secret != "" {
+		req.Header.Set("X-Dit-Signature", Sign(s.cfg.Secret, body))
+	}
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// Sign returns the X-Dit-Signature header value for body, which receivers
+// can recompute to verify a delivery.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
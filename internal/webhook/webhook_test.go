@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSenderSignsAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	var got []Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if sig := r.Header.Get("X-Dit-Signature"); sig != Sign("s3cret", body) {
+			t.Errorf("signature = %q, want %q", sig, Sign("s3cret", body))
+		}
+		if r.Header.Get("X-Dit-Event") != LoginPageFound {
+			t.Errorf("X-Dit-Event = %q", r.Header.Get("X-Dit-Event"))
+		}
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Error(err)
+		}
+		got = append(got, e)
+	}))
+	defer receiver.Close()
+
+	s := New(Config{URL: receiver.URL, Secret: "s3cret", Backoff: time.Millisecond})
+	s.Send(Event{Type: LoginPageFound, URL: "https://example.org/login", Job: "j1"})
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+	if len(got) != 1 || got[0].URL != "https://example.org/login" || got[0].Job != "j1" || got[0].Time.IsZero() {
+		t.Errorf("delivered = %+v", got)
+	}
+}
+
+func TestSenderGivesUpOnClientErrors(t *testing.T) {
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+
+	s := New(Config{URL: receiver.URL, Backoff: time.Millisecond})
+	s.Send(Event{Type: Soft404Detected})
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 for a 400", attempts)
+	}
+}
+
+func TestSenderCloseAbortsRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	s := New(Config{URL: receiver.URL, Backoff: time.Hour})
+	s.Send(Event{Type: LoginPageFound})
+	s.Send(Event{Type: Soft404Detected})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close err = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close took %v, want it to stop waiting on the backoff", elapsed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 before Close aborted the retries", attempts)
+	}
+}
@@ -0,0 +1,116 @@
+package dit
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed labels/*.json
+var labelFiles embed.FS
+
+// Labels maps dit's English type labels (e.g. "login") to display labels
+// (e.g. "connexion"). Labels missing from the table are left unchanged.
+type Labels map[string]string
+
+// LoadLabels returns the label table for a built-in locale ("fr", "de") or,
+// if locale names a .json file, the table stored in that file.
+func LoadLabels(locale string) (Labels, error) {
+	var data []byte
+	var err error
+	if strings.HasSuffix(locale, ".json") {
+		data, err = os.ReadFile(locale)
+	} else {
+		data, err = labelFiles.ReadFile("labels/" + locale + ".json")
+		if err != nil {
+			return nil, fmt.Errorf("dit: unknown labels locale %q", locale)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+
+	var labels Labels
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("dit: parse labels: %w", err)
+	}
+	return labels, nil
+}
+
+// Label returns the display label for a type label.
+func (l Labels) Label(label string) string {
+	if localized, ok := l[label]; ok {
+		return localized
+	}
+	return label
+}
+
+// Forms returns a copy of results with form and field types localized.
+// Field names are left as they appear in the HTML.
+func (l Labels) Forms(results []FormResult) []FormResult {
+	out := make([]FormResult, len(results))
+	for i, r := range results {
+		out[i] = FormResult{FormInfo: r.FormInfo, Type: l.Label(r.Type), CSRFTokens: r.CSRFTokens, Error: r.Error}
+		if r.Fields != nil {
+			out[i].Fields = make(map[string]string, len(r.Fields))
+			for name, tp := range r.Fields {
+				out[i].Fields[name] = l.Label(tp)
+			}
+		}
+		if r.FieldList != nil {
+			out[i].FieldList = make([]Field, len(r.FieldList))
+			for j, f := range r.FieldList {
+				out[i].FieldList[j] = Field{Name: f.Name, Type: l.Label(f.Type), Element: f.Element, Locator: f.Locator, Correction: f.Correction}
+				if f.Correction != nil {
+					out[i].FieldList[j].Correction = &Correction{Template: f.Correction.Template, Original: l.Label(f.Correction.Original)}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// FormsProba returns a copy of results with form and field type
+// probability keys localized.
+func (l Labels) FormsProba(results []FormResultProba) []FormResultProba {
+	out := make([]FormResultProba, len(results))
+	for i, r := range results {
+		out[i] = FormResultProba{FormInfo: r.FormInfo, Type: l.proba(r.Type), CSRFTokens: r.CSRFTokens, Error: r.Error}
+		if r.Fields != nil {
+			out[i].Fields = make(map[string]map[string]float64, len(r.Fields))
+			for name, proba := range r.Fields {
+				out[i].Fields[name] = l.proba(proba)
+			}
+		}
+		if r.FieldList != nil {
+			out[i].FieldList = make([]FieldProba, len(r.FieldList))
+			for j, f := range r.FieldList {
+				out[i].FieldList[j] = FieldProba{Name: f.Name, Type: l.proba(f.Type), Element: f.Element, Locator: f.Locator}
+			}
+		}
+	}
+	return out
+}
+
+// Page returns a copy of result with page, form, and field types localized.
+func (l Labels) Page(result *PageResult) *PageResult {
+	return &PageResult{Type: l.Label(result.Type), Forms: l.Forms(result.Forms), BotChallenge: result.BotChallenge, Parked: result.Parked, Heuristic: result.Heuristic}
+}
+
+// PageProba returns a copy of result with all type probability keys localized.
+func (l Labels) PageProba(result *PageResultProba) *PageResultProba {
+	return &PageResultProba{Type: l.proba(result.Type), Forms: l.FormsProba(result.Forms), BotChallenge: result.BotChallenge, Parked: result.Parked}
+}
+
+func (l Labels) proba(proba map[string]float64) map[string]float64 {
+	if proba == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(proba))
+	for label, p := range proba {
+		out[l.Label(label)] = p
+	}
+	return out
+}
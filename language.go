@@ -0,0 +1,55 @@
+package dit
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// WithLanguageModel routes pages in lang, a lowercase ISO 639-1 code such
+// as "fr", to the models of m, e.g. one trained on French annotations, so
+// that non-English forms are not classified by English-trained models.
+// Pages in other languages, or whose language DetectLanguage cannot tell,
+// keep using the classifier's own models, as do pages in lang for any
+// model m lacks. The options of m are ignored. Save writes the language
+// models along with the classifier's own, so Load restores the routing.
+func WithLanguageModel(lang string, m *Classifier) Option {
+	return func(c *Classifier) {
+		if m == nil || m.models() == nil {
+			return
+		}
+		if c.fc.Languages == nil {
+			c.fc.Languages = make(map[string]*classifier.FormFieldClassifier)
+		}
+		c.fc.Languages[strings.ToLower(lang)] = m.models()
+		c.resetModelVersion()
+	}
+}
+
+// Languages returns the languages the classifier has dedicated models for,
+// sorted.
+func (c *Classifier) Languages() []string {
+	fc := c.models()
+	if fc == nil {
+		return nil
+	}
+	langs := make([]string, 0, len(fc.Languages))
+	for lang := range fc.Languages {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// DetectLanguage returns the language a page is routed by as a lowercase
+// ISO 639-1 code, or "" if it cannot be told. The lang attribute of <html>
+// wins; otherwise the language is guessed from the visible text.
+func DetectLanguage(html string) string {
+	doc, err := htmlutil.LoadHTMLString(html)
+	if err != nil {
+		return ""
+	}
+	return htmlutil.DetectLanguage(doc.Selection)
+}
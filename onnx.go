@@ -0,0 +1,30 @@
+package dit
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExportONNX writes the form type model, and a linear approximation of the
+// field model's CRF, to path as an ONNX model, so the same weights can be
+// served from ONNX runtimes in other languages. The graph takes feature
+// vectors rather than HTML; see classifier.FormFieldClassifier.ExportONNX
+// for its inputs, outputs, and metadata.
+func (c *Classifier) ExportONNX(path string) error {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return ErrNotInitialized
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("dit: %w", err)
+	}
+	if err := fc.ExportONNX(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("dit: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("dit: %w", err)
+	}
+	return nil
+}
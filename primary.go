@@ -0,0 +1,93 @@
+package dit
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// Positional weights applied to a form's type probability when ranking
+// candidates in PrimaryForm.
+const (
+	hiddenFormWeight  = 0.1 // hidden forms are rarely the one a user fills in
+	peripheralWeight  = 0.7 // header, footer, nav, and aside forms
+	mainContentWeight = 1.2 // main and article forms
+	perFieldWeight    = 0.05
+	maxWeightedFields = 10
+)
+
+// PrimaryFormResult is the form chosen by PrimaryForm.
+type PrimaryFormResult struct {
+	// FormResult's Index is the form's position among the page's forms.
+	FormResult
+	Probability float64 `json:"probability"` // probability of the requested type
+	Score       float64 `json:"score"`       // probability after positional weighting
+}
+
+// PrimaryForm returns the most prominent form of the given type on the page,
+// e.g. the main login form when a page also carries a header login widget.
+// Candidates are forms predicted as formType; they are ranked by the type
+// probability weighted by visibility, landmark (main content beats header,
+// footer, nav, and aside), and number of visible fields.
+// Forms are classified in isolation as in ExtractForms: those whose type
+// classification times out or panics are not candidates, and a failure
+// classifying the chosen form's fields is reported in its Error.
+// Returns ErrNoForms if the page has no forms at all, and nil (and no error)
+// if none of its forms is of that type.
+func (c *Classifier) PrimaryForm(html, formType string) (*PrimaryFormResult, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlutil.LoadHTMLString(html)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	fc = fc.ForLanguage(htmlutil.DetectLanguage(doc.Selection))
+
+	forms := htmlutil.GetForms(doc)
+	if len(forms) == 0 {
+		return nil, ErrNoForms
+	}
+	var best *PrimaryFormResult
+	var bestForm *goquery.Selection
+	for _, form := range forms {
+		r := fc.ExtractForm(form, true, 0, false)
+		if r.Error != "" {
+			continue
+		}
+		proba := r.Proba.Form
+		if fc.FormModel.Predict(proba) != formType {
+			continue
+		}
+
+		weight := 1.0
+		if htmlutil.IsHidden(form) {
+			weight *= hiddenFormWeight
+		}
+		switch htmlutil.GetLandmark(form) {
+		case "header", "footer", "nav", "aside":
+			weight *= peripheralWeight
+		case "main", "article":
+			weight *= mainContentWeight
+		}
+		weight *= 1 + perFieldWeight*float64(min(len(htmlutil.GetVisibleFields(form)), maxWeightedFields))
+
+		score := proba[formType] * weight
+		if best == nil || score > best.Score {
+			best = &PrimaryFormResult{Probability: proba[formType], Score: score}
+			bestForm = form
+		}
+	}
+	if best != nil {
+		r := fc.ExtractForm(bestForm, false, 0, true)
+		best.FormResult = newFormResults([]classifier.FormResult{r})[0]
+	}
+	return best, nil
+}
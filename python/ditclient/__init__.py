@@ -0,0 +1,113 @@
+"""Reference Python client for dit.
+
+Runs ``dit run --stdin-jsonl`` as a long-lived subprocess and exchanges one
+JSON object per line with it, so the model is loaded once per process::
+
+    from ditclient import Dit
+
+    with Dit() as dit:
+        page = dit.classify(html=html)
+        print(page["type"], [f["type"] for f in page["forms"]])
+
+        # Formasaurus-style results: [{"form": ..., "fields": {...}}, ...]
+        for info in dit.extract_forms(html):
+            print(info["form"], info["fields"])
+"""
+
+import itertools
+import json
+import subprocess
+import threading
+
+__all__ = ["Dit", "DitError", "LibDit"]
+
+
+class DitError(Exception):
+    """Raised when dit answers a request with an error."""
+
+
+class Dit:
+    """A running ``dit run --stdin-jsonl`` process.
+
+    Requests are answered in order; the client is safe to share between
+    threads, which take turns on the subprocess.
+    """
+
+    def __init__(self, binary="dit", model=None, labels_locale=None, extra_args=()):
+        args = [binary, "run", "--stdin-jsonl", "--silent"]
+        if model:
+            args += ["--model", model]
+        if labels_locale:
+            args += ["--labels-locale", labels_locale]
+        args += list(extra_args)
+        self._proc = subprocess.Popen(
+            args,
+            stdin=subprocess.PIPE,
+            stdout=subprocess.PIPE,
+            text=True,
+            encoding="utf-8",
+            bufsize=1,
+        )
+        self._lock = threading.Lock()
+        self._ids = itertools.count(1)
+
+    def classify(self, html=None, url=None, proba=None, threshold=None, precision=None):
+        """Classify a page given its HTML or URL.
+
+        Returns what ``dit run`` prints: the page type with its forms, or a
+        list of forms if the model has no page classifier.
+        """
+        request = {"id": next(self._ids)}
+        if html is not None:
+            request["html"] = html
+        if url is not None:
+            request["url"] = url
+        if proba is not None:
+            request["proba"] = proba
+        if threshold is not None:
+            request["threshold"] = threshold
+        if precision is not None:
+            request["precision"] = precision
+        response = self._roundtrip(request)
+        if "error" in response:
+            raise DitError(response["error"])
+        return response.get("result")
+
+    def extract_forms(self, html, proba=False, threshold=0.05):
+        """Return Formasaurus-style form info dicts for each form in html.
+
+        Each item is ``{"form": form_type, "fields": {name: field_type}}``,
+        with probability dicts in place of types when proba is set.
+        """
+        result = self.classify(html=html, proba=proba, threshold=threshold)
+        forms = result.get("forms", []) if isinstance(result, dict) else result
+        return [{"form": f["type"], "fields": f.get("fields", {})} for f in forms or []]
+
+    def close(self):
+        """Close stdin and wait for the dit process to exit."""
+        if self._proc.stdin and not self._proc.stdin.closed:
+            self._proc.stdin.close()
+        self._proc.wait()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def _roundtrip(self, request):
+        with self._lock:
+            if self._proc.poll() is not None:
+                raise DitError("dit process exited with code %d" % self._proc.returncode)
+            self._proc.stdin.write(json.dumps(request) + "\n")
+            self._proc.stdin.flush()
+            line = self._proc.stdout.readline()
+        if not line:
+            raise DitError("dit process closed its output")
+        response = json.loads(line)
+        if response.get("id") != request["id"]:
+            raise DitError("response id %r does not match request %r" % (response.get("id"), request["id"]))
+        return response
+
+
+from .lib import LibDit  # noqa: E402  (needs DitError)
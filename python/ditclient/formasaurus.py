@@ -0,0 +1,95 @@
+"""Export a trained Formasaurus model for ``dit model import``.
+
+Writes the form type LogisticRegression, its vectorizer vocabularies, and
+the python-crfsuite field model weights as JSON::
+
+    python -m ditclient.formasaurus dump.json
+    python -m ditclient.formasaurus dump.json --model formasaurus.joblib \\
+        --config formasaurus/data/config.json
+    dit model import --from formasaurus dump.json -o model.json
+
+Runs in the environment Formasaurus is installed in; without ``--model`` it
+exports Formasaurus's default model. ``--config`` takes the annotation
+config.json the model was trained with, so labels stored as short type
+names are imported as full names.
+"""
+
+import argparse
+import json
+
+__all__ = ["export"]
+
+
+def export(classifier, config=None):
+    """Return the dump of a Formasaurus FormFieldClassifier as a dict.
+
+    config is a parsed annotation config.json, or None.
+    """
+    form_model = getattr(classifier.form_classifier, "model", classifier.form_classifier)
+    features, logreg = form_model.steps[0][1], form_model.steps[-1][1]
+    dump = {
+        "form_model": {
+            "classes": [str(c) for c in logreg.classes_],
+            "coef": logreg.coef_.tolist(),
+            "intercept": logreg.intercept_.tolist(),
+            "pipelines": [_pipeline(p) for _, p in features.transformer_list],
+        },
+    }
+    crf = classifier.field_model
+    if crf is not None:
+        dump["field_model"] = {
+            "labels": list(crf.classes_),
+            "state_features": [
+                {"attr": attr, "label": label, "weight": w}
+                for (attr, label), w in crf.state_features_.items()
+            ],
+            "transition_features": [
+                {"from": src, "to": dst, "weight": w}
+                for (src, dst), w in crf.transition_features_.items()
+            ],
+        }
+    if config:
+        for key in ("form_types", "field_types"):
+            dump[key] = {t["short"]: t["full"] for t in config[key]["types"]}
+    return dump
+
+
+def _pipeline(pipeline):
+    extractor, vec = pipeline.steps[0][1], pipeline.steps[-1][1]
+    p = {"extractor": type(extractor).__name__, "vectorizer": type(vec).__name__}
+    if p["vectorizer"] == "DictVectorizer":
+        p["feature_names"] = list(vec.feature_names_)
+        return p
+    p.update(
+        vocabulary={term: int(i) for term, i in vec.vocabulary_.items()},
+        ngram_range=list(vec.ngram_range),
+        binary=bool(vec.binary),
+        analyzer=vec.analyzer,
+        min_df=vec.min_df if isinstance(vec.min_df, int) else 1,
+        stop_words=sorted(vec.get_stop_words() or []),
+    )
+    if p["vectorizer"] == "TfidfVectorizer":
+        p.update(idf=vec.idf_.tolist(), norm=vec.norm, sublinear_tf=bool(vec.sublinear_tf))
+    return p
+
+
+def main():
+    parser = argparse.ArgumentParser(description="Export a Formasaurus model for dit model import.")
+    parser.add_argument("output", help="path to write the JSON dump to")
+    parser.add_argument("--model", help="Formasaurus model file (default: Formasaurus's own model)")
+    parser.add_argument("--config", help="annotation config.json mapping short type names to full ones")
+    args = parser.parse_args()
+
+    from formasaurus.classifiers import FormFieldClassifier, get_instance
+
+    classifier = FormFieldClassifier.load(args.model) if args.model else get_instance()
+    config = None
+    if args.config:
+        with open(args.config) as f:
+            config = json.load(f)
+    with open(args.output, "w") as f:
+        json.dump(export(classifier, config), f)
+
+
+if __name__ == "__main__":
+    main()
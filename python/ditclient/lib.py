@@ -0,0 +1,78 @@
+"""ctypes binding for libdit, dit built as a C shared library.
+
+Calls the Go classifier in-process instead of through a ``dit`` subprocess::
+
+    from ditclient import LibDit
+
+    dit = LibDit("./libdit.so", model="model.json")
+    page = dit.extract_page_type(html)
+    for info in dit.extract_forms_info(html):
+        print(info["form"], info["fields"])
+
+Build the library with
+``go build -buildmode=c-shared -o libdit.so ./cmd/libdit``.
+"""
+
+import ctypes
+import json
+
+from . import DitError
+
+__all__ = ["LibDit"]
+
+
+class LibDit:
+    """A classifier loaded into libdit.
+
+    Calls are safe from several threads at once.
+    """
+
+    def __init__(self, library="libdit.so", model=None):
+        lib = ctypes.CDLL(library)
+        lib.dit_load.argtypes = [ctypes.c_char_p]
+        lib.dit_load.restype = ctypes.c_void_p
+        for name in ("dit_extract_forms", "dit_extract_page_type"):
+            fn = getattr(lib, name)
+            fn.argtypes = [ctypes.c_size_t, ctypes.c_char_p]
+            fn.restype = ctypes.c_void_p
+        lib.dit_close.argtypes = [ctypes.c_size_t]
+        lib.dit_free.argtypes = [ctypes.c_void_p]
+        self._lib = lib
+        self._handle = self._call(lib.dit_load, (model or "").encode("utf-8"))
+
+    def extract_forms(self, html):
+        """Return dit's results for each form in html."""
+        return self._call(self._lib.dit_extract_forms, self._handle, html.encode("utf-8")) or []
+
+    def extract_page_type(self, html):
+        """Return the page type of html with its forms.
+
+        Raises DitError if the model has no page classifier.
+        """
+        return self._call(self._lib.dit_extract_page_type, self._handle, html.encode("utf-8"))
+
+    def extract_forms_info(self, html):
+        """Return Formasaurus-style ``{"form": type, "fields": {...}}`` dicts."""
+        return [{"form": f["type"], "fields": f.get("fields", {})} for f in self.extract_forms(html)]
+
+    def close(self):
+        """Release the classifier; the object is unusable afterwards."""
+        if self._handle:
+            self._lib.dit_close(self._handle)
+            self._handle = 0
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def _call(self, fn, *args):
+        ptr = fn(*args)
+        try:
+            response = json.loads(ctypes.string_at(ptr).decode("utf-8"))
+        finally:
+            self._lib.dit_free(ptr)
+        if "error" in response:
+            raise DitError(response["error"])
+        return response.get("result")
@@ -0,0 +1,89 @@
+package dit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/happyhackingspace/dit/classifier"
+)
+
+// defaultWatchInterval is how often WatchModel checks the model file.
+const defaultWatchInterval = 5 * time.Second
+
+// models returns the classifier's current models. Callers use the result
+// for a whole call, so that a concurrent Reload cannot mix two models.
+func (c *Classifier) models() *classifier.FormFieldClassifier {
+	c.model.RLock()
+	defer c.model.RUnlock()
+	return c.fc
+}
+
+// Reload loads the model file at path, e.g. one just retrained, and swaps
+// it in for the classifier's models, applying again the options the
+// classifier was loaded with. Calls already running finish on the old
+// models; later calls use the new ones. On error the old models are kept.
+// Changes made since loading, such as TuneThresholds, are not carried over.
+func (c *Classifier) Reload(path string) error {
+	next, err := Load(path, c.opts...)
+	if err != nil {
+		return err
+	}
+	c.model.Lock()
+	c.fc = next.fc
+	c.model.Unlock()
+	return nil
+}
+
+// updateModels swaps in a copy of fc, the models the caller snapshotted,
+// changed by update. Calls already running keep the models they started
+// with, as with Reload. It fails if the models were swapped since the
+// snapshot, say by a Reload, as the change was made for the old ones.
+func (c *Classifier) updateModels(fc *classifier.FormFieldClassifier, update func(next *classifier.FormFieldClassifier)) error {
+	next, err := fc.Clone()
+	if err != nil {
+		return fmt.Errorf("dit: %w", err)
+	}
+	update(next)
+	c.model.Lock()
+	defer c.model.Unlock()
+	if c.fc != fc {
+		return errors.New("dit: the models were reloaded during the update; try again")
+	}
+	c.fc = next
+	return nil
+}
+
+// WatchModel reloads the model file at path whenever its modification time
+// or size changes, checking every interval (0 means five seconds), until
+// ctx is done; run it in its own goroutine. onReload, if not nil, is called
+// with the outcome of each reload. Replace the model file atomically, by
+// writing a temporary file in the same directory and renaming it over path;
+// otherwise a reload of a half-written file fails and is reported through
+// onReload. A file that fails to load is tried again once it changes again.
+func (c *Classifier) WatchModel(ctx context.Context, path string, interval time.Duration, onReload func(error)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || (last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size()) {
+			continue
+		}
+		last = info
+		err = c.Reload(path)
+		if onReload != nil {
+			onReload(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package dit
+
+import "math"
+
+// Precision rounds result probabilities to a number of decimal places, so
+// that output diffs between runs only show meaningful changes. JSON
+// encoding already orders map keys, which makes rounded output stable
+// byte for byte. A negative Precision leaves probabilities unchanged.
+type Precision int
+
+// Round rounds p to the precision.
+func (d Precision) Round(p float64) float64 {
+	if d < 0 {
+		return p
+	}
+	scale := math.Pow10(int(d))
+	return math.Round(p*scale) / scale
+}
+
+// FormsProba returns a copy of results with form and field probabilities
+// rounded.
+func (d Precision) FormsProba(results []FormResultProba) []FormResultProba {
+	if d < 0 {
+		return results
+	}
+	out := make([]FormResultProba, len(results))
+	for i, r := range results {
+		out[i] = FormResultProba{FormInfo: r.FormInfo, Type: d.proba(r.Type), CSRFTokens: r.CSRFTokens, Error: r.Error}
+		if r.Fields != nil {
+			out[i].Fields = make(map[string]map[string]float64, len(r.Fields))
+			for name, proba := range r.Fields {
+				out[i].Fields[name] = d.proba(proba)
+			}
+		}
+		if r.FieldList != nil {
+			out[i].FieldList = make([]FieldProba, len(r.FieldList))
+			for j, f := range r.FieldList {
+				out[i].FieldList[j] = FieldProba{Name: f.Name, Type: d.proba(f.Type), Element: f.Element, Locator: f.Locator}
+			}
+		}
+	}
+	return out
+}
+
+// PageProba returns a copy of result with all probabilities rounded.
+func (d Precision) PageProba(result *PageResultProba) *PageResultProba {
+	if d < 0 {
+		return result
+	}
+	return &PageResultProba{Type: d.proba(result.Type), Forms: d.FormsProba(result.Forms), BotChallenge: result.BotChallenge, Parked: result.Parked}
+}
+
+func (d Precision) proba(proba map[string]float64) map[string]float64 {
+	if proba == nil {
+		return nil
+	}
+	out := make(map[string]float64, len(proba))
+	for label, p := range proba {
+		out[label] = d.Round(p)
+	}
+	return out
+}
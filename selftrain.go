@@ -0,0 +1,306 @@
+package dit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+	"github.com/happyhackingspace/dit/internal/storage"
+)
+
+// SelfTrainConfig holds configuration for SelfTrain. TrainConfig applies
+// to every model trained along the way.
+type SelfTrainConfig struct {
+	TrainConfig
+	// Rounds is the number of times the unlabeled pages are pseudo-labeled
+	// and the models retrained; 0 means 3.
+	Rounds int
+	// Threshold is the least probability a form or page type needs to
+	// become a pseudo-label; 0 means 0.95.
+	Threshold float64
+	// HoldOut is the share of annotated sites kept out of training to
+	// evaluate every round; 0 means 0.2.
+	HoldOut float64
+}
+
+// SelfTrainRound reports one round of SelfTrain, measured on the held-out
+// annotated forms and pages.
+type SelfTrainRound struct {
+	Round        int `json:"round"`        // 0 is trained on the annotations alone
+	PseudoForms  int `json:"pseudo_forms"` // pseudo-labeled forms trained on
+	PseudoPages  int `json:"pseudo_pages"`
+	HeldOutForms int `json:"held_out_forms"`
+	HeldOutPages int `json:"held_out_pages"` // 0 without a page model or enough sites
+	// FormAccuracy and PageAccuracy are the shares of held-out forms and
+	// pages typed as annotated.
+	FormAccuracy float64 `json:"form_accuracy"`
+	PageAccuracy float64 `json:"page_accuracy"`
+}
+
+// unlabeledPage is a page of the unlabeled folder, read from path each
+// round rather than kept in memory.
+type unlabeledPage struct {
+	path, url string
+}
+
+// SelfTrain trains on the annotations in dataDir like Train, then
+// repeatedly pseudo-labels the forms and pages of the .html files under
+// unlabeledDir that the latest models type with at least
+// config.Threshold probability, and retrains on the annotations and
+// pseudo-labels together. Pseudo-labels are drawn afresh every round, so
+// a later model can drop an earlier mistake. Page URLs are read from an
+// index.json in unlabeledDir, as written by dit collect, if there is one.
+// Pages are classified as ExtractPageDoc does, with the model for their
+// language and forms that time out or panic skipped.
+//
+// Every round is evaluated on a held-out share of the annotated sites,
+// which no round trains on; unlabeled pages from those sites are not
+// pseudo-labeled either. The returned classifier is trained on all
+// annotations plus the pseudo-labels of the round that scored best there
+// (round 0, without pseudo-labels, if none helped), and the rounds report
+// how each fared.
+func SelfTrain(dataDir, unlabeledDir string, config *SelfTrainConfig) (*Classifier, []SelfTrainRound, error) {
+	if config == nil {
+		config = &SelfTrainConfig{}
+	}
+	if err := config.validate(); err != nil {
+		return nil, nil, err
+	}
+	if config.Threshold < 0 || config.Threshold > 1 {
+		return nil, nil, fmt.Errorf("dit: self-training threshold %g out of range [0, 1]", config.Threshold)
+	}
+	if config.HoldOut < 0 || config.HoldOut >= 1 {
+		return nil, nil, fmt.Errorf("dit: self-training hold-out %g out of range [0, 1)", config.HoldOut)
+	}
+	rounds := config.Rounds
+	if rounds <= 0 {
+		rounds = 3
+	}
+	threshold := config.Threshold
+	if threshold == 0 {
+		threshold = 0.95
+	}
+	holdOut := config.HoldOut
+	if holdOut == 0 {
+		holdOut = 0.2
+	}
+	log := loggerOrDiscard(config.Logger)
+
+	annotations, pageAnnotations, err := loadTrainingData(dataDir, &config.TrainConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	unlabeled, err := loadUnlabeledPages(unlabeledDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(unlabeled) == 0 {
+		return nil, nil, fmt.Errorf("dit: no .html files in %s", unlabeledDir)
+	}
+
+	nFolds := max(2, int(1/holdOut+0.5))
+	formFolds := groupKFold(domainGroups(annotations), nFolds)
+	if len(formFolds) < 2 {
+		return nil, nil, fmt.Errorf("dit: self-training needs annotated forms from at least two sites to hold some out")
+	}
+	heldOut := makeTestSet(len(annotations), formFolds[0])
+	var trainForms, testForms []storage.FormAnnotation
+	for i, a := range annotations {
+		if heldOut[i] {
+			testForms = append(testForms, a)
+		} else {
+			trainForms = append(trainForms, a)
+		}
+	}
+	trainPages, testPages := pageAnnotations, []storage.PageAnnotation(nil)
+	if pageFolds := groupKFold(pageDomainGroups(pageAnnotations), nFolds); len(pageFolds) >= 2 {
+		heldOut := makeTestSet(len(pageAnnotations), pageFolds[0])
+		trainPages = nil
+		for i, a := range pageAnnotations {
+			if heldOut[i] {
+				testPages = append(testPages, a)
+			} else {
+				trainPages = append(trainPages, a)
+			}
+		}
+	}
+
+	unlabeled = excludeSites(unlabeled, testForms, testPages)
+
+	var results []SelfTrainRound
+	var pseudoForms [][]storage.FormAnnotation
+	var pseudoPages [][]storage.PageAnnotation
+	var forms []storage.FormAnnotation
+	var pages []storage.PageAnnotation
+	best := 0
+	for r := 0; r <= rounds; r++ {
+		c, err := trainModels(append(trainForms[:len(trainForms):len(trainForms)], forms...),
+			append(trainPages[:len(trainPages):len(trainPages)], pages...), &config.TrainConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := evaluateSelfTrain(c.fc, testForms, testPages)
+		result.Round, result.PseudoForms, result.PseudoPages = r, len(forms), len(pages)
+		log.Info("Self-training round", "round", r, "pseudo_forms", len(forms), "pseudo_pages", len(pages),
+			"form_accuracy", result.FormAccuracy, "page_accuracy", result.PageAccuracy)
+		results = append(results, result)
+		pseudoForms, pseudoPages = append(pseudoForms, forms), append(pseudoPages, pages)
+		if selfTrainScore(result) > selfTrainScore(results[best]) {
+			best = r
+		}
+		if r < rounds {
+			forms, pages = pseudoLabel(c.fc, unlabeled, threshold)
+		}
+	}
+
+	log.Info("Retraining on all annotations", "round", best, "pseudo_forms", len(pseudoForms[best]), "pseudo_pages", len(pseudoPages[best]))
+	c, err := trainModels(append(annotations[:len(annotations):len(annotations)], pseudoForms[best]...),
+		append(pageAnnotations[:len(pageAnnotations):len(pageAnnotations)], pseudoPages[best]...), &config.TrainConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, results, nil
+}
+
+// selfTrainScore ranks self-training rounds by held-out accuracy.
+func selfTrainScore(r SelfTrainRound) float64 {
+	return r.FormAccuracy + r.PageAccuracy
+}
+
+// loadUnlabeledPages lists the .html and .htm files under dir, with their
+// URLs from dir/index.json if it exists.
+func loadUnlabeledPages(dir string) ([]unlabeledPage, error) {
+	index, _ := storage.NewPageStorage(dir).GetPageIndex()
+	var pages []unlabeledPage
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".html" && ext != ".htm" {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		pages = append(pages, unlabeledPage{path: path, url: index[filepath.ToSlash(rel)].URL})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	return pages, nil
+}
+
+// excludeSites returns the pages of unlabeled that are not from the sites
+// of the held-out annotations.
+func excludeSites(unlabeled []unlabeledPage, testForms []storage.FormAnnotation, testPages []storage.PageAnnotation) []unlabeledPage {
+	heldOut := make(map[string]bool)
+	for _, a := range testForms {
+		if a.URL != "" {
+			heldOut[storage.GetDomain(a.URL)] = true
+		}
+	}
+	for _, a := range testPages {
+		if a.URL != "" {
+			heldOut[storage.GetDomain(a.URL)] = true
+		}
+	}
+	var pages []unlabeledPage
+	for _, page := range unlabeled {
+		if page.url == "" || !heldOut[storage.GetDomain(page.url)] {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// pseudoLabel returns the forms and pages of unlabeled that fc types with
+// at least threshold probability, labeled with those types.
+func pseudoLabel(fc *classifier.FormFieldClassifier, unlabeled []unlabeledPage, threshold float64) ([]storage.FormAnnotation, []storage.PageAnnotation) {
+	var forms []storage.FormAnnotation
+	var pages []storage.PageAnnotation
+	for _, page := range unlabeled {
+		data, err := os.ReadFile(page.path)
+		if err != nil {
+			continue
+		}
+		html := string(data)
+		doc, err := htmlutil.LoadHTMLString(html)
+		if err != nil {
+			continue
+		}
+		formResults, _, pageProba := fc.ExtractPageDoc(doc, page.url, true, 0, false)
+		for i, form := range htmlutil.GetForms(doc) {
+			if formResults[i].Error != "" {
+				continue
+			}
+			label, p := mostProbable(formResults[i].Proba.Form)
+			if p < threshold {
+				continue
+			}
+			formHTML, _ := form.Html()
+			forms = append(forms, storage.FormAnnotation{
+				FormHTML: formHTML, Hash: htmlutil.HashFormHTML(formHTML), URL: page.url, TypeFull: label,
+				FormIndex: i, Position: htmlutil.GetFormPosition(form), FormAnnotated: true,
+			})
+		}
+		if fc.PageModel != nil {
+			if label, p := mostProbable(pageProba.Form); p >= threshold {
+				pages = append(pages, storage.PageAnnotation{HTML: html, URL: page.url, TypeFull: label})
+			}
+		}
+	}
+	return forms, pages
+}
+
+// mostProbable returns the class of proba with the highest probability,
+// the alphabetically first on ties.
+func mostProbable(proba map[string]float64) (string, float64) {
+	best, bestP := "", -1.0
+	for cls, p := range proba {
+		if p > bestP || p == bestP && cls < best {
+			best, bestP = cls, p
+		}
+	}
+	return best, bestP
+}
+
+// evaluateSelfTrain measures fc on the held-out annotations of SelfTrain.
+func evaluateSelfTrain(fc *classifier.FormFieldClassifier, testForms []storage.FormAnnotation, testPages []storage.PageAnnotation) SelfTrainRound {
+	var result SelfTrainRound
+	forms, labels := extractFormTrainingData(testForms)
+	correct := 0
+	for i, form := range forms {
+		if form == nil {
+			continue
+		}
+		result.HeldOutForms++
+		if fc.FormModel.Classify(form) == labels[i] {
+			correct++
+		}
+	}
+	if result.HeldOutForms > 0 {
+		result.FormAccuracy = float64(correct) / float64(result.HeldOutForms)
+	}
+
+	if fc.PageModel == nil || len(testPages) == 0 {
+		return result
+	}
+	docs, formResults, urls, pageLabels := extractPageTrainingData(testPages, fc.FormModel)
+	correct = 0
+	for i, doc := range docs {
+		if fc.PageModel.ClassifyURL(doc, formResults[i], urls[i]) == pageLabels[i] {
+			correct++
+		}
+	}
+	result.HeldOutPages = len(docs)
+	if len(docs) > 0 {
+		result.PageAccuracy = float64(correct) / float64(len(docs))
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package dit
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+var smokeSeed = flag.Int64("smoke.seed", 1, "seed for the randomized form smoke tests")
+
+// randomForm is a synthetic but valid HTML form. Its Generate method lets
+// testing/quick produce forms from the seeded source.
+type randomForm string
+
+var (
+	smokeWords      = []string{"user", "name", "email", "pass", "login", "q", "search", "first", "last", "phone", "city", "zip", "country", "remember", "token", "comment", "ülke", "日本"}
+	smokeInputTypes = []string{"text", "password", "email", "search", "tel", "number", "hidden", "checkbox", "radio", "submit", "file", "date", ""}
+)
+
+func (randomForm) Generate(r *rand.Rand, size int) reflect.Value {
+	word := func() string { return smokeWords[r.Intn(len(smokeWords))] }
+
+	var b strings.Builder
+	b.WriteString("<form")
+	if r.Intn(2) == 0 {
+		fmt.Fprintf(&b, ` method="%s"`, []string{"get", "post", "POST"}[r.Intn(3)])
+	}
+	if r.Intn(2) == 0 {
+		fmt.Fprintf(&b, ` action="/%s"`, word())
+	}
+	b.WriteString(">")
+
+	for i := range r.Intn(size%12 + 1) {
+		id := fmt.Sprintf("f%d", i)
+		name := word()
+		if r.Intn(3) == 0 {
+			name += fmt.Sprint(i)
+		}
+		switch r.Intn(4) {
+		case 0:
+			fmt.Fprintf(&b, `<label for="%s">%s %s</label>`, id, word(), word())
+		case 1:
+			fmt.Fprintf(&b, `<div>%s</div>`, word())
+		}
+		switch r.Intn(6) {
+		case 0:
+			fmt.Fprintf(&b, `<select name="%s" id="%s">`, name, id)
+			for j := range r.Intn(80) {
+				fmt.Fprintf(&b, `<option value="%d">%s %d</option>`, j, word(), j)
+			}
+			b.WriteString("</select>")
+		case 1:
+			fmt.Fprintf(&b, `<textarea name="%s" id="%s" placeholder="%s"></textarea>`, name, id, word())
+		case 2:
+			fmt.Fprintf(&b, `<button type="submit">%s</button>`, word())
+		default:
+			tp := smokeInputTypes[r.Intn(len(smokeInputTypes))]
+			fmt.Fprintf(&b, `<input type="%s" name="%s" id="%s" class="%s"`, tp, name, id, word())
+			if r.Intn(2) == 0 {
+				fmt.Fprintf(&b, ` value="%s"`, word())
+			}
+			b.WriteString("/>")
+		}
+	}
+	b.WriteString("</form>")
+	return reflect.ValueOf(randomForm(b.String()))
+}
+
+func smokeConfig() *quick.Config {
+	return &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(*smokeSeed))}
+}
+
+func TestRandomFormsGenerateDeterministically(t *testing.T) {
+	generate := func() []randomForm {
+		r := rand.New(rand.NewSource(*smokeSeed))
+		forms := make([]randomForm, 20)
+		for i := range forms {
+			forms[i] = randomForm("").Generate(r, 50).Interface().(randomForm)
+		}
+		return forms
+	}
+	if a, b := generate(), generate(); !reflect.DeepEqual(a, b) {
+		t.Fatal("generator output differs for the same seed")
+	}
+}
+
+func TestRandomFormsFeatures(t *testing.T) {
+	property := func(f randomForm) bool {
+		doc, err := htmlutil.LoadHTMLString(string(f))
+		if err != nil {
+			t.Logf("load %s: %v", f, err)
+			return false
+		}
+		for _, form := range htmlutil.GetForms(doc) {
+			fields := htmlutil.GetFieldsToAnnotate(form)
+			feats := classifier.GetFormFeatures(form, "login", fields)
+			if len(feats) != len(fields) {
+				t.Logf("%d feature sets for %d fields in %s", len(feats), len(fields), f)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, smokeConfig()); err != nil {
+		t.Fatalf("seed %d: %v", *smokeSeed, err)
+	}
+}
+
+func TestRandomFormsClassify(t *testing.T) {
+	c := newTestClassifier(t)
+
+	property := func(f randomForm) bool {
+		html := string(f)
+		probas, err := c.ExtractFormsProba(html, 0)
+		if err != nil || len(probas) != 1 {
+			t.Logf("ExtractFormsProba(%s) = %d forms, %v", html, len(probas), err)
+			return false
+		}
+		p := probas[0]
+		if p.Error != "" {
+			t.Logf("form error %q for %s", p.Error, html)
+			return false
+		}
+		if !sumsToOne(p.Type) {
+			t.Logf("form type probabilities %v do not sum to 1 for %s", p.Type, html)
+			return false
+		}
+		for _, field := range p.FieldList {
+			if !sumsToOne(field.Type) {
+				t.Logf("field %q probabilities %v do not sum to 1 for %s", field.Name, field.Type, html)
+				return false
+			}
+		}
+
+		first, err := c.ExtractForms(html)
+		if err != nil {
+			return false
+		}
+		second, _ := c.ExtractForms(html)
+		if !reflect.DeepEqual(first, second) {
+			t.Logf("non-deterministic results for %s", html)
+			return false
+		}
+		return len(first[0].FieldList) == len(p.FieldList)
+	}
+	if err := quick.Check(property, smokeConfig()); err != nil {
+		t.Fatalf("seed %d: %v", *smokeSeed, err)
+	}
+}
+
+func sumsToOne(proba map[string]float64) bool {
+	sum := 0.0
+	for _, p := range proba {
+		if math.IsNaN(p) || p < 0 || p > 1 {
+			return false
+		}
+		sum += p
+	}
+	return math.Abs(sum-1) < 1e-6
+}
@@ -0,0 +1,56 @@
+package dit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ClassifyStream reads JSON Lines records from r, each a Page such as
+// {"url": "https://example.com/login", "html": "<html>..."}, and writes one
+// PageBatchResult line to w per record as soon as it is classified, in
+// input order, so crawlers can pipe pages through without either side
+// holding more than one page in memory. Results are as in
+// ExtractPageTypesBatch; without a page model only forms are classified.
+// A record that is not valid JSON or not HTML gets a result with Error
+// and does not stop the stream; blank lines are skipped. ClassifyStream
+// returns at EOF, or with the first read or write error.
+func (c *Classifier) ClassifyStream(r io.Reader, w io.Writer) error {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return ErrNotInitialized
+	}
+
+	reader := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var result PageBatchResult
+			var page Page
+			if err := json.Unmarshal(line, &page); err != nil {
+				result.Error = fmt.Sprintf("dit: invalid record: %v", err)
+			} else {
+				result = extractBatchPage(fc, page)
+			}
+			if err := enc.Encode(result); err != nil {
+				return fmt.Errorf("dit: write result: %w", err)
+			}
+			// Flush per record so results reach the reader as they are ready.
+			if err := bw.Flush(); err != nil {
+				return fmt.Errorf("dit: write result: %w", err)
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return fmt.Errorf("dit: read record: %w", readErr)
+		}
+	}
+}
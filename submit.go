@@ -0,0 +1,244 @@
+package dit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// NewSubmitRequest builds the request a browser sends when a classified
+// form is submitted: form is a result of ExtractForms (or PrimaryForm) on
+// html, fetched from pageURL, and values maps field names to the values to
+// submit, such as those of the fill package. Fields are collected as the
+// HTML form submission algorithm does, in document order: the form's
+// controls, including those elsewhere on the page that name it in a form
+// attribute, except disabled ones and those in a disabled fieldset. Fields
+// missing from values keep their defaults from the markup, including
+// hidden inputs (e.g. CSRF tokens), checked checkboxes and radios, and
+// selected options; a name in values replaces every default of that name,
+// in the place of the first, and names the form lacks are added at the
+// end. The form's first submit button is the submitter: its name and
+// value are sent, and its formaction, formmethod, and formenctype override
+// the form's. GET forms carry the fields in the action's query, POST forms
+// in a urlencoded or, for multipart/form-data, multipart body.
+//
+// Returns ErrNoForms if html has no form at form.Index.
+func NewSubmitRequest(ctx context.Context, html, pageURL string, form FormInfo, values map[string]string) (*http.Request, error) {
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+	doc, err := htmlutil.LoadHTMLString(html)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	forms := htmlutil.GetForms(doc)
+	if form.Index < 0 || form.Index >= len(forms) {
+		return nil, fmt.Errorf("%w: no form at index %d", ErrNoForms, form.Index)
+	}
+	sel := forms[form.Index]
+
+	fields, submitter := formDefaults(doc, sel)
+	fields = setFormValues(fields, values)
+	enctype := sel.AttrOr("enctype", "")
+	if submitter != nil {
+		if action := htmlutil.ResolveURL(submitter, submitter.AttrOr("formaction", "")); action != "" {
+			form.Action = action
+		}
+		switch method := strings.ToUpper(strings.TrimSpace(submitter.AttrOr("formmethod", ""))); method {
+		case "GET", "POST", "DIALOG":
+			form.Method = method
+		}
+		if v, ok := submitter.Attr("formenctype"); ok {
+			enctype = v
+		}
+	}
+	if form.Method == "DIALOG" {
+		return nil, errors.New("dit: dialog forms close a dialog instead of submitting")
+	}
+	if err := form.ResolveAction(pageURL); err != nil {
+		return nil, err
+	}
+	action, err := url.Parse(form.Action)
+	if err != nil {
+		return nil, fmt.Errorf("dit: resolve action: %w", err)
+	}
+
+	if form.Method != "POST" {
+		action.RawQuery = encodeForm(fields)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, action.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		return req, nil
+	}
+
+	body, contentType := strings.NewReader(encodeForm(fields)), "application/x-www-form-urlencoded"
+	if strings.EqualFold(strings.TrimSpace(enctype), "multipart/form-data") {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, f := range fields {
+			if err := w.WriteField(f.name, f.value); err != nil {
+				return nil, fmt.Errorf("dit: %w", err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		body, contentType = strings.NewReader(buf.String()), w.FormDataContentType()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	return req, nil
+}
+
+// formField is a name and value a form submits.
+type formField struct {
+	name, value string
+}
+
+// formDefaults returns the values form submits untouched, in document
+// order: the successful controls of the HTML form submission algorithm,
+// without file inputs, with the form's first submit button as the
+// submitter. It also returns that button, or nil if the form has none or
+// it is disabled.
+func formDefaults(doc *goquery.Document, form *goquery.Selection) ([]formField, *goquery.Selection) {
+	var fields []formField
+	var submitter *goquery.Selection
+	seenSubmit := false
+	formControls(doc, form).Each(func(_ int, s *goquery.Selection) {
+		name := s.AttrOr("name", "")
+		typ := strings.ToLower(strings.TrimSpace(s.AttrOr("type", "")))
+		tag := goquery.NodeName(s)
+		if tag == "button" && (typ == "" || typ == "submit") || tag == "input" && (typ == "submit" || typ == "image") {
+			// Only the submitter of a submission sends its value.
+			if seenSubmit {
+				return
+			}
+			seenSubmit = true
+			if isDisabledControl(s) {
+				return
+			}
+			submitter = s
+			switch {
+			case typ == "image" && name != "":
+				fields = append(fields, formField{name + ".x", "0"}, formField{name + ".y", "0"})
+			case typ == "image":
+				fields = append(fields, formField{"x", "0"}, formField{"y", "0"})
+			case name != "":
+				fields = append(fields, formField{name, s.AttrOr("value", "")})
+			}
+			return
+		}
+		if name == "" || isDisabledControl(s) {
+			return
+		}
+		switch tag {
+		case "textarea":
+			fields = append(fields, formField{name, s.Text()})
+		case "select":
+			selected := s.Find("option[selected]")
+			if selected.Length() == 0 {
+				if _, multiple := s.Attr("multiple"); multiple {
+					return
+				}
+				selected = s.Find("option").First()
+			}
+			selected.Each(func(_ int, o *goquery.Selection) {
+				fields = append(fields, formField{name, o.AttrOr("value", strings.TrimSpace(o.Text()))})
+			})
+		case "input":
+			switch typ {
+			case "checkbox", "radio":
+				if _, checked := s.Attr("checked"); checked {
+					fields = append(fields, formField{name, s.AttrOr("value", "on")})
+				}
+			case "button", "reset", "file":
+			default:
+				fields = append(fields, formField{name, s.AttrOr("value", "")})
+			}
+		}
+	})
+	return fields, submitter
+}
+
+// formControls returns the controls whose form owner is form, in document
+// order: its descendants, unless their form attribute names another form,
+// and controls elsewhere whose form attribute names its id.
+func formControls(doc *goquery.Document, form *goquery.Selection) *goquery.Selection {
+	id := form.AttrOr("id", "")
+	return doc.Find("input, select, textarea, button").FilterFunction(func(_ int, s *goquery.Selection) bool {
+		if owner, ok := s.Attr("form"); ok {
+			return id != "" && owner == id
+		}
+		closest := s.Closest("form")
+		return closest.Length() > 0 && closest.Nodes[0] == form.Nodes[0]
+	})
+}
+
+// isDisabledControl reports whether s is disabled itself or by a disabled
+// fieldset around it; controls in such a fieldset's first legend stay
+// enabled.
+func isDisabledControl(s *goquery.Selection) bool {
+	if _, ok := s.Attr("disabled"); ok {
+		return true
+	}
+	disabled := false
+	s.ParentsFiltered("fieldset[disabled]").EachWithBreak(func(_ int, fieldset *goquery.Selection) bool {
+		legend := fieldset.ChildrenFiltered("legend").First()
+		disabled = legend.Length() == 0 || !legend.Contains(s.Nodes[0])
+		return !disabled
+	})
+	return disabled
+}
+
+// setFormValues replaces the fields named in values: the first field of
+// each name takes the value and the others are dropped. Names no field
+// has are added at the end, sorted.
+func setFormValues(fields []formField, values map[string]string) []formField {
+	var out []formField
+	set := make(map[string]bool)
+	for _, f := range fields {
+		v, ok := values[f.name]
+		switch {
+		case !ok:
+			out = append(out, f)
+		case !set[f.name]:
+			set[f.name] = true
+			out = append(out, formField{f.name, v})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if !set[name] {
+			out = append(out, formField{name, values[name]})
+		}
+	}
+	return out
+}
+
+// encodeForm encodes fields as application/x-www-form-urlencoded, keeping
+// their order.
+func encodeForm(fields []formField) string {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(url.QueryEscape(f.name))
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(f.value))
+	}
+	return b.String()
+}
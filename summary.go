@@ -0,0 +1,78 @@
+package dit
+
+import (
+	"fmt"
+
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/htmlutil"
+)
+
+// PageSummary condenses page and form classification into a single record.
+type PageSummary struct {
+	// Type is the predicted page type, empty if the model has no page classifier.
+	Type      string         `json:"type,omitempty"`
+	FormCount int            `json:"form_count"`
+	FormTypes map[string]int `json:"form_types,omitempty"` // form type -> number of forms
+	// FailedForms counts forms that panicked or timed out while being
+	// classified (see FormResult.Error); they are left out of FormTypes.
+	FailedForms     int      `json:"failed_forms,omitempty"`
+	HasLogin        bool     `json:"has_login"`
+	HasRegistration bool     `json:"has_registration"`
+	HasSearch       bool     `json:"has_search"`
+	SSOProviders    []string `json:"sso_providers,omitempty"` // e.g. "google", "github"
+	Captchas        []string `json:"captchas,omitempty"`      // e.g. "recaptcha", "turnstile"
+	// DocsFrameworks lists documentation generators and API explorers that
+	// rendered the page, e.g. "swagger-ui", "redoc", "docusaurus".
+	DocsFrameworks []string `json:"docs_frameworks,omitempty"`
+}
+
+// Summarize classifies the page and its forms and returns a one-call summary:
+// page type, form counts by type, whether login/registration/search forms
+// are present, and detected SSO providers, CAPTCHAs, and docs frameworks.
+// Each form is classified in isolation, as by ExtractForms.
+func (c *Classifier) Summarize(html string) (*PageSummary, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	if err := checkHTML(html); err != nil {
+		return nil, err
+	}
+
+	return cached(c, fc, "summary", html, func() (*PageSummary, error) {
+		doc, err := htmlutil.LoadHTMLString(html)
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		fc := fc.ForLanguage(htmlutil.DetectLanguage(doc.Selection))
+
+		results := fc.ExtractFormsDoc(doc, false, 0, false)
+		formResults := make([]classifier.ClassifyResult, 0, len(results))
+		for _, r := range results {
+			if r.Error == "" {
+				formResults = append(formResults, r.Result)
+			}
+		}
+		summary := &PageSummary{
+			FormCount:      len(results),
+			FailedForms:    len(results) - len(formResults),
+			SSOProviders:   htmlutil.GetSSOProviders(doc),
+			Captchas:       htmlutil.GetCaptchaProviders(doc),
+			DocsFrameworks: htmlutil.GetDocsFrameworks(doc),
+		}
+		if len(formResults) > 0 {
+			summary.FormTypes = make(map[string]int)
+		}
+		for _, r := range formResults {
+			summary.FormTypes[r.Form]++
+		}
+		summary.HasLogin = summary.FormTypes["login"] > 0
+		summary.HasRegistration = summary.FormTypes["registration"] > 0
+		summary.HasSearch = summary.FormTypes["search"] > 0
+
+		if fc.PageModel != nil {
+			summary.Type = fc.PageModel.Classify(doc, formResults)
+		}
+		return summary, nil
+	})
+}
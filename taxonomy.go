@@ -0,0 +1,111 @@
+package dit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/happyhackingspace/dit/internal/storage"
+)
+
+// Taxonomy lists the form, field, and page type labels known to a data
+// config or a model.
+type Taxonomy struct {
+	FormTypes  *TypeSet `json:"form_types,omitempty"`
+	FieldTypes *TypeSet `json:"field_types,omitempty"`
+	PageTypes  *TypeSet `json:"page_types,omitempty"`
+}
+
+// TypeSet describes one label set. Short codes, the NA and skip values, and
+// the simplify map come from the data config and are empty for a taxonomy
+// read from a model.
+type TypeSet struct {
+	Types     []TypeLabel `json:"types"`
+	NAValue   string      `json:"na_value,omitempty"`
+	SkipValue string      `json:"skip_value,omitempty"`
+	// SimplifyMap maps annotated short codes to the ones used in training.
+	SimplifyMap map[string]string `json:"simplify_map,omitempty"`
+	// URLPatterns maps type names to the URL path fragments dit collect
+	// seeds and recognizes pages of the type by, from the page config.
+	URLPatterns map[string][]string `json:"url_patterns,omitempty"`
+}
+
+// TypeLabel is a single type: the label dit outputs and its annotation code.
+type TypeLabel struct {
+	Name  string `json:"name"`
+	Short string `json:"short,omitempty"`
+}
+
+// LoadTaxonomy reads the label sets from the data folder's form and page
+// configs. The page config is optional.
+func LoadTaxonomy(dataDir string) (*Taxonomy, error) {
+	store := storage.NewStorage(filepath.Join(dataDir, "forms"))
+	formSchema, err := store.GetFormSchema()
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	fieldSchema, err := store.GetFieldSchema()
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	taxonomy := &Taxonomy{
+		FormTypes:  newSchemaTypeSet(formSchema),
+		FieldTypes: newSchemaTypeSet(fieldSchema),
+	}
+
+	pagesDir := filepath.Join(dataDir, "pages")
+	if _, err := os.Stat(filepath.Join(pagesDir, "config.json")); err == nil {
+		pageSchema, err := storage.NewPageStorage(pagesDir).GetPageSchema()
+		if err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+		taxonomy.PageTypes = newSchemaTypeSet(pageSchema)
+	}
+	return taxonomy, nil
+}
+
+// Taxonomy returns the labels the loaded models can output.
+func (c *Classifier) Taxonomy() (*Taxonomy, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	taxonomy := &Taxonomy{FormTypes: newLabelTypeSet(fc.FormModel.Classes)}
+	if fc.FieldModel != nil && fc.FieldModel.CRF != nil && fc.FieldModel.CRF.Labels != nil {
+		taxonomy.FieldTypes = newLabelTypeSet(fc.FieldModel.CRF.Labels.ToStr)
+	}
+	if fc.PageModel != nil {
+		taxonomy.PageTypes = newLabelTypeSet(fc.PageModel.Classes)
+	}
+	return taxonomy, nil
+}
+
+func newSchemaTypeSet(schema *storage.AnnotationSchema) *TypeSet {
+	ts := &TypeSet{
+		Types:       make([]TypeLabel, 0, len(schema.Types)),
+		NAValue:     schema.NAValue,
+		SkipValue:   schema.SkipValue,
+		SimplifyMap: schema.SimplifyMap,
+	}
+	for full, short := range schema.Types {
+		ts.Types = append(ts.Types, TypeLabel{Name: full, Short: short})
+	}
+	for _, t := range schema.URLPatterns {
+		if ts.URLPatterns == nil {
+			ts.URLPatterns = make(map[string][]string, len(schema.URLPatterns))
+		}
+		ts.URLPatterns[schema.TypesInv[t.Short]] = t.Patterns
+	}
+	sort.Slice(ts.Types, func(i, j int) bool { return ts.Types[i].Name < ts.Types[j].Name })
+	return ts
+}
+
+func newLabelTypeSet(labels []string) *TypeSet {
+	ts := &TypeSet{Types: make([]TypeLabel, len(labels))}
+	for i, label := range labels {
+		ts.Types[i] = TypeLabel{Name: label}
+	}
+	sort.Slice(ts.Types, func(i, j int) bool { return ts.Types[i].Name < ts.Types[j].Name })
+	return ts
+}
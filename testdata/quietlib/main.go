@@ -0,0 +1,31 @@
+// Command quietlib uses dit as a library, for the test checking that the
+// library writes nothing to stderr on its own.
+//
+// Usage: quietlib <data-dir>
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/happyhackingspace/dit"
+)
+
+const html = `<form><input type="text" name="username"/><input type="password" name="password"/><input type="submit" value="Log In"/></form>`
+
+func main() {
+	cl, err := dit.Train(os.Args[1], &dit.TrainConfig{Verbose: true})
+	if err != nil {
+		os.Exit(1)
+	}
+	if _, err := dit.Evaluate(os.Args[1], &dit.EvalConfig{Folds: 2, Verbose: true}); err != nil {
+		os.Exit(2)
+	}
+	forms, err := cl.ExtractForms(html)
+	if err != nil {
+		os.Exit(3)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(forms); err != nil {
+		os.Exit(4)
+	}
+}
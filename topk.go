@@ -0,0 +1,69 @@
+package dit
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Prediction is one candidate type and its probability.
+type Prediction struct {
+	Type  string  `json:"type"`
+	Score float64 `json:"score"`
+}
+
+// FormResultTopK holds the most probable form and field types of a form,
+// each list ordered from most to least probable.
+type FormResultTopK struct {
+	FormInfo
+	Types      []Prediction `json:"types"`
+	FieldList  []FieldTopK  `json:"field_list,omitempty"`
+	CSRFTokens []CSRFToken  `json:"csrf_tokens,omitempty"` // see FormResult.CSRFTokens
+	Error      string       `json:"error,omitempty"`       // see FormResult.Error
+}
+
+// FieldTopK holds the most probable types of a single form field.
+type FieldTopK struct {
+	Name    string       `json:"name"`
+	Types   []Prediction `json:"types"`
+	Element *Element     `json:"element,omitempty"`
+	Locator *Locator     `json:"locator,omitempty"`
+}
+
+// ExtractFormsTopK extracts forms and returns the k most probable form
+// types and field types of each, most probable first; ties are ordered by
+// type name. A k of 0 or less returns every type.
+func (c *Classifier) ExtractFormsTopK(html string, k int) ([]FormResultTopK, error) {
+	results, err := c.ExtractFormsProba(html, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]FormResultTopK, len(results))
+	for i, r := range results {
+		out[i] = FormResultTopK{FormInfo: r.FormInfo, Types: topK(r.Type, k), CSRFTokens: r.CSRFTokens, Error: r.Error}
+		if r.FieldList != nil {
+			out[i].FieldList = make([]FieldTopK, len(r.FieldList))
+			for j, f := range r.FieldList {
+				out[i].FieldList[j] = FieldTopK{Name: f.Name, Types: topK(f.Type, k), Element: f.Element, Locator: f.Locator}
+			}
+		}
+	}
+	return out, nil
+}
+
+func topK(probs map[string]float64, k int) []Prediction {
+	if len(probs) == 0 {
+		return nil
+	}
+	preds := make([]Prediction, 0, len(probs))
+	for typ, p := range probs {
+		preds = append(preds, Prediction{Type: typ, Score: p})
+	}
+	slices.SortFunc(preds, func(a, b Prediction) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Type, b.Type))
+	})
+	if k > 0 && k < len(preds) {
+		preds = preds[:k]
+	}
+	return preds
+}
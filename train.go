@@ -1,27 +1,99 @@
 package dit
 
 import (
+	"cmp"
+	"crypto/sha1"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/happyhackingspace/dit/classifier"
 	"github.com/happyhackingspace/dit/crf"
-	"github.com/happyhackingspace/dit/internal/htmlutil"
+	"github.com/happyhackingspace/dit/htmlutil"
 	"github.com/happyhackingspace/dit/internal/storage"
 )
 
 // TrainConfig holds configuration for training.
 type TrainConfig struct {
 	Verbose bool
+	// ScalePipelines learns per-pipeline scale factors for the form type
+	// model so no single feature pipeline dominates.
+	ScalePipelines bool
+	// PipelineScales fixes scale factors by form feature pipeline name
+	// (e.g. "input names"), overriding learned ones.
+	PipelineScales map[string]float64
+	// Workers bounds concurrent page parsing and feature extraction;
+	// 0 uses GOMAXPROCS.
+	Workers int
+	// Logger receives training progress and warnings. The library never
+	// logs otherwise; nil keeps training silent.
+	Logger *slog.Logger
+	// VocabFrom freezes the vocabulary to that of an earlier model: its
+	// fitted vectorizers and CRF attributes are reused and only weights are
+	// retrained, keeping model diffs small and A/B comparisons like for
+	// like. Features the earlier model never saw are ignored. An ensemble
+	// (see Ensemble) has no vocabulary of its own and is rejected.
+	VocabFrom *Classifier
+	// Calibration fits a CalibrationPlatt or CalibrationIsotonic mapping of
+	// the form and page type probabilities on held-out predictions from
+	// cross-validation, so that they can be used as thresholds; the raw
+	// softmax outputs are over-confident. Empty skips calibration.
+	Calibration string
+	// CalibrationFolds is the number of cross-validation folds used for
+	// calibration; 0 means 5.
+	CalibrationFolds int
+	// ExtraFormPipelines adds custom feature pipelines to the form type
+	// model, e.g. one reading data-testid attributes. Register their
+	// extractors with classifier.RegisterExtractor so that Load can run
+	// them again.
+	ExtraFormPipelines []classifier.FeaturePipeline
+	// FormModel selects the form type learner: FormModelLogReg (the
+	// default when empty) or FormModelGBT, a gradient boosted tree
+	// ensemble over the same features (see classifier.TrainFormTypeGBT).
+	FormModel string
+	// GBT holds the settings of the FormModelGBT learner, such as the
+	// number of rounds and BalanceClasses; zero values take the defaults.
+	// Other form models ignore it.
+	GBT classifier.GBTConfig
+	// LabelSmoothing and TrimFraction make the form and page type models
+	// robust to mislabeled training data, such as auto-labeled crawls;
+	// see classifier.NoiseConfig. LabelSmoothing is in [0, 1), and
+	// TrimFraction, the share of highest-loss examples left out at every
+	// iteration, in [0, 0.5).
+	LabelSmoothing float64
+	TrimFraction   float64
 }
 
+// Form type learners for TrainConfig.FormModel.
+const (
+	FormModelLogReg = "logreg" // logistic regression
+	FormModelGBT    = "gbt"    // gradient boosted trees
+)
+
+// Calibration methods for TrainConfig.Calibration.
+const (
+	CalibrationPlatt    = classifier.CalibrationPlatt    // sigmoid fit; suits small datasets
+	CalibrationIsotonic = classifier.CalibrationIsotonic // monotone fit; needs more data
+)
+
 // EvalConfig holds configuration for evaluation.
 type EvalConfig struct {
 	Folds   int
 	Verbose bool
+	// EndToEnd additionally evaluates the full extraction pipeline, with
+	// predicted form types feeding the field model (see EndToEndResult).
+	EndToEnd bool
+	// Options are applied to the classifier of each end-to-end fold, such
+	// as WithFieldTemplates, so that it classifies as the deployed one
+	// does. Language models given with WithLanguageModel are used as they
+	// are, not retrained per fold.
+	Options []Option
+	// Logger receives warnings; nil keeps evaluation silent.
+	Logger *slog.Logger
 }
 
 // EvalResult holds cross-validation evaluation results.
@@ -46,32 +118,151 @@ type EvalResult struct {
 	PageF1         map[string]float64
 	PageMacroF1    float64
 	PageWeightedF1 float64
+	// EndToEnd is set when EvalConfig.EndToEnd is enabled.
+	EndToEnd *EndToEndResult
+}
+
+// EndToEndResult holds cross-validation results for the full extraction
+// pipeline. Each fold's models are trained together as by Train, and
+// held-out forms and pages are classified as by ExtractForms and
+// ExtractPageType, so field accuracy is measured under predicted rather
+// than gold form types and page accuracy under predicted form types.
+type EndToEndResult struct {
+	FormAccuracy     float64
+	FieldAccuracy    float64
+	SequenceAccuracy float64
+	PageAccuracy     float64
+	FormCorrect      int
+	FormTotal        int
+	FieldCorrect     int
+	FieldTotal       int
+	SequenceCorrect  int
+	SequenceTotal    int
+	PageCorrect      int // zero without page annotations
+	PageTotal        int
 }
 
 // Train trains a classifier on annotated HTML forms in the given data directory.
 func Train(dataDir string, config *TrainConfig) (*Classifier, error) {
-	verbose := false
-	if config != nil {
-		verbose = config.Verbose
+	if config == nil {
+		config = &TrainConfig{}
 	}
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	annotations, pageAnnotations, err := loadTrainingData(dataDir, config)
+	if err != nil {
+		return nil, err
+	}
+	return trainModels(annotations, pageAnnotations, config)
+}
 
+// validate checks the options of config that Train would otherwise only
+// trip over after loading the data.
+func (config *TrainConfig) validate() error {
+	switch config.Calibration {
+	case "", CalibrationPlatt, CalibrationIsotonic:
+	default:
+		return fmt.Errorf("dit: unknown calibration method %q (want %s or %s)", config.Calibration, CalibrationPlatt, CalibrationIsotonic)
+	}
+	switch config.FormModel {
+	case "", FormModelLogReg, FormModelGBT:
+	default:
+		return fmt.Errorf("dit: unknown form model %q (want %s or %s)", config.FormModel, FormModelLogReg, FormModelGBT)
+	}
+	if config.GBT.Rounds < 0 || config.GBT.MaxDepth < 0 || config.GBT.LearningRate < 0 || config.GBT.Lambda < 0 || config.GBT.MinChildWeight < 0 {
+		return fmt.Errorf("dit: negative gradient boosted tree setting in %+v", config.GBT)
+	}
+	if config.VocabFrom != nil {
+		if fc := config.VocabFrom.models(); fc != nil && fc.FormModel != nil && len(fc.FormModel.Ensemble) > 0 {
+			return fmt.Errorf("dit: cannot freeze the vocabulary of an ensemble; use one of its members as VocabFrom")
+		}
+	}
+	if config.LabelSmoothing < 0 || config.LabelSmoothing >= 1 {
+		return fmt.Errorf("dit: label smoothing %g out of range [0, 1)", config.LabelSmoothing)
+	}
+	if config.TrimFraction < 0 || config.TrimFraction >= 0.5 {
+		return fmt.Errorf("dit: trim fraction %g out of range [0, 0.5)", config.TrimFraction)
+	}
+	return nil
+}
+
+// loadTrainingData reads the form annotations in dataDir/forms and, if
+// there is a page index, the page annotations in dataDir/pages. Pages that
+// fail to load are logged and skipped, as the page model is optional.
+func loadTrainingData(dataDir string, config *TrainConfig) ([]storage.FormAnnotation, []storage.PageAnnotation, error) {
 	store := storage.NewStorage(filepath.Join(dataDir, "forms"))
 	opts := storage.DefaultIterOptions()
-	opts.Verbose = verbose
+	opts.Verbose = config.Verbose
+	opts.Workers = config.Workers
+	opts.Logger = config.Logger
 	annotations, err := store.IterAnnotations(opts)
 	if err != nil {
-		return nil, fmt.Errorf("dit: %w", err)
+		return nil, nil, fmt.Errorf("dit: %w", err)
 	}
 	if len(annotations) == 0 {
-		return nil, fmt.Errorf("dit: no annotations found in %s", dataDir)
+		return nil, nil, fmt.Errorf("%w in %s", ErrNoAnnotations, dataDir)
+	}
+
+	pagesDir := filepath.Join(dataDir, "pages")
+	if _, err := os.Stat(filepath.Join(pagesDir, "index.json")); err != nil {
+		return annotations, nil, nil
+	}
+	pageOpts := storage.DefaultIterOptions()
+	pageOpts.Verbose = config.Verbose
+	pageOpts.Logger = config.Logger
+	pageAnnotations, err := storage.NewPageStorage(pagesDir).IterPageAnnotations(pageOpts)
+	if err != nil {
+		loggerOrDiscard(config.Logger).Warn("Failed to load page annotations", "error", err)
+		return annotations, nil, nil
 	}
+	return annotations, pageAnnotations, nil
+}
+
+// trainModels trains the form, field, and page models of Train on the
+// given annotations; without page annotations there is no page model.
+func trainModels(annotations []storage.FormAnnotation, pageAnnotations []storage.PageAnnotation, config *TrainConfig) (*Classifier, error) {
+	verbose := config.Verbose
+	log := loggerOrDiscard(config.Logger)
+	noise := classifier.NoiseConfig{LabelSmoothing: config.LabelSmoothing, TrimFraction: config.TrimFraction}
+	calibrationFolds := cmp.Or(config.CalibrationFolds, 5)
+	var err error
 
 	// Train form type classifier
 	formAnnotations := filterFormAnnotated(annotations)
 	forms, formLabels := extractFormTrainingData(formAnnotations)
 	formConfig := classifier.DefaultFormTypeTrainConfig()
 	formConfig.Verbose = verbose
+	formConfig.ScalePipelines = config.ScalePipelines
+	formConfig.PipelineScales = config.PipelineScales
+	formConfig.Workers = config.Workers
+	formConfig.ExtraPipelines = config.ExtraFormPipelines
+	formConfig.Noise = noise
+	if config.FormModel == FormModelGBT {
+		gbt := config.GBT
+		formConfig.Trees = &gbt
+	}
+	var vocab classifier.FormFieldClassifier
+	if config.VocabFrom != nil {
+		if fc := config.VocabFrom.models(); fc != nil {
+			vocab = *fc
+		}
+	}
+	if vocab.FormModel != nil {
+		formConfig.Vocab = vocab.FormModel.Pipelines
+	}
 	formModel := classifier.TrainFormType(forms, formLabels, formConfig)
+	if config.Calibration != "" {
+		log.Info("Calibrating form type probabilities", "method", config.Calibration, "folds", calibrationFolds)
+		foldConfig := formConfig
+		foldConfig.Verbose = false
+		probas, labels := heldOutFormProbas(formAnnotations, calibrationFolds, func(forms []*goquery.Selection, labels []string) *classifier.FormTypeModel {
+			return classifier.TrainFormType(forms, labels, foldConfig)
+		})
+		if formModel.Calibration, err = classifier.FitCalibration(config.Calibration, probas, labels); err != nil {
+			return nil, fmt.Errorf("dit: %w", err)
+		}
+	}
 
 	// Train field type classifier
 	fieldAnnotations := filterFieldAnnotated(annotations)
@@ -80,25 +271,33 @@ func Train(dataDir string, config *TrainConfig) (*Classifier, error) {
 		crfSequences, _ := buildCRFSequences(fieldAnnotations)
 		crfConfig := crf.DefaultTrainerConfig()
 		crfConfig.Verbose = verbose
+		crfConfig.Logger = config.Logger
+		if vocab.FieldModel != nil {
+			crfConfig.Attributes = vocab.FieldModel.CRF.Attributes
+		}
 		fieldModel = classifier.TrainFieldType(crfSequences, crfConfig)
 	}
 
 	// Train page type classifier (if page data exists)
 	var pageModel *classifier.PageTypeModel
-	pagesDir := filepath.Join(dataDir, "pages")
-	if _, err := os.Stat(filepath.Join(pagesDir, "index.json")); err == nil {
-		pageStore := storage.NewPageStorage(pagesDir)
-		pageOpts := storage.DefaultIterOptions()
-		pageOpts.Verbose = verbose
-		pageAnnotations, err := pageStore.IterPageAnnotations(pageOpts)
-		if err != nil {
-			slog.Warn("Failed to load page annotations", "error", err)
-		} else if len(pageAnnotations) > 0 {
-			slog.Info("Training page type classifier", "annotations", len(pageAnnotations))
-			docs, formResults, urls, labels := extractPageTrainingData(pageAnnotations, formModel)
-			pageConfig := classifier.DefaultPageTypeTrainConfig()
-			pageConfig.Verbose = verbose
-			pageModel = classifier.TrainPageType(docs, formResults, urls, labels, pageConfig)
+	if len(pageAnnotations) > 0 {
+		log.Info("Training page type classifier", "annotations", len(pageAnnotations))
+		docs, formResults, urls, labels := extractPageTrainingData(pageAnnotations, formModel)
+		pageConfig := classifier.DefaultPageTypeTrainConfig()
+		pageConfig.Verbose = verbose
+		pageConfig.Noise = noise
+		if vocab.PageModel != nil {
+			pageConfig.Vocab = vocab.PageModel.Pipelines
+		}
+		pageModel = classifier.TrainPageType(docs, formResults, urls, labels, pageConfig)
+		if config.Calibration != "" {
+			log.Info("Calibrating page type probabilities", "method", config.Calibration, "folds", calibrationFolds)
+			foldConfig := pageConfig
+			foldConfig.Verbose = false
+			probas, heldOutLabels := heldOutPageProbas(pageAnnotations, docs, formResults, urls, labels, calibrationFolds, foldConfig)
+			if pageModel.Calibration, err = classifier.FitCalibration(config.Calibration, probas, heldOutLabels); err != nil {
+				return nil, fmt.Errorf("dit: %w", err)
+			}
 		}
 	}
 
@@ -110,29 +309,42 @@ func Train(dataDir string, config *TrainConfig) (*Classifier, error) {
 	return &Classifier{fc: fc}, nil
 }
 
+// loggerOrDiscard returns l, or a logger discarding everything if l is nil,
+// so the library never writes through the global slog default.
+func loggerOrDiscard(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return l
+}
+
 // Evaluate runs cross-validation evaluation on annotated data.
 func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 	nFolds := 10
 	verbose := false
+	var logger *slog.Logger
 	if config != nil {
 		if config.Folds > 0 {
 			nFolds = config.Folds
 		}
 		verbose = config.Verbose
+		logger = config.Logger
 	}
 
 	store := storage.NewStorage(filepath.Join(dataDir, "forms"))
 	opts := storage.DefaultIterOptions()
 	opts.Verbose = verbose
+	opts.Logger = logger
 	annotations, err := store.IterAnnotations(opts)
 	if err != nil {
 		return nil, fmt.Errorf("dit: %w", err)
 	}
 	if len(annotations) == 0 {
-		return nil, fmt.Errorf("dit: no annotations found in %s", dataDir)
+		return nil, fmt.Errorf("%w in %s", ErrNoAnnotations, dataDir)
 	}
 
 	result := &EvalResult{}
+	log := loggerOrDiscard(logger)
 
 	// Evaluate form types
 	formAnnotations := filterFormAnnotated(annotations)
@@ -140,6 +352,7 @@ func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 		forms, labels := extractFormTrainingData(formAnnotations)
 		groups := domainGroups(formAnnotations)
 		folds := groupKFold(groups, nFolds)
+		warnFewerFolds(log, "forms", folds, nFolds)
 
 		for _, testIdx := range folds {
 			testSet := makeTestSet(len(forms), testIdx)
@@ -162,8 +375,9 @@ func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 	fieldAnnotations := filterFieldAnnotated(annotations)
 	if len(fieldAnnotations) > 0 {
 		sequences, keptAnnotations := buildCRFSequences(fieldAnnotations)
-		groups := domainGroups(keptAnnotations)
+		groups := fieldGroups(keptAnnotations)
 		folds := groupKFold(groups, nFolds)
+		warnFewerFolds(log, "fields", folds, nFolds)
 
 		for _, testIdx := range folds {
 			testSet := makeTestSet(len(sequences), testIdx)
@@ -204,18 +418,21 @@ func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 	}
 
 	// Evaluate page types (if page data exists)
+	var pageAnnotations []storage.PageAnnotation
 	pagesDir := filepath.Join(dataDir, "pages")
 	if _, err := os.Stat(filepath.Join(pagesDir, "index.json")); err == nil {
 		pageStore := storage.NewPageStorage(pagesDir)
 		pageOpts := storage.DefaultIterOptions()
 		pageOpts.Verbose = verbose
-		pageAnnotations, err := pageStore.IterPageAnnotations(pageOpts)
+		pageOpts.Logger = logger
+		pageAnnotations, err = pageStore.IterPageAnnotations(pageOpts)
 		if err != nil {
-			slog.Warn("Failed to load page annotations for evaluation", "error", err)
+			loggerOrDiscard(logger).Warn("Failed to load page annotations for evaluation", "error", err)
 		} else if len(pageAnnotations) > 0 {
 			// Train form model once for form feature extraction
 			formStore := storage.NewStorage(filepath.Join(dataDir, "forms"))
 			formOpts := storage.DefaultIterOptions()
+			formOpts.Logger = logger
 			formAnns, _ := formStore.IterAnnotations(formOpts)
 			formAnnotated := filterFormAnnotated(formAnns)
 			trainForms, trainFormLabels := extractFormTrainingData(formAnnotated)
@@ -230,6 +447,7 @@ func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 
 			groups := pageDomainGroups(pageAnnotations)
 			folds := groupKFold(groups, nFolds)
+			warnFewerFolds(log, "pages", folds, nFolds)
 
 			result.PageConfusion = make(map[string]map[string]int)
 			classSet := make(map[string]bool)
@@ -264,9 +482,117 @@ func Evaluate(dataDir string, config *EvalConfig) (*EvalResult, error) {
 		}
 	}
 
+	if config != nil && config.EndToEnd {
+		result.EndToEnd = evaluateEndToEnd(annotations, pageAnnotations, nFolds, config.Options)
+	}
+
 	return result, nil
 }
 
+// evaluateEndToEnd cross-validates the extraction pipeline on forms
+// annotated with both form and field types, grouped like the field model
+// folds, and on pages, grouped by domain. Each fold trains its models as
+// Train does on the rest, applies opts, and classifies the held-out forms
+// with ExtractForm and the held-out pages with ExtractPageDoc. Returns nil
+// if there are no such forms.
+func evaluateEndToEnd(annotations []storage.FormAnnotation, pageAnnotations []storage.PageAnnotation, nFolds int, opts []Option) *EndToEndResult {
+	var annotated []storage.FormAnnotation
+	for _, a := range annotations {
+		if a.FormAnnotated && a.FieldsAnnotated {
+			annotated = append(annotated, a)
+		}
+	}
+	sequences, kept := buildCRFSequences(annotated)
+	if len(kept) == 0 {
+		return nil
+	}
+	forms, labels := extractFormTrainingData(kept)
+	folds := groupKFold(fieldGroups(kept), nFolds)
+	pageFolds := groupKFold(pageDomainGroups(pageAnnotations), len(folds))
+
+	result := &EndToEndResult{}
+	for fold, testIdx := range folds {
+		testSet := makeTestSet(len(kept), testIdx)
+		var trainAnns []storage.FormAnnotation
+		for i, a := range kept {
+			if !testSet[i] {
+				trainAnns = append(trainAnns, a)
+			}
+		}
+		var testPages []int
+		if fold < len(pageFolds) {
+			testPages = pageFolds[fold]
+		}
+		testPageSet := makeTestSet(len(pageAnnotations), testPages)
+		var trainPages []storage.PageAnnotation
+		for i, a := range pageAnnotations {
+			if !testPageSet[i] {
+				trainPages = append(trainPages, a)
+			}
+		}
+
+		c, err := trainModels(trainAnns, trainPages, &TrainConfig{})
+		if err != nil {
+			continue
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+		fc := c.fc
+
+		for _, idx := range testIdx {
+			pred := fc.ExtractForm(forms[idx], false, 0, true).Result
+			if pred.Form == labels[idx] {
+				result.FormCorrect++
+			}
+			result.FormTotal++
+
+			allCorrect := true
+			for j, gold := range sequences[idx].Labels {
+				if j < len(pred.FieldList) && pred.FieldList[j].Type == gold {
+					result.FieldCorrect++
+				} else {
+					allCorrect = false
+				}
+				result.FieldTotal++
+			}
+			if allCorrect {
+				result.SequenceCorrect++
+			}
+			result.SequenceTotal++
+		}
+
+		if fc.PageModel == nil {
+			continue
+		}
+		for _, idx := range testPages {
+			ann := pageAnnotations[idx]
+			doc, err := htmlutil.LoadHTMLString(ann.HTML)
+			if err != nil {
+				continue
+			}
+			_, pred, _ := fc.ExtractPageDoc(doc, ann.URL, false, 0, false)
+			if pred.Form == ann.TypeFull {
+				result.PageCorrect++
+			}
+			result.PageTotal++
+		}
+	}
+	if result.FormTotal > 0 {
+		result.FormAccuracy = float64(result.FormCorrect) / float64(result.FormTotal)
+	}
+	if result.FieldTotal > 0 {
+		result.FieldAccuracy = float64(result.FieldCorrect) / float64(result.FieldTotal)
+	}
+	if result.SequenceTotal > 0 {
+		result.SequenceAccuracy = float64(result.SequenceCorrect) / float64(result.SequenceTotal)
+	}
+	if result.PageTotal > 0 {
+		result.PageAccuracy = float64(result.PageCorrect) / float64(result.PageTotal)
+	}
+	return result
+}
+
 // --- private helpers (moved from cmd/dit/main.go) ---
 
 func filterFormAnnotated(annotations []storage.FormAnnotation) []storage.FormAnnotation {
@@ -294,7 +620,8 @@ func extractFormTrainingData(annotations []storage.FormAnnotation) ([]*goquery.S
 	labels := make([]string, len(annotations))
 
 	for i, ann := range annotations {
-		doc, err := htmlutil.LoadHTMLString("<form>" + ann.FormHTML + "</form>")
+		// Restore the form's page position so position features are trainable.
+		doc, err := htmlutil.LoadHTMLString(ann.Position.Wrap("<form>" + ann.FormHTML + "</form>"))
 		if err != nil {
 			continue
 		}
@@ -351,23 +678,39 @@ func buildCRFSequences(annotations []storage.FormAnnotation) ([]crf.TrainingSequ
 	return sequences, kept
 }
 
+// groupKFold splits item indices into nFolds folds, keeping items of the
+// same group in one fold. With fewer groups than nFolds there is one fold
+// per group.
 func groupKFold(groups []int, nFolds int) [][]int {
-	uniqueGroups := make(map[int]bool)
+	sizes := make(map[int]int)
 	for _, g := range groups {
-		uniqueGroups[g] = true
+		sizes[g]++
 	}
-	sortedGroups := make([]int, 0, len(uniqueGroups))
-	for g := range uniqueGroups {
+	sortedGroups := make([]int, 0, len(sizes))
+	for g := range sizes {
 		sortedGroups = append(sortedGroups, g)
 	}
+	// Largest groups first, each into the fold with the fewest items so
+	// far, so that one big group does not leave the folds lopsided.
+	slices.SortFunc(sortedGroups, func(a, b int) int {
+		return cmp.Or(cmp.Compare(sizes[b], sizes[a]), cmp.Compare(a, b))
+	})
 
 	if nFolds > len(sortedGroups) {
 		nFolds = len(sortedGroups)
 	}
 
 	groupToFold := make(map[int]int)
-	for i, g := range sortedGroups {
-		groupToFold[g] = i % nFolds
+	foldSizes := make([]int, nFolds)
+	for _, g := range sortedGroups {
+		fold := 0
+		for f := range foldSizes {
+			if foldSizes[f] < foldSizes[fold] {
+				fold = f
+			}
+		}
+		groupToFold[g] = fold
+		foldSizes[fold] += sizes[g]
 	}
 
 	folds := make([][]int, nFolds)
@@ -378,6 +721,14 @@ func groupKFold(groups []int, nFolds int) [][]int {
 	return folds
 }
 
+// warnFewerFolds logs when groupKFold returned fewer folds than asked
+// for, because the items of what fall into fewer groups than nFolds.
+func warnFewerFolds(log *slog.Logger, what string, folds [][]int, nFolds int) {
+	if len(folds) < nFolds {
+		log.Warn("Fewer cross-validation folds than requested", "data", what, "folds", len(folds), "requested", nFolds)
+	}
+}
+
 func domainGroups(annotations []storage.FormAnnotation) []int {
 	groups := make([]int, len(annotations))
 	domainMap := make(map[string]int)
@@ -391,6 +742,110 @@ func domainGroups(annotations []storage.FormAnnotation) []int {
 	return groups
 }
 
+// minTemplateFields is the number of named inputs a form needs before its
+// template signature is used for grouping; tiny forms (a lone "q" search
+// box) look alike across unrelated sites.
+const minTemplateFields = 3
+
+// maxTemplateDomains is the number of domains a template signature may be
+// seen on and still be used for grouping. Signatures on more domains come
+// from widely deployed software (the WordPress login form, say) and would
+// join most of the data into one group.
+const maxTemplateDomains = 5
+
+// fieldGroups assigns CV groups for the field model. Forms are grouped
+// together when they share a domain, a page URL, or a template signature
+// (the same ordered input names and types) seen on at most
+// maxTemplateDomains domains, so templated forms reused across sites cannot
+// straddle train and test folds. Forms without a URL are grouped by
+// template only.
+func fieldGroups(annotations []storage.FormAnnotation) []int {
+	templates := make([]string, len(annotations))
+	templateDomains := make(map[string]map[string]bool)
+	for i, ann := range annotations {
+		templates[i] = formTemplate(ann.FormHTML)
+		if templates[i] == "" {
+			continue
+		}
+		if templateDomains[templates[i]] == nil {
+			templateDomains[templates[i]] = make(map[string]bool)
+		}
+		templateDomains[templates[i]][storage.GetDomain(ann.URL)] = true
+	}
+
+	keys := make([][]string, len(annotations))
+	for i, ann := range annotations {
+		if ann.URL != "" {
+			keys[i] = []string{"domain:" + storage.GetDomain(ann.URL), "page:" + ann.URL}
+		}
+		if tmpl := templates[i]; tmpl != "" && len(templateDomains[tmpl]) <= maxTemplateDomains {
+			keys[i] = append(keys[i], "template:"+tmpl)
+		}
+	}
+	return connectedGroups(keys)
+}
+
+// formTemplate returns a signature of the form's inputs (tag, type, and name
+// in document order, hidden inputs included), or "" for forms with fewer
+// than minTemplateFields named inputs.
+func formTemplate(formHTML string) string {
+	doc, err := htmlutil.LoadHTMLString("<form>" + formHTML + "</form>")
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	doc.Find("form").First().Find("input, select, textarea").Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		if name == "" {
+			return
+		}
+		tp, _ := s.Attr("type")
+		parts = append(parts, goquery.NodeName(s)+":"+strings.ToLower(tp)+":"+name)
+	})
+	if len(parts) < minTemplateFields {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join(parts, "\n"))))
+}
+
+// connectedGroups assigns group IDs so that items sharing any key end up in
+// the same group (connected components via union-find).
+func connectedGroups(keys [][]string) []int {
+	parent := make([]int, len(keys))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	owner := make(map[string]int)
+	for i, itemKeys := range keys {
+		for _, k := range itemKeys {
+			if j, ok := owner[k]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				owner[k] = i
+			}
+		}
+	}
+
+	groups := make([]int, len(keys))
+	ids := make(map[int]int)
+	for i := range keys {
+		root := find(i)
+		if _, ok := ids[root]; !ok {
+			ids[root] = len(ids)
+		}
+		groups[i] = ids[root]
+	}
+	return groups
+}
+
 func makeTestSet(n int, testIdx []int) []bool {
 	set := make([]bool, n)
 	for _, i := range testIdx {
@@ -528,6 +983,26 @@ func computeMetrics(confusion map[string]map[string]int, classes []string) (prec
 	return
 }
 
+// heldOutPageProbas is heldOutFormProbas for page types.
+func heldOutPageProbas(annotations []storage.PageAnnotation, docs []*goquery.Document, formResults [][]classifier.ClassifyResult, urls, labels []string, nFolds int, config classifier.PageTypeTrainConfig) ([]map[string]float64, []string) {
+	folds := groupKFold(pageDomainGroups(annotations), nFolds)
+	var probas []map[string]float64
+	var heldOutLabels []string
+	for _, testIdx := range folds {
+		testSet := makeTestSet(len(docs), testIdx)
+		trainDocs, trainFormResults, trainURLs, trainLabels := filterPageByIndex(docs, formResults, urls, labels, testSet, false)
+		if len(trainDocs) == 0 {
+			continue
+		}
+		model := classifier.TrainPageType(trainDocs, trainFormResults, trainURLs, trainLabels, config)
+		for _, idx := range testIdx {
+			probas = append(probas, model.ClassifyProbaURL(docs[idx], formResults[idx], urls[idx]))
+			heldOutLabels = append(heldOutLabels, labels[idx])
+		}
+	}
+	return probas, heldOutLabels
+}
+
 func filterPageByIndex(docs []*goquery.Document, formResults [][]classifier.ClassifyResult, urls, labels []string, testSet []bool, isTest bool) ([]*goquery.Document, [][]classifier.ClassifyResult, []string, []string) {
 	var outDocs []*goquery.Document
 	var outFormResults [][]classifier.ClassifyResult
@@ -0,0 +1,100 @@
+package dit
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/happyhackingspace/dit/classifier"
+	"github.com/happyhackingspace/dit/internal/storage"
+)
+
+// TuneConfig holds configuration for decision threshold tuning.
+type TuneConfig struct {
+	Folds int
+	// MinPrecision, if set, tunes each form type for the highest recall at
+	// this precision instead of the best F1.
+	MinPrecision float64
+	Verbose      bool
+	// Logger receives warnings; nil keeps tuning silent.
+	Logger *slog.Logger
+}
+
+// TuneThresholds tunes per-class form type decision thresholds on held-out
+// predictions from cross-validation over the annotated forms in dataDir.
+// Each fold's model is trained with the learner and config the form model
+// was trained with (see classifier.FormTypeModel.Retrainer), and its
+// probabilities are calibrated like the form model's; models without a
+// recorded config, such as those saved by older dit versions, are
+// rejected. The thresholds are stored in a copy of the classifier's form
+// model that is swapped in as by Reload, so calls already running finish
+// on the untuned one; they are applied by ExtractForms and the other form
+// APIs, and persisted by Save.
+func (c *Classifier) TuneThresholds(dataDir string, config *TuneConfig) (map[string]float64, error) {
+	fc := c.models()
+	if fc == nil || fc.FormModel == nil {
+		return nil, ErrNotInitialized
+	}
+	train, err := fc.FormModel.Retrainer()
+	if err != nil {
+		return nil, fmt.Errorf("dit: tune thresholds: %w", err)
+	}
+	if config == nil {
+		config = &TuneConfig{}
+	}
+	nFolds := 10
+	if config.Folds > 0 {
+		nFolds = config.Folds
+	}
+
+	store := storage.NewStorage(filepath.Join(dataDir, "forms"))
+	opts := storage.DefaultIterOptions()
+	opts.Verbose = config.Verbose
+	opts.Logger = config.Logger
+	annotations, err := store.IterAnnotations(opts)
+	if err != nil {
+		return nil, fmt.Errorf("dit: %w", err)
+	}
+	formAnnotations := filterFormAnnotated(annotations)
+	if len(formAnnotations) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoAnnotations, dataDir)
+	}
+
+	// The fold models carry the form model's calibration, so the
+	// thresholds are tuned on calibrated probabilities, as they apply.
+	probas, labels := heldOutFormProbas(formAnnotations, nFolds, train)
+	thresholds := classifier.TuneThresholds(probas, labels, config.MinPrecision)
+	err = c.updateModels(fc, func(next *classifier.FormFieldClassifier) {
+		next.FormModel.Thresholds = thresholds
+	})
+	if err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// heldOutFormProbas returns out-of-fold form type probabilities and their
+// gold labels, with folds grouped by domain as in Evaluate and each fold's
+// model trained by train. A fold holding every domain has nothing to train
+// on and is left out.
+func heldOutFormProbas(annotations []storage.FormAnnotation, nFolds int, train func(forms []*goquery.Selection, labels []string) *classifier.FormTypeModel) ([]map[string]float64, []string) {
+	forms, labels := extractFormTrainingData(annotations)
+	folds := groupKFold(domainGroups(annotations), nFolds)
+
+	var probas []map[string]float64
+	var heldOutLabels []string
+	for _, testIdx := range folds {
+		testSet := makeTestSet(len(forms), testIdx)
+		trainForms, trainLabels := filterByIndex(forms, labels, testSet, false)
+		if len(trainForms) == 0 {
+			continue
+		}
+		model := train(trainForms, trainLabels)
+		for _, idx := range testIdx {
+			probas = append(probas, model.ClassifyProba(forms[idx]))
+			heldOutLabels = append(heldOutLabels, labels[idx])
+		}
+	}
+	return probas, heldOutLabels
+}
@@ -0,0 +1,222 @@
+package vectorizer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/happyhackingspace/dit/internal/textutil"
+)
+
+// CountVectorizer converts text to token count vectors.
+type CountVectorizer struct {
+	Vocabulary map[string]int `json:"vocabulary"`
+	NgramRange [2]int         `json:"ngram_range"`
+	Binary     bool           `json:"binary"`
+	Analyzer   string         `json:"analyzer"` // "word" or "char_wb"
+	MinDF      int            `json:"min_df"`
+}
+
+// NewCountVectorizer creates a CountVectorizer with default settings.
+func NewCountVectorizer(ngramRange [2]int, binary bool, analyzer string, minDF int) *CountVectorizer {
+	if analyzer == "" {
+		analyzer = "word"
+	}
+	if minDF < 1 {
+		minDF = 1
+	}
+	return &CountVectorizer{
+		NgramRange: ngramRange,
+		Binary:     binary,
+		Analyzer:   analyzer,
+		MinDF:      minDF,
+	}
+}
+
+// analyze extracts features from text based on the analyzer type.
+func (cv *CountVectorizer) analyze(text string) []string {
+	text = strings.ToLower(text)
+	if cv.Analyzer == "char_wb" {
+		return charWbNgrams(text, cv.NgramRange[0], cv.NgramRange[1])
+	}
+	// word analyzer
+	tokens := textutil.Tokenize(text)
+	return textutil.TokenNgrams(tokens, cv.NgramRange[0], cv.NgramRange[1])
+}
+
+// charWbNgrams extracts character n-grams within word boundaries.
+// Each word is padded with spaces, and n-grams are extracted from padded words.
+func charWbNgrams(text string, minN, maxN int) []string {
+	tokens := textutil.Tokenize(text)
+	var result []string
+	for _, token := range tokens {
+		padded := " " + token + " "
+		result = append(result, textutil.Ngrams(padded, minN, maxN)...)
+	}
+	return result
+}
+
+// corpusScan holds the result of a single analysis pass over a corpus.
+// Terms are interned to dense ids in order of first appearance.
+type corpusScan struct {
+	terms []string      // id -> term
+	df    []int         // id -> document frequency
+	docs  [][]termCount // per-document term counts, if requested
+}
+
+// termCount is the number of occurrences of an interned term in a document.
+type termCount struct {
+	id    int32
+	count int32
+}
+
+// scan analyzes each document exactly once, counting document frequencies
+// as it goes. With keepDocs it also records each document's term counts by
+// interned id, which is far smaller than keeping the analyzed strings.
+func (cv *CountVectorizer) scan(corpus []string, keepDocs bool) *corpusScan {
+	s := &corpusScan{}
+	if keepDocs {
+		s.docs = make([][]termCount, len(corpus))
+	}
+	ids := make(map[string]int32)
+	docIdx := make(map[int32]int) // id -> position in the current doc's counts
+	for i, doc := range corpus {
+		var counts []termCount
+		clear(docIdx)
+		for _, f := range cv.analyze(doc) {
+			id, ok := ids[f]
+			if !ok {
+				id = int32(len(s.terms))
+				ids[f] = id
+				s.terms = append(s.terms, f)
+				s.df = append(s.df, 0)
+			}
+			if pos, ok := docIdx[id]; ok {
+				counts[pos].count++
+				continue
+			}
+			docIdx[id] = len(counts)
+			counts = append(counts, termCount{id: id, count: 1})
+			s.df[id]++
+		}
+		if keepDocs {
+			s.docs[i] = counts
+		}
+	}
+	return s
+}
+
+// fitScan builds the vocabulary from a scan and returns the mapping from
+// interned id to vocabulary index (-1 for terms below min_df).
+func (cv *CountVectorizer) fitScan(s *corpusScan) []int {
+	// Sort terms for deterministic ordering
+	kept := make([]int32, 0, len(s.terms))
+	for id, count := range s.df {
+		if count >= cv.MinDF {
+			kept = append(kept, int32(id))
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return s.terms[kept[i]] < s.terms[kept[j]] })
+
+	cv.Vocabulary = make(map[string]int, len(kept))
+	remap := make([]int, len(s.terms))
+	for i := range remap {
+		remap[i] = -1
+	}
+	for i, id := range kept {
+		cv.Vocabulary[s.terms[id]] = i
+		remap[id] = i
+	}
+	return remap
+}
+
+// vocabDF returns the document frequency of each of dim vocabulary terms.
+func (s *corpusScan) vocabDF(remap []int, dim int) []int {
+	df := make([]int, dim)
+	for id, idx := range remap {
+		if idx >= 0 {
+			df[idx] = s.df[id]
+		}
+	}
+	return df
+}
+
+// Fit builds the vocabulary from a corpus.
+func (cv *CountVectorizer) Fit(corpus []string) {
+	cv.fitScan(cv.scan(corpus, false))
+}
+
+// FitTransform fits the vocabulary and transforms the corpus, analyzing
+// each document only once.
+func (cv *CountVectorizer) FitTransform(corpus []string) []SparseVector {
+	vecs, _ := cv.fitTransform(corpus)
+	return vecs
+}
+
+// fitTransform is FitTransform that also returns the document frequency
+// of each vocabulary term.
+func (cv *CountVectorizer) fitTransform(corpus []string) ([]SparseVector, []int) {
+	s := cv.scan(corpus, true)
+	remap := cv.fitScan(s)
+
+	dim := len(cv.Vocabulary)
+	result := make([]SparseVector, len(corpus))
+	for i, counts := range s.docs {
+		sv := NewSparseVector(dim)
+		for _, tc := range counts {
+			idx := remap[tc.id]
+			if idx < 0 {
+				continue
+			}
+			val := float64(tc.count)
+			if cv.Binary {
+				val = 1.0
+			}
+			sv.Indices = append(sv.Indices, idx)
+			sv.Values = append(sv.Values, val)
+		}
+		result[i] = sv
+		s.docs[i] = nil // release as we go
+	}
+	return result, s.vocabDF(remap, dim)
+}
+
+// Transform converts a single document to a sparse vector.
+func (cv *CountVectorizer) Transform(text string) SparseVector {
+	dim := len(cv.Vocabulary)
+	sv := NewSparseVector(dim)
+	features := cv.analyze(text)
+
+	counts := make(map[int]float64)
+	for _, f := range features {
+		if idx, ok := cv.Vocabulary[f]; ok {
+			counts[idx]++
+		}
+	}
+
+	for idx, count := range counts {
+		if cv.Binary {
+			sv.Set(idx, 1.0)
+		} else {
+			sv.Set(idx, count)
+		}
+	}
+	return sv
+}
+
+// VocabSize returns the vocabulary size.
+func (cv *CountVectorizer) VocabSize() int {
+	return len(cv.Vocabulary)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (cv *CountVectorizer) MarshalJSON() ([]byte, error) {
+	type Alias CountVectorizer
+	return json.Marshal((*Alias)(cv))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (cv *CountVectorizer) UnmarshalJSON(data []byte) error {
+	type Alias CountVectorizer
+	return json.Unmarshal(data, (*Alias)(cv))
+}
@@ -1,4 +1,12 @@
 // Package vectorizer provides text vectorization utilities matching sklearn behavior.
+//
+// DictVectorizer, CountVectorizer, and TfidfVectorizer follow sklearn's
+// Fit/Transform/FitTransform API and serialize to JSON, so a fitted
+// vectorizer can be stored alongside a model and reloaded:
+//
+//	tv := vectorizer.NewTfidfVectorizer([2]int{1, 2}, 1, true, "word", vectorizer.EnglishStopWords())
+//	vecs := tv.FitTransform(corpus)   // []SparseVector, L2-normalized
+//	v := tv.Transform("new document") // same vocabulary and IDF weights
 package vectorizer
 
 import "math"
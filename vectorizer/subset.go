@@ -0,0 +1,66 @@
+package vectorizer
+
+import "sort"
+
+// Terms returns the vocabulary ordered by feature index.
+func (cv *CountVectorizer) Terms() []string {
+	terms := make([]string, len(cv.Vocabulary))
+	for term, idx := range cv.Vocabulary {
+		terms[idx] = term
+	}
+	return terms
+}
+
+// Subset returns a copy of the vectorizer whose vocabulary holds only the
+// given terms (in sorted order); terms not in the vocabulary are skipped.
+// It lets a compact model keep just the features it uses.
+func (cv *CountVectorizer) Subset(terms []string) *CountVectorizer {
+	sub := *cv
+	sub.Vocabulary = make(map[string]int)
+	for i, term := range subsetOf(terms, cv.Vocabulary) {
+		sub.Vocabulary[term] = i
+	}
+	return &sub
+}
+
+// Subset returns a copy of the vectorizer restricted to the given terms,
+// keeping their IDF weights. Values are L2-normalized over the remaining
+// terms only, so only their presence, not their weight, carries over.
+func (tv *TfidfVectorizer) Subset(terms []string) *TfidfVectorizer {
+	sub := *tv
+	sub.CountVec = tv.CountVec.Subset(terms)
+	sub.IDF = make([]float64, sub.CountVec.VocabSize())
+	for term, idx := range sub.CountVec.Vocabulary {
+		if old := tv.CountVec.Vocabulary[term]; old < len(tv.IDF) {
+			sub.IDF[idx] = tv.IDF[old]
+		}
+	}
+	return &sub
+}
+
+// Subset returns a copy of the vectorizer holding only the given feature
+// names; names it does not know are skipped.
+func (dv *DictVectorizer) Subset(names []string) *DictVectorizer {
+	sub := &DictVectorizer{
+		FeatureNames: subsetOf(names, dv.FeatureIndex),
+		FeatureIndex: make(map[string]int),
+	}
+	for i, name := range sub.FeatureNames {
+		sub.FeatureIndex[name] = i
+	}
+	return sub
+}
+
+// subsetOf returns the distinct keys of vocab among names, sorted.
+func subsetOf(names []string, vocab map[string]int) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, name := range names {
+		if _, ok := vocab[name]; ok && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
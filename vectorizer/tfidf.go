@@ -20,47 +20,45 @@ func NewTfidfVectorizer(ngramRange [2]int, minDF int, binary bool, analyzer stri
 	}
 }
 
-// Fit computes IDF values from a corpus.
+// Fit computes IDF values from a corpus in a single analysis pass.
 func (tv *TfidfVectorizer) Fit(corpus []string) {
 	// Filter stop words from corpus for word analyzer
 	filtered := tv.filterCorpus(corpus)
-	tv.CountVec.Fit(filtered)
-
-	nDocs := float64(len(filtered))
-	vocabSize := tv.CountVec.VocabSize()
-	tv.IDF = make([]float64, vocabSize)
-
-	// Compute document frequencies
-	df := make([]float64, vocabSize)
-	for _, doc := range filtered {
-		sv := tv.CountVec.Transform(doc)
-		for _, idx := range sv.Indices {
-			df[idx]++
-		}
-	}
-
-	// sklearn smooth IDF: log((1 + n) / (1 + df)) + 1
-	for i := 0; i < vocabSize; i++ {
-		tv.IDF[i] = math.Log((1+nDocs)/(1+df[i])) + 1
-	}
+	s := tv.CountVec.scan(filtered, false)
+	remap := tv.CountVec.fitScan(s)
+	tv.setIDF(len(filtered), s.vocabDF(remap, tv.CountVec.VocabSize()))
 }
 
-// FitTransform fits and transforms the corpus.
+// FitTransform fits and transforms the corpus, analyzing each document once.
 func (tv *TfidfVectorizer) FitTransform(corpus []string) []SparseVector {
-	tv.Fit(corpus)
-	result := make([]SparseVector, len(corpus))
-	for i, doc := range corpus {
-		result[i] = tv.Transform(doc)
+	filtered := tv.filterCorpus(corpus)
+	result, df := tv.CountVec.fitTransform(filtered)
+	tv.setIDF(len(filtered), df)
+	for i := range result {
+		tv.weight(&result[i])
 	}
 	return result
 }
 
+// setIDF computes IDF values from document frequencies.
+func (tv *TfidfVectorizer) setIDF(nDocs int, df []int) {
+	tv.IDF = make([]float64, len(df))
+	// sklearn smooth IDF: log((1 + n) / (1 + df)) + 1
+	for i, d := range df {
+		tv.IDF[i] = math.Log((1+float64(nDocs))/(1+float64(d))) + 1
+	}
+}
+
 // Transform converts a single document to a TF-IDF sparse vector.
 func (tv *TfidfVectorizer) Transform(text string) SparseVector {
 	filtered := tv.filterText(text)
 	sv := tv.CountVec.Transform(filtered)
+	tv.weight(&sv)
+	return sv
+}
 
-	// Apply IDF weights
+// weight applies IDF weights to a count vector and L2-normalizes it.
+func (tv *TfidfVectorizer) weight(sv *SparseVector) {
 	for i, idx := range sv.Indices {
 		if idx < len(tv.IDF) {
 			sv.Values[i] *= tv.IDF[idx]
@@ -74,7 +72,6 @@ func (tv *TfidfVectorizer) Transform(text string) SparseVector {
 			sv.Values[i] /= norm
 		}
 	}
-	return sv
 }
 
 // VocabSize returns the vocabulary size.
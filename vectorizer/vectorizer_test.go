@@ -2,6 +2,7 @@ package vectorizer
 
 import (
 	"math"
+	"reflect"
 	"testing"
 )
 
@@ -101,6 +102,44 @@ func TestTfidfVectorizer(t *testing.T) {
 	}
 }
 
+func TestFitTransformMatchesTransform(t *testing.T) {
+	corpus := []string{"hello world hello", "hello universe", "world peace", "lonely"}
+	sameDense := func(a, b SparseVector) bool {
+		da, db := a.ToDense(), b.ToDense()
+		if len(da) != len(db) {
+			return false
+		}
+		for i := range da {
+			if math.Abs(da[i]-db[i]) > 1e-12 {
+				return false
+			}
+		}
+		return true
+	}
+
+	cv := NewCountVectorizer([2]int{1, 2}, false, "word", 2)
+	for i, v := range cv.FitTransform(corpus) {
+		if want := cv.Transform(corpus[i]); !sameDense(v, want) {
+			t.Errorf("count doc %d: FitTransform = %v, Transform = %v", i, v.ToDense(), want.ToDense())
+		}
+	}
+
+	tv := NewTfidfVectorizer([2]int{2, 3}, 1, true, "char_wb", nil)
+	vectors := tv.FitTransform(corpus)
+	idf := append([]float64(nil), tv.IDF...)
+	tv.Fit(corpus)
+	for i := range idf {
+		if idf[i] != tv.IDF[i] {
+			t.Fatalf("IDF[%d] = %v after FitTransform, %v after Fit", i, idf[i], tv.IDF[i])
+		}
+	}
+	for i, v := range vectors {
+		if want := tv.Transform(corpus[i]); !sameDense(v, want) {
+			t.Errorf("tfidf doc %d: FitTransform differs from Transform", i)
+		}
+	}
+}
+
 func TestTfidfVectorizerStopWords(t *testing.T) {
 	stopWords := map[string]bool{"the": true, "a": true}
 	tv := NewTfidfVectorizer([2]int{1, 1}, 1, true, "word", stopWords)
@@ -160,3 +199,29 @@ func TestDictVectorizerTransform(t *testing.T) {
 		t.Errorf("unknown feature value should produce no entries, got %d", sv2.Nnz())
 	}
 }
+
+func TestSubset(t *testing.T) {
+	tv := NewTfidfVectorizer([2]int{1, 1}, 1, true, "word", nil)
+	tv.Fit([]string{"sign in now", "sign up", "read the blog"})
+
+	sub := tv.Subset([]string{"sign", "blog", "missing"})
+	if got := sub.CountVec.Terms(); !reflect.DeepEqual(got, []string{"blog", "sign"}) {
+		t.Errorf("Terms() = %v, want [blog sign]", got)
+	}
+	if got, want := sub.IDF[sub.CountVec.Vocabulary["sign"]], tv.IDF[tv.CountVec.Vocabulary["sign"]]; got != want {
+		t.Errorf("IDF(sign) = %v, want %v", got, want)
+	}
+	if sv := sub.Transform("sign the blog"); sv.Dim != 2 || sv.Nnz() != 2 {
+		t.Errorf("Transform = %+v, want both subset terms", sv)
+	}
+	if tv.CountVec.VocabSize() == sub.CountVec.VocabSize() {
+		t.Error("Subset modified the original vocabulary")
+	}
+
+	dv := NewDictVectorizer()
+	dv.Fit([]map[string]any{{"method": "post", "fields": 2}})
+	dsub := dv.Subset([]string{"method=post"})
+	if !reflect.DeepEqual(dsub.FeatureNames, []string{"method=post"}) || dsub.Transform(map[string]any{"method": "post", "fields": 3}).Nnz() != 1 {
+		t.Errorf("DictVectorizer.Subset = %+v", dsub)
+	}
+}
@@ -0,0 +1,45 @@
+// JavaScript bindings for dit compiled to WebAssembly (dit.wasm), for
+// browsers and Node.js. Build dit.wasm and copy Go's wasm_exec.js next to
+// this file as described in README.md.
+import "./wasm_exec.js";
+
+/**
+ * Starts dit.wasm and loads a model into it.
+ *
+ * @param {object} options
+ * @param {BufferSource|Response|Promise<Response>} options.wasm dit.wasm,
+ *   as bytes or as a fetch() of it
+ * @param {Uint8Array} [options.model] model.json contents, plain or
+ *   gzipped; without it the compact model embedded in dit.wasm is used
+ * @returns {Promise<{extractForms(html: string): object[]}>}
+ */
+export async function load({ wasm, model }) {
+  const go = new globalThis.Go();
+  const source = await wasm;
+  const { instance } =
+    typeof Response !== "undefined" && source instanceof Response
+      ? await WebAssembly.instantiateStreaming(source, go.importObject)
+      : await WebAssembly.instantiate(source, go.importObject);
+  // main registers __dit and then blocks, handing control back here.
+  go.run(instance);
+  const dit = globalThis.__dit;
+  delete globalThis.__dit;
+
+  const err = dit.load(model);
+  if (err !== null) {
+    throw new Error(err);
+  }
+  return {
+    /**
+     * Classifies the forms of a page, returning the same objects as
+     * dit.Classifier.ExtractForms: [{type, fields: {name: type}, ...}].
+     */
+    extractForms(html) {
+      const out = dit.extractForms(html);
+      if (typeof out !== "string") {
+        throw new Error(out.error);
+      }
+      return JSON.parse(out);
+    },
+  };
+}